@@ -0,0 +1,231 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// ErrBreakerOpen is the error of lookups failed fast because the circuit breaker is open.
+var ErrBreakerOpen = errors.New("backend circuit breaker is open")
+
+// The circuit breaker states, they are the values of the backend_breaker_state gauge.
+const (
+	// breakerClosed passes lookups to the backend.
+	breakerClosed breakerState = iota
+	// breakerOpen fails lookups fast until the cooldown is over.
+	breakerOpen
+	// breakerHalfOpen passes a single probe lookup to the backend, its result closes or reopens the breaker.
+	breakerHalfOpen
+)
+
+type breakerState int
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker is a circuit breaker around backend lookups. It opens after failures
+// consecutive lookups failed for a transient reason, see IsTransient, within window of
+// the first of them, and fails lookups fast with ErrBreakerOpen for cooldown, after which
+// a single probe lookup is passed to the backend: it closes the breaker if it succeeds
+// and reopens it otherwise. Hardware that is not found is a success, the backend
+// answered. A nil Breaker passes all lookups.
+type Breaker struct {
+	failures int
+	window   time.Duration
+	cooldown time.Duration
+	now      func() time.Time
+	// onChange is called with the new state whenever it changes, b.mu is held
+	onChange func(state string)
+
+	mu          sync.Mutex
+	state       breakerState
+	consecutive int
+	// firstFailure is when the first of the consecutive failures happened
+	firstFailure time.Time
+	openedAt     time.Time
+	probing      bool
+}
+
+// NewBreaker returns a Breaker opening after failures consecutive failed lookups within
+// window, for cooldown. A window of 0 counts consecutive failures however far apart they
+// are. Returns nil if failures is 0.
+func NewBreaker(failures int, window, cooldown time.Duration) (*Breaker, error) {
+	if failures < 0 {
+		return nil, errors.New("breaker failures must not be negative")
+	}
+	if failures == 0 {
+		return nil, nil
+	}
+	if window < 0 {
+		return nil, errors.New("breaker window must not be negative")
+	}
+	if cooldown <= 0 {
+		return nil, errors.New("breaker cooldown must be greater than 0")
+	}
+	metrics.BackendBreaker.Set(float64(breakerClosed))
+
+	return &Breaker{failures: failures, window: window, cooldown: cooldown, now: time.Now}, nil
+}
+
+// OnChange sets f to be called with the new state, closed, open or half-open, whenever
+// it changes. It must not block.
+func (b *Breaker) OnChange(f func(state string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onChange = f
+}
+
+// Do calls lookup unless the breaker is open, in which case ErrBreakerOpen is returned
+// right away, name is the lookup it is counted as. Returns the error of lookup.
+func (b *Breaker) Do(name string, lookup func() error) error {
+	if b == nil {
+		return lookup()
+	}
+	if !b.allow() {
+		metrics.BackendRejected.WithLabelValues(name).Inc()
+
+		return ErrBreakerOpen
+	}
+	err := lookup()
+	b.done(err)
+
+	return err
+}
+
+// allow reports whether a lookup may be passed to the backend, once the cooldown is
+// over the first lookup is the probe.
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerClosed:
+		return true
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.set(breakerHalfOpen)
+	}
+	if b.probing {
+		return false
+	}
+	b.probing = true
+
+	return true
+}
+
+// done records the result of a lookup passed to the backend.
+func (b *Breaker) done(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := b.probing && b.state == breakerHalfOpen
+	switch {
+	case errors.Is(err, context.Canceled):
+		// the caller gave up, the backend may still be fine
+		if probe {
+			b.probing = false
+		}
+	case !IsTransient(err):
+		b.consecutive = 0
+		b.probing = false
+		b.set(breakerClosed)
+	default:
+		// failures further apart than the window start counting again
+		if b.consecutive == 0 || b.window > 0 && b.now().Sub(b.firstFailure) > b.window {
+			b.consecutive = 0
+			b.firstFailure = b.now()
+		}
+		b.consecutive++
+		if probe || b.state == breakerClosed && b.consecutive >= b.failures {
+			b.probing = false
+			b.openedAt = b.now()
+			b.set(breakerOpen)
+		}
+	}
+}
+
+// set changes the state to s, b.mu must be held.
+func (b *Breaker) set(s breakerState) {
+	if b.state == s {
+		return
+	}
+	b.state = s
+	metrics.BackendBreaker.Set(float64(s))
+	if b.onChange != nil {
+		b.onChange(s.String())
+	}
+}
+
+// BreakHardwareFinder returns f with its lookups passed through b, or f itself if b is nil.
+func BreakHardwareFinder(f HardwareFinder, b *Breaker) HardwareFinder {
+	if b == nil {
+		return f
+	}
+
+	return breakingHardwareFinder{finder: f, breaker: b}
+}
+
+type breakingHardwareFinder struct {
+	finder  HardwareFinder
+	breaker *Breaker
+}
+
+func (r breakingHardwareFinder) ByIP(ctx context.Context, ip net.IP) (Discoverer, error) {
+	var d Discoverer
+	err := r.breaker.Do("ip", func() (err error) {
+		d, err = r.finder.ByIP(ctx, ip)
+
+		return err
+	})
+
+	return d, err
+}
+
+func (r breakingHardwareFinder) ByMAC(ctx context.Context, mac net.HardwareAddr, giaddr net.IP, circuitID string) (Discoverer, error) {
+	var d Discoverer
+	err := r.breaker.Do("mac", func() (err error) {
+		d, err = r.finder.ByMAC(ctx, mac, giaddr, circuitID)
+
+		return err
+	})
+
+	return d, err
+}
+
+// BreakWorkflowFinder returns f with its lookups passed through b, or f itself if b is nil.
+func BreakWorkflowFinder(f WorkflowFinder, b *Breaker) WorkflowFinder {
+	if b == nil {
+		return f
+	}
+
+	return breakingWorkflowFinder{finder: f, breaker: b}
+}
+
+type breakingWorkflowFinder struct {
+	finder  WorkflowFinder
+	breaker *Breaker
+}
+
+func (r breakingWorkflowFinder) HasActiveWorkflow(ctx context.Context, id HardwareID) (bool, error) {
+	var active bool
+	err := r.breaker.Do("workflow", func() (err error) {
+		active, err = r.finder.HasActiveWorkflow(ctx, id)
+
+		return err
+	})
+
+	return active, err
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBreaker(t *testing.T) {
+	transient := errors.Wrap(context.DeadlineExceeded, "lookup")
+	b, err := NewBreaker(2, 0, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	var states []string
+	b.OnChange(func(state string) { states = append(states, state) })
+	lookup := func(err error) func() error {
+		return func() error { return err }
+	}
+	rejected := metrics.BackendRejected.WithLabelValues("mac")
+	before := testutil.ToFloat64(rejected)
+
+	// not found is a success, it resets the consecutive failures
+	_ = b.Do("mac", lookup(transient))
+	_ = b.Do("mac", lookup(ErrNotFound))
+	_ = b.Do("mac", lookup(transient))
+	if b.state != breakerClosed {
+		t.Fatalf("want closed after non consecutive failures, got %v", b.state)
+	}
+	_ = b.Do("mac", lookup(transient))
+	if b.state != breakerOpen || testutil.ToFloat64(metrics.BackendBreaker) != 1 {
+		t.Fatalf("want open after 2 consecutive failures, got %v", b.state)
+	}
+
+	// open, lookups fail fast without reaching the backend
+	if err := b.Do("mac", func() error { t.Fatal("want no lookup while open"); return nil }); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("want ErrBreakerOpen, got: %v", err)
+	}
+	if n := testutil.ToFloat64(rejected) - before; n != 1 {
+		t.Fatalf("want 1 rejected lookup, got %v", n)
+	}
+
+	// after the cooldown a failed probe reopens the breaker
+	now = now.Add(time.Minute)
+	if err := b.Do("mac", lookup(transient)); !errors.Is(err, transient) {
+		t.Fatalf("want the probe to reach the backend, got: %v", err)
+	}
+	if b.state != breakerOpen {
+		t.Fatalf("want open after a failed probe, got %v", b.state)
+	}
+	if err := b.Do("mac", lookup(nil)); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("want the cooldown restarted by the failed probe, got: %v", err)
+	}
+
+	// a successful probe closes it, while it is in flight other lookups fail fast
+	now = now.Add(time.Minute)
+	err = b.Do("mac", func() error {
+		if b.state != breakerHalfOpen || testutil.ToFloat64(metrics.BackendBreaker) != 2 {
+			t.Fatalf("want half-open during the probe, got %v", b.state)
+		}
+		if err := b.Do("mac", lookup(nil)); !errors.Is(err, ErrBreakerOpen) {
+			t.Fatalf("want lookups during the probe to fail fast, got: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil || b.state != breakerClosed || testutil.ToFloat64(metrics.BackendBreaker) != 0 {
+		t.Fatalf("want closed after a successful probe, got %v, %v", b.state, err)
+	}
+
+	want := []string{"open", "half-open", "open", "half-open", "closed"}
+	if len(states) != len(want) {
+		t.Fatalf("want state changes %v, got %v", want, states)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Fatalf("want state changes %v, got %v", want, states)
+		}
+	}
+}
+
+func TestBreakerCanceledProbe(t *testing.T) {
+	b, err := NewBreaker(1, 0, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	_ = b.Do("ip", func() error { return context.DeadlineExceeded })
+	now = now.Add(time.Minute)
+
+	// a probe whose caller gave up says nothing about the backend, the next lookup probes again
+	_ = b.Do("ip", func() error { return context.Canceled })
+	if b.state != breakerHalfOpen {
+		t.Fatalf("want half-open after a canceled probe, got %v", b.state)
+	}
+	if err := b.Do("ip", func() error { return nil }); err != nil || b.state != breakerClosed {
+		t.Fatalf("want closed after the next probe, got %v, %v", b.state, err)
+	}
+}
+
+func TestBreakerWindow(t *testing.T) {
+	b, err := NewBreaker(2, time.Minute, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	transient := func() error { return context.DeadlineExceeded }
+
+	// consecutive failures further apart than the window do not open the breaker
+	_ = b.Do("mac", transient)
+	now = now.Add(time.Minute + time.Second)
+	_ = b.Do("mac", transient)
+	if b.state != breakerClosed {
+		t.Fatalf("want closed after failures outside the window, got %v", b.state)
+	}
+	now = now.Add(30 * time.Second)
+	_ = b.Do("mac", transient)
+	if b.state != breakerOpen {
+		t.Fatalf("want open after 2 failures within the window, got %v", b.state)
+	}
+}
+
+func TestBreakerGRPCUnavailable(t *testing.T) {
+	b, err := NewBreaker(2, 0, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the tink backend reports an unreachable server as a gRPC status, not a network error
+	unavailable := func() error {
+		return errors.WithMessage(status.Error(codes.Unavailable, "connection refused"), "get hardware")
+	}
+	_ = b.Do("mac", unavailable)
+	_ = b.Do("mac", unavailable)
+	if b.state != breakerOpen {
+		t.Fatalf("want open after 2 consecutive gRPC Unavailable lookups, got %v", b.state)
+	}
+}
+
+func TestNewBreaker(t *testing.T) {
+	if b, err := NewBreaker(0, 0, 0); err != nil || b != nil {
+		t.Fatalf("want a nil breaker without failures, got: %v, %v", b, err)
+	}
+	if _, err := NewBreaker(-1, 0, time.Second); err == nil {
+		t.Fatal("want an error for negative failures")
+	}
+	if _, err := NewBreaker(1, 0, 0); err == nil {
+		t.Fatal("want an error for a zero cooldown")
+	}
+	if _, err := NewBreaker(1, -time.Second, time.Second); err == nil {
+		t.Fatal("want an error for a negative window")
+	}
+	var b *Breaker
+	for i := 0; i < 3; i++ {
+		if err := b.Do("ip", func() error { return nil }); err != nil {
+			t.Fatalf("want a nil breaker to pass lookups, got: %v", err)
+		}
+	}
+	if f := BreakHardwareFinder(nil, nil); f != nil {
+		t.Fatalf("want the finder itself without a breaker, got %T", f)
+	}
+}
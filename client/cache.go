@@ -0,0 +1,129 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// CachingFinder is a HardwareFinder that remembers the hardware found by another
+// HardwareFinder for a TTL, so the machines booting at once do not each hit the
+// backend for every request. Only successful lookups are cached. At most size
+// lookups are kept, the least recently used is evicted to make room.
+type CachingFinder struct {
+	finder HardwareFinder
+	ttl    time.Duration
+	size   int
+	now    func() time.Time
+
+	mu sync.Mutex
+	// lru holds *cacheEntry, most recently used first
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key      string
+	hardware Discoverer
+	expires  time.Time
+}
+
+// NewCachingFinder returns f cached for ttl, or f itself if ttl is 0.
+func NewCachingFinder(f HardwareFinder, ttl time.Duration, size int) (HardwareFinder, error) {
+	if ttl < 0 {
+		return nil, errors.New("hardware cache ttl must not be negative")
+	}
+	if ttl == 0 {
+		return f, nil
+	}
+	if size <= 0 {
+		return nil, errors.New("hardware cache size must be greater than 0")
+	}
+
+	return &CachingFinder{
+		finder:  f,
+		ttl:     ttl,
+		size:    size,
+		now:     time.Now,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}, nil
+}
+
+// ByIP returns the cached hardware for ip, looking it up if it is not cached or has expired.
+func (c *CachingFinder) ByIP(ctx context.Context, ip net.IP) (Discoverer, error) {
+	return c.lookup("ip", "ip/"+ip.String(), func() (Discoverer, error) {
+		return c.finder.ByIP(ctx, ip)
+	})
+}
+
+// ByMAC returns the cached hardware for mac, giaddr and circuitID, looking it up
+// if it is not cached or has expired.
+func (c *CachingFinder) ByMAC(ctx context.Context, mac net.HardwareAddr, giaddr net.IP, circuitID string) (Discoverer, error) {
+	return c.lookup("mac", "mac/"+mac.String()+"/"+giaddr.String()+"/"+circuitID, func() (Discoverer, error) {
+		return c.finder.ByMAC(ctx, mac, giaddr, circuitID)
+	})
+}
+
+func (c *CachingFinder) lookup(kind, key string, find func() (Discoverer, error)) (Discoverer, error) {
+	if d, ok := c.get(key); ok {
+		metrics.HardwareCacheTotal.WithLabelValues(kind, "hit").Inc()
+
+		return d, nil
+	}
+	metrics.HardwareCacheTotal.WithLabelValues(kind, "miss").Inc()
+
+	d, err := find()
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, d)
+
+	return d, nil
+}
+
+// get returns the unexpired hardware cached for key.
+func (c *CachingFinder) get(key string) (Discoverer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+
+	return entry.hardware, true
+}
+
+func (c *CachingFinder) set(key string, d Discoverer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*cacheEntry)
+		entry.hardware, entry.expires = d, expires
+		c.lru.MoveToFront(e)
+
+		return
+	}
+	for c.lru.Len() >= c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, hardware: d, expires: expires})
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	l "github.com/packethost/pkg/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestMain(m *testing.M) {
+	logger, _ := l.Init("github.com/tinkerbell/boots")
+	metrics.Init(logger)
+	os.Exit(m.Run())
+}
+
+// fakeDiscoverer is a Discoverer identified by id.
+type fakeDiscoverer struct {
+	Discoverer
+	id string
+}
+
+// countingFinder finds a new fakeDiscoverer on every lookup, failing when err is set.
+type countingFinder struct {
+	lookups int
+	err     error
+}
+
+func (f *countingFinder) ByIP(context.Context, net.IP) (Discoverer, error) {
+	return f.find()
+}
+
+func (f *countingFinder) ByMAC(context.Context, net.HardwareAddr, net.IP, string) (Discoverer, error) {
+	return f.find()
+}
+
+func (f *countingFinder) find() (Discoverer, error) {
+	f.lookups++
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return fakeDiscoverer{id: string(rune('a' + f.lookups))}, nil
+}
+
+func TestCachingFinder(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingFinder{}
+	hf, err := NewCachingFinder(backend, time.Minute, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := hf.(*CachingFinder)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	hits := metrics.HardwareCacheTotal.WithLabelValues("mac", "hit")
+	misses := metrics.HardwareCacheTotal.WithLabelValues("mac", "miss")
+	hitsBefore, missesBefore := testutil.ToFloat64(hits), testutil.ToFloat64(misses)
+
+	mac := net.HardwareAddr{0x00, 0xba, 0xdd, 0xbe, 0xef, 0x00}
+	first, _ := c.ByMAC(ctx, mac, nil, "")
+	second, _ := c.ByMAC(ctx, mac, nil, "")
+	if first != second || backend.lookups != 1 {
+		t.Fatalf("want the second lookup cached, got %d backend lookups", backend.lookups)
+	}
+	if got := testutil.ToFloat64(hits) - hitsBefore; got != 1 {
+		t.Fatalf("want 1 hit, got: %v", got)
+	}
+	if got := testutil.ToFloat64(misses) - missesBefore; got != 1 {
+		t.Fatalf("want 1 miss, got: %v", got)
+	}
+
+	now = now.Add(time.Minute)
+	if expired, _ := c.ByMAC(ctx, mac, nil, ""); expired == first || backend.lookups != 2 {
+		t.Fatal("want the lookup repeated once the ttl expired")
+	}
+
+	// the mac lookup is the least recently used, so it is evicted for the two ip lookups
+	_, _ = c.ByIP(ctx, net.ParseIP("192.168.1.10"))
+	_, _ = c.ByIP(ctx, net.ParseIP("192.168.1.11"))
+	if _, _ = c.ByMAC(ctx, mac, nil, ""); backend.lookups != 5 {
+		t.Fatalf("want the evicted lookup repeated, got %d backend lookups", backend.lookups)
+	}
+}
+
+func TestCachingFinderErrors(t *testing.T) {
+	backend := &countingFinder{err: ErrNotFound}
+	hf, err := NewCachingFinder(backend, time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ip := net.ParseIP("192.168.1.10")
+	for i := 0; i < 2; i++ {
+		if _, err := hf.ByIP(context.Background(), ip); err != ErrNotFound {
+			t.Fatalf("want ErrNotFound, got: %v", err)
+		}
+	}
+	if backend.lookups != 2 {
+		t.Fatalf("want failed lookups not cached, got %d backend lookups", backend.lookups)
+	}
+}
+
+func TestNewCachingFinder(t *testing.T) {
+	backend := &countingFinder{}
+	if hf, err := NewCachingFinder(backend, 0, 0); err != nil || hf != backend {
+		t.Fatalf("want the finder itself without a ttl, got: %v, %v", hf, err)
+	}
+	if _, err := NewCachingFinder(backend, -time.Second, 10); err == nil {
+		t.Fatal("want an error for a negative ttl")
+	}
+	if _, err := NewCachingFinder(backend, time.Second, 0); err == nil {
+		t.Fatal("want an error for a zero size")
+	}
+}
@@ -16,6 +16,35 @@ type HardwareFinder interface {
 	ByMAC(context.Context, net.HardwareAddr, net.IP, string) (Discoverer, error)
 }
 
+// HardwarePinger is implemented by hardware and workflow backends that depend on a
+// remote service, Ping returns an error if it can not be reached.
+type HardwarePinger interface {
+	Ping(context.Context) error
+}
+
+// HardwareCreator is implemented by hardware backends that can create a hardware
+// record from the inventory of a machine that has none.
+type HardwareCreator interface {
+	CreateFromInventory(context.Context, Inventory) (HardwareID, error)
+}
+
+// Inventory is what a machine's iPXE reports about its hardware.
+type Inventory struct {
+	Time         time.Time `json:"time"`
+	Client       string    `json:"client"`
+	MAC          string    `json:"mac"`
+	IP           string    `json:"ip,omitempty"`
+	Arch         string    `json:"arch,omitempty"`
+	Platform     string    `json:"platform,omitempty"`
+	Manufacturer string    `json:"manufacturer,omitempty"`
+	Product      string    `json:"product,omitempty"`
+	Serial       string    `json:"serial,omitempty"`
+	UUID         string    `json:"uuid,omitempty"`
+	Asset        string    `json:"asset,omitempty"`
+	MemSize      string    `json:"memsize,omitempty"`
+	NICs         []string  `json:"nics,omitempty"`
+}
+
 // WorkflowFinder looks for a Tinkerbell workflow for a given HardwareID.
 type WorkflowFinder interface {
 	HasActiveWorkflow(context.Context, HardwareID) (bool, error)
@@ -74,6 +103,8 @@ type Hardware interface {
 	HardwareID() HardwareID
 	HardwareIPs() []IP
 	Interfaces() []Port // TODO: to be updated
+	NetworkInterfaces() []NetworkInterface
+	HardwareNetbootConfigured(mac net.HardwareAddr) bool
 	HardwareManufacturer() string
 	HardwareProvisioner() string
 	HardwarePlanSlug() string
@@ -85,6 +116,9 @@ type Hardware interface {
 	OSIEBaseURL(mac net.HardwareAddr) string
 	KernelPath(mac net.HardwareAddr) string
 	InitrdPath(mac net.HardwareAddr) string
+	KernelArgs(mac net.HardwareAddr) string
+	RedirectTo(mac net.HardwareAddr) string
+	DHCPOptions(mac net.HardwareAddr) map[string]string
 	OperatingSystem() *OperatingSystem
 	GetTraceparent() string
 }
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// endpoints rotates backend requests across multiple endpoints of a backend. Each request
+// starts at the next endpoint in turn and fails over to the others, endpoints that failed
+// within the cooldown are only tried once the healthy ones have failed.
+type endpoints struct {
+	names    []string
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex
+	next int
+	// failed is the time of the last failure of each endpoint
+	failed []time.Time
+}
+
+func newEndpoints(names []string, cooldown time.Duration) (*endpoints, error) {
+	if cooldown <= 0 {
+		return nil, errors.New("backend endpoint cooldown must be greater than 0")
+	}
+	for _, name := range names {
+		metrics.BackendEndpoints.WithLabelValues(name, "success")
+		metrics.BackendEndpoints.WithLabelValues(name, "failure")
+	}
+
+	return &endpoints{names: names, cooldown: cooldown, now: time.Now, failed: make([]time.Time, len(names))}, nil
+}
+
+// order returns the indexes of the endpoints in the order a request tries them.
+func (e *endpoints) order() []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	start := e.next
+	e.next = (e.next + 1) % len(e.names)
+	now := e.now()
+	healthy := make([]int, 0, len(e.names))
+	var cooling []int
+	for n := 0; n < len(e.names); n++ {
+		i := (start + n) % len(e.names)
+		if !e.failed[i].IsZero() && now.Sub(e.failed[i]) < e.cooldown {
+			cooling = append(cooling, i)
+
+			continue
+		}
+		healthy = append(healthy, i)
+	}
+
+	return append(healthy, cooling...)
+}
+
+// do calls request with the index of each endpoint in turn, until one does not fail.
+// Returns the error of the last endpoint tried.
+func (e *endpoints) do(ctx context.Context, request func(i int) error) error {
+	var err error
+	for _, i := range e.order() {
+		err = request(i)
+		if !endpointFailed(err) {
+			metrics.BackendEndpoints.WithLabelValues(e.names[i], "success").Inc()
+
+			return err
+		}
+		metrics.BackendEndpoints.WithLabelValues(e.names[i], "failure").Inc()
+		e.mu.Lock()
+		e.failed[i] = e.now()
+		e.mu.Unlock()
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// endpointFailed reports whether err is a failure of the endpoint that answered, rather
+// than an answer, such as hardware not being found, or the request being canceled.
+func endpointFailed(err error) bool {
+	return err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, context.Canceled)
+}
+
+// NewEndpointWorkflowFinder returns a WorkflowFinder that rotates lookups across the
+// finders, each of the endpoint of the same name, failing over to the next finder when a
+// lookup fails. A finder that failed is skipped for cooldown unless all others fail.
+// Returns the finder itself if there is only one.
+func NewEndpointWorkflowFinder(names []string, finders []WorkflowFinder, cooldown time.Duration) (WorkflowFinder, error) {
+	if len(names) != len(finders) || len(finders) == 0 {
+		return nil, errors.New("backend endpoints must have a name and a finder each")
+	}
+	if len(finders) == 1 {
+		return finders[0], nil
+	}
+	e, err := newEndpoints(names, cooldown)
+	if err != nil {
+		return nil, err
+	}
+
+	return endpointWorkflowFinder{endpoints: e, finders: finders}, nil
+}
+
+type endpointWorkflowFinder struct {
+	*endpoints
+	finders []WorkflowFinder
+}
+
+func (f endpointWorkflowFinder) HasActiveWorkflow(ctx context.Context, id HardwareID) (bool, error) {
+	var active bool
+	err := f.do(ctx, func(i int) (err error) {
+		active, err = f.finders[i].HasActiveWorkflow(ctx, id)
+
+		return err
+	})
+
+	return active, err
+}
+
+// Ping returns nil if any of the finders can be reached, see HardwarePinger, as lookups
+// fail over to it. Finders that do not depend on a remote service can always be reached.
+func (f endpointWorkflowFinder) Ping(ctx context.Context) error {
+	var err error
+	for _, wf := range f.finders {
+		p, ok := wf.(HardwarePinger)
+		if !ok {
+			return nil
+		}
+		if err = p.Ping(ctx); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// endpointWorkflows is a WorkflowFinder that records its lookups and returns err.
+type endpointWorkflows struct {
+	lookups *[]string
+	name    string
+	err     error
+}
+
+func (f endpointWorkflows) HasActiveWorkflow(context.Context, HardwareID) (bool, error) {
+	*f.lookups = append(*f.lookups, f.name)
+
+	return f.err == nil, f.err
+}
+
+func TestEndpointWorkflowFinder(t *testing.T) {
+	unavailable := errors.New("connection refused")
+	var lookups []string
+	names := []string{"tink-a:42113", "tink-b:42113", "tink-c:42113"}
+	finders := []WorkflowFinder{
+		endpointWorkflows{lookups: &lookups, name: names[0]},
+		endpointWorkflows{lookups: &lookups, name: names[1], err: unavailable},
+		endpointWorkflows{lookups: &lookups, name: names[2]},
+	}
+	f, err := NewEndpointWorkflowFinder(names, finders, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.(endpointWorkflowFinder).now = func() time.Time { return now }
+	failures := testutil.ToFloat64(metrics.BackendEndpoints.WithLabelValues(names[1], "failure"))
+
+	// a, then b fails over to c, then c, then a again as b is cooling down
+	for i := 0; i < 4; i++ {
+		if active, err := f.HasActiveWorkflow(context.Background(), "hw"); err != nil || !active {
+			t.Fatalf("want active workflow, got %v, %v", active, err)
+		}
+	}
+	want := []string{"tink-a:42113", "tink-b:42113", "tink-c:42113", "tink-c:42113", "tink-a:42113"}
+	if len(lookups) != len(want) {
+		t.Fatalf("want lookups %v, got %v", want, lookups)
+	}
+	for i := range want {
+		if lookups[i] != want[i] {
+			t.Fatalf("want lookups %v, got %v", want, lookups)
+		}
+	}
+	if n := testutil.ToFloat64(metrics.BackendEndpoints.WithLabelValues(names[1], "failure")) - failures; n != 1 {
+		t.Fatalf("want 1 failure of %s, got %v", names[1], n)
+	}
+
+	// once cooled down b is tried again
+	now = now.Add(time.Minute)
+	lookups = nil
+	_, _ = f.HasActiveWorkflow(context.Background(), "hw")
+	if len(lookups) != 2 || lookups[0] != names[1] {
+		t.Fatalf("want %s tried after its cooldown, got %v", names[1], lookups)
+	}
+}
+
+func TestEndpointWorkflowFinderAllFail(t *testing.T) {
+	var lookups []string
+	names := []string{"tink-a:42113", "tink-b:42113"}
+	finders := []WorkflowFinder{
+		endpointWorkflows{lookups: &lookups, name: names[0], err: errors.New("a down")},
+		endpointWorkflows{lookups: &lookups, name: names[1], err: errors.New("b down")},
+	}
+	f, err := NewEndpointWorkflowFinder(names, finders, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.HasActiveWorkflow(context.Background(), "hw"); err == nil || err.Error() != "b down" {
+		t.Fatalf("want error of the last endpoint, got %v", err)
+	}
+	// both are cooling down, they are still tried
+	if _, err := f.HasActiveWorkflow(context.Background(), "hw"); err == nil || len(lookups) != 4 {
+		t.Fatalf("want all endpoints tried, got %v", lookups)
+	}
+}
+
+func TestEndpointWorkflowFinderNotFound(t *testing.T) {
+	var lookups []string
+	finders := []WorkflowFinder{endpointWorkflows{lookups: &lookups, name: "a", err: ErrNotFound}, endpointWorkflows{lookups: &lookups, name: "b"}}
+	f, err := NewEndpointWorkflowFinder([]string{"a", "b"}, finders, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hardware that is not found is an answer, it does not fail over
+	if _, err := f.HasActiveWorkflow(context.Background(), "hw"); !errors.Is(err, ErrNotFound) || len(lookups) != 1 {
+		t.Fatalf("want not found from a single endpoint, got %v, %v", err, lookups)
+	}
+}
+
+func TestNewEndpointFinderSingle(t *testing.T) {
+	single := endpointWorkflows{name: "tink:42113"}
+	f, err := NewEndpointWorkflowFinder([]string{"tink:42113"}, []WorkflowFinder{single}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != WorkflowFinder(single) {
+		t.Fatalf("want the finder itself, got %T", f)
+	}
+	if _, err := NewEndpointWorkflowFinder([]string{"a", "b"}, []WorkflowFinder{single, single}, 0); err == nil {
+		t.Fatal("want error for zero cooldown")
+	}
+}
+
+// pingWorkflows is a WorkflowFinder whose Ping returns err.
+type pingWorkflows struct {
+	WorkflowFinder
+	err error
+}
+
+func (f pingWorkflows) Ping(context.Context) error { return f.err }
+
+func TestEndpointWorkflowFinderPing(t *testing.T) {
+	unavailable := errors.New("connection refused")
+	tests := map[string]struct {
+		finders []WorkflowFinder
+		want    error
+	}{
+		"one reachable":  {finders: []WorkflowFinder{pingWorkflows{err: unavailable}, pingWorkflows{}}},
+		"none reachable": {finders: []WorkflowFinder{pingWorkflows{err: unavailable}, pingWorkflows{err: unavailable}}, want: unavailable},
+		"no pinger":      {finders: []WorkflowFinder{pingWorkflows{err: unavailable}, &NoOpWorkflowFinder{}}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := NewEndpointWorkflowFinder([]string{"tink-a:42113", "tink-b:42113"}, tt.finders, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f.(HardwarePinger).Ping(context.Background()); !errors.Is(err, tt.want) {
+				t.Fatalf("want %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -167,6 +167,8 @@ type DHCP struct {
 	UEFI        bool     `json:"uefi"`
 	IfaceName   string   `json:"iface_name"` // to be removed?
 	VLANID      string   `json:"vlan_id"`
+	// Options are DHCP options, by option number, merged into the replies to the interface, see dhcp.RecordOptions
+	Options map[string]string `json:"options,omitempty"`
 }
 
 // Netboot holds details for a hardware to boot over network.
@@ -178,6 +180,8 @@ type Netboot struct {
 		Contents string `json:"contents"`
 	} `json:"ipxe"`
 	OSIE OSIE `json:"osie"`
+	// RedirectTo is the URL of an iPXE script on another boot server the auto script chains to instead of booting locally
+	RedirectTo string `json:"redirect_to,omitempty"`
 }
 
 // Bootstrapper is the bootstrapper to be used during netboot.
@@ -185,6 +189,8 @@ type OSIE struct {
 	BaseURL string `json:"base_url"`
 	Kernel  string `json:"kernel"`
 	Initrd  string `json:"initrd"`
+	// KernelArgs are appended to the installer's kernel args, overriding args with the same key
+	KernelArgs string `json:"kernel_args,omitempty"`
 }
 
 // Network holds hardware network details.
@@ -8,6 +8,7 @@ import (
 	"github.com/tinkerbell/tink/pkg/controllers"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/cluster"
@@ -19,6 +20,24 @@ const (
 	HardwareIPAddrIndex                 = ".spec.interfaces.dhcp.ip"
 )
 
+// Option configures the Kubernetes rest client.
+type Option func(*rest.Config)
+
+// WithRateLimit sets the client side rate limit of requests to the Kubernetes API to
+// qps, allowing bursts of burst requests. Zero values keep the client-go defaults.
+// Hardware and workflow lookups are served from the cluster's informer cache, so the
+// limit mostly applies to the initial list and watch requests.
+func WithRateLimit(qps float32, burst int) Option {
+	return func(cfg *rest.Config) {
+		if qps > 0 {
+			cfg.QPS = qps
+		}
+		if burst > 0 {
+			cfg.Burst = burst
+		}
+	}
+}
+
 // NewCluster returns a controller-runtime cluster.Cluster with the Tinkerbell runtime
 // scheme registered, and indexers for:
 // * Hardware by MAC address
@@ -26,7 +45,7 @@ const (
 // * Workflows by worker address
 //
 // Callers must instantiate the client-side cache by calling Start() before use.
-func NewCluster(config clientcmd.ClientConfig) (cluster.Cluster, error) {
+func NewCluster(config clientcmd.ClientConfig, opts ...Option) (cluster.Cluster, error) {
 	runtimescheme := runtime.NewScheme()
 
 	err := clientgoscheme.AddToScheme(runtimescheme)
@@ -48,6 +67,9 @@ func NewCluster(config clientcmd.ClientConfig) (cluster.Cluster, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get client config: %v", err)
 	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
 
 	c, err := cluster.New(cfg, func(o *cluster.Options) {
 		o.Scheme = runtimescheme
@@ -0,0 +1,29 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	tests := map[string]struct {
+		qps       float32
+		burst     int
+		wantQPS   float32
+		wantBurst int
+	}{
+		"defaults kept": {wantQPS: 5, wantBurst: 10},
+		"qps only":      {qps: 50, wantQPS: 50, wantBurst: 10},
+		"both":          {qps: 200, burst: 400, wantQPS: 200, wantBurst: 400},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &rest.Config{QPS: 5, Burst: 10}
+			WithRateLimit(tt.qps, tt.burst)(cfg)
+			if cfg.QPS != tt.wantQPS || cfg.Burst != tt.wantBurst {
+				t.Fatalf("want qps: %v, burst: %d, got qps: %v, burst: %d", tt.wantQPS, tt.wantBurst, cfg.QPS, cfg.Burst)
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@ package kubernetes
 
 import (
 	"context"
+	"encoding/base64"
 	"net"
+	"strings"
 
 	"github.com/packethost/pkg/log"
 	"github.com/pkg/errors"
@@ -18,47 +20,98 @@ import (
 
 // Finder is a type that looks up hardware and workflows from Kubernetes.
 type Finder struct {
-	clientFunc   func() crclient.Client
+	clientFunc func() crclient.Client
+	// apiReader reads from the API server instead of the client-side cache
+	apiReader    func() crclient.Reader
 	cacheStarter func(context.Context) error
 	logger       log.Logger
+	// namespaces scopes hardware lookups to namespaces, nil considers them all
+	namespaces *NamespacePolicy
 }
 
 // NewFinder returns a HardwareFinder that discovers hardware from Kubernetes.
 //
 // Callers must instantiate the client-side cache by calling Start() before use.
-func NewFinder(logger log.Logger, k8sAPI, kubeconfig, kubeNamespace string) (*Finder, error) {
+func NewFinder(logger log.Logger, k8sAPI string, kubeconfig Kubeconfig, kubeNamespace string, opts ...Option) (*Finder, error) {
+	config, err := clientConfig(k8sAPI, kubeconfig, kubeNamespace)
+	if err != nil {
+		return nil, err
+	}
 	// TODO(moadqassem): Maybe use the tinkerbell kubeclient instead of using this cluster client similar to hegel.
-	ccfg := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{
-			ExplicitPath: kubeconfig,
-		},
-		&clientcmd.ConfigOverrides{
-			ClusterInfo: clientcmdapi.Cluster{
-				Server: k8sAPI,
-			},
-			Context: clientcmdapi.Context{
-				Namespace: kubeNamespace,
-			},
-		},
-	)
-
-	cluster, err := NewCluster(ccfg)
+	cluster, err := NewCluster(config, opts...)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
 	return &Finder{
 		clientFunc:   cluster.GetClient,
+		apiReader:    cluster.GetAPIReader,
 		cacheStarter: cluster.Start,
 		logger:       logger,
 	}, nil
 }
 
+// Kubeconfig is where the Kubernetes client config is read from: Content, the config
+// itself as YAML or base64 encoded YAML, if it is set, otherwise the file at Path. Both
+// empty uses the in-cluster config.
+type Kubeconfig struct {
+	Path    string
+	Content string
+}
+
+// clientConfig returns the client config from kubeconfig with the API URL and namespace
+// overrides, the API URL takes precedence over the server of the config.
+func clientConfig(k8sAPI string, kubeconfig Kubeconfig, kubeNamespace string) (clientcmd.ClientConfig, error) {
+	overrides := &clientcmd.ConfigOverrides{
+		ClusterInfo: clientcmdapi.Cluster{
+			Server: k8sAPI,
+		},
+		Context: clientcmdapi.Context{
+			Namespace: kubeNamespace,
+		},
+	}
+	if kubeconfig.Content == "" {
+		return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{
+				ExplicitPath: kubeconfig.Path,
+			},
+			overrides,
+		), nil
+	}
+
+	content := []byte(kubeconfig.Content)
+	// a kubeconfig is never valid base64, it has at least a colon
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(kubeconfig.Content)); err == nil {
+		content = decoded
+	}
+	config, err := clientcmd.Load(content)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid -kubeconfig-content")
+	}
+	cc := clientcmd.NewNonInteractiveClientConfig(*config, config.CurrentContext, overrides, nil)
+	// the overrides are applied before the config is validated, so a config without a server can be used with the API URL
+	if _, err := cc.ClientConfig(); err != nil {
+		return nil, errors.Wrap(err, "invalid -kubeconfig-content")
+	}
+
+	return cc, nil
+}
+
 // Start instantiates the client-side cache.
 func (f *Finder) Start(ctx context.Context) error {
 	return f.cacheStarter(ctx)
 }
 
+// Ping lists a single Hardware from the API server, bypassing the client-side cache,
+// to check the API server can be reached.
+func (f *Finder) Ping(ctx context.Context) error {
+	if err := f.apiReader().List(ctx, &v1alpha1.HardwareList{}, crclient.Limit(1)); err != nil {
+		return errors.Wrap(err, "failed listing hardware")
+	}
+
+	return nil
+}
+
 // ByIP returns a Discoverer for a particular IP.
 func (f *Finder) ByIP(ctx context.Context, ip net.IP) (client.Discoverer, error) {
 	hardwareList := &v1alpha1.HardwareList{}
@@ -70,15 +123,12 @@ func (f *Finder) ByIP(ctx context.Context, ip net.IP) (client.Discoverer, error)
 		return nil, errors.Wrap(err, "failed listing hardware")
 	}
 
-	if len(hardwareList.Items) == 0 {
-		return nil, errors.New("no hardware found")
-	}
-
-	if len(hardwareList.Items) > 1 {
-		return nil, errors.Errorf("got %d hardware for ip %s, expected only 1", len(hardwareList.Items), ip)
+	hw, err := f.resolve(items(hardwareList), "ip", ip.String())
+	if err != nil {
+		return nil, err
 	}
 
-	return NewK8sDiscoverer(&hardwareList.Items[0]), nil
+	return NewK8sDiscoverer(hw), nil
 }
 
 // ByMAC returns a Discoverer for a particular MAC address.
@@ -92,15 +142,33 @@ func (f *Finder) ByMAC(ctx context.Context, mac net.HardwareAddr, _ net.IP, _ st
 		return nil, errors.Wrap(err, "failed listing hardware")
 	}
 
-	if len(hardwareList.Items) == 0 {
-		return nil, errors.New("no hardware found")
+	hw, err := f.resolve(items(hardwareList), "mac", mac.String())
+	if err != nil {
+		return nil, err
 	}
 
-	if len(hardwareList.Items) > 1 {
-		return nil, errors.Errorf("got %d hardware for mac %s, expected only 1", len(hardwareList.Items), mac)
+	return NewK8sDiscoverer(hw), nil
+}
+
+// resolve returns the single Hardware of matches, the Hardware with field value,
+// allowed by the namespace policy, logging lookups that are ambiguous.
+func (f *Finder) resolve(matches []*v1alpha1.Hardware, field, value string) (*v1alpha1.Hardware, error) {
+	hw, err := f.namespaces.resolve(matches, field, value)
+	if err != nil && len(matches) > 1 {
+		f.logger.With(field, value).Error(errors.WithMessage(err, "ambiguous hardware lookup"))
+	}
+
+	return hw, err
+}
+
+// items returns pointers to the Hardware of list.
+func items(list *v1alpha1.HardwareList) []*v1alpha1.Hardware {
+	matches := make([]*v1alpha1.Hardware, len(list.Items))
+	for i := range list.Items {
+		matches[i] = &list.Items[i]
 	}
 
-	return NewK8sDiscoverer(&hardwareList.Items[0]), nil
+	return matches
 }
 
 // HasActiveWorkflow finds if an active workflow exists for a particular hardware ID.
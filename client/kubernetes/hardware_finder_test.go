@@ -0,0 +1,81 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://10.0.0.1:6443
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+    namespace: tink
+current-context: test
+users:
+- name: test
+  user:
+    token: secret
+`
+
+func TestClientConfig(t *testing.T) {
+	tests := map[string]struct {
+		k8sAPI        string
+		content       string
+		namespace     string
+		wantErr       bool
+		wantHost      string
+		wantNamespace string
+	}{
+		"yaml":             {content: testKubeconfig, wantHost: "https://10.0.0.1:6443", wantNamespace: "tink"},
+		"base64":           {content: base64.StdEncoding.EncodeToString([]byte(testKubeconfig)) + "\n", wantHost: "https://10.0.0.1:6443", wantNamespace: "tink"},
+		"api url override": {k8sAPI: "https://10.0.0.2:6443", content: testKubeconfig, wantHost: "https://10.0.0.2:6443", wantNamespace: "tink"},
+		"namespace":        {content: testKubeconfig, namespace: "other", wantHost: "https://10.0.0.1:6443", wantNamespace: "other"},
+		"invalid yaml":     {content: "clusters: [", wantErr: true},
+		"invalid base64":   {content: base64.StdEncoding.EncodeToString([]byte("clusters: [")), wantErr: true},
+		"no cluster":       {content: "apiVersion: v1\nkind: Config\n", wantErr: true},
+		"no cluster, api":  {k8sAPI: "https://10.0.0.2:6443", content: "apiVersion: v1\nkind: Config\n", wantHost: "https://10.0.0.2:6443", wantNamespace: "default"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cc, err := clientConfig(tt.k8sAPI, Kubeconfig{Content: tt.content}, tt.namespace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %t, got: %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			cfg, err := cc.ClientConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Host != tt.wantHost {
+				t.Fatalf("want host %q, got %q", tt.wantHost, cfg.Host)
+			}
+			if ns, _, _ := cc.Namespace(); ns != tt.wantNamespace {
+				t.Fatalf("want namespace %q, got %q", tt.wantNamespace, ns)
+			}
+		})
+	}
+}
+
+func TestClientConfigPath(t *testing.T) {
+	// the file is only read once the config is used, with the API URL it needs no file
+	cc, err := clientConfig("https://10.0.0.2:6443", Kubeconfig{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := cc.ClientConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "https://10.0.0.2:6443" {
+		t.Fatalf("want host https://10.0.0.2:6443, got %q", cfg.Host)
+	}
+}
@@ -0,0 +1,258 @@
+package kubernetes
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/packethost/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// hardwareIndex is an in-memory index of Hardware by MAC, IP and hardware ID, kept
+// current by the Hardware informer's events.
+type hardwareIndex struct {
+	mu sync.RWMutex
+	// objects are the indexed Hardware by namespace/name
+	objects map[string]*v1alpha1.Hardware
+	// byMAC, byIP and byID map a value to the namespace/names of the Hardware with it
+	byMAC map[string]map[string]bool
+	byIP  map[string]map[string]bool
+	byID  map[string]map[string]bool
+	// lastEvent is when the last informer event was indexed
+	lastEvent time.Time
+}
+
+func newHardwareIndex() *hardwareIndex {
+	return &hardwareIndex{
+		objects: map[string]*v1alpha1.Hardware{},
+		byMAC:   map[string]map[string]bool{},
+		byIP:    map[string]map[string]bool{},
+		byID:    map[string]map[string]bool{},
+	}
+}
+
+// hardwareKeys returns the normalized MACs, IPs and ID hw is indexed by.
+func hardwareKeys(hw *v1alpha1.Hardware) (macs, ips []string, id string) {
+	for _, iface := range hw.Spec.Interfaces {
+		if iface.DHCP == nil {
+			continue
+		}
+		if mac, err := net.ParseMAC(iface.DHCP.MAC); err == nil {
+			macs = append(macs, mac.String())
+		}
+		if iface.DHCP.IP != nil {
+			if ip := net.ParseIP(iface.DHCP.IP.Address); ip != nil {
+				ips = append(ips, ip.String())
+			}
+		}
+	}
+
+	return macs, ips, strings.ToLower(NewK8sDiscoverer(hw).Hardware().HardwareID().String())
+}
+
+func objectKey(hw *v1alpha1.Hardware) string {
+	return hw.Namespace + "/" + hw.Name
+}
+
+func addKey(index map[string]map[string]bool, value, key string) {
+	if value == "" {
+		return
+	}
+	if index[value] == nil {
+		index[value] = map[string]bool{}
+	}
+	index[value][key] = true
+}
+
+func removeKey(index map[string]map[string]bool, value, key string) {
+	delete(index[value], key)
+	if len(index[value]) == 0 {
+		delete(index, value)
+	}
+}
+
+// set indexes hw, replacing any previous version of it.
+func (x *hardwareIndex) set(hw *v1alpha1.Hardware) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	key := objectKey(hw)
+	x.removeLocked(key)
+	x.lastEvent = time.Now()
+	x.objects[key] = hw
+	macs, ips, id := hardwareKeys(hw)
+	for _, mac := range macs {
+		addKey(x.byMAC, mac, key)
+	}
+	for _, ip := range ips {
+		addKey(x.byIP, ip, key)
+	}
+	addKey(x.byID, id, key)
+}
+
+// remove drops the Hardware with namespace/name key from the index.
+func (x *hardwareIndex) remove(key string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.removeLocked(key)
+	x.lastEvent = time.Now()
+}
+
+// status returns the number of indexed Hardware and when the last event was indexed.
+func (x *hardwareIndex) status() (int, time.Time) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	return len(x.objects), x.lastEvent
+}
+
+func (x *hardwareIndex) removeLocked(key string) {
+	hw, ok := x.objects[key]
+	if !ok {
+		return
+	}
+	delete(x.objects, key)
+	macs, ips, id := hardwareKeys(hw)
+	for _, mac := range macs {
+		removeKey(x.byMAC, mac, key)
+	}
+	for _, ip := range ips {
+		removeKey(x.byIP, ip, key)
+	}
+	removeKey(x.byID, id, key)
+}
+
+// lookup returns the Hardware indexed by value.
+func (x *hardwareIndex) lookup(index map[string]map[string]bool, value string) []*v1alpha1.Hardware {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	matches := make([]*v1alpha1.Hardware, 0, len(index[value]))
+	for key := range index[value] {
+		matches = append(matches, x.objects[key])
+	}
+	sort.Slice(matches, func(i, j int) bool { return objectKey(matches[i]) < objectKey(matches[j]) })
+
+	return matches
+}
+
+// OnAdd implements toolscache.ResourceEventHandler.
+func (x *hardwareIndex) OnAdd(obj interface{}) {
+	if hw, ok := obj.(*v1alpha1.Hardware); ok {
+		x.set(hw)
+	}
+}
+
+// OnUpdate implements toolscache.ResourceEventHandler.
+func (x *hardwareIndex) OnUpdate(_, obj interface{}) {
+	x.OnAdd(obj)
+}
+
+// OnDelete implements toolscache.ResourceEventHandler, including for deletes whose
+// final state is unknown because the watch was disconnected.
+func (x *hardwareIndex) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		x.remove(tombstone.Key)
+
+		return
+	}
+	if hw, ok := obj.(*v1alpha1.Hardware); ok {
+		x.remove(objectKey(hw))
+	}
+}
+
+// IndexedFinder is a Finder that answers hardware lookups from an in-memory index
+// of Hardware by MAC, IP and hardware ID, kept current by a Hardware informer, so
+// lookups make no API requests and keep working through API server outages once
+// the informer has synced.
+type IndexedFinder struct {
+	*Finder
+	index  *hardwareIndex
+	synced func() bool
+}
+
+// NewIndexedFinder returns a HardwareFinder that discovers hardware from an index of
+// the Hardware in Kubernetes.
+//
+// Callers must instantiate the client-side cache by calling Start() before use.
+func NewIndexedFinder(logger log.Logger, k8sAPI string, kubeconfig Kubeconfig, kubeNamespace string, opts ...Option) (*IndexedFinder, error) {
+	config, err := clientConfig(k8sAPI, kubeconfig, kubeNamespace)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := NewCluster(config, opts...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	informer, err := cluster.GetCache().GetInformer(context.Background(), &v1alpha1.Hardware{})
+	if err != nil {
+		return nil, errors.Wrap(err, "hardware informer")
+	}
+	index := newHardwareIndex()
+	informer.AddEventHandler(index)
+
+	return &IndexedFinder{
+		Finder: &Finder{
+			clientFunc:   cluster.GetClient,
+			apiReader:    cluster.GetAPIReader,
+			cacheStarter: cluster.Start,
+			logger:       logger,
+		},
+		index:  index,
+		synced: informer.HasSynced,
+	}, nil
+}
+
+// Synced reports whether the informer has synced the index with the Hardware in Kubernetes.
+func (f *IndexedFinder) Synced() bool {
+	return f.synced()
+}
+
+// Size returns the number of Hardware in the index.
+func (f *IndexedFinder) Size() int {
+	n, _ := f.index.status()
+
+	return n
+}
+
+// LastEvent returns when the last informer event was indexed, zero if there has been none.
+func (f *IndexedFinder) LastEvent() time.Time {
+	_, t := f.index.status()
+
+	return t
+}
+
+// ByIP returns a Discoverer for a particular IP.
+func (f *IndexedFinder) ByIP(_ context.Context, ip net.IP) (client.Discoverer, error) {
+	hw, err := f.resolve(f.index.lookup(f.index.byIP, ip.String()), "ip", ip.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewK8sDiscoverer(hw), nil
+}
+
+// ByMAC returns a Discoverer for a particular MAC address.
+func (f *IndexedFinder) ByMAC(_ context.Context, mac net.HardwareAddr, _ net.IP, _ string) (client.Discoverer, error) {
+	hw, err := f.resolve(f.index.lookup(f.index.byMAC, mac.String()), "mac", mac.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewK8sDiscoverer(hw), nil
+}
+
+// ByID returns a Discoverer for a particular hardware ID.
+func (f *IndexedFinder) ByID(_ context.Context, id client.HardwareID) (client.Discoverer, error) {
+	hw, err := f.resolve(f.index.lookup(f.index.byID, strings.ToLower(id.String())), "id", id.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewK8sDiscoverer(hw), nil
+}
@@ -0,0 +1,76 @@
+package kubernetes
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/packethost/pkg/log"
+	"github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+func testHardware(name, id, mac, ip string) *v1alpha1.Hardware {
+	return &v1alpha1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: v1alpha1.HardwareSpec{
+			Metadata: &v1alpha1.HardwareMetadata{Instance: &v1alpha1.MetadataInstance{ID: id}},
+			Interfaces: []v1alpha1.Interface{{
+				DHCP: &v1alpha1.DHCP{MAC: mac, IP: &v1alpha1.IP{Address: ip}},
+			}},
+		},
+	}
+}
+
+func TestIndexedFinder(t *testing.T) {
+	index := newHardwareIndex()
+	f := &IndexedFinder{Finder: &Finder{logger: log.Test(t, "kubernetes")}, index: index, synced: func() bool { return true }}
+	ctx := context.Background()
+	mac, _ := net.ParseMAC("00:00:ba:dd:be:ef")
+
+	index.OnAdd(testHardware("a", "id-a", "00:00:BA:DD:BE:EF", "10.0.0.1"))
+	d, err := f.ByMAC(ctx, mac, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Hardware().HardwareID(); got != "id-a" {
+		t.Fatalf("want id-a by mac, got %q", got)
+	}
+	if _, err := f.ByIP(ctx, net.ParseIP("10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.ByID(ctx, "ID-A"); err != nil {
+		t.Fatal(err)
+	}
+
+	// an update moving the hardware to a new IP drops the old one
+	index.OnUpdate(nil, testHardware("a", "id-a", "00:00:ba:dd:be:ef", "10.0.0.2"))
+	if _, err := f.ByIP(ctx, net.ParseIP("10.0.0.1")); err == nil {
+		t.Fatal("want error for the old ip after update")
+	}
+	if _, err := f.ByIP(ctx, net.ParseIP("10.0.0.2")); err != nil {
+		t.Fatal(err)
+	}
+
+	// two hardware with the same MAC are ambiguous
+	index.OnAdd(testHardware("b", "id-b", "00:00:ba:dd:be:ef", "10.0.0.3"))
+	if _, err := f.ByMAC(ctx, mac, nil, ""); err == nil {
+		t.Fatal("want error for a mac on two hardware")
+	}
+
+	index.OnDelete(testHardware("b", "id-b", "00:00:ba:dd:be:ef", "10.0.0.3"))
+	if _, err := f.ByMAC(ctx, mac, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	index.OnDelete(toolscache.DeletedFinalStateUnknown{Key: "default/a"})
+	if _, err := f.ByMAC(ctx, mac, nil, ""); err == nil {
+		t.Fatal("want error after tombstone delete")
+	}
+	if n, last := index.status(); n != 0 || last.IsZero() {
+		t.Fatalf("want an empty index with a last event, got %d, %v", n, last)
+	}
+	if len(index.objects) != 0 || len(index.byMAC) != 0 || len(index.byIP) != 0 || len(index.byID) != 0 {
+		t.Fatalf("want empty index, got %+v", index)
+	}
+}
@@ -183,7 +183,7 @@ func (d *K8sDiscoverer) HardwareAllowWorkflow(mac net.HardwareAddr) bool {
 
 func (d *K8sDiscoverer) HardwareAllowPXE(mac net.HardwareAddr) bool {
 	for _, iface := range d.hw.Spec.Interfaces {
-		if iface.Netboot != nil && iface.DHCP != nil && mac.String() == iface.DHCP.MAC {
+		if iface.Netboot != nil && iface.Netboot.AllowPXE != nil && iface.DHCP != nil && mac.String() == iface.DHCP.MAC {
 			return *iface.Netboot.AllowPXE
 		}
 	}
@@ -191,6 +191,17 @@ func (d *K8sDiscoverer) HardwareAllowPXE(mac net.HardwareAddr) bool {
 	return false
 }
 
+// HardwareNetbootConfigured reports whether the interface with the given mac has netboot settings.
+func (d *K8sDiscoverer) HardwareNetbootConfigured(mac net.HardwareAddr) bool {
+	for _, iface := range d.hw.Spec.Interfaces {
+		if iface.Netboot != nil && iface.Netboot.AllowPXE != nil && iface.DHCP != nil && mac.String() == iface.DHCP.MAC {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (d *K8sDiscoverer) HardwareArch(net.HardwareAddr) string {
 	for _, iface := range d.hw.Spec.Interfaces {
 		if iface.DHCP != nil {
@@ -238,6 +249,57 @@ func (d *K8sDiscoverer) HardwareIPs() []client.IP {
 
 func (d *K8sDiscoverer) Interfaces() []client.Port { return nil }
 
+// NetworkInterfaces returns the DHCP and netboot configuration of every interface
+// in the hardware spec.
+func (d *K8sDiscoverer) NetworkInterfaces() []client.NetworkInterface {
+	resp := []client.NetworkInterface{}
+	for _, iface := range d.hw.Spec.Interfaces {
+		ni := client.NetworkInterface{}
+		if iface.DHCP != nil {
+			ni.DHCP = client.DHCP{
+				Hostname:    iface.DHCP.Hostname,
+				LeaseTime:   int(iface.DHCP.LeaseTime),
+				NameServers: iface.DHCP.NameServers,
+				TimeServers: iface.DHCP.TimeServers,
+				Arch:        iface.DHCP.Arch,
+				UEFI:        iface.DHCP.UEFI,
+				IfaceName:   iface.DHCP.IfaceName,
+				VLANID:      iface.DHCP.VLANID,
+			}
+			mac := &client.MACAddr{}
+			if err := mac.UnmarshalText([]byte(iface.DHCP.MAC)); err == nil {
+				ni.DHCP.MAC = mac
+			}
+			if iface.DHCP.IP != nil {
+				ni.DHCP.IP = client.IP{
+					Address: net.ParseIP(iface.DHCP.IP.Address),
+					Netmask: net.ParseIP(iface.DHCP.IP.Netmask),
+					Gateway: net.ParseIP(iface.DHCP.IP.Gateway),
+					Family:  int(iface.DHCP.IP.Family),
+				}
+			}
+		}
+		if iface.Netboot != nil {
+			if iface.Netboot.AllowPXE != nil {
+				ni.Netboot.AllowPXE = *iface.Netboot.AllowPXE
+			}
+			if iface.Netboot.AllowWorkflow != nil {
+				ni.Netboot.AllowWorkflow = *iface.Netboot.AllowWorkflow
+			}
+			if iface.Netboot.OSIE != nil {
+				ni.Netboot.OSIE = client.OSIE{
+					BaseURL: iface.Netboot.OSIE.BaseURL,
+					Kernel:  iface.Netboot.OSIE.Kernel,
+					Initrd:  iface.Netboot.OSIE.Initrd,
+				}
+			}
+		}
+		resp = append(resp, ni)
+	}
+
+	return resp
+}
+
 func (d *K8sDiscoverer) HardwareManufacturer() string {
 	if d.hw.Spec.Metadata != nil && d.hw.Spec.Metadata.Manufacturer != nil {
 		return d.hw.Spec.Metadata.Manufacturer.ID
@@ -318,6 +380,21 @@ func (d *K8sDiscoverer) InitrdPath(net.HardwareAddr) string {
 	return ""
 }
 
+// KernelArgs returns no args, the Hardware resource has no field for them.
+func (d *K8sDiscoverer) KernelArgs(net.HardwareAddr) string {
+	return ""
+}
+
+// RedirectTo returns no redirect, the Hardware resource has no field for it.
+func (d *K8sDiscoverer) RedirectTo(net.HardwareAddr) string {
+	return ""
+}
+
+// DHCPOptions returns no options, the Hardware resource has no field for them.
+func (d *K8sDiscoverer) DHCPOptions(net.HardwareAddr) map[string]string {
+	return nil
+}
+
 func (d *K8sDiscoverer) OperatingSystem() *client.OperatingSystem {
 	if d.hw.Spec.Metadata != nil && d.hw.Spec.Metadata.Instance != nil && d.hw.Spec.Metadata.Instance.OperatingSystem != nil {
 		return &client.OperatingSystem{
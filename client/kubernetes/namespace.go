@@ -0,0 +1,97 @@
+package kubernetes
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
+)
+
+// NamespacePolicy scopes hardware lookups to namespaces and resolves lookups that
+// match Hardware in more than one namespace. A nil NamespacePolicy considers every
+// namespace and denies all ambiguous lookups.
+type NamespacePolicy struct {
+	// allowed are the namespaces Hardware is considered in, all if empty
+	allowed map[string]bool
+	// precedence are the namespaces preferred when a lookup matches Hardware in several, most preferred first
+	precedence []string
+}
+
+// NewNamespacePolicy returns a policy that only considers Hardware in the allowed
+// namespaces, if any, and resolves a lookup matching Hardware in several namespaces
+// to the one in the earliest namespace of precedence. Ambiguous lookups the
+// precedence does not resolve are denied. Returns nil if both are empty.
+func NewNamespacePolicy(allowed, precedence []string) (*NamespacePolicy, error) {
+	p := &NamespacePolicy{allowed: map[string]bool{}}
+	for _, ns := range allowed {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			p.allowed[ns] = true
+		}
+	}
+	for _, ns := range precedence {
+		ns = strings.TrimSpace(ns)
+		if ns == "" {
+			continue
+		}
+		if len(p.allowed) > 0 && !p.allowed[ns] {
+			return nil, errors.Errorf("preferred namespace %q is not an allowed namespace", ns)
+		}
+		p.precedence = append(p.precedence, ns)
+	}
+	if len(p.allowed) == 0 && len(p.precedence) == 0 {
+		return nil, nil
+	}
+
+	return p, nil
+}
+
+// resolve returns the single Hardware of matches, the Hardware with field value, after
+// scoping them to the allowed namespaces and applying the namespace precedence.
+func (p *NamespacePolicy) resolve(matches []*v1alpha1.Hardware, field, value string) (*v1alpha1.Hardware, error) {
+	if p != nil && len(p.allowed) > 0 {
+		var scoped []*v1alpha1.Hardware
+		for _, hw := range matches {
+			if p.allowed[hw.Namespace] {
+				scoped = append(scoped, hw)
+			}
+		}
+		matches = scoped
+	}
+	switch len(matches) {
+	case 0:
+		return nil, errors.New("no hardware found")
+	case 1:
+		return matches[0], nil
+	}
+
+	if p != nil {
+		for _, ns := range p.precedence {
+			var preferred []*v1alpha1.Hardware
+			for _, hw := range matches {
+				if hw.Namespace == ns {
+					preferred = append(preferred, hw)
+				}
+			}
+			if len(preferred) == 1 {
+				return preferred[0], nil
+			}
+			if len(preferred) > 1 {
+				matches = preferred
+
+				break
+			}
+		}
+	}
+
+	names := make([]string, len(matches))
+	for i, hw := range matches {
+		names[i] = objectKey(hw)
+	}
+
+	return nil, errors.Errorf("got %d hardware for %s %s, expected only 1: %s", len(matches), field, value, strings.Join(names, ", "))
+}
+
+// SetNamespacePolicy sets the policy scoping f's hardware lookups to namespaces.
+func (f *Finder) SetNamespacePolicy(p *NamespacePolicy) {
+	f.namespaces = p
+}
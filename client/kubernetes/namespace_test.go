@@ -0,0 +1,59 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
+)
+
+func TestNamespacePolicy(t *testing.T) {
+	prod := testHardware("m1", "id-prod", "00:00:ba:dd:be:ef", "10.0.0.1")
+	prod.Namespace = "prod"
+	test := testHardware("m1", "id-test", "00:00:ba:dd:be:ef", "10.0.0.1")
+	test.Namespace = "test"
+	prod2 := testHardware("m2", "id-prod2", "00:00:ba:dd:be:ef", "10.0.0.1")
+	prod2.Namespace = "prod"
+
+	tests := map[string]struct {
+		allowed    []string
+		precedence []string
+		matches    []*v1alpha1.Hardware
+		want       string
+		wantErr    string
+	}{
+		"no policy single":       {matches: []*v1alpha1.Hardware{prod}, want: "id-prod"},
+		"no policy ambiguous":    {matches: []*v1alpha1.Hardware{prod, test}, wantErr: "prod/m1, test/m1"},
+		"restricted":             {allowed: []string{"prod"}, matches: []*v1alpha1.Hardware{prod, test}, want: "id-prod"},
+		"restricted away":        {allowed: []string{"prod"}, matches: []*v1alpha1.Hardware{test}, wantErr: "no hardware found"},
+		"preferred":              {precedence: []string{"prod", "test"}, matches: []*v1alpha1.Hardware{test, prod}, want: "id-prod"},
+		"preference not matched": {precedence: []string{"staging"}, matches: []*v1alpha1.Hardware{test, prod}, wantErr: "expected only 1"},
+		"duplicate in preferred": {precedence: []string{"prod"}, matches: []*v1alpha1.Hardware{test, prod, prod2}, wantErr: "prod/m1, prod/m2"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewNamespacePolicy(tt.allowed, tt.precedence)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hw, err := p.resolve(tt.matches, "mac", "00:00:ba:dd:be:ef")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("want error containing %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hw.Spec.Metadata.Instance.ID; got != tt.want {
+				t.Fatalf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := NewNamespacePolicy([]string{"prod"}, []string{"test"}); err == nil {
+		t.Fatal("want error for a preferred namespace that is not allowed")
+	}
+}
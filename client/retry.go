@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// initialRetryInterval is the wait before the first retry, it doubles for each retry after that.
+const initialRetryInterval = 100 * time.Millisecond
+
+// Backoff retries backend lookups that failed for a transient reason, see
+// IsTransient, with an exponentially growing wait between them. A nil Backoff
+// does not retry.
+type Backoff struct {
+	retries     int
+	maxInterval time.Duration
+}
+
+// NewBackoff returns a Backoff retrying a lookup up to retries times, waiting at most
+// maxInterval between retries. Returns nil if retries is 0.
+func NewBackoff(retries int, maxInterval time.Duration) (*Backoff, error) {
+	if retries < 0 {
+		return nil, errors.New("backend retries must not be negative")
+	}
+	if retries == 0 {
+		return nil, nil
+	}
+	if maxInterval <= 0 {
+		return nil, errors.New("backend retry max interval must be greater than 0")
+	}
+
+	return &Backoff{retries: retries, maxInterval: maxInterval}, nil
+}
+
+// Do calls lookup until it succeeds, fails for a reason that is not transient, the
+// retries are used up or ctx is done. Returns the last error of lookup.
+func (b *Backoff) Do(ctx context.Context, name string, lookup func() error) error {
+	err := lookup()
+	if b == nil {
+		return err
+	}
+	interval := initialRetryInterval
+	for retry := 0; retry < b.retries && err != nil && IsTransient(err); retry++ {
+		if interval > b.maxInterval {
+			interval = b.maxInterval
+		}
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+
+			return err
+		case <-t.C:
+		}
+		metrics.BackendRetries.WithLabelValues(name).Inc()
+		err = lookup()
+		interval *= 2
+	}
+
+	return err
+}
+
+// IsTransient reports whether err is a failure to reach the backend, or the backend
+// failing, that may not happen again, i.e. a network error, a timeout or a server
+// error, of the Kubernetes API or of a gRPC backend. Hardware that is not found is
+// never transient.
+func IsTransient(err error) bool {
+	var netErr net.Error
+	switch {
+	case err == nil, errors.Is(err, ErrNotFound), errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return true
+	}
+	if status := apierrors.APIStatus(nil); errors.As(err, &status) {
+		return apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) || status.Status().Code >= 500
+	}
+	// status.Code does not unwrap, the gRPC status may be wrapped with context
+	var grpcErr interface{ GRPCStatus() *grpcstatus.Status }
+	if errors.As(err, &grpcErr) {
+		switch grpcErr.GRPCStatus().Code() {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+			return true
+		}
+	}
+
+	return false
+}
+
+// RetryHardwareFinder returns f with its lookups retried by b, or f itself if b is nil.
+func RetryHardwareFinder(f HardwareFinder, b *Backoff) HardwareFinder {
+	if b == nil {
+		return f
+	}
+
+	return retryingHardwareFinder{finder: f, backoff: b}
+}
+
+type retryingHardwareFinder struct {
+	finder  HardwareFinder
+	backoff *Backoff
+}
+
+func (r retryingHardwareFinder) ByIP(ctx context.Context, ip net.IP) (Discoverer, error) {
+	var d Discoverer
+	err := r.backoff.Do(ctx, "ip", func() (err error) {
+		d, err = r.finder.ByIP(ctx, ip)
+
+		return err
+	})
+
+	return d, err
+}
+
+func (r retryingHardwareFinder) ByMAC(ctx context.Context, mac net.HardwareAddr, giaddr net.IP, circuitID string) (Discoverer, error) {
+	var d Discoverer
+	err := r.backoff.Do(ctx, "mac", func() (err error) {
+		d, err = r.finder.ByMAC(ctx, mac, giaddr, circuitID)
+
+		return err
+	})
+
+	return d, err
+}
+
+// RetryWorkflowFinder returns f with its lookups retried by b, or f itself if b is nil.
+func RetryWorkflowFinder(f WorkflowFinder, b *Backoff) WorkflowFinder {
+	if b == nil {
+		return f
+	}
+
+	return retryingWorkflowFinder{finder: f, backoff: b}
+}
+
+type retryingWorkflowFinder struct {
+	finder  WorkflowFinder
+	backoff *Backoff
+}
+
+func (r retryingWorkflowFinder) HasActiveWorkflow(ctx context.Context, id HardwareID) (bool, error) {
+	var active bool
+	err := r.backoff.Do(ctx, "workflow", func() (err error) {
+		active, err = r.finder.HasActiveWorkflow(ctx, id)
+
+		return err
+	})
+
+	return active, err
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIsTransient(t *testing.T) {
+	hardware := schema.GroupResource{Group: "tinkerbell.org", Resource: "hardware"}
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"not found":           {err: errors.Wrap(ErrNotFound, "lookup")},
+		"canceled":            {err: context.Canceled},
+		"deadline":            {err: errors.Wrap(context.DeadlineExceeded, "lookup"), want: true},
+		"network":             {err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, want: true},
+		"api not found":       {err: apierrors.NewNotFound(hardware, "machine")},
+		"api forbidden":       {err: apierrors.NewForbidden(hardware, "machine", errors.New("rbac"))},
+		"api internal":        {err: apierrors.NewInternalError(errors.New("etcd")), want: true},
+		"api unavailable":     {err: errors.Wrap(apierrors.NewServiceUnavailable("restarting"), "failed listing hardware"), want: true},
+		"api too many":        {err: apierrors.NewTooManyRequests("slow down", 1), want: true},
+		"api server timeout":  {err: apierrors.NewServerTimeout(hardware, "list", 1), want: true},
+		"unknown":             {err: errors.New("no hardware found")},
+		"ambiguous hardware":  {err: errors.New("2 hardware with mac")},
+		"wrapped api timeout": {err: errors.WithMessage(apierrors.NewTimeoutError("list", 1), "lookup"), want: true},
+		"grpc unavailable":    {err: status.Error(codes.Unavailable, "connection refused"), want: true},
+		"grpc deadline":       {err: status.Error(codes.DeadlineExceeded, "deadline exceeded"), want: true},
+		"grpc exhausted":      {err: errors.Wrap(status.Error(codes.ResourceExhausted, "too many requests"), "get hardware"), want: true},
+		"grpc aborted":        {err: status.Error(codes.Aborted, "conflict"), want: true},
+		"grpc not found":      {err: status.Error(codes.NotFound, "no hardware")},
+		"grpc denied":         {err: status.Error(codes.PermissionDenied, "bad token")},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Fatalf("want transient: %t, got: %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	transient := errors.Wrap(context.DeadlineExceeded, "lookup")
+	tests := map[string]struct {
+		errs    []error
+		want    error
+		lookups int
+	}{
+		"success":              {errs: []error{nil}, lookups: 1},
+		"recovers":             {errs: []error{transient, transient, nil}, lookups: 3},
+		"not found":            {errs: []error{ErrNotFound}, want: ErrNotFound, lookups: 1},
+		"retries used up":      {errs: []error{transient, transient, transient}, want: transient, lookups: 3},
+		"permanent after blip": {errs: []error{transient, ErrNotFound}, want: ErrNotFound, lookups: 2},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := NewBackoff(2, time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			retries := metrics.BackendRetries.WithLabelValues("mac")
+			before := testutil.ToFloat64(retries)
+
+			lookups := 0
+			err = b.Do(context.Background(), "mac", func() error {
+				lookups++
+
+				return tt.errs[lookups-1]
+			})
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("want error: %v, got: %v", tt.want, err)
+			}
+			if lookups != tt.lookups {
+				t.Fatalf("want %d lookups, got: %d", tt.lookups, lookups)
+			}
+			if got := testutil.ToFloat64(retries) - before; got != float64(tt.lookups-1) {
+				t.Fatalf("want %d retries counted, got: %v", tt.lookups-1, got)
+			}
+		})
+	}
+}
+
+func TestBackoffContext(t *testing.T) {
+	b, err := NewBackoff(5, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	lookups := 0
+	start := time.Now()
+	_ = b.Do(ctx, "ip", func() error {
+		lookups++
+
+		return context.DeadlineExceeded
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("want retries to stop with the context, took %v", elapsed)
+	}
+	if lookups > 2 {
+		t.Fatalf("want no retries once the context is done, got %d lookups", lookups)
+	}
+}
+
+func TestNewBackoff(t *testing.T) {
+	if b, err := NewBackoff(0, 0); err != nil || b != nil {
+		t.Fatalf("want a nil backoff without retries, got: %v, %v", b, err)
+	}
+	if _, err := NewBackoff(-1, time.Second); err == nil {
+		t.Fatal("want an error for negative retries")
+	}
+	if _, err := NewBackoff(1, 0); err == nil {
+		t.Fatal("want an error for a zero max interval")
+	}
+	var b *Backoff
+	lookups := 0
+	_ = b.Do(context.Background(), "ip", func() error {
+		lookups++
+
+		return context.DeadlineExceeded
+	})
+	if lookups != 1 {
+		t.Fatalf("want a nil backoff to not retry, got %d lookups", lookups)
+	}
+}
@@ -0,0 +1,172 @@
+package standalone
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/packethost/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+	"sigs.k8s.io/yaml"
+)
+
+// FileHardwareFinder looks up hardware in a JSON or YAML file formatted as a slice of
+// DiscoverStandalone, by the MAC and IP of any of their interfaces. The file is
+// reloaded by Watch when it changes, lookups see either the old or the new records.
+type FileHardwareFinder struct {
+	path   string
+	logger log.Logger
+	// index is the current *fileIndex
+	index atomic.Value
+	// modTime and size are the file's when it was last loaded, only used by Watch
+	modTime time.Time
+	size    int64
+}
+
+// fileIndex maps the MACs and IPs of the interfaces in a hardware file to their record.
+type fileIndex struct {
+	byMAC    map[string]*DiscoverStandalone
+	byIP     map[string]*DiscoverStandalone
+	size     int
+	loadedAt time.Time
+}
+
+// NewFileHardwareFinder returns a finder for the hardware file at path.
+func NewFileHardwareFinder(logger log.Logger, path string) (*FileHardwareFinder, error) {
+	f := &FileHardwareFinder{path: path, logger: logger}
+	if _, err := f.load(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// load reads and indexes the file, the current index is kept if it is invalid.
+// Returns whether the file changed since it was last loaded.
+func (f *FileHardwareFinder) load() (bool, error) {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not stat hardware file %q", f.path)
+	}
+	if fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return false, nil
+	}
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not read hardware file %q", f.path)
+	}
+	db := []*DiscoverStandalone{}
+	if err := yaml.Unmarshal(content, &db); err != nil {
+		return false, errors.Wrapf(err, "unable to parse hardware file %q", f.path)
+	}
+	x, err := newFileIndex(db)
+	if err != nil {
+		return false, errors.WithMessagef(err, "invalid hardware file %q", f.path)
+	}
+	f.index.Store(x)
+	f.modTime, f.size = fi.ModTime(), fi.Size()
+
+	return true, nil
+}
+
+func newFileIndex(db []*DiscoverStandalone) (*fileIndex, error) {
+	x := &fileIndex{
+		byMAC:    map[string]*DiscoverStandalone{},
+		byIP:     map[string]*DiscoverStandalone{},
+		size:     len(db),
+		loadedAt: time.Now(),
+	}
+	for i, d := range db {
+		if d == nil {
+			return nil, errors.Errorf("record %d is empty", i)
+		}
+		for _, iface := range d.Network.Interfaces {
+			if mac := iface.DHCP.MAC; mac != nil {
+				if err := addRecord(x.byMAC, mac.String(), d); err != nil {
+					return nil, err
+				}
+			}
+			if ip := iface.DHCP.IP.Address; ip != nil {
+				if err := addRecord(x.byIP, ip.String(), d); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return x, nil
+}
+
+func addRecord(index map[string]*DiscoverStandalone, key string, d *DiscoverStandalone) error {
+	key = strings.ToLower(key)
+	if other, ok := index[key]; ok && other != d {
+		return errors.Errorf("%s is in both hardware %s and %s", key, other.ID, d.ID)
+	}
+	index[key] = d
+
+	return nil
+}
+
+func (f *FileHardwareFinder) current() *fileIndex {
+	return f.index.Load().(*fileIndex)
+}
+
+// Watch reloads the file every interval that it has changed, until ctx is done. An
+// invalid file is logged and the records last loaded are kept.
+func (f *FileHardwareFinder) Watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			changed, err := f.load()
+			if err != nil {
+				f.logger.Error(errors.WithMessage(err, "hardware file not reloaded"))
+
+				continue
+			}
+			if changed {
+				f.logger.With("path", f.path, "records", f.Size()).Info("hardware file reloaded")
+			}
+		}
+	}
+}
+
+// Synced is always true, the file is loaded before the finder is returned.
+func (f *FileHardwareFinder) Synced() bool {
+	return true
+}
+
+// Size returns the number of records in the file.
+func (f *FileHardwareFinder) Size() int {
+	return f.current().size
+}
+
+// LastEvent returns when the file was last loaded.
+func (f *FileHardwareFinder) LastEvent() time.Time {
+	return f.current().loadedAt
+}
+
+// ByIP returns the hardware with an interface with ip.
+func (f *FileHardwareFinder) ByIP(_ context.Context, ip net.IP) (client.Discoverer, error) {
+	if d, ok := f.current().byIP[ip.String()]; ok {
+		return d, nil
+	}
+
+	return nil, errors.WithMessagef(client.ErrNotFound, "no hardware found for ip %q", ip)
+}
+
+// ByMAC returns the hardware with an interface with mac.
+func (f *FileHardwareFinder) ByMAC(_ context.Context, mac net.HardwareAddr, _ net.IP, _ string) (client.Discoverer, error) {
+	if d, ok := f.current().byMAC[mac.String()]; ok {
+		return d, nil
+	}
+
+	return nil, errors.WithMessagef(client.ErrNotFound, "no hardware found for mac %q", mac)
+}
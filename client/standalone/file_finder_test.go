@@ -0,0 +1,143 @@
+package standalone
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/packethost/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+)
+
+const hardwareYAML = `
+- id: hw-1
+  network:
+    interfaces:
+    - dhcp:
+        mac: 02:00:00:00:00:01
+        ip:
+          address: 192.168.1.1
+      netboot:
+        allow_pxe: true
+    - dhcp:
+        mac: 02:00:00:00:00:02
+        ip:
+          address: 2001:db8::1
+`
+
+func writeHardwareFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileHardwareFinder(t *testing.T) {
+	for name, content := range map[string]string{
+		"yaml": hardwareYAML,
+		"json": `[{"id": "hw-1", "network": {"interfaces": [
+			{"dhcp": {"mac": "02:00:00:00:00:01", "ip": {"address": "192.168.1.1"}}, "netboot": {"allow_pxe": true}},
+			{"dhcp": {"mac": "02:00:00:00:00:02", "ip": {"address": "2001:db8::1"}}}]}}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "hardware")
+			writeHardwareFile(t, file, content)
+			f, err := NewFileHardwareFinder(log.Test(t, "standalone"), file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			for _, ip := range []string{"192.168.1.1", "2001:db8::1"} {
+				d, err := f.ByIP(ctx, net.ParseIP(ip))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if d.Hardware().HardwareID() != "hw-1" {
+					t.Fatalf("%s: want hw-1, got %s", ip, d.Hardware().HardwareID())
+				}
+			}
+			mac, _ := net.ParseMAC("02:00:00:00:00:02")
+			d, err := f.ByMAC(ctx, mac, nil, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Hardware().HardwareID() != "hw-1" || !d.Hardware().HardwareAllowPXE(d.MAC()) {
+				t.Fatalf("want hw-1 with allow_pxe, got %s", d.Hardware().HardwareID())
+			}
+			if _, err := f.ByIP(ctx, net.ParseIP("192.168.1.2")); !errors.Is(err, client.ErrNotFound) {
+				t.Fatalf("want ErrNotFound, got: %v", err)
+			}
+			if f.Size() != 1 {
+				t.Fatalf("want 1 record, got %d", f.Size())
+			}
+		})
+	}
+}
+
+func TestFileHardwareFinderInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"not a list": "id: hw-1",
+		"duplicate mac": `
+- id: hw-1
+  network: {interfaces: [{dhcp: {mac: "02:00:00:00:00:01"}}]}
+- id: hw-2
+  network: {interfaces: [{dhcp: {mac: "02:00:00:00:00:01"}}]}
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "hardware.yaml")
+			writeHardwareFile(t, file, content)
+			if _, err := NewFileHardwareFinder(log.Test(t, "standalone"), file); err == nil {
+				t.Fatal("want an error")
+			}
+		})
+	}
+}
+
+func TestFileHardwareFinderWatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hardware.yaml")
+	writeHardwareFile(t, file, hardwareYAML)
+	f, err := NewFileHardwareFinder(log.Test(t, "standalone"), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.Watch(ctx, 10*time.Millisecond)
+
+	reloaded := func(ip string) bool {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, err := f.ByIP(ctx, net.ParseIP(ip)); err == nil {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		return false
+	}
+
+	// an invalid file keeps the records last loaded
+	writeHardwareFile(t, file, "not: [valid")
+	time.Sleep(50 * time.Millisecond)
+	if _, err := f.ByIP(ctx, net.ParseIP("192.168.1.1")); err != nil {
+		t.Fatalf("want the last valid records kept, got: %v", err)
+	}
+
+	// replace the file like editors and config management do
+	tmp := file + ".tmp"
+	writeHardwareFile(t, tmp, `[{"id": "hw-2", "network": {"interfaces": [{"dhcp": {"mac": "02:00:00:00:00:03", "ip": {"address": "192.168.1.3"}}}]}}]`)
+	if err := os.Rename(tmp, file); err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded("192.168.1.3") {
+		t.Fatal("want the changed file reloaded")
+	}
+	if _, err := f.ByIP(ctx, net.ParseIP("192.168.1.1")); err == nil {
+		t.Fatal("want the removed hardware gone")
+	}
+}
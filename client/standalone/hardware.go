@@ -18,6 +18,12 @@ func (hs *HardwareStandalone) HardwareAllowPXE(net.HardwareAddr) bool {
 	return hs.getPrimaryInterface().Netboot.AllowPXE
 }
 
+// HardwareNetbootConfigured reports whether the hardware has an interface to netboot from.
+// Netboot settings are not optional in the standalone data, so only missing interfaces are detected.
+func (hs *HardwareStandalone) HardwareNetbootConfigured(net.HardwareAddr) bool {
+	return len(hs.Network.Interfaces) > 0
+}
+
 func (hs *HardwareStandalone) HardwareAllowWorkflow(net.HardwareAddr) bool {
 	return hs.getPrimaryInterface().Netboot.AllowWorkflow
 }
@@ -51,6 +57,11 @@ func (hs *HardwareStandalone) Interfaces() []client.Port {
 	return []client.Port{} // stubbed out in tink too
 }
 
+// NetworkInterfaces returns all the network interfaces in the hardware record.
+func (hs *HardwareStandalone) NetworkInterfaces() []client.NetworkInterface {
+	return hs.Network.Interfaces
+}
+
 func (hs *HardwareStandalone) HardwareManufacturer() string {
 	return hs.Metadata.Manufacturer.Slug
 }
@@ -91,6 +102,18 @@ func (hs *HardwareStandalone) InitrdPath(net.HardwareAddr) string {
 	return hs.getPrimaryInterface().Netboot.OSIE.Initrd
 }
 
+func (hs *HardwareStandalone) KernelArgs(net.HardwareAddr) string {
+	return hs.getPrimaryInterface().Netboot.OSIE.KernelArgs
+}
+
+func (hs *HardwareStandalone) RedirectTo(net.HardwareAddr) string {
+	return hs.getPrimaryInterface().Netboot.RedirectTo
+}
+
+func (hs *HardwareStandalone) DHCPOptions(net.HardwareAddr) map[string]string {
+	return hs.getPrimaryInterface().DHCP.Options
+}
+
 func (hs *HardwareStandalone) OperatingSystem() *client.OperatingSystem {
 	return hs.Metadata.Instance.OS
 }
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"net"
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/tinkerbell/boots/client"
 	tinkclient "github.com/tinkerbell/tink/client"
 	tinkworkflow "github.com/tinkerbell/tink/protos/workflow"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 )
 
 // HardwareFinder is a type for statically looking up hardware.
@@ -61,23 +64,39 @@ func (f *HardwareFinder) ByMAC(_ context.Context, mac net.HardwareAddr, _ net.IP
 
 // WorkflowFinder is a type for finding if a hardware ID has active workflows.
 type WorkflowFinder struct {
+	conn    *grpc.ClientConn
 	wClient tinkworkflow.WorkflowServiceClient
 }
 
-// NewWorkflowFinder returns a *WorkflowFinder that satisfies client.WorkflowFinder.
-//
-// TODO: micahhausler: Explicitly pass in tink endpoint.
-func NewWorkflowFinder() (*WorkflowFinder, error) {
-	wc, err := tinkclient.TinkWorkflowClient()
+// NewWorkflowFinder returns a *WorkflowFinder that satisfies client.WorkflowFinder, using
+// the tink server at authority, a host:port.
+func NewWorkflowFinder(authority string, tls bool) (*WorkflowFinder, error) {
+	conn, err := tinkclient.NewClientConn(authority, tls)
 	if err != nil {
 		return nil, errors.Wrap(err, "connect to tink")
 	}
 
 	return &WorkflowFinder{
-		wClient: wc,
+		conn:    conn,
+		wClient: tinkworkflow.NewWorkflowServiceClient(conn),
 	}, nil
 }
 
+// Ping connects to the tink server, returning an error if the connection is not ready
+// before ctx is done.
+func (f *WorkflowFinder) Ping(ctx context.Context) error {
+	f.conn.Connect()
+	for {
+		state := f.conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if !f.conn.WaitForStateChange(ctx, state) {
+			return errors.Wrapf(ctx.Err(), "tink server connection %s", strings.ToLower(state.String()))
+		}
+	}
+}
+
 // HasActiveWorkflow finds if an active workflow exists for a particular hardware ID.
 func (f *WorkflowFinder) HasActiveWorkflow(ctx context.Context, hwID client.HardwareID) (bool, error) {
 	if hwID == "" {
@@ -4,10 +4,12 @@ import (
 	"context"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 	"github.com/tinkerbell/boots/client"
+	"google.golang.org/grpc"
 )
 
 func TestByIP(t *testing.T) {
@@ -253,3 +255,34 @@ func TestByMAC(t *testing.T) {
 		})
 	}
 }
+
+func TestWorkflowFinderPing(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Stop()
+
+	f, err := NewWorkflowFinder(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := f.Ping(ctx); err != nil {
+		t.Fatalf("want the tink server reached, got %v", err)
+	}
+
+	srv.Stop()
+	down, err := NewWorkflowFinder(l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := down.Ping(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want the ping timed out, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package standalone
+
+import (
+	"context"
+	"encoding/hex"
+	"hash/fnv"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+)
+
+// syntheticNet is the first byte of the IPs of synthetic hardware, which are in 10.0.0.0/8.
+const syntheticNet = 10
+
+// SyntheticFinder is a client.HardwareFinder and client.WorkflowFinder for load testing
+// that fabricates a record for any MAC instead of looking one up. Records are
+// deterministic: the same MAC always gets the same record, IP and whether it is found.
+type SyntheticFinder struct {
+	// latency is how long every lookup takes
+	latency time.Duration
+	// notFoundPercent is the percentage of MACs, 0 to 100, with no hardware
+	notFoundPercent int
+
+	// macs are the MACs ByMAC gave the IPs to, by the last 3 bytes of the IP
+	mu   sync.Mutex
+	macs map[[3]byte]net.HardwareAddr
+}
+
+// NewSyntheticFinder returns a finder whose lookups take latency, that finds no hardware
+// for notFoundPercent percent of MACs.
+func NewSyntheticFinder(latency time.Duration, notFoundPercent int) (*SyntheticFinder, error) {
+	if latency < 0 {
+		return nil, errors.New("-synthetic-latency must not be negative")
+	}
+	if notFoundPercent < 0 || notFoundPercent > 100 {
+		return nil, errors.New("-synthetic-not-found-percent must be between 0 and 100")
+	}
+
+	return &SyntheticFinder{latency: latency, notFoundPercent: notFoundPercent, macs: map[[3]byte]net.HardwareAddr{}}, nil
+}
+
+// wait waits for the lookup latency, returns the context's error if it is done first.
+func (f *SyntheticFinder) wait(ctx context.Context) error {
+	if f.latency == 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(f.latency)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// found reports whether mac is not one of the notFoundPercent percent of MACs with no hardware.
+func (f *SyntheticFinder) found(mac net.HardwareAddr) bool {
+	h := fnv.New32a()
+	_, _ = h.Write(mac)
+
+	return int(h.Sum32()%100) >= f.notFoundPercent
+}
+
+// ByIP returns the hardware ByMAC last gave ip to, for IPs in 10.0.0.0/8. An IP ByMAC has
+// not given out is the hardware of the MAC 02:00:00 followed by the last 3 bytes of ip.
+func (f *SyntheticFinder) ByIP(ctx context.Context, ip net.IP) (client.Discoverer, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+	ip4 := ip.To4()
+	if ip4 == nil || ip4[0] != syntheticNet {
+		return nil, errors.WithMessagef(client.ErrNotFound, "no synthetic hardware for ip %q", ip)
+	}
+	key := [3]byte{ip4[1], ip4[2], ip4[3]}
+	f.mu.Lock()
+	mac, ok := f.macs[key]
+	f.mu.Unlock()
+	if !ok {
+		mac = net.HardwareAddr{0x02, 0x00, 0x00, key[0], key[1], key[2]}
+	}
+	if !f.found(mac) {
+		return nil, errors.WithMessagef(client.ErrNotFound, "no synthetic hardware for ip %q", ip)
+	}
+
+	return syntheticHardware(mac), nil
+}
+
+// ByMAC returns the hardware fabricated for mac, and remembers mac as the hardware of its
+// IP for ByIP.
+func (f *SyntheticFinder) ByMAC(ctx context.Context, mac net.HardwareAddr, _ net.IP, _ string) (client.Discoverer, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+	if len(mac) != 6 || !f.found(mac) {
+		return nil, errors.WithMessagef(client.ErrNotFound, "no synthetic hardware for mac %q", mac)
+	}
+	f.mu.Lock()
+	f.macs[[3]byte{mac[3], mac[4], mac[5]}] = append(net.HardwareAddr(nil), mac...)
+	f.mu.Unlock()
+
+	return syntheticHardware(mac), nil
+}
+
+// HasActiveWorkflow always returns false without error, after the lookup latency.
+func (f *SyntheticFinder) HasActiveWorkflow(ctx context.Context, _ client.HardwareID) (bool, error) {
+	if err := f.wait(ctx); err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// syntheticHardware returns the record fabricated for mac, a 6 byte MAC: an x86_64 machine
+// allowed to netboot, with the IP 10 followed by the last 3 bytes of mac.
+func syntheticHardware(mac net.HardwareAddr) *DiscoverStandalone {
+	var m client.MACAddr
+	copy(m[:], mac)
+	name := "synthetic-" + hex.EncodeToString(mac)
+
+	return &DiscoverStandalone{HardwareStandalone: HardwareStandalone{
+		ID: name,
+		Network: client.Network{Interfaces: []client.NetworkInterface{{
+			DHCP: client.DHCP{
+				MAC: &m,
+				IP: client.IP{
+					Address: net.IPv4(syntheticNet, mac[3], mac[4], mac[5]),
+					Netmask: net.IPv4(255, 0, 0, 0),
+					Gateway: net.IPv4(syntheticNet, 0, 0, 1),
+					Family:  4,
+				},
+				Hostname:    name,
+				LeaseTime:   86400,
+				NameServers: []string{},
+				TimeServers: []string{},
+				Arch:        "x86_64",
+			},
+			Netboot: client.Netboot{AllowPXE: true, AllowWorkflow: true},
+		}}},
+		Metadata: client.Metadata{Facility: client.Facility{FacilityCode: "synthetic"}},
+	}}
+}
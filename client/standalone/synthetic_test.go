@@ -0,0 +1,105 @@
+package standalone
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+)
+
+func TestSyntheticFinder(t *testing.T) {
+	f, err := NewSyntheticFinder(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac, _ := net.ParseMAC("02:00:00:0a:0b:0c")
+	d, err := f.ByMAC(context.Background(), mac, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hw := d.Hardware()
+	if d.MAC().String() != mac.String() || !hw.HardwareAllowPXE(mac) || hw.HardwareID() != "synthetic-0200000a0b0c" || hw.HardwareArch(mac) != "x86_64" {
+		t.Fatalf("unexpected hardware %+v", d)
+	}
+	ip := d.GetIP(mac).Address
+	if !ip.Equal(net.IPv4(10, 10, 11, 12)) {
+		t.Fatalf("want ip 10.10.11.12, got %v", ip)
+	}
+	d, err = f.ByIP(context.Background(), ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.MAC().String() != mac.String() {
+		t.Fatalf("want mac %v by ip, got %v", mac, d.MAC())
+	}
+	// the IP of a MAC outside 02:00:00 is that MAC's, not 02:00:00's
+	other, _ := net.ParseMAC("aa:bb:cc:0a:0b:0c")
+	if _, err := f.ByMAC(context.Background(), other, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if d, err = f.ByIP(context.Background(), ip); err != nil || d.MAC().String() != other.String() {
+		t.Fatalf("want mac %v by ip, got %v, %v", other, d, err)
+	}
+	// an IP not given out yet is 02:00:00's
+	d, err = f.ByIP(context.Background(), net.IPv4(10, 1, 2, 3))
+	if err != nil || d.MAC().String() != "02:00:00:01:02:03" {
+		t.Fatalf("want mac 02:00:00:01:02:03 by ip, got %v, %v", d, err)
+	}
+	if _, err := f.ByIP(context.Background(), net.ParseIP("192.168.1.1")); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("want ErrNotFound outside 10.0.0.0/8, got %v", err)
+	}
+	if active, err := f.HasActiveWorkflow(context.Background(), hw.HardwareID()); active || err != nil {
+		t.Fatalf("want no active workflow, got %v, %v", active, err)
+	}
+}
+
+func TestSyntheticFinderNotFound(t *testing.T) {
+	for percent, want := range map[int][2]int{0: {0, 0}, 100: {1000, 1000}, 30: {200, 400}} {
+		f, err := NewSyntheticFinder(0, percent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		notFound := 0
+		for i := 0; i < 1000; i++ {
+			mac := net.HardwareAddr{0x02, 0, 0, 0, byte(i >> 8), byte(i)}
+			_, err := f.ByMAC(context.Background(), mac, nil, "")
+			if errors.Is(err, client.ErrNotFound) {
+				notFound++
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if _, again := f.ByMAC(context.Background(), mac, nil, ""); (again == nil) != (err == nil) {
+				t.Fatalf("want the same result for %v every lookup", mac)
+			}
+		}
+		if notFound < want[0] || notFound > want[1] {
+			t.Fatalf("%d%%: want %d to %d of 1000 MACs not found, got %d", percent, want[0], want[1], notFound)
+		}
+	}
+}
+
+func TestSyntheticFinderLatency(t *testing.T) {
+	f, err := NewSyntheticFinder(time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := f.ByMAC(ctx, net.HardwareAddr{0x02, 0, 0, 0, 0, 1}, nil, ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want the lookup to wait for the latency until the context is done, got %v", err)
+	}
+}
+
+func TestNewSyntheticFinder(t *testing.T) {
+	for _, tt := range []struct {
+		latency time.Duration
+		percent int
+	}{{-time.Second, 0}, {0, -1}, {0, 101}} {
+		if _, err := NewSyntheticFinder(tt.latency, tt.percent); err == nil {
+			t.Fatalf("want error for latency %v and not found percent %d", tt.latency, tt.percent)
+		}
+	}
+}
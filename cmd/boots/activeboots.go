@@ -0,0 +1,239 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// The stages of a boot recorded by the active boots tracker.
+const (
+	bootStageDHCP        = "dhcp"
+	bootStageFile        = "file"
+	bootStagePhoneHome   = "phone-home"
+	bootStageBootFailure = "boot-failure"
+)
+
+// activeBoot is a machine that has recently been seen booting.
+type activeBoot struct {
+	MAC string `json:"mac"`
+	// BootID identifies the boot in the logs and traces of each stage, see bootIDHeader
+	BootID string `json:"boot_id"`
+	Client string `json:"client,omitempty"`
+	// IP is the address the machine was last offered over DHCP, TFTP requests are matched to the boot by it
+	IP    string `json:"ip,omitempty"`
+	Stage string `json:"stage"`
+	// Arch is the arch reported in DHCP option 93 of the machine's last DHCP request, see dhcp.Arch
+	Arch      string    `json:"arch,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// activeBoots tracks the machines seen booting, with bounded memory. Entries
+// expire once they have not been seen for maxAge, and the least recently seen
+// entry is evicted when a new machine is seen with maxEntries already tracked.
+type activeBoots struct {
+	maxAge     time.Duration
+	maxEntries int
+	now        func() time.Time
+	newID      func() string
+
+	mu sync.Mutex
+	// lru holds *activeBoot, most recently seen first
+	lru     *list.List
+	entries map[string]*list.Element
+	// byIP indexes the entries by the address they were offered and the one they last
+	// requested from, an address maps to the machine that most recently got or used it
+	byIP map[string]*list.Element
+}
+
+func newActiveBoots(maxAge time.Duration, maxEntries int) (*activeBoots, error) {
+	if maxAge <= 0 {
+		return nil, errors.New("-active-boots-max-age must be greater than 0")
+	}
+	if maxEntries <= 0 {
+		return nil, errors.New("-active-boots-max-entries must be greater than 0")
+	}
+
+	return &activeBoots{
+		maxAge:     maxAge,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		newID:      newBootID,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+		byIP:       map[string]*list.Element{},
+	}, nil
+}
+
+// seen records that mac reached stage and returns the ID of its boot, a machine not
+// already tracked starts a new boot. client is the requesting address, if known. bootID
+// is the boot's ID if the machine sent one, it replaces the tracked one.
+func (a *activeBoots) seen(mac net.HardwareAddr, client, stage, bootID string) string {
+	if a == nil || len(mac) == 0 {
+		return bootID
+	}
+	now := a.now().UTC()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(now)
+
+	if e, ok := a.entries[mac.String()]; ok {
+		b := e.Value.(*activeBoot)
+		b.Stage = stage
+		b.LastSeen = now
+		if client != "" {
+			a.unindex(remoteIP(b.Client), e)
+			b.Client = client
+			a.index(remoteIP(client), e)
+		}
+		if bootID != "" {
+			b.BootID = bootID
+		}
+		a.lru.MoveToFront(e)
+
+		return b.BootID
+	}
+
+	for a.lru.Len() >= a.maxEntries {
+		a.remove(a.lru.Back())
+		metrics.ActiveBootsEvicted.WithLabelValues("capacity").Inc()
+	}
+	if bootID == "" {
+		bootID = a.newID()
+	}
+	b := &activeBoot{MAC: mac.String(), BootID: bootID, Client: client, Stage: stage, FirstSeen: now, LastSeen: now}
+	e := a.lru.PushFront(b)
+	a.entries[b.MAC] = e
+	a.index(remoteIP(client), e)
+
+	return bootID
+}
+
+// seenIP records the address mac was offered over DHCP.
+func (a *activeBoots) seenIP(mac net.HardwareAddr, ip net.IP) {
+	if a == nil || ip == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if e, ok := a.entries[mac.String()]; ok {
+		b := e.Value.(*activeBoot)
+		a.unindex(b.IP, e)
+		b.IP = ip.String()
+		a.index(b.IP, e)
+	}
+}
+
+// byAddr returns the unexpired boot of the machine at addr, an IP or host:port, the one
+// that was most recently offered it or requested from it. Returns false if there is none.
+func (a *activeBoots) byAddr(addr string) (activeBoot, bool) {
+	if a == nil {
+		return activeBoot{}, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(a.now().UTC())
+	if e, ok := a.byIP[remoteIP(addr)]; ok {
+		return *e.Value.(*activeBoot), true
+	}
+
+	return activeBoot{}, false
+}
+
+// index maps ip to e, a.mu must be held.
+func (a *activeBoots) index(ip string, e *list.Element) {
+	if ip != "" {
+		a.byIP[ip] = e
+	}
+}
+
+// unindex removes ip from the index if it maps to e, a.mu must be held.
+func (a *activeBoots) unindex(ip string, e *list.Element) {
+	if a.byIP[ip] == e {
+		delete(a.byIP, ip)
+	}
+}
+
+// seenArch records the arch mac reported in its last DHCP request, see dhcp.Arch.
+func (a *activeBoots) seenArch(mac net.HardwareAddr, arch string) {
+	if a == nil || arch == "" {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if e, ok := a.entries[mac.String()]; ok {
+		e.Value.(*activeBoot).Arch = arch
+	}
+}
+
+// arch returns the arch mac reported in its last DHCP request, empty if it is unknown.
+func (a *activeBoots) arch(mac net.HardwareAddr) string {
+	if a == nil || len(mac) == 0 {
+		return ""
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if e, ok := a.entries[mac.String()]; ok {
+		return e.Value.(*activeBoot).Arch
+	}
+
+	return ""
+}
+
+// list returns the unexpired active boots, most recently seen first.
+func (a *activeBoots) list() []activeBoot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(a.now().UTC())
+
+	boots := make([]activeBoot, 0, a.lru.Len())
+	for e := a.lru.Front(); e != nil; e = e.Next() {
+		boots = append(boots, *e.Value.(*activeBoot))
+	}
+
+	return boots
+}
+
+// size returns the number of unexpired active boots, 0 if a is nil.
+func (a *activeBoots) size() int {
+	if a == nil {
+		return 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(a.now().UTC())
+
+	return a.lru.Len()
+}
+
+// expire removes the entries not seen for maxAge, a.mu must be held.
+func (a *activeBoots) expire(now time.Time) {
+	for e := a.lru.Back(); e != nil && now.Sub(e.Value.(*activeBoot).LastSeen) >= a.maxAge; e = a.lru.Back() {
+		a.remove(e)
+		metrics.ActiveBootsEvicted.WithLabelValues("idle").Inc()
+	}
+}
+
+func (a *activeBoots) remove(e *list.Element) {
+	a.lru.Remove(e)
+	b := e.Value.(*activeBoot)
+	delete(a.entries, b.MAC)
+	a.unindex(b.IP, e)
+	a.unindex(remoteIP(b.Client), e)
+}
+
+// serveActiveBoots lists the machines currently being tracked as booting.
+func (s *BootsHTTPServer) serveActiveBoots(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.activeBoots.list()); err != nil {
+		mainlog.Error(errors.Wrap(err, "marshaling active boots json"))
+	}
+}
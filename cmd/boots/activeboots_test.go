@@ -0,0 +1,164 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestActiveBoots(t *testing.T) {
+	macs := make([]net.HardwareAddr, 3)
+	for i := range macs {
+		macs[i] = net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, byte(i)}
+	}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	a, err := newActiveBoots(time.Minute, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.now = func() time.Time { return now }
+	a.newID = func() string { return "id" }
+	idle := testutil.ToFloat64(metrics.ActiveBootsEvicted.WithLabelValues("idle"))
+	capacity := testutil.ToFloat64(metrics.ActiveBootsEvicted.WithLabelValues("capacity"))
+
+	a.seen(macs[0], "", bootStageDHCP, "")
+	now = now.Add(10 * time.Second)
+	a.seen(macs[1], "", bootStageDHCP, "")
+	now = now.Add(10 * time.Second)
+	a.seen(macs[0], "192.168.1.5:1234", bootStageFile, "")
+
+	want := []activeBoot{
+		{MAC: macs[0].String(), BootID: "id", Client: "192.168.1.5:1234", Stage: bootStageFile, FirstSeen: start, LastSeen: start.Add(20 * time.Second)},
+		{MAC: macs[1].String(), BootID: "id", Stage: bootStageDHCP, FirstSeen: start.Add(10 * time.Second), LastSeen: start.Add(10 * time.Second)},
+	}
+	if diff := cmp.Diff(want, a.list()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// full, so the least recently seen machine is evicted
+	now = now.Add(20 * time.Second)
+	a.seen(macs[2], "", bootStageDHCP, "")
+	got := a.list()
+	if len(got) != 2 || got[0].MAC != macs[2].String() || got[1].MAC != macs[0].String() {
+		t.Fatalf("unexpected active boots after eviction: %v", got)
+	}
+	if n := testutil.ToFloat64(metrics.ActiveBootsEvicted.WithLabelValues("capacity")) - capacity; n != 1 {
+		t.Fatalf("want 1 capacity eviction, got %v", n)
+	}
+
+	// macs[0] was last seen 20s before macs[2], so it expires first
+	now = now.Add(41 * time.Second)
+	got = a.list()
+	if len(got) != 1 || got[0].MAC != macs[2].String() {
+		t.Fatalf("unexpected active boots after expiry: %v", got)
+	}
+	now = now.Add(time.Minute)
+	if got := a.list(); len(got) != 0 {
+		t.Fatalf("want no active boots, got %v", got)
+	}
+	if n := testutil.ToFloat64(metrics.ActiveBootsEvicted.WithLabelValues("idle")) - idle; n != 2 {
+		t.Fatalf("want 2 idle evictions, got %v", n)
+	}
+}
+
+func TestActiveBootsArch(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, 0xef}
+	a, err := newActiveBoots(time.Minute, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.seenArch(mac, "aarch64")
+	if got := a.arch(mac); got != "" {
+		t.Fatalf("want no arch for an untracked machine, got %q", got)
+	}
+	a.seen(mac, "", bootStageDHCP, "")
+	a.seenArch(mac, "aarch64")
+	a.seenArch(mac, "")
+	a.seen(mac, "192.168.1.5:1234", bootStageFile, "")
+	if got := a.arch(mac); got != "aarch64" {
+		t.Fatalf("want arch aarch64, got %q", got)
+	}
+}
+
+func TestActiveBootsBootID(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, 0xef}
+	a, err := newActiveBoots(time.Minute, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := a.seen(mac, "", bootStageDHCP, "")
+	if id == "" {
+		t.Fatal("want a generated boot ID")
+	}
+	a.seenIP(mac, net.ParseIP("192.168.1.5"))
+	if got := a.seen(mac, "192.168.1.5:1234", bootStageFile, ""); got != id {
+		t.Fatalf("want boot ID %q kept, got %q", id, got)
+	}
+	if got := a.seen(mac, "192.168.1.5:1234", bootStagePhoneHome, "from-header"); got != "from-header" {
+		t.Fatalf("want the sent boot ID, got %q", got)
+	}
+
+	b, ok := a.byAddr("192.168.1.5")
+	if !ok || b.MAC != mac.String() || b.BootID != "from-header" {
+		t.Fatalf("unexpected boot for address: %v, %v", b, ok)
+	}
+	if b, ok := a.byAddr("192.168.1.6"); ok {
+		t.Fatalf("want no boot for an unknown address, got %v", b)
+	}
+}
+
+func TestActiveBootsByAddr(t *testing.T) {
+	first := net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, 0xef}
+	second := net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, 0xe0}
+	a, err := newActiveBoots(time.Minute, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	a.seen(first, "", bootStageDHCP, "")
+	a.seenIP(first, net.ParseIP("192.168.1.5"))
+
+	// an address offered to another machine is matched to that machine
+	now = now.Add(time.Second)
+	a.seen(second, "", bootStageDHCP, "")
+	a.seenIP(second, net.ParseIP("192.168.1.5"))
+	if b, ok := a.byAddr("192.168.1.5:69"); !ok || b.MAC != second.String() {
+		t.Fatalf("want the machine last offered the address, got %v, %v", b, ok)
+	}
+
+	// a machine offered another address is no longer matched by its old one
+	a.seenIP(second, net.ParseIP("192.168.1.6"))
+	if b, ok := a.byAddr("192.168.1.5"); ok {
+		t.Fatalf("want no boot for the old address, got %v", b)
+	}
+	now = now.Add(30 * time.Second)
+	a.seen(first, "192.168.1.7:1234", bootStageFile, "")
+	if b, ok := a.byAddr("192.168.1.7"); !ok || b.MAC != first.String() {
+		t.Fatalf("want the machine that requested from the address, got %v, %v", b, ok)
+	}
+
+	// expired machines are not matched
+	now = now.Add(31 * time.Second)
+	if b, ok := a.byAddr("192.168.1.6"); ok {
+		t.Fatalf("want no boot for an expired machine, got %v", b)
+	}
+	if len(a.byIP) != 1 {
+		t.Fatalf("want the expired machine's addresses dropped from the index, got %v", a.byIP)
+	}
+}
+
+func TestNewActiveBootsInvalid(t *testing.T) {
+	if _, err := newActiveBoots(0, 1); err == nil {
+		t.Fatal("expected an error for a zero max age")
+	}
+	if _, err := newActiveBoots(time.Minute, 0); err == nil {
+		t.Fatal("expected an error for zero max entries")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/dhcp"
+	"github.com/tinkerbell/boots/installers"
+)
+
+// parseArchKernelArgs parses -extra-kernel-args-arch, a ; separated list of arch=args,
+// e.g. "x86_64=console=ttyS0,115200; aarch64=console=ttyAMA0". The arch must be one
+// dhcp.Arch returns and the args may contain template actions like -extra-kernel-args.
+// Returns nil if s is empty.
+func parseArchKernelArgs(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	byArch := map[string]string{}
+	for _, entry := range strings.Split(s, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		arch, args, ok := strings.Cut(entry, "=")
+		arch = strings.TrimSpace(arch)
+		if !ok || strings.TrimSpace(args) == "" {
+			return nil, errors.Errorf("invalid -extra-kernel-args-arch entry %q, must be arch=args", entry)
+		}
+		if !dhcp.IsArch(arch) {
+			return nil, errors.Errorf("invalid -extra-kernel-args-arch arch %q, must be x86_64, aarch64 or another DHCP option 93 arch", arch)
+		}
+		if _, ok := byArch[arch]; ok {
+			return nil, errors.Errorf("invalid -extra-kernel-args-arch, arch %s is given more than once", arch)
+		}
+		if _, err := installers.ParseKernelArgs(args); err != nil {
+			return nil, errors.WithMessagef(err, "invalid -extra-kernel-args-arch args for %s", arch)
+		}
+		byArch[arch] = strings.TrimSpace(args)
+	}
+
+	return byArch, nil
+}
+
+// archKernelArgsTemplate returns a kernel args template expanding to the args of byArch
+// for the arch the machine boots as. It is the layer after the global kernel args, so
+// the arch's args override the global ones but not the hardware record's, see
+// installers.MergeKernelArgs. Returns "" if byArch is empty.
+func archKernelArgsTemplate(byArch map[string]string) string {
+	if len(byArch) == 0 {
+		return ""
+	}
+	arches := make([]string, 0, len(byArch))
+	for arch := range byArch {
+		arches = append(arches, arch)
+	}
+	sort.Strings(arches)
+
+	var b strings.Builder
+	for i, arch := range arches {
+		if i == 0 {
+			b.WriteString("{{if eq .Arch ")
+		} else {
+			b.WriteString("{{else if eq .Arch ")
+		}
+		b.WriteString(strconv.Quote(arch) + "}}" + byArch[arch])
+	}
+	b.WriteString("{{end}}")
+
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/boots/installers"
+	"github.com/tinkerbell/boots/job"
+)
+
+func TestParseArchKernelArgs(t *testing.T) {
+	tests := map[string]struct {
+		s       string
+		want    map[string]string
+		wantErr bool
+	}{
+		"empty":         {},
+		"arches":        {s: "x86_64=console=ttyS0,115200 iommu=on; aarch64=console=ttyAMA0;", want: map[string]string{"x86_64": "console=ttyS0,115200 iommu=on", "aarch64": "console=ttyAMA0"}},
+		"dhcp arch":     {s: "ARM 32-bit UEFI=console=ttyAMA0", want: map[string]string{"ARM 32-bit UEFI": "console=ttyAMA0"}},
+		"template":      {s: "x86_64=hostname={{.Hostname}}", want: map[string]string{"x86_64": "hostname={{.Hostname}}"}},
+		"unknown arch":  {s: "arm64=console=ttyAMA0", wantErr: true},
+		"no args":       {s: "x86_64=", wantErr: true},
+		"no arch":       {s: "console=ttyS0", wantErr: true},
+		"repeated arch": {s: "x86_64=a=1; x86_64=b=2", wantErr: true},
+		"bad template":  {s: "x86_64={{.Nope}}", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseArchKernelArgs(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got: %v", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestArchKernelArgsTemplate(t *testing.T) {
+	byArch := map[string]string{"x86_64": "console=ttyS0 iommu=on", "aarch64": "console=ttyAMA0 console=ttyS0,115200"}
+	tmpl := archKernelArgsTemplate(byArch)
+	if _, err := installers.ParseKernelArgs(tmpl); err != nil {
+		t.Fatal(err)
+	}
+	args := installers.NewKernelArgs("console=tty0 quiet", tmpl)
+	tests := map[string]struct {
+		arch     string
+		hardware string
+		want     string
+	}{
+		"x86_64":        {arch: "x86_64", want: "console=ttyS0 quiet iommu=on"},
+		"aarch64":       {arch: "aarch64", want: "console=ttyAMA0 console=ttyS0,115200 quiet"},
+		"other arch":    {arch: "x86 UEFI", want: "console=tty0 quiet"},
+		"hardware args": {arch: "x86_64", hardware: "console=ttyS1", want: "console=ttyS1 quiet iommu=on"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := job.NewMock(t, "c3.small.x86", "ewr1")
+			m.SetKernelArgs(tt.hardware)
+			j := m.Job()
+			j.ClientArch = tt.arch
+			if got := installers.MergeKernelArgs(args.Expand(j), j.KernelArgs()); got != tt.want {
+				t.Fatalf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+	if got := archKernelArgsTemplate(nil); got != "" {
+		t.Fatalf("want no template without arch args, got %q", got)
+	}
+}
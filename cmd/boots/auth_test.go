@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	tests := map[string]struct {
+		token string
+		auth  string
+		want  int
+	}{
+		"no token required":           {want: http.StatusOK},
+		"no token required with auth": {auth: "Bearer anything", want: http.StatusOK},
+		"missing":                     {token: "secret", want: http.StatusUnauthorized},
+		"wrong":                       {token: "secret", auth: "Bearer nope", want: http.StatusUnauthorized},
+		"not bearer":                  {token: "secret", auth: "secret", want: http.StatusUnauthorized},
+		"basic":                       {token: "secret", auth: "Basic c2VjcmV0", want: http.StatusUnauthorized},
+		"prefix":                      {token: "secret", auth: "Bearer secre", want: http.StatusUnauthorized},
+		"valid":                       {token: "secret", auth: "Bearer secret", want: http.StatusOK},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			requireToken(tt.token, ok).ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("want status %d, got %d", tt.want, w.Code)
+			}
+			if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Fatal("want a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// maxBodyReader limits a request body like http.MaxBytesReader, and records whether reading
+// it failed because it is larger than the limit, so that is told apart from a malformed body.
+type maxBodyReader struct {
+	io.ReadCloser
+	limit, read int64
+	tooLarge    bool
+}
+
+func (r *maxBodyReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	if err != nil && err != io.EOF && r.read >= r.limit {
+		r.tooLarge = true
+	}
+
+	return n, err
+}
+
+// limitBody returns h with request bodies limited to limit bytes, see -max-request-body.
+// Requests declaring a larger body are refused with a 413 without reaching h, h must
+// refuse those with a larger body than declared, see bodyTooLarge. h is returned as is
+// if limit is 0.
+func limitBody(limit int64, h http.HandlerFunc) http.HandlerFunc {
+	if limit <= 0 {
+		return h
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.ContentLength > limit {
+			refuseBodyTooLarge(w, req, limit)
+
+			return
+		}
+		req.Body = &maxBodyReader{ReadCloser: http.MaxBytesReader(w, req.Body, limit), limit: limit}
+		h(w, req)
+	}
+}
+
+// bodyTooLarge reports whether reading the body of req failed because it is larger than the
+// limitBody limit.
+func bodyTooLarge(req *http.Request) bool {
+	r, ok := req.Body.(*maxBodyReader)
+
+	return ok && r.tooLarge
+}
+
+// refuseBodyTooLarge responds with a 413 to a request with a body larger than limit bytes.
+func refuseBodyTooLarge(w http.ResponseWriter, req *http.Request, limit int64) {
+	mainlog.With("client", req.RemoteAddr, "path", req.URL.Path, "limit", limit).Info("refusing request body larger than -max-request-body")
+	http.Error(w, "request body larger than "+strconv.FormatInt(limit, 10)+" bytes", http.StatusRequestEntityTooLarge)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/job"
+)
+
+func TestPhoneHomeBodyLimit(t *testing.T) {
+	j := job.NewMock(t, "c3.small.x86", "ewr1").Job()
+	status := `{"phase": "installing", "progress": 50}`
+	tests := map[string]struct {
+		contentType string
+		body        string
+		// chunked sends the body without declaring its length
+		chunked    bool
+		wantStatus int
+	}{
+		"status":                   {contentType: "application/json", body: status, wantStatus: http.StatusOK},
+		"oversized status":         {contentType: "application/json", body: status + strings.Repeat(" ", 100), wantStatus: http.StatusRequestEntityTooLarge},
+		"oversized chunked status": {contentType: "application/json", body: status + strings.Repeat(" ", 100), chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+		"invalid status":           {contentType: "application/json", body: `{"phase": ""}`, wantStatus: http.StatusBadRequest},
+		"form":                     {contentType: "application/x-www-form-urlencoded", body: "type=provisioning.104.01", wantStatus: http.StatusOK},
+		"oversized chunked form":   {contentType: "application/x-www-form-urlencoded", body: "type=provisioning.104.01&body=" + strings.Repeat("a", 100), chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := &BootsHTTPServer{jobManager: staticManager{j: &j}, maxRequestBody: 64}
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/phone-home", body)
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			limitBody(s.maxRequestBody, s.servePhoneHome)(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d: %s", tt.wantStatus, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestPhoneHomeStatusBodyCeiling(t *testing.T) {
+	j := job.NewMock(t, "c3.small.x86", "ewr1").Job()
+	s := &BootsHTTPServer{jobManager: staticManager{j: &j}}
+	body := `{"phase": "installing", "progress": 50}` + strings.Repeat(" ", maxInstallStatusBody)
+	req := httptest.NewRequest(http.MethodPost, "/phone-home", io.MultiReader(strings.NewReader(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	limitBody(s.maxRequestBody, s.servePhoneHome)(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("want a status body over %d bytes refused without -max-request-body, got status %d", maxInstallStatusBody, w.Code)
+	}
+}
+
+func TestLimitBodyDisabled(t *testing.T) {
+	h := limitBody(0, func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		_, _ = w.Write(b)
+	})
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/phone-home", strings.NewReader(strings.Repeat("a", 1<<20))))
+	if w.Code != http.StatusOK || w.Body.Len() != 1<<20 {
+		t.Fatalf("want the whole body read without a limit, got status %d and %d bytes", w.Code, w.Body.Len())
+	}
+}
+
+func TestBootFailureBodyLimit(t *testing.T) {
+	s := &BootsHTTPServer{jobManager: staticManager{err: errors.New("unused")}, maxRequestBody: 64}
+	for body, want := range map[string]int{
+		"mac=00:00:ba:dd:be:ef&stage=kernel&errno=0x2d0c6133":                             http.StatusOK,
+		"mac=00:00:ba:dd:be:ef&stage=kernel&errno=0x2d0c6133&" + strings.Repeat("a", 100): http.StatusRequestEntityTooLarge,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/boot-failure", io.MultiReader(strings.NewReader(body)))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		limitBody(s.maxRequestBody, s.serveBootFailure)(w, req)
+		if w.Code != want {
+			t.Fatalf("body of %d bytes: want status %d, got %d", len(body), want, w.Code)
+		}
+	}
+}
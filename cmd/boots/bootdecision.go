@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/tinkerbell/boots/job"
+)
+
+// acceptsJSON reports whether the Accept header of req prefers application/json
+// over the boot script. Requests without an Accept header get the script, as do
+// ties with another media type. Ties with a wildcard, e.g. */*, prefer JSON.
+func acceptsJSON(req *http.Request) bool {
+	jsonQ, otherQ, wildcardQ := -1.0, -1.0, -1.0
+	forEachAccepted(req, func(mediaType string, q float64) {
+		switch {
+		case mediaType == "application/json":
+			jsonQ = maxQ(jsonQ, q)
+		case strings.HasSuffix(mediaType, "/*"):
+			wildcardQ = maxQ(wildcardQ, q)
+		default:
+			otherQ = maxQ(otherQ, q)
+		}
+	})
+
+	return jsonQ > 0 && jsonQ > otherQ && jsonQ >= wildcardQ
+}
+
+// varyAccept adds Accept to the Vary header of h unless it is listed already, for
+// responses whose representation depends on the Accept header of the request.
+func varyAccept(h http.Header) {
+	for _, v := range h.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), "Accept") {
+				return
+			}
+		}
+	}
+	h.Add("Vary", "Accept")
+}
+
+func maxQ(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+// serveBootDecision responds with the boot decision for j as JSON instead of the boot file.
+func (h *jobHandler) serveBootDecision(ctx context.Context, w http.ResponseWriter, req *http.Request, j *job.Job) {
+	d := j.BootDecision(ctx, req.URL.Path, h.installers.Load())
+	w.Header().Set("Content-Type", "application/json")
+	varyAccept(w.Header())
+	if err := json.NewEncoder(w).Encode(d); err != nil {
+		j.With("path", req.URL.Path).Error(err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/job"
+)
+
+func TestAcceptsJSON(t *testing.T) {
+	for _, tt := range []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: false},
+		{accept: "*/*", want: false},
+		{accept: "text/plain", want: false},
+		{accept: "application/json", want: true},
+		{accept: "application/json, */*", want: true},
+		{accept: "application/json, text/plain", want: false},
+		{accept: "text/plain;q=0.5, application/json", want: true},
+		{accept: "application/json;q=0.5, text/plain", want: false},
+		{accept: "application/json;q=0", want: false},
+		{accept: "application/json;q=nope", want: false},
+	} {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/auto.ipxe", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if got := acceptsJSON(req); got != tt.want {
+				t.Fatalf("unexpected result, want: %t, got: %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestServeJobFileVaryAccept(t *testing.T) {
+	j := job.NewMock(t, "c3.small.x86", "ewr1").Job()
+	h := &jobHandler{jobManager: staticManager{j: &j}, installers: conf.NewReloadable(job.NewInstallers()), problems: true}
+	for _, accept := range []string{"", "application/json", "application/problem+json"} {
+		req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		h.serveJobFile(w, req)
+		n := 0
+		for _, v := range w.Header().Values("Vary") {
+			if v == "Accept" {
+				n++
+			}
+		}
+		if n != 1 {
+			t.Fatalf("accept %q: want Vary: Accept once, got %q", accept, w.Header().Values("Vary"))
+		}
+	}
+}
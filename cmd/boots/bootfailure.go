@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// bootFailureStages are the boot_stage values set by iPXE scripts, anything else is counted as unknown.
+var bootFailureStages = map[string]bool{
+	"kernel": true,
+	"initrd": true,
+	"boot":   true,
+}
+
+// bootFailure is a client side boot failure reported by an iPXE script.
+type bootFailure struct {
+	Time   time.Time `json:"time"`
+	Client string    `json:"client"`
+	MAC    string    `json:"mac"`
+	Stage  string    `json:"stage"`
+	Errno  string    `json:"errno"`
+}
+
+// bootFailureRecorder persists boot failure reports as JSON lines.
+type bootFailureRecorder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (r *bootFailureRecorder) record(f bootFailure) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return errors.Wrap(err, "marshal boot failure")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.w.Write(append(b, '\n'))
+
+	return errors.Wrap(err, "write boot failure")
+}
+
+// serveBootFailure receives the boot failure reports posted by iPXE scripts, see ipxe.Script.ReportFailure.
+func (s *BootsHTTPServer) serveBootFailure(w http.ResponseWriter, req *http.Request) {
+	labels := prometheus.Labels{"from": "http", "op": "boot-failure"}
+	metrics.JobsTotal.With(labels).Inc()
+	metrics.JobsInProgress.With(labels).Inc()
+	defer metrics.JobsInProgress.With(labels).Dec()
+	timer := prometheus.NewTimer(metrics.JobDuration.With(labels))
+	defer timer.ObserveDuration()
+
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		if bodyTooLarge(req) {
+			refuseBodyTooLarge(w, req, s.maxRequestBody)
+
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		mainlog.With("client", req.RemoteAddr).Error(errors.Wrap(err, "parsing boot failure report"))
+
+		return
+	}
+
+	f := bootFailure{
+		Time:   time.Now().UTC(),
+		Client: req.RemoteAddr,
+		MAC:    req.PostForm.Get("mac"),
+		Stage:  req.PostForm.Get("stage"),
+		Errno:  req.PostForm.Get("errno"),
+	}
+	stage := f.Stage
+	if !bootFailureStages[stage] {
+		stage = "unknown"
+	}
+	metrics.BootFailures.With(prometheus.Labels{"stage": stage}).Inc()
+
+	logger := mainlog.With("mac", f.MAC)
+	if _, j, err := s.jobManager.CreateFromRemoteAddr(req.Context(), req.RemoteAddr); err == nil {
+		tagBootRequest(w, req, j, s.activeBoots.seen(j.PrimaryNIC(), req.RemoteAddr, bootStageBootFailure, requestBootID(req)))
+		logger = j.Logger
+	}
+	logger.With("client", req.RemoteAddr, "stage", f.Stage, "errno", f.Errno).Error(errors.New("client reported boot failure"))
+
+	if s.bootFailures != nil {
+		if err := s.bootFailures.record(f); err != nil {
+			mainlog.Error(err)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/tinkerbell/boots/httplog"
+	"github.com/tinkerbell/boots/job"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// bootIDHeader is the header a machine can send its boot ID in, it is set on the
+// responses to the requests of a tracked boot so installers can pass it along.
+const bootIDHeader = "X-Boot-ID"
+
+// validBootID matches the boot IDs accepted in bootIDHeader.
+var validBootID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// newBootID returns a random boot ID.
+func newBootID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// requestBootID returns the boot ID in req's bootIDHeader, empty if there is none or it is invalid.
+func requestBootID(req *http.Request) string {
+	id := req.Header.Get(bootIDHeader)
+	if !validBootID.MatchString(id) {
+		return ""
+	}
+
+	return id
+}
+
+// tagBoot adds the boot ID to the job's logs and to span.
+func tagBoot(span trace.Span, j *job.Job, id string) {
+	if id == "" {
+		return
+	}
+	j.Logger = j.Logger.With("boot_id", id)
+	span.SetAttributes(attribute.String("BootID", id))
+}
+
+// tagBootRequest tags the job of req with the boot ID, see tagBoot, and adds it to the
+// access log and the response.
+func tagBootRequest(w http.ResponseWriter, req *http.Request, j *job.Job, id string) {
+	if id == "" {
+		return
+	}
+	tagBoot(trace.SpanFromContext(req.Context()), j, id)
+	httplog.SetBootID(req.Context(), id)
+	w.Header().Set(bootIDHeader, id)
+}
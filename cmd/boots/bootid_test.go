@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestBootID(t *testing.T) {
+	tests := map[string]struct {
+		header string
+		want   string
+	}{
+		"none":     {},
+		"valid":    {header: "boot-1.a_B", want: "boot-1.a_B"},
+		"invalid":  {header: "boot id"},
+		"too long": {header: strings.Repeat("a", 65)},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+			if tt.header != "" {
+				req.Header.Set(bootIDHeader, tt.header)
+			}
+			if got := requestBootID(req); got != tt.want {
+				t.Fatalf("want boot ID %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
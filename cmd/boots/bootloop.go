@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// bootLoopsPath lists the machines with the most recent boot attempts, it is more
+// specific than installStatusPrefix so it is not taken for a MAC.
+const bootLoopsPath = installStatusPrefix + "boot-loops"
+
+// bootLoopSweepAt is the number of machines tracked before the idle ones are first dropped.
+const bootLoopSweepAt = 256
+
+// bootLoopTop is the number of machines bootLoopsPath lists.
+const bootLoopTop = 20
+
+// bootLoopMaxAttempts bounds the attempts tracked for a machine, a machine hammering Boots
+// is reported with at most this many.
+const bootLoopMaxAttempts = 1000
+
+// bootLoopDetector counts the boot script requests of each machine within a sliding
+// window, a machine with more than threshold of them is considered boot looping. A nil
+// bootLoopDetector does not count.
+type bootLoopDetector struct {
+	threshold int
+	window    time.Duration
+	// halt serves looping machines an iPXE script that stops the loop
+	halt bool
+	now  func() time.Time
+
+	mu sync.Mutex
+	// attempts are the times of the boot script requests of each MAC within the window, oldest first
+	attempts map[string][]time.Time
+	sweepAt  int
+}
+
+// bootLoop is a machine's boot attempts within the window, as served.
+type bootLoop struct {
+	MAC      string    `json:"mac"`
+	Attempts int       `json:"attempts"`
+	First    time.Time `json:"first"`
+	Last     time.Time `json:"last"`
+	Looping  bool      `json:"looping"`
+}
+
+// newBootLoopDetector returns a detector of machines with more than threshold boot
+// attempts within window. Returns nil if threshold is 0.
+func newBootLoopDetector(threshold int, window time.Duration, halt bool) (*bootLoopDetector, error) {
+	if threshold < 0 {
+		return nil, errors.New("-boot-loop-threshold must not be negative")
+	}
+	if threshold == 0 {
+		if halt {
+			return nil, errors.New("-boot-loop-halt requires -boot-loop-threshold")
+		}
+
+		return nil, nil
+	}
+	if threshold >= bootLoopMaxAttempts {
+		return nil, errors.Errorf("-boot-loop-threshold must be less than %d", bootLoopMaxAttempts)
+	}
+	if window <= 0 {
+		return nil, errors.New("-boot-loop-window must be greater than 0")
+	}
+
+	return &bootLoopDetector{threshold: threshold, window: window, halt: halt, now: time.Now, attempts: map[string][]time.Time{}, sweepAt: bootLoopSweepAt}, nil
+}
+
+// attempt records a boot attempt of mac and returns its attempts within the window
+// and whether it is looping.
+func (d *bootLoopDetector) attempt(mac net.HardwareAddr) (int, bool) {
+	if d == nil || len(mac) == 0 {
+		return 0, false
+	}
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := mac.String()
+	times, ok := d.attempts[key]
+	if !ok && len(d.attempts) >= d.sweepAt {
+		d.sweep(now)
+	}
+	times = append(d.unexpired(times, now), now)
+	if len(times) > bootLoopMaxAttempts {
+		times = times[len(times)-bootLoopMaxAttempts:]
+	}
+	d.attempts[key] = times
+
+	return len(times), len(times) > d.threshold
+}
+
+// unexpired returns the times within the window of now, d.mu must be held.
+func (d *bootLoopDetector) unexpired(times []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= d.window {
+		i++
+	}
+
+	return times[i:]
+}
+
+// sweep drops the machines with no attempts within the window, d.mu must be held.
+func (d *bootLoopDetector) sweep(now time.Time) {
+	for mac, times := range d.attempts {
+		if len(d.unexpired(times, now)) == 0 {
+			delete(d.attempts, mac)
+		}
+	}
+	d.sweepAt = 2 * len(d.attempts)
+	if d.sweepAt < bootLoopSweepAt {
+		d.sweepAt = bootLoopSweepAt
+	}
+}
+
+// top returns up to n machines with attempts within the window, most attempts first.
+func (d *bootLoopDetector) top(n int) []bootLoop {
+	loops := []bootLoop{}
+	if d == nil {
+		return loops
+	}
+	now := d.now()
+
+	d.mu.Lock()
+	for mac, times := range d.attempts {
+		if times = d.unexpired(times, now); len(times) > 0 {
+			loops = append(loops, bootLoop{MAC: mac, Attempts: len(times), First: times[0].UTC(), Last: times[len(times)-1].UTC(), Looping: len(times) > d.threshold})
+		}
+	}
+	d.mu.Unlock()
+
+	sort.Slice(loops, func(i, j int) bool {
+		if loops[i].Attempts != loops[j].Attempts {
+			return loops[i].Attempts > loops[j].Attempts
+		}
+
+		return loops[i].Last.After(loops[j].Last)
+	})
+	if len(loops) > n {
+		loops = loops[:n]
+	}
+
+	return loops
+}
+
+// haltScript returns an iPXE script that prints why the machine is not booted and waits
+// forever, so a looping machine stops requesting boot files until it is reset.
+func haltScript(mac net.HardwareAddr, attempts int, window time.Duration) []byte {
+	s := ipxe.NewScript()
+	s.Echo("Boot loop detected for " + mac.String() + ", " + strconv.Itoa(attempts) + " boot attempts within " + window.String() + ", halting")
+	s.AppendString(":halt")
+	s.Sleep(3600)
+	s.AppendString("goto halt")
+
+	return s.Bytes()
+}
+
+// haltBootLoop records a boot attempt of the machine of j requesting a boot script,
+// logging a warning once it is looping, and responds with the halt script if halting is
+// enabled. Returns true if it responded.
+func (h *jobHandler) haltBootLoop(w http.ResponseWriter, req *http.Request, j *job.Job) bool {
+	attempts, looping := h.bootLoops.attempt(j.PrimaryNIC())
+	if !looping {
+		return false
+	}
+	logger := j.With("client", req.RemoteAddr, "attempts", attempts, "window", h.bootLoops.window, "halt", h.bootLoops.halt)
+	if attempts == h.bootLoops.threshold+1 {
+		metrics.BootLoops.Inc()
+		logger.Error(errors.New("boot loop detected"), "machine is requesting boot scripts in a loop")
+	} else {
+		logger.Debug("machine is still boot looping")
+	}
+	if !h.bootLoops.halt {
+		return false
+	}
+	if _, err := w.Write(haltScript(j.PrimaryNIC(), attempts, h.bootLoops.window)); err != nil {
+		logger.Error(errors.Wrap(err, "unable to write halt script"))
+	}
+
+	return true
+}
+
+// serveBootLoops lists the machines with the most boot attempts within the window.
+func (s *BootsHTTPServer) serveBootLoops(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.bootLoops.top(bootLoopTop)); err != nil {
+		mainlog.Error(errors.Wrap(err, "marshaling boot loops json"))
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestBootLoopDetector(t *testing.T) {
+	d, err := newBootLoopDetector(3, 10*time.Minute, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+	looper, _ := net.ParseMAC("00:00:ba:dd:be:ef")
+	normal, _ := net.ParseMAC("00:00:00:00:00:01")
+
+	d.attempt(normal)
+	for i := 1; i <= 4; i++ {
+		attempts, looping := d.attempt(looper)
+		if attempts != i || looping != (i > 3) {
+			t.Fatalf("attempt %d: got %d attempts, looping %t", i, attempts, looping)
+		}
+		now = now.Add(time.Minute)
+	}
+	top := d.top(10)
+	if len(top) != 2 || top[0].MAC != looper.String() || !top[0].Looping || top[1].Looping {
+		t.Fatalf("want the looping machine first, got %+v", top)
+	}
+
+	// the attempts expire, so a machine booting normally later is not looping
+	now = now.Add(10 * time.Minute)
+	if attempts, looping := d.attempt(looper); attempts != 1 || looping {
+		t.Fatalf("want the previous attempts expired, got %d, looping %t", attempts, looping)
+	}
+	if top := d.top(10); len(top) != 1 {
+		t.Fatalf("want only unexpired machines, got %+v", top)
+	}
+}
+
+func TestNewBootLoopDetector(t *testing.T) {
+	if d, err := newBootLoopDetector(0, time.Minute, false); d != nil || err != nil {
+		t.Fatalf("want no detector, got %v, %v", d, err)
+	}
+	for name, tt := range map[string]struct {
+		threshold int
+		window    time.Duration
+		halt      bool
+	}{
+		"negative threshold":  {threshold: -1, window: time.Minute},
+		"halt with no detect": {window: time.Minute, halt: true},
+		"threshold too large": {threshold: bootLoopMaxAttempts, window: time.Minute},
+		"zero window":         {threshold: 3},
+	} {
+		if _, err := newBootLoopDetector(tt.threshold, tt.window, tt.halt); err == nil {
+			t.Fatalf("%s: want error", name)
+		}
+	}
+}
+
+func TestHaltBootLoop(t *testing.T) {
+	mock := job.NewMock(t, "c3.small.x86", "ewr1")
+	mock.SetMAC("00:00:ba:dd:be:ef")
+	j := mock.Job()
+	d, err := newBootLoopDetector(1, time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loops := testutil.ToFloat64(metrics.BootLoops)
+	h := &jobHandler{jobManager: staticManager{j: &j}, bootLoops: d}
+	h.haltBootLoop(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil), &j)
+	w := httptest.NewRecorder()
+	if !h.haltBootLoop(w, httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil), &j) {
+		t.Fatal("want the second attempt halted")
+	}
+	if body := w.Body.String(); !strings.Contains(body, "echo Boot loop detected for 00:00:ba:dd:be:ef, 2 boot attempts within 1h0m0s") || !strings.HasSuffix(body, "goto halt\n") {
+		t.Fatalf("want the halt script, got:\n%s", body)
+	}
+	if n := testutil.ToFloat64(metrics.BootLoops) - loops; n != 1 {
+		t.Fatalf("want 1 boot loop detected, got %v", n)
+	}
+
+	s := &BootsHTTPServer{bootLoops: d}
+	w = httptest.NewRecorder()
+	s.serveBootLoops(w, httptest.NewRequest(http.MethodGet, bootLoopsPath, nil))
+	var top []bootLoop
+	if err := json.Unmarshal(w.Body.Bytes(), &top); err != nil || len(top) != 1 || top[0].Attempts != 2 {
+		t.Fatalf("want the looping machine listed, got %s, %v", w.Body, err)
+	}
+}
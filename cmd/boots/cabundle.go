@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// caBundlePath is where the CA bundle is served for booting machines to fetch and install.
+const caBundlePath = "/ca.pem"
+
+// caBundleURL returns the URL booting machines fetch the CA bundle from, empty if none is configured.
+func (cf *config) caBundleURL() string {
+	if cf.caBundle == "" {
+		return ""
+	}
+
+	return "${tinkerbell}" + caBundlePath
+}
+
+// caBundle is a PEM encoded bundle of CA certificates served to booting machines.
+type caBundle struct {
+	pem     []byte
+	etag    string
+	modTime time.Time
+}
+
+// loadCABundle reads the PEM file at path, which must contain only certificates.
+func loadCABundle(path string) (*caBundle, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read ca bundle")
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "stat ca bundle")
+	}
+
+	rest, n := b, 0
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, errors.Errorf("ca bundle %s contains a %s block, only certificates are allowed", path, block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, errors.Wrapf(err, "parse certificate in ca bundle %s", path)
+		}
+		n++
+	}
+	if n == 0 || len(bytes.TrimSpace(rest)) > 0 {
+		return nil, errors.Errorf("ca bundle %s is not a PEM certificate bundle", path)
+	}
+
+	sum := sha256.Sum256(b)
+
+	return &caBundle{
+		pem:     b,
+		etag:    `"` + hex.EncodeToString(sum[:8]) + `"`,
+		modTime: fi.ModTime(),
+	}, nil
+}
+
+// ServeHTTP serves the bundle, supporting conditional requests so machines can cheaply revalidate it.
+func (c *caBundle) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Header().Set("ETag", c.etag)
+	http.ServeContent(w, req, "ca.pem", c.modTime, bytes.NewReader(c.pem))
+}
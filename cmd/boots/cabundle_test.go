@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testCAPEM(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test root"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestLoadCABundle(t *testing.T) {
+	ca := testCAPEM(t)
+	tests := map[string]struct {
+		content []byte
+		wantErr bool
+	}{
+		"single cert":   {content: ca},
+		"multiple":      {content: append(append([]byte{}, ca...), ca...)},
+		"empty":         {content: []byte{}, wantErr: true},
+		"not pem":       {content: []byte("not a certificate"), wantErr: true},
+		"trailing junk": {content: append(append([]byte{}, ca...), "junk"...), wantErr: true},
+		"private key":   {content: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte{1}}), wantErr: true},
+		"bad cert":      {content: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}}), wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ca.pem")
+			if err := os.WriteFile(path, tt.content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadCABundle(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestServeCABundle(t *testing.T) {
+	ca := testCAPEM(t)
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := loadCABundle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	bundle.ServeHTTP(w, httptest.NewRequest(http.MethodGet, caBundlePath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("want status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-pem-file" {
+		t.Fatalf("unexpected Content-Type %q", ct)
+	}
+	if w.Header().Get("Cache-Control") == "" {
+		t.Fatal("missing Cache-Control")
+	}
+	if w.Body.String() != string(ca) {
+		t.Fatal("unexpected body")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, caBundlePath, nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	bundle.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("want status 304 for a matching ETag, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	bundle.ServeHTTP(w, httptest.NewRequest(http.MethodPost, caBundlePath, nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("want status 405, got %d", w.Code)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+)
+
+// checkBackend returns an error if the hardware backend of finder or the workflow backend
+// of workflows can not be reached within timeout, used by -check. Backends that do not
+// depend on a remote service are checked by constructing them.
+func checkBackend(ctx context.Context, finder client.HardwareFinder, workflows client.WorkflowFinder, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return errors.WithMessage(pingBackend(ctx, finder, workflows), "backend check failed")
+}
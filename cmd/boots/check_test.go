@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+)
+
+// reachableFinder is a client.HardwarePinger whose backend can always be reached.
+type reachableFinder struct {
+	client.HardwareFinder
+}
+
+func (reachableFinder) Ping(context.Context) error { return nil }
+
+func TestCheckBackend(t *testing.T) {
+	tests := map[string]struct {
+		finder    client.HardwareFinder
+		workflows client.WorkflowFinder
+		err       bool
+	}{
+		"reachable":             {finder: reachableFinder{}, workflows: reachableWorkflows{}},
+		"unreachable":           {finder: pingFinder{err: errors.New("connection refused")}, err: true},
+		"timeout":               {finder: pingFinder{}, err: true},
+		"no pinger":             {finder: cachedFinder{}, workflows: &client.NoOpWorkflowFinder{}},
+		"workflows unreachable": {finder: cachedFinder{}, workflows: pingWorkflows{err: errors.New("connection refused")}, err: true},
+		"workflows timeout":     {finder: reachableFinder{}, workflows: pingWorkflows{}, err: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkBackend(context.Background(), tt.finder, tt.workflows, 10*time.Millisecond)
+			if tt.err != (err != nil) {
+				t.Fatalf("want error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+)
+
+// requireClientCert only calls h for requests with a verified client certificate
+// that maps to the same hardware record as the request's source address, see
+// hardwareFromCert. All other requests get a 403.
+func (s *BootsHTTPServer) requireClientCert(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if err := s.verifyClientCert(req); err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			mainlog.With("client", req.RemoteAddr).Error(err, "client certificate authentication failed")
+
+			return
+		}
+		h(w, req)
+	}
+}
+
+func (s *BootsHTTPServer) verifyClientCert(req *http.Request) error {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return errors.New("no verified client certificate")
+	}
+	hwID, err := s.hardwareFromCert(req.Context(), req.TLS.VerifiedChains[0][0])
+	if err != nil {
+		return err
+	}
+	_, j, err := s.jobManager.CreateFromRemoteAddr(req.Context(), req.RemoteAddr)
+	if err != nil {
+		return errors.WithMessage(err, "finding hardware for client address")
+	}
+	if j.HardwareID() != hwID {
+		return errors.Errorf("client certificate is for hardware %q, not %q", hwID, j.HardwareID())
+	}
+
+	return nil
+}
+
+// hardwareFromCert returns the ID of the hardware record a client certificate
+// belongs to. Certificates are mapped by their IP address SANs, or their subject
+// common name when it is the hardware's MAC.
+func (s *BootsHTTPServer) hardwareFromCert(ctx context.Context, cert *x509.Certificate) (client.HardwareID, error) {
+	for _, ip := range cert.IPAddresses {
+		if d, err := s.finder.ByIP(ctx, ip); err == nil {
+			return d.Hardware().HardwareID(), nil
+		}
+	}
+	if mac, err := net.ParseMAC(cert.Subject.CommonName); err == nil {
+		if d, err := s.finder.ByMAC(ctx, mac, nil, ""); err == nil {
+			return d.Hardware().HardwareID(), nil
+		}
+	}
+
+	return "", errors.Errorf("client certificate %q does not map to a hardware record", cert.Subject)
+}
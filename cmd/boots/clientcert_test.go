@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/client/standalone"
+)
+
+func TestHardwareFromCert(t *testing.T) {
+	data := `[{
+		"id": "hw-1",
+		"network": {"interfaces": [{"dhcp": {"mac": "02:00:00:00:00:01", "ip": {"address": "192.168.1.10"}}}]}
+	}]`
+	path := filepath.Join(t.TempDir(), "hardware.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	finder, err := standalone.NewHardwareFinder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &BootsHTTPServer{finder: finder}
+
+	tests := map[string]struct {
+		cert    *x509.Certificate
+		want    client.HardwareID
+		wantErr bool
+	}{
+		"ip san": {
+			cert: &x509.Certificate{IPAddresses: []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("192.168.1.10")}},
+			want: "hw-1",
+		},
+		"mac common name": {
+			cert: &x509.Certificate{Subject: pkix.Name{CommonName: "02:00:00:00:00:01"}},
+			want: "hw-1",
+		},
+		"unknown ip san": {
+			cert:    &x509.Certificate{IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}},
+			wantErr: true,
+		},
+		"unknown mac": {
+			cert:    &x509.Certificate{Subject: pkix.Name{CommonName: "02:00:00:00:00:02"}},
+			wantErr: true,
+		},
+		"common name not a mac": {
+			cert:    &x509.Certificate{Subject: pkix.Name{CommonName: "machine-1"}},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := s.hardwareFromCert(context.Background(), tt.cert)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// redacted replaces the value of secret flags in the effective configuration.
+const redacted = "REDACTED"
+
+// secretFlags are the flags whose values are redacted from the effective configuration.
+var secretFlags = map[string]bool{
+	"kubeconfig":          true,
+	"kubeconfig-content":  true,
+	"http-tls-key":        true,
+	"syslog-stream-token": true,
+	"config-token":        true,
+	"simulate-token":      true,
+	"maintenance-token":   true,
+	"drain-token":         true,
+	"active-boots-token":  true,
+	"status-token":        true,
+	"metrics-auth-token":  true,
+	"pprof-auth-token":    true,
+	"phone-home-webhook":  true,
+}
+
+// effectiveConfig returns the value of every flag in fs keyed by flag name, i.e. the
+// configuration resolved from the flags, env and config file, in the config file
+// format. Durations are formatted like they are given and secret flags are redacted.
+// The flags that print something and exit are left out.
+func effectiveConfig(fs *flag.FlagSet) map[string]interface{} {
+	c := map[string]interface{}{}
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "print-config" || f.Name == "version" {
+			return
+		}
+		var v interface{} = f.Value.String()
+		if g, ok := f.Value.(flag.Getter); ok {
+			v = g.Get()
+		}
+		if d, ok := v.(time.Duration); ok {
+			v = d.String()
+		}
+		if secretFlags[f.Name] && f.Value.String() != "" {
+			v = redacted
+		}
+		c[f.Name] = v
+	})
+
+	return c
+}
+
+// printConfig writes the effective configuration of fs to w as indented JSON.
+func printConfig(w io.Writer, fs *flag.FlagSet) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+
+	return errors.Wrap(e.Encode(effectiveConfig(fs)), "encode configuration")
+}
+
+// serveConfig responds with the effective configuration, it is served behind
+// requireToken with the config token.
+func (s *BootsHTTPServer) serveConfig(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.config.Load()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		mainlog.Error(errors.Wrap(err, "marshaling config json"))
+	}
+}
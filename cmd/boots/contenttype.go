@@ -0,0 +1,108 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// contentTypes are the Content-Types of served files by lowercase extension, including
+// the dot, overriding the type the handler sets or sniffs, see -content-type-map.
+type contentTypes map[string]string
+
+// parseContentTypeMap parses ".ext=type" pairs separated by ";", e.g.
+// ".ipxe=text/plain; .efi=application/octet-stream". A type may have parameters, so a part
+// that does not start with "." is a parameter of the previous type, as in
+// ".ipxe=text/plain; charset=utf-8". Returns nil if s is empty.
+func parseContentTypeMap(s string) (contentTypes, error) {
+	var pairs []string
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "":
+		case strings.HasPrefix(part, "."):
+			pairs = append(pairs, part)
+		case len(pairs) == 0:
+			return nil, errors.Errorf("invalid -content-type-map entry %q, must be .ext=type", part)
+		default:
+			pairs[len(pairs)-1] += "; " + part
+		}
+	}
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	types := contentTypes{}
+	for _, pair := range pairs {
+		ext, typ, ok := strings.Cut(pair, "=")
+		ext, typ = strings.ToLower(strings.TrimSpace(ext)), strings.TrimSpace(typ)
+		if !ok || len(ext) < 2 || strings.ContainsAny(ext[1:], "./ \t") {
+			return nil, errors.Errorf("invalid -content-type-map entry %q, must be .ext=type", pair)
+		}
+		if _, _, err := mime.ParseMediaType(typ); err != nil || !validHeaderValue(typ) {
+			return nil, errors.Errorf("invalid -content-type-map type %q for %s", typ, ext)
+		}
+		if _, dup := types[ext]; dup {
+			return nil, errors.Errorf("invalid -content-type-map, %s is given more than once", ext)
+		}
+		types[ext] = typ
+	}
+
+	return types, nil
+}
+
+// wrap returns h with the Content-Type of its successful responses set by the extension
+// of the request path. h is returned as is if there are no types.
+func (c contentTypes) wrap(h http.HandlerFunc) http.HandlerFunc {
+	if len(c) == 0 {
+		return h
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		typ, ok := c[strings.ToLower(path.Ext(req.URL.Path))]
+		if !ok {
+			h(w, req)
+
+			return
+		}
+		h(&contentTypeWriter{ResponseWriter: w, contentType: typ}, req)
+	}
+}
+
+// contentTypeWriter sets the Content-Type when a 2xx response header is written, errors
+// keep the type they are written with.
+type contentTypeWriter struct {
+	http.ResponseWriter
+	contentType string
+	wroteHeader bool
+}
+
+func (w *contentTypeWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if code >= 200 && code < 300 {
+			w.Header().Set("Content-Type", w.contentType)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *contentTypeWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the underlying ResponseWriter if it supports it, see http.Flusher.
+func (w *contentTypeWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
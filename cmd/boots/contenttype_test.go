@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestParseContentTypeMap(t *testing.T) {
+	tests := map[string]struct {
+		s    string
+		want contentTypes
+		err  bool
+	}{
+		"none":          {},
+		"types":         {s: ".ipxe=text/plain; .EFI = application/octet-stream;", want: contentTypes{".ipxe": "text/plain", ".efi": "application/octet-stream"}},
+		"parameters":    {s: ".ipxe=text/plain; charset=utf-8; .kpxe=application/octet-stream", want: contentTypes{".ipxe": "text/plain; charset=utf-8", ".kpxe": "application/octet-stream"}},
+		"no dot":        {s: "ipxe=text/plain", err: true},
+		"no extension":  {s: ".=text/plain", err: true},
+		"double ext":    {s: ".tar.gz=application/gzip", err: true},
+		"no type":       {s: ".ipxe", err: true},
+		"invalid type":  {s: ".ipxe=text plain", err: true},
+		"control chars": {s: ".ipxe=text/plain\r\nX-Tag: a", err: true},
+		"duplicate":     {s: ".ipxe=text/plain; .IPXE=text/x-ipxe", err: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseContentTypeMap(tt.s)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("want error, got %v", got)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestContentTypesWrap(t *testing.T) {
+	types, err := parseContentTypeMap(".ipxe=text/plain; .efi=application/octet-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &jobHandler{jobManager: staticManager{err: errors.New("no hardware")}, errorScripts: true}
+	tests := map[string]struct {
+		handler    http.HandlerFunc
+		path       string
+		wantStatus int
+		wantType   string
+	}{
+		"ipxe script":    {handler: h.serveJobFile, path: "/auto.ipxe", wantStatus: http.StatusOK, wantType: "text/plain"},
+		"upper case ext": {handler: func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("MZ\x90\x00")) }, path: "/ipxe/IPXE.EFI", wantStatus: http.StatusOK, wantType: "application/octet-stream"},
+		"sniffed":        {handler: func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("#!ipxe\n")) }, path: "/ipxe/undionly.kpxe", wantStatus: http.StatusOK, wantType: "text/plain; charset=utf-8"},
+		"binary":         {handler: func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("MZ\x90\x00")) }, path: "/ipxe/ipxe.efi", wantStatus: http.StatusOK, wantType: "application/octet-stream"},
+		"error kept":     {handler: func(w http.ResponseWriter, _ *http.Request) { http.Error(w, "not found", http.StatusNotFound) }, path: "/ipxe/ipxe.efi", wantStatus: http.StatusNotFound, wantType: "text/plain; charset=utf-8"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = "192.0.2.10:4321"
+			w := httptest.NewRecorder()
+			types.wrap(tt.handler)(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Fatalf("want Content-Type %q, got %q", tt.wantType, got)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"net"
 	"runtime"
+	"strings"
+	"sync"
 
 	"github.com/avast/retry-go"
 	"github.com/gammazero/workerpool"
@@ -11,7 +13,10 @@ import (
 	"github.com/packethost/pkg/env"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/boots/client"
 	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/dhcp"
+	"github.com/tinkerbell/boots/ipxe"
 	"github.com/tinkerbell/boots/job"
 	"github.com/tinkerbell/boots/metrics"
 	"go.opentelemetry.io/otel"
@@ -20,48 +25,366 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// The ways DHCP packets with an all zero or broadcast chaddr can be handled.
+const (
+	// invalidMACIgnore drops the packet.
+	invalidMACIgnore = "ignore"
+	// invalidMACClientID looks up hardware by the MAC in the client identifier (option 61) instead.
+	invalidMACClientID = "client-id"
+)
+
+// validInvalidMACActions are the accepted values for -dhcp-invalid-mac.
+var validInvalidMACActions = map[string]bool{
+	invalidMACIgnore:   true,
+	invalidMACClientID: true,
+}
+
+// The DHCP server modes.
+const (
+	// dhcpModeAuto assigns addresses and serves boot information.
+	dhcpModeAuto = "auto"
+	// dhcpModeProxy only serves boot information to PXE clients, leaving addressing to another DHCP server.
+	dhcpModeProxy = "proxy"
+	// dhcpModeDisabled does not serve DHCP.
+	dhcpModeDisabled = "disabled"
+)
+
+// validDHCPModes are the accepted values for -dhcp-mode.
+var validDHCPModes = map[string]bool{
+	dhcpModeAuto:     true,
+	dhcpModeProxy:    true,
+	dhcpModeDisabled: true,
+}
+
+// The reasons a DHCPREQUEST is NAKed in authoritative mode.
+const (
+	// nakUnknownClient is a request from a machine with no hardware record.
+	nakUnknownClient = "unknown-client"
+	// nakWrongAddress is a request for an address other than the one the machine is assigned.
+	nakWrongAddress = "wrong-address"
+)
+
+// nakMessages are the messages (option 56) sent with the DHCPNAKs, by reason.
+var nakMessages = map[string]string{
+	nakUnknownClient: "no lease for this client",
+	nakWrongAddress:  "requested address is not assigned to this client",
+}
+
+// proxyDHCPPort is the port PXE clients send their DHCPREQUEST for boot server information to after a ProxyDHCP offer.
+const proxyDHCPPort = "4011"
+
 type BootsDHCPServer struct {
-	jobmanager job.Manager
+	jobmanager   job.Manager
+	listenConfig net.ListenConfig
+	// invalidMAC is how packets with an all zero or broadcast chaddr are handled
+	invalidMAC string
+	// tracer logs the option set of replies to selected clients, nil disables tracing
+	tracer *replyTracer
+	// packetLog logs the options of received packets at debug level, nil logs nothing
+	packetLog *packetLogger
+	// activeBoots records the machines that are sent a DHCP reply
+	activeBoots *activeBoots
+	// archPolicy chooses the iPXE binary sent to PXE clients by their arch
+	archPolicy *dhcp.ArchPolicy
+	// ipxeUserClasses are the user-classes of the iPXE builds besides Tinkerbell's that are sent the boot script URL
+	ipxeUserClasses ipxe.UserClasses
+	// relayPolicy handles packets from relays outside of the configured relay subnets
+	relayPolicy *dhcp.RelayPolicy
+	// quietPolicy chooses the machines whose per-DISCOVER logging is at debug level
+	quietPolicy *job.QuietPolicy
+	// mode is dhcpModeAuto or dhcpModeProxy
+	mode string
+	// ipxeScheme is the scheme of the URL HTTP clients fetch iPXE binaries from
+	ipxeScheme string
+	// bootsScheme is the scheme of the boot script URL HTTP clients are sent, https when the HTTP server serves TLS
+	bootsScheme string
+	// maintenance leaves the boot options out of the replies while it is enabled
+	maintenance *maintenance
+	// recentLookups counts the hardware lookups for the stats endpoint, nil does not count them
+	recentLookups *recentLookups
+	// authoritative NAKs DHCPREQUESTs for addresses or leases Boots does not own instead of dropping them
+	authoritative bool
+
+	drainer *drainer
+	mu      sync.Mutex
+	conns   []net.PacketConn
+	closed  bool
 }
 
-// ServeDHCP starts the DHCP server.
+// parseDHCPAddrs splits the comma separated -dhcp-addr addresses and validates each, see
+// validateDHCPAddr. Duplicates are an error as they cannot both be bound.
+func parseDHCPAddrs(s string) ([]string, error) {
+	var addrs []string
+	seen := map[string]bool{}
+	for _, addr := range strings.Split(s, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if seen[addr] {
+			return nil, errors.Errorf("duplicate -dhcp-addr %q", addr)
+		}
+		seen[addr] = true
+		if err := validateDHCPAddr(addr); err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no -dhcp-addr")
+	}
+	if len(addrs) > 1 {
+		for _, addr := range addrs {
+			if host, _, _ := net.SplitHostPort(addr); host == "" || net.ParseIP(host).IsUnspecified() {
+				return nil, errors.Errorf("-dhcp-addr %q listens on every address and cannot be combined with other addresses", addr)
+			}
+		}
+	}
+
+	return addrs, nil
+}
+
+// validateDHCPAddr checks that addr, an address DHCP is served on, is an IPv4 host:port
+// and that there is a public IPv4 to hand out as the DHCP server and next server, DHCP is
+// only served to IPv4 clients.
+func validateDHCPAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.Wrap(err, "invalid -dhcp-addr")
+	}
+	if ip := net.ParseIP(host); host != "" && (ip == nil || ip.To4() == nil) {
+		return errors.Errorf("invalid -dhcp-addr %q, DHCP is only served on IPv4 addresses", addr)
+	}
+	if conf.PublicIPv4 == nil {
+		return errors.New("DHCP requires a public IPv4, set PUBLIC_IP or -dhcp-mode disabled")
+	}
+
+	return nil
+}
+
+// dhcpListener is a bound DHCP address and the handler serving it.
+type dhcpListener struct {
+	addr    string
+	conn    net.PacketConn
+	handler dhcpHandler
+	// demux serves several -dhcp-addr addresses from addr, a wildcard address, instead of handler
+	demux *dhcpDemux
+}
+
+// ServeDHCP starts a DHCP server on each of addrs, sharing a worker pool.
 // It takes the next server address (nextServer) for serving iPXE binaries via TFTP
 // and an IP:Port (httpServerFQDN) for serving iPXE binaries via HTTP.
-func (s *BootsDHCPServer) ServeDHCP(addr string, nextServer net.IP, ipxeBaseURL string, bootsBaseURL string) {
+// All the addresses are bound before any is served, Boots exits if one cannot be bound.
+func (s *BootsDHCPServer) ServeDHCP(addrs []string, nextServer net.IP, ipxeBaseURL string, bootsBaseURL string) {
 	poolSize := env.Int("BOOTS_DHCP_WORKERS", runtime.GOMAXPROCS(0)/2)
-	handler := dhcpHandler{
-		pool:         workerpool.New(poolSize),
-		nextServer:   nextServer,
-		ipxeBaseURL:  ipxeBaseURL,
-		bootsBaseURL: bootsBaseURL,
-		jobmanager:   s.jobmanager,
+	pool := workerpool.New(poolSize)
+	defer pool.Stop()
+
+	var ls []dhcpListener
+	for _, addr := range addrs {
+		handler := dhcpHandler{
+			pool:            pool,
+			nextServer:      nextServer,
+			ipxeBaseURL:     ipxeBaseURL,
+			ipxeScheme:      s.ipxeScheme,
+			bootsScheme:     s.bootsScheme,
+			bootsBaseURL:    bootsBaseURL,
+			jobmanager:      s.jobmanager,
+			invalidMAC:      s.invalidMAC,
+			tracer:          s.tracer,
+			packetLog:       s.packetLog,
+			activeBoots:     s.activeBoots,
+			archPolicy:      s.archPolicy,
+			ipxeUserClasses: s.ipxeUserClasses,
+			quietPolicy:     s.quietPolicy,
+			relayPolicy:     s.relayPolicy,
+			drainer:         s.drainer,
+			maintenance:     s.maintenance,
+			recentLookups:   s.recentLookups,
+			authoritative:   s.authoritative,
+			listener:        addr,
+		}
+		metrics.InitDHCPListener(addr)
+		if s.mode != dhcpModeProxy {
+			ls = append(ls, dhcpListener{addr: addr, handler: handler})
+
+			continue
+		}
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			mainlog.Fatal(errors.Wrap(err, "parse dhcp address"))
+		}
+		// on 67 only DISCOVERs are answered, the REQUESTs there are for the DHCP server handing out addresses
+		discover := handler
+		discover.proxy = dhcp4.MessageTypeDiscover
+		boot := handler
+		boot.proxy = dhcp4.MessageTypeRequest
+		ls = append(ls, dhcpListener{addr: addr, handler: discover}, dhcpListener{addr: net.JoinHostPort(host, proxyDHCPPort), handler: boot})
+	}
+	if len(addrs) > 1 {
+		var err error
+		if ls, err = demuxDHCPListeners(ls); err != nil {
+			mainlog.Fatal(err)
+		}
+	}
+
+	var listeners []dhcpListener
+	for _, l := range ls {
+		conn, err := s.listenConfig.ListenPacket(context.Background(), "udp4", l.addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.conn.Close()
+			}
+			mainlog.Fatal(errors.Wrapf(err, "listen dhcp on %s", l.addr))
+		}
+		l.conn = conn
+		listeners = append(listeners, l)
 	}
-	defer handler.pool.Stop()
 
+	var wg sync.WaitGroup
+	for _, l := range listeners {
+		l := l
+		if l.handler.proxy == dhcp4.MessageTypeRequest {
+			mainlog.With("addr", l.addr).Info("serving proxy dhcp")
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serve(l)
+		}()
+	}
+	wg.Wait()
+}
+
+// packetConn returns pc reporting the interface packets arrive on, and their destination
+// address if l is demultiplexed.
+func (l dhcpListener) packetConn(pc net.PacketConn) (dhcp4.PacketConn, error) {
+	if l.demux == nil {
+		return dhcp4.NewPacketConn(pc)
+	}
+
+	return l.demux.packetConn(pc)
+}
+
+// dhcpHandler returns the handler of the packets received on l.
+func (l dhcpListener) dhcpHandler() dhcp4.Handler {
+	if l.demux == nil {
+		return l.handler
+	}
+
+	return l.demux
+}
+
+// serve serves DHCP on l until the server is shut down, it is rebound if serving fails.
+func (s *BootsDHCPServer) serve(l dhcpListener) {
+	pc := l.conn
 	err := retry.Do(
 		func() error {
-			return errors.Wrap(dhcp4.ListenAndServe(addr, handler), "serving dhcp")
+			if pc == nil {
+				var err error
+				if pc, err = s.listenConfig.ListenPacket(context.Background(), "udp4", l.addr); err != nil {
+					return errors.Wrap(err, "listen dhcp")
+				}
+			}
+			conn, err := l.packetConn(pc)
+			if err != nil {
+				pc.Close()
+				pc = nil
+
+				return errors.Wrap(err, "listen dhcp")
+			}
+			s.mu.Lock()
+			if s.closed {
+				s.mu.Unlock()
+				pc.Close()
+
+				return nil
+			}
+			s.conns = append(s.conns, pc)
+			s.mu.Unlock()
+
+			err = dhcp4.Serve(conn, l.dhcpHandler())
+			pc = nil
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.closed {
+				return nil
+			}
+
+			return errors.Wrap(err, "serving dhcp")
 		},
 	)
 	if err != nil {
-		mainlog.Fatal(errors.Wrap(err, "retry dhcp serve"))
+		mainlog.Fatal(errors.Wrapf(err, "retry dhcp serve on %s", l.addr))
+	}
+}
+
+// Shutdown stops handling new DHCP packets and waits for in-flight ones to be
+// replied to until ctx is done, then closes the listeners.
+func (s *BootsDHCPServer) Shutdown(ctx context.Context) {
+	if remaining := s.drainer.drain(ctx); len(remaining) > 0 {
+		mainlog.With("inflight", remaining).Info("dhcp shutdown timed out, closing with packets in flight")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for _, conn := range s.conns {
+		conn.Close()
 	}
 }
 
 type dhcpHandler struct {
-	pool         *workerpool.WorkerPool
-	nextServer   net.IP
-	ipxeBaseURL  string
-	bootsBaseURL string
-	jobmanager   job.Manager
+	pool            *workerpool.WorkerPool
+	nextServer      net.IP
+	ipxeBaseURL     string
+	ipxeScheme      string
+	bootsScheme     string
+	bootsBaseURL    string
+	jobmanager      job.Manager
+	invalidMAC      string
+	tracer          *replyTracer
+	packetLog       *packetLogger
+	activeBoots     *activeBoots
+	archPolicy      *dhcp.ArchPolicy
+	ipxeUserClasses ipxe.UserClasses
+	quietPolicy     *job.QuietPolicy
+	relayPolicy     *dhcp.RelayPolicy
+	drainer         *drainer
+	maintenance     *maintenance
+	recentLookups   *recentLookups
+	// authoritative NAKs DHCPREQUESTs for addresses or leases Boots does not own
+	authoritative bool
+	// proxy is the only message type replied to in ProxyDHCP mode, zero when not in ProxyDHCP mode
+	proxy dhcp4.MessageType
+	// listener is the -dhcp-addr address the handler serves, the metrics are labelled with it
+	listener string
 }
 
 func (d dhcpHandler) ServeDHCP(w dhcp4.ReplyWriter, req *dhcp4.Packet) {
-	d.pool.Submit(func() { d.serve(w, req) })
+	if d.proxy != 0 && (req.GetMessageType() != d.proxy || !dhcp.IsPXE(req)) {
+		return
+	}
+	finish, ok := d.drainer.start(req.GetMessageType().String() + " " + req.GetCHAddr().String())
+	if !ok {
+		return
+	}
+	d.pool.Submit(func() { d.serve(w, req, finish) })
 }
 
-func (d dhcpHandler) serve(w dhcp4.ReplyWriter, req *dhcp4.Packet) {
-	mac := req.GetCHAddr()
+// serve handles req, calling finish once it is done, including replying.
+func (d dhcpHandler) serve(w dhcp4.ReplyWriter, req *dhcp4.Packet, finish func()) {
+	replying := false
+	defer func() {
+		if !replying {
+			finish()
+		}
+	}()
+
+	mac, ok := d.lookupMAC(req)
+	if !ok {
+		return
+	}
 	if conf.ShouldIgnoreOUI(mac.String()) {
 		mainlog.With("mac", mac).Info("mac is in ignore list")
 
@@ -74,18 +397,21 @@ func (d dhcpHandler) serve(w dhcp4.ReplyWriter, req *dhcp4.Packet) {
 
 		return
 	}
+	if !d.relayPolicy.Allow(gi, mac) {
+		return
+	}
+	d.packetLog.dhcp(req, mac)
 
-	metrics.DHCPTotal.WithLabelValues("recv", req.GetMessageType().String(), gi.String()).Inc()
+	metrics.DHCPTotal.WithLabelValues("recv", req.GetMessageType().String(), gi.String(), d.listener).Inc()
 	labels := prometheus.Labels{"from": "dhcp", "op": req.GetMessageType().String()}
 	metrics.JobsTotal.With(labels).Inc()
 	metrics.JobsInProgress.With(labels).Inc()
 	timer := prometheus.NewTimer(metrics.JobDuration.With(labels))
+	dhcpTimer := prometheus.NewTimer(metrics.DHCPDuration.WithLabelValues(req.GetMessageType().String(), d.listener))
 
-	circuitID, err := getCircuitID(req)
-	if err != nil {
-		mainlog.With("mac", mac).Error(err, "error parsing option82")
-	} else {
-		mainlog.With("mac", mac, "circuitID", circuitID).Info("parsed option82/circuitid")
+	circuitID, circuitErr := getCircuitID(req)
+	if circuitErr != nil {
+		mainlog.With("mac", mac).Error(circuitErr, "error parsing option82")
 	}
 
 	tracer := otel.Tracer("DHCP")
@@ -96,27 +422,62 @@ func (d dhcpHandler) serve(w dhcp4.ReplyWriter, req *dhcp4.Packet) {
 		trace.WithAttributes(attribute.String("CircuitID", circuitID)),
 	)
 
+	lookupTimer := prometheus.NewTimer(metrics.DHCPLookupDuration.WithLabelValues(req.GetMessageType().String()))
 	ctx, j, err := d.jobmanager.CreateFromDHCP(ctx, mac, gi, circuitID)
+	lookupTimer.ObserveDuration()
+	d.recentLookups.add(err)
 	if err != nil {
-		mainlog.With("type", req.GetMessageType(), "mac", mac).Error(err, "retrieved job is empty")
+		// the relay and circuit tell where a machine without a hardware record is plugged in
+		mainlog.With("type", req.GetMessageType(), "mac", mac, "giaddr", gi, "circuitID", circuitID).Error(err, "retrieved job is empty")
+		metrics.DHCPNoHardware.WithLabelValues(req.GetMessageType().String()).Inc()
 		metrics.JobsInProgress.With(labels).Dec()
 		timer.ObserveDuration()
+		dhcpTimer.ObserveDuration()
 		span.SetStatus(codes.Error, err.Error())
 		span.End()
+		if errors.Is(err, client.ErrNotFound) && d.shouldNAK(req) {
+			d.nak(w, req, mac, nakUnknownClient)
+		}
 
 		return
 	}
+	bootID := d.activeBoots.seen(mac, "", bootStageDHCP, "")
+	d.activeBoots.seenIP(mac, j.IP())
+	d.activeBoots.seenArch(mac, dhcp.Arch(req))
+	tagBoot(span, j, bootID)
 	span.End()
+	// the lookup is needed to know whether the machine is quiet, so option82 is only logged after it
+	j.Quiet = req.GetMessageType() == dhcp4.MessageTypeDiscover && d.quietPolicy.Quiet(*j)
+	if circuitErr == nil {
+		l := j.With("circuitID", circuitID)
+		if j.Quiet {
+			l.Debug("parsed option82/circuitid")
+		} else {
+			l.Info("parsed option82/circuitid")
+		}
+	}
 	j.IpxeBaseURL = d.ipxeBaseURL
+	j.IpxeScheme = d.ipxeScheme
 	j.BootsBaseURL = d.bootsBaseURL
+	j.BootsScheme = d.bootsScheme
 	j.NextServer = d.nextServer
+	j.ArchPolicy = d.archPolicy
+	j.IPXEUserClasses = d.ipxeUserClasses
+	j.ProxyDHCP = d.proxy != 0
+	j.NoBoot = d.maintenance.enabled()
 
+	w = d.tracer.wrap(w, mac)
+	replying = true
 	go func() {
-		ctx, span := tracer.Start(ctx, "DHCP Reply")
-		ok, err := j.ServeDHCP(ctx, w, req)
-		if ok {
-			span.SetStatus(codes.Ok, "DHCPOFFER sent")
-			metrics.DHCPTotal.WithLabelValues("send", "DHCPOFFER", gi.String()).Inc()
+		defer finish()
+		ctx, span := tracer.Start(ctx, "DHCP Reply", trace.WithAttributes(attribute.String("BootID", bootID)))
+		if d.shouldNAK(req) && j.RequestsOtherAddress(req) {
+			d.nak(w, req, mac, nakWrongAddress)
+			span.SetStatus(codes.Ok, "DHCPNAK sent")
+		} else if ok, err := j.ServeDHCP(ctx, w, req); ok {
+			replyType := replyMessageType(req)
+			span.SetStatus(codes.Ok, replyType+" sent")
+			metrics.DHCPTotal.WithLabelValues("send", replyType, gi.String(), d.listener).Inc()
 		} else {
 			if err != nil {
 				j.Error(err)
@@ -128,9 +489,97 @@ func (d dhcpHandler) serve(w dhcp4.ReplyWriter, req *dhcp4.Packet) {
 		span.End()
 		metrics.JobsInProgress.With(labels).Dec()
 		timer.ObserveDuration()
+		dhcpTimer.ObserveDuration()
 	}()
 }
 
+// shouldNAK reports whether req may be NAKed: Boots is authoritative and req is a
+// DHCPREQUEST for an address that is not for another DHCP server, a client selecting
+// another server's offer is left alone.
+func (d dhcpHandler) shouldNAK(req *dhcp4.Packet) bool {
+	if !d.authoritative || d.proxy != 0 || req.GetMessageType() != dhcp4.MessageTypeRequest || dhcp.RequestedIP(req) == nil {
+		return false
+	}
+	if id, ok := req.GetIP(dhcp4.OptionDHCPServerID); ok && !id.Equal(conf.PublicIPv4) {
+		return false
+	}
+
+	return true
+}
+
+// nak sends a DHCPNAK to req, the DHCPREQUEST of mac, so the client restarts discovery.
+func (d dhcpHandler) nak(w dhcp4.ReplyWriter, req *dhcp4.Packet, mac net.HardwareAddr, reason string) {
+	l := mainlog.With("mac", mac, "requested", dhcp.RequestedIP(req), "reason", reason)
+	if err := dhcp.NewNak(w, req, conf.PublicIPv4, nakMessages[reason]).Send(); err != nil {
+		l.Error(err)
+
+		return
+	}
+	metrics.DHCPNAKs.WithLabelValues(reason).Inc()
+	metrics.DHCPTotal.WithLabelValues("send", dhcp4.MessageTypeNak.String(), req.GetGIAddr().String(), d.listener).Inc()
+	l.Info("sent DHCPNAK")
+}
+
+// replyMessageType returns the message type of the reply to req, DHCPACK for a
+// DHCPREQUEST and DHCPOFFER otherwise.
+func replyMessageType(req *dhcp4.Packet) string {
+	if req.GetMessageType() == dhcp4.MessageTypeRequest {
+		return dhcp4.MessageTypeAck.String()
+	}
+
+	return dhcp4.MessageTypeOffer.String()
+}
+
+// lookupMAC returns the MAC to look up hardware by, which is the chaddr unless it is
+// all zero or broadcast. Those are handled according to d.invalidMAC and false is
+// returned if the packet should be ignored.
+func (d dhcpHandler) lookupMAC(req *dhcp4.Packet) (net.HardwareAddr, bool) {
+	mac := req.GetCHAddr()
+	if !isInvalidMAC(mac) {
+		return mac, true
+	}
+
+	if d.invalidMAC == invalidMACClientID {
+		if id, ok := clientIDMAC(req); ok {
+			metrics.DHCPInvalidMAC.WithLabelValues(invalidMACClientID).Inc()
+			mainlog.With("chaddr", mac, "mac", id).Debug("invalid chaddr, using client identifier")
+
+			return id, true
+		}
+	}
+	metrics.DHCPInvalidMAC.WithLabelValues(invalidMACIgnore).Inc()
+	mainlog.With("chaddr", mac, "type", req.GetMessageType()).Debug("ignoring packet with invalid chaddr")
+
+	return nil, false
+}
+
+// isInvalidMAC reports whether mac is empty, all zero or broadcast.
+func isInvalidMAC(mac net.HardwareAddr) bool {
+	zero, broadcast := true, true
+	for _, b := range mac {
+		zero = zero && b == 0x00
+		broadcast = broadcast && b == 0xff
+	}
+
+	return zero || broadcast
+}
+
+// clientIDMAC returns the MAC in the client identifier (option 61), if it is an
+// ethernet type identifier holding a valid MAC.
+func clientIDMAC(req *dhcp4.Packet) (net.HardwareAddr, bool) {
+	id, ok := req.GetOption(dhcp4.OptionClientID)
+	// hardware type 1 is ethernet, followed by the 6 byte MAC
+	if !ok || len(id) != 7 || id[0] != 1 {
+		return nil, false
+	}
+	mac := net.HardwareAddr(id[1:])
+	if isInvalidMAC(mac) {
+		return nil, false
+	}
+
+	return mac, true
+}
+
 func getCircuitID(req *dhcp4.Packet) (string, error) {
 	var circuitID string
 	// Pulling option82 information from the packet (this is the relaying router)
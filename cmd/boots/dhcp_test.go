@@ -1,11 +1,19 @@
 package main
 
 import (
+	"net"
 	"os"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/packethost/dhcp4-go"
 	"github.com/packethost/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/dhcp"
+	"github.com/tinkerbell/boots/job"
 	"github.com/tinkerbell/boots/metrics"
 )
 
@@ -58,6 +66,207 @@ func TestGetCircuitID(t *testing.T) {
 	}
 }
 
+func TestLookupMAC(t *testing.T) {
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	for _, test := range []struct {
+		name       string
+		chaddr     net.HardwareAddr
+		clientID   []byte
+		invalidMAC string
+		want       net.HardwareAddr
+		wantOK     bool
+	}{
+		{
+			name:       "valid chaddr",
+			chaddr:     mac,
+			invalidMAC: invalidMACIgnore,
+			want:       mac,
+			wantOK:     true,
+		},
+		{
+			name:       "zero chaddr ignored",
+			chaddr:     net.HardwareAddr{0, 0, 0, 0, 0, 0},
+			clientID:   append([]byte{1}, mac...),
+			invalidMAC: invalidMACIgnore,
+		},
+		{
+			name:       "broadcast chaddr ignored",
+			chaddr:     net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			invalidMAC: invalidMACIgnore,
+		},
+		{
+			name:       "zero chaddr uses client identifier",
+			chaddr:     net.HardwareAddr{0, 0, 0, 0, 0, 0},
+			clientID:   append([]byte{1}, mac...),
+			invalidMAC: invalidMACClientID,
+			want:       mac,
+			wantOK:     true,
+		},
+		{
+			name:       "zero chaddr without client identifier",
+			chaddr:     net.HardwareAddr{0, 0, 0, 0, 0, 0},
+			invalidMAC: invalidMACClientID,
+		},
+		{
+			name:       "zero chaddr with non ethernet client identifier",
+			chaddr:     net.HardwareAddr{0, 0, 0, 0, 0, 0},
+			clientID:   []byte("\x00my-client"),
+			invalidMAC: invalidMACClientID,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			packet := dhcp4.NewPacket(dhcp4.BootRequest)
+			packet.RawPacket[2] = byte(len(test.chaddr)) // hlen
+			copy(packet.CHAddr(), test.chaddr)
+			if test.clientID != nil {
+				packet.SetOption(dhcp4.OptionClientID, test.clientID)
+			}
+
+			got, ok := dhcpHandler{invalidMAC: test.invalidMAC}.lookupMAC(&packet)
+			if ok != test.wantOK {
+				t.Fatalf("ok: want %v, got %v", test.wantOK, ok)
+			}
+			if got.String() != test.want.String() {
+				t.Fatalf("mac: want %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestReplyMessageType(t *testing.T) {
+	for typ, want := range map[dhcp4.MessageType]string{
+		dhcp4.MessageTypeDiscover: "DHCPOFFER",
+		dhcp4.MessageTypeRequest:  "DHCPACK",
+	} {
+		req := dhcp4.NewPacket(dhcp4.BootRequest)
+		req.SetMessageType(typ)
+		if got := replyMessageType(&req); got != want {
+			t.Fatalf("want %s reply to %s, got %s", want, typ, got)
+		}
+	}
+}
+
+func TestValidateDHCPAddr(t *testing.T) {
+	for addr, wantErr := range map[string]bool{
+		"0.0.0.0:67":           false,
+		"192.168.1.10:67":      false,
+		":67":                  false,
+		"[::]:67":              true,
+		"[2001:db8::10]:67":    true,
+		"192.168.1.10":         true,
+		"boots.example.com:67": true,
+	} {
+		if err := validateDHCPAddr(addr); (err != nil) != wantErr {
+			t.Errorf("%s: want error %t, got: %v", addr, wantErr, err)
+		}
+	}
+}
+
+func TestParseDHCPAddrs(t *testing.T) {
+	tests := map[string]struct {
+		addrs string
+		want  []string
+		err   bool
+	}{
+		"one":       {addrs: "0.0.0.0:67", want: []string{"0.0.0.0:67"}},
+		"many":      {addrs: "192.168.1.10:67, 192.168.2.10:67,", want: []string{"192.168.1.10:67", "192.168.2.10:67"}},
+		"duplicate": {addrs: "192.168.1.10:67,192.168.1.10:67", err: true},
+		"invalid":   {addrs: "192.168.1.10:67,[::]:67", err: true},
+		"empty":     {addrs: " , ", err: true},
+		"wildcard":  {addrs: "0.0.0.0:67,192.168.1.10:67", err: true},
+		"any host":  {addrs: "192.168.1.10:67,:67", err: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseDHCPAddrs(tt.addrs)
+			if (err != nil) != tt.err {
+				t.Fatalf("want error %t, got: %v", tt.err, err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+// recordingReplyWriter keeps the valid replies written.
+type recordingReplyWriter struct {
+	replies []dhcp4.Reply
+}
+
+func (w *recordingReplyWriter) WriteReply(r dhcp4.Reply) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	w.replies = append(w.replies, r)
+
+	return nil
+}
+
+func TestServeDHCPAuthoritative(t *testing.T) {
+	serverID := conf.PublicIPv4
+	conf.PublicIPv4 = net.ParseIP("192.168.1.2")
+	defer func() { conf.PublicIPv4 = serverID }()
+
+	m := job.NewMock(t, "c3.small.x86", "ewr1")
+	m.SetMAC("00:00:ba:dd:be:ef")
+	m.SetDHCP(net.ParseIP("192.168.1.5"), "")
+	j := m.Job()
+	tests := map[string]struct {
+		authoritative bool
+		manager       staticManager
+		requested     string
+		serverID      string
+		wantReason    string
+	}{
+		"unknown client":          {authoritative: true, manager: staticManager{err: errors.WithMessage(client.ErrNotFound, "discover from dhcp message")}, requested: "192.168.1.6", wantReason: nakUnknownClient},
+		"wrong address":           {authoritative: true, manager: staticManager{j: &j}, requested: "192.168.1.6", wantReason: nakWrongAddress},
+		"wrong address to us":     {authoritative: true, manager: staticManager{j: &j}, requested: "192.168.1.6", serverID: "192.168.1.2", wantReason: nakWrongAddress},
+		"another server":          {authoritative: true, manager: staticManager{err: errors.WithMessage(client.ErrNotFound, "discover from dhcp message")}, requested: "192.168.1.6", serverID: "192.168.1.3"},
+		"backend failing":         {authoritative: true, manager: staticManager{err: errors.New("connection refused")}, requested: "192.168.1.6"},
+		"not authoritative":       {manager: staticManager{err: errors.WithMessage(client.ErrNotFound, "discover from dhcp message")}, requested: "192.168.1.6"},
+		"not authoritative wrong": {manager: staticManager{j: &j}, requested: "192.168.1.6"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := dhcp4.NewPacket(dhcp4.BootRequest)
+			req.SetMessageType(dhcp4.MessageTypeRequest)
+			req.RawPacket[2] = 6 // hlen
+			copy(req.CHAddr(), j.PrimaryNIC())
+			req.SetIP(dhcp4.OptionAddressRequest, net.ParseIP(tt.requested))
+			if tt.serverID != "" {
+				req.SetIP(dhcp4.OptionDHCPServerID, net.ParseIP(tt.serverID))
+			}
+			naks := map[string]float64{}
+			for _, reason := range []string{nakUnknownClient, nakWrongAddress} {
+				naks[reason] = testutil.ToFloat64(metrics.DHCPNAKs.WithLabelValues(reason))
+			}
+			w := &recordingReplyWriter{}
+			done := make(chan struct{})
+			dhcpHandler{jobmanager: tt.manager, authoritative: tt.authoritative}.serve(w, &req, func() { close(done) })
+			<-done
+
+			for _, r := range w.replies {
+				if typ := r.Reply().GetMessageType(); (typ == dhcp4.MessageTypeNak) != (tt.wantReason != "") {
+					t.Fatalf("unexpected %v reply", typ)
+				}
+			}
+			if tt.wantReason != "" && len(w.replies) != 1 {
+				t.Fatalf("want 1 DHCPNAK, got %d replies", len(w.replies))
+			}
+			for reason, before := range naks {
+				want := 0.0
+				if reason == tt.wantReason {
+					want = 1
+				}
+				if n := testutil.ToFloat64(metrics.DHCPNAKs.WithLabelValues(reason)) - before; n != want {
+					t.Fatalf("want %v %s NAKs counted, got %v", want, reason, n)
+				}
+			}
+		})
+	}
+}
+
 func TestMain(m *testing.M) {
 	l, err := log.Init("github.com/tinkerbell/boots")
 	if err != nil {
@@ -66,5 +275,6 @@ func TestMain(m *testing.M) {
 	defer l.Close()
 	mainlog = l.Package("main")
 	metrics.Init(l)
+	dhcp.Init(l)
 	os.Exit(m.Run()) //nolint:gocritic // this seems to be the correct pattern
 }
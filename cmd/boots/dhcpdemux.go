@@ -0,0 +1,211 @@
+package main
+
+import (
+	"net"
+	"sync"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+	"github.com/pkg/errors"
+	"golang.org/x/net/ipv4"
+)
+
+// dhcpDemux dispatches the packets received on a wildcard socket to the
+// handler of the -dhcp-addr address they were sent to, or for broadcasts to
+// the handler of the address on the interface they arrived on. A socket bound
+// to a unicast address does not receive the broadcasts of clients without an
+// address, so several addresses sharing a port are served from one socket.
+type dhcpDemux struct {
+	byIP      map[string]dhcp4.Handler
+	byIfIndex map[int]dhcp4.Handler
+
+	// conn is the connection being served, it reports the destination of the
+	// packet last read
+	mu   sync.Mutex
+	conn *demuxConn
+}
+
+// demuxDHCPListeners groups ls by port into one wildcard listener per port
+// demultiplexing to the handlers of ls.
+func demuxDHCPListeners(ls []dhcpListener) ([]dhcpListener, error) {
+	ifaces, err := interfaceIndexes()
+	if err != nil {
+		return nil, err
+	}
+
+	var demuxed []dhcpListener
+	byPort := map[string]int{}
+	for _, l := range ls {
+		host, port, err := net.SplitHostPort(l.addr)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse dhcp address")
+		}
+		ifIndex, ok := ifaces[host]
+		if !ok {
+			return nil, errors.Errorf("no interface has -dhcp-addr %s", host)
+		}
+		i, ok := byPort[port]
+		if !ok {
+			i = len(demuxed)
+			byPort[port] = i
+			demuxed = append(demuxed, dhcpListener{
+				addr:    net.JoinHostPort(net.IPv4zero.String(), port),
+				handler: l.handler,
+				demux:   &dhcpDemux{byIP: map[string]dhcp4.Handler{}, byIfIndex: map[int]dhcp4.Handler{}},
+			})
+		}
+		d := demuxed[i].demux
+		d.byIP[host] = l.handler
+		// the first address on an interface answers the broadcasts received on it
+		if _, ok := d.byIfIndex[ifIndex]; !ok {
+			d.byIfIndex[ifIndex] = l.handler
+		}
+	}
+
+	return demuxed, nil
+}
+
+// interfaceIndexes returns the index of the interface each local IPv4 address is on.
+func interfaceIndexes() (map[string]int, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, errors.Wrap(err, "list interfaces")
+	}
+	indexes := map[string]int{}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, errors.Wrapf(err, "list addresses of %s", iface.Name)
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			indexes[ipNet.IP.String()] = iface.Index
+		}
+	}
+
+	return indexes, nil
+}
+
+// packetConn returns pc reporting the interface and destination of the packets
+// it reads to d.
+func (d *dhcpDemux) packetConn(pc net.PacketConn) (dhcp4.PacketConn, error) {
+	ipv4pc := ipv4.NewPacketConn(pc)
+	if err := ipv4pc.SetControlMessage(ipv4.FlagInterface|ipv4.FlagDst, true); err != nil {
+		return nil, errors.Wrap(err, "enable dhcp control messages")
+	}
+	conn := &demuxConn{PacketConn: pc, ipv4pc: ipv4pc}
+	d.mu.Lock()
+	d.conn = conn
+	d.mu.Unlock()
+
+	return conn, nil
+}
+
+// ServeDHCP hands p to the handler of the address it was received on, packets
+// for none of the addresses are dropped.
+func (d *dhcpDemux) ServeDHCP(w dhcp4.ReplyWriter, p *dhcp4.Packet) {
+	d.mu.Lock()
+	conn := d.conn
+	d.mu.Unlock()
+	// dhcp4.Serve reads the next packet only after this returns, so the
+	// destination read last is p's
+	dst, ifIndex := conn.last()
+	h, ok := d.handler(dst, ifIndex)
+	if !ok {
+		mainlog.With("dst", dst, "ifindex", ifIndex, "mac", p.GetCHAddr()).Debug("no -dhcp-addr for dhcp packet, ignoring")
+
+		return
+	}
+	h.ServeDHCP(w, p)
+}
+
+// handler returns the handler of a packet sent to dst that arrived on the
+// interface with index ifIndex.
+func (d *dhcpDemux) handler(dst net.IP, ifIndex int) (dhcp4.Handler, bool) {
+	if dst != nil {
+		if h, ok := d.byIP[dst.String()]; ok {
+			return h, true
+		}
+		if !dst.Equal(net.IPv4bcast) && !isSubnetBroadcast(dst, ifIndex) {
+			return nil, false
+		}
+	}
+	h, ok := d.byIfIndex[ifIndex]
+
+	return h, ok
+}
+
+// isSubnetBroadcast reports whether dst is the broadcast address of a subnet
+// of the interface with index ifIndex.
+func isSubnetBroadcast(dst net.IP, ifIndex int) bool {
+	iface, err := net.InterfaceByIndex(ifIndex)
+	if err != nil {
+		return false
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false
+	}
+	dst = dst.To4()
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil || len(ipNet.Mask) != net.IPv4len {
+			continue
+		}
+		bcast := make(net.IP, net.IPv4len)
+		for i, b := range ipNet.IP.To4() {
+			bcast[i] = b | ^ipNet.Mask[i]
+		}
+		if bcast.Equal(dst) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// demuxConn is a dhcp4.PacketConn that remembers the destination and interface
+// of the packet it read last.
+type demuxConn struct {
+	net.PacketConn
+	ipv4pc *ipv4.PacketConn
+
+	mu      sync.Mutex
+	dst     net.IP
+	ifIndex int
+}
+
+// ReadFrom reads a packet into b, returning the interface index it arrived on.
+func (c *demuxConn) ReadFrom(b []byte) (int, net.Addr, int, error) {
+	n, cm, src, err := c.ipv4pc.ReadFrom(b)
+	if err != nil {
+		return n, src, -1, err
+	}
+	var (
+		dst     net.IP
+		ifIndex int
+	)
+	if cm != nil {
+		dst, ifIndex = cm.Dst, cm.IfIndex
+	}
+	c.mu.Lock()
+	c.dst, c.ifIndex = dst, ifIndex
+	c.mu.Unlock()
+
+	return n, src, ifIndex, nil
+}
+
+// WriteTo writes b to addr over the interface with index ifIndex.
+func (c *demuxConn) WriteTo(b []byte, addr net.Addr, ifIndex int) (int, error) {
+	return c.ipv4pc.WriteTo(b, &ipv4.ControlMessage{IfIndex: ifIndex}, addr)
+}
+
+// last returns the destination and interface index of the packet read last.
+func (c *demuxConn) last() (net.IP, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.dst, c.ifIndex
+}
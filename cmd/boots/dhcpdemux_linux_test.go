@@ -0,0 +1,124 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/packethost/dhcp4-go"
+	"golang.org/x/sys/unix"
+)
+
+// chanHandler sends the packets it serves on a channel.
+type chanHandler chan string
+
+func (h chanHandler) ServeDHCP(_ dhcp4.ReplyWriter, p *dhcp4.Packet) {
+	h <- p.GetCHAddr().String()
+}
+
+func TestDHCPDemux(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %v", err)
+	}
+	pc, err := net.ListenPacket("udp4", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+	unicast, broadcast := make(chanHandler, 1), make(chanHandler, 1)
+	d := &dhcpDemux{
+		byIP:      map[string]dhcp4.Handler{"127.0.0.1": unicast},
+		byIfIndex: map[int]dhcp4.Handler{lo.Index: broadcast},
+	}
+	conn, err := d.packetConn(pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() { _ = dhcp4.Serve(conn, d) }()
+
+	lc := net.ListenConfig{Control: func(_, _ string, c syscall.RawConn) error {
+		return c.Control(func(fd uintptr) {
+			_ = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_BROADCAST, 1)
+		})
+	}}
+
+	tests := map[string]struct {
+		dst  string
+		want chanHandler
+	}{
+		"unicast":       {dst: "127.0.0.1", want: unicast},
+		"broadcast":     {dst: "127.255.255.255", want: broadcast},
+		"other address": {dst: "127.0.0.3"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := lc.ListenPacket(context.Background(), "udp4", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			mac := net.HardwareAddr{0, 0, 0xba, 0xdd, 0xbe, 0xef}
+			req := dhcp4.NewPacket(dhcp4.BootRequest)
+			req.SetMessageType(dhcp4.MessageTypeDiscover)
+			req.RawPacket[2] = 6 // hlen
+			copy(req.CHAddr(), mac)
+			b, err := dhcp4.PacketToBytes(req, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.WriteTo(b, &net.UDPAddr{IP: net.ParseIP(tt.dst), Port: port}); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, h := range []chanHandler{unicast, broadcast} {
+				select {
+				case got := <-h:
+					if h != tt.want {
+						t.Fatalf("packet for %s served by the wrong handler", tt.dst)
+					}
+					if got != mac.String() {
+						t.Fatalf("want %s, got %s", mac, got)
+					}
+				case <-time.After(200 * time.Millisecond):
+					if h == tt.want {
+						t.Fatalf("packet for %s not served", tt.dst)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDemuxDHCPListeners(t *testing.T) {
+	ls := []dhcpListener{
+		{addr: "127.0.0.1:67", handler: dhcpHandler{listener: "127.0.0.1:67", proxy: dhcp4.MessageTypeDiscover}},
+		{addr: "127.0.0.1:4011", handler: dhcpHandler{listener: "127.0.0.1:67", proxy: dhcp4.MessageTypeRequest}},
+	}
+	got, err := demuxDHCPListeners(ls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("want a listener per port, got %d", len(got))
+	}
+	for i, port := range []int{67, 4011} {
+		if want := net.JoinHostPort("0.0.0.0", strconv.Itoa(port)); got[i].addr != want {
+			t.Fatalf("want %s, got %s", want, got[i].addr)
+		}
+		h, ok := got[i].demux.byIP["127.0.0.1"].(dhcpHandler)
+		if !ok || h.proxy != ls[i].handler.proxy {
+			t.Fatalf("%s: want the handler of %s", got[i].addr, ls[i].addr)
+		}
+	}
+
+	if _, err := demuxDHCPListeners([]dhcpListener{{addr: "192.0.2.200:67"}}); err == nil {
+		t.Fatal("want an error for an address on no interface")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/dhcp"
+	"github.com/tinkerbell/boots/metrics"
+	"golang.org/x/time/rate"
+)
+
+// dhcpTraceAll as the -dhcp-trace-macs value traces replies to every client.
+const dhcpTraceAll = "*"
+
+// replyTracer logs the full option set of DHCP replies sent to selected clients.
+type replyTracer struct {
+	all     bool
+	macs    map[string]bool
+	limiter *rate.Limiter
+}
+
+// newReplyTracer returns a tracer for the comma separated MACs, or every client if macs is "*",
+// that logs at most perSecond replies a second. It returns nil if macs is empty.
+func newReplyTracer(macs string, perSecond float64) (*replyTracer, error) {
+	if macs == "" {
+		return nil, nil
+	}
+	if perSecond <= 0 {
+		return nil, errors.New("-dhcp-trace-rate must be greater than 0")
+	}
+	t := &replyTracer{
+		macs:    map[string]bool{},
+		limiter: rate.NewLimiter(rate.Limit(perSecond), int(perSecond)+1),
+	}
+	for _, s := range strings.Split(macs, ",") {
+		s = strings.TrimSpace(s)
+		if s == dhcpTraceAll {
+			t.all = true
+
+			continue
+		}
+		mac, err := net.ParseMAC(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid -dhcp-trace-macs entry %q", s)
+		}
+		t.macs[mac.String()] = true
+	}
+
+	return t, nil
+}
+
+// wrap returns w, tracing its replies if mac is selected.
+func (t *replyTracer) wrap(w dhcp4.ReplyWriter, mac net.HardwareAddr) dhcp4.ReplyWriter {
+	if t == nil || (!t.all && !t.macs[mac.String()]) {
+		return w
+	}
+
+	return tracingReplyWriter{ReplyWriter: w, tracer: t, mac: mac}
+}
+
+type tracingReplyWriter struct {
+	dhcp4.ReplyWriter
+	tracer *replyTracer
+	mac    net.HardwareAddr
+}
+
+func (w tracingReplyWriter) WriteReply(r dhcp4.Reply) error {
+	if err := w.ReplyWriter.WriteReply(r); err != nil {
+		return err
+	}
+	if !w.tracer.limiter.Allow() {
+		metrics.DHCPTraceDropped.Inc()
+
+		return nil
+	}
+
+	reply := r.Reply()
+	opts := dhcp.TraceOptions(reply.OptionMap)
+	rendered := make([]string, len(opts))
+	for i, o := range opts {
+		rendered[i] = o.String()
+	}
+	mainlog.With("mac", w.mac, "type", reply.GetMessageType(), "yiaddr", reply.GetYIAddr(),
+		"siaddr", reply.GetSIAddr(), "file", string(bytes.TrimRight(reply.File(), "\x00")), "options", rendered).Info("dhcp reply trace")
+
+	return nil
+}
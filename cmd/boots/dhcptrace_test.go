@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+)
+
+type nopReplyWriter struct{}
+
+func (nopReplyWriter) WriteReply(dhcp4.Reply) error { return nil }
+
+func TestReplyTracer(t *testing.T) {
+	traced, _ := net.ParseMAC("00:00:ba:dd:be:ef")
+	other, _ := net.ParseMAC("00:00:ba:dd:be:e0")
+
+	tests := map[string]struct {
+		macs       string
+		rate       float64
+		wantErr    bool
+		wantNil    bool
+		wantTraced map[string]bool
+	}{
+		"off":          {macs: "", rate: 1, wantNil: true},
+		"off bad rate": {macs: "", rate: 0, wantNil: true},
+		"single mac":   {macs: "00:00:BA:DD:BE:EF", rate: 1, wantTraced: map[string]bool{traced.String(): true, other.String(): false}},
+		"all":          {macs: "*", rate: 1, wantTraced: map[string]bool{traced.String(): true, other.String(): true}},
+		"invalid mac":  {macs: "00:00:ba:dd:be:ef, nope", rate: 1, wantErr: true},
+		"zero rate":    {macs: "*", rate: 0, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tracer, err := newReplyTracer(tt.macs, tt.rate)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantNil {
+				if tracer != nil {
+					t.Fatal("expected a nil tracer")
+				}
+			}
+			for _, mac := range []net.HardwareAddr{traced, other} {
+				_, got := tracer.wrap(nopReplyWriter{}, mac).(tracingReplyWriter)
+				if got != tt.wantTraced[mac.String()] {
+					t.Fatalf("%s: want traced %v, got %v", mac, tt.wantTraced[mac.String()], got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// drainer tracks in-flight requests so shutdown can wait for them to finish, and
+// rejects new requests once draining has started.
+type drainer struct {
+	mu       sync.Mutex
+	draining bool
+	next     uint64
+	// inflight describes each in-flight request, for logging if draining times out
+	inflight map[uint64]string
+	// idle is closed when draining and the last in-flight request finishes
+	idle chan struct{}
+}
+
+func newDrainer() *drainer {
+	return &drainer{inflight: map[uint64]string{}}
+}
+
+// start records a new in-flight request described by desc. It returns false if
+// draining has started, otherwise finish must be called once the request is done.
+func (d *drainer) start(desc string) (finish func(), ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return nil, false
+	}
+	id := d.next
+	d.next++
+	d.inflight[id] = desc
+
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.inflight, id)
+		if d.idle != nil && len(d.inflight) == 0 {
+			close(d.idle)
+			d.idle = nil
+		}
+	}, true
+}
+
+// drain stops new requests from starting and waits for the in-flight ones to
+// finish or ctx to be done. It returns the descriptions of the requests that
+// were still in flight when ctx was done.
+func (d *drainer) drain(ctx context.Context) []string {
+	d.mu.Lock()
+	d.draining = true
+	if len(d.inflight) == 0 {
+		d.mu.Unlock()
+
+		return nil
+	}
+	if d.idle == nil {
+		d.idle = make(chan struct{})
+	}
+	idle := d.idle
+	d.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	remaining := make([]string, 0, len(d.inflight))
+	for _, desc := range d.inflight {
+		remaining = append(remaining, desc)
+	}
+	sort.Strings(remaining)
+
+	return remaining
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDrainer(t *testing.T) {
+	d := newDrainer()
+	finish1, ok := d.start("one")
+	if !ok {
+		t.Fatal("start before drain refused")
+	}
+	finish2, _ := d.start("two")
+
+	done := make(chan []string)
+	go func() { done <- d.drain(context.Background()) }()
+
+	// wait for draining to start
+	for {
+		finish, ok := d.start("three")
+		if !ok {
+			break
+		}
+		finish()
+		time.Sleep(time.Millisecond)
+	}
+	finish1()
+	finish2()
+
+	select {
+	case remaining := <-done:
+		if len(remaining) != 0 {
+			t.Fatalf("want nothing remaining, got %v", remaining)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain did not return after in-flight requests finished")
+	}
+}
+
+func TestDrainerTimeout(t *testing.T) {
+	d := newDrainer()
+	d.start("b")
+	finish, _ := d.start("c")
+	d.start("a")
+	finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if diff := cmp.Diff([]string{"a", "b"}, d.drain(ctx)); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestDrainerIdle(t *testing.T) {
+	d := newDrainer()
+	if remaining := d.drain(context.Background()); remaining != nil {
+		t.Fatalf("want nil, got %v", remaining)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// drainPath shows and toggles drain mode, see serveDrain.
+const drainPath = "/_packet/drain"
+
+// drainReason describes the job files refused in drain mode.
+const drainReason = "This Boots instance is draining for a restart, boot from another instance"
+
+// drainMode is whether Boots is draining for a rolling restart: it reports not ready so
+// load balancers stop sending it traffic and refuses new job files with a 503, while
+// the requests in flight finish.
+type drainMode struct {
+	// on is 1 while draining, accessed atomically
+	on int32
+}
+
+// enabled reports whether Boots is draining, false if d is nil.
+func (d *drainMode) enabled() bool {
+	return d != nil && atomic.LoadInt32(&d.on) == 1
+}
+
+// set starts or stops draining, returns whether it changed.
+func (d *drainMode) set(on bool) bool {
+	var v int32
+	if on {
+		v = 1
+	}
+	metrics.Draining.Set(float64(v))
+
+	return atomic.SwapInt32(&d.on, v) != v
+}
+
+// serveDrain responds with whether Boots is draining as JSON, a POST starts draining
+// first, or with the enabled form value false stops it, e.g. to roll back a rollout. It
+// is served behind requireToken with the drain token.
+func (s *BootsHTTPServer) serveDrain(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		on := true
+		if v := req.FormValue("enabled"); v != "" {
+			var err error
+			if on, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "invalid enabled, must be true or false", http.StatusBadRequest)
+
+				return
+			}
+		}
+		if s.draining.set(on) {
+			mainlog.With("client", req.RemoteAddr, "draining", on).Info("drain mode changed")
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		Draining bool `json:"draining"`
+	}{s.draining.enabled()}); err != nil {
+		mainlog.Error(errors.Wrap(err, "marshaling drain json"))
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestServeDrain(t *testing.T) {
+	s := &BootsHTTPServer{draining: &drainMode{}}
+	tests := []struct {
+		name       string
+		method     string
+		form       url.Values
+		wantStatus int
+		want       bool
+	}{
+		{name: "show", method: http.MethodGet, wantStatus: http.StatusOK},
+		{name: "drain", method: http.MethodPost, wantStatus: http.StatusOK, want: true},
+		{name: "invalid", method: http.MethodPost, form: url.Values{"enabled": {"maybe"}}, wantStatus: http.StatusBadRequest, want: true},
+		{name: "method", method: http.MethodDelete, wantStatus: http.StatusMethodNotAllowed, want: true},
+		{name: "stop", method: http.MethodPost, form: url.Values{"enabled": {"false"}}, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, drainPath, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			s.serveDrain(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if s.draining.enabled() != tt.want {
+				t.Fatalf("want draining %t", tt.want)
+			}
+			if gauge := testutil.ToFloat64(metrics.Draining); (gauge == 1) != tt.want {
+				t.Fatalf("want draining gauge %t, got %v", tt.want, gauge)
+			}
+		})
+	}
+}
+
+func TestServeJobFileDraining(t *testing.T) {
+	// the lookup is never made, hungManager would block the request
+	h := &jobHandler{jobManager: hungManager{}, draining: &drainMode{}, shedder: testShedder(t), problems: true}
+	h.draining.set(true)
+	defer h.draining.set(false)
+
+	for _, path := range []string{"/auto.ipxe", "/undionly.kpxe"} {
+		for _, accept := range []string{"", "application/problem+json"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Accept", accept)
+			w := httptest.NewRecorder()
+			h.serveJobFile(w, req)
+			if w.Code != http.StatusServiceUnavailable {
+				t.Fatalf("want status %d for %s, got %d", http.StatusServiceUnavailable, path, w.Code)
+			}
+			if got := w.Header().Get("Retry-After"); got != "2" {
+				t.Fatalf("%s, accept %q: want Retry-After: 2, got %q", path, accept, got)
+			}
+		}
+	}
+}
+
+func TestServeReadinessDraining(t *testing.T) {
+	s := &BootsHTTPServer{draining: &drainMode{}}
+	for _, draining := range []bool{false, true, false} {
+		s.draining.set(draining)
+		w := httptest.NewRecorder()
+		s.serveReadiness(w, httptest.NewRequest(http.MethodGet, "/readiness", nil))
+		var res readiness
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		wantStatus := http.StatusOK
+		if draining {
+			wantStatus = http.StatusServiceUnavailable
+		}
+		if w.Code != wantStatus || res.Ready == draining || res.Draining != draining {
+			t.Fatalf("draining %t: want status %d, got %d: %s", draining, wantStatus, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// loadFallbackScript returns the iPXE script of -fallback-ipxe-script, given inline,
+// starting with #!ipxe, or as the path of a file with it. Returns nil if v is empty.
+func loadFallbackScript(v string) ([]byte, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(v), "#!ipxe") {
+		return []byte(strings.TrimSpace(v) + "\n"), nil
+	}
+	script, err := os.ReadFile(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "read -fallback-ipxe-script")
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(script), []byte("#!ipxe")) {
+		return nil, errors.Errorf("-fallback-ipxe-script %s is not an iPXE script, it must start with #!ipxe", v)
+	}
+
+	return script, nil
+}
+
+// serveFallbackScript responds with the fallback script to a boot script request whose
+// hardware lookup failed with err, for reason, timeout or error. Returns false without
+// responding if there is no fallback script or req is not for a boot script.
+func (h *jobHandler) serveFallbackScript(w http.ResponseWriter, req *http.Request, reason string, err error) bool {
+	if h.fallbackScript == nil || path.Ext(req.URL.Path) != ".ipxe" {
+		return false
+	}
+	metrics.FallbackScripts.WithLabelValues(reason).Inc()
+	mainlog.With("client", req.RemoteAddr, "path", req.URL.Path, "reason", reason).Error(err, "hardware lookup failed, serving the fallback script")
+	if _, err := w.Write(h.fallbackScript); err != nil {
+		mainlog.Error(errors.Wrap(err, "unable to write fallback script"))
+	}
+
+	return true
+}
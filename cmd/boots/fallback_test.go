@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/client/standalone"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestLoadFallbackScript(t *testing.T) {
+	dir := t.TempDir()
+	script, notScript := filepath.Join(dir, "rescue.ipxe"), filepath.Join(dir, "rescue.txt")
+	if err := os.WriteFile(script, []byte("#!ipxe\nchain http://rescue/boot.ipxe\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(notScript, []byte("chain http://rescue/boot.ipxe\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		v       string
+		want    string
+		wantErr bool
+	}{
+		"off":          {},
+		"inline":       {v: "#!ipxe\nshell", want: "#!ipxe\nshell\n"},
+		"file":         {v: script, want: "#!ipxe\nchain http://rescue/boot.ipxe\n"},
+		"not a script": {v: notScript, wantErr: true},
+		"missing":      {v: filepath.Join(dir, "missing.ipxe"), wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := loadFallbackScript(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got: %v", tt.wantErr, err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("want script %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// recordFinder is a hardware backend that finds d for every lookup.
+type recordFinder struct {
+	client.HardwareFinder
+	d client.Discoverer
+}
+
+func (f recordFinder) ByIP(context.Context, net.IP) (client.Discoverer, error) { return f.d, nil }
+
+func TestServeJobFileFallback(t *testing.T) {
+	fallback := []byte("#!ipxe\nshell\n")
+	// a record without an id, denied by validation
+	mac := client.MACAddr{}
+	_ = mac.UnmarshalText([]byte("00:00:ba:dd:be:ef"))
+	denied := job.NewCreator(mainlog, "", recordFinder{d: &standalone.DiscoverStandalone{HardwareStandalone: standalone.HardwareStandalone{
+		Network: client.Network{Interfaces: []client.NetworkInterface{{DHCP: client.DHCP{MAC: &mac, IP: client.IP{Address: net.ParseIP("192.0.2.1")}, Arch: "x86_64"}}}},
+	}}})
+	v, err := job.NewRecordValidation(job.MissingFieldDeny, job.RecordDefaults{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	denied.SetRecordValidation(v)
+	tests := map[string]struct {
+		manager    job.Manager
+		path       string
+		wantStatus int
+		reason     string
+	}{
+		"backend failing":   {manager: staticManager{err: &job.LookupError{Err: errors.New("connection refused")}}, path: "/auto.ipxe", wantStatus: http.StatusOK, reason: "error"},
+		"lookup timeout":    {manager: hungManager{}, path: "/auto.ipxe", wantStatus: http.StatusOK, reason: "timeout"},
+		"not found":         {manager: staticManager{err: errors.WithMessage(client.ErrNotFound, "lookup")}, path: "/auto.ipxe", wantStatus: http.StatusNotFound},
+		"validation denied": {manager: denied, path: "/auto.ipxe", wantStatus: http.StatusNotFound},
+		"not a script":      {manager: staticManager{err: &job.LookupError{Err: errors.New("connection refused")}}, path: "/favicon.ico", wantStatus: http.StatusBadGateway},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &jobHandler{jobManager: tt.manager, lookupTimeout: 10 * time.Millisecond, fallbackScript: fallback}
+			timeouts, errs := testutil.ToFloat64(metrics.FallbackScripts.WithLabelValues("timeout")), testutil.ToFloat64(metrics.FallbackScripts.WithLabelValues("error"))
+			w := httptest.NewRecorder()
+			h.serveJobFile(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, w.Code)
+			}
+			served := testutil.ToFloat64(metrics.FallbackScripts.WithLabelValues("timeout")) - timeouts + testutil.ToFloat64(metrics.FallbackScripts.WithLabelValues("error")) - errs
+			if tt.reason == "" {
+				if served != 0 || w.Body.Len() != 0 {
+					t.Fatalf("want no fallback script, got %v served: %q", served, w.Body)
+				}
+
+				return
+			}
+			if w.Body.String() != string(fallback) {
+				t.Fatalf("want the fallback script, got %q", w.Body)
+			}
+			if n := testutil.ToFloat64(metrics.FallbackScripts.WithLabelValues(tt.reason)) - map[string]float64{"timeout": timeouts, "error": errs}[tt.reason]; n != 1 {
+				t.Fatalf("want 1 fallback script served for %s, got %v", tt.reason, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// connContextKey is the request context key of the connection the request came in on.
+type connContextKey struct{}
+
+// withConn is the http.Server ConnContext that makes a request's connection available to
+// fileDeadline.
+func withConn(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
+
+// fileDeadline aborts iPXE script and binary transfers that take longer than timeout
+// overall, or that make no progress writing for idle, cancelling the request's context
+// and the write to the client. A nil fileDeadline does not bound transfers.
+type fileDeadline struct {
+	timeout time.Duration
+	idle    time.Duration
+	// writeTimeout is the http.Server WriteTimeout, restored once a transfer finishes
+	writeTimeout time.Duration
+}
+
+// newFileDeadline returns a fileDeadline, or nil if timeout and idle are both 0.
+func newFileDeadline(timeout, idle, writeTimeout time.Duration) (*fileDeadline, error) {
+	if timeout < 0 {
+		return nil, errors.New("-file-serve-timeout must not be negative")
+	}
+	if idle < 0 {
+		return nil, errors.New("-file-serve-idle-timeout must not be negative")
+	}
+	if timeout == 0 && idle == 0 {
+		return nil, nil
+	}
+
+	return &fileDeadline{timeout: timeout, idle: idle, writeTimeout: writeTimeout}, nil
+}
+
+// wrap returns h with its transfers bounded, h is returned as is if d is nil.
+func (d *fileDeadline) wrap(h http.HandlerFunc) http.HandlerFunc {
+	if d == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		ctx, cancel := context.WithCancel(req.Context())
+		fw := &deadlineWriter{ResponseWriter: w, cancel: cancel, idle: d.idle}
+		if d.timeout > 0 {
+			fw.deadline = start.Add(d.timeout)
+			ctx, cancel = context.WithDeadline(ctx, fw.deadline)
+		}
+		defer cancel()
+		if d.writeTimeout > 0 && (fw.deadline.IsZero() || start.Add(d.writeTimeout).Before(fw.deadline)) {
+			fw.deadline = start.Add(d.writeTimeout)
+		}
+		fw.conn, _ = req.Context().Value(connContextKey{}).(net.Conn)
+
+		h(fw, req.WithContext(ctx))
+
+		if fw.aborted == "" && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			fw.aborted = "timeout"
+		}
+		if fw.aborted != "" {
+			metrics.FileTransfersAborted.With(prometheus.Labels{"reason": fw.aborted}).Inc()
+			mainlog.With("client", req.RemoteAddr, "path", req.URL.Path, "reason", fw.aborted, "written", fw.written).Info("file transfer aborted")
+
+			return
+		}
+		// later requests on the connection are only bounded by the server's write timeout
+		if fw.conn != nil {
+			var restore time.Time
+			if d.writeTimeout > 0 {
+				restore = start.Add(d.writeTimeout)
+			}
+			_ = fw.conn.SetWriteDeadline(restore)
+		}
+	}
+}
+
+// deadlineWriter moves the write deadline of the connection forward by idle on every
+// write, bounded by deadline, and records why a write timed out.
+type deadlineWriter struct {
+	http.ResponseWriter
+	conn     net.Conn
+	cancel   context.CancelFunc
+	deadline time.Time
+	idle     time.Duration
+	written  int64
+	// aborted is why the transfer was aborted, timeout or idle, empty if it was not
+	aborted string
+}
+
+func (w *deadlineWriter) Write(b []byte) (int, error) {
+	if w.conn != nil {
+		next := w.deadline
+		if now := time.Now(); w.idle > 0 && (next.IsZero() || now.Add(w.idle).Before(next)) {
+			next = now.Add(w.idle)
+		}
+		_ = w.conn.SetWriteDeadline(next)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	var netErr net.Error
+	if err != nil && errors.As(err, &netErr) && netErr.Timeout() && w.aborted == "" {
+		w.aborted = "idle"
+		if !w.deadline.IsZero() && !time.Now().Before(w.deadline) {
+			w.aborted = "timeout"
+		}
+		w.cancel()
+	}
+
+	return n, err
+}
+
+// Flush flushes the underlying ResponseWriter if it supports it, see http.Flusher.
+func (w *deadlineWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
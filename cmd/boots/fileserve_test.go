@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// serveFile serves h, bounded by d, and returns its address.
+func serveFile(t *testing.T, d *fileDeadline, h http.HandlerFunc) string {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(d.wrap(h))
+	srv.Config.ConnContext = withConn
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	return srv.Listener.Addr().String()
+}
+
+func TestFileDeadlineIdle(t *testing.T) {
+	d, err := newFileDeadline(0, 50*time.Millisecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	addr := serveFile(t, d, func(w http.ResponseWriter, req *http.Request) {
+		chunk := make([]byte, 1<<20)
+		for {
+			if _, err := w.Write(chunk); err != nil {
+				done <- req.Context().Err()
+
+				return
+			}
+		}
+	})
+	idle := testutil.ToFloat64(metrics.FileTransfersAborted.WithLabelValues("idle"))
+
+	// the client requests the file and never reads it
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /vmlinuz HTTP/1.1\r\nHost: boots\r\n\r\n")
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("want the request context canceled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the stalled transfer aborted")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := testutil.ToFloat64(metrics.FileTransfersAborted.WithLabelValues("idle")) - idle; n != 1 {
+		t.Fatalf("want 1 idle transfer aborted, got %v", n)
+	}
+}
+
+func TestFileDeadlineProgressing(t *testing.T) {
+	d, err := newFileDeadline(time.Minute, 100*time.Millisecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serveFile(t, d, func(w http.ResponseWriter, _ *http.Request) {
+		// slower overall than the idle timeout, but always making progress
+		for i := 0; i < 6; i++ {
+			_, _ = w.Write([]byte("chunk\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(40 * time.Millisecond)
+		}
+	})
+	resp, err := http.Get("http://" + addr + "/auto.ipxe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil || len(b) != 6*len("chunk\n") {
+		t.Fatalf("want the whole transfer, got %d bytes, %v", len(b), err)
+	}
+}
+
+func TestFileDeadlineTimeout(t *testing.T) {
+	d, err := newFileDeadline(50*time.Millisecond, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeouts := testutil.ToFloat64(metrics.FileTransfersAborted.WithLabelValues("timeout"))
+	addr := serveFile(t, d, func(w http.ResponseWriter, req *http.Request) {
+		for req.Context().Err() == nil {
+			_, _ = w.Write([]byte("chunk\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+	resp, err := http.Get("http://" + addr + "/ipxe/ipxe.efi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+	for {
+		if _, err := r.ReadString('\n'); err != nil {
+			break
+		}
+	}
+	// the abort is counted once the handler returns, which may be after the client saw the connection close
+	var n float64
+	for end := time.Now().Add(time.Second); time.Now().Before(end); time.Sleep(5 * time.Millisecond) {
+		if n = testutil.ToFloat64(metrics.FileTransfersAborted.WithLabelValues("timeout")) - timeouts; n == 1 {
+			break
+		}
+	}
+	if n != 1 {
+		t.Fatalf("want 1 transfer timed out, got %v", n)
+	}
+}
+
+func TestNewFileDeadline(t *testing.T) {
+	if d, err := newFileDeadline(0, 0, time.Minute); d != nil || err != nil {
+		t.Fatalf("want no deadline, got %v, %v", d, err)
+	}
+	if _, err := newFileDeadline(-time.Second, 0, 0); err == nil {
+		t.Fatal("want error for negative timeout")
+	}
+	if _, err := newFileDeadline(0, -time.Second, 0); err == nil {
+		t.Fatal("want error for negative idle timeout")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// acceptsGzip reports whether the Accept-Encoding headers of req accept gzip, with a
+// q-value greater than 0.
+func acceptsGzip(req *http.Request) bool {
+	for _, accept := range req.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(accept, ",") {
+			coding, params, _ := strings.Cut(part, ";")
+			if coding = strings.ToLower(strings.TrimSpace(coding)); coding != "gzip" && coding != "x-gzip" {
+				continue
+			}
+			q := 1.0
+			if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+				var err error
+				if q, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+					continue
+				}
+			}
+
+			return q > 0
+		}
+	}
+
+	return false
+}
+
+// gzipScript returns w compressing the iPXE script written to it with gzip if req accepts
+// it, and a func that must be called once the script is written to finish it. Only 200
+// responses are compressed, others have no body worth it. Vary: Accept-Encoding is set
+// either way so caches keep both.
+func gzipScript(w http.ResponseWriter, req *http.Request) (http.ResponseWriter, func()) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(req) {
+		return w, func() {}
+	}
+	gw := &gzipWriter{ResponseWriter: w}
+
+	return gw, func() {
+		if gw.gz == nil {
+			return
+		}
+		if err := gw.gz.Close(); err != nil {
+			mainlog.With("client", req.RemoteAddr, "path", req.URL.Path).Error(errors.Wrap(err, "unable to finish gzip script"))
+		}
+	}
+}
+
+// gzipWriter compresses the body of a 200 response with gzip.
+type gzipWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if code == http.StatusOK {
+			header := w.Header()
+			// the length of the script is not the length of the compressed body, and the
+			// type would be sniffed from the compressed body
+			header.Del("Content-Length")
+			if header.Get("Content-Type") == "" {
+				header.Set("Content-Type", "text/plain; charset=utf-8")
+			}
+			header.Set("Content-Encoding", "gzip")
+			w.gz = gzip.NewWriter(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.gz.Write(b)
+}
+
+// Flush flushes the compressed data written so far and the underlying ResponseWriter if it
+// supports it, see http.Flusher.
+func (w *gzipWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := map[string]bool{
+		"":                    false,
+		"gzip":                true,
+		"GZIP":                true,
+		"deflate, gzip":       true,
+		"gzip;q=0.5":          true,
+		"br, gzip ; q=0":      false,
+		"identity":            false,
+		"deflate, x-gzip":     true,
+		"gzip;q=nope, x-gzip": true,
+	}
+	for accept, want := range tests {
+		t.Run(accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+			req.Header.Set("Accept-Encoding", accept)
+			if got := acceptsGzip(req); got != want {
+				t.Fatalf("want %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestServeJobFileGzip(t *testing.T) {
+	h := &jobHandler{jobManager: staticManager{err: errors.New("no hardware")}, errorScripts: true}
+	for _, tt := range []struct {
+		accept   string
+		wantGzip bool
+	}{
+		{},
+		{accept: "gzip", wantGzip: true},
+		{accept: "gzip;q=0"},
+	} {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+			req.Header.Set("Accept-Encoding", tt.accept)
+			req.RemoteAddr = "192.0.2.10:4321"
+			w := httptest.NewRecorder()
+			h.serveJobFile(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("want status 200, got %d", w.Code)
+			}
+			if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Fatalf("want Vary: Accept-Encoding, got %q", vary)
+			}
+			var body io.Reader = w.Body
+			if encoding := w.Header().Get("Content-Encoding"); tt.wantGzip != (encoding == "gzip") {
+				t.Fatalf("want gzip %v, got Content-Encoding %q", tt.wantGzip, encoding)
+			}
+			if tt.wantGzip {
+				gz, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = gz
+				if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+					t.Fatalf("want a text/plain script, got %q", ct)
+				}
+			}
+			script, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(script), "#!ipxe") || !strings.Contains(string(script), "No hardware record found") {
+				t.Fatalf("want the error script, got:\n%s", script)
+			}
+		})
+	}
+}
+
+func TestGzipScript(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	// a script with its length set is sent compressed without it
+	w := httptest.NewRecorder()
+	gw, finish := gzipScript(w, req)
+	gw.Header().Set("Content-Length", "12")
+	_, _ = gw.Write([]byte("#!ipxe\nexit\n"))
+	finish()
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Length") != "" {
+		t.Fatalf("want a compressed body without Content-Length, got headers %v", w.Header())
+	}
+
+	// errors are sent as is
+	w = httptest.NewRecorder()
+	gw, finish = gzipScript(w, req)
+	gw.WriteHeader(http.StatusNotFound)
+	finish()
+	if w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 0 {
+		t.Fatalf("want an empty uncompressed 404, got headers %v and %d bytes", w.Header(), w.Body.Len())
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// serverHeaders are the headers that describe the response and how it is transferred,
+// set by Boots or net/http, they can not be added with -http-extra-headers.
+var serverHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Transfer-Encoding": true,
+	"Trailer":           true,
+	"Upgrade":           true,
+}
+
+// headerValues is a flag.Value of HTTP headers, a "Key: Value" each, that can be given
+// more than once. A value with several lines has a header per line.
+type headerValues []string
+
+func (h *headerValues) String() string {
+	if h == nil {
+		return ""
+	}
+
+	return strings.Join(*h, "\n")
+}
+
+func (h *headerValues) Set(v string) error {
+	for _, line := range strings.Split(v, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			*h = append(*h, line)
+		}
+	}
+
+	return nil
+}
+
+// Get returns the headers, so the effective configuration lists them, see flag.Getter.
+func (h *headerValues) Get() interface{} {
+	if *h == nil {
+		return []string{}
+	}
+
+	return []string(*h)
+}
+
+// extraHeaders are headers added to the responses of a handler, see -http-extra-headers.
+type extraHeaders http.Header
+
+// parseExtraHeaders parses "Key: Value" headers, returns an error for an invalid header
+// name or value, which includes values with a CR or LF, or for a header in serverHeaders.
+// Returns nil if there are no headers.
+func parseExtraHeaders(values []string) (extraHeaders, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	h := http.Header{}
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || !validHeaderName(key) {
+			return nil, errors.Errorf("invalid -http-extra-headers header %q, must be Key: Value", v)
+		}
+		if !validHeaderValue(value) {
+			return nil, errors.Errorf("invalid -http-extra-headers value for %s, must not have control characters", key)
+		}
+		key = textproto.CanonicalMIMEHeaderKey(key)
+		if serverHeaders[key] {
+			return nil, errors.Errorf("invalid -http-extra-headers header %s, it is set by the server", key)
+		}
+		h.Add(key, value)
+	}
+
+	return extraHeaders(h), nil
+}
+
+// validHeaderName reports whether name is an RFC 7230 token.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", c)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validHeaderValue reports whether value has no control characters other than tab.
+func validHeaderValue(value string) bool {
+	for _, c := range value {
+		if c == 0x7f || (c < ' ' && c != '\t') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// wrap returns h with the extra headers added to its responses, unless h sets them
+// itself. h is returned as is if there are no extra headers.
+func (e extraHeaders) wrap(h http.HandlerFunc) http.HandlerFunc {
+	if len(e) == 0 {
+		return h
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		h(&headerWriter{ResponseWriter: w, extra: e}, req)
+	}
+}
+
+// headerWriter adds the extra headers that are not set yet when the response header is written.
+type headerWriter struct {
+	http.ResponseWriter
+	extra       extraHeaders
+	wroteHeader bool
+}
+
+func (w *headerWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		header := w.Header()
+		for key, values := range w.extra {
+			if _, set := header[key]; !set {
+				header[key] = values
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *headerWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the underlying ResponseWriter if it supports it, see http.Flusher.
+func (w *headerWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
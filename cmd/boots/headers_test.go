@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseExtraHeaders(t *testing.T) {
+	tests := map[string]struct {
+		values []string
+		want   extraHeaders
+		err    bool
+	}{
+		"none":           {},
+		"headers":        {values: []string{"Cache-Control: no-store", "x-cdn-tag:  boots ", "X-Cdn-Tag: ewr1"}, want: extraHeaders{"Cache-Control": {"no-store"}, "X-Cdn-Tag": {"boots", "ewr1"}}},
+		"empty value":    {values: []string{"X-Empty:"}, want: extraHeaders{"X-Empty": {""}}},
+		"no colon":       {values: []string{"Cache-Control no-store"}, err: true},
+		"no name":        {values: []string{": no-store"}, err: true},
+		"space in name":  {values: []string{"Cache Control: no-store"}, err: true},
+		"crlf injection": {values: []string{"X-Tag: a\r\nSet-Cookie: session=1"}, err: true},
+		"nul":            {values: []string{"X-Tag: a\x00b"}, err: true},
+		"content type":   {values: []string{"content-type: text/html"}, err: true},
+		"content length": {values: []string{"Content-Length: 0"}, err: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseExtraHeaders(tt.values)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("want error, got %v", got)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestHeaderValues(t *testing.T) {
+	var h headerValues
+	for _, v := range []string{"Cache-Control: no-store", "X-A: 1\nX-B: 2\n"} {
+		if err := h.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if diff := cmp.Diff(headerValues{"Cache-Control: no-store", "X-A: 1", "X-B: 2"}, h); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestExtraHeadersWrap(t *testing.T) {
+	e, err := parseExtraHeaders([]string{"Cache-Control: no-store", "X-Boot-ID: spoofed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := e.wrap(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(bootIDHeader, "0123456789abcdef")
+		_, _ = w.Write([]byte("#!ipxe\n"))
+	})
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil))
+
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Fatalf("want Cache-Control no-store, got %q", got)
+	}
+	// headers set by Boots are not overridden
+	if got := w.Header().Values(bootIDHeader); len(got) != 1 || got[0] != "0123456789abcdef" {
+		t.Fatalf("want the boot ID header kept, got %q", got)
+	}
+}
@@ -1,28 +1,144 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"io"
+	"net"
 	"net/http"
-	"net/http/pprof"
+	"os"
+	"path"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sebest/xff"
 	"github.com/tinkerbell/boots/client"
 	"github.com/tinkerbell/boots/conf"
 	"github.com/tinkerbell/boots/httplog"
 	"github.com/tinkerbell/boots/job"
 	"github.com/tinkerbell/boots/metrics"
+	"github.com/tinkerbell/boots/syslog"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type BootsHTTPServer struct {
+	// activeConns is the number of open HTTP connections, accessed atomically
+	activeConns    int64
 	workflowFinder client.WorkflowFinder
-	finder         client.HardwareFinder
-	jobManager     job.Manager
+	// workflowBackend is workflowFinder without its retries and circuit breaker, pinged by the readiness check
+	workflowBackend   client.WorkflowFinder
+	finder            client.HardwareFinder
+	jobManager        job.Manager
+	listenConfig      net.ListenConfig
+	tlsConfig         *tls.Config
+	bootFailures      *bootFailureRecorder
+	noNetbootResponse string
+	syslogHub         *syslog.Hub
+	syslogStreamToken string
+	// configToken enables the /_packet/config endpoint, clients must present it as a bearer token
+	configToken string
+	// config is the effective configuration served by /_packet/config, see effectiveConfig
+	config *conf.Reloadable[map[string]interface{}]
+	// simulateToken enables the /_packet/simulate endpoint, clients must present it as a bearer token
+	simulateToken  string
+	phoneHomes     *phoneHomeBatcher
+	phoneHomeDedup *phoneHomeDeduper
+	// phoneHomeWebhook is notified of processed phone-homes, nil if no webhook is configured
+	phoneHomeWebhook *phoneHomeWebhook
+	// phoneHomeUnknownStatus is the response code to phone-homes no job is found for, 0 is 200
+	phoneHomeUnknownStatus int
+	// phoneHomeDuplicate is the response to phone-homes suppressed by phoneHomeDedup
+	phoneHomeDuplicate  string
+	phoneHomeClientCert bool
+	activeBoots         *activeBoots
+	// activeBootsToken enables the /active-boots endpoint, clients must present it as a bearer token
+	activeBootsToken string
+	// statusToken enables the /_packet/status/ and /_packet/stats endpoints, clients must present it as a bearer token
+	statusToken string
+	// installStatuses are the install statuses reported in JSON phone-home bodies
+	installStatuses *installStatuses
+	caBundle        *caBundle
+	// shedder sets the Retry-After of load shedding responses
+	shedder *loadShedder
+	// rateLimiter limits the job file and phone-home requests of each client, nil does not limit
+	rateLimiter *clientRateLimiter
+	// readHeaderTimeout, writeTimeout and idleTimeout are the http.Server timeouts
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	// fileDeadline bounds iPXE script and binary transfers
+	fileDeadline *fileDeadline
+	// extraHeaders are added to the job file responses
+	extraHeaders extraHeaders
+	// contentTypes override the Content-Type of job files and iPXE binaries by extension
+	contentTypes contentTypes
+	// readinessTimeout bounds the hardware backend check of the readiness endpoint
+	readinessTimeout time.Duration
+	// jobLookupTimeout bounds the hardware backend lookup of job file requests, 0 does not bound it
+	jobLookupTimeout time.Duration
+	// collectInventory serves machines without a hardware record the inventory collection script
+	collectInventory bool
+	// inventoryNonces are the nonces of the inventory collection scripts served, nil without collectInventory
+	inventoryNonces *inventoryNonces
+	// ipxeErrorScripts serves boot scripts that are refused an iPXE script printing the reason
+	ipxeErrorScripts bool
+	// maintenance refuses all boots while it is enabled, maintenanceToken enables the /_packet/maintenance endpoint toggling it
+	maintenance      *maintenance
+	maintenanceToken string
+	// draining reports not ready and refuses new job files while it is enabled, drainToken enables the /_packet/drain endpoint toggling it
+	draining   *drainMode
+	drainToken string
+	// packetLog logs the boot scripts served at debug level, nil logs nothing
+	packetLog *packetLogger
+	// userAgentArch falls back to the arch named in the User-Agent of boot script requests, see userAgentArch
+	userAgentArch bool
+	// allowPXEDefault boots machines with no hardware record with the default installer, see job.NewDefaultJob
+	allowPXEDefault bool
+	// bootLoops detects machines requesting boot scripts in a loop, nil does not detect them
+	bootLoops *bootLoopDetector
+	// fallbackScript is served to boot script requests whose hardware lookup failed, nil responds with an error
+	fallbackScript []byte
+	// recentBoots counts the boot scripts served for the stats endpoint
+	recentBoots *recentCounter
+	// recentLookups counts the hardware lookups for the stats endpoint, shared with the DHCP server
+	recentLookups *recentLookups
+	// maxRequestBody is the max size of phone-home, boot failure and simulate request bodies, 0 does not limit them
+	maxRequestBody int64
+	// inventory stores inventory reports for review, nil if they are not stored
+	inventory *inventoryRecorder
+	// inventoryRetry is how long machines whose inventory was stored wait before booting again
+	inventoryRetry time.Duration
+	// problemJSON describes failed job file and phone-home requests with problem details to clients that accept them
+	problemJSON problemJSON
+	// xffStrict rejects requests with an X-Forwarded-For header from peers that are not trusted proxies
+	xffStrict bool
+	// logFormat and logFields are the format and optional fields of the access log, see httplog.Handler
+	logFormat string
+	logFields httplog.Fields
+	// pprofEnabled serves the pprof handlers, on pprofAddr if it is set and otherwise on the main mux
+	pprofEnabled bool
+	pprofAddr    string
+	// metricsAuthToken and pprofAuthToken are the bearer tokens required by /metrics and pprof, empty requires none
+	metricsAuthToken string
+	pprofAuthToken   string
+
+	pprofServer *http.Server
+
+	mu     sync.Mutex
+	server *http.Server
+	closed bool
+}
+
+// tlsEnabled reports whether the server serves TLS.
+func (s *BootsHTTPServer) tlsEnabled() bool {
+	return s.tlsConfig != nil && len(s.tlsConfig.Certificates) > 0
 }
 
 func (s *BootsHTTPServer) serveHealthchecker(rev string, start time.Time) http.HandlerFunc {
@@ -32,10 +148,30 @@ func (s *BootsHTTPServer) serveHealthchecker(rev string, start time.Time) http.H
 			GitRev     string  `json:"git_rev"`
 			Uptime     float64 `json:"uptime"`
 			Goroutines int     `json:"goroutines"`
+			TLS        bool    `json:"tls"`
+			// LastSuccessfulLookup is the unix time of the last successful hardware lookup
+			LastSuccessfulLookup *int64 `json:"last_successful_lookup,omitempty"`
+			// LastLookupError is the error of the last hardware lookup the backend failed, not finding a record is no failure
+			LastLookupError string `json:"last_lookup_error,omitempty"`
+			// ActiveConnections is the number of open HTTP connections
+			ActiveConnections int64 `json:"active_connections"`
+			// Maintenance is whether all boots are refused, see -maintenance
+			Maintenance bool `json:"maintenance"`
 		}{
-			GitRev:     rev,
-			Uptime:     time.Since(start).Seconds(),
-			Goroutines: runtime.NumGoroutine(),
+			GitRev:            rev,
+			Uptime:            time.Since(start).Seconds(),
+			Goroutines:        runtime.NumGoroutine(),
+			TLS:               s.tlsEnabled(),
+			ActiveConnections: atomic.LoadInt64(&s.activeConns),
+			Maintenance:       s.maintenance.enabled(),
+		}
+		if l, ok := s.jobManager.(lookupReporter); ok {
+			last, lastErr := l.LastLookup()
+			if !last.IsZero() {
+				unix := last.Unix()
+				res.LastSuccessfulLookup = &unix
+			}
+			res.LastLookupError = lastErr
 		}
 		if err := json.NewEncoder(w).Encode(&res); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -44,6 +180,83 @@ func (s *BootsHTTPServer) serveHealthchecker(rev string, start time.Time) http.H
 	}
 }
 
+// lookupReporter is implemented by job managers that record the outcome of their hardware lookups.
+type lookupReporter interface {
+	LastLookup() (time.Time, string)
+}
+
+// hardwareCache is implemented by hardware finders that serve lookups from a local
+// cache, which must be synced before lookups can be answered.
+type hardwareCache interface {
+	Synced() bool
+	Size() int
+	LastEvent() time.Time
+}
+
+// readiness is the readiness check response.
+type readiness struct {
+	Ready         bool                 `json:"ready"`
+	Draining      bool                 `json:"draining,omitempty"`
+	Error         string               `json:"error,omitempty"`
+	HardwareCache *hardwareCacheStatus `json:"hardware_cache,omitempty"`
+}
+
+type hardwareCacheStatus struct {
+	Synced    bool       `json:"synced"`
+	Size      int        `json:"size"`
+	LastEvent *time.Time `json:"last_event,omitempty"`
+}
+
+// serveReadiness responds 200 once Boots can answer hardware lookups, 503 while
+// draining, the hardware or workflow backend can not be reached within the readiness timeout or the
+// hardware finder's cache has not synced. The body reports whether Boots is draining,
+// the backend error and the cache's sync state, size and the time of its last event.
+func (s *BootsHTTPServer) serveReadiness(w http.ResponseWriter, req *http.Request) {
+	res := readiness{Ready: !s.draining.enabled(), Draining: s.draining.enabled()}
+	ctx, cancel := context.WithTimeout(req.Context(), s.readinessTimeout)
+	defer cancel()
+	if err := pingBackend(ctx, s.finder, s.workflowBackend); err != nil {
+		res.Ready = false
+		res.Error = err.Error()
+	}
+	if c, ok := s.finder.(hardwareCache); ok {
+		status := &hardwareCacheStatus{Synced: c.Synced(), Size: c.Size()}
+		if t := c.LastEvent(); !t.IsZero() {
+			status.LastEvent = &t
+		}
+		res.Ready = res.Ready && status.Synced
+		res.HardwareCache = status
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !res.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(&res); err != nil {
+		mainlog.Error(errors.Wrap(err, "marshaling readiness json"))
+	}
+}
+
+// pingBackend pings the hardware backend of finder and the workflow backend of workflows
+// if they depend on a remote service, see client.HardwarePinger.
+func pingBackend(ctx context.Context, finder client.HardwareFinder, workflows client.WorkflowFinder) error {
+	if p, ok := finder.(client.HardwarePinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			return errors.WithMessage(err, "hardware backend")
+		}
+	}
+	// a workflow backend that is a hardware backend too, like kubernetes, is pinged as finder
+	if _, both := workflows.(client.HardwareFinder); both {
+		return nil
+	}
+	if p, ok := workflows.(client.HardwarePinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			return errors.WithMessage(err, "workflow backend")
+		}
+	}
+
+	return nil
+}
+
 // otelFuncWrapper takes a route and an http handler function, wraps the function
 // with otelhttp, and returns the route again and http.Handler all set for mux.Handle().
 func otelFuncWrapper(route string, h func(w http.ResponseWriter, req *http.Request)) (string, http.Handler) {
@@ -51,35 +264,117 @@ func otelFuncWrapper(route string, h func(w http.ResponseWriter, req *http.Reque
 }
 
 type jobHandler struct {
-	i          job.Installers
-	jobManager job.Manager
+	// installers are replaced when the configuration is reloaded
+	installers        *conf.Reloadable[job.Installers]
+	jobManager        job.Manager
+	noNetbootResponse string
+	activeBoots       *activeBoots
+	// collectInventory serves boot scripts requested by clients without a job the inventory collection script
+	collectInventory bool
+	// inventoryNonces are the nonces of the inventory collection scripts served, nil without collectInventory
+	inventoryNonces *inventoryNonces
+	// errorScripts serves boot scripts that are refused an iPXE script printing the reason instead of a 404
+	errorScripts bool
+	// lookupTimeout bounds the job lookup, 0 does not bound it
+	lookupTimeout time.Duration
+	// maintenance refuses all job files while it is enabled
+	maintenance *maintenance
+	// shedder sets the Retry-After of the job files refused while draining or in maintenance mode
+	shedder *loadShedder
+	// draining refuses new job files with a 503 while it is enabled
+	draining *drainMode
+	// packetLog logs the boot scripts served at debug level, nil logs nothing
+	packetLog *packetLogger
+	// userAgentArch falls back to the arch named in the User-Agent of boot script requests, see userAgentArch
+	userAgentArch bool
+	// allowPXEDefault boots machines with no hardware record with the default installer, see job.NewDefaultJob
+	allowPXEDefault bool
+	// bootLoops detects machines requesting boot scripts in a loop, nil does not detect them
+	bootLoops *bootLoopDetector
+	// fallbackScript is served to boot script requests whose hardware lookup failed, nil responds with an error
+	fallbackScript []byte
+	// recentBoots counts the boot scripts served, nil does not count them
+	recentBoots *recentCounter
+	// recentLookups counts the hardware lookups, nil does not count them
+	recentLookups *recentLookups
+	// problems describes refused job files with problem details to clients that accept them
+	problems problemJSON
 }
 
 // ServeHTTP sets up all the HTTP routes using a stdlib mux and starts the http
 // server, which will block. App functionality is instrumented in Prometheus and
 // OpenTelemetry. Optionally configures X-Forwarded-For support.
-func (s *BootsHTTPServer) ServeHTTP(i job.Installers, addr string, ipxePattern string, ipxeHandler func(http.ResponseWriter, *http.Request)) {
+func (s *BootsHTTPServer) ServeHTTP(i *conf.Reloadable[job.Installers], addr string, ipxePattern string, ipxeHandler func(http.ResponseWriter, *http.Request)) {
 	mux := http.NewServeMux()
-	jh := jobHandler{i: i, jobManager: s.jobManager}
-	mux.Handle(otelFuncWrapper("/", jh.serveJobFile))
+	jh := jobHandler{installers: i, jobManager: s.jobManager, noNetbootResponse: s.noNetbootResponse, activeBoots: s.activeBoots, collectInventory: s.collectInventory, inventoryNonces: s.inventoryNonces, errorScripts: s.ipxeErrorScripts, lookupTimeout: s.jobLookupTimeout, maintenance: s.maintenance, shedder: s.shedder, bootLoops: s.bootLoops, fallbackScript: s.fallbackScript, recentBoots: s.recentBoots, recentLookups: s.recentLookups, problems: s.problemJSON, draining: s.draining, packetLog: s.packetLog, userAgentArch: s.userAgentArch, allowPXEDefault: s.allowPXEDefault}
+	mux.Handle(otelFuncWrapper("/", s.rateLimiter.wrap("/", s.shedder, s.fileDeadline.wrap(s.contentTypes.wrap(s.extraHeaders.wrap(jh.serveJobFile))))))
 	if ipxeHandler != nil {
-		mux.Handle(otelFuncWrapper(ipxePattern, ipxeHandler))
+		mux.Handle(otelFuncWrapper(ipxePattern, s.fileDeadline.wrap(s.contentTypes.wrap(ipxeHandler))))
 	}
-	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/metrics", requireToken(s.metricsAuthToken, metrics.Handler(mainlog)))
 	mux.HandleFunc("/_packet/healthcheck", s.serveHealthchecker(GitRev, StartTime))
-	mux.HandleFunc("/_packet/pprof/", pprof.Index)
-	mux.HandleFunc("/_packet/pprof/cmdline", pprof.Cmdline)
-	mux.HandleFunc("/_packet/pprof/profile", pprof.Profile)
-	mux.HandleFunc("/_packet/pprof/symbol", pprof.Symbol)
-	mux.HandleFunc("/_packet/pprof/trace", pprof.Trace)
+	if s.configToken != "" {
+		mux.Handle("/_packet/config", requireToken(s.configToken, http.HandlerFunc(s.serveConfig)))
+	}
+	if s.maintenanceToken != "" {
+		mux.Handle(maintenancePath, requireToken(s.maintenanceToken, http.HandlerFunc(s.serveMaintenance)))
+	}
+	if s.drainToken != "" {
+		mux.Handle(drainPath, requireToken(s.drainToken, http.HandlerFunc(s.serveDrain)))
+	}
+	if s.simulateToken != "" {
+		mux.Handle(simulatePath, requireToken(s.simulateToken, limitBody(s.maxRequestBody, jh.serveSimulate)))
+	}
+	s.registerPprof(mux)
+	if s.pprofEnabled && s.pprofAddr != "" {
+		go s.servePprof()
+	}
 	mux.HandleFunc("/healthcheck", s.serveHealthchecker(GitRev, StartTime))
-	mux.Handle(otelFuncWrapper("/phone-home", s.servePhoneHome))
+	mux.HandleFunc("/_packet/version", serveVersion)
+	mux.HandleFunc("/version", serveVersion)
+	mux.HandleFunc("/readiness", s.serveReadiness)
+	phoneHome := limitBody(s.maxRequestBody, s.servePhoneHome)
+	if s.phoneHomeClientCert {
+		phoneHome = s.requireClientCert(phoneHome)
+	}
+	phoneHome = s.rateLimiter.wrap("/phone-home", s.shedder, phoneHome)
+	mux.Handle(otelFuncWrapper("/phone-home", phoneHome))
+	mux.Handle(otelFuncWrapper("/boot-failure", s.rateLimiter.wrap("/boot-failure", s.shedder, limitBody(s.maxRequestBody, s.serveBootFailure))))
+	mux.Handle(otelFuncWrapper(noCloudPrefix, s.serveNoCloud))
+	if s.activeBootsToken != "" {
+		mux.Handle("/active-boots", requireToken(s.activeBootsToken, http.HandlerFunc(s.serveActiveBoots)))
+	}
+	if s.statusToken != "" {
+		mux.Handle(installStatusPrefix, requireToken(s.statusToken, http.HandlerFunc(s.serveInstallStatus)))
+		mux.Handle(bootLoopsPath, requireToken(s.statusToken, http.HandlerFunc(s.serveBootLoops)))
+		mux.Handle(statsPath, requireToken(s.statusToken, http.HandlerFunc(s.serveStats)))
+	}
+	if s.collectInventory {
+		mux.Handle(otelFuncWrapper("/inventory.ipxe", s.serveInventoryScript))
+		mux.Handle(otelFuncWrapper("/inventory", limitBody(s.maxRequestBody, s.serveInventory)))
+	}
+	if s.caBundle != nil {
+		mux.Handle(caBundlePath, s.caBundle)
+	}
+	if s.syslogStreamToken != "" {
+		mux.HandleFunc("/syslog/stream", s.serveSyslogStream)
+	}
 
 	// wrap the mux with an OpenTelemetry interceptor
-	otelHandler := otelhttp.NewHandler(mux, "boots-http")
+	var handler http.Handler = mux
+	if s.shedder != nil {
+		handler = s.shedder.track(mux)
+	}
+	otelHandler := otelhttp.NewHandler(handler, "boots-http")
 
-	// add X-Forwarded-For support if trusted proxies are configured
-	var xffHandler http.Handler
+	// add X-Forwarded-For support if trusted proxies are configured, the access log is
+	// inside it so the resolved client is logged rather than the proxy
+	var xffHandler http.Handler = &httplog.Handler{
+		Handler: otelHandler,
+		Format:  s.logFormat,
+		Output:  os.Stdout,
+		Fields:  s.logFields,
+	}
 	if len(conf.TrustedProxies) > 0 {
 		xffmw, err := xff.New(xff.Options{
 			AllowedSubnets: conf.TrustedProxies,
@@ -88,30 +383,85 @@ func (s *BootsHTTPServer) ServeHTTP(i job.Installers, addr string, ipxePattern s
 			mainlog.Fatal(err, "failed to create new xff object")
 		}
 
-		xffHandler = xffmw.Handler(&httplog.Handler{
-			Handler: otelHandler,
-		})
-	} else {
-		xffHandler = &httplog.Handler{
-			Handler: otelHandler,
+		guard, err := newXFFGuard(conf.TrustedProxies, s.xffStrict)
+		if err != nil {
+			mainlog.Fatal(err, "failed to create xff guard")
 		}
+		xffHandler = guard.wrap(xffmw.Handler(xffHandler))
 	}
 
-	server := http.Server{
+	server := &http.Server{
 		Addr:    addr,
 		Handler: xffHandler,
 
 		// Mitigate Slowloris attacks. 30 seconds is based on Apache's recommended 20-40
-		// recommendation. Boots doesn't really have many headers so 20s should be plenty of time.
+		// recommendation. Boots doesn't really have many headers so the 20s default should be plenty of time.
 		// https://en.wikipedia.org/wiki/Slowloris_(computer_security)
-		ReadHeaderTimeout: 20 * time.Second,
+		ReadHeaderTimeout: s.readHeaderTimeout,
+		WriteTimeout:      s.writeTimeout,
+		IdleTimeout:       s.idleTimeout,
+		TLSConfig:         s.tlsConfig,
+		ConnContext:       withConn,
+		ConnState:         s.trackConnState,
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+
+		return
+	}
+	s.server = server
+	s.mu.Unlock()
+
+	ln, err := s.listenConfig.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		err = errors.Wrap(err, "listen http")
+		mainlog.Fatal(err)
+	}
+	if s.tlsEnabled() {
+		// metrics and pprof, unless it has its own address, are served on the same mux, so they are served over TLS too
+		ln = tls.NewListener(ln, s.tlsConfig)
 	}
-	if err := server.ListenAndServe(); err != nil {
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		err = errors.Wrap(err, "listen and serve http")
 		mainlog.Fatal(err)
 	}
 }
 
+// trackConnState counts the connections of the http.Server as they are opened and closed.
+func (s *BootsHTTPServer) trackConnState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		atomic.AddInt64(&s.activeConns, 1)
+		metrics.HTTPConnectionsTotal.Inc()
+		metrics.HTTPActiveConnections.Inc()
+	case http.StateClosed, http.StateHijacked:
+		atomic.AddInt64(&s.activeConns, -1)
+		metrics.HTTPActiveConnections.Dec()
+	}
+}
+
+// Shutdown stops accepting new connections and waits for in-flight requests to
+// finish until ctx is done, then closes any remaining connections.
+func (s *BootsHTTPServer) Shutdown(ctx context.Context) {
+	s.mu.Lock()
+	s.closed = true
+	server, pprofServer := s.server, s.pprofServer
+	// the lock is not held while waiting for requests, which may need it
+	s.mu.Unlock()
+	if pprofServer != nil {
+		// profiles are only for debugging, they are not waited for
+		pprofServer.Close()
+	}
+	if server == nil {
+		return
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		mainlog.Error(errors.Wrap(err, "http shutdown timed out, closing with requests in flight"))
+		server.Close()
+	}
+}
+
 func (h *jobHandler) serveJobFile(w http.ResponseWriter, req *http.Request) {
 	labels := prometheus.Labels{"from": "http", "op": "file"}
 	metrics.JobsTotal.With(labels).Inc()
@@ -120,13 +470,86 @@ func (h *jobHandler) serveJobFile(w http.ResponseWriter, req *http.Request) {
 	timer := prometheus.NewTimer(metrics.JobDuration.With(labels))
 	defer timer.ObserveDuration()
 
-	ctx, j, err := h.jobManager.CreateFromRemoteAddr(req.Context(), req.RemoteAddr)
+	if path.Ext(req.URL.Path) == ".ipxe" && !acceptsJSON(req) {
+		var finish func()
+		w, finish = gzipScript(w, req)
+		defer finish()
+	}
+	if h.draining.enabled() {
+		mainlog.With("client", req.RemoteAddr, "path", req.URL.Path).Info("refusing job file while draining")
+		h.shedder.setRetryAfter(w)
+		h.problems.fail(w, req, http.StatusServiceUnavailable, problemDraining, drainReason, nil)
+
+		return
+	}
+	if h.maintenance.enabled() {
+		mainlog.With("client", req.RemoteAddr, "path", req.URL.Path).Info("refusing job file in maintenance mode")
+		h.shedder.setRetryAfter(w)
+		if path.Ext(req.URL.Path) == ".ipxe" {
+			serveErrorScript(w, mainlog, maintenanceReason)
+
+			return
+		}
+		h.problems.fail(w, req, http.StatusServiceUnavailable, problemMaintenance, maintenanceReason, nil)
+
+		return
+	}
+	ctx, j, err := h.lookupJob(req)
+	if errors.Is(err, client.ErrNotFound) && h.allowPXEDefault && !h.collectInventory {
+		if dj, derr := job.NewDefaultJob(mainlog, req.RemoteAddr); derr == nil {
+			j, err = dj, nil
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		metrics.JobLookupTimeouts.Inc()
+		if h.serveFallbackScript(w, req, "timeout", err) {
+			return
+		}
+		h.problems.fail(w, req, http.StatusGatewayTimeout, problemLookupTimeout, "The hardware lookup for "+remoteIP(req.RemoteAddr)+" did not finish within "+h.lookupTimeout.String(), nil)
+		mainlog.With("client", req.RemoteAddr, "timeout", h.lookupTimeout).Error(err, "job lookup timed out")
+
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		if job.IsLookupError(err) && h.serveFallbackScript(w, req, "error", err) {
+			return
+		}
+		if h.collectInventory && path.Ext(req.URL.Path) == ".ipxe" {
+			mainlog.With("client", req.RemoteAddr, "error", err).Info("no job found for client address, serving inventory collection")
+			if _, err := w.Write(inventoryScript(h.inventoryNonces, req)); err != nil {
+				mainlog.Error(errors.Wrap(err, "unable to write inventory script"))
+			}
+
+			return
+		}
+		if h.errorScripts && path.Ext(req.URL.Path) == ".ipxe" {
+			mainlog.With("client", req.RemoteAddr).Error(err, "no job found for client address, serving error script")
+			serveErrorScript(w, mainlog, "No hardware record found for "+remoteIP(req.RemoteAddr)+", boot not allowed")
+
+			return
+		}
+		code, typ := lookupProblem(err)
+		if typ == problemBackendError {
+			h.problems.fail(w, req, code, typ, "The hardware lookup for "+remoteIP(req.RemoteAddr)+" failed", nil)
+			mainlog.With("client", req.RemoteAddr, "status", code).Error(err, "hardware lookup failed")
+
+			return
+		}
+		h.problems.fail(w, req, code, typ, "No hardware record found for "+remoteIP(req.RemoteAddr), nil)
 		mainlog.With("client", req.RemoteAddr).Error(err, "no job found for client address")
 
 		return
 	}
+	tagJobSpan(req, j)
+	// the boot script points the machine back at Boots the way it reached it
+	j.BootsScheme = requestScheme(req)
+	// the boot file and the boot decision are representations of the same resource
+	varyAccept(w.Header())
+	if acceptsJSON(req) {
+		h.serveBootDecision(ctx, w, req, j)
+
+		return
+	}
 	// This gates serving PXE file by
 	// 1. the existence of a hardware record in tink server
 	// AND
@@ -134,14 +557,89 @@ func (h *jobHandler) serveJobFile(w http.ResponseWriter, req *http.Request) {
 	// This allows serving custom ipxe scripts, starting up into OSIE or other installation environments
 	// without a tink workflow present.
 	if !j.AllowPXE() {
-		w.WriteHeader(http.StatusNotFound)
+		// a known machine that is missing netboot config is distinguished from one that is not allowed to pxe
+		if !j.NetbootConfigured() {
+			h.serveNoNetbootConfig(ctx, w, req, j)
+
+			return
+		}
+		if h.errorScripts && path.Ext(req.URL.Path) == ".ipxe" {
+			j.With("client", req.RemoteAddr).Info("the hardware data for this machine does not allow it to pxe, serving error script; allow_pxe: false")
+			serveErrorScript(w, j.Logger, "Hardware "+j.HardwareID().String()+" with MAC "+j.PrimaryNIC().String()+" is not allowed to netboot, allow_pxe is false")
+
+			return
+		}
+		h.problems.fail(w, req, http.StatusNotFound, problemPXENotAllowed, "Hardware "+j.HardwareID().String()+" with MAC "+j.PrimaryNIC().String()+" is not allowed to netboot, allow_pxe is false", j.PrimaryNIC())
 		mainlog.With("client", req.RemoteAddr).Info("the hardware data for this machine, or lack there of, does not allow it to pxe; allow_pxe: false")
 
 		return
 	}
 
+	if path.Ext(req.URL.Path) == ".ipxe" && h.haltBootLoop(w, req, j) {
+		return
+	}
+	tagBootRequest(w, req, j, h.activeBoots.seen(j.PrimaryNIC(), req.RemoteAddr, bootStageFile, requestBootID(req)))
+	if j.AllowPXEByDefault() {
+		j.With("client", req.RemoteAddr).Info("boot allowed by the -allow-pxe-default policy, the machine has no hardware record or no netboot settings in it")
+	}
+	j.ClientArch = h.clientArch(req, j)
+	if path.Ext(req.URL.Path) == ".ipxe" {
+		h.recentBoots.add()
+		var logScript func()
+		w, logScript = h.packetLog.script(w, req, j.PrimaryNIC())
+		defer logScript()
+	}
 	// otel: send a req.Clone with the updated context from the job's hw data
-	j.ServeFile(w, req.Clone(ctx), h.i)
+	j.ServeFile(w, req.Clone(ctx), h.installers.Load())
+}
+
+// lookupJob creates the job of the client of req, giving up after the lookup timeout with
+// an error wrapping context.DeadlineExceeded. The returned context is req's, with the job's
+// trace, so the deadline only applies to the lookup.
+func (h *jobHandler) lookupJob(req *http.Request) (context.Context, *job.Job, error) {
+	start := time.Now()
+	if h.lookupTimeout <= 0 {
+		ctx, j, err := h.jobManager.CreateFromRemoteAddr(req.Context(), req.RemoteAddr)
+		observeLookup("file", start, err)
+		h.recentLookups.add(err)
+
+		return ctx, j, err
+	}
+	lookupCtx, cancel := context.WithTimeout(req.Context(), h.lookupTimeout)
+	defer cancel()
+	ctx, j, err := h.jobManager.CreateFromRemoteAddr(lookupCtx, req.RemoteAddr)
+	observeLookup("file", start, err)
+	h.recentLookups.add(err)
+	if lookupCtx.Err() == context.DeadlineExceeded {
+		return req.Context(), nil, errors.Wrapf(context.DeadlineExceeded, "no job found within %s", h.lookupTimeout)
+	}
+
+	return trace.ContextWithSpanContext(req.Context(), trace.SpanContextFromContext(ctx)), j, err
+}
+
+// tagJobSpan sets the identity of the hardware of j, found for req, on the span of req so
+// traces can be filtered by machine. The span is req's own, not the job's, so the
+// attributes are not carried into the contexts of other requests.
+func tagJobSpan(req *http.Request, j *job.Job) {
+	trace.SpanFromContext(req.Context()).SetAttributes(
+		attribute.String("boots.mac", j.PrimaryNIC().String()),
+		attribute.String("boots.client_ip", remoteIP(req.RemoteAddr)),
+		attribute.String("boots.hardware_id", j.HardwareID().String()),
+		attribute.String("boots.arch", j.Arch()),
+		attribute.Bool("boots.allow_pxe", j.AllowPXE()),
+	)
+}
+
+// observeLookup records the duration of the hardware lookup of op started at start, by its outcome.
+func observeLookup(op string, start time.Time, err error) {
+	outcome := "found"
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		outcome = "not-found"
+	case err != nil:
+		outcome = "error"
+	}
+	metrics.BackendLookupDuration.With(prometheus.Labels{"op": op, "outcome": outcome}).Observe(time.Since(start).Seconds())
 }
 
 func (s *BootsHTTPServer) servePhoneHome(w http.ResponseWriter, req *http.Request) {
@@ -152,12 +650,124 @@ func (s *BootsHTTPServer) servePhoneHome(w http.ResponseWriter, req *http.Reques
 	timer := prometheus.NewTimer(metrics.JobDuration.With(labels))
 	defer timer.ObserveDuration()
 
+	// a JSON body is an install status, otherwise the type and body are form values
+	var status *installStatus
+	if isJSONBody(req) {
+		// the status is read into memory, so it is bounded even if -max-request-body is 0
+		limit := s.maxRequestBody
+		if limit <= 0 {
+			limit = maxInstallStatusBody
+			req.Body = &maxBodyReader{ReadCloser: http.MaxBytesReader(w, req.Body, limit), limit: limit}
+		}
+		st, err := parseInstallStatus(req.Body)
+		// a body that starts with a valid status is still refused if it is larger than the limit
+		_, _ = io.Copy(io.Discard, req.Body)
+		if bodyTooLarge(req) {
+			refuseBodyTooLarge(w, req, limit)
+
+			return
+		}
+		if err != nil {
+			s.problemJSON.fail(w, req, http.StatusBadRequest, problemInvalidStatus, err.Error(), nil)
+			mainlog.With("client", req.RemoteAddr).Error(err, "invalid phone-home status")
+
+			return
+		}
+		status = &st
+	} else if err := req.ParseForm(); err != nil && bodyTooLarge(req) {
+		refuseBodyTooLarge(w, req, s.maxRequestBody)
+
+		return
+	}
+
+	lookupStart := time.Now()
 	_, j, err := s.jobManager.CreateFromRemoteAddr(req.Context(), req.RemoteAddr)
+	observeLookup("phone-home", lookupStart, err)
+	s.recentLookups.add(err)
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		// the machine may well be known, so the phone-home is not answered like an unknown one
+		code := http.StatusBadGateway
+		if errors.Is(err, client.ErrBreakerOpen) {
+			code = http.StatusServiceUnavailable
+		}
+		s.problemJSON.fail(w, req, code, problemBackendError, "The hardware lookup for "+remoteIP(req.RemoteAddr)+" failed", nil)
+		mainlog.With("client", req.RemoteAddr, "status", code).Error(err, "phone-home hardware lookup failed")
+
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusOK)
-		mainlog.With("client", req.RemoteAddr, "error", err).Info("no job found for client address")
+		metrics.PhoneHomeUnknown.Inc()
+		code := s.phoneHomeUnknownStatus
+		if code == 0 {
+			code = http.StatusOK
+		}
+		if code >= http.StatusBadRequest {
+			s.problemJSON.fail(w, req, code, problemNoHardware, "No hardware record found for "+remoteIP(req.RemoteAddr), nil)
+		} else {
+			w.WriteHeader(code)
+		}
+		mainlog.With("client", req.RemoteAddr, "status", code, "error", err).Info("no job found for client address")
+
+		return
+	}
+	tagJobSpan(req, j)
+	tagBootRequest(w, req, j, s.activeBoots.seen(j.PrimaryNIC(), req.RemoteAddr, bootStagePhoneHome, requestBootID(req)))
+	_ = req.ParseForm()
+	typ, body := req.PostForm.Get("type"), req.PostForm.Get("body")
+	if status != nil {
+		// every status is recorded, only the events and notifications are deduplicated by phase
+		s.installStatuses.record(j.PrimaryNIC(), *status)
+		typ, body = status.Phase, status.Message
+	}
+	processed, duplicate := s.phoneHomeDedup.begin(j.PrimaryNIC().String(), typ)
+	if duplicate {
+		metrics.PhoneHomeDuplicates.Inc()
+		j.With("client", req.RemoteAddr, "type", typ).Debug("suppressing duplicate phone-home")
+		if s.phoneHomeDuplicate == phoneHomeDuplicateReject {
+			s.shedder.reject(w, http.StatusTooManyRequests)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
 
 		return
 	}
-	j.ServePhoneHomeEndpoint(w, req)
+	if s.phoneHomes != nil {
+		s.phoneHomes.add(phoneHomeEvent{
+			Time:       time.Now().UTC(),
+			Client:     req.RemoteAddr,
+			MAC:        j.PrimaryNIC().String(),
+			HardwareID: j.HardwareID().String(),
+			Type:       typ,
+			Body:       body,
+		})
+	}
+	s.phoneHomeWebhook.notify(phoneHomeNotification{
+		Time:       time.Now().UTC(),
+		MAC:        j.PrimaryNIC().String(),
+		IP:         remoteIP(req.RemoteAddr),
+		JobID:      j.ID(),
+		HardwareID: j.HardwareID().String(),
+		Type:       typ,
+	})
+	rw := &phoneHomeRecorder{ResponseWriter: w}
+	j.ServePhoneHomeEndpoint(rw, req)
+	processed(rw.code >= http.StatusOK && rw.code < http.StatusMultipleChoices)
+}
+
+// requestScheme returns https for requests served over TLS, http otherwise.
+func requestScheme(req *http.Request) string {
+	if req.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
+
+// remoteIP returns the IP of a request's RemoteAddr, or RemoteAddr itself if it has no port.
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
 }
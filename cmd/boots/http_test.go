@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// hungManager is a job.Manager whose lookups block until their context is done.
+type hungManager struct{}
+
+func (hungManager) CreateFromRemoteAddr(ctx context.Context, _ string) (context.Context, *job.Job, error) {
+	<-ctx.Done()
+
+	return ctx, nil, errors.Wrap(ctx.Err(), "lookup")
+}
+
+func (hungManager) CreateFromDHCP(ctx context.Context, _ net.HardwareAddr, _ net.IP, _ string) (context.Context, *job.Job, error) {
+	<-ctx.Done()
+
+	return ctx, nil, ctx.Err()
+}
+
+func TestServeJobFileLookupTimeout(t *testing.T) {
+	h := &jobHandler{jobManager: hungManager{}, lookupTimeout: 10 * time.Millisecond}
+	timeouts := testutil.ToFloat64(metrics.JobLookupTimeouts)
+
+	w := httptest.NewRecorder()
+	h.serveJobFile(w, httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("want status %d, got %d", http.StatusGatewayTimeout, w.Code)
+	}
+	if n := testutil.ToFloat64(metrics.JobLookupTimeouts) - timeouts; n != 1 {
+		t.Fatalf("want 1 job lookup timeout, got %v", n)
+	}
+}
+
+func TestLookupJobDeadline(t *testing.T) {
+	h := &jobHandler{jobManager: &job.Creator{}, lookupTimeout: time.Minute}
+	req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+	req.RemoteAddr = "not an address"
+
+	// the lookup fails, the returned context must not carry the lookup deadline
+	ctx, _, err := h.lookupJob(req)
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want lookup error, got: %v", err)
+	}
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatal("want context without the lookup deadline")
+	}
+}
+
+// lookupCount returns the number of backend lookups of op with outcome.
+func lookupCount(t *testing.T, op, outcome string) uint64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "backend_lookup_duration_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["op"] == op && labels["outcome"] == outcome {
+				return m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+
+	return 0
+}
+
+func TestBackendLookupDuration(t *testing.T) {
+	j := job.NewMock(t, "c3.small.x86", "ewr1").Job()
+	tests := map[string]struct {
+		manager staticManager
+		outcome string
+	}{
+		"found":     {manager: staticManager{j: &j}, outcome: "found"},
+		"not found": {manager: staticManager{err: errors.WithMessage(client.ErrNotFound, "discovering from ip address")}, outcome: "not-found"},
+		"error":     {manager: staticManager{err: errors.New("connection refused")}, outcome: "error"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, phoneHomes := lookupCount(t, "file", tt.outcome), lookupCount(t, "phone-home", tt.outcome)
+			h := &jobHandler{jobManager: tt.manager}
+			req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+			h.serveJobFile(httptest.NewRecorder(), req)
+			s := &BootsHTTPServer{jobManager: tt.manager}
+			req = httptest.NewRequest(http.MethodPost, "/phone-home", nil)
+			s.servePhoneHome(httptest.NewRecorder(), req)
+
+			if n := lookupCount(t, "file", tt.outcome) - files; n != 1 {
+				t.Fatalf("want 1 %s file lookup, got %d", tt.outcome, n)
+			}
+			if n := lookupCount(t, "phone-home", tt.outcome) - phoneHomes; n != 1 {
+				t.Fatalf("want 1 %s phone-home lookup, got %d", tt.outcome, n)
+			}
+		})
+	}
+}
+
+// recordingSpan is a trace.Span that records the attributes set on it.
+type recordingSpan struct {
+	trace.Span
+	attrs map[attribute.Key]attribute.Value
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func TestServeJobFileSpanAttributes(t *testing.T) {
+	j := job.NewMock(t, "c3.small.x86", "ewr1").Job()
+	tests := map[string]struct {
+		manager staticManager
+		want    map[attribute.Key]attribute.Value
+	}{
+		"found": {manager: staticManager{j: &j}, want: map[attribute.Key]attribute.Value{
+			"boots.mac":         attribute.StringValue(j.PrimaryNIC().String()),
+			"boots.client_ip":   attribute.StringValue("192.0.2.10"),
+			"boots.hardware_id": attribute.StringValue(j.HardwareID().String()),
+			"boots.arch":        attribute.StringValue("x86_64"),
+			"boots.allow_pxe":   attribute.BoolValue(false),
+		}},
+		"not found": {manager: staticManager{err: errors.New("no hardware")}, want: map[attribute.Key]attribute.Value{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			span := &recordingSpan{Span: trace.SpanFromContext(context.Background()), attrs: map[attribute.Key]attribute.Value{}}
+			req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+			req = req.WithContext(trace.ContextWithSpan(req.Context(), span))
+			req.RemoteAddr = "192.0.2.10:4321"
+			h := &jobHandler{jobManager: tt.manager}
+			h.serveJobFile(httptest.NewRecorder(), req)
+			if diff := cmp.Diff(tt.want, span.attrs, cmp.Comparer(func(a, b attribute.Value) bool { return a.Emit() == b.Emit() })); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestServeHTTPMetricsCollision(t *testing.T) {
+	// registering dhcp_total again with other labels collides with the registered one
+	metrics.MustRegister(mainlog, prometheus.NewCounter(prometheus.CounterOpts{Name: "dhcp_total", Help: "colliding metric"}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	s := &BootsHTTPServer{}
+	go s.ServeHTTP(conf.NewReloadable(job.NewInstallers()), addr, "", nil)
+	defer s.Shutdown(context.Background())
+
+	for _, path := range []string{"/_packet/healthcheck", "/metrics"} {
+		var resp *http.Response
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			if resp, err = http.Get("http://" + addr + path); err == nil || time.Now().After(deadline) {
+				break
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("want status %d for %s, got %d", http.StatusOK, path, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// installStatusPrefix is the path the install status of a MAC is served under.
+const installStatusPrefix = "/_packet/status/"
+
+// maxInstallStatusBody is the max size of a phone-home status body if -max-request-body is 0.
+const maxInstallStatusBody = 64 << 10
+
+// installStatus is a status a machine reports in a JSON phone-home body.
+type installStatus struct {
+	Phase    string    `json:"phase"`
+	Progress int       `json:"progress"`
+	Message  string    `json:"message,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// installStatusReport is the install status of a machine, as served.
+type installStatusReport struct {
+	MAC string `json:"mac"`
+	installStatus
+	// History are the previous statuses, most recent first
+	History []installStatus `json:"history,omitempty"`
+}
+
+// isJSONBody reports whether req has a JSON body, otherwise phone-home bodies are form encoded.
+func isJSONBody(req *http.Request) bool {
+	t, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+	return err == nil && t == "application/json"
+}
+
+// parseInstallStatus decodes a JSON phone-home body, it must have a phase and a progress from 0 to 100.
+func parseInstallStatus(body io.Reader) (installStatus, error) {
+	var st installStatus
+	d := json.NewDecoder(body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&st); err != nil {
+		return installStatus{}, errors.Wrap(err, "decode phone-home status")
+	}
+	if st.Phase == "" {
+		return installStatus{}, errors.New("phone-home status has no phase")
+	}
+	if st.Progress < 0 || st.Progress > 100 {
+		return installStatus{}, errors.Errorf("phone-home status progress %d is not between 0 and 100", st.Progress)
+	}
+
+	return st, nil
+}
+
+// installStatuses holds the statuses machines last reported, keeping at most
+// maxMessages per machine. A machine's statuses expire ttl after its last report.
+type installStatuses struct {
+	ttl         time.Duration
+	maxMessages int
+	now         func() time.Time
+
+	mu sync.Mutex
+	// byMAC are the statuses of each MAC, most recent first
+	byMAC     map[string][]installStatus
+	lastSweep time.Time
+}
+
+func newInstallStatuses(ttl time.Duration, maxMessages int) (*installStatuses, error) {
+	if ttl <= 0 {
+		return nil, errors.New("-phone-home-status-ttl must be greater than 0")
+	}
+	if maxMessages <= 0 {
+		return nil, errors.New("-phone-home-status-history must be greater than 0")
+	}
+
+	return &installStatuses{ttl: ttl, maxMessages: maxMessages, now: time.Now, byMAC: map[string][]installStatus{}}, nil
+}
+
+// record stores st as the latest status of mac.
+func (s *installStatuses) record(mac net.HardwareAddr, st installStatus) {
+	if s == nil || len(mac) == 0 {
+		return
+	}
+	now := s.now().UTC()
+	st.Time = now
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(now)
+	prev := s.byMAC[mac.String()]
+	if len(prev) >= s.maxMessages {
+		prev = prev[:s.maxMessages-1]
+	}
+	s.byMAC[mac.String()] = append([]installStatus{st}, prev...)
+}
+
+// get returns the unexpired statuses of mac, false if there are none.
+func (s *installStatuses) get(mac net.HardwareAddr) (installStatusReport, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := s.byMAC[mac.String()]
+	if len(statuses) == 0 || s.now().UTC().Sub(statuses[0].Time) >= s.ttl {
+		return installStatusReport{}, false
+	}
+
+	return installStatusReport{
+		MAC:           mac.String(),
+		installStatus: statuses[0],
+		History:       append([]installStatus(nil), statuses[1:]...),
+	}, true
+}
+
+// expire removes the MACs whose last status is older than ttl, at most once every ttl.
+// s.mu must be held.
+func (s *installStatuses) expire(now time.Time) {
+	if now.Sub(s.lastSweep) < s.ttl {
+		return
+	}
+	for mac, statuses := range s.byMAC {
+		if now.Sub(statuses[0].Time) >= s.ttl {
+			delete(s.byMAC, mac)
+		}
+	}
+	s.lastSweep = now
+}
+
+// serveInstallStatus responds with the install status last reported by the MAC in the path.
+func (s *BootsHTTPServer) serveInstallStatus(w http.ResponseWriter, req *http.Request) {
+	mac, err := net.ParseMAC(strings.TrimPrefix(req.URL.Path, installStatusPrefix))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+	report, ok := s.installStatuses.get(mac)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		mainlog.Error(errors.Wrap(err, "marshaling install status json"))
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseInstallStatus(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		want installStatus
+		err  bool
+	}{
+		"status":         {body: `{"phase": "partitioning", "progress": 40, "message": "sda"}`, want: installStatus{Phase: "partitioning", Progress: 40, Message: "sda"}},
+		"no message":     {body: `{"phase": "done", "progress": 100}`, want: installStatus{Phase: "done", Progress: 100}},
+		"malformed":      {body: `{"phase": `, err: true},
+		"not an object":  {body: `"done"`, err: true},
+		"unknown field":  {body: `{"phase": "done", "percent": 100}`, err: true},
+		"no phase":       {body: `{"progress": 10}`, err: true},
+		"progress > 100": {body: `{"phase": "done", "progress": 101}`, err: true},
+		"progress < 0":   {body: `{"phase": "done", "progress": -1}`, err: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseInstallStatus(strings.NewReader(tt.body))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("want error, got %v", got)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestInstallStatuses(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, 0xef}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	s, err := newInstallStatuses(time.Minute, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return now }
+
+	s.record(mac, installStatus{Phase: "partitioning", Progress: 10})
+	now = now.Add(10 * time.Second)
+	s.record(mac, installStatus{Phase: "image", Progress: 50})
+	now = now.Add(10 * time.Second)
+	s.record(mac, installStatus{Phase: "image", Progress: 80, Message: "almost"})
+
+	// only the 2 most recent statuses are kept
+	want := installStatusReport{
+		MAC:           mac.String(),
+		installStatus: installStatus{Phase: "image", Progress: 80, Message: "almost", Time: start.Add(20 * time.Second)},
+		History:       []installStatus{{Phase: "image", Progress: 50, Time: start.Add(10 * time.Second)}},
+	}
+	got, ok := s.get(mac)
+	if !ok {
+		t.Fatal("want install status")
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(installStatusReport{})); diff != "" {
+		t.Fatal(diff)
+	}
+
+	now = now.Add(time.Minute)
+	if got, ok := s.get(mac); ok {
+		t.Fatalf("want expired install status, got %v", got)
+	}
+	s.record(net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, 0x00}, installStatus{Phase: "done", Progress: 100})
+	if _, ok := s.byMAC[mac.String()]; ok {
+		t.Fatal("want expired install status removed")
+	}
+}
+
+func TestNewInstallStatusesInvalid(t *testing.T) {
+	if _, err := newInstallStatuses(0, 10); err == nil {
+		t.Fatal("want error for zero ttl")
+	}
+	if _, err := newInstallStatuses(time.Hour, 0); err == nil {
+		t.Fatal("want error for zero history")
+	}
+}
+
+func TestServeInstallStatus(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, 0xef}
+	statuses, err := newInstallStatuses(time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses.record(mac, installStatus{Phase: "image", Progress: 50})
+	s := &BootsHTTPServer{installStatuses: statuses, statusToken: "secret"}
+
+	tests := map[string]struct {
+		path  string
+		token string
+		want  int
+		phase string
+	}{
+		"status":      {path: installStatusPrefix + mac.String(), token: "secret", want: http.StatusOK, phase: "image"},
+		"unknown mac": {path: installStatusPrefix + "00:00:ba:dd:be:00", token: "secret", want: http.StatusNotFound},
+		"invalid mac": {path: installStatusPrefix + "nope", token: "secret", want: http.StatusBadRequest},
+		"no token":    {path: installStatusPrefix + mac.String(), want: http.StatusUnauthorized},
+		"wrong token": {path: installStatusPrefix + mac.String(), token: "nope", want: http.StatusUnauthorized},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			requireToken(s.statusToken, http.HandlerFunc(s.serveInstallStatus)).ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("want status %d, got %d", tt.want, w.Code)
+			}
+			if tt.phase == "" {
+				return
+			}
+			var got installStatusReport
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.MAC != mac.String() || got.Phase != tt.phase {
+				t.Fatalf("unexpected install status %+v", got)
+			}
+		})
+	}
+}
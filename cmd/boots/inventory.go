@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// The outcomes of an inventory report.
+const (
+	// inventoryCreated is a report a hardware record was created from.
+	inventoryCreated = "created"
+	// inventoryStored is a report stored for an operator to review.
+	inventoryStored = "stored"
+	// inventoryFailed is a report that could not be created from or stored.
+	inventoryFailed = "failed"
+)
+
+// inventoryNonceTTL is how long the nonce of a served inventory collection script can be
+// used to post its report.
+const inventoryNonceTTL = 10 * time.Minute
+
+// inventoryMaxNonces is the max number of unused nonces kept, the oldest is dropped when full.
+const inventoryMaxNonces = 10000
+
+// inventoryNonces binds inventory reports to the inventory collection scripts served:
+// each script gets a single use nonce that is only accepted from the address it was
+// served to, so a client can only report inventory for itself after booting the script.
+type inventoryNonces struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	issued map[string]inventoryNonce
+}
+
+type inventoryNonce struct {
+	ip      string
+	expires time.Time
+}
+
+func newInventoryNonces() *inventoryNonces {
+	return &inventoryNonces{now: time.Now, issued: map[string]inventoryNonce{}}
+}
+
+// issue returns a new nonce for the client at addr, an IP or host:port.
+func (n *inventoryNonces) issue(addr string) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	nonce := hex.EncodeToString(b)
+	now := n.now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.issued) >= inventoryMaxNonces {
+		oldest := ""
+		for k, v := range n.issued {
+			if now.After(v.expires) {
+				delete(n.issued, k)
+			} else if oldest == "" || v.expires.Before(n.issued[oldest].expires) {
+				oldest = k
+			}
+		}
+		if len(n.issued) >= inventoryMaxNonces {
+			delete(n.issued, oldest)
+		}
+	}
+	n.issued[nonce] = inventoryNonce{ip: remoteIP(addr), expires: now.Add(inventoryNonceTTL)}
+
+	return nonce
+}
+
+// redeem reports whether nonce was issued to the client at addr and has not expired,
+// using it up if so.
+func (n *inventoryNonces) redeem(nonce, addr string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	e, ok := n.issued[nonce]
+	if !ok || e.ip != remoteIP(addr) {
+		return false
+	}
+	delete(n.issued, nonce)
+
+	return n.now().Before(e.expires)
+}
+
+// inventoryRecorder persists inventory reports as JSON lines for operators to review.
+type inventoryRecorder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (r *inventoryRecorder) record(inv client.Inventory) error {
+	b, err := json.Marshal(inv)
+	if err != nil {
+		return errors.Wrap(err, "marshal inventory")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.w.Write(append(b, '\n'))
+
+	return errors.Wrap(err, "write inventory")
+}
+
+// inventoryScript is an iPXE script that collects the machine's inventory and posts it
+// with a nonce issued to the client of req, for machines without a hardware record.
+func inventoryScript(nonces *inventoryNonces, req *http.Request) []byte {
+	s := ipxe.NewScript()
+	s.Set("tinkerbell", requestScheme(req)+"://"+conf.PublicFQDN)
+	s.CollectInventory(nonces.issue(req.RemoteAddr))
+
+	return s.Bytes()
+}
+
+// serveInventoryScript serves the inventory collection script.
+func (s *BootsHTTPServer) serveInventoryScript(w http.ResponseWriter, req *http.Request) {
+	if _, err := w.Write(inventoryScript(s.inventoryNonces, req)); err != nil {
+		mainlog.Error(errors.Wrap(err, "unable to write inventory script"))
+	}
+}
+
+// serveInventory receives the inventory posted by the collection script, see
+// ipxe.Script.CollectInventory, refusing reports without a nonce issued to the client,
+// see inventoryNonces. A hardware record is created from it if the backend supports it,
+// otherwise it is stored for an operator to review. The response is the iPXE script the
+// machine runs next, which boots it again once it has a record.
+func (s *BootsHTTPServer) serveInventory(w http.ResponseWriter, req *http.Request) {
+	labels := prometheus.Labels{"from": "http", "op": "inventory"}
+	metrics.JobsTotal.With(labels).Inc()
+	metrics.JobsInProgress.With(labels).Inc()
+	defer metrics.JobsInProgress.With(labels).Dec()
+	timer := prometheus.NewTimer(metrics.JobDuration.With(labels))
+	defer timer.ObserveDuration()
+
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		if bodyTooLarge(req) {
+			refuseBodyTooLarge(w, req, s.maxRequestBody)
+
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		mainlog.With("client", req.RemoteAddr).Error(errors.Wrap(err, "parsing inventory report"))
+
+		return
+	}
+	if !s.inventoryNonces.redeem(req.PostForm.Get("nonce"), req.RemoteAddr) {
+		w.WriteHeader(http.StatusUnauthorized)
+		mainlog.With("client", req.RemoteAddr).Info("refusing inventory report without a nonce issued to the client")
+
+		return
+	}
+	inv := client.Inventory{
+		Time:         time.Now().UTC(),
+		Client:       req.RemoteAddr,
+		MAC:          req.PostForm.Get("mac"),
+		IP:           req.PostForm.Get("ip"),
+		Arch:         req.PostForm.Get("arch"),
+		Platform:     req.PostForm.Get("platform"),
+		Manufacturer: req.PostForm.Get("manufacturer"),
+		Product:      req.PostForm.Get("product"),
+		Serial:       req.PostForm.Get("serial"),
+		UUID:         req.PostForm.Get("uuid"),
+		Asset:        req.PostForm.Get("asset"),
+		MemSize:      req.PostForm.Get("memsize"),
+	}
+	for i := 0; i < ipxe.InventoryNICs; i++ {
+		if mac := req.PostForm.Get(fmt.Sprintf("net%d", i)); mac != "" {
+			inv.NICs = append(inv.NICs, mac)
+		}
+	}
+	if inv.MAC == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		mainlog.With("client", req.RemoteAddr).Error(errors.New("inventory report has no mac"))
+
+		return
+	}
+	logger := mainlog.With("client", req.RemoteAddr, "mac", inv.MAC, "serial", inv.Serial)
+
+	outcome := inventoryFailed
+	if creator, ok := s.finder.(client.HardwareCreator); ok {
+		id, err := creator.CreateFromInventory(req.Context(), inv)
+		if err == nil {
+			outcome = inventoryCreated
+			logger = logger.With("hardware.id", id)
+		} else {
+			logger.Error(errors.WithMessage(err, "creating hardware from inventory"))
+		}
+	}
+	if outcome != inventoryCreated && s.inventory != nil {
+		if err := s.inventory.record(inv); err == nil {
+			outcome = inventoryStored
+		} else {
+			logger.Error(err)
+		}
+	}
+	metrics.InventoryReports.With(prometheus.Labels{"outcome": outcome}).Inc()
+	logger.With("outcome", outcome).Info("received inventory")
+
+	sc := ipxe.NewScript()
+	sc.Set("tinkerbell", requestScheme(req)+"://"+conf.PublicFQDN)
+	switch outcome {
+	case inventoryCreated:
+		sc.Echo("Hardware record created, booting")
+	case inventoryStored:
+		sc.Echo("Inventory recorded, waiting for an operator to create a hardware record")
+		sc.Sleep(int(s.inventoryRetry.Seconds()))
+	default:
+		sc.Echo("Unable to record inventory")
+		sc.Sleep(int(s.inventoryRetry.Seconds()))
+	}
+	sc.Chain("${tinkerbell}/auto.ipxe")
+	if _, err := w.Write(sc.Bytes()); err != nil {
+		logger.Error(errors.Wrap(err, "unable to write inventory response script"))
+	}
+}
+
+// validateCollectInventory checks that the inventory reports of -collect-inventory can
+// either create a hardware record with finder or be stored in the inventory log.
+func validateCollectInventory(finder client.HardwareFinder, inventoryLog string) error {
+	if _, ok := finder.(client.HardwareCreator); !ok && inventoryLog == "" {
+		return errors.New("-collect-inventory requires -inventory-log, the hardware backend can not create hardware records")
+	}
+
+	return nil
+}
+
+// validateInventoryRetry checks that d is a whole number of seconds, at least one, for iPXE's sleep.
+func validateInventoryRetry(d time.Duration) error {
+	if d < time.Second || d%time.Second != 0 {
+		return errors.Errorf("invalid -inventory-retry %s, must be a whole number of seconds", d)
+	}
+
+	return nil
+}
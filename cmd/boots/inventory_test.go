@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/conf"
+)
+
+// creatingFinder is a hardware backend that can create hardware records.
+type creatingFinder struct {
+	client.HardwareFinder
+	created []client.Inventory
+	err     error
+}
+
+func (f *creatingFinder) CreateFromInventory(_ context.Context, inv client.Inventory) (client.HardwareID, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	f.created = append(f.created, inv)
+
+	return "hw-1", nil
+}
+
+func TestInventoryScript(t *testing.T) {
+	nonces := newInventoryNonces()
+	req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.TLS = &tls.ConnectionState{}
+	got := string(inventoryScript(nonces, req))
+	var nonce string
+	for nonce = range nonces.issued {
+	}
+	for _, want := range []string{
+		"set tinkerbell https://" + conf.PublicFQDN + "\n",
+		"param nonce " + nonce + "\n",
+		"param mac ${mac}\n",
+		"param serial ${serial}\n",
+		"param memsize ${memsize}\n",
+		"param net3 ${net3/mac}\n",
+		"chain --autofree ${tinkerbell}/inventory##params\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("inventory script missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestInventoryNonces(t *testing.T) {
+	n := newInventoryNonces()
+	now := time.Now()
+	n.now = func() time.Time { return now }
+
+	nonce := n.issue("192.0.2.1:1234")
+	if n.redeem(nonce, "192.0.2.2:1234") {
+		t.Fatal("want a nonce refused from another address")
+	}
+	if n.redeem("nope", "192.0.2.1:1234") {
+		t.Fatal("want an unknown nonce refused")
+	}
+	if !n.redeem(nonce, "192.0.2.1:4321") {
+		t.Fatal("want the nonce accepted from the address it was issued to")
+	}
+	if n.redeem(nonce, "192.0.2.1:4321") {
+		t.Fatal("want a used nonce refused")
+	}
+
+	nonce = n.issue("192.0.2.1")
+	now = now.Add(inventoryNonceTTL)
+	if n.redeem(nonce, "192.0.2.1") {
+		t.Fatal("want an expired nonce refused")
+	}
+
+	for i := 0; i < inventoryMaxNonces+1; i++ {
+		n.issue("192.0.2.1")
+	}
+	if len(n.issued) != inventoryMaxNonces {
+		t.Fatalf("want at most %d nonces kept, got %d", inventoryMaxNonces, len(n.issued))
+	}
+}
+
+func TestServeInventory(t *testing.T) {
+	form := url.Values{
+		"nonce":  {"issued"},
+		"mac":    {"02:00:00:00:00:01"},
+		"serial": {"SN123"},
+		"net0":   {"02:00:00:00:00:01"},
+		"net1":   {"02:00:00:00:00:02"},
+		"net2":   {""},
+	}
+	tests := map[string]struct {
+		finder  client.HardwareFinder
+		store   bool
+		form    url.Values
+		status  int
+		want    string
+		stored  bool
+		created bool
+		// client is the address the report is posted from, the nonce is issued to 192.0.2.1
+		client string
+	}{
+		"created":             {finder: &creatingFinder{}, store: true, form: form, status: http.StatusOK, want: "Hardware record created", created: true},
+		"stored":              {store: true, form: form, status: http.StatusOK, want: "waiting for an operator", stored: true},
+		"create fails stored": {finder: &creatingFinder{err: errors.New("backend down")}, store: true, form: form, status: http.StatusOK, want: "waiting for an operator", stored: true},
+		"not stored":          {form: form, status: http.StatusOK, want: "Unable to record inventory"},
+		"no mac":              {store: true, form: url.Values{"nonce": {"issued"}, "serial": {"SN123"}}, status: http.StatusBadRequest},
+		"no nonce":            {finder: &creatingFinder{}, store: true, form: url.Values{"mac": {"02:00:00:00:00:01"}}, status: http.StatusUnauthorized},
+		"wrong nonce":         {finder: &creatingFinder{}, store: true, form: url.Values{"nonce": {"nope"}, "mac": {"02:00:00:00:00:01"}}, status: http.StatusUnauthorized},
+		"other client":        {finder: &creatingFinder{}, store: true, form: form, client: "192.0.2.2:1234", status: http.StatusUnauthorized},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var log bytes.Buffer
+			nonces := newInventoryNonces()
+			nonces.issued["issued"] = inventoryNonce{ip: "192.0.2.1", expires: time.Now().Add(time.Minute)}
+			s := &BootsHTTPServer{finder: tt.finder, inventoryRetry: time.Minute, inventoryNonces: nonces}
+			if tt.store {
+				s.inventory = &inventoryRecorder{w: &log}
+			}
+			req := httptest.NewRequest(http.MethodPost, "/inventory", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.RemoteAddr = "192.0.2.1:1234"
+			if tt.client != "" {
+				req.RemoteAddr = tt.client
+			}
+			w := httptest.NewRecorder()
+			s.serveInventory(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("want status: %d, got: %d", tt.status, w.Code)
+			}
+			if f, ok := tt.finder.(*creatingFinder); ok && tt.status != http.StatusOK && len(f.created) != 0 {
+				t.Fatalf("want no record created from a refused report, got %+v", f.created)
+			}
+			if tt.status != http.StatusOK {
+				if log.Len() != 0 {
+					t.Fatalf("want a refused report not stored, got %q", log.String())
+				}
+
+				return
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, tt.want) || !strings.HasSuffix(body, "chain --autofree ${tinkerbell}/auto.ipxe\n") {
+				t.Fatalf("unexpected response script:\n%s", body)
+			}
+			if f, ok := tt.finder.(*creatingFinder); ok && (len(f.created) == 1) != tt.created {
+				t.Fatalf("want created: %t, got: %d records", tt.created, len(f.created))
+			}
+			if stored := log.Len() > 0; stored != tt.stored {
+				t.Fatalf("want stored: %t, got: %q", tt.stored, log.String())
+			}
+			if !tt.stored {
+				return
+			}
+			var inv client.Inventory
+			if err := json.Unmarshal(log.Bytes(), &inv); err != nil {
+				t.Fatal(err)
+			}
+			if inv.MAC != "02:00:00:00:00:01" || inv.Serial != "SN123" || len(inv.NICs) != 2 {
+				t.Fatalf("unexpected stored inventory: %+v", inv)
+			}
+		})
+	}
+}
+
+func TestServeInventoryBodyLimit(t *testing.T) {
+	s := &BootsHTTPServer{finder: &creatingFinder{}, inventoryRetry: time.Minute, inventoryNonces: newInventoryNonces(), maxRequestBody: 64}
+	form := url.Values{"nonce": {s.inventoryNonces.issue("192.0.2.1")}, "mac": {"02:00:00:00:00:01"}, "product": {strings.Repeat("x", 64)}}
+	req := httptest.NewRequest(http.MethodPost, "/inventory", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	limitBody(s.maxRequestBody, s.serveInventory)(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("want status %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+func TestValidateCollectInventory(t *testing.T) {
+	tests := map[string]struct {
+		finder       client.HardwareFinder
+		inventoryLog string
+		wantErr      bool
+	}{
+		"creator":           {finder: &creatingFinder{}},
+		"inventory log":     {finder: cachedFinder{}, inventoryLog: "inventory.jsonl"},
+		"nowhere to report": {finder: cachedFinder{}, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := validateCollectInventory(tt.finder, tt.inventoryLog); (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
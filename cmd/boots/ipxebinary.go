@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/ipxedust/binary"
+)
+
+// checkServedBinaries checks that the -dhcp-boot-filenames files are iPXE binaries Boots
+// serves, for when it serves them itself rather than -ipxe-remote-tftp-addr or
+// -ipxe-remote-http-addr. A machine sent a file that is not served can not boot.
+func checkServedBinaries(filenames []string) error {
+	for _, filename := range filenames {
+		if _, ok := binary.Files[filename]; ok {
+			continue
+		}
+		served := make([]string, 0, len(binary.Files))
+		for name := range binary.Files {
+			served = append(served, name)
+		}
+		sort.Strings(served)
+
+		return errors.Errorf("-dhcp-boot-filenames file %q is not served, the iPXE binaries served are %s, others need -ipxe-remote-tftp-addr or -ipxe-remote-http-addr", filename, strings.Join(served, ", "))
+	}
+
+	return nil
+}
+
+// serveIPXEBinaryRanges returns h, which serves the iPXE binaries, answering Range requests
+// for them with http.ServeContent so firmware that fetches them in parts gets a 206, or a
+// 416 for a range outside the binary. iPXE binary responses advertise byte ranges,
+// requests without a Range header and files that are not a binary are served by h.
+func serveIPXEBinaryRanges(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		name := path.Base(req.URL.Path)
+		file, ok := binary.Files[name]
+		if !ok || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+			h(w, req)
+
+			return
+		}
+		w.Header().Set("Accept-Ranges", "bytes")
+		if req.Header.Get("Range") == "" {
+			h(w, req)
+
+			return
+		}
+		mainlog.With("client", req.RemoteAddr, "filename", name, "range", req.Header.Get("Range")).Info("serving iPXE binary range")
+		http.ServeContent(w, req, name, time.Time{}, bytes.NewReader(file))
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tinkerbell/ipxedust/binary"
+)
+
+func TestServeIPXEBinaryRanges(t *testing.T) {
+	file := binary.Files["ipxe.efi"]
+	full := func(w http.ResponseWriter, req *http.Request) {
+		if f, ok := binary.Files["ipxe.efi"]; ok && req.URL.Path == "/ipxe/ipxe.efi" {
+			_, _ = w.Write(f)
+
+			return
+		}
+		http.NotFound(w, req)
+	}
+	tests := map[string]struct {
+		path         string
+		rangeHeader  string
+		wantStatus   int
+		wantBody     []byte
+		acceptRanges string
+	}{
+		"whole binary":  {path: "/ipxe/ipxe.efi", wantStatus: http.StatusOK, wantBody: file, acceptRanges: "bytes"},
+		"mid-file":      {path: "/ipxe/ipxe.efi", rangeHeader: "bytes=1024-2047", wantStatus: http.StatusPartialContent, wantBody: file[1024:2048], acceptRanges: "bytes"},
+		"unsatisfiable": {path: "/ipxe/ipxe.efi", rangeHeader: "bytes=100000000-", wantStatus: http.StatusRequestedRangeNotSatisfiable, acceptRanges: "bytes"},
+		"not a binary":  {path: "/ipxe/nope.efi", rangeHeader: "bytes=0-10", wantStatus: http.StatusNotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			w := httptest.NewRecorder()
+			serveIPXEBinaryRanges(full)(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Accept-Ranges"); got != tt.acceptRanges {
+				t.Fatalf("want Accept-Ranges %q, got %q", tt.acceptRanges, got)
+			}
+			if tt.wantBody != nil && !bytes.Equal(w.Body.Bytes(), tt.wantBody) {
+				t.Fatalf("want %d bytes of the binary, got %d", len(tt.wantBody), w.Body.Len())
+			}
+		})
+	}
+}
+
+func TestCheckServedBinaries(t *testing.T) {
+	tests := map[string]struct {
+		filenames []string
+		wantErr   bool
+	}{
+		"none":       {},
+		"served":     {filenames: []string{"ipxe.efi", "snp.efi", "undionly.kpxe"}},
+		"not served": {filenames: []string{"undionly.kpxe", "ipxe.pxe"}, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := checkServedBinaries(tt.filenames); (err != nil) != tt.wantErr {
+				t.Fatalf("want error %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net"
+)
+
+// listenConfig returns the net.ListenConfig used to bind the HTTP, DHCP, and
+// TFTP listeners.
+//
+// When reusePort is set, SO_REUSEPORT is enabled on every listening socket so
+// that a new Boots instance can bind the same ports before the old instance
+// exits. This is only supported on Linux, where the kernel load balances
+// incoming connections and datagrams across all sockets bound to the port. Both
+// instances will be serving traffic for the duration of the hand-off, so they
+// should be running compatible configurations.
+//
+// tcpKeepAlive is the keep-alive period for accepted TCP connections. Zero uses
+// the Go default and a negative value disables keep-alives.
+func (cf *config) listenConfig() net.ListenConfig {
+	lc := net.ListenConfig{KeepAlive: cf.tcpKeepAlive}
+	if cf.reusePort {
+		lc.Control = reusePortControl
+	}
+
+	return lc
+}
@@ -0,0 +1,26 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// reusePortSupported reports whether SO_REUSEPORT can be enabled on this platform.
+const reusePortSupported = true
+
+// reusePortControl enables SO_REUSEPORT on the socket before it is bound.
+func reusePortControl(_, _ string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if err != nil {
+		return errors.Wrap(err, "accessing raw socket")
+	}
+
+	return errors.Wrap(sockErr, "setting SO_REUSEPORT")
+}
@@ -0,0 +1,37 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestListenConfigReusePort(t *testing.T) {
+	cfg := &config{reusePort: true}
+	lc := cfg.listenConfig()
+
+	first, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	second, err := lc.Listen(context.Background(), "tcp", first.Addr().String())
+	if err != nil {
+		t.Fatalf("second listener could not bind %v: %v", first.Addr(), err)
+	}
+	second.Close()
+
+	pc, err := lc.ListenPacket(context.Background(), "udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	pc2, err := lc.ListenPacket(context.Background(), "udp4", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("second packet listener could not bind %v: %v", pc.LocalAddr(), err)
+	}
+	pc2.Close()
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// reusePortSupported reports whether SO_REUSEPORT can be enabled on this platform.
+const reusePortSupported = false
+
+// reusePortControl always fails, SO_REUSEPORT is only supported on Linux.
+func reusePortControl(_, _ string, _ syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is only supported on linux")
+}
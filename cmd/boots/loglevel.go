@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"unsafe"
+
+	"github.com/packethost/pkg/log"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// parseLogLevel returns the zap level of a -log-level, e.g. debug, info or error.
+func parseLogLevel(level string) (zapcore.Level, error) {
+	l, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return l, errors.Errorf("invalid -log-level %q, must be debug, info, warn or error", level)
+	}
+
+	return l, nil
+}
+
+// leveledLogger returns l, built by log.Init at debug level, logging only the entries
+// enabled by level, so the level can be changed while Boots runs. log.Init fixes the
+// level of the zap logger inside a log.Logger and does not expose it, so it is
+// replaced with one whose core is filtered by level. Loggers derived from l before
+// this call keep logging at debug level.
+func leveledLogger(l log.Logger, level zap.AtomicLevel) (log.Logger, error) {
+	s, err := zapLogger(&l)
+	if err != nil {
+		return l, err
+	}
+	leveled := s.Interface().(*zap.SugaredLogger).Desugar().WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		var leveled zapcore.Core
+		if leveled, err = zapcore.NewIncreaseLevelCore(c, level); err != nil {
+			return c
+		}
+
+		return leveled
+	}))
+	if err != nil {
+		return l, errors.Wrap(err, "set the log level")
+	}
+	s.Set(reflect.ValueOf(leveled.Sugar()))
+
+	return l, nil
+}
+
+// zapLogger returns the settable *zap.SugaredLogger inside l.
+func zapLogger(l *log.Logger) (reflect.Value, error) {
+	field := reflect.ValueOf(l).Elem().FieldByName("s")
+	if !field.IsValid() || field.Type() != reflect.TypeOf(&zap.SugaredLogger{}) {
+		return reflect.Value{}, errors.New("github.com/packethost/pkg/log has no zap logger to set the level of")
+	}
+
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem(), nil //nolint:gosec // log.Logger has no other way to change its level
+}
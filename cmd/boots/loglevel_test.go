@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/packethost/pkg/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLeveledLogger(t *testing.T) {
+	t.Setenv("LOG_DISCARD_LOGS", "1")
+	level := flag.Lookup("log-level").Value.String()
+	defer func() { _ = flag.Set("log-level", level) }()
+	_ = flag.Set("log-level", "debug")
+	l, err := log.Init("github.com/tinkerbell/boots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	atomic := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	l, err = leveledLogger(l, atomic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := l.Package("main").With("k", "v")
+
+	s, err := zapLogger(&pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	core := s.Interface().(*zap.SugaredLogger).Desugar().Core()
+	if core.Enabled(zapcore.DebugLevel) || !core.Enabled(zapcore.InfoLevel) {
+		t.Fatal("want info logged and debug dropped")
+	}
+	atomic.SetLevel(zapcore.DebugLevel)
+	if !core.Enabled(zapcore.DebugLevel) {
+		t.Fatal("want debug logged after the level changed")
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for level, wantErr := range map[string]bool{"debug": false, "info": false, "error": false, "loud": true} {
+		if _, err := parseLogLevel(level); (err != nil) != wantErr {
+			t.Errorf("%s: want error %t, got: %v", level, wantErr, err)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"text/tabwriter"
 	"time"
@@ -22,6 +23,7 @@ import (
 	"github.com/packethost/pkg/log"
 	"github.com/peterbourgon/ff/v3"
 	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/peterbourgon/ff/v3/ffyaml"
 	"github.com/pkg/errors"
 	"github.com/tinkerbell/boots/client"
 	"github.com/tinkerbell/boots/client/kubernetes"
@@ -31,14 +33,15 @@ import (
 	"github.com/tinkerbell/boots/httplog"
 	"github.com/tinkerbell/boots/installers"
 	"github.com/tinkerbell/boots/installers/customipxe"
+	"github.com/tinkerbell/boots/installers/ipxetemplate"
 	"github.com/tinkerbell/boots/installers/osie"
+	"github.com/tinkerbell/boots/ipxe"
 	"github.com/tinkerbell/boots/job"
 	"github.com/tinkerbell/boots/metrics"
 	"github.com/tinkerbell/boots/syslog"
 	"github.com/tinkerbell/ipxedust"
 	"github.com/tinkerbell/ipxedust/ihttp"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -48,22 +51,31 @@ var (
 	mainlog log.Logger
 
 	GitRev    = "unknown (use make)"
+	BuildDate = "unknown (use make)"
 	StartTime = time.Now()
 )
 
 const name = "boots"
 
 type config struct {
+	// configFile is an optional YAML file of flag values keyed by flag name, re-read on SIGHUP
+	configFile string
 	// ipxe holds the config for serving ipxe binaries
 	ipxe ipxedust.Command
 	// ipxeTFTPEnabled determines if local iPXE binaries served via TFTP are enabled
 	ipxeTFTPEnabled bool
 	// ipxeHTTPEnabled determines if local iPXE binaries served via TFTP are enabled
 	ipxeHTTPEnabled bool
+	// tftpMaxConcurrent bounds the TFTP transfers in flight, 0 does not bound them
+	tftpMaxConcurrent int
+	// tftpQueueTimeout is how long a TFTP read request waits for a transfer slot before it is rejected
+	tftpQueueTimeout time.Duration
 	// ipxeRemoteTFTPAddr is the address of the remote TFTP server serving iPXE binaries
 	ipxeRemoteTFTPAddr string
 	// ipxeRemoteHTTPAddr is the address and port of the remote HTTP server serving iPXE binaries
 	ipxeRemoteHTTPAddr string
+	// ipxeRemoteHTTPScheme is the scheme of the remote HTTP server serving iPXE binaries
+	ipxeRemoteHTTPScheme string
 	// ipxeVars are additional variable definitions to include in all iPXE installer
 	// scripts. See https://ipxe.org/cfg. Separate multiple var definitions with spaces,
 	// e.g. 'var1=val1 var2=val2'. Note that settings which require spaces (e.g, scriptlets)
@@ -71,40 +83,339 @@ type config struct {
 	ipxeVars string
 	// httpAddr is the address of the HTTP server serving the iPXE script and other installer assets
 	httpAddr string
-	// dhcpAddr is the local address for the DHCP server
+	// dhcpAddr is the comma separated local addresses for the DHCP server
 	dhcpAddr string
 	// syslogAddr is the local address for the syslog server
 	syslogAddr string
 	// loglevel is the log level for boots
 	logLevel string
+	// ipxeTemplateDir is a directory of iPXE script templates, each served to the hardware of the facility it is named after
+	ipxeTemplateDir string
+	// installerArches restricts the arches installers serve, space separated installer=arch,arch entries
+	installerArches string
+	// installerPriority is the order installers are selected in, comma separated kinds, "|" joining kinds that tie
+	installerPriority string
 	// extraKernelArgs are key=value pairs to be added as kernel commandline to the kernel in iPXE for OSIE
 	extraKernelArgs string
+	// extraKernelArgsArch are the kernel args added after extraKernelArgs for each arch, arch=args separated by ;
+	extraKernelArgsArch string
+	// osieKernelArgs are the OSIE installer's default kernel args for installs and rescues, overridden by extraKernelArgs
+	osieKernelArgs string
+	// osieDiscoverKernelArgs are the OSIE installer's default kernel args for discovery, overridden by extraKernelArgs
+	osieDiscoverKernelArgs string
+	// customIPXEKernelArgs are the custom iPXE installer's default kernel args, overridden by extraKernelArgs
+	customIPXEKernelArgs string
 	// kubeConfig is the path to a kubernetes config file
 	kubeconfig string
+	// kubeconfigContent is a kubernetes config, YAML or base64 encoded YAML, used instead of a file
+	kubeconfigContent string
 	// kubeAPI is the Kubernetes API URL
 	kubeAPI string
 	// kubeNamespace is an override for the namespace the kubernetes client will watch.
 	kubeNamespace string
+	// kubeQPS is the max number of Kubernetes API requests per second, 0 uses the client-go default
+	kubeQPS float64
+	// kubeBurst is the max burst of Kubernetes API requests, 0 uses the client-go default
+	kubeBurst int
+	// kubeLocalIndex answers hardware lookups from a local index of Hardware kept current by an informer
+	kubeLocalIndex bool
+	// kubeNamespaces restricts hardware lookups to a comma separated list of namespaces
+	kubeNamespaces string
+	// kubeNamespacePrecedence is a comma separated list of namespaces preferred, in order, when a lookup matches hardware in several
+	kubeNamespacePrecedence string
 	// osiePathOverride allows a completely custom path/URL to be specified for OSIE/Hook images
 	// This will bypass the hardcoded path appending of 'misc/osie/current' to the path
 	osiePathOverride string
+	// osieMirrors is a comma separated list of URL[=weight] OSIE/Hook mirrors used instead of osiePathOverride
+	osieMirrors string
+	// osieFacilityURLs is a comma separated list of facility=URL OSIE/Hook base URLs, taking precedence over osieMirrors and osiePathOverride
+	osieFacilityURLs string
+	// osieMirrorSelection is how a mirror is picked for each boot, round-robin or mac-hash
+	osieMirrorSelection string
+	// osieChecksums is a sha256sum manifest of the OSIE/Hook images boot scripts are only served for if they match
+	osieChecksums string
+	// osieChecksumInterval is how often an image is verified again against osieChecksums
+	osieChecksumInterval time.Duration
+	// osieVerifier verifies the images against osieChecksums, it is set at startup and kept across reloads
+	osieVerifier *osie.Verifier
+	// reusePort enables SO_REUSEPORT on the HTTP, DHCP, and TFTP listeners (Linux only)
+	reusePort bool
+	// tcpKeepAlive is the keep-alive period for accepted TCP connections
+	tcpKeepAlive time.Duration
+	// userAgentArch falls back to the arch named in the User-Agent of boot script requests
+	userAgentArch bool
+	// staticNetworkKernelArgs passes the hw's static network config to OSIE/Hook as ip= kernel args instead of ip=dhcp
+	staticNetworkKernelArgs bool
+	// httpReadHeaderTimeout is how long the HTTP server waits for a request's headers
+	httpReadHeaderTimeout time.Duration
+	// httpWriteTimeout is how long the HTTP server takes to write a response before it gives up, 0 is no limit
+	httpWriteTimeout time.Duration
+	// httpExtraHeaders are "Key: Value" headers added to the job file responses
+	httpExtraHeaders headerValues
+	// contentTypeMap is ".ext=type" pairs overriding the Content-Type of served files
+	contentTypeMap string
+	// problemJSON describes failed requests with application/problem+json bodies to clients that accept them
+	problemJSON bool
+	// fileServeTimeout is how long an iPXE script or binary transfer may take, 0 is no limit
+	fileServeTimeout time.Duration
+	// fileServeIdleTimeout is how long an iPXE script or binary transfer may make no progress, 0 is no limit
+	fileServeIdleTimeout time.Duration
+	// httpRateLimit is the number of job file and phone-home requests a second allowed per client IP, 0 disables rate limiting
+	httpRateLimit float64
+	// httpRateBurst is the number of requests a client may make at once above httpRateLimit
+	httpRateBurst int
+	// httpLogFormat is the access log format, text or json
+	httpLogFormat string
+	// httpLogFields is a comma separated list of the optional access log fields
+	httpLogFields string
+	// xffStrict rejects requests with an X-Forwarded-For header from peers outside TRUSTED_PROXIES
+	xffStrict bool
+	// httpIdleTimeout is how long the HTTP server keeps idle keep-alive connections open, 0 uses httpReadHeaderTimeout
+	httpIdleTimeout time.Duration
+	// httpTLSCert is a PEM certificate file the HTTP server serves TLS with
+	httpTLSCert string
+	// httpTLSKey is the PEM private key file of httpTLSCert
+	httpTLSKey string
+	// tlsMinVersion is the minimum TLS version accepted by the HTTPS server
+	tlsMinVersion string
+	// tlsCipherSuites is a comma separated allow-list of TLS 1.2 cipher suites for the HTTPS server
+	tlsCipherSuites string
+	// tlsClientCA is a PEM file of CAs used to verify client certificates presented to the HTTPS server
+	tlsClientCA string
+	// phoneHomeClientCert requires phone-home requests to authenticate with a client certificate
+	phoneHomeClientCert bool
+	// ipxeReportFailures makes OSIE/Hook boot scripts report failed kernel, initrd and boot commands to Boots
+	ipxeReportFailures bool
+	// bootFailureLog is an optional file that reported boot failures are appended to as JSON lines
+	bootFailureLog string
+	// dhcpMode is whether DHCP assigns addresses, only serves PXE clients alongside another DHCP server, or is disabled
+	dhcpMode string
+	// dhcpAuthoritative NAKs DHCPREQUESTs for addresses or leases Boots does not own instead of dropping them
+	dhcpAuthoritative bool
+	// dhcpInvalidMAC is how DHCP packets with an all zero or broadcast chaddr are handled
+	dhcpInvalidMAC string
+	// dhcpLeaseTime overrides the lease time of the hardware records, 0 keeps it
+	dhcpLeaseTime time.Duration
+	// dhcpRenewalTime is the T1 renewal time of the leases, 0 leaves it to the client
+	dhcpRenewalTime time.Duration
+	// dhcpRebindingTime is the T2 rebinding time of the leases, 0 leaves it to the client
+	dhcpRebindingTime time.Duration
+	// dhcpArchMap is a comma separated list of option 93 arch=profile pairs overriding the iPXE binary sent to PXE clients
+	dhcpArchMap string
+	// dhcpBootFilenames is a comma separated list of option 93 arch/firmware=filename pairs overriding the iPXE binary sent to PXE clients
+	dhcpBootFilenames string
+	// dhcpIPXEUserClasses is a comma separated list of the user-classes of iPXE builds besides Tinkerbell's that are sent the boot script URL
+	dhcpIPXEUserClasses string
+	// dhcpUnknownArch is how PXE clients whose option 93 arch is neither mapped nor known are handled
+	dhcpUnknownArch string
+	// dhcpUnknownArchBootfile is the boot filename sent to clients with an unknown arch by the bootfile policy
+	dhcpUnknownArchBootfile string
+	// backendRetries is the number of times a hardware or workflow lookup is retried after a transient backend failure
+	backendRetries int
+	// backendRetryMaxInterval is the longest wait between retries of a lookup
+	backendRetryMaxInterval time.Duration
+	// breakerFailures is the number of consecutive failed backend lookups that open the circuit breaker, 0 disables it
+	breakerFailures int
+	// breakerWindow is how close together the consecutive failures that open the circuit breaker must be, 0 does not bound them
+	breakerWindow time.Duration
+	// breakerCooldown is how long the circuit breaker fails lookups fast before probing the backend
+	breakerCooldown time.Duration
+	// backendEndpointCooldown is how long a tink server that failed is skipped for workflow lookups while others are healthy
+	backendEndpointCooldown time.Duration
+	// hardwareCacheTTL is how long hardware lookups by DHCP and HTTP requests are cached, 0 disables the cache
+	hardwareCacheTTL time.Duration
+	// hardwareCacheSize is the number of hardware lookups cached
+	hardwareCacheSize int
+	// hardwareFile is the JSON or YAML file of hardware records used when DATA_MODEL_VERSION=file
+	hardwareFile string
+	// hardwareFileInterval is how often the hardware file is checked for changes, 0 disables reloading
+	hardwareFileInterval time.Duration
+	// allowSyntheticBackend permits DATA_MODEL_VERSION=synthetic, which fabricates hardware for load testing
+	allowSyntheticBackend bool
+	// syntheticLatency is how long every synthetic backend lookup takes
+	syntheticLatency time.Duration
+	// syntheticNotFoundPercent is the percentage of MACs the synthetic backend has no hardware for
+	syntheticNotFoundPercent int
+	// hardwareMissingField is the action for hardware records missing a required field, empty disables validation
+	hardwareMissingField string
+	// hardwareDefaultFacility is the facility used for records missing one by the default action
+	hardwareDefaultFacility string
+	// hardwareDefaultArch is the arch used for records missing one by the default action
+	hardwareDefaultArch string
+	// dhcpRelaySubnets is a comma separated list of CIDRs DHCP relays are configured in, empty accepts every relay
+	dhcpRelaySubnets string
+	// dhcpUnmatchedRelay is how packets relayed from a giaddr outside of dhcpRelaySubnets are handled
+	dhcpUnmatchedRelay string
+	// dhcpQuietStates is a comma separated list of states of machines done provisioning whose DISCOVERs are logged at debug level
+	dhcpQuietStates string
+	// dhcpQuietStateSource is where the state matched by dhcpQuietStates is read from, hardware or instance
+	dhcpQuietStateSource string
+	// dhcpQuietLogSample is the one in every N quiet DISCOVERs still logged at info level, 0 logs none
+	dhcpQuietLogSample int
+	// dhcpTraceMACs is a comma separated list of MACs, or "*", whose DHCP replies are logged with their full option set
+	dhcpTraceMACs string
+	// dhcpTraceRate is the max number of DHCP reply traces logged per second
+	dhcpTraceRate float64
+	// noNetbootResponse is how to respond to HTTP requests from hardware that has no netboot config
+	noNetbootResponse string
+	// allowPXEDefault allows hardware that has no netboot config to PXE and boot the default installer
+	allowPXEDefault bool
+	// pprofEnabled serves the pprof handlers
+	pprofEnabled bool
+	// pprofAddr is an optional address the pprof handlers are served on instead of httpAddr
+	pprofAddr string
+	// pprofAuthToken is the bearer token required by the pprof handlers, empty requires none
+	pprofAuthToken string
+	// metricsAuthToken is the bearer token required by /metrics, empty requires none
+	metricsAuthToken string
+	// printConfig prints the effective configuration as JSON and exits
+	printConfig bool
+	// printVersion prints the version and exits
+	printVersion bool
+	// check validates the configuration and reaches the hardware backend, then exits without serving
+	check bool
+	// configToken enables the /_packet/config endpoint, clients must present it as a bearer token
+	configToken string
+	// simulateToken enables the /_packet/simulate endpoint, clients must present it as a bearer token
+	simulateToken string
+	// maintenance starts Boots in maintenance mode, refusing all boots
+	maintenance bool
+	// maintenanceToken enables the /_packet/maintenance endpoint, clients must present it as a bearer token
+	maintenanceToken string
+	// drainToken enables the /_packet/drain endpoint, clients must present it as a bearer token
+	drainToken string
+	// logPackets logs the options of received DHCP packets and the iPXE scripts served at debug level
+	logPackets bool
+	// logPacketsRate is the max number of dumps logged per second per MAC
+	logPacketsRate float64
+	// logPacketsRedact are the comma separated DHCP options, script variables and kernel args whose values are not logged
+	logPacketsRedact string
+	// bootLoopThreshold is the number of boot script requests of a machine within bootLoopWindow past which it is boot looping, 0 disables detection
+	bootLoopThreshold int
+	bootLoopWindow    time.Duration
+	// bootLoopHalt serves boot looping machines an iPXE script that stops the loop
+	bootLoopHalt bool
+	// syslogForwardAddr is an optional [udp://|tcp://]host:port of a collector received syslog messages are relayed to
+	syslogForwardAddr string
+	// syslogForwardBuffer is the max number of syslog messages waiting to be forwarded
+	syslogForwardBuffer int
+	// syslogStreamToken enables the /syslog/stream endpoint, clients must present it as a bearer token
+	syslogStreamToken string
+	// phoneHomeLog is an optional file that phone-home events are appended to as JSON lines
+	phoneHomeLog string
+	// phoneHomeBatchWindow is how long phone-home events are collected before being persisted together
+	phoneHomeBatchWindow time.Duration
+	// phoneHomeBatchSize is the max number of phone-home events persisted together
+	phoneHomeBatchSize int
+	// maxRequestBody is the max size of phone-home, boot failure and simulate request bodies, 0 does not limit them
+	maxRequestBody int64
+	// activeBootsMaxAge is how long a machine stays in the active boots tracker after it was last seen
+	activeBootsMaxAge time.Duration
+	// activeBootsMaxEntries is the max number of machines in the active boots tracker
+	activeBootsMaxEntries int
+	// activeBootsToken enables the /active-boots endpoint, clients must present it as a bearer token
+	activeBootsToken string
+	// statusToken enables the /_packet/status/ and /_packet/stats endpoints, clients must present it as a bearer token
+	statusToken string
+	// phoneHomeStatusTTL is how long the install status a machine reported is kept after its last report
+	phoneHomeStatusTTL time.Duration
+	// phoneHomeStatusHistory is the max number of install statuses kept per machine
+	phoneHomeStatusHistory int
+	// allowedURLHosts is a comma separated list of hosts, or .domains, that OSIE and iPXE script URLs must be on
+	allowedURLHosts string
+	// allowedURLHostsFile is a file of allowed URL hosts, one per line
+	allowedURLHostsFile string
+	// caBundle is an optional PEM file of CA certificates served to booting machines at /ca.pem
+	caBundle string
+	// phoneHomeWebhook is an optional URL that a JSON notification is POSTed to for each processed phone-home
+	phoneHomeWebhook string
+	// phoneHomeWebhookTimeout bounds each delivery attempt of a phone-home notification
+	phoneHomeWebhookTimeout time.Duration
+	// phoneHomeWebhookRetries is the number of times a failed phone-home notification is retried
+	phoneHomeWebhookRetries int
+	// phoneHomeDedupWindow is how long repeated phone-homes of the same type from a MAC are not reprocessed
+	phoneHomeDedupWindow time.Duration
+	// phoneHomeDuplicateResponse is how a suppressed duplicate phone-home is responded to, ack or reject
+	phoneHomeDuplicateResponse string
+	// phoneHomeUnknownStatus is the response code to phone-homes from an address no job is found for
+	phoneHomeUnknownStatus int
+	// collectInventory serves machines without a hardware record an iPXE script that reports their inventory
+	collectInventory bool
+	// ipxeErrorScripts serves an iPXE script printing the reason a boot script is refused instead of a 404
+	ipxeErrorScripts bool
+	// fallbackIPXEScript is the iPXE script, inline or a file, served to boot script requests whose hardware lookup failed
+	fallbackIPXEScript string
+	// inventoryLog is an optional file that inventory reports are appended to as JSON lines
+	inventoryLog string
+	// inventoryRetry is how long machines whose inventory was stored for review wait before booting again
+	inventoryRetry time.Duration
+	// retryAfterMin is the Retry-After of load shedding responses when Boots is idle
+	retryAfterMin time.Duration
+	// retryAfterMax is the Retry-After of load shedding responses at retryAfterCapacity
+	retryAfterMax time.Duration
+	// retryAfterCapacity is the number of in-flight HTTP requests at which the Retry-After reaches retryAfterMax
+	retryAfterCapacity int
+	// readinessTimeout bounds the hardware backend check of the readiness endpoint
+	readinessTimeout time.Duration
+	// jobLookupTimeout bounds the hardware backend lookup of job file requests
+	jobLookupTimeout time.Duration
+	// shutdownTimeout is how long in-flight DHCP, HTTP, TFTP and syslog requests are given to finish on shutdown
+	shutdownTimeout time.Duration
 }
 
 func main() {
 	cfg := &config{}
 	cli := newCLI(cfg, flag.NewFlagSet(name, flag.ExitOnError))
-	_ = cli.Parse(os.Args[1:])
+	// boots has no Exec, so a successful parse still returns a NoExecError
+	var noExec ffcli.NoExecError
+	if err := cli.Parse(os.Args[1:]); err != nil && !errors.As(err, &noExec) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if cfg.printVersion {
+		if err := printVersion(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if cfg.printConfig {
+		if err := printConfig(os.Stdout, cli.FlagSet); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
-	// this flag.Set is needed to support how the log level is set in github.com/packethost/pkg/log
-	_ = flag.Set("log-level", cfg.logLevel)
+	level, err := parseLogLevel(cfg.logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logLevel := zap.NewAtomicLevelAt(level)
+	// this flag.Set is needed to support how the log level is set in github.com/packethost/pkg/log,
+	// the logger is built at debug level and filtered by logLevel so SIGHUP can change it
+	_ = flag.Set("log-level", "debug")
 	l, err := log.Init("github.com/tinkerbell/boots")
 	if err != nil {
 		panic(nil)
 	}
+	if l, err = leveledLogger(l, logLevel); err != nil {
+		l.Fatal(err)
+	}
 	defer l.Close()
 	mainlog = l.Package("main")
+	if cfg.check {
+		// mainlog.Fatal panics, report its error as the result of the check instead
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Fprintln(os.Stderr, r)
+				os.Exit(1)
+			}
+		}()
+	}
 
-	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGHUP, syscall.SIGTERM)
+	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer done()
 	ctx, otelShutdown := otelinit.InitOpenTelemetry(ctx, name)
 	defer otelShutdown(ctx)
@@ -118,37 +429,143 @@ func main() {
 	syslog.Init(l)
 	mainlog.With("version", GitRev).Info("starting")
 
-	workflowFinder, finder, err := getFinders(l, cfg)
+	if cfg.reusePort && !reusePortSupported {
+		mainlog.Fatal(errors.New("-reuse-port is only supported on linux"))
+	}
+	if !validDHCPModes[cfg.dhcpMode] {
+		mainlog.Fatal(errors.Errorf("invalid -dhcp-mode %q, must be one of: %s, %s, %s", cfg.dhcpMode, dhcpModeAuto, dhcpModeProxy, dhcpModeDisabled))
+	}
+	if cfg.dhcpAuthoritative && cfg.dhcpMode != dhcpModeAuto {
+		mainlog.Fatal(errors.New("-dhcp-authoritative requires -dhcp-mode auto"))
+	}
+	var dhcpAddrs []string
+	if cfg.dhcpMode != dhcpModeDisabled {
+		if dhcpAddrs, err = parseDHCPAddrs(cfg.dhcpAddr); err != nil {
+			mainlog.Fatal(err)
+		}
+	}
+	if !validInvalidMACActions[cfg.dhcpInvalidMAC] {
+		mainlog.Fatal(errors.Errorf("invalid -dhcp-invalid-mac %q, must be one of: %s, %s", cfg.dhcpInvalidMAC, invalidMACIgnore, invalidMACClientID))
+	}
+	if err := validateNoNetbootResponse(cfg.noNetbootResponse); err != nil {
+		mainlog.Fatal(err)
+	}
+	activeBoots, err := newActiveBoots(cfg.activeBootsMaxAge, cfg.activeBootsMaxEntries)
 	if err != nil {
 		mainlog.Fatal(err)
 	}
-	jobManager := job.NewCreator(l, provisionerEngineName, finder)
-
-	go func() {
-		mainlog.With("addr", cfg.syslogAddr).Info("serving syslog")
-		err = retry.Do(
-			func() error {
-				_, err := syslog.StartReceiver(cfg.syslogAddr, 1)
+	installStatuses, err := newInstallStatuses(cfg.phoneHomeStatusTTL, cfg.phoneHomeStatusHistory)
+	if err != nil {
+		mainlog.Fatal(err)
+	}
+	archPolicy, err := dhcp.NewArchPolicy(cfg.dhcpArchMap, cfg.dhcpBootFilenames, cfg.dhcpUnknownArch, cfg.dhcpUnknownArchBootfile)
+	if err != nil {
+		mainlog.Fatal(errors.WithMessage(err, "invalid -dhcp-arch-map, -dhcp-boot-filenames, -dhcp-unknown-arch or -dhcp-unknown-arch-bootfile"))
+	}
+	if cfg.ipxeRemoteTFTPAddr == "" && cfg.ipxeRemoteHTTPAddr == "" {
+		if err := checkServedBinaries(archPolicy.Filenames()); err != nil {
+			mainlog.Fatal(err)
+		}
+	}
+	ipxeUserClasses, err := ipxe.ParseUserClasses(cfg.dhcpIPXEUserClasses)
+	if err != nil {
+		mainlog.Fatal(errors.WithMessage(err, "invalid -dhcp-ipxe-user-classes"))
+	}
+	relayPolicy, err := dhcp.NewRelayPolicy(cfg.dhcpRelaySubnets, cfg.dhcpUnmatchedRelay)
+	if err != nil {
+		mainlog.Fatal(errors.WithMessage(err, "invalid -dhcp-relay-subnets or -dhcp-unmatched-relay"))
+	}
+	quietPolicy, err := job.NewQuietPolicy(cfg.dhcpQuietStateSource, strings.Split(cfg.dhcpQuietStates, ","), cfg.dhcpQuietLogSample)
+	if err != nil {
+		mainlog.Fatal(errors.WithMessage(err, "invalid -dhcp-quiet-states, -dhcp-quiet-state-source or -dhcp-quiet-log-sample"))
+	}
+	replyTracer, err := newReplyTracer(cfg.dhcpTraceMACs, cfg.dhcpTraceRate)
+	if err != nil {
+		mainlog.Fatal(err)
+	}
+	packetLog, err := newPacketLogger(cfg.logPackets, cfg.logPacketsRate, cfg.logPacketsRedact)
+	if err != nil {
+		mainlog.Fatal(err)
+	}
+	if packetLog != nil && cfg.logLevel != "debug" {
+		mainlog.With("logLevel", cfg.logLevel).Info("-log-packets dumps are logged at debug level, they are not logged until -log-level is debug")
+	}
+	lc := cfg.listenConfig()
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		mainlog.Fatal(err)
+	}
+	if cfg.phoneHomeDuplicateResponse != phoneHomeDuplicateAck && cfg.phoneHomeDuplicateResponse != phoneHomeDuplicateReject {
+		mainlog.Fatal(errors.Errorf("invalid -phone-home-duplicate-response %q, must be %s or %s", cfg.phoneHomeDuplicateResponse, phoneHomeDuplicateAck, phoneHomeDuplicateReject))
+	}
+	if err := validatePhoneHomeUnknownStatus(cfg.phoneHomeUnknownStatus); err != nil {
+		mainlog.Fatal(err)
+	}
 
-				return err
-			},
-		)
+	workflowFinder, finder, err := getFinders(l, cfg)
+	if err != nil {
+		mainlog.Fatal(err)
+	}
+	metrics.SetDataModel(os.Getenv("DATA_MODEL_VERSION"))
+	backoff, err := client.NewBackoff(cfg.backendRetries, cfg.backendRetryMaxInterval)
+	if err != nil {
+		mainlog.Fatal(errors.WithMessage(err, "invalid -backend-retries or -backend-retry-max-interval"))
+	}
+	breaker, err := client.NewBreaker(cfg.breakerFailures, cfg.breakerWindow, cfg.breakerCooldown)
+	if err != nil {
+		mainlog.Fatal(errors.WithMessage(err, "invalid -breaker-failures, -breaker-window or -breaker-cooldown"))
+	}
+	if breaker != nil {
+		breaker.OnChange(func(state string) {
+			mainlog.With("state", state, "failures", cfg.breakerFailures, "window", cfg.breakerWindow, "cooldown", cfg.breakerCooldown).Info("backend circuit breaker changed state")
+		})
+	}
+	workflowBackend := workflowFinder
+	// a lookup and its retries are a single failure to the breaker
+	workflowFinder = client.BreakWorkflowFinder(client.RetryWorkflowFinder(workflowFinder, backoff), breaker)
+	cachingFinder, err := client.NewCachingFinder(client.BreakHardwareFinder(client.RetryHardwareFinder(finder, backoff), breaker), cfg.hardwareCacheTTL, cfg.hardwareCacheSize)
+	if err != nil {
+		mainlog.Fatal(errors.WithMessage(err, "invalid -hardware-cache-ttl or -hardware-cache-size"))
+	}
+	jobManager := job.NewCreator(l, provisionerEngineName, cachingFinder)
+	if cfg.hardwareMissingField != "" {
+		v, err := job.NewRecordValidation(cfg.hardwareMissingField, job.RecordDefaults{Facility: cfg.hardwareDefaultFacility, Arch: cfg.hardwareDefaultArch})
 		if err != nil {
-			mainlog.Fatal(errors.Wrap(err, "retry syslog serve"))
+			mainlog.Fatal(errors.WithMessage(err, "invalid -hardware-missing-field, -hardware-default-facility or -hardware-default-arch"))
 		}
-	}()
+		jobManager.SetRecordValidation(v)
+	}
+	jobManager.SetAllowPXEDefault(cfg.allowPXEDefault)
+	leases, err := dhcp.NewLeaseTimes(cfg.dhcpLeaseTime, cfg.dhcpRenewalTime, cfg.dhcpRebindingTime)
+	if err != nil {
+		mainlog.Fatal(errors.WithMessage(err, "invalid -dhcp-lease-time, -dhcp-renewal-time or -dhcp-rebinding-time"))
+	}
+	jobManager.SetLeaseTimes(leases)
+	if leases != nil {
+		mainlog.With("lease_time", cfg.dhcpLeaseTime, "renewal_time", cfg.dhcpRenewalTime, "rebinding_time", cfg.dhcpRebindingTime).Info("dhcp leases use -dhcp-lease-time instead of the hardware records' lease time")
+	} else {
+		mainlog.Info("dhcp leases use the lease time of the hardware records")
+	}
 
+	syslogHub := syslog.NewHub()
+	syslogForwarder, err := syslog.NewForwarder(cfg.syslogForwardAddr, cfg.syslogForwardBuffer)
+	if err != nil {
+		mainlog.Fatal(errors.WithMessage(err, "invalid -syslog-forward-addr or -syslog-forward-buffer"))
+	}
 	g, ctx := errgroup.WithContext(ctx)
-	lg := defaultLogger(cfg.logLevel)
+	// stopped once the syslog receiver is shut down, so the messages received until then are forwarded
+	forwardCtx, stopForwarding := context.WithCancel(context.Background())
+	defer stopForwarding()
+	if syslogForwarder != nil {
+		mainlog.With("addr", cfg.syslogForwardAddr).Info("forwarding syslog")
+		g.Go(func() error { return syslogForwarder.Run(forwardCtx) })
+	}
+	lg := defaultLogger(logLevel)
 	lg = lg.WithValues("service", "github.com/tinkerbell/boots")
 	lg = lg.WithName("github.com/tinkerbell/ipxedust")
-	ipxe := &ipxedust.Server{
-		Log:                  lg,
-		EnableTFTPSinglePort: true,
-		TFTP:                 ipxedust.ServerSpec{Disabled: true},
-		HTTP:                 ipxedust.ServerSpec{Disabled: true},
-	}
 	var nextServer net.IP
+	var tftpServer *BootsTFTPServer
+	var tftpAddr string
 	if cfg.ipxeRemoteTFTPAddr == "" { // use local iPXE binary service for TFTP
 		if cfg.ipxeTFTPEnabled {
 			ipportTFTP, err := netip.ParseAddrPort(cfg.ipxe.TFTPAddr)
@@ -158,10 +575,19 @@ func main() {
 			if ipportTFTP.Port() != 69 {
 				mainlog.With("providedPort", ipportTFTP.Port()).Fatal(fmt.Errorf("port for tftp addr must be 69"))
 			}
-			ipxe.TFTP = ipxedust.ServerSpec{
-				Addr:    ipportTFTP,
-				Timeout: cfg.ipxe.TFTPTimeout,
+			limiter, err := newTFTPLimiter(cfg.tftpMaxConcurrent, cfg.tftpQueueTimeout)
+			if err != nil {
+				mainlog.Fatal(err)
+			}
+			tftpServer = &BootsTFTPServer{
+				log:         lg,
+				timeout:     cfg.ipxe.TFTPTimeout,
+				singlePort:  true,
+				limiter:     limiter,
+				activeBoots: activeBoots,
+				drainer:     newDrainer(),
 			}
+			tftpAddr = ipportTFTP.String()
 		}
 		nextServer = conf.PublicIPv4
 	} else { // use remote iPXE binary service for TFTP
@@ -176,44 +602,278 @@ func main() {
 	var ipxeHandler func(http.ResponseWriter, *http.Request)
 	var ipxePattern string
 	var ipxeBaseURL string
+	bootsScheme := "http"
+	if len(tlsConfig.Certificates) > 0 {
+		bootsScheme = "https"
+	}
+	ipxeScheme := bootsScheme
 	bootsBaseURL := conf.PublicFQDN
 	if cfg.ipxeRemoteHTTPAddr == "" { // use local iPXE binary service for HTTP
 		if cfg.ipxeHTTPEnabled {
-			ipxeHandler = ihttp.Handler{Log: lg}.Handle
+			ipxeHandler = serveIPXEBinaryRanges(ihttp.Handler{Log: lg}.Handle)
 		}
 		ipxePattern = "/ipxe/"
 		ipxeBaseURL = conf.PublicFQDN + ipxePattern
 		mainlog.With("addr", ipxeBaseURL).Info("serving iPXE binaries from local HTTP server")
 	} else { // use remote iPXE binary service for HTTP
-		ipxeBaseURL = cfg.ipxeRemoteHTTPAddr
-		mainlog.With("addr", ipxeBaseURL).Info("serving iPXE binaries from remote HTTP server")
+		ipxeScheme, ipxeBaseURL, err = parseIPXERemoteHTTPAddr(cfg.ipxeRemoteHTTPAddr, cfg.ipxeRemoteHTTPScheme)
+		if err != nil {
+			mainlog.Fatal(err)
+		}
+		mainlog.With("addr", ipxeBaseURL, "scheme", ipxeScheme).Info("serving iPXE binaries from remote HTTP server")
+	}
+
+	maintenance := newMaintenance(cfg.maintenance)
+	if cfg.maintenance {
+		mainlog.Info("starting in maintenance mode, all boots are refused")
 	}
-	g.Go(func() error {
-		return ipxe.ListenAndServe(ctx)
-	})
 
+	// the stats endpoint counts the hardware lookups of both servers
+	recentLookups := newRecentLookups()
 	httpServer := &BootsHTTPServer{
-		finder:         finder,
-		jobManager:     jobManager,
-		workflowFinder: workflowFinder,
+		finder:              finder,
+		jobManager:          jobManager,
+		workflowFinder:      workflowFinder,
+		workflowBackend:     workflowBackend,
+		listenConfig:        lc,
+		tlsConfig:           tlsConfig,
+		noNetbootResponse:   cfg.noNetbootResponse,
+		syslogHub:           syslogHub,
+		readinessTimeout:    cfg.readinessTimeout,
+		jobLookupTimeout:    cfg.jobLookupTimeout,
+		readHeaderTimeout:   cfg.httpReadHeaderTimeout,
+		writeTimeout:        cfg.httpWriteTimeout,
+		idleTimeout:         cfg.httpIdleTimeout,
+		syslogStreamToken:   cfg.syslogStreamToken,
+		configToken:         cfg.configToken,
+		simulateToken:       cfg.simulateToken,
+		maintenance:         maintenance,
+		maintenanceToken:    cfg.maintenanceToken,
+		draining:            &drainMode{},
+		drainToken:          cfg.drainToken,
+		packetLog:           packetLog,
+		userAgentArch:       cfg.userAgentArch,
+		allowPXEDefault:     cfg.allowPXEDefault,
+		pprofEnabled:        cfg.pprofEnabled,
+		pprofAddr:           cfg.pprofAddr,
+		pprofAuthToken:      cfg.pprofAuthToken,
+		metricsAuthToken:    cfg.metricsAuthToken,
+		config:              conf.NewReloadable(effectiveConfig(cli.FlagSet)),
+		phoneHomeClientCert: cfg.phoneHomeClientCert,
+		activeBoots:         activeBoots,
+		activeBootsToken:    cfg.activeBootsToken,
+		statusToken:         cfg.statusToken,
+		recentBoots:         newRecentCounter(),
+		recentLookups:       recentLookups,
+		installStatuses:     installStatuses,
+		phoneHomeDedup:      newPhoneHomeDeduper(cfg.phoneHomeDedupWindow),
+		phoneHomeDuplicate:  cfg.phoneHomeDuplicateResponse,
+	}
+	if err := validateInventoryRetry(cfg.inventoryRetry); err != nil {
+		mainlog.Fatal(err)
+	}
+	if cfg.httpLogFormat != httplog.FormatText && cfg.httpLogFormat != httplog.FormatJSON {
+		mainlog.Fatal(errors.Errorf("invalid -http-log-format %q, must be %s or %s", cfg.httpLogFormat, httplog.FormatText, httplog.FormatJSON))
+	}
+	httpServer.logFormat = cfg.httpLogFormat
+	if httpServer.logFields, err = httplog.ParseFields(cfg.httpLogFields); err != nil {
+		mainlog.Fatal(errors.WithMessage(err, "invalid -http-log-fields"))
+	}
+	httpServer.xffStrict = cfg.xffStrict
+	httpServer.problemJSON = problemJSON(cfg.problemJSON)
+	if httpServer.extraHeaders, err = parseExtraHeaders(cfg.httpExtraHeaders); err != nil {
+		mainlog.Fatal(err)
+	}
+	if httpServer.contentTypes, err = parseContentTypeMap(cfg.contentTypeMap); err != nil {
+		mainlog.Fatal(err)
+	}
+	if httpServer.fileDeadline, err = newFileDeadline(cfg.fileServeTimeout, cfg.fileServeIdleTimeout, cfg.httpWriteTimeout); err != nil {
+		mainlog.Fatal(err)
+	}
+	if httpServer.bootLoops, err = newBootLoopDetector(cfg.bootLoopThreshold, cfg.bootLoopWindow, cfg.bootLoopHalt); err != nil {
+		mainlog.Fatal(err)
+	}
+	if httpServer.fallbackScript, err = loadFallbackScript(cfg.fallbackIPXEScript); err != nil {
+		mainlog.Fatal(err)
+	}
+	if cfg.maxRequestBody < 0 {
+		mainlog.Fatal(errors.New("-max-request-body must not be negative"))
+	}
+	httpServer.maxRequestBody = cfg.maxRequestBody
+	if cfg.collectInventory {
+		if err := validateCollectInventory(finder, cfg.inventoryLog); err != nil {
+			mainlog.Fatal(err)
+		}
+		httpServer.inventoryNonces = newInventoryNonces()
+	}
+	httpServer.collectInventory = cfg.collectInventory
+	httpServer.ipxeErrorScripts = cfg.ipxeErrorScripts
+	httpServer.inventoryRetry = cfg.inventoryRetry
+	if cfg.inventoryLog != "" {
+		f, err := os.OpenFile(cfg.inventoryLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			mainlog.Fatal(errors.Wrap(err, "open inventory log"))
+		}
+		defer f.Close()
+		httpServer.inventory = &inventoryRecorder{w: f}
+	}
+	httpServer.shedder, err = newLoadShedder(cfg.retryAfterMin, cfg.retryAfterMax, cfg.retryAfterCapacity)
+	if err != nil {
+		mainlog.Fatal(errors.WithMessage(err, "invalid -retry-after-min, -retry-after-max or -retry-after-capacity"))
+	}
+	if httpServer.rateLimiter, err = newClientRateLimiter(cfg.httpRateLimit, cfg.httpRateBurst); err != nil {
+		mainlog.Fatal(err)
+	}
+	if cfg.caBundle != "" {
+		httpServer.caBundle, err = loadCABundle(cfg.caBundle)
+		if err != nil {
+			mainlog.Fatal(err)
+		}
+	}
+	if cfg.bootFailureLog != "" {
+		f, err := os.OpenFile(cfg.bootFailureLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			mainlog.Fatal(errors.Wrap(err, "open boot failure log"))
+		}
+		defer f.Close()
+		httpServer.bootFailures = &bootFailureRecorder{w: f}
+	}
+	if cfg.phoneHomeLog != "" {
+		f, err := os.OpenFile(cfg.phoneHomeLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			mainlog.Fatal(errors.Wrap(err, "open phone-home log"))
+		}
+		defer f.Close()
+		httpServer.phoneHomes = newPhoneHomeBatcher(jsonLinesSink{w: f}, cfg.phoneHomeBatchWindow, cfg.phoneHomeBatchSize)
+	}
+	// stopped once the http server is drained, pending events are written before g.Wait returns
+	phoneHomeCtx, stopPhoneHomes := context.WithCancel(context.Background())
+	defer stopPhoneHomes()
+	if httpServer.phoneHomes != nil {
+		g.Go(func() error { return httpServer.phoneHomes.run(phoneHomeCtx) })
+	}
+	httpServer.phoneHomeUnknownStatus = cfg.phoneHomeUnknownStatus
+	if httpServer.phoneHomeWebhook, err = newPhoneHomeWebhook(cfg.phoneHomeWebhook, cfg.phoneHomeWebhookTimeout, cfg.phoneHomeWebhookRetries); err != nil {
+		mainlog.Fatal(err)
+	}
+	if httpServer.phoneHomeWebhook != nil {
+		g.Go(func() error { return httpServer.phoneHomeWebhook.run(phoneHomeCtx) })
 	}
 
 	dhcpServer := &BootsDHCPServer{
-		jobmanager: jobManager,
+		jobmanager:      jobManager,
+		listenConfig:    lc,
+		invalidMAC:      cfg.dhcpInvalidMAC,
+		tracer:          replyTracer,
+		packetLog:       packetLog,
+		archPolicy:      archPolicy,
+		ipxeUserClasses: ipxeUserClasses,
+		quietPolicy:     quietPolicy,
+		relayPolicy:     relayPolicy,
+		activeBoots:     activeBoots,
+		mode:            cfg.dhcpMode,
+		ipxeScheme:      ipxeScheme,
+		bootsScheme:     bootsScheme,
+		drainer:         newDrainer(),
+		maintenance:     maintenance,
+		recentLookups:   recentLookups,
+		authoritative:   cfg.dhcpAuthoritative,
 	}
 
-	mainlog.With("addr", cfg.dhcpAddr).Info("serving dhcp")
-	go dhcpServer.ServeDHCP(cfg.dhcpAddr, nextServer, ipxeBaseURL, bootsBaseURL)
-
+	if cfg.osieChecksums != "" {
+		sums, err := osie.LoadChecksums(cfg.osieChecksums)
+		if err != nil {
+			mainlog.Fatal(err)
+		}
+		if cfg.osieVerifier, err = osie.NewVerifier(sums, cfg.osieChecksumInterval); err != nil {
+			mainlog.Fatal(err)
+		}
+	}
 	i, err := cfg.registerInstallers()
 	if err != nil {
 		mainlog.Fatal(err)
 	}
+	if cfg.check {
+		if err := checkBackend(ctx, finder, workflowBackend, cfg.readinessTimeout); err != nil {
+			mainlog.Fatal(err)
+		}
+		fmt.Println("OK")
+
+		return
+	}
+
+	// receives the syslog receiver once it is listening, so it can be shut down
+	syslogReceiver := make(chan *syslog.Receiver, 1)
+	go func() {
+		mainlog.With("addr", cfg.syslogAddr).Info("serving syslog")
+		err = retry.Do(
+			func() error {
+				r, err := syslog.StartReceiver(cfg.syslogAddr, 1, syslogHub, syslogForwarder)
+				if err == nil {
+					syslogReceiver <- r
+				}
+
+				return err
+			},
+		)
+		if err != nil {
+			mainlog.Fatal(errors.Wrap(err, "retry syslog serve"))
+		}
+	}()
+
+	if tftpServer != nil {
+		g.Go(func() error {
+			conn, err := lc.ListenPacket(ctx, "udp", tftpAddr)
+			if err != nil {
+				return errors.Wrap(err, "listen tftp")
+			}
+
+			return tftpServer.ServeTFTP(conn)
+		})
+	}
+	if cfg.dhcpMode == dhcpModeDisabled {
+		mainlog.Info("dhcp is disabled")
+	} else {
+		mainlog.With("addr", dhcpAddrs, "mode", cfg.dhcpMode).Info("serving dhcp")
+		go dhcpServer.ServeDHCP(dhcpAddrs, nextServer, ipxeBaseURL, bootsBaseURL)
+	}
+	installers := conf.NewReloadable(i)
+	r := &reloader{started: cli.FlagSet, current: *cfg, installers: installers, logLevel: logLevel, effective: httpServer.config}
+	go r.run(ctx, os.Args[1:])
 	mainlog.With("addr", cfg.httpAddr).Info("serving http")
-	go httpServer.ServeHTTP(i, cfg.httpAddr, ipxePattern, ipxeHandler)
+	go httpServer.ServeHTTP(installers, cfg.httpAddr, ipxePattern, ipxeHandler)
 
 	<-ctx.Done()
-	mainlog.Info("boots shutting down")
+	mainlog.With("timeout", cfg.shutdownTimeout).Info("boots shutting down")
+	// stop handing out new DHCP offers first, then let running HTTP and TFTP transfers finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.shutdownTimeout)
+	defer cancel()
+	dhcpServer.Shutdown(shutdownCtx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		httpServer.Shutdown(shutdownCtx)
+		stopPhoneHomes()
+	}()
+	if tftpServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tftpServer.Shutdown(shutdownCtx)
+		}()
+	}
+	select {
+	case r := <-syslogReceiver:
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Shutdown(shutdownCtx)
+		}()
+	default:
+	}
+	wg.Wait()
+	stopForwarding()
 	err = g.Wait()
 	if err != nil && !errors.Is(err, context.Canceled) {
 		mainlog.Fatal(err)
@@ -236,17 +896,61 @@ func getFinders(l log.Logger, c *config) (client.WorkflowFinder, client.Hardware
 			return nil, nil, err
 		}
 		// standalone uses Tinkerbell workflows
-		wf, err = standalone.NewWorkflowFinder()
+		wf, err = tinkWorkflowFinder(c)
 		if err != nil {
 			return nil, nil, err
 		}
-	case "kubernetes":
-		kf, err := kubernetes.NewFinder(l, c.kubeAPI, c.kubeconfig, c.kubeNamespace)
+	case "file":
+		if c.hardwareFile == "" {
+			return nil, nil, errors.New("-hardware-file must be set when DATA_MODEL_VERSION=file")
+		}
+		ff, err := standalone.NewFileHardwareFinder(l, c.hardwareFile)
 		if err != nil {
 			return nil, nil, err
 		}
+		if c.hardwareFileInterval > 0 {
+			go ff.Watch(context.Background(), c.hardwareFileInterval)
+		}
+		hf = ff
+		// there is no workflow engine without a backend
+		wf = &client.NoOpWorkflowFinder{}
+	case "synthetic":
+		if !c.allowSyntheticBackend {
+			return nil, nil, errors.New("-allow-synthetic-backend must be set when DATA_MODEL_VERSION=synthetic, it fabricates hardware for any MAC and is only for load testing")
+		}
+		sf, err := standalone.NewSyntheticFinder(c.syntheticLatency, c.syntheticNotFoundPercent)
+		if err != nil {
+			return nil, nil, err
+		}
+		l.With("latency", c.syntheticLatency, "notFoundPercent", c.syntheticNotFoundPercent).Info("using the synthetic backend, any MAC boots fabricated hardware")
+		hf, wf = sf, sf
+	case "kubernetes":
+		if c.kubeconfig != "" && c.kubeconfigContent != "" {
+			return nil, nil, errors.New("-kubeconfig and -kubeconfig-content are mutually exclusive")
+		}
+		kubeconfig := kubernetes.Kubeconfig{Path: c.kubeconfig, Content: c.kubeconfigContent}
+		opt := kubernetes.WithRateLimit(float32(c.kubeQPS), c.kubeBurst)
+		var kf *kubernetes.Finder
+		if c.kubeLocalIndex {
+			ikf, err := kubernetes.NewIndexedFinder(l, c.kubeAPI, kubeconfig, c.kubeNamespace, opt)
+			if err != nil {
+				return nil, nil, err
+			}
+			kf = ikf.Finder
+			hf = ikf
+		} else {
+			kf, err = kubernetes.NewFinder(l, c.kubeAPI, kubeconfig, c.kubeNamespace, opt)
+			if err != nil {
+				return nil, nil, err
+			}
+			hf = kf
+		}
 		wf = kf
-		hf = kf
+		np, err := kubernetes.NewNamespacePolicy(strings.Split(c.kubeNamespaces, ","), strings.Split(c.kubeNamespacePrecedence, ","))
+		if err != nil {
+			return nil, nil, err
+		}
+		kf.SetNamespacePolicy(np)
 		// Start the client-side cache
 		go func() {
 			_ = kf.Start(context.Background())
@@ -259,6 +963,38 @@ func getFinders(l log.Logger, c *config) (client.WorkflowFinder, client.Hardware
 	return wf, hf, nil
 }
 
+// tinkAuthorities returns the tink servers of TINKERBELL_GRPC_AUTHORITY, a comma separated
+// list of host:port.
+func tinkAuthorities() []string {
+	var auths []string
+	for _, a := range strings.Split(env.Get("TINKERBELL_GRPC_AUTHORITY"), ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			auths = append(auths, a)
+		}
+	}
+
+	return auths
+}
+
+// tinkWorkflowFinder returns a workflow finder rotating lookups across the tink servers
+// of TINKERBELL_GRPC_AUTHORITY, or using the tink server if there is one.
+func tinkWorkflowFinder(c *config) (client.WorkflowFinder, error) {
+	auths := tinkAuthorities()
+	if len(auths) == 0 {
+		return nil, errors.New("connect to tink: undefined TINKERBELL_GRPC_AUTHORITY")
+	}
+	finders := make([]client.WorkflowFinder, len(auths))
+	for i, auth := range auths {
+		f, err := standalone.NewWorkflowFinder(auth, env.Bool("TINKERBELL_TLS", true))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "tink server %s", auth)
+		}
+		finders[i] = f
+	}
+
+	return client.NewEndpointWorkflowFinder(auths, finders, c.backendEndpointCooldown)
+}
+
 // parseDynamicIPXEVars will parse any number of variable definitions from a
 // string, and return a an array of two-element arrays which are the key/value
 // string pairs of the variable's name and value. These will later be injected
@@ -287,16 +1023,30 @@ func parseDynamicIPXEVars(v string) ([][]string, error) {
 	return retVal, nil
 }
 
-// defaultLogger is zap logr implementation.
-func defaultLogger(level string) logr.Logger {
+// parseIPXERemoteHTTPAddr returns the scheme and the host:port[/path] of the remote
+// HTTP server serving iPXE binaries. addr may be prefixed with http:// or https://,
+// which takes precedence over scheme.
+func parseIPXERemoteHTTPAddr(addr, scheme string) (string, string, error) {
+	for _, s := range []string{"http", "https"} {
+		if strings.HasPrefix(addr, s+"://") {
+			scheme, addr = s, strings.TrimPrefix(addr, s+"://")
+		}
+	}
+	if scheme != "http" && scheme != "https" {
+		return "", "", errors.Errorf("invalid -ipxe-remote-http-scheme %q, must be http or https", scheme)
+	}
+	if addr == "" || strings.Contains(addr, "://") {
+		return "", "", errors.Errorf("invalid -ipxe-remote-http-addr %q, must be a host:port with an optional path", addr)
+	}
+
+	return scheme, addr, nil
+}
+
+// defaultLogger is zap logr implementation logging at level.
+func defaultLogger(level zap.AtomicLevel) logr.Logger {
 	config := zap.NewProductionConfig()
 	config.OutputPaths = []string{"stdout"}
-	switch level {
-	case "debug":
-		config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
-	default:
-		config.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
-	}
+	config.Level = level
 	zapLogger, err := config.Build()
 	if err != nil {
 		panic(fmt.Sprintf("who watches the watchmen (%v)?", err))
@@ -305,6 +1055,15 @@ func defaultLogger(level string) logr.Logger {
 	return zapr.NewLogger(zapLogger)
 }
 
+// envVarReplacer replaces the characters of flag names that are not allowed in env var names, like ff does.
+var envVarReplacer = strings.NewReplacer("-", "_", ".", "_", "/", "_")
+
+// envVarName returns the name of the env var a flag is read from when it is not set,
+// e.g. BOOTS_DHCP_ADDR for -dhcp-addr.
+func envVarName(flagName string) string {
+	return strings.ToUpper(name + "_" + envVarReplacer.Replace(flagName))
+}
+
 // customUsageFunc is a custom UsageFunc used for all commands.
 func customUsageFunc(c *ffcli.Command) string {
 	var b strings.Builder
@@ -335,11 +1094,11 @@ func customUsageFunc(c *ffcli.Command) string {
 		fmt.Fprintf(&b, "FLAGS\n")
 		tw := tabwriter.NewWriter(&b, 0, 2, 2, ' ', 0)
 		c.FlagSet.VisitAll(func(f *flag.Flag) {
-			format := "  -%s\t%s\n"
-			values := []interface{}{f.Name, f.Usage}
+			format := "  -%s\t%s\t%s\n"
+			values := []interface{}{f.Name, envVarName(f.Name), f.Usage}
 			if def := f.DefValue; def != "" {
-				format = "  -%s\t%s (default %q)\n"
-				values = []interface{}{f.Name, f.Usage, def}
+				format = "  -%s\t%s\t%s (default %q)\n"
+				values = []interface{}{f.Name, envVarName(f.Name), f.Usage, def}
 			}
 			fmt.Fprintf(tw, format, values...)
 		})
@@ -357,28 +1116,162 @@ func countFlags(fs *flag.FlagSet) (n int) {
 }
 
 func newCLI(cfg *config, fs *flag.FlagSet) *ffcli.Command {
+	fs.StringVar(&cfg.configFile, "config-file", "", "YAML file of flag values keyed by flag name, e.g. 'http-addr: 0.0.0.0:80', overridden by env vars and the command line. Unknown keys are an error. On SIGHUP it is re-read and changes to -extra-kernel-args, -ipxe-vars, -osie-path-override and -log-level are applied without a restart.")
 	fs.StringVar(&cfg.ipxe.TFTPAddr, "ipxe-tftp-addr", "0.0.0.0:69", "local IP and port to listen on for serving iPXE binaries via TFTP (port must be 69).")
 	fs.DurationVar(&cfg.ipxe.TFTPTimeout, "ipxe-tftp-timeout", time.Second*5, "local iPXE TFTP server requests timeout.")
+	fs.IntVar(&cfg.tftpMaxConcurrent, "tftp-max-concurrent", 0, "max number of TFTP transfers in progress, read requests beyond it wait up to -tftp-queue-timeout and are then rejected. 0 does not limit transfers.")
+	fs.DurationVar(&cfg.tftpQueueTimeout, "tftp-queue-timeout", time.Second, "how long a TFTP read request waits for a transfer to finish when -tftp-max-concurrent are in progress. -ipxe-tftp-timeout only applies once the transfer starts.")
 	fs.BoolVar(&cfg.ipxeTFTPEnabled, "ipxe-enable-tftp", true, "enable serving iPXE binaries via TFTP.")
 	fs.BoolVar(&cfg.ipxeHTTPEnabled, "ipxe-enable-http", true, "enable serving iPXE binaries via HTTP.")
 	fs.StringVar(&cfg.ipxeRemoteTFTPAddr, "ipxe-remote-tftp-addr", "", "remote IP where iPXE binaries are served via TFTP. Overrides -tftp-addr.")
 	fs.StringVar(&cfg.ipxeRemoteHTTPAddr, "ipxe-remote-http-addr", "", "remote IP and port where iPXE binaries are served via HTTP. Overrides -http-addr for iPXE binaries only.")
+	fs.StringVar(&cfg.ipxeRemoteHTTPScheme, "ipxe-remote-http-scheme", "http", "scheme of the remote HTTP server serving iPXE binaries (http, https). An http:// or https:// prefix on -ipxe-remote-http-addr takes precedence.")
 	fs.StringVar(&cfg.ipxeVars, "ipxe-vars", "", "additional variable definitions to include in all iPXE installer scripts. Separate multiple var definitions with spaces, e.g. 'var1=val1 var2=val2'.")
-	fs.StringVar(&cfg.httpAddr, "http-addr", conf.HTTPBind, "local IP and port to listen on for the serving iPXE binaries and files via HTTP.")
+	fs.StringVar(&cfg.httpAddr, "http-addr", conf.HTTPBind, "local IP and port to listen on for the serving iPXE binaries and files via HTTP. IPv6 addresses are bracketed, e.g. [::]:80.")
 	fs.StringVar(&cfg.logLevel, "log-level", "info", "log level.")
-	fs.StringVar(&cfg.dhcpAddr, "dhcp-addr", conf.BOOTPBind, "IP and port to listen on for DHCP.")
+	fs.StringVar(&cfg.dhcpAddr, "dhcp-addr", conf.BOOTPBind, "comma separated IPv4 addresses and ports to listen on for DHCP, e.g. on multiple provisioning VLANs. DHCP metrics are labelled with the address a request was received on. Multiple addresses sharing a port are served from one wildcard socket so broadcasts are received, each packet is answered by the address it was sent to or the address on the interface it arrived on. Boots exits if any address cannot be bound.")
 	fs.StringVar(&cfg.syslogAddr, "syslog-addr", conf.SyslogBind, "IP and port to listen on for syslog messages.")
-	fs.StringVar(&cfg.extraKernelArgs, "extra-kernel-args", "", "Extra set of kernel args (k=v k=v) that are appended to the kernel cmdline when booting via iPXE.")
+	fs.StringVar(&cfg.ipxeTemplateDir, "ipxe-template-dir", "", "directory of iPXE script templates (Go text/template), each <facility>.ipxe.tmpl file is served to the hardware of that facility instead of its installer. Templates get .MAC, .Arch, .HardwareID, .Facility, .KernelArgs, .IPXEVars, .Tinkerbell and .SyslogHost. All templates are checked at startup.")
+	fs.StringVar(&cfg.installerArches, "installer-arches", "", "space separated installer=arch,arch entries restricting the client arches, from DHCP option 93 or the hardware record, an installer serves, e.g. 'default=x86_64,aarch64 installer:custom_ipxe=x86_64'. installer is default, installer:<name>, slug:<slug>, distro:<distro> or facility:<code>. Machines of other arches get the next matching installer serving their arch, or a 404 if there is none.")
+	fs.StringVar(&cfg.installerPriority, "installer-priority", "facility,installer,slug,distro", "the order the installer of a machine's auto.ipxe is selected in when several match it, comma separated kinds, highest priority first, of facility, installer, slug and distro. Kinds joined with '|' share a priority and are won by an installer restricted to the machine's arch in -installer-arches, e.g. 'facility,installer|slug,distro'.")
+	fs.StringVar(&cfg.extraKernelArgs, "extra-kernel-args", "", "Extra set of kernel args (k=v k=v) that are appended to the kernel cmdline when booting via iPXE. May contain Go template actions expanded per machine with .MAC, .Hostname, .IP, .HardwareID, .Facility, .Plan and .Arch, e.g. hostname={{.Hostname}}.")
+	fs.StringVar(&cfg.extraKernelArgsArch, "extra-kernel-args-arch", "", "; separated list of arch=args, e.g. 'x86_64=console=ttyS0,115200; aarch64=console=ttyAMA0', of kernel args added after -extra-kernel-args for machines booting as the arch, overriding its args with the same key. The arch is x86_64, aarch64 or another DHCP option 93 arch, args may contain template actions like -extra-kernel-args.")
+	fs.StringVar(&cfg.osieKernelArgs, "osie-kernel-args", "", "default kernel args (k=v k=v) of the OSIE installer when installing or rescuing. Args with the same key are overridden by -extra-kernel-args, which are overridden by the hardware record's kernel args.")
+	fs.StringVar(&cfg.osieDiscoverKernelArgs, "osie-discover-kernel-args", "", "default kernel args (k=v k=v) of the OSIE installer when discovering, overridden like -osie-kernel-args.")
+	fs.StringVar(&cfg.customIPXEKernelArgs, "custom-ipxe-kernel-args", "", "default kernel args (k=v k=v) of the custom iPXE installer, overridden like -osie-kernel-args. Custom scripts get the merged args in the kernel_args iPXE variable.")
 	fs.StringVar(&cfg.kubeconfig, "kubeconfig", "", "The Kubernetes config file location. Only applies if DATA_MODEL_VERSION=kubernetes.")
+	fs.StringVar(&cfg.kubeconfigContent, "kubeconfig-content", "", "The Kubernetes config itself, as YAML or base64 encoded YAML, instead of -kubeconfig, e.g. from a secret in BOOTS_KUBECONFIG_CONTENT. Only applies if DATA_MODEL_VERSION=kubernetes.")
 	fs.StringVar(&cfg.kubeAPI, "kubernetes", "", "The Kubernetes API URL, used for in-cluster client construction. Only applies if DATA_MODEL_VERSION=kubernetes.")
 	fs.StringVar(&cfg.kubeNamespace, "kube-namespace", "", "An optional Kubernetes namespace override to query hardware data from.")
+	fs.Float64Var(&cfg.kubeQPS, "kube-qps", 0, "max number of Kubernetes API requests per second. 0 uses the client-go default of 5. Only applies if DATA_MODEL_VERSION=kubernetes.")
+	fs.BoolVar(&cfg.kubeLocalIndex, "kube-local-index", false, "answer hardware lookups from a local index of Hardware kept current by an informer, /readiness reports not ready until it has synced and reports the index size and last event time. Only applies if DATA_MODEL_VERSION=kubernetes.")
+	fs.StringVar(&cfg.kubeNamespaces, "kube-namespaces", "", "comma separated list of namespaces to consider hardware in, all watched namespaces if empty. Only applies if DATA_MODEL_VERSION=kubernetes.")
+	fs.StringVar(&cfg.kubeNamespacePrecedence, "kube-namespace-precedence", "", "comma separated list of namespaces, most preferred first, whose hardware is used when a lookup matches hardware in several namespaces. Lookups still ambiguous are denied. Only applies if DATA_MODEL_VERSION=kubernetes.")
+	fs.IntVar(&cfg.kubeBurst, "kube-burst", 0, "max burst of Kubernetes API requests over -kube-qps. 0 uses the client-go default of 10. Only applies if DATA_MODEL_VERSION=kubernetes.")
 	fs.StringVar(&cfg.osiePathOverride, "osie-path-override", "", "A custom URL for OSIE/Hook images.")
+	fs.StringVar(&cfg.osieFacilityURLs, "osie-facility-urls", "", "comma separated list of facility=URL OSIE/Hook base URLs, e.g. 'ewr1=http://mirror.ewr1.example.com/osie', that machines in the facility boot from instead of -osie-mirrors, -osie-path-override or their hardware record's OSIE base URL.")
+	fs.StringVar(&cfg.osieMirrors, "osie-mirrors", "", "comma separated list of OSIE/Hook mirror URLs, each optionally followed by =weight, to spread boots across instead of -osie-path-override.")
+	fs.StringVar(&cfg.osieChecksums, "osie-checksums", "", "sha256sum format manifest of OSIE/Hook image checksums, by file name relative to the OSIE URL, e.g. vmlinuz-x86_64. An image is downloaded and verified the first time a machine boots it, and boot scripts using an image that fails verification get a 404.")
+	fs.DurationVar(&cfg.osieChecksumInterval, "osie-checksum-interval", time.Hour, "how often an OSIE/Hook image is verified again against -osie-checksums. Failed images are verified again on the next boot.")
+	fs.StringVar(&cfg.osieMirrorSelection, "osie-mirror-selection", "round-robin", "how an -osie-mirrors mirror is picked for each boot. 'round-robin' is weighted round-robin, 'mac-hash' keeps each MAC on the same mirror.")
+	fs.BoolVar(&cfg.reusePort, "reuse-port", false, "enable SO_REUSEPORT on the HTTP, DHCP, and TFTP listeners so a new instance can bind before the old one exits (Linux only).")
+	fs.DurationVar(&cfg.tcpKeepAlive, "tcp-keepalive", 0, "keep-alive period for accepted TCP connections. 0 uses the Go default, a negative value disables keep-alives.")
+	fs.BoolVar(&cfg.userAgentArch, "user-agent-arch", true, "select installers for the arch named in the User-Agent of a boot script request, e.g. arm64-efi, if neither DHCP nor the hardware record has one. Only custom iPXE builds name their platform, stock iPXE sends iPXE/<version> and is never matched.")
+	fs.BoolVar(&cfg.staticNetworkKernelArgs, "static-network-kernel-args", false, "pass the hardware's static network config to OSIE/Hook as dracut ip= kernel args instead of ip=dhcp.")
+	fs.Var(&cfg.httpExtraHeaders, "http-extra-headers", "a 'Key: Value' header added to iPXE script responses, e.g. 'Cache-Control: no-store', unless Boots sets it itself. Can be given more than once, or as one header per line. Content-Type and the headers describing the transfer can not be set.")
+	fs.StringVar(&cfg.contentTypeMap, "content-type-map", "", "'.ext=type' pairs separated by ';' forcing the Content-Type of iPXE scripts and binaries by the extension of the request path, e.g. '.ipxe=text/plain; .efi=application/octet-stream'. Other files keep the type Go detects.")
+	fs.BoolVar(&cfg.problemJSON, "problem-json", false, "describe failed job file, phone-home and simulate requests with an RFC 7807 application/problem+json body, only to clients whose Accept header lists application/problem+json or application/json, so iPXE clients keep getting plain responses.")
+	fs.DurationVar(&cfg.fileServeTimeout, "file-serve-timeout", 0, "how long an iPXE script or binary transfer may take overall before it is aborted and its connection closed. 0 is no limit.")
+	fs.DurationVar(&cfg.fileServeIdleTimeout, "file-serve-idle-timeout", 0, "how long an iPXE script or binary transfer may go without writing to a stalled client before it is aborted, slow transfers that keep making progress are not. 0 is no limit.")
+	fs.DurationVar(&cfg.httpReadHeaderTimeout, "http-read-header-timeout", 20*time.Second, "how long the HTTP server waits for a request's headers, protecting against slowloris attacks.")
+	fs.DurationVar(&cfg.httpWriteTimeout, "http-write-timeout", 0, "how long the HTTP server takes to write a response, including large iPXE or OSIE files, before closing the connection. 0 is no limit.")
+	fs.Float64Var(&cfg.httpRateLimit, "http-rate-limit", 0, "requests a second each client IP may make to the boot script, phone-home and boot failure endpoints, the rest get a 429. Off by default.")
+	fs.IntVar(&cfg.httpRateBurst, "http-rate-burst", 20, "number of requests a client IP may make at once above -http-rate-limit.")
+	fs.BoolVar(&cfg.xffStrict, "xff-strict", false, "reject, with a 400, HTTP requests with an X-Forwarded-For header from peers that are not in TRUSTED_PROXIES. Without it the header of those requests is ignored. Only applies when TRUSTED_PROXIES is set.")
+	fs.StringVar(&cfg.httpLogFormat, "http-log-format", httplog.FormatText, "format of the HTTP access log. 'text' logs requests through the Boots logger, 'json' writes a JSON line per request to stdout.")
+	fs.StringVar(&cfg.httpLogFields, "http-log-fields", httplog.DefaultFields, "comma separated list of the fields logged for HTTP requests besides the method and URI, from: remote-addr, user-agent, duration, status, bytes. The remote-addr is the X-Forwarded-For client when TRUSTED_PROXIES is set.")
+	fs.DurationVar(&cfg.httpIdleTimeout, "http-idle-timeout", 0, "how long the HTTP server keeps idle keep-alive connections open. 0 uses -http-read-header-timeout.")
+	fs.StringVar(&cfg.httpTLSCert, "http-tls-cert", "", "PEM certificate file to serve HTTPS with on -http-addr, instead of HTTP. Requires -http-tls-key. Boot URLs given to machines use https.")
+	fs.StringVar(&cfg.httpTLSKey, "http-tls-key", "", "PEM private key file of -http-tls-cert.")
+	fs.StringVar(&cfg.tlsMinVersion, "tls-min-version", "1.2", "minimum TLS version accepted by the HTTPS server, one of: 1.2, 1.3.")
+	fs.StringVar(&cfg.tlsCipherSuites, "tls-cipher-suites", "", "comma separated list of TLS 1.2 cipher suites allowed by the HTTPS server. Defaults to ECDHE AEAD cipher suites only.")
+	fs.StringVar(&cfg.tlsClientCA, "tls-client-ca", "", "PEM file of CAs used to verify client certificates presented to the HTTPS server.")
+	fs.BoolVar(&cfg.phoneHomeClientCert, "phone-home-client-cert", false, "require phone-home requests to present a client certificate, verified by -tls-client-ca, that maps to the requesting machine's hardware record via an IP SAN or a MAC common name. Requires -http-tls-cert and -http-tls-key.")
+	fs.BoolVar(&cfg.ipxeReportFailures, "ipxe-report-failures", false, "make OSIE/Hook iPXE scripts report failed kernel, initrd and boot commands to Boots before retrying.")
+	fs.StringVar(&cfg.bootFailureLog, "boot-failure-log", "", "optional file that boot failures reported by iPXE clients are appended to as JSON lines.")
+	fs.StringVar(&cfg.dhcpMode, "dhcp-mode", dhcpModeAuto, "how DHCP is served. 'auto' assigns addresses and serves boot information, 'proxy' only serves boot information to PXE clients, as ProxyDHCP on the DHCP port and 4011, alongside another DHCP server, 'disabled' does not serve DHCP.")
+	fs.BoolVar(&cfg.dhcpAuthoritative, "dhcp-authoritative", false, "in auto -dhcp-mode, send a DHCPNAK to DHCPREQUESTs for an address other than the one the machine is assigned, or from machines with no hardware record, so the client restarts discovery. By default they are dropped.")
+	fs.DurationVar(&cfg.dhcpLeaseTime, "dhcp-lease-time", 0, "lease time offered to every machine instead of the lease time of its hardware record, e.g. 10m for short leases during provisioning. 0 uses the hardware record's.")
+	fs.DurationVar(&cfg.dhcpRenewalTime, "dhcp-renewal-time", 0, "T1 renewal time offered with -dhcp-lease-time, shorter than it. 0 leaves it to the client, usually half the lease time.")
+	fs.DurationVar(&cfg.dhcpRebindingTime, "dhcp-rebinding-time", 0, "T2 rebinding time offered with -dhcp-lease-time, between -dhcp-renewal-time and the lease time. 0 leaves it to the client, usually 7/8 of the lease time.")
+	fs.StringVar(&cfg.dhcpInvalidMAC, "dhcp-invalid-mac", invalidMACIgnore, "how to handle DHCP packets with an all zero or broadcast chaddr. 'ignore' drops them, 'client-id' looks up hardware by the MAC in the client identifier (option 61).")
+	fs.StringVar(&cfg.dhcpArchMap, "dhcp-arch-map", "", "comma separated list of option 93 arch=profile pairs, e.g. '25=uefi', choosing the iPXE binary sent to PXE clients. Profiles are bios, uefi and arm64.")
+	fs.StringVar(&cfg.dhcpBootFilenames, "dhcp-boot-filenames", "", "comma separated list of option 93 arch/firmware=filename pairs, e.g. '0/pxe=undionly.kpxe,7/ipxe=snp.efi', choosing the boot filename sent to PXE clients that are not sent the boot script by their arch and firmware, pxe or ipxe, over -dhcp-arch-map. The file is served like the iPXE binaries, from -ipxe-remote-tftp-addr or -ipxe-remote-http-addr if set, otherwise it must be one Boots serves.")
+	fs.StringVar(&cfg.dhcpIPXEUserClasses, "dhcp-ipxe-user-classes", "", "comma separated list of DHCP user-classes (option 77), e.g. 'iPXE', of iPXE builds that are sent the boot script URL instead of the Tinkerbell iPXE binary to chainload. Tinkerbell iPXE always is.")
+	fs.StringVar(&cfg.dhcpUnknownArch, "dhcp-unknown-arch", "bios", "how to handle PXE clients whose option 93 arch is neither mapped nor known. ARM, including U-Boot, UEFI, including RISC-V, and BIOS arches are known, others such as Itanium, PowerPC and s390 or values past the IANA list are not. A profile (bios, uefi, arm64), 'deny' to send no boot filename, or 'bootfile' to send -dhcp-unknown-arch-bootfile.")
+	fs.StringVar(&cfg.dhcpUnknownArchBootfile, "dhcp-unknown-arch-bootfile", "", "boot filename sent to PXE clients with an unknown arch when -dhcp-unknown-arch is 'bootfile'.")
+	fs.IntVar(&cfg.backendRetries, "backend-retries", 3, "number of times a hardware or workflow lookup is retried, with exponential backoff, after a network error, timeout or server error from the backend, including the gRPC codes Unavailable, DeadlineExceeded, ResourceExhausted and Aborted. Lookups that find no hardware are not retried. 0 disables retries.")
+	fs.DurationVar(&cfg.backendRetryMaxInterval, "backend-retry-max-interval", 2*time.Second, "the longest wait between retries of a backend lookup, the wait starts at 100ms and doubles on every retry.")
+	fs.IntVar(&cfg.breakerFailures, "breaker-failures", 0, "number of consecutive hardware or workflow lookups failing with a network error, timeout or server error, after their retries, within -breaker-window, that open the backend circuit breaker. While open lookups fail right away. 0 disables the breaker.")
+	fs.DurationVar(&cfg.breakerWindow, "breaker-window", time.Minute, "how long after the first of the -breaker-failures consecutive failed lookups the last must fail to open the backend circuit breaker, older failures are forgotten. 0 counts consecutive failures however far apart.")
+	fs.DurationVar(&cfg.breakerCooldown, "breaker-cooldown", 30*time.Second, "how long the open backend circuit breaker fails lookups right away before passing a single probe lookup to the backend, which closes the breaker if it succeeds.")
+	fs.DurationVar(&cfg.backendEndpointCooldown, "backend-endpoint-cooldown", 30*time.Second, "how long a tink server that failed is skipped for workflow lookups, while others are healthy, when TINKERBELL_GRPC_AUTHORITY lists multiple tink servers.")
+	fs.DurationVar(&cfg.hardwareCacheTTL, "hardware-cache-ttl", 0, "how long hardware found for DHCP and HTTP requests is cached, sparing the backend when many machines boot at once. Hardware changes take up to this long to be seen. 0 disables the cache.")
+	fs.IntVar(&cfg.hardwareCacheSize, "hardware-cache-size", 1000, "the number of hardware lookups cached when -hardware-cache-ttl is set, the least recently used are evicted.")
+	fs.StringVar(&cfg.hardwareFile, "hardware-file", "", "JSON or YAML file of hardware records, in the BOOTS_STANDALONE_JSON format, that hardware is looked up in by MAC and IP. Only applies if DATA_MODEL_VERSION=file.")
+	fs.DurationVar(&cfg.hardwareFileInterval, "hardware-file-interval", 5*time.Second, "how often -hardware-file is checked for changes, it is reloaded when it changed and is valid. 0 disables reloading.")
+	fs.BoolVar(&cfg.allowSyntheticBackend, "allow-synthetic-backend", false, "permit DATA_MODEL_VERSION=synthetic, a backend for load testing that fabricates hardware allowed to netboot for any MAC. Never set it in production.")
+	fs.DurationVar(&cfg.syntheticLatency, "synthetic-latency", 0, "how long every hardware and workflow lookup takes when DATA_MODEL_VERSION=synthetic.")
+	fs.IntVar(&cfg.syntheticNotFoundPercent, "synthetic-not-found-percent", 0, "percentage of MACs, 0 to 100, that no hardware is found for when DATA_MODEL_VERSION=synthetic. The same MACs are always not found.")
+	fs.StringVar(&cfg.hardwareMissingField, "hardware-missing-field", "", "how to handle hardware records missing a field required to boot them (id, mac, ip, facility, arch). 'deny' fails the request naming the field, 'default' uses -hardware-default-facility and -hardware-default-arch for missing facility and arch and denies the rest. Off by default.")
+	fs.StringVar(&cfg.hardwareDefaultFacility, "hardware-default-facility", "", "facility used for hardware records missing one when -hardware-missing-field is 'default'.")
+	fs.StringVar(&cfg.hardwareDefaultArch, "hardware-default-arch", "", "arch, e.g. 'x86_64', used for hardware records missing one when -hardware-missing-field is 'default'.")
+	fs.StringVar(&cfg.dhcpRelaySubnets, "dhcp-relay-subnets", "", "comma separated list of CIDRs DHCP relays are configured in. Packets relayed from a giaddr outside of them are handled by -dhcp-unmatched-relay. Off by default.")
+	fs.StringVar(&cfg.dhcpUnmatchedRelay, "dhcp-unmatched-relay", dhcp.UnmatchedRelayAllow, "how to handle DHCP packets relayed from a giaddr outside of -dhcp-relay-subnets. 'allow' handles them like any other, 'ignore' drops them, 'deny' drops them and logs the unknown relay. All are counted in the dhcp_unmatched_relay_total metric.")
+	fs.StringVar(&cfg.dhcpQuietStates, "dhcp-quiet-states", "", "comma separated list of states, e.g. 'in_use', of machines done provisioning that keep network booting to be told to boot from disk. Their per-DISCOVER logging is at debug level instead of info. Off by default.")
+	fs.StringVar(&cfg.dhcpQuietStateSource, "dhcp-quiet-state-source", job.QuietStateHardware, "where the state matched by -dhcp-quiet-states is read from, 'hardware' for the hardware record's state or 'instance' for the instance's state.")
+	fs.IntVar(&cfg.dhcpQuietLogSample, "dhcp-quiet-log-sample", 0, "log one in every N DISCOVERs from quiet machines at info level, 0 logs none. All are counted in the dhcp_quiet_total metric.")
+	fs.StringVar(&cfg.dhcpTraceMACs, "dhcp-trace-macs", "", "comma separated list of MACs, or '*' for all clients, whose DHCP replies are logged with every option decoded and in hex. Off by default.")
+	fs.BoolVar(&cfg.logPackets, "log-packets", false, "log the options of every received DHCP packet and every iPXE script served, at debug level, for debugging a machine's boot. Off by default.")
+	fs.Float64Var(&cfg.logPacketsRate, "log-packets-rate", 1, "max number of -log-packets dumps logged per second per MAC, in bursts of up to 8. Dumps over the limit are dropped.")
+	fs.StringVar(&cfg.logPacketsRedact, "log-packets-redact", "registry_password,pwhash", "comma separated DHCP option names or codes, iPXE script variables and kernel args whose values are replaced with REDACTED in -log-packets dumps.")
+	fs.Float64Var(&cfg.dhcpTraceRate, "dhcp-trace-rate", 1, "max number of DHCP reply traces logged per second, traces over the limit are dropped.")
+	fs.BoolVar(&cfg.allowPXEDefault, "allow-pxe-default", false, "allow hardware whose record has no netboot settings, or that has no hardware record, to PXE, booting the default installer unless the record selects another, e.g. during initial bring-up. An explicit allow_pxe: false is still honored. Overrides -no-netboot-response, machines with no record are served -collect-inventory instead when it is set.")
+	fs.StringVar(&cfg.noNetbootResponse, "no-netboot-response", "404", "how to respond to HTTP requests from known hardware that has no netboot config. A 4xx status code, 'script' serves an iPXE script that prints the problem and exits, 'discovery' serves the discovery script.")
+	fs.BoolVar(&cfg.pprofEnabled, "enable-pprof", true, "serve the pprof profiling handlers under /_packet/pprof/. When disabled the pprof paths respond 404.")
+	fs.StringVar(&cfg.pprofAddr, "pprof-addr", "", "local IP and port to serve the pprof handlers on over HTTP, instead of -http-addr.")
+	fs.StringVar(&cfg.pprofAuthToken, "pprof-auth-token", "", "require pprof requests to present this token as a bearer token, others get a 401.")
+	fs.StringVar(&cfg.metricsAuthToken, "metrics-auth-token", "", "require /metrics requests to present this token as a bearer token, others get a 401. Boot, iPXE and phone-home requests are never authenticated.")
+	fs.BoolVar(&cfg.printConfig, "print-config", false, "print the effective configuration, resolved from the flags, env and -config-file, as JSON and exit. Secrets are redacted.")
+	fs.BoolVar(&cfg.printVersion, "version", false, "print the git revision, build date and Go version and exit.")
+	fs.BoolVar(&cfg.check, "check", false, "validate the configuration, templates and hardware backend connection, print OK and exit without serving. Exits non-zero on the first error.")
+	fs.StringVar(&cfg.configToken, "config-token", "", "enables serving the effective configuration as JSON from /_packet/config. Clients must present this token as a bearer token.")
+	fs.BoolVar(&cfg.maintenance, "maintenance", false, "start in maintenance mode: job files are refused with an iPXE script explaining Boots is in maintenance, or a 503, and DHCP replies have no boot options. Phone-homes are still handled.")
+	fs.StringVar(&cfg.drainToken, "drain-token", "", "enables draining for a rolling restart with a POST to /_packet/drain, after which /readiness reports not ready and new job file requests get a 503 while those in flight finish. A POST of enabled=false stops draining. Clients must present this token as a bearer token.")
+	fs.StringVar(&cfg.maintenanceToken, "maintenance-token", "", "enables toggling maintenance mode with a POST of enabled=true or enabled=false to /_packet/maintenance. Clients must present this token as a bearer token.")
+	fs.IntVar(&cfg.bootLoopThreshold, "boot-loop-threshold", 0, "number of boot script requests of a machine within -boot-loop-window past which it is logged as boot looping and listed as such by /_packet/status/boot-loops. 0 disables detection.")
+	fs.DurationVar(&cfg.bootLoopWindow, "boot-loop-window", 30*time.Minute, "sliding window boot script requests are counted in for -boot-loop-threshold.")
+	fs.BoolVar(&cfg.bootLoopHalt, "boot-loop-halt", false, "serve boot looping machines an iPXE script that halts them instead of their boot script, until they are reset. Requires -boot-loop-threshold.")
+	fs.StringVar(&cfg.simulateToken, "simulate-token", "", "enables simulating the boot of a MAC from /_packet/simulate?mac=<mac>, responding with the boot script and kernel args it would get. Clients must present this token as a bearer token.")
+	fs.StringVar(&cfg.syslogForwardAddr, "syslog-forward-addr", "", "optional host:port of a collector, e.g. rsyslog, that received syslog messages are relayed to unmodified. Prefix with tcp:// to forward over TCP with octet counted framing, UDP is the default.")
+	fs.IntVar(&cfg.syslogForwardBuffer, "syslog-forward-buffer", 10000, "max number of syslog messages waiting to be forwarded, messages are dropped when it is full.")
+	fs.StringVar(&cfg.syslogStreamToken, "syslog-stream-token", "", "enables streaming syslog messages as server-sent events from /syslog/stream. Clients must present this token as a bearer token or the token query param.")
+	fs.StringVar(&cfg.phoneHomeLog, "phone-home-log", "", "optional file that phone-home events are appended to as JSON lines.")
+	fs.DurationVar(&cfg.phoneHomeBatchWindow, "phone-home-batch-window", 0, "how long phone-home events are collected before being persisted together. 0 persists each event immediately.")
+	fs.IntVar(&cfg.phoneHomeBatchSize, "phone-home-batch-size", 100, "max number of phone-home events persisted together.")
+	fs.Int64Var(&cfg.maxRequestBody, "max-request-body", 16<<10, "max size in bytes of phone-home, /boot-failure and /_packet/simulate request bodies, larger bodies are refused with a 413. 0 does not limit them, except JSON phone-home status bodies which are always limited to 64 KiB.")
+	fs.DurationVar(&cfg.activeBootsMaxAge, "active-boots-max-age", time.Hour, "how long a machine is listed in /active-boots after it was last seen. Should be longer than the slowest install.")
+	fs.IntVar(&cfg.activeBootsMaxEntries, "active-boots-max-entries", 10000, "max number of machines listed in /active-boots, the least recently seen is evicted when full.")
+	fs.StringVar(&cfg.activeBootsToken, "active-boots-token", "", "enables listing the machines currently booting, with their MAC, IP and boot stage, as JSON from /active-boots. Clients must present this token as a bearer token.")
+	fs.StringVar(&cfg.statusToken, "status-token", "", "enables the machine status endpoints: the install status of a machine from /_packet/status/<mac>, the boot loops from /_packet/status/boot-loops and the activity stats from /_packet/stats. Clients must present this token as a bearer token.")
+	fs.DurationVar(&cfg.phoneHomeStatusTTL, "phone-home-status-ttl", time.Hour, "how long the install status a machine reports in a JSON phone-home body ({\"phase\", \"progress\", \"message\"}) is served by /_packet/status/<mac> after its last report.")
+	fs.IntVar(&cfg.phoneHomeStatusHistory, "phone-home-status-history", 10, "max number of install statuses kept per machine, the latest and the previous ones.")
+	fs.StringVar(&cfg.allowedURLHosts, "allowed-url-hosts", "", "comma separated list of hosts, or .domains matching all subdomains, that OSIE/Hook, chained iPXE script and redirect_to URLs must be on. Machines whose URLs are elsewhere get an error script. Empty allows all hosts.")
+	fs.StringVar(&cfg.allowedURLHostsFile, "allowed-url-hosts-file", "", "file of allowed URL hosts, one per line, added to -allowed-url-hosts.")
+	fs.StringVar(&cfg.caBundle, "ca-bundle", "", "optional PEM file of CA certificates served to booting machines at /ca.pem and passed to OSIE/Hook as the ca_bundle_url kernel arg.")
+	fs.StringVar(&cfg.phoneHomeWebhook, "phone-home-webhook", "", "optional http or https URL that a JSON notification with the MAC, IP, time and job ID is POSTed to for each processed phone-home. Delivery is in the background and does not affect the response to the machine.")
+	fs.DurationVar(&cfg.phoneHomeWebhookTimeout, "phone-home-webhook-timeout", 5*time.Second, "how long each delivery attempt of a phone-home webhook notification may take.")
+	fs.IntVar(&cfg.phoneHomeWebhookRetries, "phone-home-webhook-retries", 3, "number of times a phone-home webhook notification is retried after a network error or 5xx response, with backoff starting at 1s.")
+	fs.DurationVar(&cfg.phoneHomeDedupWindow, "phone-home-dedup-window", 0, "how long after a phone-home is processed that phone-homes of the same type from the same MAC are not reprocessed. 0 processes every phone-home.")
+	fs.BoolVar(&cfg.ipxeErrorScripts, "ipxe-error-scripts", false, "respond to iPXE script requests from machines without a hardware record, or whose record does not allow them to PXE, with a script that prints the reason on the console and exits instead of a 404.")
+	fs.StringVar(&cfg.fallbackIPXEScript, "fallback-ipxe-script", "", "iPXE script, inline starting with #!ipxe or the path of a file, served to boot script requests whose hardware lookup timed out or failed, e.g. booting a rescue image during a backend outage. Machines with no hardware record, or whose record fails -hardware-missing-field, are not served it. Off by default.")
+	fs.BoolVar(&cfg.collectInventory, "collect-inventory", false, "serve machines without a hardware record an iPXE script that posts their inventory to /inventory. Reports are only accepted from the address the script was served to, with the single use nonce it carries. A hardware record is created from it if the backend supports it, otherwise it is stored in -inventory-log, which is then required.")
+	fs.StringVar(&cfg.inventoryLog, "inventory-log", "", "optional file that inventory reports are appended to as JSON lines, for operators to review.")
+	fs.DurationVar(&cfg.inventoryRetry, "inventory-retry", 5*time.Minute, "how long machines whose inventory was stored for review wait before booting again, a whole number of seconds.")
+	fs.DurationVar(&cfg.retryAfterMin, "retry-after-min", time.Second, "Retry-After of 429 and 503 responses when Boots is idle. It grows with the number of in-flight HTTP requests and is jittered.")
+	fs.DurationVar(&cfg.retryAfterMax, "retry-after-max", time.Minute, "Retry-After of 429 and 503 responses once -retry-after-capacity HTTP requests are in flight.")
+	fs.IntVar(&cfg.retryAfterCapacity, "retry-after-capacity", 100, "number of in-flight HTTP requests at which the Retry-After of 429 and 503 responses reaches -retry-after-max.")
+	fs.IntVar(&cfg.phoneHomeUnknownStatus, "phone-home-unknown-status", http.StatusOK, "response code to a phone-home from an address no hardware record is found for, 200, 204 or 404.")
+	fs.StringVar(&cfg.phoneHomeDuplicateResponse, "phone-home-duplicate-response", phoneHomeDuplicateAck, "response to a duplicate phone-home within -phone-home-dedup-window. 'ack' responds 200, 'reject' responds 429.")
+	fs.DurationVar(&cfg.jobLookupTimeout, "job-lookup-timeout", 5*time.Second, "how long a boot script or other job file request waits for the hardware backend to find the machine's record before getting a 504. 0 waits as long as the client does.")
+	fs.DurationVar(&cfg.readinessTimeout, "readiness-timeout", 2*time.Second, "how long the readiness check at /readiness and -check wait for the hardware and workflow backends to respond before reporting not ready.")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 20*time.Second, "how long in-flight DHCP, HTTP, TFTP and syslog requests are given to finish on shutdown. Should be less than the pod's termination grace period.")
 
 	return &ffcli.Command{
 		Name:       name,
 		ShortUsage: "Run Boots server for provisioning",
 		FlagSet:    fs,
-		Options:    []ff.Option{ff.WithEnvVarPrefix(name)},
+		Options:    []ff.Option{ff.WithEnvVarPrefix(name), ff.WithConfigFileFlag("config-file"), ff.WithConfigFileParser(ffyaml.Parser)},
 		UsageFunc:  customUsageFunc,
 	}
 }
@@ -392,31 +1285,165 @@ func (cf *config) registerInstallers() (job.Installers, error) {
 		return job.Installers{}, err
 	}
 
+	if _, err := installers.ParseKernelArgs(cf.extraKernelArgs); err != nil {
+		return job.Installers{}, errors.WithMessage(err, "invalid -extra-kernel-args")
+	}
+	archKernelArgs, err := parseArchKernelArgs(cf.extraKernelArgsArch)
+	if err != nil {
+		return job.Installers{}, err
+	}
+	extraKernelArgs := installers.NewKernelArgs(cf.extraKernelArgs, archKernelArgsTemplate(archKernelArgs))
+
+	allowedHosts, err := cf.hostAllowList()
+	if err != nil {
+		return job.Installers{}, err
+	}
+
 	// register custom ipxe
-	o := customipxe.Installer(extraIPXEVars)
+	o := customipxe.Installer(extraIPXEVars,
+		customipxe.WithAllowedHosts(allowedHosts),
+		customipxe.WithKernelArgs(cf.customIPXEKernelArgs, extraKernelArgs),
+	)
 	i.RegisterDistro("custom_ipxe", o.BootScript("custom_ipxe"))
 	i.RegisterInstaller("custom_ipxe", o.BootScript("custom_ipxe"))
 
 	dataModelVersion := env.Get("DATA_MODEL_VERSION")
-	auth := env.Get("TINKERBELL_GRPC_AUTHORITY")
+	var auth string
+	if auths := tinkAuthorities(); len(auths) > 0 {
+		// workers are given a single tink server
+		auth = auths[0]
+	}
 	if dataModelVersion == "1" && auth == "" {
 		return job.Installers{}, errors.New("TINKERBELL_GRPC_AUTHORITY is required when in tinkerbell mode")
 	}
 
+	mirrors, err := cf.osieMirrorList()
+	if err != nil {
+		return job.Installers{}, err
+	}
+	facilityURLs, err := osie.ParseFacilityURLs(cf.osieFacilityURLs)
+	if err != nil {
+		return job.Installers{}, errors.WithMessage(err, "invalid -osie-facility-urls")
+	}
+
 	// register osie
 	o = osie.Installer(
 		dataModelVersion,
 		auth,
-		cf.extraKernelArgs,
+		extraKernelArgs,
 		env.Get("DOCKER_REGISTRY"),
 		env.Get("REGISTRY_USERNAME"),
 		env.Get("REGISTRY_PASSWORD"),
 		env.Bool("TINKERBELL_TLS", true),
 		cf.osiePathOverride,
 		extraIPXEVars,
+		osie.WithStaticNetworkKernelArgs(cf.staticNetworkKernelArgs),
+		osie.WithFailureReporting(cf.ipxeReportFailures),
+		osie.WithCABundleURL(cf.caBundleURL()),
+		osie.WithAllowedHosts(allowedHosts),
+		osie.WithMirrors(mirrors, cf.osieMirrorSelection == "mac-hash"),
+		osie.WithFacilityURLs(facilityURLs),
+		osie.WithKernelArgs(cf.osieKernelArgs, cf.osieDiscoverKernelArgs),
+		osie.WithVerifier(cf.osieVerifier),
 	)
 	i.RegisterDistro("discovery", o.BootScript("discover"))
 	i.RegisterDefaultInstaller(o.BootScript("default"))
 
+	if cf.ipxeTemplateDir != "" {
+		templates, err := ipxetemplate.Load(cf.ipxeTemplateDir)
+		if err != nil {
+			return job.Installers{}, err
+		}
+		for facility, t := range templates {
+			i.RegisterFacility(facility, ipxetemplate.Installer(t, extraIPXEVars, extraKernelArgs).BootScript(facility))
+		}
+	}
+
+	arches, err := parseInstallerArches(cf.installerArches)
+	if err != nil {
+		return job.Installers{}, err
+	}
+	for installer, a := range arches {
+		i.RestrictArch(installer, a)
+	}
+	if i.Priority, err = parseInstallerPriority(cf.installerPriority); err != nil {
+		return job.Installers{}, err
+	}
+	i.AllowedHosts = allowedHosts
+
 	return i, nil
 }
+
+// parseInstallerArches parses space separated installer=arch,arch entries, where
+// installer is default, installer:<name>, slug:<slug> or distro:<distro>.
+func parseInstallerArches(s string) (map[string][]string, error) {
+	arches := map[string][]string{}
+	for _, entry := range strings.Fields(s) {
+		installer, list, ok := strings.Cut(entry, "=")
+		if !ok || list == "" {
+			return nil, errors.Errorf("invalid -installer-arches entry %q, must be installer=arch,arch", entry)
+		}
+		if installer != "default" && !strings.HasPrefix(installer, "installer:") && !strings.HasPrefix(installer, "slug:") && !strings.HasPrefix(installer, "distro:") && !strings.HasPrefix(installer, "facility:") {
+			return nil, errors.Errorf("invalid installer %q in -installer-arches, must be default, installer:<name>, slug:<slug>, distro:<distro> or facility:<code>", installer)
+		}
+		arches[installer] = strings.Split(list, ",")
+	}
+
+	return arches, nil
+}
+
+// parseInstallerPriority parses comma separated installer kinds, facility, installer,
+// slug and distro, highest priority first, where kinds joined with "|" share a priority,
+// e.g. "facility,installer|slug,distro". Each kind must be given once.
+func parseInstallerPriority(s string) (map[string]int, error) {
+	kinds := map[string]bool{"facility": true, "installer": true, "slug": true, "distro": true}
+	priority := map[string]int{}
+	for rank, group := range strings.Split(s, ",") {
+		for _, kind := range strings.Split(group, "|") {
+			kind = strings.TrimSpace(kind)
+			if !kinds[kind] {
+				return nil, errors.Errorf("invalid installer kind %q in -installer-priority, must be facility, installer, slug or distro", kind)
+			}
+			if _, dup := priority[kind]; dup {
+				return nil, errors.Errorf("invalid -installer-priority, %s is given more than once", kind)
+			}
+			priority[kind] = rank
+		}
+	}
+	if len(priority) != len(kinds) {
+		return nil, errors.New("invalid -installer-priority, must rank each of facility, installer, slug and distro")
+	}
+
+	return priority, nil
+}
+
+// osieMirrorList returns the parsed -osie-mirrors, nil if there are none.
+func (cf *config) osieMirrorList() ([]osie.Mirror, error) {
+	if cf.osieMirrorSelection != "round-robin" && cf.osieMirrorSelection != "mac-hash" {
+		return nil, errors.Errorf("invalid -osie-mirror-selection %q, must be round-robin or mac-hash", cf.osieMirrorSelection)
+	}
+	if cf.osieMirrors == "" {
+		return nil, nil
+	}
+	if cf.osiePathOverride != "" {
+		return nil, errors.New("-osie-mirrors and -osie-path-override are mutually exclusive")
+	}
+	mirrors, err := osie.ParseMirrors(cf.osieMirrors)
+
+	return mirrors, errors.WithMessage(err, "invalid -osie-mirrors")
+}
+
+// hostAllowList returns the allow-list of hosts from -allowed-url-hosts and the
+// lines of -allowed-url-hosts-file, nil if neither has any entries.
+func (cf *config) hostAllowList() (*installers.HostAllowList, error) {
+	entries := strings.Split(cf.allowedURLHosts, ",")
+	if cf.allowedURLHostsFile != "" {
+		b, err := os.ReadFile(cf.allowedURLHostsFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read allowed url hosts file")
+		}
+		entries = append(entries, strings.Split(string(b), "\n")...)
+	}
+
+	return installers.NewHostAllowList(entries)
+}
@@ -4,11 +4,18 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/client/standalone"
 	"github.com/tinkerbell/ipxedust"
 )
 
@@ -19,14 +26,63 @@ func TestParser(t *testing.T) {
 			TFTPTimeout:          time.Second * 5,
 			EnableTFTPSinglePort: false,
 		},
-		ipxeTFTPEnabled:    true,
-		ipxeHTTPEnabled:    true,
-		ipxeRemoteTFTPAddr: "192.168.2.225",
-		ipxeRemoteHTTPAddr: "192.168.2.225:8080",
-		httpAddr:           "192.168.2.225:8080",
-		dhcpAddr:           "0.0.0.0:67",
-		syslogAddr:         "0.0.0.0:514",
-		logLevel:           "info",
+		ipxeTFTPEnabled:            true,
+		ipxeHTTPEnabled:            true,
+		pprofEnabled:               true,
+		ipxeRemoteTFTPAddr:         "192.168.2.225",
+		ipxeRemoteHTTPAddr:         "192.168.2.225:8080",
+		ipxeRemoteHTTPScheme:       "http",
+		httpAddr:                   "192.168.2.225:8080",
+		dhcpAddr:                   "0.0.0.0:67",
+		syslogAddr:                 "0.0.0.0:514",
+		syslogForwardBuffer:        10000,
+		logLevel:                   "info",
+		tlsMinVersion:              "1.2",
+		backendRetries:             3,
+		backendRetryMaxInterval:    2 * time.Second,
+		breakerWindow:              time.Minute,
+		userAgentArch:              true,
+		breakerCooldown:            30 * time.Second,
+		backendEndpointCooldown:    30 * time.Second,
+		hardwareCacheSize:          1000,
+		hardwareFileInterval:       5 * time.Second,
+		httpRateBurst:              20,
+		httpLogFormat:              "text",
+		httpLogFields:              "remote-addr,duration,status",
+		dhcpMode:                   "auto",
+		dhcpInvalidMAC:             "ignore",
+		osieMirrorSelection:        "round-robin",
+		installerPriority:          "facility,installer,slug,distro",
+		dhcpUnknownArch:            "bios",
+		dhcpQuietStateSource:       "hardware",
+		dhcpUnmatchedRelay:         "allow",
+		dhcpTraceRate:              1,
+		logPacketsRate:             1,
+		logPacketsRedact:           "registry_password,pwhash",
+		activeBootsMaxAge:          time.Hour,
+		activeBootsMaxEntries:      10000,
+		phoneHomeStatusTTL:         time.Hour,
+		phoneHomeStatusHistory:     10,
+		noNetbootResponse:          "404",
+		phoneHomeBatchSize:         100,
+		maxRequestBody:             16 << 10,
+		phoneHomeDuplicateResponse: "ack",
+		phoneHomeUnknownStatus:     200,
+		inventoryRetry:             5 * time.Minute,
+		retryAfterMin:              time.Second,
+		retryAfterMax:              time.Minute,
+		retryAfterCapacity:         100,
+		shutdownTimeout:            20 * time.Second,
+		phoneHomeWebhookTimeout:    5 * time.Second,
+		phoneHomeWebhookRetries:    3,
+		readinessTimeout:           2 * time.Second,
+		jobLookupTimeout:           5 * time.Second,
+		osieChecksumInterval:       time.Hour,
+		bootLoopWindow:             30 * time.Minute,
+		tftpQueueTimeout:           time.Second,
+		httpReadHeaderTimeout:      10 * time.Second,
+		httpWriteTimeout:           5 * time.Minute,
+		httpIdleTimeout:            time.Minute,
 	}
 	got := &config{}
 	fs := flag.NewFlagSet(name, flag.ContinueOnError)
@@ -37,6 +93,9 @@ func TestParser(t *testing.T) {
 		"-http-addr", "192.168.2.225:8080",
 		"-dhcp-addr", "0.0.0.0:67",
 		"-syslog-addr", "0.0.0.0:514",
+		"-http-read-header-timeout", "10s",
+		"-http-write-timeout", "5m",
+		"-http-idle-timeout", "1m",
 	}
 	cli := newCLI(got, fs)
 	cli.Parse(args)
@@ -129,6 +188,184 @@ func TestParseDynamicIPXEVarsFunc(t *testing.T) {
 	}
 }
 
+func TestParserConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boots.yaml")
+	yaml := `
+http-addr: 192.168.2.225:8080
+dhcp-mode: proxy
+ipxe-enable-tftp: false
+phone-home-batch-size: 50
+http-rate-limit: 2.5
+shutdown-timeout: 45s
+ipxe-vars: a=1 b=2
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// the defaults, with the values from the file
+	want := &config{}
+	parseArgs(t, want, nil)
+	want.configFile = path
+	want.httpAddr = "192.168.2.225:8080"
+	want.dhcpMode = "proxy"
+	want.ipxeTFTPEnabled = false
+	want.phoneHomeBatchSize = 50
+	want.httpRateLimit = 2.5
+	want.shutdownTimeout = 45 * time.Second
+	want.ipxeVars = "a=1 b=2"
+
+	got := &config{}
+	parseArgs(t, got, []string{"-config-file", path})
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(ipxedust.Command{}, "Log"), cmp.AllowUnexported(config{})); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// env vars override the file and flags override env vars
+	t.Setenv("BOOTS_DHCP_MODE", "disabled")
+	t.Setenv("BOOTS_HTTP_ADDR", "10.0.0.1:80")
+	got = &config{}
+	parseArgs(t, got, []string{"-config-file", path, "-http-addr", "10.0.0.2:80"})
+	if got.dhcpMode != "disabled" || got.httpAddr != "10.0.0.2:80" || got.shutdownTimeout != 45*time.Second {
+		t.Fatalf("want dhcp-mode from the env, http-addr from the flag and shutdown-timeout from the file, got %q, %q, %v", got.dhcpMode, got.httpAddr, got.shutdownTimeout)
+	}
+}
+
+func TestParserConfigFileUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boots.yaml")
+	if err := os.WriteFile(path, []byte("http-addr: 10.0.0.1:80\nhttp-adr: 10.0.0.2:80\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err := newCLI(&config{}, flag.NewFlagSet(name, flag.ContinueOnError)).Parse([]string{"-config-file", path})
+	var noExec ffcli.NoExecError
+	if err == nil || errors.As(err, &noExec) || !strings.Contains(err.Error(), `"http-adr"`) {
+		t.Fatalf("want an error naming the unknown key, got: %v", err)
+	}
+}
+
+// parseArgs parses args into cfg, failing the test if they are invalid.
+func parseArgs(t *testing.T, cfg *config, args []string) {
+	t.Helper()
+	// boots has no Exec, so a successful parse still returns a NoExecError
+	var noExec ffcli.NoExecError
+	if err := newCLI(cfg, flag.NewFlagSet(name, flag.ContinueOnError)).Parse(args); !errors.As(err, &noExec) {
+		t.Fatal(err)
+	}
+}
+
+func TestParserEnv(t *testing.T) {
+	tests := map[string]struct {
+		env  map[string]string
+		args []string
+		want config
+	}{
+		"env only": {
+			env: map[string]string{
+				"BOOTS_KUBECONFIG": "/env/kubeconfig",
+				"BOOTS_HTTP_ADDR":  "10.0.0.1:80",
+				"BOOTS_IPXE_VARS":  "a=1 b=2",
+			},
+			want: config{kubeconfig: "/env/kubeconfig", httpAddr: "10.0.0.1:80", ipxeVars: "a=1 b=2"},
+		},
+		"flag only": {
+			args: []string{"-kubeconfig", "/flag/kubeconfig", "-http-addr", "10.0.0.2:80", "-ipxe-vars", "c=3"},
+			want: config{kubeconfig: "/flag/kubeconfig", httpAddr: "10.0.0.2:80", ipxeVars: "c=3"},
+		},
+		"flag overrides env": {
+			env: map[string]string{
+				"BOOTS_KUBECONFIG": "/env/kubeconfig",
+				"BOOTS_HTTP_ADDR":  "10.0.0.1:80",
+				"BOOTS_IPXE_VARS":  "a=1 b=2",
+			},
+			args: []string{"-http-addr", "10.0.0.2:80"},
+			want: config{kubeconfig: "/env/kubeconfig", httpAddr: "10.0.0.2:80", ipxeVars: "a=1 b=2"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			got := &config{}
+			// boots has no Exec, so a successful parse still returns a NoExecError
+			var noExec ffcli.NoExecError
+			if err := newCLI(got, flag.NewFlagSet(name, flag.ContinueOnError)).Parse(tt.args); !errors.As(err, &noExec) {
+				t.Fatal(err)
+			}
+			if got.kubeconfig != tt.want.kubeconfig || got.httpAddr != tt.want.httpAddr || got.ipxeVars != tt.want.ipxeVars {
+				t.Fatalf("want kubeconfig: %q, http-addr: %q, ipxe-vars: %q, got %q, %q, %q", tt.want.kubeconfig, tt.want.httpAddr, tt.want.ipxeVars, got.kubeconfig, got.httpAddr, got.ipxeVars)
+			}
+		})
+	}
+}
+
+func TestParseInstallerArches(t *testing.T) {
+	got, err := parseInstallerArches("default=x86_64,aarch64  installer:custom_ipxe=x86_64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"default":               {"x86_64", "aarch64"},
+		"installer:custom_ipxe": {"x86_64"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+	for _, s := range []string{"default", "default=", "osie=x86_64"} {
+		if _, err := parseInstallerArches(s); err == nil {
+			t.Fatalf("want error for %q", s)
+		}
+	}
+}
+
+func TestParseInstallerPriority(t *testing.T) {
+	got, err := parseInstallerPriority("facility, installer|slug ,distro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]int{"facility": 0, "installer": 1, "slug": 1, "distro": 2}, got); diff != "" {
+		t.Fatal(diff)
+	}
+	for _, s := range []string{"", "facility,installer,slug", "facility,installer,slug,distro,slug", "facility,installer|os,slug,distro", "facility,,installer,slug,distro"} {
+		if _, err := parseInstallerPriority(s); err == nil {
+			t.Fatalf("want error for %q", s)
+		}
+	}
+}
+
+func TestParseIPXERemoteHTTPAddr(t *testing.T) {
+	tests := []struct {
+		addr, scheme         string
+		wantScheme, wantAddr string
+		wantErr              bool
+	}{
+		{addr: "192.168.2.225:8080", scheme: "http", wantScheme: "http", wantAddr: "192.168.2.225:8080"},
+		{addr: "192.168.2.225:8443", scheme: "https", wantScheme: "https", wantAddr: "192.168.2.225:8443"},
+		{addr: "https://ipxe.example.com:8443/ipxe", scheme: "http", wantScheme: "https", wantAddr: "ipxe.example.com:8443/ipxe"},
+		{addr: "http://192.168.2.225:8080", scheme: "https", wantScheme: "http", wantAddr: "192.168.2.225:8080"},
+		{addr: "192.168.2.225:8080", scheme: "ftp", wantErr: true},
+		{addr: "tftp://192.168.2.225", scheme: "http", wantErr: true},
+		{addr: "https://", scheme: "http", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr+"/"+tt.scheme, func(t *testing.T) {
+			scheme, addr, err := parseIPXERemoteHTTPAddr(tt.addr, tt.scheme)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want error")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scheme != tt.wantScheme || addr != tt.wantAddr {
+				t.Fatalf("want %s %s, got %s %s", tt.wantScheme, tt.wantAddr, scheme, addr)
+			}
+		})
+	}
+}
+
 func TestCustomUsageFunc(t *testing.T) {
 	var defaultIP net.IP
 	addrs, err := net.InterfaceAddrs()
@@ -153,22 +390,156 @@ func TestCustomUsageFunc(t *testing.T) {
   Run Boots server for provisioning
 
 FLAGS
-  -dhcp-addr              IP and port to listen on for DHCP. (default "%v:67")
-  -extra-kernel-args      Extra set of kernel args (k=v k=v) that are appended to the kernel cmdline when booting via iPXE.
-  -http-addr              local IP and port to listen on for the serving iPXE binaries and files via HTTP. (default "%[1]v:80")
-  -ipxe-enable-http       enable serving iPXE binaries via HTTP. (default "true")
-  -ipxe-enable-tftp       enable serving iPXE binaries via TFTP. (default "true")
-  -ipxe-remote-http-addr  remote IP and port where iPXE binaries are served via HTTP. Overrides -http-addr for iPXE binaries only.
-  -ipxe-remote-tftp-addr  remote IP where iPXE binaries are served via TFTP. Overrides -tftp-addr.
-  -ipxe-tftp-addr         local IP and port to listen on for serving iPXE binaries via TFTP (port must be 69). (default "0.0.0.0:69")
-  -ipxe-tftp-timeout      local iPXE TFTP server requests timeout. (default "5s")
-  -ipxe-vars              additional variable definitions to include in all iPXE installer scripts. Separate multiple var definitions with spaces, e.g. 'var1=val1 var2=val2'.
-  -kube-namespace         An optional Kubernetes namespace override to query hardware data from.
-  -kubeconfig             The Kubernetes config file location. Only applies if DATA_MODEL_VERSION=kubernetes.
-  -kubernetes             The Kubernetes API URL, used for in-cluster client construction. Only applies if DATA_MODEL_VERSION=kubernetes.
-  -log-level              log level. (default "info")
-  -osie-path-override     A custom URL for OSIE/Hook images.
-  -syslog-addr            IP and port to listen on for syslog messages. (default "%[1]v:514")
+  -active-boots-max-age           BOOTS_ACTIVE_BOOTS_MAX_AGE           how long a machine is listed in /active-boots after it was last seen. Should be longer than the slowest install. (default "1h0m0s")
+  -active-boots-max-entries       BOOTS_ACTIVE_BOOTS_MAX_ENTRIES       max number of machines listed in /active-boots, the least recently seen is evicted when full. (default "10000")
+  -active-boots-token             BOOTS_ACTIVE_BOOTS_TOKEN             enables listing the machines currently booting, with their MAC, IP and boot stage, as JSON from /active-boots. Clients must present this token as a bearer token.
+  -allow-pxe-default              BOOTS_ALLOW_PXE_DEFAULT              allow hardware whose record has no netboot settings, or that has no hardware record, to PXE, booting the default installer unless the record selects another, e.g. during initial bring-up. An explicit allow_pxe: false is still honored. Overrides -no-netboot-response, machines with no record are served -collect-inventory instead when it is set. (default "false")
+  -allow-synthetic-backend        BOOTS_ALLOW_SYNTHETIC_BACKEND        permit DATA_MODEL_VERSION=synthetic, a backend for load testing that fabricates hardware allowed to netboot for any MAC. Never set it in production. (default "false")
+  -allowed-url-hosts              BOOTS_ALLOWED_URL_HOSTS              comma separated list of hosts, or .domains matching all subdomains, that OSIE/Hook, chained iPXE script and redirect_to URLs must be on. Machines whose URLs are elsewhere get an error script. Empty allows all hosts.
+  -allowed-url-hosts-file         BOOTS_ALLOWED_URL_HOSTS_FILE         file of allowed URL hosts, one per line, added to -allowed-url-hosts.
+  -backend-endpoint-cooldown      BOOTS_BACKEND_ENDPOINT_COOLDOWN      how long a tink server that failed is skipped for workflow lookups, while others are healthy, when TINKERBELL_GRPC_AUTHORITY lists multiple tink servers. (default "30s")
+  -backend-retries                BOOTS_BACKEND_RETRIES                number of times a hardware or workflow lookup is retried, with exponential backoff, after a network error, timeout or server error from the backend, including the gRPC codes Unavailable, DeadlineExceeded, ResourceExhausted and Aborted. Lookups that find no hardware are not retried. 0 disables retries. (default "3")
+  -backend-retry-max-interval     BOOTS_BACKEND_RETRY_MAX_INTERVAL     the longest wait between retries of a backend lookup, the wait starts at 100ms and doubles on every retry. (default "2s")
+  -boot-failure-log               BOOTS_BOOT_FAILURE_LOG               optional file that boot failures reported by iPXE clients are appended to as JSON lines.
+  -boot-loop-halt                 BOOTS_BOOT_LOOP_HALT                 serve boot looping machines an iPXE script that halts them instead of their boot script, until they are reset. Requires -boot-loop-threshold. (default "false")
+  -boot-loop-threshold            BOOTS_BOOT_LOOP_THRESHOLD            number of boot script requests of a machine within -boot-loop-window past which it is logged as boot looping and listed as such by /_packet/status/boot-loops. 0 disables detection. (default "0")
+  -boot-loop-window               BOOTS_BOOT_LOOP_WINDOW               sliding window boot script requests are counted in for -boot-loop-threshold. (default "30m0s")
+  -breaker-cooldown               BOOTS_BREAKER_COOLDOWN               how long the open backend circuit breaker fails lookups right away before passing a single probe lookup to the backend, which closes the breaker if it succeeds. (default "30s")
+  -breaker-failures               BOOTS_BREAKER_FAILURES               number of consecutive hardware or workflow lookups failing with a network error, timeout or server error, after their retries, within -breaker-window, that open the backend circuit breaker. While open lookups fail right away. 0 disables the breaker. (default "0")
+  -breaker-window                 BOOTS_BREAKER_WINDOW                 how long after the first of the -breaker-failures consecutive failed lookups the last must fail to open the backend circuit breaker, older failures are forgotten. 0 counts consecutive failures however far apart. (default "1m0s")
+  -ca-bundle                      BOOTS_CA_BUNDLE                      optional PEM file of CA certificates served to booting machines at /ca.pem and passed to OSIE/Hook as the ca_bundle_url kernel arg.
+  -check                          BOOTS_CHECK                          validate the configuration, templates and hardware backend connection, print OK and exit without serving. Exits non-zero on the first error. (default "false")
+  -collect-inventory              BOOTS_COLLECT_INVENTORY              serve machines without a hardware record an iPXE script that posts their inventory to /inventory. Reports are only accepted from the address the script was served to, with the single use nonce it carries. A hardware record is created from it if the backend supports it, otherwise it is stored in -inventory-log, which is then required. (default "false")
+  -config-file                    BOOTS_CONFIG_FILE                    YAML file of flag values keyed by flag name, e.g. 'http-addr: 0.0.0.0:80', overridden by env vars and the command line. Unknown keys are an error. On SIGHUP it is re-read and changes to -extra-kernel-args, -ipxe-vars, -osie-path-override and -log-level are applied without a restart.
+  -config-token                   BOOTS_CONFIG_TOKEN                   enables serving the effective configuration as JSON from /_packet/config. Clients must present this token as a bearer token.
+  -content-type-map               BOOTS_CONTENT_TYPE_MAP               '.ext=type' pairs separated by ';' forcing the Content-Type of iPXE scripts and binaries by the extension of the request path, e.g. '.ipxe=text/plain; .efi=application/octet-stream'. Other files keep the type Go detects.
+  -custom-ipxe-kernel-args        BOOTS_CUSTOM_IPXE_KERNEL_ARGS        default kernel args (k=v k=v) of the custom iPXE installer, overridden like -osie-kernel-args. Custom scripts get the merged args in the kernel_args iPXE variable.
+  -dhcp-addr                      BOOTS_DHCP_ADDR                      comma separated IPv4 addresses and ports to listen on for DHCP, e.g. on multiple provisioning VLANs. DHCP metrics are labelled with the address a request was received on. Multiple addresses sharing a port are served from one wildcard socket so broadcasts are received, each packet is answered by the address it was sent to or the address on the interface it arrived on. Boots exits if any address cannot be bound. (default "%v:67")
+  -dhcp-arch-map                  BOOTS_DHCP_ARCH_MAP                  comma separated list of option 93 arch=profile pairs, e.g. '25=uefi', choosing the iPXE binary sent to PXE clients. Profiles are bios, uefi and arm64.
+  -dhcp-authoritative             BOOTS_DHCP_AUTHORITATIVE             in auto -dhcp-mode, send a DHCPNAK to DHCPREQUESTs for an address other than the one the machine is assigned, or from machines with no hardware record, so the client restarts discovery. By default they are dropped. (default "false")
+  -dhcp-boot-filenames            BOOTS_DHCP_BOOT_FILENAMES            comma separated list of option 93 arch/firmware=filename pairs, e.g. '0/pxe=undionly.kpxe,7/ipxe=snp.efi', choosing the boot filename sent to PXE clients that are not sent the boot script by their arch and firmware, pxe or ipxe, over -dhcp-arch-map. The file is served like the iPXE binaries, from -ipxe-remote-tftp-addr or -ipxe-remote-http-addr if set, otherwise it must be one Boots serves.
+  -dhcp-invalid-mac               BOOTS_DHCP_INVALID_MAC               how to handle DHCP packets with an all zero or broadcast chaddr. 'ignore' drops them, 'client-id' looks up hardware by the MAC in the client identifier (option 61). (default "ignore")
+  -dhcp-ipxe-user-classes         BOOTS_DHCP_IPXE_USER_CLASSES         comma separated list of DHCP user-classes (option 77), e.g. 'iPXE', of iPXE builds that are sent the boot script URL instead of the Tinkerbell iPXE binary to chainload. Tinkerbell iPXE always is.
+  -dhcp-lease-time                BOOTS_DHCP_LEASE_TIME                lease time offered to every machine instead of the lease time of its hardware record, e.g. 10m for short leases during provisioning. 0 uses the hardware record's. (default "0s")
+  -dhcp-mode                      BOOTS_DHCP_MODE                      how DHCP is served. 'auto' assigns addresses and serves boot information, 'proxy' only serves boot information to PXE clients, as ProxyDHCP on the DHCP port and 4011, alongside another DHCP server, 'disabled' does not serve DHCP. (default "auto")
+  -dhcp-quiet-log-sample          BOOTS_DHCP_QUIET_LOG_SAMPLE          log one in every N DISCOVERs from quiet machines at info level, 0 logs none. All are counted in the dhcp_quiet_total metric. (default "0")
+  -dhcp-quiet-state-source        BOOTS_DHCP_QUIET_STATE_SOURCE        where the state matched by -dhcp-quiet-states is read from, 'hardware' for the hardware record's state or 'instance' for the instance's state. (default "hardware")
+  -dhcp-quiet-states              BOOTS_DHCP_QUIET_STATES              comma separated list of states, e.g. 'in_use', of machines done provisioning that keep network booting to be told to boot from disk. Their per-DISCOVER logging is at debug level instead of info. Off by default.
+  -dhcp-rebinding-time            BOOTS_DHCP_REBINDING_TIME            T2 rebinding time offered with -dhcp-lease-time, between -dhcp-renewal-time and the lease time. 0 leaves it to the client, usually 7/8 of the lease time. (default "0s")
+  -dhcp-relay-subnets             BOOTS_DHCP_RELAY_SUBNETS             comma separated list of CIDRs DHCP relays are configured in. Packets relayed from a giaddr outside of them are handled by -dhcp-unmatched-relay. Off by default.
+  -dhcp-renewal-time              BOOTS_DHCP_RENEWAL_TIME              T1 renewal time offered with -dhcp-lease-time, shorter than it. 0 leaves it to the client, usually half the lease time. (default "0s")
+  -dhcp-trace-macs                BOOTS_DHCP_TRACE_MACS                comma separated list of MACs, or '*' for all clients, whose DHCP replies are logged with every option decoded and in hex. Off by default.
+  -dhcp-trace-rate                BOOTS_DHCP_TRACE_RATE                max number of DHCP reply traces logged per second, traces over the limit are dropped. (default "1")
+  -dhcp-unknown-arch              BOOTS_DHCP_UNKNOWN_ARCH              how to handle PXE clients whose option 93 arch is neither mapped nor known. ARM, including U-Boot, UEFI, including RISC-V, and BIOS arches are known, others such as Itanium, PowerPC and s390 or values past the IANA list are not. A profile (bios, uefi, arm64), 'deny' to send no boot filename, or 'bootfile' to send -dhcp-unknown-arch-bootfile. (default "bios")
+  -dhcp-unknown-arch-bootfile     BOOTS_DHCP_UNKNOWN_ARCH_BOOTFILE     boot filename sent to PXE clients with an unknown arch when -dhcp-unknown-arch is 'bootfile'.
+  -dhcp-unmatched-relay           BOOTS_DHCP_UNMATCHED_RELAY           how to handle DHCP packets relayed from a giaddr outside of -dhcp-relay-subnets. 'allow' handles them like any other, 'ignore' drops them, 'deny' drops them and logs the unknown relay. All are counted in the dhcp_unmatched_relay_total metric. (default "allow")
+  -drain-token                    BOOTS_DRAIN_TOKEN                    enables draining for a rolling restart with a POST to /_packet/drain, after which /readiness reports not ready and new job file requests get a 503 while those in flight finish. A POST of enabled=false stops draining. Clients must present this token as a bearer token.
+  -enable-pprof                   BOOTS_ENABLE_PPROF                   serve the pprof profiling handlers under /_packet/pprof/. When disabled the pprof paths respond 404. (default "true")
+  -extra-kernel-args              BOOTS_EXTRA_KERNEL_ARGS              Extra set of kernel args (k=v k=v) that are appended to the kernel cmdline when booting via iPXE. May contain Go template actions expanded per machine with .MAC, .Hostname, .IP, .HardwareID, .Facility, .Plan and .Arch, e.g. hostname={{.Hostname}}.
+  -extra-kernel-args-arch         BOOTS_EXTRA_KERNEL_ARGS_ARCH         ; separated list of arch=args, e.g. 'x86_64=console=ttyS0,115200; aarch64=console=ttyAMA0', of kernel args added after -extra-kernel-args for machines booting as the arch, overriding its args with the same key. The arch is x86_64, aarch64 or another DHCP option 93 arch, args may contain template actions like -extra-kernel-args.
+  -fallback-ipxe-script           BOOTS_FALLBACK_IPXE_SCRIPT           iPXE script, inline starting with #!ipxe or the path of a file, served to boot script requests whose hardware lookup timed out or failed, e.g. booting a rescue image during a backend outage. Machines with no hardware record, or whose record fails -hardware-missing-field, are not served it. Off by default.
+  -file-serve-idle-timeout        BOOTS_FILE_SERVE_IDLE_TIMEOUT        how long an iPXE script or binary transfer may go without writing to a stalled client before it is aborted, slow transfers that keep making progress are not. 0 is no limit. (default "0s")
+  -file-serve-timeout             BOOTS_FILE_SERVE_TIMEOUT             how long an iPXE script or binary transfer may take overall before it is aborted and its connection closed. 0 is no limit. (default "0s")
+  -hardware-cache-size            BOOTS_HARDWARE_CACHE_SIZE            the number of hardware lookups cached when -hardware-cache-ttl is set, the least recently used are evicted. (default "1000")
+  -hardware-cache-ttl             BOOTS_HARDWARE_CACHE_TTL             how long hardware found for DHCP and HTTP requests is cached, sparing the backend when many machines boot at once. Hardware changes take up to this long to be seen. 0 disables the cache. (default "0s")
+  -hardware-default-arch          BOOTS_HARDWARE_DEFAULT_ARCH          arch, e.g. 'x86_64', used for hardware records missing one when -hardware-missing-field is 'default'.
+  -hardware-default-facility      BOOTS_HARDWARE_DEFAULT_FACILITY      facility used for hardware records missing one when -hardware-missing-field is 'default'.
+  -hardware-file                  BOOTS_HARDWARE_FILE                  JSON or YAML file of hardware records, in the BOOTS_STANDALONE_JSON format, that hardware is looked up in by MAC and IP. Only applies if DATA_MODEL_VERSION=file.
+  -hardware-file-interval         BOOTS_HARDWARE_FILE_INTERVAL         how often -hardware-file is checked for changes, it is reloaded when it changed and is valid. 0 disables reloading. (default "5s")
+  -hardware-missing-field         BOOTS_HARDWARE_MISSING_FIELD         how to handle hardware records missing a field required to boot them (id, mac, ip, facility, arch). 'deny' fails the request naming the field, 'default' uses -hardware-default-facility and -hardware-default-arch for missing facility and arch and denies the rest. Off by default.
+  -http-addr                      BOOTS_HTTP_ADDR                      local IP and port to listen on for the serving iPXE binaries and files via HTTP. IPv6 addresses are bracketed, e.g. [::]:80. (default "%[1]v:80")
+  -http-extra-headers             BOOTS_HTTP_EXTRA_HEADERS             a 'Key: Value' header added to iPXE script responses, e.g. 'Cache-Control: no-store', unless Boots sets it itself. Can be given more than once, or as one header per line. Content-Type and the headers describing the transfer can not be set.
+  -http-idle-timeout              BOOTS_HTTP_IDLE_TIMEOUT              how long the HTTP server keeps idle keep-alive connections open. 0 uses -http-read-header-timeout. (default "0s")
+  -http-log-fields                BOOTS_HTTP_LOG_FIELDS                comma separated list of the fields logged for HTTP requests besides the method and URI, from: remote-addr, user-agent, duration, status, bytes. The remote-addr is the X-Forwarded-For client when TRUSTED_PROXIES is set. (default "remote-addr,duration,status")
+  -http-log-format                BOOTS_HTTP_LOG_FORMAT                format of the HTTP access log. 'text' logs requests through the Boots logger, 'json' writes a JSON line per request to stdout. (default "text")
+  -http-rate-burst                BOOTS_HTTP_RATE_BURST                number of requests a client IP may make at once above -http-rate-limit. (default "20")
+  -http-rate-limit                BOOTS_HTTP_RATE_LIMIT                requests a second each client IP may make to the boot script, phone-home and boot failure endpoints, the rest get a 429. Off by default. (default "0")
+  -http-read-header-timeout       BOOTS_HTTP_READ_HEADER_TIMEOUT       how long the HTTP server waits for a request's headers, protecting against slowloris attacks. (default "20s")
+  -http-tls-cert                  BOOTS_HTTP_TLS_CERT                  PEM certificate file to serve HTTPS with on -http-addr, instead of HTTP. Requires -http-tls-key. Boot URLs given to machines use https.
+  -http-tls-key                   BOOTS_HTTP_TLS_KEY                   PEM private key file of -http-tls-cert.
+  -http-write-timeout             BOOTS_HTTP_WRITE_TIMEOUT             how long the HTTP server takes to write a response, including large iPXE or OSIE files, before closing the connection. 0 is no limit. (default "0s")
+  -installer-arches               BOOTS_INSTALLER_ARCHES               space separated installer=arch,arch entries restricting the client arches, from DHCP option 93 or the hardware record, an installer serves, e.g. 'default=x86_64,aarch64 installer:custom_ipxe=x86_64'. installer is default, installer:<name>, slug:<slug>, distro:<distro> or facility:<code>. Machines of other arches get the next matching installer serving their arch, or a 404 if there is none.
+  -installer-priority             BOOTS_INSTALLER_PRIORITY             the order the installer of a machine's auto.ipxe is selected in when several match it, comma separated kinds, highest priority first, of facility, installer, slug and distro. Kinds joined with '|' share a priority and are won by an installer restricted to the machine's arch in -installer-arches, e.g. 'facility,installer|slug,distro'. (default "facility,installer,slug,distro")
+  -inventory-log                  BOOTS_INVENTORY_LOG                  optional file that inventory reports are appended to as JSON lines, for operators to review.
+  -inventory-retry                BOOTS_INVENTORY_RETRY                how long machines whose inventory was stored for review wait before booting again, a whole number of seconds. (default "5m0s")
+  -ipxe-enable-http               BOOTS_IPXE_ENABLE_HTTP               enable serving iPXE binaries via HTTP. (default "true")
+  -ipxe-enable-tftp               BOOTS_IPXE_ENABLE_TFTP               enable serving iPXE binaries via TFTP. (default "true")
+  -ipxe-error-scripts             BOOTS_IPXE_ERROR_SCRIPTS             respond to iPXE script requests from machines without a hardware record, or whose record does not allow them to PXE, with a script that prints the reason on the console and exits instead of a 404. (default "false")
+  -ipxe-remote-http-addr          BOOTS_IPXE_REMOTE_HTTP_ADDR          remote IP and port where iPXE binaries are served via HTTP. Overrides -http-addr for iPXE binaries only.
+  -ipxe-remote-http-scheme        BOOTS_IPXE_REMOTE_HTTP_SCHEME        scheme of the remote HTTP server serving iPXE binaries (http, https). An http:// or https:// prefix on -ipxe-remote-http-addr takes precedence. (default "http")
+  -ipxe-remote-tftp-addr          BOOTS_IPXE_REMOTE_TFTP_ADDR          remote IP where iPXE binaries are served via TFTP. Overrides -tftp-addr.
+  -ipxe-report-failures           BOOTS_IPXE_REPORT_FAILURES           make OSIE/Hook iPXE scripts report failed kernel, initrd and boot commands to Boots before retrying. (default "false")
+  -ipxe-template-dir              BOOTS_IPXE_TEMPLATE_DIR              directory of iPXE script templates (Go text/template), each <facility>.ipxe.tmpl file is served to the hardware of that facility instead of its installer. Templates get .MAC, .Arch, .HardwareID, .Facility, .KernelArgs, .IPXEVars, .Tinkerbell and .SyslogHost. All templates are checked at startup.
+  -ipxe-tftp-addr                 BOOTS_IPXE_TFTP_ADDR                 local IP and port to listen on for serving iPXE binaries via TFTP (port must be 69). (default "0.0.0.0:69")
+  -ipxe-tftp-timeout              BOOTS_IPXE_TFTP_TIMEOUT              local iPXE TFTP server requests timeout. (default "5s")
+  -ipxe-vars                      BOOTS_IPXE_VARS                      additional variable definitions to include in all iPXE installer scripts. Separate multiple var definitions with spaces, e.g. 'var1=val1 var2=val2'.
+  -job-lookup-timeout             BOOTS_JOB_LOOKUP_TIMEOUT             how long a boot script or other job file request waits for the hardware backend to find the machine's record before getting a 504. 0 waits as long as the client does. (default "5s")
+  -kube-burst                     BOOTS_KUBE_BURST                     max burst of Kubernetes API requests over -kube-qps. 0 uses the client-go default of 10. Only applies if DATA_MODEL_VERSION=kubernetes. (default "0")
+  -kube-local-index               BOOTS_KUBE_LOCAL_INDEX               answer hardware lookups from a local index of Hardware kept current by an informer, /readiness reports not ready until it has synced and reports the index size and last event time. Only applies if DATA_MODEL_VERSION=kubernetes. (default "false")
+  -kube-namespace                 BOOTS_KUBE_NAMESPACE                 An optional Kubernetes namespace override to query hardware data from.
+  -kube-namespace-precedence      BOOTS_KUBE_NAMESPACE_PRECEDENCE      comma separated list of namespaces, most preferred first, whose hardware is used when a lookup matches hardware in several namespaces. Lookups still ambiguous are denied. Only applies if DATA_MODEL_VERSION=kubernetes.
+  -kube-namespaces                BOOTS_KUBE_NAMESPACES                comma separated list of namespaces to consider hardware in, all watched namespaces if empty. Only applies if DATA_MODEL_VERSION=kubernetes.
+  -kube-qps                       BOOTS_KUBE_QPS                       max number of Kubernetes API requests per second. 0 uses the client-go default of 5. Only applies if DATA_MODEL_VERSION=kubernetes. (default "0")
+  -kubeconfig                     BOOTS_KUBECONFIG                     The Kubernetes config file location. Only applies if DATA_MODEL_VERSION=kubernetes.
+  -kubeconfig-content             BOOTS_KUBECONFIG_CONTENT             The Kubernetes config itself, as YAML or base64 encoded YAML, instead of -kubeconfig, e.g. from a secret in BOOTS_KUBECONFIG_CONTENT. Only applies if DATA_MODEL_VERSION=kubernetes.
+  -kubernetes                     BOOTS_KUBERNETES                     The Kubernetes API URL, used for in-cluster client construction. Only applies if DATA_MODEL_VERSION=kubernetes.
+  -log-level                      BOOTS_LOG_LEVEL                      log level. (default "info")
+  -log-packets                    BOOTS_LOG_PACKETS                    log the options of every received DHCP packet and every iPXE script served, at debug level, for debugging a machine's boot. Off by default. (default "false")
+  -log-packets-rate               BOOTS_LOG_PACKETS_RATE               max number of -log-packets dumps logged per second per MAC, in bursts of up to 8. Dumps over the limit are dropped. (default "1")
+  -log-packets-redact             BOOTS_LOG_PACKETS_REDACT             comma separated DHCP option names or codes, iPXE script variables and kernel args whose values are replaced with REDACTED in -log-packets dumps. (default "registry_password,pwhash")
+  -maintenance                    BOOTS_MAINTENANCE                    start in maintenance mode: job files are refused with an iPXE script explaining Boots is in maintenance, or a 503, and DHCP replies have no boot options. Phone-homes are still handled. (default "false")
+  -maintenance-token              BOOTS_MAINTENANCE_TOKEN              enables toggling maintenance mode with a POST of enabled=true or enabled=false to /_packet/maintenance. Clients must present this token as a bearer token.
+  -max-request-body               BOOTS_MAX_REQUEST_BODY               max size in bytes of phone-home, /boot-failure and /_packet/simulate request bodies, larger bodies are refused with a 413. 0 does not limit them, except JSON phone-home status bodies which are always limited to 64 KiB. (default "16384")
+  -metrics-auth-token             BOOTS_METRICS_AUTH_TOKEN             require /metrics requests to present this token as a bearer token, others get a 401. Boot, iPXE and phone-home requests are never authenticated.
+  -no-netboot-response            BOOTS_NO_NETBOOT_RESPONSE            how to respond to HTTP requests from known hardware that has no netboot config. A 4xx status code, 'script' serves an iPXE script that prints the problem and exits, 'discovery' serves the discovery script. (default "404")
+  -osie-checksum-interval         BOOTS_OSIE_CHECKSUM_INTERVAL         how often an OSIE/Hook image is verified again against -osie-checksums. Failed images are verified again on the next boot. (default "1h0m0s")
+  -osie-checksums                 BOOTS_OSIE_CHECKSUMS                 sha256sum format manifest of OSIE/Hook image checksums, by file name relative to the OSIE URL, e.g. vmlinuz-x86_64. An image is downloaded and verified the first time a machine boots it, and boot scripts using an image that fails verification get a 404.
+  -osie-discover-kernel-args      BOOTS_OSIE_DISCOVER_KERNEL_ARGS      default kernel args (k=v k=v) of the OSIE installer when discovering, overridden like -osie-kernel-args.
+  -osie-facility-urls             BOOTS_OSIE_FACILITY_URLS             comma separated list of facility=URL OSIE/Hook base URLs, e.g. 'ewr1=http://mirror.ewr1.example.com/osie', that machines in the facility boot from instead of -osie-mirrors, -osie-path-override or their hardware record's OSIE base URL.
+  -osie-kernel-args               BOOTS_OSIE_KERNEL_ARGS               default kernel args (k=v k=v) of the OSIE installer when installing or rescuing. Args with the same key are overridden by -extra-kernel-args, which are overridden by the hardware record's kernel args.
+  -osie-mirror-selection          BOOTS_OSIE_MIRROR_SELECTION          how an -osie-mirrors mirror is picked for each boot. 'round-robin' is weighted round-robin, 'mac-hash' keeps each MAC on the same mirror. (default "round-robin")
+  -osie-mirrors                   BOOTS_OSIE_MIRRORS                   comma separated list of OSIE/Hook mirror URLs, each optionally followed by =weight, to spread boots across instead of -osie-path-override.
+  -osie-path-override             BOOTS_OSIE_PATH_OVERRIDE             A custom URL for OSIE/Hook images.
+  -phone-home-batch-size          BOOTS_PHONE_HOME_BATCH_SIZE          max number of phone-home events persisted together. (default "100")
+  -phone-home-batch-window        BOOTS_PHONE_HOME_BATCH_WINDOW        how long phone-home events are collected before being persisted together. 0 persists each event immediately. (default "0s")
+  -phone-home-client-cert         BOOTS_PHONE_HOME_CLIENT_CERT         require phone-home requests to present a client certificate, verified by -tls-client-ca, that maps to the requesting machine's hardware record via an IP SAN or a MAC common name. Requires -http-tls-cert and -http-tls-key. (default "false")
+  -phone-home-dedup-window        BOOTS_PHONE_HOME_DEDUP_WINDOW        how long after a phone-home is processed that phone-homes of the same type from the same MAC are not reprocessed. 0 processes every phone-home. (default "0s")
+  -phone-home-duplicate-response  BOOTS_PHONE_HOME_DUPLICATE_RESPONSE  response to a duplicate phone-home within -phone-home-dedup-window. 'ack' responds 200, 'reject' responds 429. (default "ack")
+  -phone-home-log                 BOOTS_PHONE_HOME_LOG                 optional file that phone-home events are appended to as JSON lines.
+  -phone-home-status-history      BOOTS_PHONE_HOME_STATUS_HISTORY      max number of install statuses kept per machine, the latest and the previous ones. (default "10")
+  -phone-home-status-ttl          BOOTS_PHONE_HOME_STATUS_TTL          how long the install status a machine reports in a JSON phone-home body ({"phase", "progress", "message"}) is served by /_packet/status/<mac> after its last report. (default "1h0m0s")
+  -phone-home-unknown-status      BOOTS_PHONE_HOME_UNKNOWN_STATUS      response code to a phone-home from an address no hardware record is found for, 200, 204 or 404. (default "200")
+  -phone-home-webhook             BOOTS_PHONE_HOME_WEBHOOK             optional http or https URL that a JSON notification with the MAC, IP, time and job ID is POSTed to for each processed phone-home. Delivery is in the background and does not affect the response to the machine.
+  -phone-home-webhook-retries     BOOTS_PHONE_HOME_WEBHOOK_RETRIES     number of times a phone-home webhook notification is retried after a network error or 5xx response, with backoff starting at 1s. (default "3")
+  -phone-home-webhook-timeout     BOOTS_PHONE_HOME_WEBHOOK_TIMEOUT     how long each delivery attempt of a phone-home webhook notification may take. (default "5s")
+  -pprof-addr                     BOOTS_PPROF_ADDR                     local IP and port to serve the pprof handlers on over HTTP, instead of -http-addr.
+  -pprof-auth-token               BOOTS_PPROF_AUTH_TOKEN               require pprof requests to present this token as a bearer token, others get a 401.
+  -print-config                   BOOTS_PRINT_CONFIG                   print the effective configuration, resolved from the flags, env and -config-file, as JSON and exit. Secrets are redacted. (default "false")
+  -problem-json                   BOOTS_PROBLEM_JSON                   describe failed job file, phone-home and simulate requests with an RFC 7807 application/problem+json body, only to clients whose Accept header lists application/problem+json or application/json, so iPXE clients keep getting plain responses. (default "false")
+  -readiness-timeout              BOOTS_READINESS_TIMEOUT              how long the readiness check at /readiness and -check wait for the hardware and workflow backends to respond before reporting not ready. (default "2s")
+  -retry-after-capacity           BOOTS_RETRY_AFTER_CAPACITY           number of in-flight HTTP requests at which the Retry-After of 429 and 503 responses reaches -retry-after-max. (default "100")
+  -retry-after-max                BOOTS_RETRY_AFTER_MAX                Retry-After of 429 and 503 responses once -retry-after-capacity HTTP requests are in flight. (default "1m0s")
+  -retry-after-min                BOOTS_RETRY_AFTER_MIN                Retry-After of 429 and 503 responses when Boots is idle. It grows with the number of in-flight HTTP requests and is jittered. (default "1s")
+  -reuse-port                     BOOTS_REUSE_PORT                     enable SO_REUSEPORT on the HTTP, DHCP, and TFTP listeners so a new instance can bind before the old one exits (Linux only). (default "false")
+  -shutdown-timeout               BOOTS_SHUTDOWN_TIMEOUT               how long in-flight DHCP, HTTP, TFTP and syslog requests are given to finish on shutdown. Should be less than the pod's termination grace period. (default "20s")
+  -simulate-token                 BOOTS_SIMULATE_TOKEN                 enables simulating the boot of a MAC from /_packet/simulate?mac=<mac>, responding with the boot script and kernel args it would get. Clients must present this token as a bearer token.
+  -static-network-kernel-args     BOOTS_STATIC_NETWORK_KERNEL_ARGS     pass the hardware's static network config to OSIE/Hook as dracut ip= kernel args instead of ip=dhcp. (default "false")
+  -status-token                   BOOTS_STATUS_TOKEN                   enables the machine status endpoints: the install status of a machine from /_packet/status/<mac>, the boot loops from /_packet/status/boot-loops and the activity stats from /_packet/stats. Clients must present this token as a bearer token.
+  -synthetic-latency              BOOTS_SYNTHETIC_LATENCY              how long every hardware and workflow lookup takes when DATA_MODEL_VERSION=synthetic. (default "0s")
+  -synthetic-not-found-percent    BOOTS_SYNTHETIC_NOT_FOUND_PERCENT    percentage of MACs, 0 to 100, that no hardware is found for when DATA_MODEL_VERSION=synthetic. The same MACs are always not found. (default "0")
+  -syslog-addr                    BOOTS_SYSLOG_ADDR                    IP and port to listen on for syslog messages. (default "%[1]v:514")
+  -syslog-forward-addr            BOOTS_SYSLOG_FORWARD_ADDR            optional host:port of a collector, e.g. rsyslog, that received syslog messages are relayed to unmodified. Prefix with tcp:// to forward over TCP with octet counted framing, UDP is the default.
+  -syslog-forward-buffer          BOOTS_SYSLOG_FORWARD_BUFFER          max number of syslog messages waiting to be forwarded, messages are dropped when it is full. (default "10000")
+  -syslog-stream-token            BOOTS_SYSLOG_STREAM_TOKEN            enables streaming syslog messages as server-sent events from /syslog/stream. Clients must present this token as a bearer token or the token query param.
+  -tcp-keepalive                  BOOTS_TCP_KEEPALIVE                  keep-alive period for accepted TCP connections. 0 uses the Go default, a negative value disables keep-alives. (default "0s")
+  -tftp-max-concurrent            BOOTS_TFTP_MAX_CONCURRENT            max number of TFTP transfers in progress, read requests beyond it wait up to -tftp-queue-timeout and are then rejected. 0 does not limit transfers. (default "0")
+  -tftp-queue-timeout             BOOTS_TFTP_QUEUE_TIMEOUT             how long a TFTP read request waits for a transfer to finish when -tftp-max-concurrent are in progress. -ipxe-tftp-timeout only applies once the transfer starts. (default "1s")
+  -tls-cipher-suites              BOOTS_TLS_CIPHER_SUITES              comma separated list of TLS 1.2 cipher suites allowed by the HTTPS server. Defaults to ECDHE AEAD cipher suites only.
+  -tls-client-ca                  BOOTS_TLS_CLIENT_CA                  PEM file of CAs used to verify client certificates presented to the HTTPS server.
+  -tls-min-version                BOOTS_TLS_MIN_VERSION                minimum TLS version accepted by the HTTPS server, one of: 1.2, 1.3. (default "1.2")
+  -user-agent-arch                BOOTS_USER_AGENT_ARCH                select installers for the arch named in the User-Agent of a boot script request, e.g. arm64-efi, if neither DHCP nor the hardware record has one. Only custom iPXE builds name their platform, stock iPXE sends iPXE/<version> and is never matched. (default "true")
+  -version                        BOOTS_VERSION                        print the git revision, build date and Go version and exit. (default "false")
+  -xff-strict                     BOOTS_XFF_STRICT                     reject, with a 400, HTTP requests with an X-Forwarded-For header from peers that are not in TRUSTED_PROXIES. Without it the header of those requests is ignored. Only applies when TRUSTED_PROXIES is set. (default "false")
 `, defaultIP)
 	c := &config{}
 	fs := flag.NewFlagSet(name, flag.ContinueOnError)
@@ -178,3 +549,29 @@ FLAGS
 		t.Fatal(diff)
 	}
 }
+
+func TestGetFindersSynthetic(t *testing.T) {
+	t.Setenv("DATA_MODEL_VERSION", "synthetic")
+	if _, _, err := getFinders(mainlog, &config{}); err == nil || !strings.Contains(err.Error(), "-allow-synthetic-backend") {
+		t.Fatalf("want the synthetic backend refused without -allow-synthetic-backend, got: %v", err)
+	}
+	wf, hf, err := getFinders(mainlog, &config{allowSyntheticBackend: true, syntheticNotFoundPercent: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf, ok := hf.(*standalone.SyntheticFinder)
+	if !ok || wf != client.WorkflowFinder(sf) {
+		t.Fatalf("want the synthetic finder for hardware and workflows, got %T and %T", hf, wf)
+	}
+	if _, _, err := getFinders(mainlog, &config{allowSyntheticBackend: true, syntheticNotFoundPercent: 101}); err == nil {
+		t.Fatal("want an error for a not found percent over 100")
+	}
+}
+
+func TestGetFindersKubeconfigExclusive(t *testing.T) {
+	t.Setenv("DATA_MODEL_VERSION", "kubernetes")
+	_, _, err := getFinders(mainlog, &config{kubeconfig: "/etc/kube/config", kubeconfigContent: "apiVersion: v1"})
+	if err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Fatalf("want -kubeconfig and -kubeconfig-content refused together, got: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// maintenancePath shows and toggles maintenance mode, see serveMaintenance.
+const maintenancePath = "/_packet/maintenance"
+
+// maintenanceReason is printed by the iPXE script served to machines booting in maintenance mode.
+const maintenanceReason = "Boot services are temporarily disabled for maintenance, try again later"
+
+// maintenance is whether Boots is in maintenance mode, refusing all boots: job files are
+// refused and DHCP replies have no boot options, while phone-homes are still handled so
+// installs in progress can finish.
+type maintenance struct {
+	// on is 1 in maintenance mode, accessed atomically
+	on int32
+}
+
+func newMaintenance(on bool) *maintenance {
+	m := &maintenance{}
+	m.set(on)
+
+	return m
+}
+
+// enabled reports whether Boots is in maintenance mode, false if m is nil.
+func (m *maintenance) enabled() bool {
+	return m != nil && atomic.LoadInt32(&m.on) == 1
+}
+
+// set enters or leaves maintenance mode, returns whether it changed.
+func (m *maintenance) set(on bool) bool {
+	var v int32
+	if on {
+		v = 1
+	}
+	metrics.Maintenance.Set(float64(v))
+
+	return atomic.SwapInt32(&m.on, v) != v
+}
+
+// serveMaintenance responds with whether Boots is in maintenance mode as JSON, a POST with
+// the enabled form value, e.g. enabled=true, enters or leaves it first. It is served behind
+// requireToken with the maintenance token.
+func (s *BootsHTTPServer) serveMaintenance(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		on, err := strconv.ParseBool(req.FormValue("enabled"))
+		if err != nil {
+			http.Error(w, "invalid enabled, must be true or false", http.StatusBadRequest)
+
+			return
+		}
+		if s.maintenance.set(on) {
+			mainlog.With("client", req.RemoteAddr, "maintenance", on).Info("maintenance mode changed")
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		Maintenance bool `json:"maintenance"`
+	}{s.maintenance.enabled()}); err != nil {
+		mainlog.Error(errors.Wrap(err, "marshaling maintenance json"))
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestServeMaintenance(t *testing.T) {
+	s := &BootsHTTPServer{maintenance: newMaintenance(false)}
+	tests := []struct {
+		name       string
+		method     string
+		enabled    string
+		wantStatus int
+		want       bool
+	}{
+		{name: "show", method: http.MethodGet, wantStatus: http.StatusOK},
+		{name: "enable", method: http.MethodPost, enabled: "true", wantStatus: http.StatusOK, want: true},
+		{name: "invalid", method: http.MethodPost, enabled: "maybe", wantStatus: http.StatusBadRequest, want: true},
+		{name: "method", method: http.MethodDelete, wantStatus: http.StatusMethodNotAllowed, want: true},
+		{name: "disable", method: http.MethodPost, enabled: "false", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, maintenancePath, strings.NewReader(url.Values{"enabled": {tt.enabled}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			s.serveMaintenance(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if s.maintenance.enabled() != tt.want {
+				t.Fatalf("want maintenance %t", tt.want)
+			}
+			if gauge := testutil.ToFloat64(metrics.Maintenance); (gauge == 1) != tt.want {
+				t.Fatalf("want maintenance gauge %t, got %v", tt.want, gauge)
+			}
+		})
+	}
+}
+
+func TestServeJobFileMaintenance(t *testing.T) {
+	// the lookup is never made, hungManager would block the request
+	h := &jobHandler{jobManager: hungManager{}, maintenance: newMaintenance(true), shedder: testShedder(t), problems: true}
+	defer h.maintenance.set(false)
+
+	w := httptest.NewRecorder()
+	h.serveJobFile(w, httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "echo "+maintenanceReason) {
+		t.Fatalf("want the maintenance script, got %d:\n%s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("want the maintenance script with Retry-After: 2, got %q", got)
+	}
+	for _, accept := range []string{"", "application/problem+json"} {
+		req := httptest.NewRequest(http.MethodGet, "/undionly.kpxe", nil)
+		req.Header.Set("Accept", accept)
+		w = httptest.NewRecorder()
+		h.serveJobFile(w, req)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("accept %q: want status %d, got %d", accept, http.StatusServiceUnavailable, w.Code)
+		}
+		if got := w.Header().Get("Retry-After"); got != "2" {
+			t.Fatalf("accept %q: want Retry-After: 2, got %q", accept, got)
+		}
+	}
+}
+
+// testShedder returns a loadShedder whose Retry-After is always 2s with no requests in flight.
+func testShedder(t *testing.T) *loadShedder {
+	t.Helper()
+	l, err := newLoadShedder(2*time.Second, time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.jitter = func() float64 { return 0 }
+
+	return l
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/packethost/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// The -no-netboot-response values that are not a status code.
+const (
+	// noNetbootScript serves an iPXE script that reports the problem on the console and exits.
+	noNetbootScript = "script"
+	// noNetbootDiscovery serves the discovery boot script.
+	noNetbootDiscovery = "discovery"
+)
+
+// validateNoNetbootResponse checks that v is a 4xx status code, "script" or "discovery".
+func validateNoNetbootResponse(v string) error {
+	if v == noNetbootScript || v == noNetbootDiscovery {
+		return nil
+	}
+	if code, err := strconv.Atoi(v); err == nil && code >= 400 && code < 500 {
+		return nil
+	}
+
+	return errors.Errorf("invalid -no-netboot-response %q, must be a 4xx status code, %s or %s", v, noNetbootScript, noNetbootDiscovery)
+}
+
+// serveNoNetbootConfig responds to a client whose hardware record was found but has
+// no netboot settings for its interface, according to -no-netboot-response.
+func (h *jobHandler) serveNoNetbootConfig(ctx context.Context, w http.ResponseWriter, req *http.Request, j *job.Job) {
+	logger := j.With("client", req.RemoteAddr, "response", h.noNetbootResponse)
+
+	switch h.noNetbootResponse {
+	case noNetbootScript:
+		metrics.NoNetbootConfig.With(prometheus.Labels{"response": noNetbootScript}).Inc()
+		logger.Info("hardware has no netboot config, serving error script")
+		serveErrorScript(w, logger, "Hardware "+j.HardwareID().String()+" has no netboot configuration for this interface")
+	case noNetbootDiscovery:
+		metrics.NoNetbootConfig.With(prometheus.Labels{"response": noNetbootDiscovery}).Inc()
+		logger.Info("hardware has no netboot config, serving discovery")
+		j.ServeFile(w, req.Clone(ctx), job.Installers{Default: h.installers.Load().ByDistro["discovery"]})
+	default:
+		metrics.NoNetbootConfig.With(prometheus.Labels{"response": "status"}).Inc()
+		logger.Info("hardware has no netboot config")
+		code, _ := strconv.Atoi(h.noNetbootResponse)
+		h.problems.fail(w, req, code, problemNoNetbootConfig, "Hardware "+j.HardwareID().String()+" has no netboot configuration for this interface", j.PrimaryNIC())
+	}
+}
+
+// serveErrorScript responds with an iPXE script that prints reason on the console and
+// exits, so the machine's operator can see why it was not booted.
+func serveErrorScript(w http.ResponseWriter, logger log.Logger, reason string) {
+	s := ipxe.NewScript()
+	s.Echo(reason)
+	s.Sleep(10)
+	s.AppendString("exit")
+	if _, err := w.Write(s.Bytes()); err != nil {
+		logger.Error(errors.Wrap(err, "unable to write error script"))
+	}
+}
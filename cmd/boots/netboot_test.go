@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/client/kubernetes"
+	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+	"github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateNoNetbootResponse(t *testing.T) {
+	tests := map[string]bool{
+		"404":       true,
+		"422":       true,
+		"script":    true,
+		"discovery": true,
+		"200":       false,
+		"500":       false,
+		"":          false,
+		"shell":     false,
+	}
+	for v, valid := range tests {
+		t.Run(v, func(t *testing.T) {
+			err := validateNoNetbootResponse(v)
+			if valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !valid && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+		})
+	}
+}
+
+// staticManager is a job.Manager whose lookups return j, or err if j is nil.
+type staticManager struct {
+	j   *job.Job
+	err error
+}
+
+func (m staticManager) CreateFromRemoteAddr(ctx context.Context, _ string) (context.Context, *job.Job, error) {
+	return ctx, m.j, m.err
+}
+
+func (m staticManager) CreateFromDHCP(ctx context.Context, _ net.HardwareAddr, _ net.IP, _ string) (context.Context, *job.Job, error) {
+	return ctx, m.j, m.err
+}
+
+func TestServeJobFileErrorScripts(t *testing.T) {
+	j := job.NewMock(t, "c3.small.x86", "ewr1").Job()
+	tests := map[string]struct {
+		manager      staticManager
+		errorScripts bool
+		path         string
+		wantStatus   int
+		wantEcho     string
+	}{
+		"no hardware":            {manager: staticManager{err: errors.New("no hardware")}, path: "/auto.ipxe", wantStatus: http.StatusNotFound},
+		"no hardware script":     {manager: staticManager{err: errors.New("no hardware")}, errorScripts: true, path: "/auto.ipxe", wantStatus: http.StatusOK, wantEcho: "echo No hardware record found for 192.0.2.10, boot not allowed"},
+		"no hardware not ipxe":   {manager: staticManager{err: errors.New("no hardware")}, errorScripts: true, path: "/favicon.ico", wantStatus: http.StatusNotFound},
+		"pxe not allowed":        {manager: staticManager{j: &j}, path: "/auto.ipxe", wantStatus: http.StatusNotFound},
+		"pxe not allowed script": {manager: staticManager{j: &j}, errorScripts: true, path: "/auto.ipxe", wantStatus: http.StatusOK, wantEcho: "is not allowed to netboot, allow_pxe is false"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &jobHandler{jobManager: tt.manager, errorScripts: tt.errorScripts}
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = "192.0.2.10:4321"
+			w := httptest.NewRecorder()
+			h.serveJobFile(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, w.Code)
+			}
+			body := w.Body.String()
+			if tt.wantEcho == "" {
+				if body != "" {
+					t.Fatalf("want empty body, got %q", body)
+				}
+
+				return
+			}
+			if !strings.Contains(body, tt.wantEcho) || !strings.HasSuffix(body, "exit\n") {
+				t.Fatalf("want script echoing %q and exiting, got:\n%s", tt.wantEcho, body)
+			}
+		})
+	}
+}
+
+func TestServeJobFileAllowPXEDefault(t *testing.T) {
+	// a record of the machine without netboot settings
+	noNetboot := job.NewCreator(mainlog, "", recordFinder{d: kubernetes.NewK8sDiscoverer(&v1alpha1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine"},
+		Spec: v1alpha1.HardwareSpec{Interfaces: []v1alpha1.Interface{{
+			DHCP: &v1alpha1.DHCP{MAC: "00:00:ba:dd:be:ef", Arch: "x86_64", IP: &v1alpha1.IP{Address: "192.0.2.10", Netmask: "255.255.255.0"}},
+		}}},
+	})})
+	noNetboot.SetAllowPXEDefault(true)
+	i := job.NewInstallers()
+	i.Default = func(_ context.Context, _ job.Job, s *ipxe.Script) { s.Echo("default installer") }
+	tests := map[string]struct {
+		manager      job.Manager
+		allowDefault bool
+		wantStatus   int
+	}{
+		"no record":              {manager: staticManager{err: errors.WithMessage(client.ErrNotFound, "discovering from ip address")}, allowDefault: true, wantStatus: http.StatusOK},
+		"no record not allowed":  {manager: staticManager{err: errors.WithMessage(client.ErrNotFound, "discovering from ip address")}, wantStatus: http.StatusNotFound},
+		"no netboot settings":    {manager: noNetboot, allowDefault: true, wantStatus: http.StatusOK},
+		"backend failing":        {manager: staticManager{err: &job.LookupError{Err: errors.New("connection refused")}}, allowDefault: true, wantStatus: http.StatusBadGateway},
+		"record denies the boot": {manager: staticManager{j: func() *job.Job { j := job.NewMock(t, "c3.small.x86", "ewr1").Job(); return &j }()}, allowDefault: true, wantStatus: http.StatusNotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &jobHandler{jobManager: tt.manager, installers: conf.NewReloadable(i), allowPXEDefault: tt.allowDefault}
+			req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+			req.RemoteAddr = "192.0.2.10:4321"
+			w := httptest.NewRecorder()
+			h.serveJobFile(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if booted := strings.Contains(w.Body.String(), "echo default installer"); booted != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("want the default installer %t, got:\n%s", tt.wantStatus == http.StatusOK, w.Body)
+			}
+		})
+	}
+}
+
+func TestServeJobFileNoNetbootResponse(t *testing.T) {
+	// a record of the machine without netboot settings
+	noNetboot := job.NewCreator(mainlog, "", recordFinder{d: kubernetes.NewK8sDiscoverer(&v1alpha1.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine"},
+		Spec: v1alpha1.HardwareSpec{Interfaces: []v1alpha1.Interface{{
+			DHCP: &v1alpha1.DHCP{MAC: "00:00:ba:dd:be:ef", Arch: "x86_64", IP: &v1alpha1.IP{Address: "192.0.2.10", Netmask: "255.255.255.0"}},
+		}}},
+	})})
+	i := job.NewInstallers()
+	i.Default = func(_ context.Context, _ job.Job, s *ipxe.Script) { s.Echo("default installer") }
+	i.ByDistro["discovery"] = func(_ context.Context, _ job.Job, s *ipxe.Script) { s.Echo("discovery installer") }
+	tests := map[string]struct {
+		response   string
+		wantStatus int
+		wantBody   string
+		wantLabel  string
+	}{
+		"status":       {response: "404", wantStatus: http.StatusNotFound, wantLabel: "status"},
+		"other status": {response: "422", wantStatus: http.StatusUnprocessableEntity, wantLabel: "status"},
+		"script":       {response: noNetbootScript, wantStatus: http.StatusOK, wantBody: "has no netboot configuration for this interface", wantLabel: noNetbootScript},
+		"discovery":    {response: noNetbootDiscovery, wantStatus: http.StatusOK, wantBody: "echo discovery installer", wantLabel: noNetbootDiscovery},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			counter := metrics.NoNetbootConfig.With(prometheus.Labels{"response": tt.wantLabel})
+			before := testutil.ToFloat64(counter)
+			h := &jobHandler{jobManager: noNetboot, installers: conf.NewReloadable(i), noNetbootResponse: tt.response}
+			req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+			req.RemoteAddr = "192.0.2.10:4321"
+			w := httptest.NewRecorder()
+			h.serveJobFile(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, w.Code)
+			}
+			body := w.Body.String()
+			if tt.wantBody == "" {
+				if body != "" {
+					t.Fatalf("want empty body, got %q", body)
+				}
+			} else if !strings.Contains(body, tt.wantBody) {
+				t.Fatalf("want body containing %q, got:\n%s", tt.wantBody, body)
+			}
+			if strings.Contains(body, "default installer") {
+				t.Fatalf("want the machine not booted into the default installer, got:\n%s", body)
+			}
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Fatalf("want no_netboot_config_total{response=%q} incremented once, got %v", tt.wantLabel, got)
+			}
+		})
+	}
+}
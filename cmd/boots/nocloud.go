@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// noCloudPrefix is the cloud-init NoCloud datasource seed URL, e.g. ds=nocloud-net;s=http://boots/nocloud/.
+const noCloudPrefix = "/nocloud/"
+
+// serveNoCloud serves the NoCloud meta-data, user-data and network-config
+// documents of the requesting machine. network-config is not found when the
+// hardware record has no static network config so cloud-init falls back to DHCP.
+func (s *BootsHTTPServer) serveNoCloud(w http.ResponseWriter, req *http.Request) {
+	labels := prometheus.Labels{"from": "http", "op": "nocloud"}
+	metrics.JobsTotal.With(labels).Inc()
+	metrics.JobsInProgress.With(labels).Inc()
+	defer metrics.JobsInProgress.With(labels).Dec()
+	timer := prometheus.NewTimer(metrics.JobDuration.With(labels))
+	defer timer.ObserveDuration()
+
+	var render func(job.Job) ([]byte, error)
+	switch strings.TrimPrefix(req.URL.Path, noCloudPrefix) {
+	case "meta-data":
+		render = job.Job.NoCloudMetaData
+	case "user-data":
+		render = func(j job.Job) ([]byte, error) { return []byte(j.UserData()), nil }
+	case "network-config":
+		render = job.Job.NetworkConfig
+	default:
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+
+	_, j, err := s.jobManager.CreateFromRemoteAddr(req.Context(), req.RemoteAddr)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		mainlog.With("client", req.RemoteAddr, "error", err).Info("no job found for client address")
+
+		return
+	}
+	b, err := render(*j)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		j.With("path", req.URL.Path).Error(err)
+
+		return
+	}
+	if b == nil {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write(b); err != nil {
+		j.With("path", req.URL.Path).Error(err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/dhcp"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// packetLogBurst is the number of dumps a MAC may log at once above its rate, enough for the
+// DHCP exchanges of a PXE and an iPXE boot and the boot script that follows.
+const packetLogBurst = 8
+
+// packetLogMaxScript is the max number of bytes of a boot script that are logged.
+const packetLogMaxScript = 64 << 10
+
+// packetLogger logs the options of received DHCP packets and the iPXE scripts served, at
+// debug level, see -log-packets. A nil packetLogger logs nothing.
+type packetLogger struct {
+	limiter *clientRateLimiter
+	// redact are the lowercase DHCP option names and codes, and script variables and kernel args, whose values are not logged
+	redact map[string]bool
+}
+
+// newPacketLogger returns a logger logging at most perSecond dumps a second per MAC, with
+// the values of the comma separated redact names replaced. Returns nil if enabled is false.
+func newPacketLogger(enabled bool, perSecond float64, redact string) (*packetLogger, error) {
+	if !enabled {
+		return nil, nil
+	}
+	if perSecond <= 0 {
+		return nil, errors.New("-log-packets-rate must be greater than 0")
+	}
+	limiter, err := newClientRateLimiter(perSecond, packetLogBurst)
+	if err != nil {
+		return nil, err
+	}
+	p := &packetLogger{limiter: limiter, redact: map[string]bool{}}
+	for _, name := range strings.Split(redact, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			p.redact[name] = true
+		}
+	}
+
+	return p, nil
+}
+
+// allow reports whether mac may log a dump of kind now, counting the dropped dumps.
+func (p *packetLogger) allow(mac net.HardwareAddr, kind string) bool {
+	if p.limiter.allow(mac.String()) {
+		return true
+	}
+	metrics.PacketLogsDropped.WithLabelValues(kind).Inc()
+
+	return false
+}
+
+// dhcp logs the options of req, received from mac.
+func (p *packetLogger) dhcp(req *dhcp4.Packet, mac net.HardwareAddr) {
+	if p == nil || !p.allow(mac, "dhcp") {
+		return
+	}
+	mainlog.With("mac", mac, "type", req.GetMessageType(), "giaddr", req.GetGIAddr(), "ciaddr", req.GetCIAddr(),
+		"options", p.options(req.OptionMap)).Debug("dhcp packet dump")
+}
+
+// options renders the options of om, see dhcp.TraceOptions, with the values of redacted options replaced.
+func (p *packetLogger) options(om dhcp4.OptionMap) []string {
+	opts := dhcp.TraceOptions(om)
+	rendered := make([]string, len(opts))
+	for i, o := range opts {
+		if p.redact[o.Name] || p.redact[strconv.Itoa(int(o.Code))] {
+			o.Value, o.Hex = "", redacted
+		}
+		rendered[i] = o.String()
+	}
+
+	return rendered
+}
+
+// script returns w, recording the boot script written to it for mac to be logged by the
+// returned function once it is served.
+func (p *packetLogger) script(w http.ResponseWriter, req *http.Request, mac net.HardwareAddr) (http.ResponseWriter, func()) {
+	if p == nil || !p.allow(mac, "ipxe") {
+		return w, func() {}
+	}
+	sw := &scriptRecorder{ResponseWriter: w, code: http.StatusOK}
+
+	return sw, func() {
+		mainlog.With("mac", mac, "client", req.RemoteAddr, "path", req.URL.Path, "status", sw.code,
+			"truncated", sw.truncated, "script", p.redactScript(sw.script.String())).Debug("ipxe script dump")
+	}
+}
+
+// redactScript replaces the values of the redacted key=value args and set commands in script.
+func (p *packetLogger) redactScript(script string) string {
+	if len(p.redact) == 0 {
+		return script
+	}
+	lines := strings.Split(script, "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 2 && fields[0] == "set" && p.redact[strings.ToLower(fields[1])] {
+			lines[i] = "set " + fields[1] + " " + redacted
+
+			continue
+		}
+		for j, f := range fields {
+			if key, _, ok := strings.Cut(f, "="); ok && p.redact[strings.ToLower(key)] {
+				fields[j] = key + "=" + redacted
+				lines[i] = strings.Join(fields, " ")
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// scriptRecorder records the status and the first packetLogMaxScript bytes written.
+type scriptRecorder struct {
+	http.ResponseWriter
+	code      int
+	script    bytes.Buffer
+	truncated bool
+}
+
+func (w *scriptRecorder) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *scriptRecorder) Write(b []byte) (int, error) {
+	if n := packetLogMaxScript - w.script.Len(); n < len(b) {
+		w.script.Write(b[:n])
+		w.truncated = true
+	} else {
+		w.script.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the underlying ResponseWriter if it supports it, see http.Flusher.
+func (w *scriptRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
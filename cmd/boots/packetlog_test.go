@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	dhcp4 "github.com/packethost/dhcp4-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestNewPacketLogger(t *testing.T) {
+	tests := map[string]struct {
+		enabled bool
+		rate    float64
+		redact  string
+		wantErr bool
+		want    map[string]bool
+	}{
+		"off":          {rate: 1},
+		"off bad rate": {},
+		"zero rate":    {enabled: true, wantErr: true},
+		"no redact":    {enabled: true, rate: 1, want: map[string]bool{}},
+		"redact":       {enabled: true, rate: 1, redact: " Hostname, 61,,pwhash", want: map[string]bool{"hostname": true, "61": true, "pwhash": true}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := newPacketLogger(tt.enabled, tt.rate, tt.redact)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if p != nil {
+					t.Fatal("expected a nil packet logger")
+				}
+
+				return
+			}
+			if diff := cmp.Diff(tt.want, p.redact); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestPacketLoggerOptions(t *testing.T) {
+	p, err := newPacketLogger(true, 1, "hostname,61")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := dhcp4.NewPacket(dhcp4.BootRequest)
+	req.SetMessageType(dhcp4.MessageTypeDiscover)
+	req.SetString(dhcp4.OptionHostname, "secret-host")
+	req.SetOption(dhcp4.OptionClientID, []byte{0x01, 0x00, 0x00, 0xba, 0xdd, 0xbe, 0xef})
+	req.SetString(dhcp4.OptionClassID, "PXEClient")
+
+	want := []string{
+		"hostname(12)=[REDACTED]",
+		"message-type(53)=DHCPDISCOVER [01]",
+		"vendor-class-identifier(60)=\"PXEClient\" [505845436c69656e74]",
+		"client-identifier(61)=[REDACTED]",
+	}
+	if diff := cmp.Diff(want, p.options(req.OptionMap)); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestPacketLoggerRedactScript(t *testing.T) {
+	p, err := newPacketLogger(true, 1, "registry_password,pwhash,Token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := strings.Join([]string{
+		"#!ipxe",
+		"set token s3cret",
+		"set base-url http://192.0.2.1",
+		"kernel ${base-url}/vmlinuz console=ttyS0 registry_password=hunter2 pwhash=$6$abc",
+		"boot",
+	}, "\n")
+	want := strings.Join([]string{
+		"#!ipxe",
+		"set token REDACTED",
+		"set base-url http://192.0.2.1",
+		"kernel ${base-url}/vmlinuz console=ttyS0 registry_password=REDACTED pwhash=REDACTED",
+		"boot",
+	}, "\n")
+	if diff := cmp.Diff(want, p.redactScript(script)); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestPacketLoggerRateLimit(t *testing.T) {
+	p, err := newPacketLogger(true, 0.001, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac, _ := net.ParseMAC("00:00:ba:dd:be:ef")
+	other, _ := net.ParseMAC("00:00:ba:dd:be:e0")
+	dropped := testutil.ToFloat64(metrics.PacketLogsDropped.WithLabelValues("ipxe"))
+	for i := 0; i < packetLogBurst; i++ {
+		if !p.allow(mac, "ipxe") {
+			t.Fatalf("dump %d within the burst was dropped", i)
+		}
+	}
+	if p.allow(mac, "ipxe") {
+		t.Fatal("want the dump over the burst dropped")
+	}
+	if !p.allow(other, "ipxe") {
+		t.Fatal("want another MAC's dump logged")
+	}
+	if n := testutil.ToFloat64(metrics.PacketLogsDropped.WithLabelValues("ipxe")) - dropped; n != 1 {
+		t.Fatalf("want 1 dropped dump counted, got %v", n)
+	}
+}
+
+func TestPacketLoggerScript(t *testing.T) {
+	mac, _ := net.ParseMAC("00:00:ba:dd:be:ef")
+	req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+
+	var nilLogger *packetLogger
+	w := httptest.NewRecorder()
+	if got, _ := nilLogger.script(w, req, mac); got != w {
+		t.Fatal("want a nil packet logger to return the writer as is")
+	}
+
+	p, err := newPacketLogger(true, 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, done := p.script(w, req, mac)
+	sw, ok := got.(*scriptRecorder)
+	if !ok {
+		t.Fatalf("want a *scriptRecorder, got %T", got)
+	}
+	body := "#!ipxe\n" + strings.Repeat("echo hello\n", packetLogMaxScript/8)
+	if _, err := sw.Write([]byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	done()
+	if w.Body.String() != body {
+		t.Fatal("want the whole script written to the client")
+	}
+	if !sw.truncated || sw.script.Len() != packetLogMaxScript || sw.code != http.StatusOK {
+		t.Fatalf("want %d bytes of the script recorded and truncated, got %d bytes, truncated %v, status %d", packetLogMaxScript, sw.script.Len(), sw.truncated, sw.code)
+	}
+}
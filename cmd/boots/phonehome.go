@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// phoneHomeEvent is a phone-home request as persisted.
+type phoneHomeEvent struct {
+	Time       time.Time `json:"time"`
+	Client     string    `json:"client"`
+	MAC        string    `json:"mac,omitempty"`
+	HardwareID string    `json:"hardware_id,omitempty"`
+	Type       string    `json:"type,omitempty"`
+	Body       string    `json:"body,omitempty"`
+}
+
+// phoneHomeSink durably writes phone-home events.
+type phoneHomeSink interface {
+	write([]phoneHomeEvent) error
+}
+
+// jsonLinesSink appends phone-home events to w as JSON lines, one write per batch.
+type jsonLinesSink struct {
+	w io.Writer
+}
+
+func (s jsonLinesSink) write(events []phoneHomeEvent) error {
+	var b []byte
+	for _, e := range events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return errors.Wrap(err, "marshal phone-home event")
+		}
+		b = append(append(b, line...), '\n')
+	}
+	_, err := s.w.Write(b)
+
+	return errors.Wrap(err, "write phone-home events")
+}
+
+// phoneHomeBatcher collects phone-home events and writes them to the sink
+// together, at most window after the first event of a batch was added or as
+// soon as the batch reaches maxSize. A zero window writes every event immediately.
+type phoneHomeBatcher struct {
+	sink    phoneHomeSink
+	window  time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	pending []phoneHomeEvent
+	// full is signaled when pending reaches maxSize
+	full chan struct{}
+	// started is signaled when the first event of a batch is added
+	started chan struct{}
+}
+
+func newPhoneHomeBatcher(sink phoneHomeSink, window time.Duration, maxSize int) *phoneHomeBatcher {
+	if maxSize < 1 {
+		maxSize = 1
+	}
+
+	return &phoneHomeBatcher{
+		sink:    sink,
+		window:  window,
+		maxSize: maxSize,
+		full:    make(chan struct{}, 1),
+		started: make(chan struct{}, 1),
+	}
+}
+
+// add queues e for writing, it does not wait for the write.
+func (b *phoneHomeBatcher) add(e phoneHomeEvent) {
+	b.mu.Lock()
+	b.pending = append(b.pending, e)
+	n := len(b.pending)
+	b.mu.Unlock()
+
+	signal := b.started
+	if n >= b.maxSize || b.window <= 0 {
+		signal = b.full
+	} else if n != 1 {
+		return
+	}
+	select {
+	case signal <- struct{}{}:
+	default:
+	}
+}
+
+// run writes batches until ctx is done, then writes any pending events.
+func (b *phoneHomeBatcher) run(ctx context.Context) error {
+	timer := time.NewTimer(b.window)
+	stopTimer(timer)
+	for {
+		select {
+		case <-ctx.Done():
+			stopTimer(timer)
+			b.flush()
+
+			return nil
+		case <-b.started:
+			stopTimer(timer)
+			timer.Reset(b.window)
+		case <-timer.C:
+			b.flush()
+		case <-b.full:
+			stopTimer(timer)
+			b.flush()
+		}
+	}
+}
+
+// stopTimer stops t and drains its channel so it can be safely reset.
+func stopTimer(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
+
+func (b *phoneHomeBatcher) flush() {
+	b.mu.Lock()
+	events := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+	if len(events) == 0 {
+		return
+	}
+
+	start := time.Now()
+	if err := b.sink.write(events); err != nil {
+		mainlog.With("events", len(events)).Error(err, "persisting phone-home events")
+	}
+	metrics.PhoneHomeBatchSize.Observe(float64(len(events)))
+	metrics.PhoneHomeFlushDuration.Observe(time.Since(start).Seconds())
+}
+
+// validatePhoneHomeUnknownStatus checks that code, the response to a phone-home from an
+// address no job is found for, is 200, 204 or 404.
+func validatePhoneHomeUnknownStatus(code int) error {
+	switch code {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return errors.Errorf("invalid -phone-home-unknown-status %d, must be 200, 204 or 404", code)
+	}
+}
+
+// The responses to a duplicate phone-home.
+const (
+	// phoneHomeDuplicateAck responds 200 without processing the phone-home.
+	phoneHomeDuplicateAck = "ack"
+	// phoneHomeDuplicateReject responds 429 so the client can tell it was not processed.
+	phoneHomeDuplicateReject = "reject"
+)
+
+// phoneHomeDeduper detects phone-homes of the same type from a MAC within
+// window of the last one that was processed.
+type phoneHomeDeduper struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu sync.Mutex
+	// processed is when each mac and type was last processed
+	processed map[string]time.Time
+	lastSweep time.Time
+}
+
+// newPhoneHomeDeduper returns a deduper for window, nil if window is not positive.
+func newPhoneHomeDeduper(window time.Duration) *phoneHomeDeduper {
+	if window <= 0 {
+		return nil
+	}
+
+	return &phoneHomeDeduper{window: window, now: time.Now, processed: map[string]time.Time{}}
+}
+
+// begin reports whether a phone-home of typ from mac is a duplicate of one processed,
+// or being processed, within the window. Otherwise the phone-home is recorded as
+// processed now, in the same critical section so concurrent retries can not both be
+// processed, and done must be called with whether it was. A phone-home that failed to
+// be processed is forgotten, so it is not suppressed when the client retries it.
+func (d *phoneHomeDeduper) begin(mac, typ string) (done func(processed bool), duplicate bool) {
+	if d == nil {
+		return func(bool) {}, false
+	}
+	key := mac + " " + typ
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if t, ok := d.processed[key]; ok && now.Sub(t) < d.window {
+		return func(bool) {}, true
+	}
+	if now.Sub(d.lastSweep) >= d.window {
+		for k, t := range d.processed {
+			if now.Sub(t) >= d.window {
+				delete(d.processed, k)
+			}
+		}
+		d.lastSweep = now
+	}
+	d.processed[key] = now
+
+	return func(processed bool) {
+		if processed {
+			return
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		// a later phone-home may have been processed since
+		if d.processed[key].Equal(now) {
+			delete(d.processed, key)
+		}
+	}, false
+}
+
+// phoneHomeRecorder records the status of a phone-home response, see phoneHomeDeduper.begin.
+type phoneHomeRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *phoneHomeRecorder) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *phoneHomeRecorder) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(b)
+}
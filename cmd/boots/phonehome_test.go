@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+type testSink struct {
+	mu      sync.Mutex
+	batches [][]phoneHomeEvent
+	written chan struct{}
+}
+
+func (s *testSink) write(events []phoneHomeEvent) error {
+	s.mu.Lock()
+	s.batches = append(s.batches, events)
+	s.mu.Unlock()
+	s.written <- struct{}{}
+
+	return nil
+}
+
+func (s *testSink) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sizes := make([]int, 0, len(s.batches))
+	for _, b := range s.batches {
+		sizes = append(sizes, len(b))
+	}
+
+	return sizes
+}
+
+func waitWritten(t *testing.T, s *testSink) {
+	t.Helper()
+	select {
+	case <-s.written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for batch to be written")
+	}
+}
+
+func TestPhoneHomeBatcher(t *testing.T) {
+	tests := map[string]struct {
+		window  time.Duration
+		maxSize int
+		events  int
+		want    []int
+	}{
+		"no window":    {window: 0, maxSize: 100, events: 1, want: []int{1}},
+		"window":       {window: 50 * time.Millisecond, maxSize: 100, events: 3, want: []int{3}},
+		"max size":     {window: time.Hour, maxSize: 2, events: 2, want: []int{2}},
+		"zero maxSize": {window: time.Hour, maxSize: 0, events: 1, want: []int{1}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sink := &testSink{written: make(chan struct{}, 10)}
+			b := newPhoneHomeBatcher(sink, tt.window, tt.maxSize)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go b.run(ctx) //nolint:errcheck // always nil
+
+			for i := 0; i < tt.events; i++ {
+				b.add(phoneHomeEvent{Client: "192.168.1.2:1234"})
+			}
+			for range tt.want {
+				waitWritten(t, sink)
+			}
+			if got := sink.batchSizes(); len(got) != len(tt.want) || got[0] != tt.want[0] {
+				t.Fatalf("want batch sizes %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPhoneHomeBatcherFlushOnShutdown(t *testing.T) {
+	sink := &testSink{written: make(chan struct{}, 10)}
+	b := newPhoneHomeBatcher(sink, time.Hour, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- b.run(ctx) }()
+
+	b.add(phoneHomeEvent{Client: "192.168.1.2:1234"})
+	b.add(phoneHomeEvent{Client: "192.168.1.3:1234"})
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.batchSizes(); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("want one batch of 2 written on shutdown, got %v", got)
+	}
+}
+
+func TestPhoneHomeDeduper(t *testing.T) {
+	if d := newPhoneHomeDeduper(0); d != nil {
+		t.Fatal("a zero window should not dedup")
+	}
+	if _, dup := (*phoneHomeDeduper)(nil).begin("00:00:ba:dd:be:ef", "provisioning.104.01"); dup {
+		t.Fatal("a zero window should not dedup")
+	}
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := newPhoneHomeDeduper(time.Minute)
+	d.now = func() time.Time { return now }
+
+	steps := []struct {
+		after time.Duration
+		mac   string
+		typ   string
+		// failed phone-homes are not marked processed
+		failed bool
+		want   bool
+	}{
+		{mac: "00:00:ba:dd:be:ef", typ: "provisioning.104.01", want: false},
+		{after: time.Second, mac: "00:00:ba:dd:be:ef", typ: "provisioning.104.01", want: true},
+		{mac: "00:00:ba:dd:be:ef", typ: "provisioning.109", want: false},
+		{mac: "00:00:ba:dd:be:e0", typ: "provisioning.104.01", want: false},
+		{after: 30 * time.Second, mac: "00:00:ba:dd:be:ef", typ: "provisioning.104.01", want: true},
+		// duplicates do not extend the window
+		{after: 29 * time.Second, mac: "00:00:ba:dd:be:ef", typ: "provisioning.104.01", want: false},
+		{after: time.Second, mac: "00:00:ba:dd:be:ef", typ: "provisioning.104.01", want: true},
+		// a retry of a failed phone-home is processed
+		{mac: "00:00:ba:dd:be:e2", typ: "provisioning.104.01", failed: true, want: false},
+		{after: time.Second, mac: "00:00:ba:dd:be:e2", typ: "provisioning.104.01", want: false},
+		{after: time.Second, mac: "00:00:ba:dd:be:e2", typ: "provisioning.104.01", want: true},
+	}
+	for i, s := range steps {
+		now = now.Add(s.after)
+		done, got := d.begin(s.mac, s.typ)
+		if got != s.want {
+			t.Fatalf("step %d: want duplicate %v, got %v", i, s.want, got)
+		}
+		done(!s.failed)
+	}
+	// a phone-home being processed is a duplicate of its retry
+	done, _ := d.begin("00:00:ba:dd:be:e3", "provisioning.104.01")
+	if _, dup := d.begin("00:00:ba:dd:be:e3", "provisioning.104.01"); !dup {
+		t.Fatal("want a retry of a phone-home being processed suppressed")
+	}
+	done(true)
+	// expired entries are swept
+	now = now.Add(2 * time.Minute)
+	d.begin("00:00:ba:dd:be:e1", "")
+	if len(d.processed) != 1 {
+		t.Fatalf("want expired entries swept, got %v", d.processed)
+	}
+}
+
+func TestServePhoneHomeDedupRetry(t *testing.T) {
+	j := job.NewMock(t, "c3.small.x86", "ewr1").Job()
+	s := &BootsHTTPServer{phoneHomeDedup: newPhoneHomeDeduper(time.Minute), phoneHomeDuplicate: phoneHomeDuplicateReject}
+	steps := []struct {
+		manager    staticManager
+		wantStatus int
+		wantDup    float64
+	}{
+		{manager: staticManager{err: &job.LookupError{Err: errors.New("connection refused")}}, wantStatus: http.StatusBadGateway},
+		// the retry once the backend is back is processed
+		{manager: staticManager{j: &j}, wantStatus: http.StatusOK},
+		{manager: staticManager{j: &j}, wantStatus: http.StatusTooManyRequests, wantDup: 1},
+	}
+	for i, step := range steps {
+		s.jobManager = step.manager
+		dups := testutil.ToFloat64(metrics.PhoneHomeDuplicates)
+		req := httptest.NewRequest(http.MethodPost, "/phone-home", strings.NewReader("type=provisioning.104.01"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.servePhoneHome(w, req)
+		if w.Code != step.wantStatus {
+			t.Fatalf("step %d: want status %d, got %d", i, step.wantStatus, w.Code)
+		}
+		if n := testutil.ToFloat64(metrics.PhoneHomeDuplicates) - dups; n != step.wantDup {
+			t.Fatalf("step %d: want %v duplicates counted, got %v", i, step.wantDup, n)
+		}
+	}
+}
+
+func TestServePhoneHomeUnknown(t *testing.T) {
+	notFound := errors.WithMessage(client.ErrNotFound, "discovering from ip address")
+	tests := map[string]struct {
+		status      int
+		err         error
+		wantStatus  int
+		wantUnknown float64
+	}{
+		"default":         {err: notFound, wantStatus: http.StatusOK, wantUnknown: 1},
+		"ok":              {status: http.StatusOK, err: notFound, wantStatus: http.StatusOK, wantUnknown: 1},
+		"no content":      {status: http.StatusNoContent, err: notFound, wantStatus: http.StatusNoContent, wantUnknown: 1},
+		"not found":       {status: http.StatusNotFound, err: notFound, wantStatus: http.StatusNotFound, wantUnknown: 1},
+		"backend failing": {status: http.StatusNotFound, err: &job.LookupError{Err: errors.New("connection refused")}, wantStatus: http.StatusBadGateway},
+		"breaker open":    {err: &job.LookupError{Err: client.ErrBreakerOpen}, wantStatus: http.StatusServiceUnavailable},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := &BootsHTTPServer{jobManager: staticManager{err: tt.err}, phoneHomeUnknownStatus: tt.status}
+			unknown := testutil.ToFloat64(metrics.PhoneHomeUnknown)
+			req := httptest.NewRequest(http.MethodPost, "/phone-home", strings.NewReader("type=provisioning.104.01"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			s.servePhoneHome(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if n := testutil.ToFloat64(metrics.PhoneHomeUnknown) - unknown; n != tt.wantUnknown {
+				t.Fatalf("want %v unknown phone-homes counted, got %v", tt.wantUnknown, n)
+			}
+		})
+	}
+}
+
+func TestValidatePhoneHomeUnknownStatus(t *testing.T) {
+	for code, wantErr := range map[int]bool{200: false, 204: false, 404: false, 0: true, 403: true, 500: true} {
+		if err := validatePhoneHomeUnknownStatus(code); (err != nil) != wantErr {
+			t.Fatalf("status %d: want error %t, got: %v", code, wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/pkg/errors"
+)
+
+// pprofPrefix is the path the pprof handlers are served under.
+const pprofPrefix = "/_packet/pprof/"
+
+// handlePprof registers the pprof handlers on mux, requiring the pprof auth token.
+func (s *BootsHTTPServer) handlePprof(mux *http.ServeMux) {
+	mux.Handle(pprofPrefix, requireToken(s.pprofAuthToken, http.HandlerFunc(pprof.Index)))
+	mux.Handle(pprofPrefix+"cmdline", requireToken(s.pprofAuthToken, http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle(pprofPrefix+"profile", requireToken(s.pprofAuthToken, http.HandlerFunc(pprof.Profile)))
+	mux.Handle(pprofPrefix+"symbol", requireToken(s.pprofAuthToken, http.HandlerFunc(pprof.Symbol)))
+	mux.Handle(pprofPrefix+"trace", requireToken(s.pprofAuthToken, http.HandlerFunc(pprof.Trace)))
+}
+
+// registerPprof registers the pprof handlers on the main mux. When pprof is disabled
+// or served on its own address the pprof paths respond 404 instead of falling
+// through to the job file handler.
+func (s *BootsHTTPServer) registerPprof(mux *http.ServeMux) {
+	if !s.pprofEnabled || s.pprofAddr != "" {
+		mux.Handle(pprofPrefix, http.NotFoundHandler())
+
+		return
+	}
+	s.handlePprof(mux)
+}
+
+// servePprof serves only the pprof handlers on pprofAddr, over plain HTTP, until the
+// server is shut down.
+func (s *BootsHTTPServer) servePprof() {
+	mux := http.NewServeMux()
+	s.handlePprof(mux)
+	server := &http.Server{
+		Addr:              s.pprofAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: s.readHeaderTimeout,
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+
+		return
+	}
+	s.pprofServer = server
+	s.mu.Unlock()
+
+	ln, err := s.listenConfig.Listen(context.Background(), "tcp", s.pprofAddr)
+	if err != nil {
+		mainlog.Fatal(errors.Wrap(err, "listen pprof"))
+	}
+	mainlog.With("addr", s.pprofAddr).Info("serving pprof")
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		mainlog.Fatal(errors.Wrap(err, "serve pprof"))
+	}
+}
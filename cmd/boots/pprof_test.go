@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegisterPprof(t *testing.T) {
+	tests := map[string]struct {
+		enabled bool
+		addr    string
+		token   string
+		auth    string
+		want    int
+	}{
+		"enabled":               {enabled: true, want: http.StatusOK},
+		"disabled":              {want: http.StatusNotFound},
+		"own address":           {enabled: true, addr: "127.0.0.1:6060", want: http.StatusNotFound},
+		"disabled with address": {addr: "127.0.0.1:6060", want: http.StatusNotFound},
+		"token":                 {enabled: true, token: "secret", auth: "Bearer secret", want: http.StatusOK},
+		"no token":              {enabled: true, token: "secret", want: http.StatusUnauthorized},
+		"wrong token":           {enabled: true, token: "secret", auth: "Bearer wrong", want: http.StatusUnauthorized},
+		"token not bearer":      {enabled: true, token: "secret", auth: "secret", want: http.StatusUnauthorized},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := &BootsHTTPServer{pprofEnabled: tt.enabled, pprofAddr: tt.addr, pprofAuthToken: tt.token}
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+				t.Fatal("want pprof paths not served by the job file handler")
+			})
+			s.registerPprof(mux)
+			for _, p := range []string{pprofPrefix, pprofPrefix + "cmdline", pprofPrefix + "heap"} {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, p, nil)
+				if tt.auth != "" {
+					req.Header.Set("Authorization", tt.auth)
+				}
+				mux.ServeHTTP(w, req)
+				if w.Code != tt.want {
+					t.Fatalf("%s: want status %d, got %d", p, tt.want, w.Code)
+				}
+			}
+		})
+	}
+}
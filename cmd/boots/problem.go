@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/job"
+)
+
+// problemTypePrefix prefixes the type of each problem, e.g. urn:tinkerbell:boots:no-hardware.
+const problemTypePrefix = "urn:tinkerbell:boots:"
+
+// The types of the failures described with problem details.
+const (
+	problemMaintenance     = "maintenance"
+	problemDraining        = "draining"
+	problemLookupTimeout   = "lookup-timeout"
+	problemNoHardware      = "no-hardware"
+	problemBackendError    = "backend-error"
+	problemNoNetbootConfig = "no-netboot-config"
+	problemPXENotAllowed   = "pxe-not-allowed"
+	problemInvalidStatus   = "invalid-status"
+	problemInvalidRequest  = "invalid-request"
+	problemNoBootScript    = "no-boot-script"
+)
+
+// problemTitles are the titles of the problem types, which do not change between occurrences.
+var problemTitles = map[string]string{
+	problemMaintenance:     "Boot services are in maintenance mode",
+	problemDraining:        "Boots is draining",
+	problemLookupTimeout:   "Hardware lookup timed out",
+	problemNoHardware:      "No hardware found",
+	problemBackendError:    "Hardware backend failed",
+	problemNoNetbootConfig: "Hardware has no netboot configuration",
+	problemPXENotAllowed:   "Hardware is not allowed to netboot",
+	problemInvalidStatus:   "Invalid install status",
+	problemInvalidRequest:  "Invalid request",
+	problemNoBootScript:    "No boot script",
+}
+
+// problem is an RFC 7807 problem details object describing why a request failed, with
+// the MAC of the machine as an extension member once it is known.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	MAC      string `json:"mac,omitempty"`
+}
+
+// problemJSON describes failed requests with application/problem+json bodies when
+// enabled, to requests that accept them, see -problem-json.
+type problemJSON bool
+
+// accepted reports whether p is enabled and the Accept header of req lists
+// application/problem+json or application/json. Wildcards are not enough, so iPXE
+// clients and browsers keep getting plain responses.
+func (p problemJSON) accepted(req *http.Request) bool {
+	if !p {
+		return false
+	}
+	accepted := false
+	forEachAccepted(req, func(mediaType string, q float64) {
+		if q > 0 && (mediaType == "application/problem+json" || mediaType == "application/json") {
+			accepted = true
+		}
+	})
+
+	return accepted
+}
+
+// forEachAccepted calls f with each media type listed in the Accept headers of req
+// and its q-value, 1 unless given. Invalid media types and q-values are skipped.
+func forEachAccepted(req *http.Request, f func(mediaType string, q float64)) {
+	for _, accept := range req.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					continue
+				}
+			}
+			f(mediaType, q)
+		}
+	}
+}
+
+// lookupProblem returns the response code and problem type of a failed hardware
+// lookup: 503 while the backend circuit breaker is open, 502 for other backend
+// failures and 404 when no hardware is found for the machine.
+func lookupProblem(err error) (int, string) {
+	switch {
+	case errors.Is(err, client.ErrBreakerOpen):
+		return http.StatusServiceUnavailable, problemBackendError
+	case job.IsLookupError(err):
+		return http.StatusBadGateway, problemBackendError
+	}
+
+	return http.StatusNotFound, problemNoHardware
+}
+
+// fail responds with code, with a body describing the problem of type typ with detail
+// and mac, which may be nil, only if p is accepted by req.
+func (p problemJSON) fail(w http.ResponseWriter, req *http.Request, code int, typ, detail string, mac net.HardwareAddr) {
+	if !p.accepted(req) {
+		w.WriteHeader(code)
+
+		return
+	}
+	pr := problem{
+		Type:     problemTypePrefix + typ,
+		Title:    problemTitles[typ],
+		Status:   code,
+		Detail:   detail,
+		Instance: req.URL.RequestURI(),
+	}
+	if len(mac) > 0 {
+		pr.MAC = mac.String()
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	varyAccept(w.Header())
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(pr); err != nil {
+		mainlog.Error(errors.Wrap(err, "marshaling problem json"))
+	}
+}
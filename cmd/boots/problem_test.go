@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/job"
+)
+
+func TestProblemJSONAccepted(t *testing.T) {
+	tests := map[string]struct {
+		disabled bool
+		accept   []string
+		want     bool
+	}{
+		"no accept":       {},
+		"problem json":    {accept: []string{"application/problem+json"}, want: true},
+		"json":            {accept: []string{"text/plain, application/json;q=0.5"}, want: true},
+		"several headers": {accept: []string{"text/plain", "application/problem+json"}, want: true},
+		"wildcard":        {accept: []string{"*/*"}},
+		"refused":         {accept: []string{"application/json;q=0"}},
+		"invalid q":       {accept: []string{"application/json;q=x"}},
+		"disabled":        {disabled: true, accept: []string{"application/problem+json"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+			for _, a := range tt.accept {
+				req.Header.Add("Accept", a)
+			}
+			if got := problemJSON(!tt.disabled).accepted(req); got != tt.want {
+				t.Fatalf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestServeJobFileProblemJSON(t *testing.T) {
+	j := job.NewMock(t, "c3.small.x86", "ewr1").Job()
+	tests := map[string]struct {
+		manager    staticManager
+		accept     string
+		wantStatus int
+		want       *problem
+	}{
+		"no hardware": {manager: staticManager{err: errors.WithMessage(client.ErrNotFound, "discover from dhcp message")}, accept: "application/problem+json", wantStatus: http.StatusNotFound, want: &problem{
+			Type:     "urn:tinkerbell:boots:no-hardware",
+			Title:    "No hardware found",
+			Status:   http.StatusNotFound,
+			Detail:   "No hardware record found for 192.0.2.10",
+			Instance: "/auto.ipxe",
+		}},
+		"pxe not allowed": {manager: staticManager{j: &j}, accept: "application/problem+json", wantStatus: http.StatusNotFound, want: &problem{
+			Type:     "urn:tinkerbell:boots:pxe-not-allowed",
+			Title:    "Hardware is not allowed to netboot",
+			Status:   http.StatusNotFound,
+			Detail:   "Hardware " + j.HardwareID().String() + " with MAC " + j.PrimaryNIC().String() + " is not allowed to netboot, allow_pxe is false",
+			Instance: "/auto.ipxe",
+			MAC:      j.PrimaryNIC().String(),
+		}},
+		"backend error": {manager: staticManager{err: &job.LookupError{Err: errors.New("connection refused")}}, accept: "application/problem+json", wantStatus: http.StatusBadGateway, want: &problem{
+			Type:     "urn:tinkerbell:boots:backend-error",
+			Title:    "Hardware backend failed",
+			Status:   http.StatusBadGateway,
+			Detail:   "The hardware lookup for 192.0.2.10 failed",
+			Instance: "/auto.ipxe",
+		}},
+		"breaker open": {manager: staticManager{err: &job.LookupError{Err: client.ErrBreakerOpen}}, accept: "application/problem+json", wantStatus: http.StatusServiceUnavailable, want: &problem{
+			Type:     "urn:tinkerbell:boots:backend-error",
+			Title:    "Hardware backend failed",
+			Status:   http.StatusServiceUnavailable,
+			Detail:   "The hardware lookup for 192.0.2.10 failed",
+			Instance: "/auto.ipxe",
+		}},
+		"ipxe client": {manager: staticManager{err: errors.WithMessage(client.ErrNotFound, "discover from dhcp message")}, wantStatus: http.StatusNotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &jobHandler{jobManager: tt.manager, problems: true}
+			req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+			req.RemoteAddr = "192.0.2.10:4321"
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			h.serveJobFile(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.want == nil {
+				if w.Body.Len() != 0 {
+					t.Fatalf("want empty body, got %q", w.Body)
+				}
+
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Fatalf("want Content-Type application/problem+json, got %q", ct)
+			}
+			var got problem
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(*tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestServePhoneHomeProblemJSON(t *testing.T) {
+	s := &BootsHTTPServer{jobManager: staticManager{err: errors.New("unused")}, problemJSON: true}
+	req := httptest.NewRequest(http.MethodPost, "/phone-home", strings.NewReader(`{"phase":`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/problem+json")
+	w := httptest.NewRecorder()
+	s.servePhoneHome(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("want status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	var got problem
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != "urn:tinkerbell:boots:invalid-status" || got.Status != http.StatusBadRequest || got.Detail == "" {
+		t.Fatalf("unexpected problem %+v", got)
+	}
+}
+
+func TestServeSimulateProblemJSON(t *testing.T) {
+	h := &jobHandler{problems: true}
+	for accept, wantType := range map[string]string{"application/problem+json": "application/problem+json", "": "text/plain; charset=utf-8"} {
+		req := httptest.NewRequest(http.MethodGet, simulatePath+"?mac=nope", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		h.serveSimulate(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("want status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != wantType {
+			t.Fatalf("accept %q: want Content-Type %q, got %q", accept, wantType, ct)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"hash/fnv"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+	"golang.org/x/time/rate"
+)
+
+// rateLimitShards is the number of independently locked client maps, so concurrent
+// requests from different clients rarely wait on each other.
+const rateLimitShards = 32
+
+// rateLimitSweepAt is the number of clients a shard tracks before it first drops the idle ones.
+const rateLimitSweepAt = 256
+
+// clientRateLimiter limits the request rate of each client IP with a token bucket.
+// A nil clientRateLimiter does not limit.
+type clientRateLimiter struct {
+	limit rate.Limit
+	burst int
+	// idle is how long a client's bucket takes to refill, after which it is dropped
+	idle   time.Duration
+	now    func() time.Time
+	shards [rateLimitShards]rateLimitShard
+}
+
+type rateLimitShard struct {
+	mu      sync.Mutex
+	clients map[string]*clientLimit
+	sweepAt int
+}
+
+type clientLimit struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newClientRateLimiter returns a limiter allowing each client limit requests a
+// second with bursts of burst. Returns nil if limit is 0.
+func newClientRateLimiter(limit float64, burst int) (*clientRateLimiter, error) {
+	if limit < 0 {
+		return nil, errors.New("-http-rate-limit must not be negative")
+	}
+	if limit == 0 {
+		return nil, nil
+	}
+	if burst < 1 {
+		return nil, errors.New("-http-rate-burst must be at least 1")
+	}
+
+	r := &clientRateLimiter{
+		limit: rate.Limit(limit),
+		burst: burst,
+		idle:  time.Duration(float64(burst) / limit * float64(time.Second)),
+		now:   time.Now,
+	}
+	for i := range r.shards {
+		r.shards[i].clients = map[string]*clientLimit{}
+		r.shards[i].sweepAt = rateLimitSweepAt
+	}
+
+	return r, nil
+}
+
+// allow reports whether client may make a request now.
+func (r *clientRateLimiter) allow(client string) bool {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(client))
+	s := &r.shards[h.Sum32()%rateLimitShards]
+	now := r.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.clients[client]
+	if !ok {
+		if len(s.clients) >= s.sweepAt {
+			s.sweep(now, r.idle)
+		}
+		c = &clientLimit{limiter: rate.NewLimiter(r.limit, r.burst)}
+		s.clients[client] = c
+	}
+	c.lastSeen = now
+
+	return c.limiter.AllowN(now, 1)
+}
+
+// sweep drops the clients whose bucket has refilled, they are no different from a
+// new client. s.mu must be held.
+func (s *rateLimitShard) sweep(now time.Time, idle time.Duration) {
+	for client, c := range s.clients {
+		if now.Sub(c.lastSeen) >= idle {
+			delete(s.clients, client)
+		}
+	}
+	s.sweepAt = 2 * len(s.clients)
+	if s.sweepAt < rateLimitSweepAt {
+		s.sweepAt = rateLimitSweepAt
+	}
+}
+
+// wrap rejects the requests to route of clients over their limit with a 429
+// through shedder, and passes the rest to h.
+func (r *clientRateLimiter) wrap(route string, shedder *loadShedder, h http.HandlerFunc) http.HandlerFunc {
+	if r == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		// the X-Forwarded-For handler has already replaced RemoteAddr with the resolved client
+		if !r.allow(remoteIP(req.RemoteAddr)) {
+			metrics.HTTPRateLimited.WithLabelValues(route).Inc()
+			shedder.reject(w, http.StatusTooManyRequests)
+
+			return
+		}
+		h(w, req)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestClientRateLimiter(t *testing.T) {
+	r, err := newClientRateLimiter(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	served := 0
+	h := r.wrap("/", nil, func(http.ResponseWriter, *http.Request) { served++ })
+	limited := metrics.HTTPRateLimited.WithLabelValues("/")
+	before := testutil.ToFloat64(limited)
+
+	request := func(client string) int {
+		req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+		req.RemoteAddr = client + ":5000"
+		rec := httptest.NewRecorder()
+		h(rec, req)
+
+		return rec.Code
+	}
+	for i := 0; i < 2; i++ {
+		if code := request("192.168.1.10"); code != http.StatusOK {
+			t.Fatalf("want the burst allowed, got: %d", code)
+		}
+	}
+	if code := request("192.168.1.10"); code != http.StatusTooManyRequests {
+		t.Fatalf("want 429 once the burst is used, got: %d", code)
+	}
+	if code := request("192.168.1.11"); code != http.StatusOK {
+		t.Fatalf("want another client allowed, got: %d", code)
+	}
+	now = now.Add(time.Second)
+	if code := request("192.168.1.10"); code != http.StatusOK {
+		t.Fatalf("want a request allowed once the bucket refilled, got: %d", code)
+	}
+	if served != 4 {
+		t.Fatalf("want 4 requests served, got: %d", served)
+	}
+	if got := testutil.ToFloat64(limited) - before; got != 1 {
+		t.Fatalf("want 1 rate limited request counted, got: %v", got)
+	}
+}
+
+func TestClientRateLimiterSweep(t *testing.T) {
+	r, err := newClientRateLimiter(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	for i := 0; i < rateLimitShards*rateLimitSweepAt; i++ {
+		r.allow("10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256))
+	}
+	now = now.Add(time.Second)
+	for i := 0; i < rateLimitShards*rateLimitSweepAt; i++ {
+		r.allow("10.1." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256))
+	}
+	tracked := 0
+	for i := range r.shards {
+		tracked += len(r.shards[i].clients)
+	}
+	if tracked >= 2*rateLimitShards*rateLimitSweepAt {
+		t.Fatalf("want idle clients dropped, %d tracked", tracked)
+	}
+}
+
+func TestNewClientRateLimiter(t *testing.T) {
+	if r, err := newClientRateLimiter(0, 0); err != nil || r != nil {
+		t.Fatalf("want a nil limiter without a limit, got: %v, %v", r, err)
+	}
+	if _, err := newClientRateLimiter(-1, 1); err == nil {
+		t.Fatal("want an error for a negative limit")
+	}
+	if _, err := newClientRateLimiter(1, 0); err == nil {
+		t.Fatal("want an error for a zero burst")
+	}
+	var r *clientRateLimiter
+	served := false
+	r.wrap("/", nil, func(http.ResponseWriter, *http.Request) { served = true })(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !served {
+		t.Fatal("want a nil limiter to serve every request")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+type cachedFinder struct {
+	client.HardwareFinder
+	synced    bool
+	size      int
+	lastEvent time.Time
+}
+
+func (f cachedFinder) Synced() bool         { return f.synced }
+func (f cachedFinder) Size() int            { return f.size }
+func (f cachedFinder) LastEvent() time.Time { return f.lastEvent }
+
+type pingFinder struct {
+	client.HardwareFinder
+	err error
+}
+
+func (f pingFinder) Ping(ctx context.Context) error {
+	if f.err == nil {
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+
+	return f.err
+}
+
+// pingWorkflows is a workflow backend like pingFinder.
+type pingWorkflows struct {
+	client.WorkflowFinder
+	err error
+}
+
+func (f pingWorkflows) Ping(ctx context.Context) error { return pingFinder{err: f.err}.Ping(ctx) }
+
+// reachableWorkflows is a workflow backend that can always be reached.
+type reachableWorkflows struct {
+	client.WorkflowFinder
+}
+
+func (reachableWorkflows) Ping(context.Context) error { return nil }
+
+func TestServeReadiness(t *testing.T) {
+	last := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		finder     client.HardwareFinder
+		workflows  client.WorkflowFinder
+		wantStatus int
+		want       readiness
+	}{
+		"no cache": {wantStatus: http.StatusOK, want: readiness{Ready: true}},
+		"not synced": {
+			finder:     cachedFinder{size: 3},
+			wantStatus: http.StatusServiceUnavailable,
+			want:       readiness{HardwareCache: &hardwareCacheStatus{Size: 3}},
+		},
+		"synced": {
+			finder:     cachedFinder{synced: true, size: 10, lastEvent: last},
+			wantStatus: http.StatusOK,
+			want:       readiness{Ready: true, HardwareCache: &hardwareCacheStatus{Synced: true, Size: 10, LastEvent: &last}},
+		},
+		"backend down": {
+			finder:     pingFinder{err: errors.New("connection refused")},
+			wantStatus: http.StatusServiceUnavailable,
+			want:       readiness{Error: "hardware backend: connection refused"},
+		},
+		"backend timeout": {
+			finder:     pingFinder{},
+			wantStatus: http.StatusServiceUnavailable,
+			want:       readiness{Error: "hardware backend: " + context.DeadlineExceeded.Error()},
+		},
+		"workflow backend down": {
+			finder:     reachableFinder{},
+			workflows:  pingWorkflows{err: errors.New("tink server connection transient_failure")},
+			wantStatus: http.StatusServiceUnavailable,
+			want:       readiness{Error: "workflow backend: tink server connection transient_failure"},
+		},
+		"workflow backend up": {
+			finder:     reachableFinder{},
+			workflows:  reachableWorkflows{},
+			wantStatus: http.StatusOK,
+			want:       readiness{Ready: true},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := &BootsHTTPServer{finder: tt.finder, workflowBackend: tt.workflows, readinessTimeout: 10 * time.Millisecond}
+			w := httptest.NewRecorder()
+			s.serveReadiness(w, httptest.NewRequest(http.MethodGet, "/readiness", nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, w.Code)
+			}
+			var got readiness
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+// lookupManager is a job.Manager reporting a fixed last lookup.
+type lookupManager struct {
+	job.Manager
+	last    time.Time
+	lastErr string
+}
+
+func (m lookupManager) LastLookup() (time.Time, string) { return m.last, m.lastErr }
+
+func TestServeHealthcheckerLookup(t *testing.T) {
+	last := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	s := &BootsHTTPServer{jobManager: lookupManager{last: last, lastErr: "connection refused"}}
+	w := httptest.NewRecorder()
+	s.serveHealthchecker("rev", time.Now())(w, httptest.NewRequest(http.MethodGet, "/healthcheck", nil))
+
+	var got struct {
+		LastSuccessfulLookup int64  `json:"last_successful_lookup"`
+		LastLookupError      string `json:"last_lookup_error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.LastSuccessfulLookup != last.Unix() || got.LastLookupError != "connection refused" {
+		t.Fatalf("unexpected last lookup: %+v", got)
+	}
+}
+
+func TestServeHealthcheckerConnections(t *testing.T) {
+	s := &BootsHTTPServer{}
+	total := testutil.ToFloat64(metrics.HTTPConnectionsTotal)
+	active := testutil.ToFloat64(metrics.HTTPActiveConnections)
+	for _, state := range []http.ConnState{http.StateNew, http.StateActive, http.StateNew, http.StateIdle, http.StateNew, http.StateClosed} {
+		s.trackConnState(nil, state)
+	}
+
+	w := httptest.NewRecorder()
+	s.serveHealthchecker("rev", time.Now())(w, httptest.NewRequest(http.MethodGet, "/healthcheck", nil))
+	var got struct {
+		ActiveConnections int64 `json:"active_connections"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ActiveConnections != 2 {
+		t.Fatalf("want 2 active connections, got %d", got.ActiveConnections)
+	}
+	if n := testutil.ToFloat64(metrics.HTTPConnectionsTotal) - total; n != 3 {
+		t.Fatalf("want 3 connections counted, got %v", n)
+	}
+	if n := testutil.ToFloat64(metrics.HTTPActiveConnections) - active; n != 2 {
+		t.Fatalf("want 2 more active connections, got %v", n)
+	}
+}
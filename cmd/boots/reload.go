@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/job"
+	"go.uber.org/zap"
+)
+
+// reloadableFlags are the flags whose changes are applied when the configuration is
+// reloaded, changes to any other flag are only logged as requiring a restart.
+var reloadableFlags = map[string]bool{
+	"extra-kernel-args":      true,
+	"extra-kernel-args-arch": true,
+	"ipxe-vars":              true,
+	"osie-path-override":     true,
+	"log-level":              true,
+}
+
+// reloader re-reads the configuration on SIGHUP and applies the reloadable flags
+// without restarting the listeners.
+type reloader struct {
+	// started are the flags Boots was started with
+	started *flag.FlagSet
+	// current is the running configuration, with the reloadable flags as last applied
+	current    config
+	installers *conf.Reloadable[job.Installers]
+	// logLevel is the level of the Boots, iPXE and TFTP logs
+	logLevel zap.AtomicLevel
+	// effective is the effective configuration, updated with the reloadable flags as they are applied
+	effective *conf.Reloadable[map[string]interface{}]
+}
+
+// run reloads the configuration on every SIGHUP until ctx is done.
+func (r *reloader) run(ctx context.Context, args []string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := r.reload(args); err != nil {
+				mainlog.Error(errors.WithMessage(err, "configuration not reloaded"))
+			}
+		}
+	}
+}
+
+// reload parses args, the env and the config file again like at startup and applies
+// the reloadable flags. Nothing is applied if the configuration is invalid.
+func (r *reloader) reload(args []string) error {
+	next := &config{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var noExec ffcli.NoExecError
+	if err := newCLI(next, fs).Parse(args); err != nil && !errors.As(err, &noExec) {
+		return errors.Wrap(err, "parse configuration")
+	}
+
+	var restart []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if !reloadableFlags[f.Name] && r.started.Lookup(f.Name).Value.String() != f.Value.String() {
+			restart = append(restart, f.Name)
+		}
+	})
+	if len(restart) > 0 {
+		sort.Strings(restart)
+		mainlog.With("flags", strings.Join(restart, ",")).Info("configuration changes require restart, they are not applied")
+	}
+
+	cfg := r.current
+	cfg.extraKernelArgs = next.extraKernelArgs
+	cfg.extraKernelArgsArch = next.extraKernelArgsArch
+	cfg.ipxeVars = next.ipxeVars
+	cfg.osiePathOverride = next.osiePathOverride
+	cfg.logLevel = next.logLevel
+	level, err := parseLogLevel(cfg.logLevel)
+	if err != nil {
+		return err
+	}
+	installersChanged := cfg.extraKernelArgs != r.current.extraKernelArgs || cfg.extraKernelArgsArch != r.current.extraKernelArgsArch || cfg.ipxeVars != r.current.ipxeVars ||
+		cfg.osiePathOverride != r.current.osiePathOverride
+	if !installersChanged && cfg.logLevel == r.current.logLevel {
+		mainlog.Info("configuration reloaded, no reloadable flag changed")
+
+		return nil
+	}
+	if installersChanged {
+		i, err := cfg.registerInstallers()
+		if err != nil {
+			return err
+		}
+		r.installers.Store(i)
+	}
+	if cfg.logLevel != r.current.logLevel {
+		r.logLevel.SetLevel(level)
+		mainlog.With("level", cfg.logLevel).Info("log level changed")
+	}
+	r.current = cfg
+	if r.effective != nil {
+		effective, reloaded := effectiveConfig(r.started), effectiveConfig(fs)
+		for f := range reloadableFlags {
+			effective[f] = reloaded[f]
+		}
+		r.effective.Store(effective)
+	}
+	mainlog.Info("configuration reloaded")
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/job"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// startReloader parses args like main does and returns a reloader for the result.
+func startReloader(t *testing.T, args []string) *reloader {
+	t.Helper()
+	cfg := &config{}
+	cli := newCLI(cfg, flag.NewFlagSet(name, flag.ContinueOnError))
+	_ = cli.Parse(args)
+	i, err := cfg.registerInstallers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &reloader{started: cli.FlagSet, current: *cfg, installers: conf.NewReloadable(i), logLevel: zap.NewAtomicLevel()}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boots.yaml")
+	write := func(config string) {
+		if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("extra-kernel-args: a=1\nhttp-addr: 127.0.0.1:8080\n")
+	args := []string{"-config-file", path}
+	r := startReloader(t, args)
+	before := r.installers.Load()
+
+	write("extra-kernel-args: a=2\nhttp-addr: 127.0.0.1:9090\nlog-level: debug\n")
+	if err := r.reload(args); err != nil {
+		t.Fatal(err)
+	}
+	if r.current.extraKernelArgs != "a=2" || r.current.logLevel != "debug" {
+		t.Fatalf("want the reloadable flags applied, got extra kernel args %q and log level %q", r.current.extraKernelArgs, r.current.logLevel)
+	}
+	if r.current.httpAddr != "127.0.0.1:8080" {
+		t.Fatalf("want -http-addr to require a restart, got: %q", r.current.httpAddr)
+	}
+	if sameInstallers(before, r.installers.Load()) {
+		t.Fatal("want the installers rebuilt")
+	}
+	if r.logLevel.Level() != zapcore.DebugLevel {
+		t.Fatalf("want the debug log level, got: %v", r.logLevel.Level())
+	}
+
+	// a log level change alone does not rebuild the installers
+	applied := r.installers.Load()
+	write("extra-kernel-args: a=2\nlog-level: error\n")
+	if err := r.reload(args); err != nil {
+		t.Fatal(err)
+	}
+	if r.logLevel.Level() != zapcore.ErrorLevel || !sameInstallers(applied, r.installers.Load()) {
+		t.Fatalf("want only the error log level applied, got: %v", r.logLevel.Level())
+	}
+	write("extra-kernel-args: a=2\nlog-level: loud\n")
+	if err := r.reload(args); err == nil || r.logLevel.Level() != zapcore.ErrorLevel {
+		t.Fatalf("want an invalid log level not applied, got: %v", err)
+	}
+
+	// the running config has mirrors, which an osie path override conflicts with
+	r.current.osieMirrors = "http://mirror.example.com"
+	r.current.osieMirrorSelection = "round-robin"
+	applied = r.installers.Load()
+	write("osie-path-override: http://osie.example.com\n")
+	if err := r.reload(args); err == nil {
+		t.Fatal("want an error for an invalid configuration")
+	}
+	if r.current.osiePathOverride != "" || !sameInstallers(applied, r.installers.Load()) {
+		t.Fatal("want an invalid configuration not applied")
+	}
+}
+
+func sameInstallers(a, b job.Installers) bool {
+	return reflect.ValueOf(a.ByDistro).Pointer() == reflect.ValueOf(b.ByDistro).Pointer()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// loadShedder responds to requests Boots sheds, e.g. with 429 or 503, with a
+// Retry-After that grows with the number of in-flight HTTP requests. The delay is
+// jittered so that clients shed at the same time do not retry at the same time.
+// All load shedding responses should go through reject so clients see the same
+// back off everywhere.
+type loadShedder struct {
+	inflight int64
+	min, max time.Duration
+	// capacity is the number of in-flight requests at which the delay reaches max
+	capacity int64
+	// jitter returns a number in [0, 1)
+	jitter func() float64
+}
+
+func newLoadShedder(min, max time.Duration, capacity int) (*loadShedder, error) {
+	if min < time.Second || max < min {
+		return nil, errors.Errorf("invalid retry-after range %s-%s, min must be at least 1s and not more than max", min, max)
+	}
+	if capacity < 1 {
+		return nil, errors.Errorf("invalid retry-after capacity %d, must be positive", capacity)
+	}
+
+	return &loadShedder{min: min, max: max, capacity: int64(capacity), jitter: rand.Float64}, nil
+}
+
+// track counts the requests in flight in next as the load.
+func (l *loadShedder) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&l.inflight, 1)
+		defer atomic.AddInt64(&l.inflight, -1)
+		next.ServeHTTP(w, req)
+	})
+}
+
+// retryAfter returns the delay for the current load, between half of and the full
+// load scaled delay. It is never less than min.
+func (l *loadShedder) retryAfter() time.Duration {
+	load := math.Min(float64(atomic.LoadInt64(&l.inflight))/float64(l.capacity), 1)
+	d := float64(l.min) + float64(l.max-l.min)*load
+	d *= 0.5 + 0.5*l.jitter()
+
+	return time.Duration(math.Max(d, float64(l.min)))
+}
+
+// reject responds with status and a Retry-After for the current load. A nil
+// loadShedder responds without a Retry-After.
+func (l *loadShedder) reject(w http.ResponseWriter, status int) {
+	l.setRetryAfter(w)
+	w.WriteHeader(status)
+}
+
+// setRetryAfter sets the Retry-After for the current load on w, for rejections whose
+// status and body are written elsewhere, like problem details or error scripts. A nil
+// loadShedder sets none.
+func (l *loadShedder) setRetryAfter(w http.ResponseWriter) {
+	if l == nil {
+		return
+	}
+	secs := int(math.Ceil(l.retryAfter().Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+}
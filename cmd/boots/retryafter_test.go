@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadShedder(t *testing.T) {
+	l, err := newLoadShedder(2*time.Second, 60*time.Second, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jitter := 0.999
+	l.jitter = func() float64 { return jitter }
+
+	for _, tt := range []struct {
+		inflight int64
+		jitter   float64
+		want     string
+	}{
+		{inflight: 0, jitter: 0.999, want: "2"},
+		{inflight: 0, jitter: 0, want: "2"},
+		{inflight: 5, jitter: 0.999, want: "31"},
+		{inflight: 5, jitter: 0, want: "16"},
+		{inflight: 10, jitter: 0.999, want: "60"},
+		{inflight: 50, jitter: 0.999, want: "60"},
+		{inflight: 50, jitter: 0, want: "30"},
+	} {
+		l.inflight, jitter = tt.inflight, tt.jitter
+		w := httptest.NewRecorder()
+		l.reject(w, http.StatusServiceUnavailable)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("unexpected status: %d", w.Code)
+		}
+		if got := w.Header().Get("Retry-After"); got != tt.want {
+			t.Fatalf("inflight %d, jitter %v: want Retry-After: %s, got: %s", tt.inflight, tt.jitter, tt.want, got)
+		}
+	}
+
+	// the load is the requests in flight in the tracked handler
+	l.inflight = 0
+	var during int64
+	l.track(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { during = l.inflight })).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if during != 1 || l.inflight != 0 {
+		t.Fatalf("want 1 request in flight while serving and 0 after, got %d and %d", during, l.inflight)
+	}
+
+	w := httptest.NewRecorder()
+	(*loadShedder)(nil).reject(w, http.StatusTooManyRequests)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "" {
+		t.Fatalf("unexpected nil shedder response: %d, Retry-After: %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	if _, err := newLoadShedder(0, time.Minute, 10); err == nil {
+		t.Fatal("expected an error for a min under a second")
+	}
+	if _, err := newLoadShedder(time.Minute, time.Second, 10); err == nil {
+		t.Fatal("expected an error for a max under min")
+	}
+}
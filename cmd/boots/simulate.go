@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// simulatePath serves the boot decision for a MAC without it booting, see serveSimulate.
+const simulatePath = "/_packet/simulate"
+
+// serveSimulate responds with the boot decision, including the boot script and its kernel
+// args, that the machine with the mac query param would get requesting the script query
+// param, auto by default, as JSON, or with format=ipxe the boot script itself. The arch
+// query param is the arch the machine reports in DHCP option 93. It looks up the hardware
+// like a DHCP request but does not record a boot, and the boot script is rendered as a dry
+// run, see job.WithDryRun. It is served behind requireToken with the simulate token.
+func (h *jobHandler) serveSimulate(w http.ResponseWriter, req *http.Request) {
+	labels := prometheus.Labels{"from": "http", "op": "simulate"}
+	metrics.JobsTotal.With(labels).Inc()
+	metrics.JobsInProgress.With(labels).Inc()
+	defer metrics.JobsInProgress.With(labels).Dec()
+	timer := prometheus.NewTimer(metrics.JobDuration.With(labels))
+	defer timer.ObserveDuration()
+
+	q := req.URL.Query()
+	mac, err := net.ParseMAC(q.Get("mac"))
+	if err != nil {
+		h.simulateError(w, req, http.StatusBadRequest, problemInvalidRequest, "invalid mac: "+err.Error(), nil)
+
+		return
+	}
+	script := q.Get("script")
+	if script == "" {
+		script = "auto"
+	}
+	if strings.ContainsAny(script, "/.") {
+		h.simulateError(w, req, http.StatusBadRequest, problemInvalidRequest, "invalid script "+script, mac)
+
+		return
+	}
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "ipxe" {
+		h.simulateError(w, req, http.StatusBadRequest, problemInvalidRequest, "invalid format "+format+", must be json or ipxe", mac)
+
+		return
+	}
+
+	lookupCtx, cancel := req.Context(), context.CancelFunc(func() {})
+	if h.lookupTimeout > 0 {
+		lookupCtx, cancel = context.WithTimeout(lookupCtx, h.lookupTimeout)
+	}
+	ctx, j, err := h.jobManager.CreateFromDHCP(job.WithDryRun(lookupCtx), mac, nil, "")
+	cancel()
+	if err != nil {
+		code, typ := lookupProblem(err)
+		h.simulateError(w, req, code, typ, err.Error(), mac)
+		mainlog.With("client", req.RemoteAddr, "mac", mac).Error(err, "no job found for simulated boot")
+
+		return
+	}
+	j.ClientArch = q.Get("arch")
+	j.BootsScheme = requestScheme(req)
+	// the job manager may not be a job.Creator, which sets it from the context
+	j.DryRun = true
+	d := j.BootDecision(ctx, "/"+script+".ipxe", h.installers.Load())
+	j.With("client", req.RemoteAddr, "script", script, "installer", d.Installer).Info("simulated boot")
+
+	if format == "ipxe" {
+		if d.Script == "" {
+			reason, typ := "no boot script", problemNoBootScript
+			if !d.AllowPXE {
+				reason, typ = "not allowed to netboot, allow_pxe is false", problemPXENotAllowed
+			} else if d.Error != "" {
+				reason = d.Error
+			}
+			h.simulateError(w, req, http.StatusNotFound, typ, reason, mac)
+
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := w.Write([]byte(d.Script)); err != nil {
+			j.Error(errors.Wrap(err, "unable to write simulated boot script"))
+		}
+
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(d); err != nil {
+		j.Error(errors.Wrap(err, "marshaling simulated boot json"))
+	}
+}
+
+// simulateError responds with code and a problem of type typ if the client accepts it,
+// otherwise with msg as a plain text body.
+func (h *jobHandler) simulateError(w http.ResponseWriter, req *http.Request, code int, typ, msg string, mac net.HardwareAddr) {
+	if h.problems.accepted(req) {
+		h.problems.fail(w, req, code, typ, msg, mac)
+
+		return
+	}
+	http.Error(w, msg, code)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestServeSimulate(t *testing.T) {
+	j := job.NewMock(t, "c3.small.x86", "ewr1").Job()
+	i := job.NewInstallers()
+	tests := map[string]struct {
+		manager    staticManager
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		"decision":        {manager: staticManager{j: &j}, query: "mac=00:00:ba:dd:be:ef", wantStatus: http.StatusOK, wantBody: `"allow_pxe":false`},
+		"not allowed":     {manager: staticManager{j: &j}, query: "mac=00:00:ba:dd:be:ef&format=ipxe", wantStatus: http.StatusNotFound, wantBody: "allow_pxe is false"},
+		"invalid mac":     {manager: staticManager{j: &j}, query: "mac=nope", wantStatus: http.StatusBadRequest},
+		"invalid script":  {manager: staticManager{j: &j}, query: "mac=00:00:ba:dd:be:ef&script=../auto", wantStatus: http.StatusBadRequest},
+		"invalid format":  {manager: staticManager{j: &j}, query: "mac=00:00:ba:dd:be:ef&format=yaml", wantStatus: http.StatusBadRequest},
+		"not found":       {manager: staticManager{err: errors.WithMessage(client.ErrNotFound, "discover from dhcp message")}, query: "mac=00:00:ba:dd:be:ef", wantStatus: http.StatusNotFound},
+		"backend failing": {manager: staticManager{err: &job.LookupError{Err: errors.New("connection refused")}}, query: "mac=00:00:ba:dd:be:ef", wantStatus: http.StatusBadGateway},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &jobHandler{jobManager: tt.manager, installers: conf.NewReloadable(i)}
+			files := testutil.ToFloat64(metrics.JobsTotal.WithLabelValues("http", "file"))
+			req := httptest.NewRequest(http.MethodGet, simulatePath+"?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			h.serveSimulate(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d: %s", tt.wantStatus, w.Code, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Fatalf("want body containing %q, got %s", tt.wantBody, w.Body)
+			}
+			if n := testutil.ToFloat64(metrics.JobsTotal.WithLabelValues("http", "file")) - files; n != 0 {
+				t.Fatalf("want no file jobs counted, got %v", n)
+			}
+		})
+	}
+}
+
+func TestServeSimulateDecision(t *testing.T) {
+	j := job.NewMock(t, "c3.small.x86", "ewr1").Job()
+	h := &jobHandler{jobManager: staticManager{j: &j}, installers: conf.NewReloadable(job.NewInstallers())}
+	simulated := testutil.ToFloat64(metrics.JobsTotal.WithLabelValues("http", "simulate"))
+	w := httptest.NewRecorder()
+	h.serveSimulate(w, httptest.NewRequest(http.MethodGet, simulatePath+"?mac=00:00:ba:dd:be:ef", nil))
+
+	var d job.BootDecision
+	if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.ScriptName != "auto" || d.Installer != "shell" || d.Script != "" {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+	if n := testutil.ToFloat64(metrics.JobsTotal.WithLabelValues("http", "simulate")) - simulated; n != 1 {
+		t.Fatalf("want 1 simulated job, got %v", n)
+	}
+}
+
+// dryRunManager is a staticManager recording whether it was asked for a dry run job.
+type dryRunManager struct {
+	staticManager
+	dryRun *bool
+}
+
+func (m dryRunManager) CreateFromDHCP(ctx context.Context, mac net.HardwareAddr, giaddr net.IP, circuitID string) (context.Context, *job.Job, error) {
+	*m.dryRun = job.IsDryRun(ctx)
+
+	return m.staticManager.CreateFromDHCP(ctx, mac, giaddr, circuitID)
+}
+
+func TestServeSimulateDryRun(t *testing.T) {
+	j := job.NewMock(t, "c3.small.x86", "ewr1").Job()
+	var dryRun bool
+	h := &jobHandler{jobManager: dryRunManager{staticManager: staticManager{j: &j}, dryRun: &dryRun}, installers: conf.NewReloadable(job.NewInstallers())}
+	w := httptest.NewRecorder()
+	h.serveSimulate(w, httptest.NewRequest(http.MethodGet, simulatePath+"?mac=00:00:ba:dd:be:ef", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("want status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	if !dryRun {
+		t.Fatal("want the hardware looked up for a dry run")
+	}
+	if !j.DryRun {
+		t.Fatal("want the simulated job rendered as a dry run")
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/boots/client"
+)
+
+// statsPath serves a digest of recent activity, see serveStats.
+const statsPath = "/_packet/stats"
+
+// recentWindow is how long a recentCounter counts, in 1 second buckets.
+const recentWindow = 3600
+
+// recentCounter counts events within the last hour, in a ring of 1 second buckets. A nil
+// recentCounter does not count.
+type recentCounter struct {
+	now func() time.Time
+
+	mu sync.Mutex
+	// counts are the events of each second, seconds the unix time of each bucket
+	counts  [recentWindow]int
+	seconds [recentWindow]int64
+}
+
+func newRecentCounter() *recentCounter {
+	return &recentCounter{now: time.Now}
+}
+
+// add counts an event.
+func (r *recentCounter) add() {
+	if r == nil {
+		return
+	}
+	now := r.now().Unix()
+	i := now % recentWindow
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seconds[i] != now {
+		r.seconds[i], r.counts[i] = now, 0
+	}
+	r.counts[i]++
+}
+
+// within returns the events counted within the last minute and the last hour.
+func (r *recentCounter) within() (minute, hour int) {
+	if r == nil {
+		return 0, 0
+	}
+	now := r.now().Unix()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, sec := range r.seconds {
+		switch age := now - sec; {
+		case age < 0 || age >= recentWindow:
+		case age < 60:
+			minute += r.counts[i]
+			hour += r.counts[i]
+		default:
+			hour += r.counts[i]
+		}
+	}
+
+	return minute, hour
+}
+
+// recentLookups counts the hardware lookups of DHCP and HTTP jobs within the last hour,
+// and the ones that failed. A lookup that found no record did not fail. A nil
+// recentLookups does not count.
+type recentLookups struct {
+	all    *recentCounter
+	failed *recentCounter
+}
+
+func newRecentLookups() *recentLookups {
+	return &recentLookups{all: newRecentCounter(), failed: newRecentCounter()}
+}
+
+// add counts a lookup that returned err.
+func (r *recentLookups) add(err error) {
+	if r == nil {
+		return
+	}
+	r.all.add()
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		r.failed.add()
+	}
+}
+
+// lastHour returns the lookups counted within the last hour and how many of them failed.
+func (r *recentLookups) lastHour() (lookups, failed int) {
+	if r == nil {
+		return 0, 0
+	}
+	_, lookups = r.all.within()
+	_, failed = r.failed.within()
+
+	return lookups, failed
+}
+
+// stats is a digest of the recent activity of Boots, as served.
+type stats struct {
+	BootsLastMinute int `json:"boots_last_minute"`
+	BootsLastHour   int `json:"boots_last_hour"`
+	JobsInProgress  int `json:"jobs_in_progress"`
+	// ActiveMACs are the distinct machines seen booting within -active-boots-max-age
+	ActiveMACs int `json:"active_macs"`
+	// BackendLookups and BackendErrors count the hardware lookups of DHCP and HTTP jobs within the last hour, BackendErrorRate is their ratio
+	BackendLookups   int     `json:"backend_lookups_last_hour"`
+	BackendErrors    int     `json:"backend_errors_last_hour"`
+	BackendErrorRate float64 `json:"backend_error_rate_last_hour"`
+}
+
+// gatherStats returns the stats read from the metrics of g, jobs_in_progress.
+func gatherStats(g prometheus.Gatherer) (stats, error) {
+	var st stats
+	families, err := g.Gather()
+	if err != nil {
+		return st, errors.Wrap(err, "gather metrics")
+	}
+	for _, f := range families {
+		if f.GetName() != "jobs_in_progress" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			st.JobsInProgress += int(m.GetGauge().GetValue())
+		}
+	}
+
+	return st, nil
+}
+
+// serveStats responds with a digest of the recent activity of Boots as JSON, for a glance
+// at its health without Prometheus.
+func (s *BootsHTTPServer) serveStats(w http.ResponseWriter, _ *http.Request) {
+	st, err := gatherStats(prometheus.DefaultGatherer)
+	if err != nil {
+		// the stats that were gathered are still served
+		mainlog.Error(err)
+	}
+	st.BootsLastMinute, st.BootsLastHour = s.recentBoots.within()
+	st.ActiveMACs = s.activeBoots.size()
+	st.BackendLookups, st.BackendErrors = s.recentLookups.lastHour()
+	if st.BackendLookups > 0 {
+		st.BackendErrorRate = float64(st.BackendErrors) / float64(st.BackendLookups)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(st); err != nil {
+		mainlog.Error(errors.Wrap(err, "marshaling stats json"))
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestRecentCounter(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := newRecentCounter()
+	r.now = func() time.Time { return now }
+
+	r.add()
+	now = now.Add(30 * time.Minute)
+	r.add()
+	r.add()
+	now = now.Add(30 * time.Second)
+	r.add()
+	if minute, hour := r.within(); minute != 3 || hour != 4 {
+		t.Fatalf("want 3 boots in the last minute and 4 in the last hour, got %d and %d", minute, hour)
+	}
+
+	// the first boot falls out of the hour, the next ones out of the minute
+	now = now.Add(30 * time.Minute)
+	if minute, hour := r.within(); minute != 0 || hour != 3 {
+		t.Fatalf("want 0 boots in the last minute and 3 in the last hour, got %d and %d", minute, hour)
+	}
+
+	// a bucket reused an hour later starts over
+	now = now.Add(time.Hour)
+	r.add()
+	if minute, hour := r.within(); minute != 1 || hour != 1 {
+		t.Fatalf("want 1 boot in the last minute and hour, got %d and %d", minute, hour)
+	}
+
+	var none *recentCounter
+	none.add()
+	if minute, hour := none.within(); minute != 0 || hour != 0 {
+		t.Fatalf("want no boots counted by a nil counter, got %d and %d", minute, hour)
+	}
+}
+
+func TestRecentLookups(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := newRecentLookups()
+	r.all.now = func() time.Time { return now }
+	r.failed.now = r.all.now
+
+	r.add(nil)
+	r.add(errors.WithMessage(client.ErrNotFound, "discover from dhcp message"))
+	r.add(&job.LookupError{Err: errors.New("connection refused")})
+	if lookups, failed := r.lastHour(); lookups != 3 || failed != 1 {
+		t.Fatalf("want 3 lookups and 1 failed, got %d and %d", lookups, failed)
+	}
+
+	// lookups older than an hour are not counted
+	now = now.Add(time.Hour)
+	r.add(nil)
+	if lookups, failed := r.lastHour(); lookups != 1 || failed != 0 {
+		t.Fatalf("want 1 lookup and none failed, got %d and %d", lookups, failed)
+	}
+
+	var none *recentLookups
+	none.add(nil)
+	if lookups, failed := none.lastHour(); lookups != 0 || failed != 0 {
+		t.Fatalf("want no lookups counted by a nil counter, got %d and %d", lookups, failed)
+	}
+}
+
+func TestServeStats(t *testing.T) {
+	active, err := newActiveBoots(time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active.seen(net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, 0x01}, "", bootStageDHCP, "")
+	active.seen(net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, 0x02}, "", bootStageFile, "")
+	s := &BootsHTTPServer{activeBoots: active, recentBoots: newRecentCounter(), recentLookups: newRecentLookups()}
+	s.recentBoots.add()
+	s.recentLookups.add(nil)
+	s.recentLookups.add(&job.LookupError{Err: errors.New("connection refused")})
+
+	before, err := gatherStats(prometheus.DefaultGatherer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inProgress := metrics.JobsInProgress.WithLabelValues("http", "file")
+	inProgress.Inc()
+	defer inProgress.Dec()
+
+	w := httptest.NewRecorder()
+	s.serveStats(w, httptest.NewRequest(http.MethodGet, statsPath, nil))
+	var got stats
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.BootsLastMinute != 1 || got.BootsLastHour != 1 || got.ActiveMACs != 2 {
+		t.Fatalf("want 1 boot and 2 active MACs, got %+v", got)
+	}
+	if got.JobsInProgress-before.JobsInProgress != 1 {
+		t.Fatalf("want 1 more job in progress than %+v, got %+v", before, got)
+	}
+	if got.BackendLookups != 2 || got.BackendErrors != 1 || got.BackendErrorRate != 0.5 {
+		t.Fatalf("want 2 lookups in the last hour, 1 failed, got %+v", got)
+	}
+}
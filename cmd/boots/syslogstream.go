@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/syslog"
+)
+
+const (
+	// syslogStreamBuffer is the number of messages buffered per client before messages are dropped.
+	syslogStreamBuffer = 256
+	// syslogStreamKeepAlive is how often a comment is sent to keep idle streams open.
+	syslogStreamKeepAlive = 15 * time.Second
+)
+
+// syslogStreamAuthorized checks the bearer token in the Authorization header, or
+// the token query param as EventSource in browsers can not set headers.
+func (s *BootsHTTPServer) syslogStreamAuthorized(req *http.Request) bool {
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = req.URL.Query().Get("token")
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.syslogStreamToken)) == 1
+}
+
+// serveSyslogStream streams received syslog messages as server-sent events, one
+// JSON encoded syslog.Event per event. Messages can be filtered by source with
+// the ip or mac query params, a mac is resolved to its IP via the hardware record.
+func (s *BootsHTTPServer) serveSyslogStream(w http.ResponseWriter, req *http.Request) {
+	if !s.syslogStreamAuthorized(req) {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		mainlog.Error(errors.New("response writer does not support flushing, can not stream syslog"))
+
+		return
+	}
+
+	filter, err := s.syslogStreamFilter(req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+
+		return
+	}
+
+	sub := s.syslogHub.Subscribe(syslogStreamBuffer, filter)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(syslogStreamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			b, err := json.Marshal(e)
+			if err != nil {
+				mainlog.Error(errors.Wrap(err, "marshal syslog event"))
+
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// syslogStreamFilter returns a filter matching the source given by the ip or mac query params, or nil for no filter.
+func (s *BootsHTTPServer) syslogStreamFilter(req *http.Request) (func(syslog.Event) bool, error) {
+	var ip net.IP
+	switch q := req.URL.Query(); {
+	case q.Get("ip") != "":
+		ip = net.ParseIP(q.Get("ip"))
+		if ip == nil {
+			return nil, errors.Errorf("invalid ip %q", q.Get("ip"))
+		}
+	case q.Get("mac") != "":
+		mac, err := net.ParseMAC(q.Get("mac"))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid mac")
+		}
+		d, err := s.finder.ByMAC(req.Context(), mac, nil, "")
+		if err != nil {
+			return nil, errors.WithMessage(err, "resolving mac")
+		}
+		ip = d.GetIP(mac).Address
+		if ip == nil {
+			return nil, errors.Errorf("no ip found for mac %q", mac)
+		}
+	default:
+		return nil, nil
+	}
+	host := ip.String()
+
+	return func(e syslog.Event) bool { return e.Host == host }, nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pin/tftp/v3"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+	"github.com/tinkerbell/ipxedust/itftp"
+)
+
+// The types of failed TFTP read requests counted by TFTPErrorsTotal.
+const (
+	// tftpErrTimeout is a client that stopped acknowledging blocks.
+	tftpErrTimeout = "timeout"
+	// tftpErrFileNotFound is a request for a file that is not an iPXE binary.
+	tftpErrFileNotFound = "file-not-found"
+	// tftpErrRejected is a request refused by the -tftp-max-concurrent limit or during shutdown.
+	tftpErrRejected = "rejected"
+	// tftpErrClient is a transfer the client aborted with a TFTP error.
+	tftpErrClient = "client-error"
+	// tftpErrOther is any other failure.
+	tftpErrOther = "other"
+)
+
+var (
+	errTFTPBusy         = errors.New("too many transfers in progress, try again later")
+	errTFTPShuttingDown = errors.New("server is shutting down")
+)
+
+// BootsTFTPServer serves iPXE binaries via TFTP.
+type BootsTFTPServer struct {
+	log logr.Logger
+	// timeout is the timeout for serving individual requests.
+	timeout time.Duration
+	// singlePort serves all transfers from the listening port instead of
+	// allocating a new port per transfer. This is required when running in a
+	// container that doesn't bind to the host's network.
+	singlePort bool
+	// limiter bounds the transfers in flight, nil does not bound them
+	limiter *tftpLimiter
+	// activeBoots matches transfers to the boot of the requesting machine, for logging its boot ID
+	activeBoots *activeBoots
+
+	drainer *drainer
+	mu      sync.Mutex
+	conn    net.PacketConn
+	ts      *tftp.Server
+}
+
+// ServeTFTP serves iPXE binaries using conn until Shutdown is called.
+func (s *BootsTFTPServer) ServeTFTP(conn net.PacketConn) error {
+	h := &itftp.Handler{Log: s.log}
+	ts := tftp.NewServer(instrumentRead(s.limiter.wrap(s.drainRead(s.handleRead))), h.HandleWrite)
+	ts.SetTimeout(s.timeout)
+	if s.singlePort {
+		ts.EnableSinglePort()
+	}
+	s.mu.Lock()
+	s.conn = conn
+	s.ts = ts
+	s.mu.Unlock()
+	s.log.Info("serving iPXE binaries via TFTP", "addr", conn.LocalAddr().String(), "timeout", s.timeout, "singlePortEnabled", s.singlePort)
+
+	return ts.Serve(conn)
+}
+
+// drainRead wraps a read handler so that in-flight transfers are tracked and new
+// ones are refused once Shutdown is called.
+func (s *BootsTFTPServer) drainRead(h func(string, io.ReaderFrom) error) func(string, io.ReaderFrom) error {
+	return func(filename string, rf io.ReaderFrom) error {
+		desc := filename
+		if ot, ok := rf.(tftp.OutgoingTransfer); ok {
+			addr := ot.RemoteAddr()
+			desc = addr.String() + " " + filename
+		}
+		finish, ok := s.drainer.start(desc)
+		if !ok {
+			return errTFTPShuttingDown
+		}
+		defer finish()
+
+		return h(filename, rf)
+	}
+}
+
+// handleRead serves a read request, logging the MAC and boot ID of the requesting machine
+// if its boot is tracked.
+func (s *BootsTFTPServer) handleRead(filename string, rf io.ReaderFrom) error {
+	log := s.log
+	if ot, ok := rf.(tftp.OutgoingTransfer); ok {
+		addr := ot.RemoteAddr()
+		if b, ok := s.activeBoots.byAddr(addr.IP.String()); ok {
+			log = log.WithValues("mac", b.MAC, "boot_id", b.BootID)
+		}
+	}
+	h := &itftp.Handler{Log: log}
+
+	return h.HandleRead(filename, rf)
+}
+
+// countingTransfer counts the bytes sent by the transfer it wraps.
+type countingTransfer struct {
+	io.ReaderFrom
+	n int64
+}
+
+func (t *countingTransfer) ReadFrom(r io.Reader) (int64, error) {
+	n, err := t.ReaderFrom.ReadFrom(r)
+	t.n += n
+
+	return n, err
+}
+
+// countingOutgoingTransfer is a countingTransfer of a tftp.OutgoingTransfer, so the
+// handler can still get the client's address and set the transfer size.
+type countingOutgoingTransfer struct {
+	*countingTransfer
+	tftp.OutgoingTransfer
+}
+
+// instrumentRead wraps a read handler to count the bytes sent, the duration and the
+// errors of its transfers. Rejected requests are counted as errors but not timed, they
+// never started a transfer.
+func instrumentRead(h func(string, io.ReaderFrom) error) func(string, io.ReaderFrom) error {
+	return func(filename string, rf io.ReaderFrom) error {
+		start := time.Now()
+		ct := &countingTransfer{ReaderFrom: rf}
+		var counted io.ReaderFrom = ct
+		if ot, ok := rf.(tftp.OutgoingTransfer); ok {
+			counted = countingOutgoingTransfer{ct, ot}
+		}
+		err := h(filename, counted)
+		metrics.TFTPBytesTotal.Add(float64(ct.n))
+		if err == nil {
+			metrics.TFTPTransferDuration.WithLabelValues("success").Observe(time.Since(start).Seconds())
+
+			return nil
+		}
+		typ := tftpErrorType(err)
+		metrics.TFTPErrorsTotal.WithLabelValues(typ).Inc()
+		if typ != tftpErrRejected {
+			metrics.TFTPTransferDuration.WithLabelValues("error").Observe(time.Since(start).Seconds())
+		}
+
+		return err
+	}
+}
+
+// tftpErrorType returns the TFTPErrorsTotal type of err, the error of a read request.
+func tftpErrorType(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errTFTPBusy), errors.Is(err, errTFTPShuttingDown):
+		return tftpErrRejected
+	case errors.Is(err, os.ErrNotExist):
+		return tftpErrFileNotFound
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return tftpErrTimeout
+	case strings.Contains(err.Error(), "code="):
+		// pin/tftp only reports the TFTP error the client sent in the message
+		return tftpErrClient
+	default:
+		return tftpErrOther
+	}
+}
+
+// tftpLimiter bounds the number of TFTP transfers in flight. Read requests beyond the
+// limit wait up to queueTimeout for a transfer to finish and are rejected after that.
+// The transfer timeout only starts once a request leaves the queue, as it bounds the
+// wait for each block's acknowledgment.
+type tftpLimiter struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// newTFTPLimiter returns a limiter of max transfers, nil if max is 0.
+func newTFTPLimiter(max int, queueTimeout time.Duration) (*tftpLimiter, error) {
+	if max < 0 {
+		return nil, errors.New("-tftp-max-concurrent must not be negative")
+	}
+	if queueTimeout < 0 {
+		return nil, errors.New("-tftp-queue-timeout must not be negative")
+	}
+	if max == 0 {
+		return nil, nil
+	}
+
+	return &tftpLimiter{slots: make(chan struct{}, max), queueTimeout: queueTimeout}, nil
+}
+
+// acquire takes a transfer slot, waiting up to the queue timeout. Returns false if
+// there was none, otherwise the slot must be released.
+func (l *tftpLimiter) acquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.queueTimeout == 0 {
+		return false
+	}
+	t := time.NewTimer(l.queueTimeout)
+	defer t.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+func (l *tftpLimiter) release() {
+	<-l.slots
+}
+
+// wrap bounds the transfers of a read handler, rejected transfers get a TFTP error.
+func (l *tftpLimiter) wrap(h func(string, io.ReaderFrom) error) func(string, io.ReaderFrom) error {
+	if l == nil {
+		return h
+	}
+
+	return func(filename string, rf io.ReaderFrom) error {
+		if !l.acquire() {
+			metrics.TFTPRejected.Inc()
+
+			return errTFTPBusy
+		}
+		defer l.release()
+		metrics.TFTPInFlight.Inc()
+		defer metrics.TFTPInFlight.Dec()
+
+		return h(filename, rf)
+	}
+}
+
+// Shutdown stops accepting new read requests and waits for in-flight transfers
+// to finish until ctx is done, then stops the server. All transfers use the
+// listening port in single port mode, so it is only closed after draining.
+func (s *BootsTFTPServer) Shutdown(ctx context.Context) {
+	if remaining := s.drainer.drain(ctx); len(remaining) > 0 {
+		s.log.Info("tftp shutdown timed out, closing with transfers in flight", "inflight", remaining)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.ts.Shutdown()
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pin/tftp/v3"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestTFTPLimiter(t *testing.T) {
+	l, err := newTFTPLimiter(1, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	read := l.wrap(func(filename string, _ io.ReaderFrom) error {
+		if filename == "slow" {
+			close(started)
+			<-finish
+		}
+
+		return nil
+	})
+
+	done := make(chan error)
+	go func() { done <- read("slow", nil) }()
+	<-started
+
+	rejected := testutil.ToFloat64(metrics.TFTPRejected)
+	if err := read("fast", nil); err == nil {
+		t.Fatal("want transfer beyond the limit rejected")
+	}
+	if n := testutil.ToFloat64(metrics.TFTPRejected) - rejected; n != 1 {
+		t.Fatalf("want 1 rejected transfer, got %v", n)
+	}
+
+	// a queued transfer starts once the one in progress finishes
+	queued := make(chan error)
+	go func() { queued <- read("fast", nil) }()
+	time.Sleep(10 * time.Millisecond)
+	close(finish)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-queued; err != nil {
+		t.Fatalf("want queued transfer served, got %v", err)
+	}
+}
+
+func TestNewTFTPLimiter(t *testing.T) {
+	l, err := newTFTPLimiter(0, time.Second)
+	if err != nil || l != nil {
+		t.Fatalf("want no limiter, got %v, %v", l, err)
+	}
+	if _, err := newTFTPLimiter(-1, time.Second); err == nil {
+		t.Fatal("want error for negative max")
+	}
+	if _, err := newTFTPLimiter(1, -time.Second); err == nil {
+		t.Fatal("want error for negative queue timeout")
+	}
+}
+
+// fakeTransfer is a tftp.OutgoingTransfer that sends the content it reads up to fail bytes, then fails with err.
+type fakeTransfer struct {
+	fail int64
+	err  error
+	addr net.UDPAddr
+}
+
+func (t *fakeTransfer) ReadFrom(r io.Reader) (int64, error) {
+	if t.err != nil {
+		return io.CopyN(io.Discard, r, t.fail)
+	}
+
+	return io.Copy(io.Discard, r)
+}
+
+func (t *fakeTransfer) SetSize(int64) {}
+
+func (t *fakeTransfer) RemoteAddr() net.UDPAddr { return t.addr }
+
+// timeoutError is a net.Error timing out, like those of a client that stopped acknowledging blocks.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// tftpDurationCount returns the number of TFTP transfers timed with result.
+func tftpDurationCount(t *testing.T, result string) uint64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "tftp_transfer_duration_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "result" && l.GetValue() == result {
+					return m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+
+	return 0
+}
+
+func TestInstrumentRead(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 1500)
+	tests := map[string]struct {
+		content   []byte
+		transfer  *fakeTransfer
+		err       error
+		wantBytes float64
+		wantType  string
+		wantTimed string
+	}{
+		"served":    {content: content, transfer: &fakeTransfer{}, wantBytes: 1500, wantTimed: "success"},
+		"timeout":   {content: content, transfer: &fakeTransfer{fail: 512, err: timeoutError{}}, wantBytes: 512, wantType: tftpErrTimeout, wantTimed: "error"},
+		"client":    {content: content, transfer: &fakeTransfer{fail: 0, err: errors.New("sending block 0: code=0, error: User aborted the transfer")}, wantType: tftpErrClient, wantTimed: "error"},
+		"not found": {err: errors.Wrap(os.ErrNotExist, "file [nope.efi] unknown"), wantType: tftpErrFileNotFound, wantTimed: "error"},
+		"rejected":  {err: errTFTPBusy, wantType: tftpErrRejected},
+		"other":     {err: errors.New("blksize too small: 8"), wantType: tftpErrOther, wantTimed: "error"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			read := instrumentRead(func(_ string, rf io.ReaderFrom) error {
+				if _, ok := rf.(tftp.OutgoingTransfer); !ok {
+					t.Fatal("want the transfer to still be a tftp.OutgoingTransfer")
+				}
+				if tt.content == nil {
+					return tt.err
+				}
+				_, err := rf.ReadFrom(bytes.NewReader(tt.content))
+				if err == nil {
+					err = tt.transfer.err
+				}
+
+				return err
+			})
+			transfer := tt.transfer
+			if transfer == nil {
+				transfer = &fakeTransfer{}
+			}
+			sent := testutil.ToFloat64(metrics.TFTPBytesTotal)
+			errs := map[string]float64{}
+			for _, typ := range []string{tftpErrTimeout, tftpErrFileNotFound, tftpErrRejected, tftpErrClient, tftpErrOther} {
+				errs[typ] = testutil.ToFloat64(metrics.TFTPErrorsTotal.WithLabelValues(typ))
+			}
+			timed := map[string]uint64{"success": tftpDurationCount(t, "success"), "error": tftpDurationCount(t, "error")}
+
+			err := read("undionly.kpxe", transfer)
+			if (err != nil) != (tt.wantType != "") {
+				t.Fatalf("want error %t, got: %v", tt.wantType != "", err)
+			}
+			if n := testutil.ToFloat64(metrics.TFTPBytesTotal) - sent; n != tt.wantBytes {
+				t.Fatalf("want %v bytes counted, got %v", tt.wantBytes, n)
+			}
+			for typ, before := range errs {
+				want := 0.0
+				if typ == tt.wantType {
+					want = 1
+				}
+				if n := testutil.ToFloat64(metrics.TFTPErrorsTotal.WithLabelValues(typ)) - before; n != want {
+					t.Fatalf("want %v %s errors counted, got %v", want, typ, n)
+				}
+			}
+			for result, before := range timed {
+				var want uint64
+				if result == tt.wantTimed {
+					want = 1
+				}
+				if n := tftpDurationCount(t, result) - before; n != want {
+					t.Fatalf("want %d %s transfers timed, got %d", want, result, n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// tlsVersions are the accepted -tls-min-version values. TLS 1.0 and 1.1 are
+// deprecated (RFC 8996) and intentionally not accepted.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// defaultCipherSuites are the TLS 1.2 cipher suites used when -tls-cipher-suites is not set.
+// Only ECDHE key exchange with AEAD ciphers are allowed.
+var defaultCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// tlsConfig builds the tls.Config for the HTTPS server from the -http-tls-cert,
+// -http-tls-key, -tls-min-version, -tls-cipher-suites and -tls-client-ca flags.
+// The HTTP server only serves TLS when the config has a certificate.
+func (cf *config) tlsConfig() (*tls.Config, error) {
+	if (cf.httpTLSCert == "") != (cf.httpTLSKey == "") {
+		return nil, errors.New("-http-tls-cert and -http-tls-key must be set together")
+	}
+	// client certificates are only presented in a TLS handshake
+	if cf.phoneHomeClientCert && (cf.httpTLSCert == "" || cf.tlsClientCA == "") {
+		return nil, errors.New("-phone-home-client-cert requires -http-tls-cert, -http-tls-key and -tls-client-ca")
+	}
+	minVersion, ok := tlsVersions[cf.tlsMinVersion]
+	if !ok {
+		return nil, errors.Errorf("invalid -tls-min-version %q, must be one of: 1.2, 1.3", cf.tlsMinVersion)
+	}
+	tc := &tls.Config{MinVersion: minVersion}
+
+	if minVersion == tls.VersionTLS13 {
+		if cf.tlsCipherSuites != "" {
+			return nil, errors.New("-tls-cipher-suites can not be used with -tls-min-version 1.3, TLS 1.3 cipher suites are not configurable")
+		}
+	} else {
+		tc.CipherSuites = defaultCipherSuites
+		if cf.tlsCipherSuites != "" {
+			suites, err := parseCipherSuites(cf.tlsCipherSuites)
+			if err != nil {
+				return nil, err
+			}
+			tc.CipherSuites = suites
+		}
+	}
+
+	if cf.tlsClientCA != "" {
+		pem, err := os.ReadFile(cf.tlsClientCA)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading -tls-client-ca")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in -tls-client-ca %q", cf.tlsClientCA)
+		}
+		tc.ClientCAs = pool
+		// booting machines don't have client certs, so they are only verified if given
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	if cf.httpTLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(cf.httpTLSCert, cf.httpTLSKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading -http-tls-cert and -http-tls-key")
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing -http-tls-cert")
+		}
+		// checked here rather than by clients, so -check catches a certificate no machine would accept
+		if now := time.Now(); now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+			return nil, errors.Errorf("-http-tls-cert %q is only valid from %s to %s", cf.httpTLSCert, leaf.NotBefore.Format(time.RFC3339), leaf.NotAfter.Format(time.RFC3339))
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	return tc, nil
+}
+
+// parseCipherSuites parses a comma separated list of TLS 1.2 cipher suite names,
+// as named by crypto/tls, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+func parseCipherSuites(v string) ([]uint16, error) {
+	secure := map[string]*tls.CipherSuite{}
+	for _, cs := range tls.CipherSuites() {
+		secure[cs.Name] = cs
+	}
+	insecure := map[string]bool{}
+	for _, cs := range tls.InsecureCipherSuites() {
+		insecure[cs.Name] = true
+	}
+
+	var suites []uint16
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if insecure[name] {
+			return nil, errors.Errorf("insecure cipher suite %q in -tls-cipher-suites", name)
+		}
+		cs, ok := secure[name]
+		if !ok {
+			return nil, errors.Errorf("unknown cipher suite %q in -tls-cipher-suites", name)
+		}
+		if !supportsTLS12(cs) {
+			return nil, errors.Errorf("cipher suite %q in -tls-cipher-suites is TLS 1.3 only, TLS 1.3 cipher suites are not configurable", name)
+		}
+		suites = append(suites, cs.ID)
+	}
+	if len(suites) == 0 {
+		return nil, errors.New("-tls-cipher-suites must contain at least one cipher suite")
+	}
+
+	return suites, nil
+}
+
+func supportsTLS12(cs *tls.CipherSuite) bool {
+	for _, v := range cs.SupportedVersions {
+		if v == tls.VersionTLS12 {
+			return true
+		}
+	}
+
+	return false
+}
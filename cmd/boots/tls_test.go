@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTLSConfig(t *testing.T) {
+	tests := map[string]struct {
+		minVersion   string
+		cipherSuites string
+		wantVersion  uint16
+		wantSuites   []uint16
+		wantErr      bool
+	}{
+		"defaults": {
+			minVersion:  "1.2",
+			wantVersion: tls.VersionTLS12,
+			wantSuites:  defaultCipherSuites,
+		},
+		"tls 1.3": {
+			minVersion:  "1.3",
+			wantVersion: tls.VersionTLS13,
+		},
+		"cipher allow-list": {
+			minVersion:   "1.2",
+			cipherSuites: "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+			wantVersion:  tls.VersionTLS12,
+			wantSuites:   []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		},
+		"tls 1.1 rejected":              {minVersion: "1.1", wantErr: true},
+		"unknown version":               {minVersion: "tls12", wantErr: true},
+		"cipher suites with tls 1.3":    {minVersion: "1.3", cipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", wantErr: true},
+		"insecure cipher suite":         {minVersion: "1.2", cipherSuites: "TLS_RSA_WITH_RC4_128_SHA", wantErr: true},
+		"unknown cipher suite":          {minVersion: "1.2", cipherSuites: "TLS_NOPE", wantErr: true},
+		"tls 1.3 only cipher suite":     {minVersion: "1.2", cipherSuites: "TLS_AES_128_GCM_SHA256", wantErr: true},
+		"empty cipher suite allow-list": {minVersion: "1.2", cipherSuites: ",", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config{tlsMinVersion: tt.minVersion, tlsCipherSuites: tt.cipherSuites}
+			got, err := cfg.tlsConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.MinVersion != tt.wantVersion {
+				t.Fatalf("MinVersion: want %v, got %v", tt.wantVersion, got.MinVersion)
+			}
+			if diff := cmp.Diff(tt.wantSuites, got.CipherSuites); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestTLSConfigCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	writeCert := func(name string, notBefore, notAfter time.Time) string {
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "boots"},
+			NotBefore:    notBefore,
+			NotAfter:     notAfter,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := filepath.Join(dir, name)
+		if err := os.WriteFile(f, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		return f
+	}
+	now := time.Now()
+	certFile := writeCert("tls.crt", now, now.Add(time.Hour))
+	expiredFile := writeCert("expired.crt", now.Add(-2*time.Hour), now.Add(-time.Hour))
+	futureFile := writeCert("future.crt", now.Add(time.Hour), now.Add(2*time.Hour))
+
+	tests := map[string]struct {
+		cert, key  string
+		clientCert bool
+		clientCA   string
+		wantCerts  int
+		wantErr    bool
+	}{
+		"no tls":                     {},
+		"cert and key":               {cert: certFile, key: keyFile, wantCerts: 1},
+		"cert only":                  {cert: certFile, wantErr: true},
+		"key only":                   {key: keyFile, wantErr: true},
+		"missing file":               {cert: filepath.Join(dir, "nope.crt"), key: keyFile, wantErr: true},
+		"expired cert":               {cert: expiredFile, key: keyFile, wantErr: true},
+		"cert not yet valid":         {cert: futureFile, key: keyFile, wantErr: true},
+		"client cert":                {cert: certFile, key: keyFile, clientCert: true, clientCA: certFile, wantCerts: 1},
+		"client cert without tls":    {clientCert: true, clientCA: certFile, wantErr: true},
+		"client cert without the ca": {cert: certFile, key: keyFile, clientCert: true, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config{tlsMinVersion: "1.2", httpTLSCert: tt.cert, httpTLSKey: tt.key, phoneHomeClientCert: tt.clientCert, tlsClientCA: tt.clientCA}
+			got, err := cfg.tlsConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Certificates) != tt.wantCerts {
+				t.Fatalf("want %d certificates, got %d", tt.wantCerts, len(got.Certificates))
+			}
+		})
+	}
+}
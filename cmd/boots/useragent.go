@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/tinkerbell/boots/job"
+)
+
+// userAgentArches are the arches of the platform names in User-Agent headers.
+var userAgentArches = map[string]string{
+	"x86_64":  "x86_64",
+	"amd64":   "x86_64",
+	"x64":     "x86_64",
+	"aarch64": "aarch64",
+	"arm64":   "aarch64",
+}
+
+// userAgentArch returns the arch named in a User-Agent, e.g. x86_64 for
+// "UefiHttpBoot/1.0 (X64)", or "" if it names none or several. Stock iPXE sends
+// "iPXE/<version>", e.g. "iPXE/1.21.1+ (g988d2)", and names no arch, only custom builds
+// that add their platform to it do.
+func userAgentArch(ua string) string {
+	ua = strings.ReplaceAll(strings.ToLower(ua), "x86-64", "x86_64")
+	var arch string
+	for _, token := range strings.FieldsFunc(ua, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_')
+	}) {
+		a, ok := userAgentArches[token]
+		if !ok {
+			continue
+		}
+		if arch != "" && arch != a {
+			return ""
+		}
+		arch = a
+	}
+
+	return arch
+}
+
+// clientArch returns the arch the machine of j reported over DHCP. Without one, if the
+// hardware record has no arch either, it falls back to the arch named in the User-Agent
+// of req, see userAgentArch, unless -user-agent-arch is disabled. Returns "" if the arch
+// is unknown, so the record's or default arch is used.
+func (h *jobHandler) clientArch(req *http.Request, j *job.Job) string {
+	if arch := h.activeBoots.arch(j.PrimaryNIC()); arch != "" {
+		return arch
+	}
+	if !h.userAgentArch || j.HasHardwareArch() {
+		return ""
+	}
+	arch := userAgentArch(req.UserAgent())
+	if arch != "" {
+		j.With("client", req.RemoteAddr, "user_agent", req.UserAgent(), "arch", arch).Info("no arch from DHCP or the hardware record, using the arch of the User-Agent")
+	}
+
+	return arch
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tinkerbell/boots/job"
+)
+
+func TestUserAgentArch(t *testing.T) {
+	tests := map[string]string{
+		// stock iPXE only sends its version
+		"iPXE/1.21.1+ (g988d2)": "",
+		"iPXE/1.20.1+ (gdc2dd)": "",
+		"iPXE/1.0.0+":           "",
+		// custom iPXE builds that add their platform
+		"iPXE/1.21.1+ (g988d2) arm64-efi":    "aarch64",
+		"iPXE/1.21.1+ (g988d2) x86_64-efi":   "x86_64",
+		"iPXE/1.21.1 (arm64-efi x86_64-efi)": "",
+		"UefiHttpBoot/1.0 (X64)":             "x86_64",
+		"Mozilla/5.0 (X11; Linux x86-64)":    "x86_64",
+		"curl/7.81.0":                        "",
+		"":                                   "",
+	}
+	for ua, want := range tests {
+		if got := userAgentArch(ua); got != want {
+			t.Fatalf("%q: want arch %q, got %q", ua, want, got)
+		}
+	}
+}
+
+func TestClientArch(t *testing.T) {
+	const ua = "iPXE/1.21.1+ (g988d2) arm64-efi"
+	tests := map[string]struct {
+		dhcpArch   string
+		recordArch string
+		disabled   bool
+		want       string
+	}{
+		"dhcp arch":           {dhcpArch: "x86_64", want: "x86_64"},
+		"record arch":         {recordArch: "x86_64"},
+		"user agent fallback": {want: "aarch64"},
+		"fallback disabled":   {disabled: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := job.NewMock(t, "c3.small.x86", "ewr1")
+			m.SetMAC("00:00:ba:dd:be:ef")
+			m.SetArch(tt.recordArch)
+			j := m.Job()
+			a, err := newActiveBoots(time.Minute, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.seen(j.PrimaryNIC(), "", bootStageDHCP, "")
+			a.seenArch(j.PrimaryNIC(), tt.dhcpArch)
+
+			req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+			req.Header.Set("User-Agent", ua)
+			h := &jobHandler{activeBoots: a, userAgentArch: !tt.disabled}
+			if got := h.clientArch(req, &j); got != tt.want {
+				t.Fatalf("want arch %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
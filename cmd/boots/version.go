@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// version is the build that is running, as printed by -version and served by /version.
+type version struct {
+	GitRev    string `json:"git_rev"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+func currentVersion() version {
+	return version{GitRev: GitRev, BuildDate: BuildDate, GoVersion: runtime.Version()}
+}
+
+// printVersion writes the version to w.
+func printVersion(w io.Writer) error {
+	v := currentVersion()
+	_, err := fmt.Fprintf(w, "%s %s\ngit revision: %s\nbuild date: %s\n", name, v.GoVersion, v.GitRev, v.BuildDate)
+
+	return errors.Wrap(err, "print version")
+}
+
+// serveVersion responds with the version as JSON.
+func serveVersion(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(currentVersion()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		mainlog.Error(errors.Wrap(err, "marshaling version json"))
+	}
+}
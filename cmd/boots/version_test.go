@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestServeVersion(t *testing.T) {
+	w := httptest.NewRecorder()
+	serveVersion(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("want status 200, got %d", w.Code)
+	}
+	var got version
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := version{GitRev: GitRev, BuildDate: BuildDate, GoVersion: runtime.Version()}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestPrintVersion(t *testing.T) {
+	var b bytes.Buffer
+	if err := printVersion(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{GitRev, BuildDate, runtime.Version()} {
+		if !strings.Contains(b.String(), want) {
+			t.Fatalf("want %q in version, got %q", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// phoneHomeWebhookQueue is the number of phone-home notifications waiting for
+// delivery, notifications beyond it are dropped.
+const phoneHomeWebhookQueue = 1000
+
+// phoneHomeNotification is the payload POSTed to the phone-home webhook.
+type phoneHomeNotification struct {
+	Time       time.Time `json:"time"`
+	MAC        string    `json:"mac"`
+	IP         string    `json:"ip"`
+	JobID      string    `json:"job_id"`
+	HardwareID string    `json:"hardware_id,omitempty"`
+	Type       string    `json:"type,omitempty"`
+}
+
+// phoneHomeWebhook POSTs a notification to url for each phone-home, in the
+// background so the booting machine's response never waits on it. Deliveries that
+// fail with a network error or a 5xx are retried up to retries times.
+type phoneHomeWebhook struct {
+	url string
+	// redactedURL is url without its password, to be logged
+	redactedURL string
+	client      *http.Client
+	retries     int
+	// backoff is the wait before the first retry, it doubles for each retry after that
+	backoff time.Duration
+	queue   chan phoneHomeNotification
+}
+
+func newPhoneHomeWebhook(rawURL string, timeout time.Duration, retries int) (*phoneHomeWebhook, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.Errorf("invalid -phone-home-webhook %q, must be an http or https URL", rawURL)
+	}
+	if timeout <= 0 {
+		return nil, errors.New("-phone-home-webhook-timeout must be greater than 0")
+	}
+	if retries < 0 {
+		return nil, errors.New("-phone-home-webhook-retries must not be negative")
+	}
+
+	return &phoneHomeWebhook{
+		url:         rawURL,
+		redactedURL: u.Redacted(),
+		// a single client so connections to the webhook are kept alive and reused
+		client:  &http.Client{Timeout: timeout},
+		retries: retries,
+		backoff: time.Second,
+		queue:   make(chan phoneHomeNotification, phoneHomeWebhookQueue),
+	}, nil
+}
+
+// notify queues n for delivery, it does not wait for the delivery.
+func (h *phoneHomeWebhook) notify(n phoneHomeNotification) {
+	if h == nil {
+		return
+	}
+	select {
+	case h.queue <- n:
+	default:
+		metrics.PhoneHomeWebhookErrors.Inc()
+		mainlog.With("mac", n.MAC).Error(errors.New("phone-home webhook queue is full, dropping notification"))
+	}
+}
+
+// run delivers the queued notifications until ctx is done.
+func (h *phoneHomeWebhook) run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			if n := len(h.queue); n > 0 {
+				mainlog.With("notifications", n).Info("phone-home webhook stopped with notifications undelivered")
+			}
+
+			return nil
+		case n := <-h.queue:
+			if err := h.deliver(ctx, n); err != nil {
+				metrics.PhoneHomeWebhookErrors.Inc()
+				mainlog.With("mac", n.MAC, "url", h.redactedURL).Error(err, "delivering phone-home webhook")
+			}
+		}
+	}
+}
+
+// deliver POSTs n, retrying transient failures.
+func (h *phoneHomeWebhook) deliver(ctx context.Context, n phoneHomeNotification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return errors.Wrap(err, "marshal phone-home notification")
+	}
+	wait := h.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := h.post(ctx, body)
+		if err == nil || !retry || attempt >= h.retries {
+			return err
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+
+			return err
+		case <-t.C:
+		}
+		wait *= 2
+	}
+}
+
+// post sends body once, reporting whether a failure is worth retrying.
+func (h *phoneHomeWebhook) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "create phone-home webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := h.client.Do(req)
+	if err != nil {
+		return true, errors.Wrap(err, "post phone-home webhook")
+	}
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	if res.StatusCode >= 300 {
+		return res.StatusCode >= 500, errors.Errorf("phone-home webhook responded %d", res.StatusCode)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// xffGuard counts, and in strict mode rejects, requests carrying an X-Forwarded-For
+// header from a peer outside the trusted proxies. The xff middleware ignores the
+// header of those requests, which would otherwise go unnoticed.
+type xffGuard struct {
+	trusted []*net.IPNet
+	strict  bool
+}
+
+// newXFFGuard returns a guard for the trusted proxy subnets, CIDRs as in conf.TrustedProxies.
+func newXFFGuard(subnets []string, strict bool) (*xffGuard, error) {
+	g := &xffGuard{strict: strict}
+	for _, s := range subnets {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid trusted proxy %q", s)
+		}
+		g.trusted = append(g.trusted, n)
+	}
+
+	return g, nil
+}
+
+// isTrusted reports whether the peer at ip is a trusted proxy.
+func (g *xffGuard) isTrusted(ip net.IP) bool {
+	for _, n := range g.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wrap checks the requests to h, it must wrap the xff middleware so the peer address is
+// still the request's RemoteAddr.
+func (g *xffGuard) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		claimed := req.Header.Get("X-Forwarded-For")
+		if claimed == "" {
+			h.ServeHTTP(w, req)
+
+			return
+		}
+		if ip := net.ParseIP(remoteIP(req.RemoteAddr)); ip != nil && g.isTrusted(ip) {
+			h.ServeHTTP(w, req)
+
+			return
+		}
+		metrics.HTTPUntrustedXFF.Inc()
+		mainlog.With("client", req.RemoteAddr, "x-forwarded-for", claimed, "strict", g.strict).Debug("X-Forwarded-For from an untrusted peer")
+		if g.strict {
+			http.Error(w, "X-Forwarded-For is only accepted from trusted proxies", http.StatusBadRequest)
+
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestXFFGuard(t *testing.T) {
+	tests := map[string]struct {
+		peer      string
+		xff       string
+		strict    bool
+		want      int
+		untrusted float64
+	}{
+		"no header":           {peer: "192.168.1.10:5000", want: http.StatusOK},
+		"trusted proxy":       {peer: "10.1.2.3:5000", xff: "192.168.1.10", want: http.StatusOK},
+		"untrusted":           {peer: "192.168.1.10:5000", xff: "192.168.1.20", want: http.StatusOK, untrusted: 1},
+		"untrusted strict":    {peer: "192.168.1.10:5000", xff: "192.168.1.20", strict: true, want: http.StatusBadRequest, untrusted: 1},
+		"trusted strict":      {peer: "10.1.2.3:5000", xff: "192.168.1.10", strict: true, want: http.StatusOK},
+		"no header strict":    {peer: "192.168.1.10:5000", strict: true, want: http.StatusOK},
+		"invalid peer strict": {peer: "nope", xff: "192.168.1.20", strict: true, want: http.StatusBadRequest, untrusted: 1},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			g, err := newXFFGuard([]string{"10.0.0.0/8"}, tt.strict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			untrusted := testutil.ToFloat64(metrics.HTTPUntrustedXFF)
+			h := g.wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+			req.RemoteAddr = tt.peer
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("want status %d, got %d", tt.want, w.Code)
+			}
+			if n := testutil.ToFloat64(metrics.HTTPUntrustedXFF) - untrusted; n != tt.untrusted {
+				t.Fatalf("want %v untrusted requests, got %v", tt.untrusted, n)
+			}
+		})
+	}
+}
+
+func TestNewXFFGuardInvalid(t *testing.T) {
+	if _, err := newXFFGuard([]string{"10.0.0.1"}, false); err == nil {
+		t.Fatal("want error for a trusted proxy that is not a CIDR")
+	}
+}
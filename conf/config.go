@@ -11,15 +11,22 @@ import (
 )
 
 var (
+	// PublicIPFamily is the address family, ipv4 or ipv6, of PublicIP.
+	PublicIPFamily = mustPublicIPFamily()
+	// PublicIPv4 is nil if PublicIPFamily is ipv6 and the host has no public IPv4.
 	PublicIPv4 = mustPublicIPv4()
-	PublicFQDN = env.Get("PUBLIC_FQDN", PublicIPv4.String())
+	// PublicIP is the address of PublicIPFamily that PublicFQDN and the HTTP and
+	// syslog listen addresses default to.
+	PublicIP   = mustPublicIP()
+	PublicFQDN = env.Get("PUBLIC_FQDN", urlHost(PublicIP))
 
 	PublicSyslogIPv4 = mustPublicSyslogIPv4()
-	PublicSyslogFQDN = env.Get("PUBLIC_SYSLOG_FQDN", PublicSyslogIPv4.String())
+	PublicSyslogFQDN = env.Get("PUBLIC_SYSLOG_FQDN", publicSyslogHost())
 
-	SyslogBind = env.Get("SYSLOG_BIND", PublicIPv4.String()+":514")
-	HTTPBind   = env.Get("HTTP_BIND", PublicIPv4.String()+":80")
-	BOOTPBind  = env.Get("BOOTP_BIND", PublicIPv4.String()+":67")
+	SyslogBind = env.Get("SYSLOG_BIND", net.JoinHostPort(PublicIP.String(), "514"))
+	HTTPBind   = env.Get("HTTP_BIND", net.JoinHostPort(PublicIP.String(), "80"))
+	// BOOTPBind is IPv4 only, DHCP is served for IPv4 clients
+	BOOTPBind = env.Get("BOOTP_BIND", bootpBind())
 
 	// Default to Google Public DNS.
 	DHCPLeaseTime = env.Duration("DHCP_LEASE_TIME", (2 * 24 * time.Hour))
@@ -34,6 +41,16 @@ var (
 	OsieVendorServicesURL = env.Get("OSIE_VENDOR_SERVICES_URL")
 )
 
+func mustPublicIPFamily() string {
+	family := env.Get("PUBLIC_IP_FAMILY", "ipv4")
+	if family != "ipv4" && family != "ipv6" {
+		err := errors.Errorf("PUBLIC_IP_FAMILY must be ipv4 or ipv6, got %q", family)
+		panic(err)
+	}
+
+	return family
+}
+
 func mustPublicIPv4() net.IP {
 	if s, ok := os.LookupEnv("PUBLIC_IP"); ok {
 		if a := net.ParseIP(s).To4(); a != nil {
@@ -47,20 +64,78 @@ func mustPublicIPv4() net.IP {
 		err = errors.Wrap(err, "unable to auto-detect public IPv4")
 		panic(err)
 	}
+	if v4 := globalUnicast(addrs, false); v4 != nil || PublicIPFamily == "ipv6" {
+		return v4
+	}
+	err = errors.New("unable to auto-detect public IPv4")
+	panic(err)
+}
+
+func mustPublicIP() net.IP {
+	if PublicIPFamily == "ipv4" {
+		return PublicIPv4
+	}
+	if s, ok := os.LookupEnv("PUBLIC_IPV6"); ok {
+		if a := net.ParseIP(s); a != nil && a.To4() == nil {
+			return a
+		}
+		err := errors.New("PUBLIC_IPV6 must be an IPv6 address")
+		panic(err)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		err = errors.Wrap(err, "unable to auto-detect public IPv6")
+		panic(err)
+	}
+	if v6 := globalUnicast(addrs, true); v6 != nil {
+		return v6
+	}
+	err = errors.New("unable to auto-detect public IPv6")
+	panic(err)
+}
+
+// globalUnicast returns the first global unicast IPv4, or IPv6 if ipv6 is set, address of addrs.
+func globalUnicast(addrs []net.Addr, ipv6 bool) net.IP {
 	for _, addr := range addrs {
 		ip, ok := addr.(*net.IPNet)
-		if !ok {
+		if !ok || !ip.IP.IsGlobalUnicast() {
 			continue
 		}
 		v4 := ip.IP.To4()
-		if v4 == nil || !v4.IsGlobalUnicast() {
-			continue
+		switch {
+		case !ipv6 && v4 != nil:
+			return v4
+		case ipv6 && v4 == nil:
+			return ip.IP
 		}
+	}
 
-		return v4
+	return nil
+}
+
+// urlHost formats ip as the host of a URL, IPv6 addresses are bracketed.
+func urlHost(ip net.IP) string {
+	if ip.To4() == nil {
+		return "[" + ip.String() + "]"
 	}
-	err = errors.New("unable to auto-detect public IPv4")
-	panic(err)
+
+	return ip.String()
+}
+
+func publicSyslogHost() string {
+	if PublicSyslogIPv4 == nil {
+		return PublicIP.String()
+	}
+
+	return PublicSyslogIPv4.String()
+}
+
+func bootpBind() string {
+	if PublicIPv4 == nil {
+		return "0.0.0.0:67"
+	}
+
+	return PublicIPv4.String() + ":67"
 }
 
 func mustPublicSyslogIPv4() net.IP {
@@ -0,0 +1,45 @@
+package conf
+
+import (
+	"net"
+	"testing"
+)
+
+func TestGlobalUnicast(t *testing.T) {
+	cidr := func(s string) net.Addr {
+		ip, n, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.IP = ip
+
+		return n
+	}
+	addrs := []net.Addr{
+		cidr("127.0.0.1/8"),
+		cidr("::1/128"),
+		cidr("fe80::1/64"),
+		cidr("2001:db8::10/64"),
+		cidr("192.168.1.10/24"),
+	}
+	if got := globalUnicast(addrs, false); got.String() != "192.168.1.10" || len(got) != net.IPv4len {
+		t.Fatalf("want 192.168.1.10, got %v", got)
+	}
+	if got := globalUnicast(addrs, true); got.String() != "2001:db8::10" {
+		t.Fatalf("want 2001:db8::10, got %v", got)
+	}
+	if got := globalUnicast(addrs[:3], true); got != nil {
+		t.Fatalf("want no global unicast IPv6, got %v", got)
+	}
+}
+
+func TestURLHost(t *testing.T) {
+	for ip, want := range map[string]string{
+		"192.168.1.10": "192.168.1.10",
+		"2001:db8::10": "[2001:db8::10]",
+	} {
+		if got := urlHost(net.ParseIP(ip)); got != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package conf
+
+import "sync/atomic"
+
+// Reloadable holds a setting that can be replaced while Boots runs, e.g. when the
+// configuration is reloaded on SIGHUP. Each request should Load the current value
+// once and use it throughout, it is safe for concurrent use.
+type Reloadable[T any] struct {
+	v atomic.Value
+}
+
+// NewReloadable returns a Reloadable holding v.
+func NewReloadable[T any](v T) *Reloadable[T] {
+	r := &Reloadable[T]{}
+	r.Store(v)
+
+	return r
+}
+
+// Load returns the current value.
+func (r *Reloadable[T]) Load() T {
+	return r.v.Load().(holder[T]).v
+}
+
+// Store replaces the current value with v.
+func (r *Reloadable[T]) Store(v T) {
+	r.v.Store(holder[T]{v: v})
+}
+
+// holder lets any T be stored, atomic.Value requires every stored value to have the same concrete type.
+type holder[T any] struct {
+	v T
+}
@@ -0,0 +1,197 @@
+package dhcp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// The unknown arch policies that are not a profile name.
+const (
+	// UnknownArchDeny sends no boot filename to clients with an unknown arch.
+	UnknownArchDeny = "deny"
+	// UnknownArchBootfile sends a configured boot filename to clients with an unknown arch.
+	UnknownArchBootfile = "bootfile"
+)
+
+// The firmware of a PXE client, see Firmware.
+const (
+	// FirmwarePXE is the PXE firmware of a NIC.
+	FirmwarePXE = "pxe"
+	// FirmwareIPXE is an iPXE build, such as the stock iPXE flashed on a NIC.
+	FirmwareIPXE = "ipxe"
+)
+
+// archProfiles are the named profiles an arch can be mapped to, with the iPXE binary they boot.
+var archProfiles = map[string]string{
+	"bios":  "undionly.kpxe",
+	"uefi":  "ipxe.efi",
+	"arm64": "snp.efi",
+}
+
+// ArchPolicy chooses the iPXE binary for a client by its option 93 arch and firmware. A nil
+// ArchPolicy chooses by the arch name and falls back to BIOS.
+type ArchPolicy struct {
+	// mapping overrides the profile of arch values
+	mapping map[uint16]string
+	// filenames override the binary of arch values by the client's firmware
+	filenames map[archFirmware]string
+	// unknown is a profile name, UnknownArchDeny or UnknownArchBootfile
+	unknown  string
+	bootfile string
+}
+
+// archFirmware is an option 93 arch and the firmware of a client, see Firmware.
+type archFirmware struct {
+	arch     uint16
+	firmware string
+}
+
+// NewArchPolicy returns a policy for mapping, a comma separated list of arch=profile
+// pairs, filenames, a comma separated list of arch/firmware=filename pairs taking
+// precedence over mapping, and unknown, the policy for arch values that are neither
+// mapped nor known. bootfile is required, and only allowed, for the UnknownArchBootfile policy.
+func NewArchPolicy(mapping, filenames, unknown, bootfile string) (*ArchPolicy, error) {
+	p := &ArchPolicy{mapping: map[uint16]string{}, filenames: map[archFirmware]string{}, unknown: unknown, bootfile: bootfile}
+	if mapping != "" {
+		for _, pair := range strings.Split(mapping, ",") {
+			code, profile, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				return nil, errors.Errorf("invalid arch mapping %q, must be arch=profile", pair)
+			}
+			n, err := strconv.ParseUint(code, 10, 16)
+			if err != nil {
+				return nil, errors.Errorf("invalid arch %q in mapping %q", code, pair)
+			}
+			if _, ok := archProfiles[profile]; !ok {
+				return nil, errors.Errorf("unknown arch profile %q in mapping %q, must be one of: bios, uefi, arm64", profile, pair)
+			}
+			p.mapping[uint16(n)] = profile
+		}
+	}
+	if filenames != "" {
+		for _, pair := range strings.Split(filenames, ",") {
+			key, filename, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			code, firmware, hasFirmware := strings.Cut(key, "/")
+			if !ok || !hasFirmware || filename == "" {
+				return nil, errors.Errorf("invalid boot filename mapping %q, must be arch/firmware=filename", pair)
+			}
+			n, err := strconv.ParseUint(code, 10, 16)
+			if err != nil {
+				return nil, errors.Errorf("invalid arch %q in boot filename mapping %q", code, pair)
+			}
+			if firmware != FirmwarePXE && firmware != FirmwareIPXE {
+				return nil, errors.Errorf("unknown firmware %q in boot filename mapping %q, must be %s or %s", firmware, pair, FirmwarePXE, FirmwareIPXE)
+			}
+			p.filenames[archFirmware{arch: uint16(n), firmware: firmware}] = filename
+		}
+	}
+
+	switch _, isProfile := archProfiles[unknown]; {
+	case isProfile, unknown == UnknownArchDeny:
+		if bootfile != "" {
+			return nil, errors.New("an unknown arch bootfile is only used with the bootfile policy")
+		}
+	case unknown == UnknownArchBootfile:
+		if bootfile == "" {
+			return nil, errors.New("the bootfile unknown arch policy requires a bootfile")
+		}
+	default:
+		return nil, errors.Errorf("invalid unknown arch policy %q, must be a profile (bios, uefi, arm64), %s or %s", unknown, UnknownArchDeny, UnknownArchBootfile)
+	}
+
+	return p, nil
+}
+
+// Filenames returns the distinct boot filenames of the arch/firmware mapping, sorted.
+func (p *ArchPolicy) Filenames() []string {
+	if p == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var filenames []string
+	for _, filename := range p.filenames {
+		if !seen[filename] {
+			seen[filename] = true
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+
+	return filenames
+}
+
+// Binary returns the iPXE binary to send to req, or an error if req's arch is
+// unknown and the policy denies it.
+func (p *ArchPolicy) Binary(req *dhcp4.Packet) (string, error) {
+	arch, ok := req.GetUint16(dhcp4.OptionClientSystem)
+	if p == nil || !ok {
+		return archBinary(req), nil
+	}
+	if filename, ok := p.filenames[archFirmware{arch: arch, firmware: Firmware(req)}]; ok {
+		return filename, nil
+	}
+	if profile, ok := p.mapping[arch]; ok {
+		return archProfiles[profile], nil
+	}
+	if binary, ok := namedArchBinary(req); ok {
+		return binary, nil
+	}
+
+	metrics.DHCPUnknownArch.WithLabelValues(strconv.Itoa(int(arch))).Inc()
+	switch p.unknown {
+	case UnknownArchDeny:
+		return "", errors.Errorf("unknown client arch %d denied", arch)
+	case UnknownArchBootfile:
+		return p.bootfile, nil
+	default:
+		dhcplog.With("mac", req.GetCHAddr(), "arch", arch, "profile", p.unknown).Info("unknown client arch, using the unknown arch profile")
+
+		return archProfiles[p.unknown], nil
+	}
+}
+
+// Firmware returns FirmwareIPXE if req is from an iPXE build, which sends its features
+// in the iPXE encapsulated options (option 175) or the stock "iPXE" user-class (option
+// 77), otherwise FirmwarePXE.
+func Firmware(req *dhcp4.Packet) string {
+	if _, ok := req.GetOption(ipxe.EncapsulatedOptions); ok {
+		return FirmwareIPXE
+	}
+	if uc, _ := req.GetOption(dhcp4.OptionUserClass); string(uc) == "iPXE" {
+		return FirmwareIPXE
+	}
+
+	return FirmwarePXE
+}
+
+// archBinary chooses the iPXE binary from the name of req's arch, falling back to BIOS.
+func archBinary(req *dhcp4.Packet) string {
+	if binary, ok := namedArchBinary(req); ok {
+		return binary
+	}
+
+	return archProfiles["bios"]
+}
+
+// namedArchBinary chooses the iPXE binary from the name of req's arch, see procArchTypes:
+// ARM arches, including U-Boot, get the arm64 binary, other UEFI arches, including RISC-V,
+// the uefi binary and BIOS arches the bios binary. It returns false for the other arches
+// and the values past procArchTypes, which have no iPXE binary.
+func namedArchBinary(req *dhcp4.Packet) (string, bool) {
+	switch {
+	case IsARM(req):
+		return archProfiles["arm64"], true
+	case IsUEFI(req):
+		return archProfiles["uefi"], true
+	case strings.Contains(strings.ToLower(ProcessorArchType(req)), "bios"):
+		return archProfiles["bios"], true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,198 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"os"
+	"strconv"
+	"testing"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+	l "github.com/packethost/pkg/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestMain(m *testing.M) {
+	logger, _ := l.Init("github.com/tinkerbell/boots")
+	Init(logger)
+	metrics.Init(logger)
+	os.Exit(m.Run())
+}
+
+func archPacket(arch int) *dhcp4.Packet {
+	p := dhcp4.NewPacket(dhcp4.BootRequest)
+	if arch >= 0 {
+		v := make([]byte, 2)
+		binary.BigEndian.PutUint16(v, uint16(arch))
+		p.SetOption(dhcp4.OptionClientSystem, v)
+	}
+
+	return &p
+}
+
+func TestArchPolicyBinary(t *testing.T) {
+	tests := map[string]struct {
+		mapping   string
+		filenames string
+		userClass string
+		unknown   string
+		bootfile  string
+		arch      int
+		want      string
+		wantErr   bool
+		counted   bool
+	}{
+		"no option 93":             {unknown: "bios", arch: -1, want: "undionly.kpxe"},
+		"x86 bios":                 {unknown: "deny", arch: 0, want: "undionly.kpxe"},
+		"x64 uefi":                 {unknown: "deny", arch: 7, want: "ipxe.efi"},
+		"x64 uefi http":            {unknown: "deny", arch: 16, want: "ipxe.efi"},
+		"arm64 uefi":               {unknown: "deny", arch: 11, want: "snp.efi"},
+		"mapped known arch":        {mapping: "7=arm64", unknown: "bios", arch: 7, want: "snp.efi"},
+		"mapped unknown arch":      {mapping: "27=uefi, 41=arm64", unknown: "deny", arch: 41, want: "snp.efi"},
+		"pc/at bios http":          {unknown: "deny", arch: 20, want: "undionly.kpxe"},
+		"arm64 uboot":              {unknown: "bios", arch: 22, want: "snp.efi"},
+		"arm32 uboot http":         {unknown: "deny", arch: 23, want: "snp.efi"},
+		"riscv64 uefi":             {unknown: "bios", arch: 27, want: "ipxe.efi"},
+		"riscv128 uefi http":       {unknown: "deny", arch: 30, want: "ipxe.efi"},
+		"mapped riscv64":           {mapping: "27=arm64", unknown: "bios", arch: 27, want: "snp.efi"},
+		"itanium to uefi profile":  {unknown: "uefi", arch: 2, want: "ipxe.efi", counted: true},
+		"itanium to bios":          {unknown: "bios", arch: 2, want: "undionly.kpxe", counted: true},
+		"powerpc denied":           {unknown: "deny", arch: 12, wantErr: true, counted: true},
+		"past arch table":          {unknown: "bootfile", bootfile: "custom.efi", arch: 1000, want: "custom.efi", counted: true},
+		"s390 to default bootfile": {unknown: "bootfile", bootfile: "s390.bin", arch: 31, want: "s390.bin", counted: true},
+		"pxe filename":             {filenames: "0/pxe=undionly-legacy.kpxe", mapping: "0=uefi", unknown: "deny", arch: 0, want: "undionly-legacy.kpxe"},
+		"pxe filename from ipxe":   {filenames: "0/pxe=undionly-legacy.kpxe", unknown: "deny", userClass: "iPXE", arch: 0, want: "undionly.kpxe"},
+		"ipxe filename":            {filenames: "0/pxe=undionly-legacy.kpxe,0/ipxe=ipxe.pxe", unknown: "deny", userClass: "iPXE", arch: 0, want: "ipxe.pxe"},
+		"ipxe filename unknown":    {filenames: "12/ipxe=ppc.bin", unknown: "deny", userClass: "iPXE", arch: 12, want: "ppc.bin"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewArchPolicy(tt.mapping, tt.filenames, tt.unknown, tt.bootfile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := archPacket(tt.arch)
+			if tt.userClass != "" {
+				req.SetString(dhcp4.OptionUserClass, tt.userClass)
+			}
+			var before float64
+			if tt.arch >= 0 {
+				before = testutil.ToFloat64(metrics.DHCPUnknownArch.WithLabelValues(strconv.Itoa(tt.arch)))
+			}
+
+			got, err := p.Binary(req)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("want %q, got %q", tt.want, got)
+			}
+			if tt.arch >= 0 {
+				counted := testutil.ToFloat64(metrics.DHCPUnknownArch.WithLabelValues(strconv.Itoa(tt.arch))) > before
+				if counted != tt.counted {
+					t.Fatalf("want unknown arch counted %v, got %v", tt.counted, counted)
+				}
+			}
+		})
+	}
+}
+
+func TestArchPolicyFilenames(t *testing.T) {
+	p, err := NewArchPolicy("", "7/pxe=snp.efi,0/pxe=undionly-legacy.kpxe,0/ipxe=snp.efi", "bios", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Filenames(); len(got) != 2 || got[0] != "snp.efi" || got[1] != "undionly-legacy.kpxe" {
+		t.Fatalf("want the distinct filenames sorted, got %q", got)
+	}
+	var none *ArchPolicy
+	if got := none.Filenames(); got != nil {
+		t.Fatalf("want no filenames for a nil policy, got %q", got)
+	}
+}
+
+func TestArchPolicyNil(t *testing.T) {
+	var p *ArchPolicy
+	for arch, want := range map[int]string{0: "undionly.kpxe", 7: "ipxe.efi", 11: "snp.efi", 27: "ipxe.efi"} {
+		got, err := p.Binary(archPacket(arch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("arch %d: want %q, got %q", arch, want, got)
+		}
+	}
+}
+
+func TestNewArchPolicyInvalid(t *testing.T) {
+	tests := map[string]struct {
+		mapping   string
+		filenames string
+		unknown   string
+		bootfile  string
+	}{
+		"missing profile":           {mapping: "27", unknown: "bios"},
+		"invalid arch":              {mapping: "x=uefi", unknown: "bios"},
+		"arch overflow":             {mapping: "65536=uefi", unknown: "bios"},
+		"unknown profile":           {mapping: "27=riscv", unknown: "bios"},
+		"unknown policy":            {unknown: "guess"},
+		"bootfile missing":          {unknown: "bootfile"},
+		"bootfile without use":      {unknown: "deny", bootfile: "x.efi"},
+		"filename no firmware":      {filenames: "0=undionly.kpxe", unknown: "bios"},
+		"filename no filename":      {filenames: "0/pxe=", unknown: "bios"},
+		"filename invalid arch":     {filenames: "x/pxe=undionly.kpxe", unknown: "bios"},
+		"filename unknown firmware": {filenames: "0/uefi=ipxe.efi", unknown: "bios"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewArchPolicy(tt.mapping, tt.filenames, tt.unknown, tt.bootfile); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestIsArch(t *testing.T) {
+	for arch := 0; arch < len(procArchTypes); arch++ {
+		if name := Arch(archPacket(arch)); !IsArch(name) {
+			t.Fatalf("want %q, returned by Arch for arch %d, to be an arch", name, arch)
+		}
+	}
+	for _, name := range []string{"", "x86", "x64 UEFI", "ARM 64-bit UEFI", "arm64"} {
+		if IsArch(name) {
+			t.Fatalf("want %q not to be an arch Arch returns", name)
+		}
+	}
+}
+
+func TestFirmware(t *testing.T) {
+	tests := map[string]struct {
+		userClass string
+		encap     bool
+		want      string
+	}{
+		"pxe":              {want: FirmwarePXE},
+		"stock ipxe":       {userClass: "iPXE", want: FirmwareIPXE},
+		"ipxe options":     {userClass: "Custom", encap: true, want: FirmwareIPXE},
+		"other user class": {userClass: "Custom", want: FirmwarePXE},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := archPacket(0)
+			if tt.userClass != "" {
+				req.SetString(dhcp4.OptionUserClass, tt.userClass)
+			}
+			if tt.encap {
+				req.SetOption(ipxe.EncapsulatedOptions, dhcp4.OptionMap{ipxe.FeatureHTTP: {1}}.Serialize())
+			}
+			if got := Firmware(req); got != tt.want {
+				t.Fatalf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package dhcp
 
 import (
+	"net"
+
 	dhcp4 "github.com/packethost/dhcp4-go"
 	"github.com/pkg/errors"
 )
@@ -62,6 +64,49 @@ func (r *Offer) Send() error {
 	return errors.Wrap(r.w.WriteReply(&r.Offer), "failed to write OFFER")
 }
 
+// Nak is a DHCPNAK, telling a client that the address or lease it requested is not
+// valid so it restarts discovery.
+type Nak struct {
+	dhcp4.Nak
+	w dhcp4.ReplyWriter
+}
+
+// NewNak returns a DHCPNAK to req from serverID, message says why it is refused.
+// Unlike Ack and Offer option 82 is not copied, a DHCPNAK must not carry it.
+func NewNak(w dhcp4.ReplyWriter, req *dhcp4.Packet, serverID net.IP, message string) *Nak {
+	nak := dhcp4.CreateNak(req)
+	if v4 := serverID.To4(); v4 != nil {
+		nak.SetOption(dhcp4.OptionDHCPServerID, []byte(v4))
+	}
+	if message != "" {
+		nak.SetString(dhcp4.OptionDHCPMessage, message)
+	}
+
+	return &Nak{nak, w}
+}
+
+func (r *Nak) Packet() *dhcp4.Packet {
+	return &r.Nak.Packet
+}
+
+func (r *Nak) Send() error {
+	return errors.Wrap(r.w.WriteReply(&r.Nak), "failed to write NAK")
+}
+
+// RequestedIP returns the address a DHCPREQUEST asks for: the requested IP address
+// option (50) when selecting an offer or rebooting, or the ciaddr when renewing or
+// rebinding a lease. Nil if neither is set.
+func RequestedIP(req *dhcp4.Packet) net.IP {
+	if ip, ok := req.GetIP(dhcp4.OptionAddressRequest); ok && !ip.IsUnspecified() {
+		return ip
+	}
+	if ip := req.GetCIAddr(); ip != nil && !ip.IsUnspecified() {
+		return ip
+	}
+
+	return nil
+}
+
 func includeOption82(req *dhcp4.Packet, res dhcp4.OptionSetter) {
 	// check if option 82 exists
 	if opt82, ok := req.GetOption(dhcp4.OptionRelayAgentInformation); ok {
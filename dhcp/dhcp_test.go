@@ -0,0 +1,73 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+)
+
+func TestNewNak(t *testing.T) {
+	req := dhcp4.NewPacket(dhcp4.BootRequest)
+	req.SetMessageType(dhcp4.MessageTypeRequest)
+	req.SetIP(dhcp4.OptionAddressRequest, net.ParseIP("192.168.1.5"))
+	req.SetOption(dhcp4.OptionRelayAgentInformation, []byte{1, 2, 'a', 'b'})
+	w := &captureReplyWriter{}
+
+	nak := NewNak(w, &req, net.ParseIP("192.168.1.2"), "no lease for this client")
+	if err := nak.Send(); err != nil {
+		t.Fatal(err)
+	}
+	rep := w.reply.Reply()
+	if got := rep.GetMessageType(); got != dhcp4.MessageTypeNak {
+		t.Fatalf("want message type %v, got: %v", dhcp4.MessageTypeNak, got)
+	}
+	if got, _ := rep.GetIP(dhcp4.OptionDHCPServerID); !got.Equal(net.ParseIP("192.168.1.2")) {
+		t.Fatalf("want server identifier 192.168.1.2, got: %v", got)
+	}
+	if got, _ := rep.GetString(dhcp4.OptionDHCPMessage); got != "no lease for this client" {
+		t.Fatalf("want message, got: %q", got)
+	}
+	if _, ok := rep.GetOption(dhcp4.OptionRelayAgentInformation); ok {
+		t.Fatal("want no option 82")
+	}
+}
+
+func TestNewNakNoServerID(t *testing.T) {
+	req := dhcp4.NewPacket(dhcp4.BootRequest)
+	req.SetMessageType(dhcp4.MessageTypeRequest)
+
+	if err := NewNak(&captureReplyWriter{}, &req, nil, "").Send(); err == nil {
+		t.Fatal("want an error sending a NAK without a server identifier")
+	}
+}
+
+func TestRequestedIP(t *testing.T) {
+	tests := map[string]struct {
+		option net.IP
+		ciaddr net.IP
+		want   net.IP
+	}{
+		"none":             {},
+		"option 50":        {option: net.ParseIP("192.168.1.5"), want: net.ParseIP("192.168.1.5")},
+		"ciaddr":           {ciaddr: net.ParseIP("192.168.1.6"), want: net.ParseIP("192.168.1.6")},
+		"option 50 first":  {option: net.ParseIP("192.168.1.5"), ciaddr: net.ParseIP("192.168.1.6"), want: net.ParseIP("192.168.1.5")},
+		"zero option 50":   {option: net.IPv4zero, ciaddr: net.ParseIP("192.168.1.6"), want: net.ParseIP("192.168.1.6")},
+		"zero ciaddr only": {ciaddr: net.IPv4zero},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := dhcp4.NewPacket(dhcp4.BootRequest)
+			req.SetMessageType(dhcp4.MessageTypeRequest)
+			if tt.option != nil {
+				req.SetIP(dhcp4.OptionAddressRequest, tt.option)
+			}
+			if tt.ciaddr != nil {
+				copy(req.CIAddr(), tt.ciaddr.To4())
+			}
+			if got := RequestedIP(&req); !got.Equal(tt.want) {
+				t.Fatalf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package dhcp
+
+import (
+	"math"
+	"time"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+	"github.com/pkg/errors"
+)
+
+// maxLeaseTime is the longest time the 32 bit seconds of the DHCP lease options can hold.
+const maxLeaseTime = math.MaxUint32 * time.Second
+
+// LeaseTimes overrides the lease time of the hardware records and sets the renewal (T1)
+// and rebinding (T2) times of the leases offered. A nil LeaseTimes leaves the lease time
+// of the hardware records and the client's default renewal and rebinding times.
+type LeaseTimes struct {
+	lease     time.Duration
+	renewal   time.Duration
+	rebinding time.Duration
+}
+
+// NewLeaseTimes returns the lease times, the renewal and rebinding times are optional
+// and each 0 leaves it to the client. Returns nil if lease is 0.
+func NewLeaseTimes(lease, renewal, rebinding time.Duration) (*LeaseTimes, error) {
+	if lease < 0 || renewal < 0 || rebinding < 0 {
+		return nil, errors.New("dhcp lease, renewal and rebinding times must not be negative")
+	}
+	if lease == 0 {
+		if renewal > 0 || rebinding > 0 {
+			return nil, errors.New("dhcp renewal and rebinding times require a lease time")
+		}
+
+		return nil, nil
+	}
+	if lease < time.Second || lease > maxLeaseTime {
+		return nil, errors.Errorf("dhcp lease time must be between 1s and %s", maxLeaseTime)
+	}
+	if renewal >= lease || rebinding >= lease {
+		return nil, errors.New("dhcp renewal and rebinding times must be shorter than the lease time")
+	}
+	if renewal > 0 && rebinding > 0 && renewal >= rebinding {
+		return nil, errors.New("dhcp renewal time must be shorter than the rebinding time")
+	}
+
+	return &LeaseTimes{lease: lease, renewal: renewal, rebinding: rebinding}, nil
+}
+
+// Lease returns the lease time, 0 if l is nil.
+func (l *LeaseTimes) Lease() time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	return l.lease
+}
+
+// ApplyTo sets the lease times in c, replacing the lease time of the hardware record.
+func (l *LeaseTimes) ApplyTo(c *Config) {
+	if l == nil || c.opts == nil {
+		return
+	}
+	c.SetLeaseTime(l.lease)
+	if l.renewal > 0 {
+		c.opts.SetDuration(dhcp4.OptionRenewalTime, l.renewal)
+	}
+	if l.rebinding > 0 {
+		c.opts.SetDuration(dhcp4.OptionRebindingTime, l.rebinding)
+	}
+}
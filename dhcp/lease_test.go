@@ -0,0 +1,63 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+)
+
+func TestNewLeaseTimes(t *testing.T) {
+	tests := map[string]struct {
+		lease, renewal, rebinding time.Duration
+		err                       bool
+	}{
+		"lease":                  {lease: 10 * time.Minute},
+		"renewal and rebinding":  {lease: 10 * time.Minute, renewal: 5 * time.Minute, rebinding: 8 * time.Minute},
+		"renewal only":           {lease: 10 * time.Minute, renewal: 5 * time.Minute},
+		"no lease":               {},
+		"renewal without lease":  {renewal: 5 * time.Minute, err: true},
+		"negative":               {lease: -time.Minute, err: true},
+		"sub-second":             {lease: time.Millisecond, err: true},
+		"too long":               {lease: maxLeaseTime + time.Second, err: true},
+		"renewal after lease":    {lease: 10 * time.Minute, renewal: 10 * time.Minute, err: true},
+		"rebinding before renew": {lease: 10 * time.Minute, renewal: 8 * time.Minute, rebinding: 5 * time.Minute, err: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewLeaseTimes(tt.lease, tt.renewal, tt.rebinding)
+			if tt.err != (err != nil) {
+				t.Fatalf("want error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestLeaseTimesApplyTo(t *testing.T) {
+	l, err := NewLeaseTimes(10*time.Minute, 5*time.Minute, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Config{}
+	c.Setup(net.IPv4(192, 168, 1, 5), nil, nil)
+	c.SetLeaseTime(48 * time.Hour)
+	l.ApplyTo(c)
+
+	for o, want := range map[dhcp4.Option]time.Duration{dhcp4.OptionAddressTime: 10 * time.Minute, dhcp4.OptionRenewalTime: 5 * time.Minute} {
+		if got, ok := c.opts.GetDuration(o); !ok || got != want {
+			t.Fatalf("want option %d %s, got %s", o, want, got)
+		}
+	}
+	if _, ok := c.opts.GetOption(dhcp4.OptionRebindingTime); ok {
+		t.Fatal("want no rebinding time")
+	}
+
+	// without lease times the hardware record's lease time is kept
+	var none *LeaseTimes
+	c.SetLeaseTime(48 * time.Hour)
+	none.ApplyTo(c)
+	if got, _ := c.opts.GetDuration(dhcp4.OptionAddressTime); got != 48*time.Hour {
+		t.Fatalf("want the record's lease time, got %s", got)
+	}
+}
@@ -0,0 +1,259 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+	"github.com/pkg/errors"
+)
+
+// optionEncoder encodes a human friendly option value into its wire format.
+type optionEncoder func(string) ([]byte, error)
+
+// knownOptions are the DHCP options whose values can be given in a human friendly form.
+var knownOptions = map[uint8]optionEncoder{
+	1:   encodeIP,                     // subnet mask
+	3:   encodeIPList,                 // routers
+	6:   encodeIPList,                 // domain name servers
+	12:  encodeString,                 // hostname
+	15:  encodeString,                 // domain name
+	19:  encodeBool,                   // ip forwarding
+	20:  encodeBool,                   // non-local source routing
+	23:  encodeUint8,                  // default ip ttl
+	26:  encodeUint16,                 // interface mtu
+	27:  encodeBool,                   // all subnets are local
+	28:  encodeIP,                     // broadcast address
+	29:  encodeBool,                   // perform mask discovery
+	31:  encodeBool,                   // perform router discovery
+	42:  encodeIPList,                 // ntp servers
+	44:  encodeIPList,                 // netbios name servers
+	46:  encodeEnum(netbiosNodeTypes), // netbios node type
+	51:  encodeUint32,                 // lease time
+	60:  encodeString,                 // vendor class identifier
+	66:  encodeString,                 // tftp server name
+	67:  encodeString,                 // bootfile name
+	116: encodeBool,                   // auto-configure
+	119: encodeDomainList,             // domain search
+	150: encodeIPList,                 // tftp server addresses
+}
+
+// reservedOptions are the options hardware records cannot set, they frame the packet,
+// are managed by Boots, or are only sent by clients and relays.
+var reservedOptions = map[uint8]string{
+	0:   "pad",
+	50:  "requested ip address",
+	52:  "option overload",
+	53:  "message type",
+	54:  "server identifier",
+	55:  "parameter request list",
+	57:  "max message size",
+	61:  "client identifier",
+	82:  "relay agent information",
+	255: "end",
+}
+
+// RecordOptions encodes the DHCP options of a hardware record, a map of option number
+// to value, see EncodeOption. Options that are reserved, not a valid option number, or
+// whose value cannot be sent in a single option are left out and returned as errors,
+// ordered by option.
+func RecordOptions(opts map[string]string) (dhcp4.OptionMap, []error) {
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	out := dhcp4.OptionMap{}
+	for _, k := range keys {
+		code, err := strconv.ParseUint(k, 10, 8)
+		if err != nil {
+			errs = append(errs, errors.Errorf("invalid dhcp option number %q", k))
+
+			continue
+		}
+		if name, ok := reservedOptions[uint8(code)]; ok {
+			errs = append(errs, errors.Errorf("dhcp option %d (%s) is reserved", code, name))
+
+			continue
+		}
+		b, err := EncodeOption(uint8(code), opts[k])
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+		if len(b) > 255 {
+			errs = append(errs, errors.Errorf("dhcp option %d is %d bytes, longer than the 255 bytes of an option", code, len(b)))
+
+			continue
+		}
+		out[dhcp4.Option(code)] = b
+	}
+
+	return out, errs
+}
+
+// netbiosNodeTypes are the values of option 46, RFC 2132 section 8.7.
+var netbiosNodeTypes = map[string]uint8{
+	"b-node": 0x1,
+	"p-node": 0x2,
+	"m-node": 0x4,
+	"h-node": 0x8,
+}
+
+// EncodeOption encodes value for the DHCP option code. Values of known options are
+// given in a human friendly form, e.g. true/false, enum names, comma separated IPs
+// or domain names. Values prefixed with 0x are raw hex and accepted for any option.
+// The result may be longer than 255 bytes, in which case it must be split across
+// multiple instances of the option as described in RFC 3396.
+func EncodeOption(code uint8, value string) ([]byte, error) {
+	if strings.HasPrefix(value, "0x") {
+		b, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "decoding hex value for option %d", code)
+		}
+
+		return b, nil
+	}
+
+	enc, ok := knownOptions[code]
+	if !ok {
+		return nil, errors.Errorf("option %d has no known encoding, value must be 0x prefixed hex", code)
+	}
+	b, err := enc(value)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "encoding option %d", code)
+	}
+
+	return b, nil
+}
+
+func encodeBool(v string) ([]byte, error) {
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, errors.Errorf("invalid bool %q", v)
+	}
+	if b {
+		return []byte{1}, nil
+	}
+
+	return []byte{0}, nil
+}
+
+func encodeUint8(v string) ([]byte, error) {
+	n, err := strconv.ParseUint(v, 10, 8)
+	if err != nil {
+		return nil, errors.Errorf("invalid uint8 %q", v)
+	}
+
+	return []byte{uint8(n)}, nil
+}
+
+func encodeUint16(v string) ([]byte, error) {
+	n, err := strconv.ParseUint(v, 10, 16)
+	if err != nil {
+		return nil, errors.Errorf("invalid uint16 %q", v)
+	}
+
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, uint16(n))
+
+	return b, nil
+}
+
+func encodeUint32(v string) ([]byte, error) {
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return nil, errors.Errorf("invalid uint32 %q", v)
+	}
+
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(n))
+
+	return b, nil
+}
+
+func encodeEnum(values map[string]uint8) optionEncoder {
+	return func(v string) ([]byte, error) {
+		n, ok := values[strings.ToLower(v)]
+		if !ok {
+			return nil, errors.Errorf("invalid value %q", v)
+		}
+
+		return []byte{n}, nil
+	}
+}
+
+func encodeString(v string) ([]byte, error) {
+	if v == "" {
+		return nil, errors.New("empty string")
+	}
+
+	return []byte(v), nil
+}
+
+func encodeIP(v string) ([]byte, error) {
+	ip := net.ParseIP(strings.TrimSpace(v)).To4()
+	if ip == nil {
+		return nil, errors.Errorf("invalid IPv4 address %q", v)
+	}
+
+	return ip, nil
+}
+
+func encodeIPList(v string) ([]byte, error) {
+	var b []byte
+	for _, s := range strings.Split(v, ",") {
+		ip, err := encodeIP(s)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, ip...)
+	}
+
+	return b, nil
+}
+
+// encodeDomainList encodes a comma separated list of domain names using the
+// RFC 1035 section 4.1.4 compression scheme, as required by RFC 3397.
+func encodeDomainList(v string) ([]byte, error) {
+	var b []byte
+	// offsets of the already encoded domain suffixes, for compression pointers
+	offsets := map[string]int{}
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+		if name == "" {
+			return nil, errors.Errorf("empty domain name in %q", v)
+		}
+		labels := strings.Split(name, ".")
+		pointer := false
+		for i, label := range labels {
+			suffix := strings.Join(labels[i:], ".")
+			if off, ok := offsets[suffix]; ok {
+				b = append(b, 0xc0|byte(off>>8), byte(off))
+				pointer = true
+
+				break
+			}
+			if label == "" || len(label) > 63 {
+				return nil, errors.Errorf("invalid domain name %q", name)
+			}
+			// pointers are 14 bits, suffixes past that can not be referenced
+			if len(b) < 0x4000 {
+				offsets[suffix] = len(b)
+			}
+			b = append(append(b, byte(len(label))), label...)
+		}
+		if !pointer {
+			b = append(b, 0)
+		}
+	}
+
+	return b, nil
+}
@@ -0,0 +1,110 @@
+package dhcp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	dhcp4 "github.com/packethost/dhcp4-go"
+)
+
+func TestEncodeOption(t *testing.T) {
+	tests := map[string]struct {
+		code    uint8
+		value   string
+		want    []byte
+		wantErr bool
+	}{
+		"bool true":         {code: 19, value: "true", want: []byte{1}},
+		"bool false":        {code: 19, value: "false", want: []byte{0}},
+		"bool invalid":      {code: 19, value: "yes please", wantErr: true},
+		"uint8":             {code: 23, value: "64", want: []byte{64}},
+		"uint8 overflow":    {code: 23, value: "256", wantErr: true},
+		"uint16":            {code: 26, value: "9000", want: []byte{0x23, 0x28}},
+		"uint32":            {code: 51, value: "86400", want: []byte{0x00, 0x01, 0x51, 0x80}},
+		"enum":              {code: 46, value: "H-node", want: []byte{0x8}},
+		"enum invalid":      {code: 46, value: "x-node", wantErr: true},
+		"ip":                {code: 1, value: "255.255.255.0", want: []byte{255, 255, 255, 0}},
+		"ipv6 rejected":     {code: 1, value: "fd00::1", wantErr: true},
+		"ip list":           {code: 6, value: "1.1.1.1, 8.8.8.8", want: []byte{1, 1, 1, 1, 8, 8, 8, 8}},
+		"ip list invalid":   {code: 6, value: "1.1.1.1,nope", wantErr: true},
+		"string":            {code: 67, value: "ipxe.efi", want: []byte("ipxe.efi")},
+		"string empty":      {code: 67, value: "", wantErr: true},
+		"hex known":         {code: 19, value: "0x01", want: []byte{1}},
+		"hex unknown":       {code: 224, value: "0xdeadbeef", want: []byte{0xde, 0xad, 0xbe, 0xef}},
+		"hex invalid":       {code: 224, value: "0xzz", wantErr: true},
+		"unknown not hex":   {code: 224, value: "true", wantErr: true},
+		"domain single":     {code: 119, value: "example.com", want: []byte("\x07example\x03com\x00")},
+		"domain trailing .": {code: 119, value: "Example.COM.", want: []byte("\x07example\x03com\x00")},
+		"domain compressed": {
+			// RFC 3397 section 2 example
+			code:  119,
+			value: "eng.apple.com, marketing.apple.com",
+			want:  []byte("\x03eng\x05apple\x03com\x00\x09marketing\xc0\x04"),
+		},
+		"domain duplicate": {
+			code:  119,
+			value: "apple.com,apple.com",
+			want:  []byte("\x05apple\x03com\x00\xc0\x00"),
+		},
+		"domain partial overlap": {
+			code:  119,
+			value: "a.example.com,b.example.org,example.org",
+			want:  []byte("\x01a\x07example\x03com\x00\x01b\x07example\x03org\x00\xc0\x11"),
+		},
+		"domain empty":          {code: 119, value: "example.com,", wantErr: true},
+		"domain empty label":    {code: 119, value: "example..com", wantErr: true},
+		"domain label too long": {code: 119, value: "a123456789012345678901234567890123456789012345678901234567890123.com", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := EncodeOption(tt.code, tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %x", got)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestRecordOptions(t *testing.T) {
+	got, errs := RecordOptions(map[string]string{
+		"43":  "0x0104c0a80101",
+		"60":  "PXEClient",
+		"53":  "0x01",
+		"300": "0x01",
+		"ntp": "1.1.1.1",
+		"224": "true",
+		"225": "0x" + strings.Repeat("00", 256),
+	})
+	want := dhcp4.OptionMap{
+		43: {0x01, 0x04, 0xc0, 0xa8, 0x01, 0x01},
+		60: []byte("PXEClient"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	wantErrs := []string{
+		"option 224 has no known encoding, value must be 0x prefixed hex",
+		"dhcp option 225 is 256 bytes, longer than the 255 bytes of an option",
+		`invalid dhcp option number "300"`,
+		"dhcp option 53 (message type) is reserved",
+		`invalid dhcp option number "ntp"`,
+	}
+	if diff := cmp.Diff(wantErrs, msgs); diff != "" {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,81 @@
+package dhcp
+
+import (
+	"net"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+	"github.com/pkg/errors"
+)
+
+// proxyPacket is a ProxyDHCP reply packet, see the PXE specification section
+// 2.2.4. Unlike dhcp4.Offer and dhcp4.Ack it assigns no address, that is left to
+// the network's DHCP server, and only carries boot server information.
+type proxyPacket struct {
+	dhcp4.Packet
+
+	msg *dhcp4.Packet
+}
+
+// Validate implements dhcp4.Reply, a ProxyDHCP reply must identify its server and must not assign an address.
+func (p *proxyPacket) Validate() error {
+	if _, ok := p.GetOption(dhcp4.OptionDHCPServerID); !ok {
+		return errors.New("proxy dhcp reply has no server identifier")
+	}
+	if _, ok := p.GetOption(dhcp4.OptionAddressTime); ok {
+		return errors.New("proxy dhcp reply must not have a lease time")
+	}
+	if yiaddr := p.GetYIAddr(); yiaddr != nil && !yiaddr.Equal(net.IPv4zero) {
+		return errors.New("proxy dhcp reply must not assign an address")
+	}
+
+	return nil
+}
+
+func (p *proxyPacket) ToBytes() ([]byte, error) {
+	return dhcp4.PacketToBytes(p.Packet, nil)
+}
+
+func (p *proxyPacket) Message() *dhcp4.Packet {
+	return p.msg
+}
+
+func (p *proxyPacket) Reply() *dhcp4.Packet {
+	return &p.Packet
+}
+
+// ProxyReply is a ProxyDHCP reply, a DHCPOFFER to a PXE client's DHCPDISCOVER or a
+// DHCPACK to its DHCPREQUEST to port 4011.
+type ProxyReply struct {
+	proxyPacket
+	w dhcp4.ReplyWriter
+}
+
+// NewProxyReply returns a ProxyDHCP reply to req from serverID, nil if req is
+// neither a DHCPDISCOVER nor a DHCPREQUEST.
+func NewProxyReply(w dhcp4.ReplyWriter, req *dhcp4.Packet, serverID net.IP) *ProxyReply {
+	rep := proxyPacket{Packet: dhcp4.NewReply(req), msg: req}
+	switch req.GetMessageType() {
+	case dhcp4.MessageTypeDiscover:
+		rep.SetMessageType(dhcp4.MessageTypeOffer)
+	case dhcp4.MessageTypeRequest:
+		rep.SetMessageType(dhcp4.MessageTypeAck)
+	default:
+		return nil
+	}
+	// PXE clients only take boot server information from replies with a PXEClient class identifier
+	rep.SetString(dhcp4.OptionClassID, "PXEClient")
+	if v4 := serverID.To4(); v4 != nil {
+		rep.SetOption(dhcp4.OptionDHCPServerID, []byte(v4))
+	}
+	includeOption82(req, &rep)
+
+	return &ProxyReply{rep, w}
+}
+
+func (r *ProxyReply) Packet() *dhcp4.Packet {
+	return &r.proxyPacket.Packet
+}
+
+func (r *ProxyReply) Send() error {
+	return errors.Wrap(r.w.WriteReply(&r.proxyPacket), "failed to write proxy reply")
+}
@@ -0,0 +1,92 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+)
+
+// captureReplyWriter keeps the last reply written.
+type captureReplyWriter struct {
+	reply dhcp4.Reply
+}
+
+func (w *captureReplyWriter) WriteReply(r dhcp4.Reply) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	w.reply = r
+
+	return nil
+}
+
+func TestProxyReply(t *testing.T) {
+	serverID := net.ParseIP("192.168.1.2")
+	tests := map[string]struct {
+		msgType dhcp4.MessageType
+		want    dhcp4.MessageType
+		ignored bool
+	}{
+		"discover": {msgType: dhcp4.MessageTypeDiscover, want: dhcp4.MessageTypeOffer},
+		"request":  {msgType: dhcp4.MessageTypeRequest, want: dhcp4.MessageTypeAck},
+		"release":  {msgType: dhcp4.MessageTypeRelease, ignored: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := dhcp4.NewPacket(dhcp4.BootRequest)
+			req.SetMessageType(tt.msgType)
+			req.SetOption(dhcp4.OptionRelayAgentInformation, []byte{1, 2, 'a', 'b'})
+			w := &captureReplyWriter{}
+
+			rep := NewProxyReply(w, &req, serverID)
+			if tt.ignored {
+				if rep != nil {
+					t.Fatalf("want no reply to %v", tt.msgType)
+				}
+
+				return
+			}
+			if got := rep.Packet().GetMessageType(); got != tt.want {
+				t.Fatalf("want message type %v, got: %v", tt.want, got)
+			}
+			if got, _ := rep.Packet().GetString(dhcp4.OptionClassID); got != "PXEClient" {
+				t.Fatalf("want class identifier PXEClient, got: %q", got)
+			}
+			if _, ok := rep.Packet().GetOption(dhcp4.OptionRelayAgentInformation); !ok {
+				t.Fatal("want option 82 copied from the request")
+			}
+			if err := rep.Send(); err != nil {
+				t.Fatal(err)
+			}
+			if w.reply == nil {
+				t.Fatal("want the reply written")
+			}
+			if _, err := w.reply.ToBytes(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestProxyReplyValidate(t *testing.T) {
+	req := dhcp4.NewPacket(dhcp4.BootRequest)
+	req.SetMessageType(dhcp4.MessageTypeDiscover)
+
+	if err := NewProxyReply(nil, &req, nil).Validate(); err == nil {
+		t.Fatal("want an error without a server identifier")
+	}
+
+	rep := NewProxyReply(nil, &req, net.ParseIP("192.168.1.2"))
+	rep.SetDuration(dhcp4.OptionAddressTime, time.Hour)
+	if err := rep.Validate(); err == nil {
+		t.Fatal("want an error with a lease time")
+	}
+
+	rep = NewProxyReply(nil, &req, net.ParseIP("192.168.1.2"))
+	copy(rep.Packet().YIAddr(), net.ParseIP("192.168.1.10").To4())
+	if err := rep.Validate(); err == nil {
+		t.Fatal("want an error with an assigned address")
+	}
+}
@@ -70,6 +70,24 @@ func Arch(req *dhcp4.Packet) string {
 	}
 }
 
+// IsArch reports whether name is an arch Arch returns, x86_64, aarch64 or the name of an
+// option 93 value that is neither.
+func IsArch(name string) bool {
+	switch name {
+	case "x86_64", "aarch64":
+		return true
+	case "x86 BIOS", "x64 UEFI", "x64 uefi boot from http", "ARM 64-bit UEFI", "arm uefi 64 boot from http":
+		return false
+	}
+	for _, arch := range procArchTypes {
+		if arch == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 func IsARM(req *dhcp4.Packet) bool {
 	return strings.Contains(strings.ToLower(ProcessorArchType(req)), "arm")
 }
@@ -142,12 +160,14 @@ func SetupPXE(ctx context.Context, rep, req *dhcp4.Packet) bool {
 	return true
 }
 
-func SetFilename(rep *dhcp4.Packet, filename string, nextServer net.IP, httpClient bool, httpServerFQDN string) {
+// SetFilename sets the boot filename of rep. For HTTP clients filename is made a
+// scheme URL on httpServerFQDN.
+func SetFilename(rep *dhcp4.Packet, filename string, nextServer net.IP, httpClient bool, scheme, httpServerFQDN string) {
 	rep.SetSIAddr(nextServer.To4()) // next-server: IP address of the TFTP/HTTP Server.
 
 	if httpClient {
 		urlPath := path.Join(httpServerFQDN, filename)
-		filename = "http://" + urlPath
+		filename = scheme + "://" + urlPath
 		rep.SetString(dhcp4.OptionClassID, "HTTPClient")
 	}
 
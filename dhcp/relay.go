@@ -0,0 +1,87 @@
+package dhcp
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// The ways packets relayed from a giaddr outside of the configured relay subnets can be handled.
+const (
+	// UnmatchedRelayAllow handles the packet like any other, only counting and logging the unknown relay.
+	UnmatchedRelayAllow = "allow"
+	// UnmatchedRelayIgnore drops the packet.
+	UnmatchedRelayIgnore = "ignore"
+	// UnmatchedRelayDeny drops the packet and logs the unknown relay.
+	UnmatchedRelayDeny = "deny"
+)
+
+// RelayPolicy handles packets relayed from a giaddr that is in none of the
+// configured relay subnets. A nil RelayPolicy accepts every giaddr.
+type RelayPolicy struct {
+	subnets   []*net.IPNet
+	unmatched string
+}
+
+// NewRelayPolicy returns a policy for subnets, a comma separated list of CIDRs
+// relays are configured in, and unmatched, the handling of packets relayed from
+// any other giaddr. Returns nil if there are no subnets.
+func NewRelayPolicy(subnets, unmatched string) (*RelayPolicy, error) {
+	if unmatched != UnmatchedRelayAllow && unmatched != UnmatchedRelayIgnore && unmatched != UnmatchedRelayDeny {
+		return nil, errors.Errorf("invalid unmatched relay policy %q, must be %s, %s or %s", unmatched, UnmatchedRelayAllow, UnmatchedRelayIgnore, UnmatchedRelayDeny)
+	}
+	p := &RelayPolicy{unmatched: unmatched}
+	for _, s := range strings.Split(subnets, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid relay subnet %q", s)
+		}
+		p.subnets = append(p.subnets, n)
+	}
+	if len(p.subnets) == 0 {
+		return nil, nil
+	}
+
+	return p, nil
+}
+
+// matches reports whether giaddr is in one of the subnets.
+func (p *RelayPolicy) matches(giaddr net.IP) bool {
+	for _, n := range p.subnets {
+		if n.Contains(giaddr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allow reports whether a packet relayed from giaddr should be handled. Packets
+// that were not relayed, whose giaddr is unset, are always handled.
+func (p *RelayPolicy) Allow(giaddr net.IP, mac net.HardwareAddr) bool {
+	if p == nil || giaddr == nil || giaddr.IsUnspecified() || p.matches(giaddr) {
+		return true
+	}
+
+	metrics.DHCPUnmatchedRelay.WithLabelValues(p.unmatched).Inc()
+	l := dhcplog.With("giaddr", giaddr, "mac", mac, "policy", p.unmatched)
+	switch p.unmatched {
+	case UnmatchedRelayIgnore:
+		l.Debug("ignoring packet from a relay outside of the relay subnets")
+
+		return false
+	case UnmatchedRelayDeny:
+		l.Info("denying packet from a relay outside of the relay subnets, the relay's subnet may need to be configured")
+
+		return false
+	default:
+		l.Info("packet from a relay outside of the relay subnets, handling it like any other")
+
+		return true
+	}
+}
@@ -0,0 +1,61 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestRelayPolicy(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0xba, 0xdd, 0xbe, 0xef, 0x00}
+	tests := map[string]struct {
+		giaddr    string
+		unmatched string
+		want      bool
+		counted   bool
+	}{
+		"not relayed":       {giaddr: "0.0.0.0", unmatched: UnmatchedRelayDeny, want: true},
+		"first subnet":      {giaddr: "10.0.1.1", unmatched: UnmatchedRelayDeny, want: true},
+		"second subnet":     {giaddr: "192.168.7.254", unmatched: UnmatchedRelayDeny, want: true},
+		"network address":   {giaddr: "10.0.1.0", unmatched: UnmatchedRelayDeny, want: true},
+		"just outside":      {giaddr: "10.0.2.1", unmatched: UnmatchedRelayDeny, counted: true},
+		"unmatched ignored": {giaddr: "172.16.0.1", unmatched: UnmatchedRelayIgnore, counted: true},
+		"unmatched denied":  {giaddr: "172.16.0.2", unmatched: UnmatchedRelayDeny, counted: true},
+		"unmatched allowed": {giaddr: "172.16.0.3", unmatched: UnmatchedRelayAllow, want: true, counted: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewRelayPolicy("10.0.1.0/24, 192.168.0.0/16", tt.unmatched)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counter := metrics.DHCPUnmatchedRelay.WithLabelValues(tt.unmatched)
+			before := testutil.ToFloat64(counter)
+
+			if got := p.Allow(net.ParseIP(tt.giaddr), mac); got != tt.want {
+				t.Fatalf("want allowed: %t, got: %t", tt.want, got)
+			}
+			if counted := testutil.ToFloat64(counter) > before; counted != tt.counted {
+				t.Fatalf("want counted: %t, got: %t", tt.counted, counted)
+			}
+		})
+	}
+}
+
+func TestNewRelayPolicy(t *testing.T) {
+	p, err := NewRelayPolicy(" , ", UnmatchedRelayDeny)
+	if err != nil || p != nil {
+		t.Fatalf("want a nil policy without subnets, got: %v, %v", p, err)
+	}
+	if !p.Allow(net.ParseIP("172.16.0.1"), nil) {
+		t.Fatal("a nil policy must allow every giaddr")
+	}
+	if _, err := NewRelayPolicy("10.0.1.0/24,10.0.2.1", UnmatchedRelayDeny); err == nil {
+		t.Fatal("expected an error for a subnet that is not a CIDR")
+	}
+	if _, err := NewRelayPolicy("10.0.1.0/24", "drop"); err == nil {
+		t.Fatal("expected an error for an invalid policy")
+	}
+}
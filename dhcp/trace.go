@@ -0,0 +1,133 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+
+	dhcp4 "github.com/packethost/dhcp4-go"
+)
+
+// TracedOption is a DHCP option rendered for troubleshooting.
+type TracedOption struct {
+	Code uint8  `json:"code"`
+	Name string `json:"name"`
+	// Value is the decoded value, empty if the option has no known decoding
+	Value string `json:"value,omitempty"`
+	Hex   string `json:"hex"`
+}
+
+// String formats o as name(code)=value [hex].
+func (o TracedOption) String() string {
+	s := o.Name + "(" + strconv.Itoa(int(o.Code)) + ")="
+	if o.Value != "" {
+		s += o.Value + " "
+	}
+
+	return s + "[" + o.Hex + "]"
+}
+
+// TraceOptions returns every option in om, sorted by code, with its decoded value and hex bytes.
+func TraceOptions(om dhcp4.OptionMap) []TracedOption {
+	opts := make([]TracedOption, 0, len(om))
+	for _, o := range om.GetSortedOptions() {
+		code := uint8(o)
+		v := om[o]
+		t := TracedOption{Code: code, Name: "option-" + strconv.Itoa(int(code)), Hex: hex.EncodeToString(v)}
+		if d, ok := tracedOptions[code]; ok {
+			t.Name = d.name
+			if d.decode != nil {
+				t.Value = d.decode(v)
+			}
+		}
+		opts = append(opts, t)
+	}
+
+	return opts
+}
+
+// optionDecoder renders an option value, returning "" if it is malformed.
+type optionDecoder func([]byte) string
+
+type optionDescription struct {
+	name   string
+	decode optionDecoder
+}
+
+// tracedOptions are the options Boots sends, or commonly sees, with their names and decodings.
+// Encapsulated options, like 43 and 175, are only rendered as hex.
+var tracedOptions = map[uint8]optionDescription{
+	1:   {"subnet-mask", decodeIPList},
+	3:   {"routers", decodeIPList},
+	6:   {"domain-name-servers", decodeIPList},
+	12:  {"hostname", decodeString},
+	15:  {"domain-name", decodeString},
+	26:  {"interface-mtu", decodeUint},
+	28:  {"broadcast-address", decodeIPList},
+	42:  {"ntp-servers", decodeIPList},
+	43:  {"vendor-specific", nil},
+	51:  {"lease-time", decodeUint},
+	53:  {"message-type", decodeMessageType},
+	54:  {"server-identifier", decodeIPList},
+	55:  {"parameter-request-list", decodeCodeList},
+	58:  {"renewal-time", decodeUint},
+	59:  {"rebinding-time", decodeUint},
+	60:  {"vendor-class-identifier", decodeString},
+	61:  {"client-identifier", nil},
+	66:  {"tftp-server-name", decodeString},
+	67:  {"bootfile-name", decodeString},
+	82:  {"relay-agent-information", nil},
+	93:  {"client-system-architecture", decodeUint},
+	97:  {"client-machine-identifier", nil},
+	119: {"domain-search", nil},
+	150: {"tftp-server-addresses", decodeIPList},
+	175: {"ipxe-encapsulated", nil},
+}
+
+func decodeIPList(b []byte) string {
+	if len(b) == 0 || len(b)%4 != 0 {
+		return ""
+	}
+	ips := make([]string, 0, len(b)/4)
+	for i := 0; i < len(b); i += 4 {
+		ips = append(ips, net.IP(b[i:i+4]).String())
+	}
+
+	return strings.Join(ips, ",")
+}
+
+func decodeString(b []byte) string {
+	return strconv.Quote(string(b))
+}
+
+func decodeUint(b []byte) string {
+	switch len(b) {
+	case 1:
+		return strconv.Itoa(int(b[0]))
+	case 2:
+		return strconv.Itoa(int(binary.BigEndian.Uint16(b)))
+	case 4:
+		return strconv.FormatUint(uint64(binary.BigEndian.Uint32(b)), 10)
+	}
+
+	return ""
+}
+
+func decodeMessageType(b []byte) string {
+	if len(b) != 1 {
+		return ""
+	}
+
+	return dhcp4.MessageType(b[0]).String()
+}
+
+func decodeCodeList(b []byte) string {
+	codes := make([]string, 0, len(b))
+	for _, c := range b {
+		codes = append(codes, strconv.Itoa(int(c)))
+	}
+
+	return strings.Join(codes, ",")
+}
@@ -0,0 +1,43 @@
+package dhcp
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	dhcp4 "github.com/packethost/dhcp4-go"
+)
+
+func TestTraceOptions(t *testing.T) {
+	om := dhcp4.OptionMap{
+		dhcp4.OptionDHCPMsgType:    {byte(dhcp4.MessageTypeOffer)},
+		dhcp4.OptionSubnetMask:     {255, 255, 255, 0},
+		dhcp4.OptionDomainServer:   {1, 1, 1, 1, 8, 8, 8, 8},
+		dhcp4.OptionBootfileName:   []byte("ipxe.efi"),
+		dhcp4.OptionAddressTime:    {0x00, 0x01, 0x51, 0x80},
+		dhcp4.OptionVendorSpecific: {0x06, 0x01, 0x08},
+		dhcp4.Option(224):          {0xde, 0xad},
+		dhcp4.OptionRouter:         {10, 0, 0},
+		dhcp4.OptionParameterList:  {1, 3, 6},
+	}
+	want := []TracedOption{
+		{Code: 1, Name: "subnet-mask", Value: "255.255.255.0", Hex: "ffffff00"},
+		{Code: 3, Name: "routers", Hex: "0a0000"},
+		{Code: 6, Name: "domain-name-servers", Value: "1.1.1.1,8.8.8.8", Hex: "0101010108080808"},
+		{Code: 43, Name: "vendor-specific", Hex: "060108"},
+		{Code: 51, Name: "lease-time", Value: "86400", Hex: "00015180"},
+		{Code: 53, Name: "message-type", Value: "DHCPOFFER", Hex: "02"},
+		{Code: 55, Name: "parameter-request-list", Value: "1,3,6", Hex: "010306"},
+		{Code: 67, Name: "bootfile-name", Value: `"ipxe.efi"`, Hex: "697078652e656669"},
+		{Code: 224, Name: "option-224", Hex: "dead"},
+	}
+	got := TraceOptions(om)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+	if s := got[0].String(); s != "subnet-mask(1)=255.255.255.0 [ffffff00]" {
+		t.Fatalf("unexpected String(): %s", s)
+	}
+	if s := got[1].String(); s != "routers(3)=[0a0000]" {
+		t.Fatalf("unexpected String(): %s", s)
+	}
+}
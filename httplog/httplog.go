@@ -1,16 +1,94 @@
 package httplog
 
 import (
+	"context"
+	"encoding/json"
+	"io"
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
+// The access log formats.
+const (
+	// FormatText logs requests through the http package logger.
+	FormatText = "text"
+	// FormatJSON writes a JSON line per request to the handler's Output.
+	FormatJSON = "json"
+)
+
+// The optional fields of an access log line, the method and URI are always logged.
+const (
+	FieldRemoteAddr = "remote-addr"
+	FieldUserAgent  = "user-agent"
+	FieldDuration   = "duration"
+	FieldStatus     = "status"
+	FieldBytes      = "bytes"
+)
+
+// DefaultFields are the fields logged by a Handler without Fields.
+const DefaultFields = FieldRemoteAddr + "," + FieldDuration + "," + FieldStatus
+
+// Fields is the set of optional fields an access log line includes.
+type Fields map[string]bool
+
+// ParseFields parses a comma separated list of fields to log, the ones not listed are left out.
+func ParseFields(s string) (Fields, error) {
+	fields := Fields{}
+	for _, f := range strings.Split(s, ",") {
+		switch f = strings.TrimSpace(f); f {
+		case "":
+		case FieldRemoteAddr, FieldUserAgent, FieldDuration, FieldStatus, FieldBytes:
+			fields[f] = true
+		default:
+			return nil, errors.Errorf("unknown http log field %q, must be one of: %s, %s, %s, %s, %s", f, FieldRemoteAddr, FieldUserAgent, FieldDuration, FieldStatus, FieldBytes)
+		}
+	}
+
+	return fields, nil
+}
+
+// Handler logs the requests served by the wrapped Handler. The client address is
+// the request's RemoteAddr, so wrap Handler in the X-Forwarded-For handler for the
+// resolved client to be logged.
 type Handler struct {
 	http.Handler
+	// Format is FormatText or FormatJSON, empty is FormatText
+	Format string
+	// Output is where FormatJSON lines are written
+	Output io.Writer
+	// Fields are the optional fields logged, nil logs DefaultFields
+	Fields Fields
+
+	mu sync.Mutex
+}
+
+// bootIDKey is the context key of the boot ID of a request, see SetBootID.
+type bootIDKey struct{}
+
+// SetBootID sets the boot ID logged for the request whose context is ctx. It is a no-op
+// for requests not served by a Handler.
+func SetBootID(ctx context.Context, id string) {
+	if p, ok := ctx.Value(bootIDKey{}).(*string); ok {
+		*p = id
+	}
+}
+
+// accessLog is a FormatJSON access log line.
+type accessLog struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	URI       string    `json:"uri"`
+	Client    string    `json:"client,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Duration  *float64  `json:"duration,omitempty"`
+	Status    int       `json:"status,omitempty"`
+	Bytes     *int      `json:"bytes,omitempty"`
+	BootID    string    `json:"boot_id,omitempty"`
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -25,22 +103,88 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if uri == "/metrics" || strings.HasPrefix(uri, "/_packet") {
 		log = false
 	}
-	if log {
+	if log && h.Format != FormatJSON {
 		httplog.With("event", "sr", "method", method, "uri", uri, "client", client).Debug()
 	}
 
+	// the boot ID is set by the wrapped handler once it knows the client's boot
+	var bootID string
+	req = req.WithContext(context.WithValue(req.Context(), bootIDKey{}, &bootID))
 	res := &ResponseWriter{ResponseWriter: w}
 	h.Handler.ServeHTTP(res, req) // process the request
 	d := time.Since(start)
 
-	if log {
-		httplog.With("event", "ss", "method", method, "uri", uri, "client", client, "duration", d, "status", res.StatusCode).Info()
+	if !log {
+		return
+	}
+	fields := h.Fields
+	if fields == nil {
+		fields, _ = ParseFields(DefaultFields)
+	}
+	if h.Format == FormatJSON {
+		h.writeJSON(start, req, client, bootID, d, res, fields)
+
+		return
+	}
+	kvs := []interface{}{"event", "ss", "method", method, "uri", uri}
+	if fields[FieldRemoteAddr] {
+		kvs = append(kvs, "client", client)
+	}
+	if fields[FieldUserAgent] {
+		kvs = append(kvs, "user_agent", req.UserAgent())
+	}
+	if fields[FieldDuration] {
+		kvs = append(kvs, "duration", d)
+	}
+	if fields[FieldStatus] {
+		kvs = append(kvs, "status", res.StatusCode)
+	}
+	if fields[FieldBytes] {
+		kvs = append(kvs, "bytes", res.Bytes)
+	}
+	if bootID != "" {
+		kvs = append(kvs, "boot_id", bootID)
+	}
+	httplog.With(kvs...).Info()
+}
+
+func (h *Handler) writeJSON(start time.Time, req *http.Request, client, bootID string, d time.Duration, res *ResponseWriter, fields Fields) {
+	line := accessLog{Time: start.UTC(), Method: req.Method, URI: req.RequestURI, BootID: bootID}
+	if fields[FieldRemoteAddr] {
+		line.Client = client
+	}
+	if fields[FieldUserAgent] {
+		line.UserAgent = req.UserAgent()
+	}
+	if fields[FieldDuration] {
+		secs := d.Seconds()
+		line.Duration = &secs
+	}
+	if fields[FieldStatus] {
+		line.Status = res.StatusCode
+	}
+	if fields[FieldBytes] {
+		line.Bytes = &res.Bytes
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		httplog.Error(errors.Wrap(err, "marshal access log"))
+
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, err := h.Output.Write(append(b, '\n')); err != nil {
+		httplog.Error(errors.Wrap(err, "write access log"))
 	}
 }
 
 type ResponseWriter struct {
 	http.ResponseWriter
 	StatusCode int
+	// Bytes is the number of body bytes written
+	Bytes int
 }
 
 func (w *ResponseWriter) Write(b []byte) (int, error) {
@@ -48,6 +192,7 @@ func (w *ResponseWriter) Write(b []byte) (int, error) {
 		w.StatusCode = 200
 	}
 	n, err := w.ResponseWriter.Write(b)
+	w.Bytes += n
 
 	return n, errors.Wrap(err, "writing response")
 }
@@ -59,6 +204,13 @@ func (w *ResponseWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// Flush sends any buffered data to the client, if the wrapped ResponseWriter supports it.
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 type Transport struct {
 	http.RoundTripper
 }
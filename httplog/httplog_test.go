@@ -0,0 +1,124 @@
+package httplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/packethost/pkg/log"
+	"github.com/sebest/xff"
+)
+
+func TestHandlerJSON(t *testing.T) {
+	Init(log.Test(t, "httplog"))
+	tests := map[string]struct {
+		fields string
+		want   []string
+	}{
+		"default":    {fields: DefaultFields, want: []string{"time", "method", "uri", "client", "duration", "status"}},
+		"all":        {fields: "remote-addr,user-agent,duration,status,bytes", want: []string{"time", "method", "uri", "client", "user_agent", "duration", "status", "bytes"}},
+		"only bytes": {fields: "bytes", want: []string{"time", "method", "uri", "bytes"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fields, err := ParseFields(tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			h := &Handler{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte("not found"))
+				}),
+				Format: FormatJSON,
+				Output: &out,
+				Fields: fields,
+			}
+			req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+			req.Header.Set("User-Agent", "iPXE/1.21.1")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			var line map[string]interface{}
+			if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+				t.Fatalf("want a JSON line, got %q: %v", out.String(), err)
+			}
+			if len(line) != len(tt.want) {
+				t.Fatalf("want fields %v, got: %v", tt.want, line)
+			}
+			for _, f := range tt.want {
+				if _, ok := line[f]; !ok {
+					t.Fatalf("want field %q, got: %v", f, line)
+				}
+			}
+			if status, ok := line["status"]; ok && status != float64(http.StatusNotFound) {
+				t.Fatalf("want status 404, got: %v", status)
+			}
+			if n, ok := line["bytes"]; ok && n != float64(len("not found")) {
+				t.Fatalf("want 9 bytes, got: %v", n)
+			}
+		})
+	}
+}
+
+func TestHandlerForwardedClient(t *testing.T) {
+	Init(log.Test(t, "httplog"))
+	var out bytes.Buffer
+	xffmw, err := xff.New(xff.Options{AllowedSubnets: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := xffmw.Handler(&Handler{
+		Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		Format:  FormatJSON,
+		Output:  &out,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+	req.RemoteAddr = "10.1.2.3:5000"
+	req.Header.Set("X-Forwarded-For", "192.168.1.10")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line accessLog
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line.Client != "192.168.1.10" {
+		t.Fatalf("want the forwarded client logged, got: %q", line.Client)
+	}
+}
+
+func TestHandlerBootID(t *testing.T) {
+	Init(log.Test(t, "httplog"))
+	var out bytes.Buffer
+	h := &Handler{
+		Handler: http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+			SetBootID(req.Context(), "0123456789abcdef")
+		}),
+		Format: FormatJSON,
+		Output: &out,
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil))
+
+	var line accessLog
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line.BootID != "0123456789abcdef" {
+		t.Fatalf("want the boot ID logged, got: %q", line.BootID)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	fields, err := ParseFields(" status , bytes,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 || !fields[FieldStatus] || !fields[FieldBytes] {
+		t.Fatalf("want status and bytes, got: %v", fields)
+	}
+	if _, err := ParseFields("status,referer"); err == nil {
+		t.Fatal("want an error for an unknown field")
+	}
+}
@@ -0,0 +1,86 @@
+package installers
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// HostAllowList restricts the hosts that machines are directed to fetch boot
+// artifacts from. A nil HostAllowList allows every host.
+type HostAllowList struct {
+	hosts map[string]bool
+	// domains are the allowed domain suffixes, each with a leading dot
+	domains []string
+}
+
+// NewHostAllowList returns an allow-list of entries, each a hostname matched
+// exactly or a domain with a leading dot, e.g. .example.com, matching the domain
+// and all of its subdomains. Empty entries and # comments are ignored. Returns nil
+// if there are no entries, allowing every host.
+func NewHostAllowList(entries []string) (*HostAllowList, error) {
+	a := &HostAllowList{hosts: map[string]bool{}}
+	for _, e := range entries {
+		if i := strings.Index(e, "#"); i >= 0 {
+			e = e[:i]
+		}
+		e = strings.ToLower(strings.TrimSpace(e))
+		switch {
+		case e == "":
+			continue
+		case strings.ContainsAny(e, "/:*"):
+			return nil, errors.Errorf("invalid allowed host %q, must be a hostname or a .domain", e)
+		case strings.HasPrefix(e, "."):
+			if len(e) == 1 {
+				return nil, errors.Errorf("invalid allowed domain %q", e)
+			}
+			a.domains = append(a.domains, e)
+		default:
+			a.hosts[e] = true
+		}
+	}
+	if len(a.hosts) == 0 && len(a.domains) == 0 {
+		return nil, nil
+	}
+
+	return a, nil
+}
+
+// Check returns an error if rawURL is not an absolute URL whose host is allowed.
+func (a *HostAllowList) Check(rawURL string) error {
+	if a == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return errors.Errorf("url %q has no host", rawURL)
+	}
+	host := strings.ToLower(u.Hostname())
+	if a.hosts[host] {
+		return nil
+	}
+	for _, d := range a.domains {
+		if host == d[1:] || strings.HasSuffix(host, d) {
+			return nil
+		}
+	}
+
+	return errors.Errorf("host %q of url %q is not allowed", host, rawURL)
+}
+
+// DisallowedURL makes s print that the machine's boot artifacts are hosted on a
+// host that is not allowed and exit, instead of fetching them. It is counted unless j is
+// a dry run.
+func DisallowedURL(j job.Job, s *ipxe.Script, installer string, err error) {
+	if !j.DryRun {
+		metrics.DisallowedURLHost.WithLabelValues(installer).Inc()
+	}
+	j.With("installer", installer).Error(errors.WithMessage(err, "refusing to serve boot script"))
+	s.Echo("Boot artifacts for hardware " + j.HardwareID().String() + " are not on an allowed host")
+	s.Sleep(10)
+	s.AppendString("exit")
+}
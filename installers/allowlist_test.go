@@ -0,0 +1,47 @@
+package installers
+
+import (
+	"testing"
+)
+
+func TestHostAllowList(t *testing.T) {
+	a, err := NewHostAllowList([]string{"mirror.example.com", " .Internal.Example.org # all internal hosts", "", "10.0.0.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		"http://mirror.example.com/osie":              true,
+		"https://MIRROR.example.com:8443/osie":        true,
+		"http://internal.example.org/osie":            true,
+		"http://a.b.internal.example.org/osie":        true,
+		"http://10.0.0.5/osie":                        true,
+		"http://sub.mirror.example.com/osie":          false,
+		"http://evilinternal.example.org/osie":        false,
+		"http://mirror.example.com.evil.example/osie": false,
+		"/relative/path":                              false,
+		"http://10.0.0.6/osie":                        false,
+		"::not a url":                                 false,
+	}
+	for u, want := range tests {
+		if err := a.Check(u); (err == nil) != want {
+			t.Errorf("%s: want allowed %v, got error %v", u, want, err)
+		}
+	}
+
+	var none *HostAllowList
+	if err := none.Check("http://anywhere.example/osie"); err != nil {
+		t.Fatalf("nil allow-list should allow all hosts, got %v", err)
+	}
+}
+
+func TestNewHostAllowList(t *testing.T) {
+	a, err := NewHostAllowList([]string{"", "# nothing here"})
+	if err != nil || a != nil {
+		t.Fatalf("want a nil allow-list without entries, got %v, %v", a, err)
+	}
+	for _, e := range []string{"http://mirror.example.com", "*.example.com", ".", "mirror.example.com:80"} {
+		if _, err := NewHostAllowList([]string{e}); err == nil {
+			t.Errorf("%q: expected an error", e)
+		}
+	}
+}
@@ -6,19 +6,50 @@ import (
 
 	"github.com/packethost/pkg/log"
 	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/installers"
 	"github.com/tinkerbell/boots/ipxe"
 	"github.com/tinkerbell/boots/job"
 )
 
 type installer struct {
 	extraIPXEVars [][]string
+	// allowedHosts are the hosts scripts may be chained from
+	allowedHosts *installers.HostAllowList
+	// kernelArgs and extraKernelArgs are the installer's default and the global kernel args
+	kernelArgs      string
+	extraKernelArgs installers.KernelArgs
+}
+
+// Option configures optional installer behavior.
+type Option func(*installer)
+
+// WithAllowedHosts makes the installer refuse to chain iPXE script URLs that are
+// not on an allowed host. Inline scripts are served as is.
+func WithAllowedHosts(a *installers.HostAllowList) Option {
+	return func(i *installer) {
+		i.allowedHosts = a
+	}
+}
+
+// WithKernelArgs sets the kernel_args iPXE variable, for custom scripts to pass to the
+// kernels they boot, to the merge of the installer's default args, the global extra
+// args and the hardware record's args, see installers.MergeKernelArgs. It is not set
+// if there are none.
+func WithKernelArgs(defaults string, extra installers.KernelArgs) Option {
+	return func(i *installer) {
+		i.kernelArgs = defaults
+		i.extraKernelArgs = extra
+	}
 }
 
 // pass vars here.
-func Installer(dynamicIPXEVars [][]string) job.BootScripter {
+func Installer(dynamicIPXEVars [][]string, opts ...Option) job.BootScripter {
 	i := installer{
 		extraIPXEVars: dynamicIPXEVars,
 	}
+	for _, opt := range opts {
+		opt(&i)
+	}
 
 	return i
 }
@@ -56,6 +87,16 @@ func (i installer) setBootScript(_ context.Context, j job.Job, s *ipxe.Script) {
 	for _, kv := range i.extraIPXEVars {
 		s.Set(kv[0], kv[1])
 	}
+	if args := installers.MergeKernelArgs(i.kernelArgs, i.extraKernelArgs.Expand(j), j.KernelArgs()); args != "" {
+		s.Set("kernel_args", args)
+	}
+	if cfg.Chain != "" {
+		if err := i.allowedHosts.Check(cfg.Chain); err != nil {
+			installers.DisallowedURL(j, s, "custom_ipxe", err)
+
+			return
+		}
+	}
 	ipxeScriptFromConfig(logger, cfg, j, s)
 }
 
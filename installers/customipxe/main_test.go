@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
 
 	l "github.com/packethost/pkg/log"
@@ -12,6 +13,7 @@ import (
 	"github.com/tinkerbell/boots/installers"
 	"github.com/tinkerbell/boots/ipxe"
 	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
 )
 
 var testLogger l.Logger
@@ -20,6 +22,7 @@ func TestMain(m *testing.M) {
 	logger, _ := l.Init("github.com/tinkerbell/boots")
 	job.Init(logger)
 	installers.Init(logger)
+	metrics.Init(logger)
 	testLogger = logger
 	os.Exit(m.Run())
 }
@@ -288,3 +291,88 @@ var dedentRegexp = regexp.MustCompile(`(?m)^[^\S\n]+`)
 func dedent(s string) string {
 	return dedentRegexp.ReplaceAllString(s, "")
 }
+
+func TestIpxeScriptAllowedHosts(t *testing.T) {
+	allowed, err := installers.NewHostAllowList([]string{"url"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name  string
+		chain string
+		want  string
+	}{
+		{
+			"allowed chain",
+			"http://url/path.ipxe",
+			`#!ipxe
+
+			echo Tinkerbell Boots iPXE
+
+			params
+			param body Device connected to DHCP system
+			param type provisioning.104.01
+			imgfetch ${tinkerbell}/phone-home##params
+			imgfree
+
+			set packet_facility test.facility
+			set packet_plan test.slug
+			chain --autofree http://url/path.ipxe
+			`,
+		},
+		{
+			"denied chain",
+			"http://elsewhere/path.ipxe",
+			`#!ipxe
+
+			echo Tinkerbell Boots iPXE
+			echo Boot artifacts for hardware $hardware_id are not on an allowed host
+			sleep 10
+			exit
+			`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockJob := job.NewMock(t, "test.slug", "test.facility")
+			mockJob.SetIPXEScriptURL(tc.chain)
+			s := ipxe.NewScript()
+			Installer(nil, WithAllowedHosts(allowed)).BootScript("")(context.Background(), mockJob.Job(), s)
+
+			want := strings.ReplaceAll(dedent(tc.want), "$hardware_id", mockJob.Job().HardwareID().String())
+			require.Equal(t, want, string(s.Bytes()))
+		})
+	}
+}
+
+func TestIpxeScriptKernelArgs(t *testing.T) {
+	tests := map[string]struct {
+		defaults string
+		extra    string
+		hardware string
+		want     string
+	}{
+		"none":               {},
+		"defaults":           {defaults: "a=1", want: "set kernel_args a=1\n"},
+		"global overrides":   {defaults: "a=1 b=1", extra: "b=2", want: "set kernel_args a=1 b=2\n"},
+		"hardware overrides": {defaults: "a=1", extra: "a=2", hardware: "a=3", want: "set kernel_args a=3\n"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockJob := job.NewMock(t, "test.slug", "test.facility")
+			mockJob.SetIPXEScriptURL("http://url/path.ipxe")
+			mockJob.SetKernelArgs(tt.hardware)
+			s := ipxe.NewScript()
+			Installer(nil, WithKernelArgs(tt.defaults, installers.NewKernelArgs(tt.extra))).BootScript("")(context.Background(), mockJob.Job(), s)
+			got := string(s.Bytes())
+
+			if tt.want == "" {
+				require.NotContains(t, got, "kernel_args")
+
+				return
+			}
+			require.Contains(t, got, tt.want)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Package ipxetemplate serves boot scripts rendered from iPXE script templates, each a
+// Go text/template registered for the hardware of a facility.
+package ipxetemplate
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/installers"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/job"
+)
+
+// Ext is the extension of the template files in a template directory.
+const Ext = ".ipxe.tmpl"
+
+// Data is what templates are executed with.
+type Data struct {
+	// MAC is the MAC of the machine's first interface
+	MAC string
+	// Arch is the arch the machine boots as, see job.Job.BootArch
+	Arch       string
+	HardwareID string
+	Facility   string
+	// KernelArgs is the merge of the global extra kernel args and the hardware record's args
+	KernelArgs string
+	// IPXEVars are the -ipxe-vars variables by name, they are also set in the script
+	IPXEVars map[string]string
+	// Tinkerbell is the base URL of Boots
+	Tinkerbell string
+	SyslogHost string
+}
+
+// Load parses the templates in dir, returning them by name, their file name without
+// Ext. Every template is executed once with empty data so that references to unknown
+// fields fail here instead of when machines boot.
+func Load(dir string) (map[string]*template.Template, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read ipxe template dir")
+	}
+	templates := map[string]*template.Template{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), Ext) {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), Ext)
+		t, err := template.New(e.Name()).Option("missingkey=zero").ParseFiles(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse ipxe template %s", e.Name())
+		}
+		if err := t.Execute(io.Discard, Data{}); err != nil {
+			return nil, errors.Wrapf(err, "check ipxe template %s", e.Name())
+		}
+		templates[name] = t
+	}
+	if len(templates) == 0 {
+		return nil, errors.Errorf("no *%s templates in ipxe template dir %s", Ext, dir)
+	}
+
+	return templates, nil
+}
+
+type installer struct {
+	tmpl            *template.Template
+	extraIPXEVars   [][]string
+	extraKernelArgs installers.KernelArgs
+}
+
+// Installer returns an installer rendering tmpl. extraIPXEVars are set in the script
+// before the template and extraKernelArgs are merged with the hardware record's args.
+func Installer(tmpl *template.Template, extraIPXEVars [][]string, extraKernelArgs installers.KernelArgs) job.BootScripter {
+	return installer{tmpl: tmpl, extraIPXEVars: extraIPXEVars, extraKernelArgs: extraKernelArgs}
+}
+
+func (i installer) BootScript(string) job.BootScript {
+	return i.bootScript
+}
+
+func (i installer) bootScript(_ context.Context, j job.Job, s *ipxe.Script) {
+	vars := make(map[string]string, len(i.extraIPXEVars))
+	for _, kv := range i.extraIPXEVars {
+		s.Set(kv[0], kv[1])
+		vars[kv[0]] = kv[1]
+	}
+	data := Data{
+		MAC:        j.PrimaryNIC().String(),
+		Arch:       j.BootArch(),
+		HardwareID: j.HardwareID().String(),
+		Facility:   j.FacilityCode(),
+		KernelArgs: installers.MergeKernelArgs(i.extraKernelArgs.Expand(j), j.KernelArgs()),
+		IPXEVars:   vars,
+		Tinkerbell: j.BootsURL(),
+		SyslogHost: conf.PublicSyslogFQDN,
+	}
+	var b bytes.Buffer
+	if err := i.tmpl.Execute(&b, data); err != nil {
+		j.With("template", i.tmpl.Name()).Error(errors.Wrap(err, "execute ipxe template"))
+		s.Echo("Boot script template " + i.tmpl.Name() + " failed")
+		s.Shell()
+
+		return
+	}
+	s.AppendString(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(b.String()), "#!ipxe")))
+}
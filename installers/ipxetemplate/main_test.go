@@ -0,0 +1,109 @@
+package ipxetemplate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinkerbell/boots/installers"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/job"
+)
+
+func writeTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	tests := map[string]struct {
+		files map[string]string
+		want  []string
+		err   string
+	}{
+		"templates": {
+			files: map[string]string{
+				"ewr1" + Ext: "chain http://example.com/{{.MAC}}",
+				"sjc1" + Ext: "#!ipxe\nexit",
+				"README.md":  "not a template",
+			},
+			want: []string{"ewr1", "sjc1"},
+		},
+		"parse error": {
+			files: map[string]string{"ewr1" + Ext: "{{.MAC"},
+			err:   "parse ipxe template ewr1" + Ext,
+		},
+		"unknown field": {
+			files: map[string]string{"ewr1" + Ext: "{{.Plan}}"},
+			err:   "check ipxe template ewr1" + Ext,
+		},
+		"no templates": {
+			files: map[string]string{"README.md": "not a template"},
+			err:   "no *" + Ext + " templates",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Load(writeTemplates(t, tt.files))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("want error containing %q, got %v", tt.err, err)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("want templates %v, got %v", tt.want, got)
+			}
+			for _, name := range tt.want {
+				if got[name] == nil {
+					t.Fatalf("missing template %q", name)
+				}
+			}
+		})
+	}
+}
+
+func TestScript(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"ewr1" + Ext: `#!ipxe
+echo {{.Facility}} {{.HardwareID}}
+kernel http://example.com/{{.Arch}}/vmlinuz mac={{.MAC}} {{.KernelArgs}} var={{index .IPXEVars "var1"}}
+boot
+`,
+	})
+	templates, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := job.NewMock(t, "c3.small.x86", "ewr1")
+	s := ipxe.NewScript()
+	Installer(templates["ewr1"], [][]string{{"var1", "val1"}}, installers.NewKernelArgs("console=ttyS0")).BootScript("")(context.Background(), m.Job(), s)
+	got := string(s.Bytes())
+
+	j := m.Job()
+	want := `#!ipxe
+
+echo Tinkerbell Boots iPXE
+set var1 val1
+echo ewr1 ` + j.HardwareID().String() + `
+kernel http://example.com/x86_64/vmlinuz mac=` + j.PrimaryNIC().String() + ` console=ttyS0 var=val1
+boot
+`
+	if got != want {
+		t.Fatalf("bad iPXE script, want:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,166 @@
+package installers
+
+import (
+	"io"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/job"
+)
+
+// MergeKernelArgs joins layers of space separated kernel args, ordered from least to
+// most specific: the installer's defaults, the global -extra-kernel-args and then the
+// hardware record's args. An arg whose key, the part before any =, is used in a later
+// layer is replaced by all the args with that key in the later layer, in the position
+// of its first use. Args repeating a key in the same layer, e.g. console=tty0
+// console=ttyS0, are all kept in their order. Spaces in double quoted values, e.g.
+// dyndbg="file x.c +p", do not separate args.
+func MergeKernelArgs(layers ...string) string {
+	var keys []string
+	args := map[string][]string{}
+	for _, layer := range layers {
+		inLayer := map[string]bool{}
+		for _, arg := range splitKernelArgs(layer) {
+			key, _, _ := strings.Cut(arg, "=")
+			if _, ok := args[key]; !ok {
+				keys = append(keys, key)
+			}
+			if !inLayer[key] {
+				inLayer[key] = true
+				args[key] = nil
+			}
+			args[key] = append(args[key], arg)
+		}
+	}
+
+	var merged []string
+	for _, key := range keys {
+		merged = append(merged, args[key]...)
+	}
+
+	return strings.Join(merged, " ")
+}
+
+// splitKernelArgs splits s into args at whitespace outside of double quotes.
+func splitKernelArgs(s string) []string {
+	var args []string
+	var arg strings.Builder
+	quoted := false
+	for _, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case !quoted && unicode.IsSpace(c):
+			if arg.Len() > 0 {
+				args = append(args, arg.String())
+				arg.Reset()
+			}
+
+			continue
+		}
+		arg.WriteRune(c)
+	}
+	if arg.Len() > 0 {
+		args = append(args, arg.String())
+	}
+
+	return args
+}
+
+// KernelArgsData is what templated kernel args are expanded with, see KernelArgs.Expand.
+type KernelArgsData struct {
+	MAC        string
+	Hostname   string
+	IP         string
+	HardwareID string
+	Facility   string
+	Plan       string
+	// Arch is the arch the machine boots as, see job.Job.BootArch
+	Arch string
+}
+
+// ParseKernelArgs parses args as a Go text/template, returning nil if it has no
+// template actions. It is executed once with empty data so that references to
+// fields that are not in KernelArgsData fail here instead of when machines boot.
+func ParseKernelArgs(args string) (*template.Template, error) {
+	if !strings.Contains(args, "{{") {
+		return nil, nil
+	}
+	t, err := template.New("kernel-args").Option("missingkey=error").Parse(args)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse kernel args template")
+	}
+	if err := t.Execute(io.Discard, KernelArgsData{}); err != nil {
+		return nil, errors.Wrap(err, "check kernel args template")
+	}
+
+	return t, nil
+}
+
+// KernelArgs are layers of kernel args that may contain template actions, parsed once
+// when an installer is registered rather than for every boot script. The zero value
+// has no args.
+type KernelArgs struct {
+	layers []kernelArgsLayer
+}
+
+type kernelArgsLayer struct {
+	args string
+	tmpl *template.Template
+	err  error
+}
+
+// NewKernelArgs parses layers, ordered from least to most specific like MergeKernelArgs,
+// see ParseKernelArgs. Layers that fail to parse are dropped by Expand, they are meant
+// to be refused at startup.
+func NewKernelArgs(layers ...string) KernelArgs {
+	var k KernelArgs
+	for _, args := range layers {
+		t, err := ParseKernelArgs(args)
+		k.layers = append(k.layers, kernelArgsLayer{args: args, tmpl: t, err: err})
+	}
+
+	return k
+}
+
+// Expand expands the template actions in the layers of k against j's hardware record
+// and merges them, see MergeKernelArgs. Args without any are used as is. A layer that
+// cannot be expanded is logged and dropped, rather than passing the template to the
+// kernel.
+func (k KernelArgs) Expand(j job.Job) string {
+	layers := make([]string, len(k.layers))
+	for i, l := range k.layers {
+		layers[i] = l.expand(j)
+	}
+
+	return MergeKernelArgs(layers...)
+}
+
+func (l kernelArgsLayer) expand(j job.Job) string {
+	t, err := l.tmpl, l.err
+	if t == nil && err == nil {
+		return l.args
+	}
+	if err == nil {
+		data := KernelArgsData{
+			MAC:        j.PrimaryNIC().String(),
+			Hostname:   j.Hostname(),
+			HardwareID: j.HardwareID().String(),
+			Facility:   j.FacilityCode(),
+			Plan:       j.PlanSlug(),
+			Arch:       j.BootArch(),
+		}
+		if ip := j.IP(); ip != nil {
+			data.IP = ip.String()
+		}
+		var b strings.Builder
+		if err = t.Execute(&b, data); err == nil {
+			return b.String()
+		}
+	}
+	j.With("args", l.args).Error(errors.WithMessage(err, "dropping kernel args"))
+
+	return ""
+}
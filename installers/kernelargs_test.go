@@ -0,0 +1,101 @@
+package installers
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/tinkerbell/boots/job"
+)
+
+func TestMergeKernelArgs(t *testing.T) {
+	tests := map[string]struct {
+		layers []string
+		want   string
+	}{
+		"none":                    {want: ""},
+		"empty layers":            {layers: []string{"", " ", ""}, want: ""},
+		"installer only":          {layers: []string{"a=1 quiet", "", ""}, want: "a=1 quiet"},
+		"layers in order":         {layers: []string{"a=1", "b=2", "c=3"}, want: "a=1 b=2 c=3"},
+		"global overrides":        {layers: []string{"a=1 b=1", "a=2", ""}, want: "a=2 b=1"},
+		"hardware overrides":      {layers: []string{"a=1", "a=2", "a=3"}, want: "a=3"},
+		"hardware over installer": {layers: []string{"a=1 b=1", "", "b=3"}, want: "a=1 b=3"},
+		"within a layer":          {layers: []string{"a=1 a=2"}, want: "a=1 a=2"},
+		"dual console":            {layers: []string{"console=tty0 quiet console=ttyS0,115200"}, want: "console=tty0 console=ttyS0,115200 quiet"},
+		"repeated key overridden": {layers: []string{"console=tty0 console=ttyS0", "console=ttyS1"}, want: "console=ttyS1"},
+		"repeated key overrides":  {layers: []string{"console=tty0 quiet", "console=ttyS0 console=ttyS1"}, want: "console=ttyS0 console=ttyS1 quiet"},
+		"quoted value":            {layers: []string{`dyndbg="file x.c +p" quiet`, `a="b c"`}, want: `dyndbg="file x.c +p" quiet a="b c"`},
+		"quoted override":         {layers: []string{`a="b c" d=1`, `a="e f"`}, want: `a="e f" d=1`},
+		"flags dedupe":            {layers: []string{"quiet", "quiet"}, want: "quiet"},
+		"flag vs value":           {layers: []string{"nomodeset", "nomodeset=0"}, want: "nomodeset=0"},
+		"extra whitespace":        {layers: []string{"  a=1\tb=2 ", "\nc=3"}, want: "a=1 b=2 c=3"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := MergeKernelArgs(tt.layers...); got != tt.want {
+				t.Fatalf("want: %q, got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseKernelArgs(t *testing.T) {
+	tests := map[string]struct {
+		args     string
+		template bool
+		err      string
+	}{
+		"empty":          {args: ""},
+		"literal":        {args: "console=ttyS1 quiet"},
+		"literal braces": {args: "a={b}"},
+		"template":       {args: "hostname={{.Hostname}} mac={{ .MAC }}", template: true},
+		"malformed":      {args: "hostname={{.Hostname", err: "parse kernel args template"},
+		"unknown field":  {args: "console={{.Console}}", err: "check kernel args template"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseKernelArgs(tt.args)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("want error containing %q, got: %v", tt.err, err)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got != nil) != tt.template {
+				t.Fatalf("want template %t, got %v", tt.template, got)
+			}
+		})
+	}
+}
+
+func TestKernelArgsExpand(t *testing.T) {
+	m := job.NewMock(t, "c3.small.x86", "ewr1")
+	m.SetMAC("00:00:ba:dd:be:ef")
+	m.SetDHCP(net.ParseIP("192.168.1.5"), "node-1")
+	j := m.Job()
+
+	tests := map[string]struct {
+		layers []string
+		want   string
+	}{
+		"none":            {want: ""},
+		"literal":         {layers: []string{"console=ttyS1 quiet"}, want: "console=ttyS1 quiet"},
+		"template":        {layers: []string{"hostname={{.Hostname}} mac={{ .MAC }} console=ttyS1"}, want: "hostname=node-1 mac=00:00:ba:dd:be:ef console=ttyS1"},
+		"fields":          {layers: []string{"f={{.Facility}} p={{.Plan}} a={{.Arch}} id={{.HardwareID}}"}, want: "f=ewr1 p=c3.small.x86 a=x86_64 id=" + j.HardwareID().String()},
+		"ip":              {layers: []string{"ip={{.IP}}::192.168.1.1"}, want: "ip=192.168.1.5::192.168.1.1"},
+		"malformed":       {layers: []string{"hostname={{.Hostname"}, want: ""},
+		"layers":          {layers: []string{"console=tty0 console=ttyS0 quiet", "hostname={{.Hostname}} console=ttyS1"}, want: "console=ttyS1 quiet hostname=node-1"},
+		"malformed layer": {layers: []string{"quiet", "hostname={{.Hostname"}, want: "quiet"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := NewKernelArgs(tt.layers...).Expand(j); got != tt.want {
+				t.Fatalf("want: %q, got: %q", tt.want, got)
+			}
+		})
+	}
+}
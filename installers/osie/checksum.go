@@ -0,0 +1,205 @@
+package osie
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/installers"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// checksumFetchTimeout bounds the download of an image being verified.
+const checksumFetchTimeout = 10 * time.Minute
+
+// LoadChecksums reads a manifest of OSIE/Hook image SHA-256 checksums in the sha256sum
+// format, a "<hex checksum>  <file name>" line per image, by the file name relative to
+// the OSIE base URL. Blank lines and lines starting with # are skipped.
+func LoadChecksums(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open osie checksums")
+	}
+	defer f.Close()
+
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		// sha256sum marks files read in binary mode with a *
+		name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+		if b, err := hex.DecodeString(sum); !ok || name == "" || err != nil || len(b) != sha256.Size {
+			return nil, errors.Errorf("invalid osie checksums line %d, must be a sha256 checksum followed by a file name", n)
+		}
+		sums[name] = strings.ToLower(sum)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read osie checksums")
+	}
+	if len(sums) == 0 {
+		return nil, errors.Errorf("no checksums in %s", path)
+	}
+
+	return sums, nil
+}
+
+// Verifier verifies the OSIE/Hook images served against their checksums. An image is
+// downloaded and verified the first time a boot script references it, which waits for
+// the result, and is verified again in the background once the result is older than
+// the interval, or on the next boot if it failed so a mirror that recovers is used again.
+type Verifier struct {
+	sums     map[string]string
+	interval time.Duration
+	client   *http.Client
+	now      func() time.Time
+
+	mu sync.Mutex
+	// results are the verifications of each image URL
+	results map[string]*verification
+}
+
+// verification is the result of verifying an image, done is closed once there is one.
+type verification struct {
+	done       chan struct{}
+	err        error
+	checked    time.Time
+	refreshing bool
+}
+
+// NewVerifier returns a verifier of the images in sums, see LoadChecksums, that
+// re-verifies them every interval. Returns nil if sums is empty.
+func NewVerifier(sums map[string]string, interval time.Duration) (*Verifier, error) {
+	if len(sums) == 0 {
+		return nil, nil
+	}
+	if interval <= 0 {
+		return nil, errors.New("-osie-checksum-interval must be greater than 0")
+	}
+
+	return &Verifier{
+		sums:     sums,
+		interval: interval,
+		client:   &http.Client{Timeout: checksumFetchTimeout},
+		now:      time.Now,
+		results:  map[string]*verification{},
+	}, nil
+}
+
+// Check returns an error if the image name under baseURL failed verification. Images
+// without a checksum are not verified.
+func (v *Verifier) Check(ctx context.Context, baseURL, name string) error {
+	if v == nil {
+		return nil
+	}
+	want, ok := v.sums[name]
+	if !ok {
+		return nil
+	}
+	url := strings.TrimSuffix(baseURL, "/") + "/" + name
+
+	v.mu.Lock()
+	r, ok := v.results[url]
+	switch {
+	case !ok:
+		r = &verification{done: make(chan struct{})}
+		v.results[url] = r
+		go func() {
+			err := v.verify(url, want)
+			v.mu.Lock()
+			r.err, r.checked = err, v.now()
+			v.mu.Unlock()
+			close(r.done)
+		}()
+	case !r.checked.IsZero() && !r.refreshing && (r.err != nil || v.now().Sub(r.checked) >= v.interval):
+		r.refreshing = true
+		go func() {
+			err := v.verify(url, want)
+			v.mu.Lock()
+			r.err, r.checked, r.refreshing = err, v.now(), false
+			v.mu.Unlock()
+		}()
+	}
+	v.mu.Unlock()
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "waiting for verification of %s", url)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return r.err
+}
+
+// Cached returns the error of the last finished verification of the image name at
+// baseURL, like Check but without starting or waiting for one, nil if none has finished,
+// for dry runs.
+func (v *Verifier) Cached(_ context.Context, baseURL, name string) error {
+	if v == nil {
+		return nil
+	}
+	if _, ok := v.sums[name]; !ok {
+		return nil
+	}
+	url := strings.TrimSuffix(baseURL, "/") + "/" + name
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if r, ok := v.results[url]; ok && !r.checked.IsZero() {
+		return r.err
+	}
+
+	return nil
+}
+
+// verify downloads the image at url and compares its checksum to want.
+func (v *Verifier) verify(url, want string) error {
+	err := v.fetchAndCompare(url, want)
+	if err != nil {
+		metrics.OSIEChecksumFailures.Inc()
+		installers.Logger("osie").With("url", url).Error(err, "osie image failed verification, boots using it are refused")
+	}
+
+	return err
+}
+
+func (v *Verifier) fetchAndCompare(url, want string) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return errors.Wrapf(err, "fetch %s", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("fetch %s: %s", url, resp.Status)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return errors.Wrapf(err, "read %s", url)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return errors.Errorf("checksum of %s is %s, want %s", url, got, want)
+	}
+
+	return nil
+}
+
+// WithVerifier makes the installer refuse to serve boot scripts whose kernel or initrd
+// failed verification by v, nil verifies nothing.
+func WithVerifier(v *Verifier) Option {
+	return func(i *installer) {
+		i.verifier = v
+	}
+}
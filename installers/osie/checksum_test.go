@@ -0,0 +1,182 @@
+package osie
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/installers"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func writeChecksums(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "SHA256SUMS")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadChecksums(t *testing.T) {
+	kernel, initrd := sha256Hex("kernel"), sha256Hex("initrd")
+	tests := map[string]struct {
+		content string
+		want    map[string]string
+		err     bool
+	}{
+		"manifest": {
+			content: "# hook images\n" + strings.ToUpper(kernel) + "  vmlinuz-x86_64\n\n" + initrd + " *initramfs-x86_64\n",
+			want:    map[string]string{"vmlinuz-x86_64": kernel, "initramfs-x86_64": initrd},
+		},
+		"no name":      {content: kernel + "\n", err: true},
+		"not hex":      {content: "nothex  vmlinuz-x86_64\n", err: true},
+		"short sum":    {content: kernel[:32] + "  vmlinuz-x86_64\n", err: true},
+		"no checksums": {content: "# nothing\n", err: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := LoadChecksums(writeChecksums(t, tt.content))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("want error, got %v", got)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	files := map[string]string{"vmlinuz-x86_64": "kernel", "initramfs-x86_64": "truncated"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		content, ok := files[strings.TrimPrefix(req.URL.Path, "/osie/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	v, err := NewVerifier(map[string]string{
+		"vmlinuz-x86_64":   sha256Hex("kernel"),
+		"initramfs-x86_64": sha256Hex("initrd"),
+		"vmlinuz-aarch64":  sha256Hex("kernel"),
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failures := testutil.ToFloat64(metrics.OSIEChecksumFailures)
+	base := srv.URL + "/osie"
+	ctx := context.Background()
+	if err := v.Check(ctx, base, "vmlinuz-x86_64"); err != nil {
+		t.Fatalf("want kernel verified, got %v", err)
+	}
+	if err := v.Check(ctx, base, "initramfs-x86_64"); err == nil || !strings.Contains(err.Error(), "checksum of") {
+		t.Fatalf("want checksum mismatch, got %v", err)
+	}
+	if err := v.Check(ctx, base, "vmlinuz-aarch64"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("want missing image error, got %v", err)
+	}
+	if err := v.Check(ctx, base, "modloop-x86_64"); err != nil {
+		t.Fatalf("want images without a checksum not verified, got %v", err)
+	}
+	if n := testutil.ToFloat64(metrics.OSIEChecksumFailures) - failures; n != 2 {
+		t.Fatalf("want 2 verification failures, got %v", n)
+	}
+}
+
+func TestNewVerifierInvalid(t *testing.T) {
+	if v, err := NewVerifier(nil, time.Hour); v != nil || err != nil {
+		t.Fatalf("want no verifier without checksums, got %v, %v", v, err)
+	}
+	if _, err := NewVerifier(map[string]string{"vmlinuz-x86_64": sha256Hex("kernel")}, 0); err == nil {
+		t.Fatal("want error for zero interval")
+	}
+}
+
+func TestScriptVerifier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("corrupt"))
+	}))
+	defer srv.Close()
+	v, err := NewVerifier(map[string]string{"vmlinuz-x86_64": sha256Hex("kernel")}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := job.NewMock(t, "c3.small.x86", facility)
+	s := ipxe.NewScript()
+	Installer("", "", installers.KernelArgs{}, "", "", "", true, srv.URL+"/osie", nil, WithVerifier(v)).BootScript("install")(context.Background(), m.Job(), s)
+	if err := s.Err(); err == nil || !strings.Contains(err.Error(), "refusing to serve osie boot script") {
+		t.Fatalf("want script failed, got %v", err)
+	}
+	if strings.Contains(string(s.Bytes()), "\nkernel ") {
+		t.Fatalf("want no kernel in a failed script:\n%s", s.Bytes())
+	}
+}
+
+func TestVerifierCached(t *testing.T) {
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		_, _ = w.Write([]byte("corrupt"))
+	}))
+	defer srv.Close()
+	v, err := NewVerifier(map[string]string{"vmlinuz-x86_64": sha256Hex("kernel")}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := srv.URL + "/osie"
+	ctx := context.Background()
+
+	m := job.NewMock(t, "c3.small.x86", facility)
+	j := m.Job()
+	j.DryRun = true
+	s := ipxe.NewScript()
+	Installer("", "", installers.KernelArgs{}, "", "", "", true, base, nil, WithVerifier(v)).BootScript("install")(ctx, j, s)
+	if err := s.Err(); err != nil {
+		t.Fatalf("want a dry run of an unverified image rendered, got %v", err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 0 {
+		t.Fatalf("want no verification started by a dry run, got %d fetches", n)
+	}
+
+	if err := v.Check(ctx, base, "vmlinuz-x86_64"); err == nil {
+		t.Fatal("want checksum mismatch")
+	}
+	if err := v.Cached(ctx, base, "vmlinuz-x86_64"); err == nil || !strings.Contains(err.Error(), "checksum of") {
+		t.Fatalf("want the cached checksum mismatch, got %v", err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("want 1 fetch, got %d", n)
+	}
+}
@@ -0,0 +1,85 @@
+package osie
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/job"
+)
+
+// ParseFacilityURLs parses a comma separated list of facility=URL OSIE/Hook base URLs,
+// by facility code.
+func ParseFacilityURLs(s string) (map[string]string, error) {
+	urls := map[string]string{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		facility, u, ok := strings.Cut(entry, "=")
+		if !ok || facility == "" {
+			return nil, errors.Errorf("invalid entry %q, must be facility=URL", entry)
+		}
+		if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+			return nil, errors.Errorf("invalid URL %q for facility %s, must be http or https", u, facility)
+		}
+		if _, dup := urls[facility]; dup {
+			return nil, errors.Errorf("facility %s is given more than once", facility)
+		}
+		urls[facility] = strings.TrimSuffix(u, "/")
+	}
+
+	return urls, nil
+}
+
+// WithFacilityURLs makes machines in the facilities of urls boot from the facility's
+// OSIE/Hook base URL, instead of the mirrors, the OSIE path override or the hardware
+// record's OSIE base URL.
+func WithFacilityURLs(urls map[string]string) Option {
+	return func(i *installer) {
+		i.facilityURLs = urls
+	}
+}
+
+// The mirror labels of boots from a base URL that is not configured, see resolveBaseURL.
+const (
+	mirrorHardware = "hardware"
+	mirrorDefault  = "default"
+)
+
+// mirrorLabel returns the mirror label of a configured base URL, its scheme and host,
+// so the paths and credentials of the URLs do not end up in the metrics.
+func mirrorLabel(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return "configured"
+	}
+
+	return u.Scheme + "://" + u.Host
+}
+
+// resolveBaseURL returns the OSIE base URL j boots from and the mirror it is counted
+// under: the URL of the facility of j, else the mirror picked for j or the OSIE path
+// override, else the hardware record's OSIE base URL or the default one. A dry run of j
+// does not advance the mirror selection.
+func (i installer) resolveBaseURL(j job.Job) (string, string) {
+	if u, ok := i.facilityURLs[j.FacilityCode()]; ok {
+		return u, mirrorLabel(u)
+	}
+	override := i.osieFullURLOverride
+	switch {
+	case i.mirrors != nil && j.DryRun:
+		override = i.mirrors.peekMirror(j.PrimaryNIC())
+	case i.mirrors != nil:
+		override = i.mirrors.selectMirror(j.PrimaryNIC())
+	}
+	if override != "" {
+		return override, mirrorLabel(override)
+	}
+	if j.OSIEBaseURL() != "" {
+		return osieBaseURL(i.osieURL, "", j), mirrorHardware
+	}
+
+	return osieBaseURL(i.osieURL, "", j), mirrorDefault
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/andreyvit/diff"
 	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/installers"
 	"github.com/tinkerbell/boots/ipxe"
 	"github.com/tinkerbell/boots/job"
 )
@@ -64,7 +65,7 @@ func TestScript(t *testing.T) {
 					s.Set("syslog_host", "127.0.0.1")
 					s.Set("ipxe_cloud_config", "packet")
 
-					Installer("", "", "", "", "", "", true, "", extraIPXEVars).BootScript(action)(context.Background(), m.Job(), s)
+					Installer("", "", installers.KernelArgs{}, "", "", "", true, "", extraIPXEVars).BootScript(action)(context.Background(), m.Job(), s)
 					got := string(s.Bytes())
 
 					arch := "x86_64"
@@ -190,3 +191,88 @@ initrd ${base-url}/initramfs-${arch}
 boot
 `,
 }
+
+func TestScriptFailureReporting(t *testing.T) {
+	m := job.NewMock(t, "c3.small.x86", facility)
+	m.SetMAC(genRandMAC(t))
+
+	s := ipxe.NewScript()
+	Installer("", "", installers.KernelArgs{}, "", "", "", true, "", nil, WithFailureReporting(true)).BootScript("install")(context.Background(), m.Job(), s)
+	got := string(s.Bytes())
+
+	for _, want := range []string{
+		"set boot_stage kernel\nkernel ${base-url}/",
+		" || goto boot-failure\nset boot_stage initrd\ninitrd ${base-url}/",
+		"set boot_stage boot\nboot || goto boot-failure\n",
+		"\n:boot-failure\nset boot_errno ${errno}\n",
+		"imgfetch ${tinkerbell}/boot-failure##params || echo Unable to report boot failure\n",
+		"sleep 10\nchain --autofree ${tinkerbell}/auto.ipxe\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("iPXE script missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestScriptAllowedHosts(t *testing.T) {
+	allowed, err := installers.NewHostAllowList([]string{".mirror.example.com", "# the upstream mirror", "10.1.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		override string
+		denied   bool
+	}{
+		"allowed subdomain": {override: "http://osie.mirror.example.com/misc/osie/current"},
+		"allowed ip":        {override: "http://10.1.1.1:8080/osie"},
+		"denied host":       {override: "http://evil.example.com/osie", denied: true},
+		"denied suffix":     {override: "http://notmirror.example.com/osie", denied: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := job.NewMock(t, "c3.small.x86", facility)
+			m.SetMAC(genRandMAC(t))
+
+			s := ipxe.NewScript()
+			Installer("", "", installers.KernelArgs{}, "", "", "", true, tt.override, nil, WithAllowedHosts(allowed)).BootScript("install")(context.Background(), m.Job(), s)
+			got := string(s.Bytes())
+
+			if denied := !strings.Contains(got, "\nkernel "); denied != tt.denied {
+				t.Fatalf("want denied %v, got script:\n%s", tt.denied, got)
+			}
+			if tt.denied && !strings.Contains(got, "not on an allowed host") {
+				t.Fatalf("denied script does not explain why:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestScriptKernelArgs(t *testing.T) {
+	tests := map[string]struct {
+		action   string
+		extra    string
+		hardware string
+		want     string
+	}{
+		"install defaults":   {action: "install", want: " install=1 shared=install "},
+		"discover defaults":  {action: "discover", want: " discover=1 shared=discover "},
+		"global overrides":   {action: "install", extra: "shared=global", want: " install=1 shared=global "},
+		"hardware overrides": {action: "install", extra: "shared=global", hardware: "shared=hw", want: " install=1 shared=hw "},
+		"hardware adds":      {action: "discover", hardware: "hw=1", want: " discover=1 shared=discover hw=1 "},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := job.NewMock(t, "c3.small.x86", facility)
+			m.SetMAC(genRandMAC(t))
+			m.SetKernelArgs(tt.hardware)
+
+			s := ipxe.NewScript()
+			Installer("", "", installers.NewKernelArgs(tt.extra), "", "", "", true, "", nil, WithKernelArgs("install=1 shared=install", "discover=1 shared=discover")).BootScript(tt.action)(context.Background(), m.Job(), s)
+			got := string(s.Bytes())
+
+			if !strings.Contains(got, tt.want) {
+				t.Fatalf("kernel args missing %q:\n%s", tt.want, got)
+			}
+		})
+	}
+}
@@ -4,42 +4,111 @@ import (
 	"context"
 	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/installers"
 	"github.com/tinkerbell/boots/ipxe"
 	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// failureRetryDelay is the number of seconds to wait before retrying a failed boot.
+const failureRetryDelay = 10
+
 type installer struct {
 	osieURL string
 	// defaultParams are passed to iPXE'd kernel always
 	defaultParams string
+	// installKernelArgs and discoverKernelArgs are the installer's default kernel args for installs, rescues and discovery
+	installKernelArgs  string
+	discoverKernelArgs string
+	// extraKernelArgs are the global kernel args, overriding the installer's defaults
+	extraKernelArgs installers.KernelArgs
 	// workflowParams are passed to iPXE'd kernel when in tinkerbell or standalone mode and the hw indicates it can run workflows
 	workflowParams      string
 	osieFullURLOverride string
 	extraIPXEVars       [][]string
+	// staticNetwork replaces ip=dhcp with the hw's static network config when it has one
+	staticNetwork bool
+	// reportFailures makes the boot script report failed kernel, initrd and boot commands back to Boots
+	reportFailures bool
+	// caBundleURL is passed to the kernel for installers to fetch and trust before anything else
+	caBundleURL string
+	// allowedHosts are the hosts the kernel and initrd may be fetched from
+	allowedHosts *installers.HostAllowList
+	// mirrors replace osieFullURLOverride with a mirror picked per boot
+	mirrors mirrorSelector
+	// facilityURLs are the base URLs of the machines in each facility, by facility code, taking precedence over the mirrors
+	facilityURLs map[string]string
+	// verifier checks the kernel and initrd against their checksums, nil checks nothing
+	verifier *Verifier
+}
+
+// Option configures optional installer behavior.
+type Option func(*installer)
+
+// WithStaticNetworkKernelArgs makes the installer pass dracut style ip= kernel args built from
+// the hardware record's static network config instead of ip=dhcp, when the record has any.
+func WithStaticNetworkKernelArgs(enabled bool) Option {
+	return func(i *installer) {
+		i.staticNetwork = enabled
+	}
+}
+
+// WithFailureReporting makes the installer's boot scripts report the iPXE errno of a failed
+// kernel, initrd or boot command to Boots before retrying.
+func WithFailureReporting(enabled bool) Option {
+	return func(i *installer) {
+		i.reportFailures = enabled
+	}
+}
+
+// WithCABundleURL passes url to the kernel as ca_bundle_url, for the installer to fetch and
+// install the CA bundle served by Boots early in boot. An empty url passes nothing.
+func WithCABundleURL(url string) Option {
+	return func(i *installer) {
+		i.caBundleURL = url
+	}
+}
+
+// WithAllowedHosts makes the installer refuse to boot machines whose OSIE base URL,
+// from the hardware record or the override, is not on an allowed host.
+func WithAllowedHosts(a *installers.HostAllowList) Option {
+	return func(i *installer) {
+		i.allowedHosts = a
+	}
+}
+
+// WithKernelArgs sets the installer's default kernel args, passed to the kernel when
+// installing or rescuing, and discovering. They are overridden by the global and
+// hardware record kernel args with the same key, see installers.MergeKernelArgs.
+func WithKernelArgs(install, discover string) Option {
+	return func(i *installer) {
+		i.installKernelArgs = install
+		i.discoverKernelArgs = discover
+	}
 }
 
 // Installer instantiates a new osie installer.
-func Installer(dataModelVersion, tinkGRPCAuth, extraKernelArgs, registry, registryUsername, registryPassword string, tinkTLS bool, osiePathOverride string, dynamicIPXEVars [][]string) job.BootScripter {
+func Installer(dataModelVersion, tinkGRPCAuth string, extraKernelArgs installers.KernelArgs, registry, registryUsername, registryPassword string, tinkTLS bool, osiePathOverride string, dynamicIPXEVars [][]string, opts ...Option) job.BootScripter {
 	defaultParams := []string{
-		"ip=dhcp",
 		"modules=loop,squashfs,sd-mod,usb-storage",
 		"tinkerbell=${tinkerbell}",
 		"syslog_host=${syslog_host}",
 		"osie_vendors_url=" + conf.OsieVendorServicesURL,
 	}
 
-	if extraKernelArgs != "" {
-		defaultParams = append(defaultParams, extraKernelArgs)
-	}
-
 	i := installer{
 		osieURL:             conf.MirrorBaseURL + "/misc/osie",
 		defaultParams:       strings.Join(defaultParams, " "),
+		extraKernelArgs:     extraKernelArgs,
 		osieFullURLOverride: osiePathOverride,
 		extraIPXEVars:       dynamicIPXEVars,
 	}
+	for _, opt := range opts {
+		opt(&i)
+	}
 
 	if dataModelVersion == "" {
 		return i
@@ -77,6 +146,17 @@ func (i installer) BootScript(slug string) job.BootScript {
 	}
 }
 
+// networkParams returns the kernel args that configure the installer's network.
+func (i installer) networkParams(j job.Job) []string {
+	if i.staticNetwork {
+		if args := j.NetworkKernelArgs(); len(args) > 0 {
+			return args
+		}
+	}
+
+	return []string{"ip=dhcp"}
+}
+
 // install generates the ipxe boot script for booting into the osie installer.
 func (i installer) install(ctx context.Context, j job.Job, s *ipxe.Script) {
 	for _, kv := range i.extraIPXEVars {
@@ -125,16 +205,69 @@ func (i installer) discover(ctx context.Context, j job.Job, s *ipxe.Script) {
 func (i installer) setBootScript(ctx context.Context, action string, j job.Job, s *ipxe.Script) {
 	s.Set("arch", j.Arch())
 	s.Set("bootdevmac", j.PrimaryNIC().String())
-	s.Set("base-url", osieBaseURL(i.osieURL, i.osieFullURLOverride, j))
+	baseURL, mirror := i.resolveBaseURL(j)
+	if err := i.allowedHosts.Check(baseURL); err != nil {
+		installers.DisallowedURL(j, s, "osie", err)
+
+		return
+	}
+	check := i.verifier.Check
+	if j.DryRun {
+		check = i.verifier.Cached
+	}
+	for _, name := range []string{kernelPath(j), initrdPath(j)} {
+		if err := check(ctx, baseURL, strings.ReplaceAll(name, "${arch}", j.Arch())); err != nil {
+			s.Fail(errors.WithMessage(err, "refusing to serve osie boot script"))
+
+			return
+		}
+	}
+	s.Set("base-url", baseURL)
+	if !j.DryRun {
+		metrics.OSIEBoots.WithLabelValues(mirror).Inc()
+	}
+	i.bootStage(s, "kernel")
 	s.Kernel("${base-url}/" + kernelPath(j))
 	i.kernelParams(ctx, action, j.HardwareState(), j, s)
+	i.orReportFailure(s)
+	i.bootStage(s, "initrd")
 	s.Initrd("${base-url}/" + initrdPath(j))
+	i.orReportFailure(s)
 
+	i.bootStage(s, "boot")
 	s.Boot()
+	if i.reportFailures {
+		s.OrReportFailure()
+		s.ReportFailure(failureRetryDelay)
+	}
+}
+
+// bootStage records the stage reported to Boots if the next command fails.
+func (i installer) bootStage(s *ipxe.Script, stage string) {
+	if i.reportFailures {
+		s.Set("boot_stage", stage)
+	}
+}
+
+func (i installer) orReportFailure(s *ipxe.Script) {
+	if i.reportFailures {
+		s.OrReportFailure()
+	}
 }
 
 func (i installer) kernelParams(ctx context.Context, action, _ string, j job.Job, s *ipxe.Script) {
+	s.Args(i.networkParams(j)...)
 	s.Args(i.defaultParams)
+	installerArgs := i.installKernelArgs
+	if action == "discover" {
+		installerArgs = i.discoverKernelArgs
+	}
+	if args := installers.MergeKernelArgs(installerArgs, i.extraKernelArgs.Expand(j), j.KernelArgs()); args != "" {
+		s.Args(args)
+	}
+	if i.caBundleURL != "" {
+		s.Args("ca_bundle_url=" + i.caBundleURL)
+	}
 
 	// only add traceparent if tracing is enabled
 	if sc := trace.SpanContextFromContext(ctx); sc.IsSampled() {
@@ -5,11 +5,15 @@ import (
 	"testing"
 
 	l "github.com/packethost/pkg/log"
+	"github.com/tinkerbell/boots/installers"
 	"github.com/tinkerbell/boots/job"
+	"github.com/tinkerbell/boots/metrics"
 )
 
 func TestMain(m *testing.M) {
 	logger, _ := l.Init("github.com/tinkerbell/boots")
 	job.Init(logger)
+	installers.Init(logger)
+	metrics.Init(logger)
 	os.Exit(m.Run())
 }
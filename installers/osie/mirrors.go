@@ -0,0 +1,154 @@
+package osie
+
+import (
+	"hash/fnv"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Mirror is an OSIE/Hook base URL and its weight relative to the other mirrors.
+type Mirror struct {
+	URL    string
+	Weight int
+}
+
+// ParseMirrors parses a comma separated list of URL[=weight] mirrors. The weight defaults to 1.
+func ParseMirrors(s string) ([]Mirror, error) {
+	var mirrors []Mirror
+	for _, m := range strings.Split(s, ",") {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		mirror := Mirror{URL: m, Weight: 1}
+		if i := strings.LastIndex(m, "="); i >= 0 {
+			w, err := strconv.Atoi(m[i+1:])
+			if err != nil || w < 1 {
+				return nil, errors.Errorf("invalid weight in mirror %q, must be a positive integer", m)
+			}
+			mirror = Mirror{URL: m[:i], Weight: w}
+		}
+		if !strings.HasPrefix(mirror.URL, "http://") && !strings.HasPrefix(mirror.URL, "https://") {
+			return nil, errors.Errorf("invalid mirror URL %q, must be http or https", mirror.URL)
+		}
+		mirrors = append(mirrors, mirror)
+	}
+	if len(mirrors) == 0 {
+		return nil, errors.New("no mirrors")
+	}
+
+	return mirrors, nil
+}
+
+// mirrorSelector picks the mirror a machine boots from.
+type mirrorSelector interface {
+	selectMirror(mac net.HardwareAddr) string
+	// peekMirror returns the mirror selectMirror would pick without advancing the selection
+	peekMirror(mac net.HardwareAddr) string
+}
+
+// WithMirrors makes the installer spread boots across mirrors instead of using the
+// OSIE path override. byMAC picks the same mirror for a MAC on every boot using
+// weighted rendezvous hashing, otherwise mirrors are picked by weighted round-robin.
+// The hardware record's OSIE base URL still takes precedence.
+func WithMirrors(mirrors []Mirror, byMAC bool) Option {
+	return func(i *installer) {
+		if len(mirrors) == 0 {
+			return
+		}
+		if byMAC {
+			i.mirrors = macHashMirrors(mirrors)
+		} else {
+			i.mirrors = newRoundRobinMirrors(mirrors)
+		}
+	}
+}
+
+// roundRobinMirrors implements smooth weighted round-robin, which interleaves the
+// mirrors instead of picking the same one weight times in a row.
+type roundRobinMirrors struct {
+	mu      sync.Mutex
+	mirrors []Mirror
+	current []int
+	total   int
+}
+
+func newRoundRobinMirrors(mirrors []Mirror) *roundRobinMirrors {
+	r := &roundRobinMirrors{mirrors: mirrors, current: make([]int, len(mirrors))}
+	for _, m := range mirrors {
+		r.total += m.Weight
+	}
+
+	return r
+}
+
+func (r *roundRobinMirrors) selectMirror(net.HardwareAddr) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	best := 0
+	for i, m := range r.mirrors {
+		r.current[i] += m.Weight
+		if r.current[i] > r.current[best] {
+			best = i
+		}
+	}
+	r.current[best] -= r.total
+
+	return r.mirrors[best].URL
+}
+
+func (r *roundRobinMirrors) peekMirror(net.HardwareAddr) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	best := 0
+	for i, m := range r.mirrors {
+		if r.current[i]+m.Weight > r.current[best]+r.mirrors[best].Weight {
+			best = i
+		}
+	}
+
+	return r.mirrors[best].URL
+}
+
+// macHashMirrors picks a mirror by weighted rendezvous hashing of the MAC, so a
+// machine keeps its mirror and only the machines of a removed mirror move.
+type macHashMirrors []Mirror
+
+func (h macHashMirrors) selectMirror(mac net.HardwareAddr) string {
+	best, bestScore := 0, math.Inf(-1)
+	for i, m := range h {
+		f := fnv.New64a()
+		_, _ = f.Write(mac)
+		_, _ = f.Write([]byte(m.URL))
+		// map the hash into (0, 1) and weigh it, see https://en.wikipedia.org/wiki/Rendezvous_hashing
+		u := (float64(mix(f.Sum64())>>11) + 0.5) / (1 << 53)
+		if score := -float64(m.Weight) / math.Log(u); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	return h[best].URL
+}
+
+func (h macHashMirrors) peekMirror(mac net.HardwareAddr) string {
+	return h.selectMirror(mac)
+}
+
+// mix is the splitmix64 finalizer, FNV alone leaves the high bits poorly mixed
+// when only the last bytes of the input differ.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
@@ -0,0 +1,120 @@
+package osie
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/boots/installers"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/job"
+)
+
+func TestParseMirrors(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    []Mirror
+		wantErr bool
+	}{
+		"default weight": {in: "http://a/osie", want: []Mirror{{URL: "http://a/osie", Weight: 1}}},
+		"weights": {
+			in:   "http://a/osie=3, https://b/osie?x=y=2",
+			want: []Mirror{{URL: "http://a/osie", Weight: 3}, {URL: "https://b/osie?x=y", Weight: 2}},
+		},
+		"zero weight":    {in: "http://a/osie=0", wantErr: true},
+		"invalid weight": {in: "http://a/osie?x=y", wantErr: true},
+		"not http":       {in: "a/osie", wantErr: true},
+		"empty":          {in: ",", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseMirrors(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestRoundRobinMirrors(t *testing.T) {
+	r := newRoundRobinMirrors([]Mirror{{URL: "a", Weight: 3}, {URL: "b", Weight: 1}, {URL: "c", Weight: 1}})
+	var got []string
+	for i := 0; i < 10; i++ {
+		got = append(got, r.selectMirror(nil))
+	}
+	// smooth weighted round-robin interleaves a between the others
+	want := []string{"a", "b", "a", "c", "a", "a", "b", "a", "c", "a"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestMACHashMirrors(t *testing.T) {
+	mirrors := []Mirror{{URL: "a", Weight: 3}, {URL: "b", Weight: 1}, {URL: "c", Weight: 1}}
+	h := macHashMirrors(mirrors)
+	counts := map[string]int{}
+	const n = 5000
+	for i := 0; i < n; i++ {
+		mac := net.HardwareAddr{0x00, 0x00, byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)}
+		m := h.selectMirror(mac)
+		if again := h.selectMirror(mac); again != m {
+			t.Fatalf("%s: picked %s then %s", mac, m, again)
+		}
+		// removing another mirror must not move this mac
+		var rest macHashMirrors
+		for _, o := range mirrors {
+			if o.URL == m || o.URL != "c" {
+				rest = append(rest, o)
+			}
+		}
+		if moved := rest.selectMirror(mac); moved != m {
+			t.Fatalf("%s: moved from %s to %s when c was removed", mac, m, moved)
+		}
+		counts[m]++
+	}
+	// a has 3/5 of the weight
+	if share := float64(counts["a"]) / n; share < 0.55 || share > 0.65 {
+		t.Fatalf("want about 60%% of macs on a, got %v", counts)
+	}
+}
+
+func TestScriptMirrors(t *testing.T) {
+	m := job.NewMock(t, "c3.small.x86", facility)
+	m.SetMAC(genRandMAC(t))
+	i := Installer("", "", installers.KernelArgs{}, "", "", "", true, "", nil, WithMirrors([]Mirror{{URL: "http://a/osie", Weight: 1}, {URL: "http://b/osie", Weight: 1}}, false))
+
+	for _, want := range []string{"http://a/osie", "http://b/osie", "http://a/osie"} {
+		s := ipxe.NewScript()
+		i.BootScript("install")(context.Background(), m.Job(), s)
+		if got := string(s.Bytes()); !strings.Contains(got, "\nset base-url "+want+"\n") {
+			t.Fatalf("want base-url %s:\n%s", want, got)
+		}
+	}
+}
+
+func TestRoundRobinMirrorsPeek(t *testing.T) {
+	r := newRoundRobinMirrors([]Mirror{{URL: "a", Weight: 3}, {URL: "b", Weight: 1}, {URL: "c", Weight: 1}})
+	want := []string{"a", "b", "a", "c", "a", "a", "b", "a", "c", "a"}
+	for i, w := range want {
+		for n := 0; n < 2; n++ {
+			if got := r.peekMirror(nil); got != w {
+				t.Fatalf("pick %d: want peek %s, got %s", i, w, got)
+			}
+		}
+		if got := r.selectMirror(nil); got != w {
+			t.Fatalf("pick %d: want %s after peeking, got %s", i, w, got)
+		}
+	}
+}
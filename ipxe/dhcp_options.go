@@ -3,8 +3,10 @@ package ipxe
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	dhcp4 "github.com/packethost/dhcp4-go"
+	"github.com/pkg/errors"
 	"github.com/tinkerbell/boots/conf"
 )
 
@@ -77,6 +79,47 @@ func IsTinkerbellIPXE(req *dhcp4.Packet) bool {
 	return string(uc) == "Tinkerbell"
 }
 
+// UserClasses are the user-classes (opt 77) of iPXE builds other than Tinkerbell's,
+// e.g. the "iPXE" of stock iPXE flashed on a NIC, that are sent the boot script URL
+// directly instead of being chainloaded into the Tinkerbell iPXE binary.
+type UserClasses []string
+
+// ParseUserClasses parses a comma separated list of user-classes, returns nil for an empty list.
+func ParseUserClasses(list string) (UserClasses, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	var u UserClasses
+	for _, class := range strings.Split(list, ",") {
+		class = strings.TrimSpace(class)
+		if class == "" {
+			return nil, errors.Errorf("invalid user-class list %q, has an empty user-class", list)
+		}
+		u = append(u, class)
+	}
+
+	return u, nil
+}
+
+// IsScriptCapable reports whether req is from Tinkerbell iPXE, or from an iPXE build with
+// one of the user-classes that supports HTTP, so it can be sent the boot script URL.
+func (u UserClasses) IsScriptCapable(req *dhcp4.Packet) bool {
+	if IsTinkerbellIPXE(req) {
+		return true
+	}
+	uc, _ := req.GetOption(dhcp4.OptionUserClass)
+	for _, class := range u {
+		if string(uc) == class {
+			// unlike IsIPXE, builds with a custom user-class are asked for their features too
+			x, ok := req.GetOption(EncapsulatedOptions)
+
+			return ok && HasFeature(ParseOptions(x), FeatureHTTP)
+		}
+	}
+
+	return false
+}
+
 // IsIPXE returns bool depending on if the request originated with a version of iPXE.
 func IsIPXE(req *dhcp4.Packet) bool {
 	if om := GetEncapsulatedOptions(req); om != nil && HasFeature(om, FeatureHTTP) {
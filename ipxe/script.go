@@ -4,6 +4,8 @@ import "fmt"
 
 type Script struct {
 	buf []byte
+	// err is why the script must not be served, see Fail
+	err error
 }
 
 func NewScript() *Script {
@@ -41,6 +43,63 @@ imgfree
 `...)
 }
 
+// InventoryNICs is the number of NICs whose MACs CollectInventory reports.
+const InventoryNICs = 4
+
+// CollectInventory posts what iPXE knows about the machine's hardware, from SMBIOS
+// and its NICs, with nonce to ${tinkerbell}/inventory and runs the script it responds
+// with. iPXE cannot see disks, so they are left for the installer to report. nonce is
+// written as is, it must be a single word without iPXE settings, e.g. hex.
+func (s *Script) CollectInventory(nonce string) {
+	s.buf = append(s.buf, `
+echo Collecting inventory
+params
+param nonce `+nonce+`
+param mac ${mac}
+param ip ${ip}
+param arch ${buildarch}
+param platform ${platform}
+param manufacturer ${manufacturer}
+param product ${product}
+param serial ${serial}
+param uuid ${uuid}
+param asset ${asset}
+param memsize ${memsize}
+`...)
+	for i := 0; i < InventoryNICs; i++ {
+		n := fmt.Sprintf("net%d", i)
+		s.buf = append(s.buf, "param "+n+" ${"+n+"/mac}\n"...)
+	}
+	s.Chain("${tinkerbell}/inventory##params")
+}
+
+// bootFailureLabel is the label of the handler added by ReportFailure.
+const bootFailureLabel = "boot-failure"
+
+// OrReportFailure makes the previous command jump to the handler added by ReportFailure when it fails.
+func (s *Script) OrReportFailure() {
+	s.Or("goto " + bootFailureLabel)
+}
+
+// ReportFailure appends a handler that posts the iPXE errno, the boot_stage and
+// bootdevmac variables to ${tinkerbell}/boot-failure, then retries the boot by
+// chainloading auto.ipxe again after retryDelay seconds.
+func (s *Script) ReportFailure(retryDelay int) {
+	s.buf = append(s.buf, `
+:`+bootFailureLabel+`
+set boot_errno ${errno}
+echo Boot failed during ${boot_stage}: ${boot_errno}
+params
+param errno ${boot_errno}
+param stage ${boot_stage}
+param mac ${bootdevmac}
+imgfetch ${tinkerbell}/boot-failure##params || echo Unable to report boot failure
+imgfree
+`...)
+	s.Sleep(retryDelay)
+	s.Chain("${tinkerbell}/auto.ipxe")
+}
+
 // Chain - Chainload another iPXE script.
 func (s *Script) Chain(uri string) {
 	s.buf = append(append(s.buf, "chain --autofree "...), uri...)
@@ -85,7 +144,21 @@ func (s *Script) Or(line string) {
 	s.buf = append(s.buf, '\n')
 }
 
+// Fail marks the script as one that must not be served because of err, the first
+// failure is kept.
+func (s *Script) Fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Err returns why the script must not be served, nil if it can be.
+func (s *Script) Err() error {
+	return s.err
+}
+
 func (s *Script) Reset() {
+	s.err = nil
 	s.buf = append(s.buf[:0], "#!ipxe\n\n"...)
 	s.Echo("Tinkerbell Boots iPXE")
 }
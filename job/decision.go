@@ -0,0 +1,81 @@
+package job
+
+import (
+	"context"
+	"path"
+	"strings"
+)
+
+// BootDecision describes how Boots responds to the machine requesting a boot file.
+type BootDecision struct {
+	HardwareID        string `json:"hardware_id"`
+	MAC               string `json:"mac"`
+	Arch              string `json:"arch"`
+	UEFI              bool   `json:"uefi"`
+	State             string `json:"state,omitempty"`
+	AllowPXE          bool   `json:"allow_pxe"`
+	NetbootConfigured bool   `json:"netboot_configured"`
+	// ScriptName is the boot script requested, empty if the file is not a boot script
+	ScriptName string `json:"script_name,omitempty"`
+	// Installer is what selected the auto boot script: redirect, facility:<code>, installer:<name>, slug:<slug>, distro:<distro>, default or shell
+	Installer string `json:"installer,omitempty"`
+	// Script is the boot script that is served, empty if none is
+	Script string `json:"script,omitempty"`
+	// KernelArgs are the args of the kernel the boot script loads, empty if it loads none
+	KernelArgs string `json:"kernel_args,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// BootDecision returns the decision for the boot file at filePath, rendering the
+// boot script only if the machine is allowed to PXE.
+func (j Job) BootDecision(ctx context.Context, filePath string, i Installers) BootDecision {
+	d := BootDecision{
+		HardwareID:        j.HardwareID().String(),
+		MAC:               j.PrimaryNIC().String(),
+		Arch:              j.Arch(),
+		UEFI:              j.IsUEFI(),
+		State:             j.HardwareState(),
+		AllowPXE:          j.AllowPXE(),
+		NetbootConfigured: j.NetbootConfigured(),
+	}
+	base := path.Base(filePath)
+	name := strings.TrimSuffix(base, ".ipxe")
+	if len(name) == len(base) {
+		return d
+	}
+	d.ScriptName = name
+	if !d.AllowPXE {
+		if name == "auto" {
+			d.Installer, _ = i.selectInstaller(j)
+		}
+
+		return d
+	}
+
+	installer, script, err := j.bootScript(ctx, name, i)
+	d.Installer = installer
+	if err != nil {
+		d.Error = err.Error()
+
+		return d
+	}
+	d.Script = string(script)
+	d.KernelArgs = kernelArgs(d.Script)
+
+	return d
+}
+
+// kernelArgs returns the args of the first kernel line of script, without its URI.
+func kernelArgs(script string) string {
+	for _, line := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(line, "kernel ") {
+			continue
+		}
+		line, _, _ = strings.Cut(line, " || ")
+		_, args, _ := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "kernel ")), " ")
+
+		return strings.TrimSpace(args)
+	}
+
+	return ""
+}
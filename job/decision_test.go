@@ -0,0 +1,305 @@
+package job
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/client/standalone"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func TestBootDecision(t *testing.T) {
+	i := Installers{
+		ByInstaller: map[string]BootScript{},
+		BySlug:      map[string]BootScript{},
+		ByDistro: map[string]BootScript{
+			"ubuntu": func(_ context.Context, _ Job, s *ipxe.Script) { s.Echo("installing ubuntu") },
+		},
+	}
+	for _, tt := range []struct {
+		name      string
+		path      string
+		allowPXE  bool
+		installer string
+		script    string
+		err       string
+	}{
+		{name: "allowed", path: "/auto.ipxe", allowPXE: true, installer: "distro:ubuntu", script: "installing ubuntu"},
+		{name: "not allowed", path: "/auto.ipxe", installer: "distro:ubuntu"},
+		{name: "shell", path: "/shell.ipxe", allowPXE: true, script: "shell"},
+		{name: "unknown script", path: "/nope.ipxe", allowPXE: true, err: `boot script "nope" not found`},
+		{name: "not a script", path: "/undionly.kpxe", allowPXE: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMock(t, "c3.small.x86", "ewr1")
+			m.SetOSDistro("ubuntu")
+			m.hardware.(*standalone.HardwareStandalone).Network.Interfaces[0].Netboot.AllowPXE = tt.allowPXE
+
+			d := m.Job().BootDecision(context.Background(), tt.path, i)
+			if d.AllowPXE != tt.allowPXE || !d.NetbootConfigured {
+				t.Fatalf("unexpected allow_pxe: %t, netboot_configured: %t", d.AllowPXE, d.NetbootConfigured)
+			}
+			if d.Installer != tt.installer {
+				t.Fatalf("unexpected installer, want: %q, got: %q", tt.installer, d.Installer)
+			}
+			if d.Error != tt.err {
+				t.Fatalf("unexpected error, want: %q, got: %q", tt.err, d.Error)
+			}
+			if tt.script == "" {
+				if d.Script != "" {
+					t.Fatalf("unexpected script:\n%s", d.Script)
+				}
+
+				return
+			}
+			if !strings.HasPrefix(d.Script, "#!ipxe") || !strings.Contains(d.Script, tt.script) {
+				t.Fatalf("script does not contain %q:\n%s", tt.script, d.Script)
+			}
+		})
+	}
+}
+
+func TestBootDecisionKernelArgs(t *testing.T) {
+	i := Installers{
+		ByInstaller: map[string]BootScript{},
+		BySlug:      map[string]BootScript{},
+		ByDistro: map[string]BootScript{
+			"ubuntu": func(_ context.Context, j Job, s *ipxe.Script) {
+				s.Kernel("${base-url}/vmlinuz", "console=ttyS0", j.KernelArgs())
+				s.Or("shell")
+				s.Boot()
+			},
+		},
+	}
+	m := NewMock(t, "c3.small.x86", "ewr1")
+	m.SetOSDistro("ubuntu")
+	m.SetKernelArgs("nomodeset")
+	m.hardware.(*standalone.HardwareStandalone).Network.Interfaces[0].Netboot.AllowPXE = true
+
+	d := m.Job().BootDecision(context.Background(), "/auto.ipxe", i)
+	if d.KernelArgs != "console=ttyS0 nomodeset" {
+		t.Fatalf("unexpected kernel args %q, script:\n%s", d.KernelArgs, d.Script)
+	}
+	if d := m.Job().BootDecision(context.Background(), "/shell.ipxe", i); d.KernelArgs != "" {
+		t.Fatalf("want no kernel args without a kernel, got %q", d.KernelArgs)
+	}
+}
+
+func TestBootScriptArch(t *testing.T) {
+	i := Installers{
+		ByInstaller: map[string]BootScript{},
+		BySlug:      map[string]BootScript{},
+		ByDistro: map[string]BootScript{
+			"ubuntu": func(_ context.Context, _ Job, s *ipxe.Script) { s.Echo("installing ubuntu") },
+		},
+	}
+	i.RestrictArch("distro:ubuntu", []string{"aarch64"})
+	for _, tt := range []struct {
+		name       string
+		clientArch string
+		err        string
+	}{
+		{name: "matching client arch", clientArch: "aarch64"},
+		{name: "other client arch", clientArch: "x86_64", err: `installer distro:ubuntu does not support arch "x86_64"`},
+		{name: "hardware record arch", err: `installer distro:ubuntu does not support arch "x86_64"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMock(t, "c3.small.x86", "ewr1")
+			m.SetOSDistro("ubuntu")
+			j := m.Job()
+			j.ClientArch = tt.clientArch
+
+			_, script, err := j.bootScript(context.Background(), "auto", i)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error, want: %q, got: %v", tt.err, err)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(script), "installing ubuntu") {
+				t.Fatalf("unexpected script:\n%s", script)
+			}
+		})
+	}
+}
+
+func TestSelectInstallerPriority(t *testing.T) {
+	echo := func(msg string) BootScript {
+		return func(_ context.Context, _ Job, s *ipxe.Script) { s.Echo(msg) }
+	}
+	tieInstallerSlug := map[string]int{"facility": 0, "installer": 1, "slug": 1, "distro": 2}
+	for _, tt := range []struct {
+		name      string
+		facility  string
+		priority  map[string]int
+		arches    map[string][]string
+		installer string
+	}{
+		{name: "default priority", facility: "ewr1", installer: "facility:ewr1"},
+		{name: "no facility match", facility: "sjc1", installer: "installer:custom"},
+		{name: "explicit priority", facility: "ewr1", priority: map[string]int{"distro": 0, "slug": 1, "installer": 2, "facility": 3}, installer: "distro:ubuntu"},
+		{name: "other arch falls through", facility: "ewr1", arches: map[string][]string{"facility:ewr1": {"aarch64"}}, installer: "installer:custom"},
+		{name: "tie kind order", facility: "sjc1", priority: tieInstallerSlug, installer: "installer:custom"},
+		{name: "tie arch specific wins", facility: "sjc1", priority: tieInstallerSlug, arches: map[string][]string{"slug:ubuntu_20_04": {"x86_64"}}, installer: "slug:ubuntu_20_04"},
+		{name: "tie other arch loses", facility: "sjc1", priority: tieInstallerSlug, arches: map[string][]string{"slug:ubuntu_20_04": {"aarch64"}}, installer: "installer:custom"},
+		{name: "no tie arch specific loses", facility: "sjc1", arches: map[string][]string{"slug:ubuntu_20_04": {"x86_64"}}, installer: "installer:custom"},
+		{name: "default serves arch", facility: "sjc1", arches: map[string][]string{"installer:custom": {"aarch64"}, "slug:ubuntu_20_04": {"aarch64"}, "distro:ubuntu": {"aarch64"}}, installer: "default"},
+		{name: "none serves arch", facility: "sjc1", arches: map[string][]string{"installer:custom": {"aarch64"}, "slug:ubuntu_20_04": {"aarch64"}, "distro:ubuntu": {"aarch64"}, "default": {"aarch64"}}, installer: "installer:custom"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			i := Installers{
+				Default:     echo("default"),
+				ByFacility:  map[string]BootScript{tt.facility: echo("facility")},
+				ByInstaller: map[string]BootScript{"custom": echo("installer")},
+				BySlug:      map[string]BootScript{"ubuntu_20_04": echo("slug")},
+				ByDistro:    map[string]BootScript{"ubuntu": echo("distro")},
+				Arches:      tt.arches,
+				Priority:    tt.priority,
+			}
+			m := NewMock(t, "c3.small.x86", "ewr1")
+			m.SetOSInstaller("custom")
+			m.SetOSSlug("ubuntu_20_04")
+			m.SetOSDistro("ubuntu")
+
+			if installer, _ := i.selectInstaller(m.Job()); installer != tt.installer {
+				t.Fatalf("unexpected installer, want: %q, got: %q", tt.installer, installer)
+			}
+		})
+	}
+}
+
+func TestBootScriptRedirect(t *testing.T) {
+	i := Installers{
+		ByInstaller: map[string]BootScript{},
+		BySlug:      map[string]BootScript{},
+		ByDistro: map[string]BootScript{
+			"ubuntu": func(_ context.Context, _ Job, s *ipxe.Script) { s.Echo("installing ubuntu") },
+		},
+	}
+	for _, tt := range []struct {
+		name       string
+		redirectTo string
+		installer  string
+		want       string
+	}{
+		{name: "no redirect", installer: "distro:ubuntu", want: "installing ubuntu"},
+		{name: "redirect", redirectTo: "http://boots.ewr2.example.com/auto.ipxe", installer: "redirect", want: "chain --autofree http://boots.ewr2.example.com/auto.ipxe\n"},
+		{name: "not http", redirectTo: "tftp://boots.ewr2.example.com/auto.ipxe", installer: "distro:ubuntu", want: "installing ubuntu"},
+		{name: "no host", redirectTo: "http:///auto.ipxe", installer: "distro:ubuntu", want: "installing ubuntu"},
+		{name: "whitespace", redirectTo: "http://boots.ewr2.example.com/auto.ipxe shell", installer: "distro:ubuntu", want: "installing ubuntu"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMock(t, "c3.small.x86", "ewr1")
+			m.SetOSDistro("ubuntu")
+			m.SetRedirectTo(tt.redirectTo)
+			j := m.Job()
+
+			if installer, _ := i.selectInstaller(j); installer != tt.installer {
+				t.Fatalf("unexpected installer, want: %q, got: %q", tt.installer, installer)
+			}
+			_, script, err := j.bootScript(context.Background(), "auto", i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(script), tt.want) {
+				t.Fatalf("script does not contain %q:\n%s", tt.want, script)
+			}
+		})
+	}
+}
+
+func TestSelectInstallerRedirectAllowedHosts(t *testing.T) {
+	i := Installers{
+		ByDistro: map[string]BootScript{
+			"ubuntu": func(_ context.Context, _ Job, s *ipxe.Script) { s.Echo("installing ubuntu") },
+		},
+		AllowedHosts: allowHosts{"boots.ewr2.example.com": true},
+	}
+	for name, tt := range map[string]struct {
+		redirectTo string
+		want       string
+		refused    bool
+	}{
+		"allowed":     {redirectTo: "http://boots.ewr2.example.com/auto.ipxe", want: "chain --autofree http://boots.ewr2.example.com/auto.ipxe\n"},
+		"not allowed": {redirectTo: "http://evil.example.com/auto.ipxe", want: "is not to an allowed host", refused: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewMock(t, "c3.small.x86", "ewr1")
+			m.SetOSDistro("ubuntu")
+			m.SetRedirectTo(tt.redirectTo)
+			j := m.Job()
+
+			refused := testutil.ToFloat64(metrics.DisallowedURLHost.WithLabelValues("redirect"))
+			installer, script, err := j.bootScript(context.Background(), "auto", i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if installer != "redirect" {
+				t.Fatalf("want installer redirect, got %q", installer)
+			}
+			if !strings.Contains(string(script), tt.want) {
+				t.Fatalf("script does not contain %q:\n%s", tt.want, script)
+			}
+			if tt.refused && strings.Contains(string(script), "chain") {
+				t.Fatalf("want no chain to a disallowed host:\n%s", script)
+			}
+			if got := testutil.ToFloat64(metrics.DisallowedURLHost.WithLabelValues("redirect")) - refused; (got == 1) != tt.refused {
+				t.Fatalf("want refused %v, got %v refusals counted", tt.refused, got)
+			}
+		})
+	}
+}
+
+// allowHosts is a HostChecker allowing the hosts set to true.
+type allowHosts map[string]bool
+
+func (a allowHosts) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || !a[u.Hostname()] {
+		return errors.Errorf("host of url %q is not allowed", rawURL)
+	}
+
+	return nil
+}
+
+func TestServeBootScriptRange(t *testing.T) {
+	i := Installers{
+		ByInstaller: map[string]BootScript{},
+		BySlug:      map[string]BootScript{},
+		ByDistro: map[string]BootScript{
+			"ubuntu": func(_ context.Context, _ Job, s *ipxe.Script) { s.Echo("installing ubuntu") },
+		},
+	}
+	m := NewMock(t, "c3.small.x86", "ewr1")
+	m.SetOSDistro("ubuntu")
+	req := httptest.NewRequest(http.MethodGet, "/auto.ipxe", nil)
+	req.Header.Set("Range", "bytes=10-20")
+	w := httptest.NewRecorder()
+	m.Job().ServeFile(w, req, i)
+
+	// scripts are rendered per request, ranges are not supported
+	if w.Code != http.StatusOK {
+		t.Fatalf("want status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := w.Header().Get("Accept-Ranges"); got != "none" {
+		t.Fatalf("want Accept-Ranges none, got %q", got)
+	}
+	if got, want := w.Header().Get("Content-Length"), strconv.Itoa(w.Body.Len()); got != want {
+		t.Fatalf("want Content-Length %s, got %s", want, got)
+	}
+	if !strings.Contains(w.Body.String(), "installing ubuntu") {
+		t.Fatalf("want the whole script, got:\n%s", w.Body.String())
+	}
+}
@@ -8,6 +8,7 @@ import (
 	dhcp4 "github.com/packethost/dhcp4-go"
 	"github.com/pkg/errors"
 	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/conf"
 	"github.com/tinkerbell/boots/dhcp"
 	"github.com/tinkerbell/boots/ipxe"
 	"go.opentelemetry.io/otel/trace"
@@ -43,7 +44,18 @@ func (j Job) ServeDHCP(ctx context.Context, w dhcp4.ReplyWriter, req *dhcp4.Pack
 	// setup reply
 	span.AddEvent("dhcp.NewReply")
 	// only DISCOVER and REQUEST get replies; reply is nil for ignored reqs
-	reply := dhcp.NewReply(w, req)
+	var reply dhcp.Reply
+	if j.ProxyDHCP {
+		// another DHCP server hands out addresses, so only PXE clients allowed to boot get a reply
+		if !dhcp.IsPXE(req) || !j.AllowPXE() || j.NoBoot {
+			return false, nil
+		}
+		if proxyReply := dhcp.NewProxyReply(w, req, conf.PublicIPv4); proxyReply != nil {
+			reply = proxyReply
+		}
+	} else {
+		reply = dhcp.NewReply(w, req)
+	}
 	if reply == nil {
 		return false, nil // ignore the request
 	}
@@ -64,12 +76,18 @@ func (j Job) ServeDHCP(ctx context.Context, w dhcp4.ReplyWriter, req *dhcp4.Pack
 
 func (j Job) configureDHCP(ctx context.Context, rep, req *dhcp4.Packet) bool {
 	span := trace.SpanFromContext(ctx)
-	if !j.dhcp.ApplyTo(rep) {
+	if !j.ProxyDHCP && !j.dhcp.ApplyTo(rep) {
 		return false
 	}
+	// the record's options are set last, so they override the PXE options
+	defer j.setRecordOptions(rep)
+
+	if j.NoBoot {
+		span.AddEvent("did not SetupPXE because booting is disabled")
 
+		return true
+	}
 	if dhcp.SetupPXE(ctx, rep, req) {
-		isARM := dhcp.IsARM(req)
 		if dhcp.Arch(req) != j.Arch() {
 			span.AddEvent(fmt.Sprintf("arch mismatch: got %q and expected %q", dhcp.Arch(req), j.Arch()))
 			j.With("dhcp", dhcp.Arch(req), "job", j.Arch()).Info("arch mismatch, using dhcp")
@@ -80,12 +98,23 @@ func (j Job) configureDHCP(ctx context.Context, rep, req *dhcp4.Packet) bool {
 			j.With("dhcp", isUEFI, "job", j.IsUEFI()).Info("uefi mismatch, using dhcp")
 		}
 
-		isTinkerbellIPXE := ipxe.IsTinkerbellIPXE(req)
+		// Tinkerbell iPXE, and the iPXE builds of IPXEUserClasses, boot the script, other PXE
+		// clients are sent the iPXE binary to chainload
+		isTinkerbellIPXE := j.IPXEUserClasses.IsScriptCapable(req)
 		if isTinkerbellIPXE {
 			ipxe.Setup(rep)
 		}
 
-		j.setPXEFilename(rep, isTinkerbellIPXE, isARM, isUEFI, dhcp.IsHTTPClient(req))
+		// the binary only matters to clients that are not yet running our iPXE
+		binary, err := j.ArchPolicy.Binary(req)
+		if err != nil && !isTinkerbellIPXE {
+			span.AddEvent(err.Error())
+			j.With("dhcp", dhcp.ProcessorArchType(req)).Info(err)
+
+			return true
+		}
+
+		j.setPXEFilename(rep, isTinkerbellIPXE, binary, dhcp.IsHTTPClient(req))
 	} else {
 		span.AddEvent("did not SetupPXE because packet is not a PXE request")
 	}
@@ -93,6 +122,30 @@ func (j Job) configureDHCP(ctx context.Context, rep, req *dhcp4.Packet) bool {
 	return true
 }
 
+// setRecordOptions merges the DHCP options of the hardware record into rep, overriding
+// the ones Boots set. The options that cannot be set are logged and skipped.
+func (j Job) setRecordOptions(rep *dhcp4.Packet) {
+	opts, errs := dhcp.RecordOptions(j.hardware.DHCPOptions(j.mac))
+	for _, err := range errs {
+		j.info(j.Logger, errors.WithMessage(err, "skipping hardware record dhcp option"))
+	}
+	for o, v := range opts {
+		rep.SetOption(o, v)
+	}
+}
+
+// RequestsOtherAddress reports whether req is a DHCPREQUEST for an address other than
+// the one j assigns, so the lease it asks for is not valid. False if we are not the
+// provisioner of the hardware, or it has no address, as the lease is not ours to refuse.
+func (j Job) RequestsOtherAddress(req *dhcp4.Packet) bool {
+	if req.GetMessageType() != dhcp4.MessageTypeRequest || !j.areWeProvisioner() {
+		return false
+	}
+	requested, addr := dhcp.RequestedIP(req), j.IP()
+
+	return requested != nil && addr != nil && !requested.Equal(addr)
+}
+
 func (j Job) areWeProvisioner() bool {
 	if j.hardware.HardwareProvisioner() == "" {
 		return true
@@ -101,7 +154,8 @@ func (j Job) areWeProvisioner() bool {
 	return j.hardware.HardwareProvisioner() == j.ProvisionerEngineName()
 }
 
-func (j Job) setPXEFilename(rep *dhcp4.Packet, isTinkerbellIPXE, isARM, isUEFI, isHTTPClient bool) {
+// setPXEFilename sets the boot filename, binary is the iPXE binary for clients not yet running Tinkerbell iPXE.
+func (j Job) setPXEFilename(rep *dhcp4.Packet, isTinkerbellIPXE bool, binary string, isHTTPClient bool) {
 	if j.HardwareState() == "in_use" {
 		if j.InstanceID() == "" {
 			j.Error(errors.New("setPXEFilename called on a job with no instance"))
@@ -110,7 +164,7 @@ func (j Job) setPXEFilename(rep *dhcp4.Packet, isTinkerbellIPXE, isARM, isUEFI,
 		}
 
 		if j.instance.State != "active" {
-			j.With("hardware.state", j.HardwareState(), "instance.state", j.instance.State).Info("device should NOT be trying to PXE boot")
+			j.info(j.With("hardware.state", j.HardwareState(), "instance.state", j.instance.State), "device should NOT be trying to PXE boot")
 
 			return
 		}
@@ -119,7 +173,7 @@ func (j Job) setPXEFilename(rep *dhcp4.Packet, isTinkerbellIPXE, isARM, isUEFI,
 		// nics don't timeout.... but why though?
 		if !j.AllowPXE() && j.hardware.OperatingSystem().OsSlug != "custom_ipxe" {
 			err := errors.New("device should NOT be trying to PXE boot")
-			j.With("hardware.state", j.HardwareState(), "allow_pxe", j.AllowPXE(), "os", j.hardware.OperatingSystem().OsSlug).Info(err)
+			j.info(j.With("hardware.state", j.HardwareState(), "allow_pxe", j.AllowPXE(), "os", j.hardware.OperatingSystem().OsSlug), err)
 
 			return
 		}
@@ -127,18 +181,14 @@ func (j Job) setPXEFilename(rep *dhcp4.Packet, isTinkerbellIPXE, isARM, isUEFI,
 	}
 
 	var filename string
-	httpPrefix := j.BootsBaseURL
+	httpPrefix, scheme := j.BootsBaseURL, j.bootsScheme()
 	switch {
 	case !isTinkerbellIPXE:
 		httpPrefix = j.IpxeBaseURL
-		switch {
-		case isARM:
-			filename = "snp.efi"
-		case isUEFI:
-			filename = "ipxe.efi"
-		default:
-			filename = "undionly.kpxe"
+		if j.IpxeScheme != "" {
+			scheme = j.IpxeScheme
 		}
+		filename = binary
 	case !j.AllowPXE():
 		// Always honor allow_pxe.
 		// We set a filename because if a machine is actually trying to PXE and nothing is sent it may hang for
@@ -150,7 +200,7 @@ func (j Job) setPXEFilename(rep *dhcp4.Packet, isTinkerbellIPXE, isARM, isUEFI,
 		// TODO(mmlb) try to move this logic to much earlier in the function, maybe all the way as the first thing even.
 
 		os := j.OperatingSystem()
-		j.With("instance.state", j.instance.State, "os_slug", os.Slug, "os_distro", os.Distro, "os_version", os.Version).Info()
+		j.info(j.With("instance.state", j.instance.State, "os_slug", os.Slug, "os_distro", os.Distro, "os_version", os.Version))
 		filename = "nonexistent"
 	default:
 		isHTTPClient = true
@@ -164,7 +214,7 @@ func (j Job) setPXEFilename(rep *dhcp4.Packet, isTinkerbellIPXE, isARM, isUEFI,
 		return
 	}
 
-	dhcp.SetFilename(rep, filename, j.NextServer, isHTTPClient, httpPrefix)
+	dhcp.SetFilename(rep, filename, j.NextServer, isHTTPClient, scheme, httpPrefix)
 }
 
 // VLANID returns the VLAN ID for the job.
@@ -2,7 +2,9 @@ package job
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"net"
 	"os"
 	"testing"
 
@@ -12,11 +14,16 @@ import (
 	"github.com/tinkerbell/boots/client"
 	"github.com/tinkerbell/boots/client/standalone"
 	"github.com/tinkerbell/boots/conf"
+	"github.com/tinkerbell/boots/dhcp"
+	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/metrics"
 )
 
 func TestMain(m *testing.M) {
 	logger, _ := l.Init("github.com/tinkerbell/boots")
 	Init(logger)
+	dhcp.Init(logger)
+	metrics.Init(logger)
 	os.Exit(m.Run())
 }
 
@@ -24,18 +31,19 @@ func TestSetPXEFilename(t *testing.T) {
 	conf.PublicFQDN = "boots-testing.packet.net"
 
 	setPXEFilenameTests := []struct {
-		name       string
-		hState     string
-		id         string
-		iState     string
-		slug       string
-		plan       string
-		allowPXE   bool
-		packet     bool
-		arm        bool
-		uefi       bool
-		httpClient bool
-		filename   string
+		name        string
+		hState      string
+		id          string
+		iState      string
+		slug        string
+		plan        string
+		allowPXE    bool
+		packet      bool
+		binary      string
+		httpClient  bool
+		ipxeScheme  string
+		bootsScheme string
+		filename    string
 	}{
 		{
 			name:   "just in_use",
@@ -68,18 +76,32 @@ func TestSetPXEFilename(t *testing.T) {
 			filename: "undionly.kpxe",
 		},
 		{
-			name: "arm",
-			arm:  true, filename: "snp.efi",
+			name:   "arm",
+			binary: "snp.efi", filename: "snp.efi",
 		},
 		{
-			name: "x86 uefi",
-			uefi: true, filename: "ipxe.efi",
+			name:   "x86 uefi",
+			binary: "ipxe.efi", filename: "ipxe.efi",
 		},
 		{
-			name: "x86 uefi http client",
-			uefi: true, allowPXE: true, httpClient: true,
+			name:   "x86 uefi http client",
+			binary: "ipxe.efi", allowPXE: true, httpClient: true,
 			filename: "http://" + conf.PublicFQDN + "/ipxe/ipxe.efi",
 		},
+		{
+			name:   "x86 uefi http client https ipxe server",
+			binary: "ipxe.efi", allowPXE: true, httpClient: true, ipxeScheme: "https",
+			filename: "https://" + conf.PublicFQDN + "/ipxe/ipxe.efi",
+		},
+		{
+			name:   "x86 uefi http client https boots",
+			binary: "ipxe.efi", allowPXE: true, httpClient: true, bootsScheme: "https",
+			filename: "https://" + conf.PublicFQDN + "/ipxe/ipxe.efi",
+		},
+		{
+			name:   "packet iPXE PXE allowed https ipxe server",
+			packet: true, id: "$instance_id", allowPXE: true, ipxeScheme: "https", filename: "http://" + conf.PublicFQDN + "/auto.ipxe",
+		},
 		{
 			name:     "all defaults",
 			filename: "undionly.kpxe",
@@ -101,6 +123,9 @@ func TestSetPXEFilename(t *testing.T) {
 			if tt.plan == "" {
 				tt.plan = "0"
 			}
+			if tt.binary == "" {
+				tt.binary = "undionly.kpxe"
+			}
 
 			instance := &client.Instance{
 				ID:       tt.id,
@@ -114,7 +139,7 @@ func TestSetPXEFilename(t *testing.T) {
 				},
 			}
 			j := Job{
-				Logger: joblog.With("index", i, "hState", tt.hState, "id", tt.id, "iState", tt.iState, "slug", tt.slug, "plan", tt.plan, "allowPXE", tt.allowPXE, "packet", tt.packet, "arm", tt.arm, "uefi", tt.uefi, "filename", tt.filename),
+				Logger: joblog.With("index", i, "hState", tt.hState, "id", tt.id, "iState", tt.iState, "slug", tt.slug, "plan", tt.plan, "allowPXE", tt.allowPXE, "packet", tt.packet, "binary", tt.binary, "filename", tt.filename),
 				hardware: &standalone.HardwareStandalone{
 					ID: "$hardware_id",
 					Metadata: client.Metadata{
@@ -128,10 +153,12 @@ func TestSetPXEFilename(t *testing.T) {
 				instance:     instance,
 				NextServer:   conf.PublicIPv4,
 				IpxeBaseURL:  conf.PublicFQDN + "/ipxe",
+				IpxeScheme:   tt.ipxeScheme,
 				BootsBaseURL: conf.PublicFQDN,
+				BootsScheme:  tt.bootsScheme,
 			}
 			rep := dhcp4.NewPacket(42)
-			j.setPXEFilename(&rep, tt.packet, tt.arm, tt.uefi, tt.httpClient)
+			j.setPXEFilename(&rep, tt.packet, tt.binary, tt.httpClient)
 			filename := string(bytes.TrimRight(rep.File(), "\x00"))
 
 			if tt.filename != filename {
@@ -147,41 +174,55 @@ func TestAllowPXE(t *testing.T) {
 		hw       bool
 		instance bool
 		iid      string
+		// noNetboot leaves out the interfaces, so the record has no netboot settings
+		noNetboot    bool
+		allowDefault bool
 	}{
 		{want: true, hw: true},
 		{want: false, hw: false, instance: true},
 		{want: true, hw: false, instance: true, iid: "id"},
 		{want: false, hw: false, instance: false, iid: "id"},
+		{want: false, hw: false, noNetboot: true},
+		{want: true, hw: false, noNetboot: true, allowDefault: true},
+		{want: false, hw: false, allowDefault: true},
 	} {
-		name := fmt.Sprintf("want=%t, hardware=%t, instance=%t, instance_id=%s", tt.want, tt.hw, tt.instance, tt.iid)
+		name := fmt.Sprintf("want=%t, hardware=%t, instance=%t, instance_id=%s, no_netboot=%t, allow_default=%t", tt.want, tt.hw, tt.instance, tt.iid, tt.noNetboot, tt.allowDefault)
 		t.Run(name, func(t *testing.T) {
-			j := Job{
-				hardware: &standalone.HardwareStandalone{
-					ID: "$hardware_id",
-					Metadata: client.Metadata{
-						Instance: &client.Instance{
-							AllowPXE: tt.hw,
-						},
+			hw := &standalone.HardwareStandalone{
+				ID: "$hardware_id",
+				Metadata: client.Metadata{
+					Instance: &client.Instance{
+						AllowPXE: tt.hw,
 					},
-					Network: client.Network{
-						Interfaces: []client.NetworkInterface{
-							{
-								Netboot: client.Netboot{
-									AllowPXE: tt.hw,
-								},
+				},
+				Network: client.Network{
+					Interfaces: []client.NetworkInterface{
+						{
+							Netboot: client.Netboot{
+								AllowPXE: tt.hw,
 							},
 						},
 					},
 				},
+			}
+			if tt.noNetboot {
+				hw.Network.Interfaces = nil
+			}
+			j := Job{
+				hardware: hw,
 				instance: &client.Instance{
 					ID:       tt.iid,
 					AllowPXE: tt.instance,
 				},
+				allowPXEDefault: tt.allowDefault,
 			}
 			got := j.AllowPXE()
 			if got != tt.want {
 				t.Fatalf("unexpected return, want: %t, got %t", tt.want, got)
 			}
+			if byDefault := j.AllowPXEByDefault(); byDefault != (tt.noNetboot && tt.allowDefault) {
+				t.Fatalf("unexpected allowed by default, got %t", byDefault)
+			}
 		})
 	}
 }
@@ -220,3 +261,138 @@ func TestIsSpecialOS(t *testing.T) {
 		})
 	}
 }
+
+func TestSetRecordOptions(t *testing.T) {
+	m := NewMock(t, "c3.small.x86", "ewr1")
+	m.SetDHCPOptions(map[string]string{"43": "0x0104c0a80101", "60": "PXEClient", "53": "0x01"})
+	rep := dhcp4.NewPacket(dhcp4.BootReply)
+	rep.SetOption(dhcp4.OptionVendorSpecific, []byte{0xff})
+	rep.SetOption(dhcp4.OptionDHCPMsgType, []byte{byte(dhcp4.MessageTypeOffer)})
+
+	m.Job().setRecordOptions(&rep)
+	want := map[dhcp4.Option][]byte{
+		dhcp4.OptionVendorSpecific: {0x01, 0x04, 0xc0, 0xa8, 0x01, 0x01},
+		dhcp4.OptionClassID:        []byte("PXEClient"),
+		dhcp4.OptionDHCPMsgType:    {byte(dhcp4.MessageTypeOffer)},
+	}
+	for o, v := range want {
+		if got, _ := rep.GetOption(o); !bytes.Equal(got, v) {
+			t.Fatalf("want option %d %x, got %x", o, v, got)
+		}
+	}
+}
+
+func TestConfigureDHCPUserClass(t *testing.T) {
+	conf.PublicFQDN = "boots-testing.packet.net"
+	tests := map[string]struct {
+		userClass string
+		http      bool
+		classes   ipxe.UserClasses
+		filenames string
+		filename  string
+	}{
+		"raw pxe":                       {filename: "undionly.kpxe"},
+		"tinkerbell ipxe":               {userClass: "Tinkerbell", http: true, filename: "http://" + conf.PublicFQDN + "/auto.ipxe"},
+		"stock ipxe":                    {userClass: "iPXE", http: true, filename: "http://" + conf.PublicFQDN + "/ipxe/undionly.kpxe"},
+		"stock ipxe allowed":            {userClass: "iPXE", http: true, classes: ipxe.UserClasses{"iPXE"}, filename: "http://" + conf.PublicFQDN + "/auto.ipxe"},
+		"stock ipxe without http":       {userClass: "iPXE", classes: ipxe.UserClasses{"iPXE"}, filename: "undionly.kpxe"},
+		"other user class":              {userClass: "Custom", http: true, classes: ipxe.UserClasses{"iPXE"}, filename: "undionly.kpxe"},
+		"custom user class":             {userClass: "Custom", http: true, classes: ipxe.UserClasses{"iPXE", "Custom"}, filename: "http://" + conf.PublicFQDN + "/auto.ipxe"},
+		"raw pxe mapped":                {filenames: "0/pxe=undionly-legacy.kpxe,0/ipxe=ipxe.pxe", filename: "undionly-legacy.kpxe"},
+		"stock ipxe mapped":             {userClass: "iPXE", http: true, filenames: "0/pxe=undionly-legacy.kpxe,0/ipxe=ipxe.pxe", filename: "http://" + conf.PublicFQDN + "/ipxe/ipxe.pxe"},
+		"stock ipxe allowed not mapped": {userClass: "iPXE", http: true, classes: ipxe.UserClasses{"iPXE"}, filenames: "0/ipxe=ipxe.pxe", filename: "http://" + conf.PublicFQDN + "/auto.ipxe"},
+		"tinkerbell ipxe not mapped":    {userClass: "Tinkerbell", http: true, filenames: "0/ipxe=ipxe.pxe", filename: "http://" + conf.PublicFQDN + "/auto.ipxe"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := NewMock(t, "c3.small.x86", "ewr1")
+			m.hardware.(*standalone.HardwareStandalone).Network.Interfaces[0].Netboot.AllowPXE = true
+			j := m.Job()
+			j.ProxyDHCP = true
+			j.NextServer = conf.PublicIPv4
+			j.IpxeBaseURL = conf.PublicFQDN + "/ipxe"
+			j.BootsBaseURL = conf.PublicFQDN
+			j.IPXEUserClasses = tt.classes
+			if tt.filenames != "" {
+				p, err := dhcp.NewArchPolicy("", tt.filenames, "bios", "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				j.ArchPolicy = p
+			}
+
+			req := dhcp4.NewPacket(dhcp4.BootRequest)
+			req.SetString(dhcp4.OptionClassID, "PXEClient:Arch:00000:UNDI:002001")
+			req.SetUint16(dhcp4.OptionClientSystem, 0)
+			if tt.userClass != "" {
+				req.SetString(dhcp4.OptionUserClass, tt.userClass)
+			}
+			if tt.http {
+				req.SetOption(ipxe.EncapsulatedOptions, dhcp4.OptionMap{ipxe.FeatureHTTP: {1}}.Serialize())
+			}
+			rep := dhcp4.NewPacket(dhcp4.BootReply)
+			if !j.configureDHCP(context.Background(), &rep, &req) {
+				t.Fatal("want DHCP configured")
+			}
+			if filename := string(bytes.TrimRight(rep.File(), "\x00")); filename != tt.filename {
+				t.Fatalf("want filename %q, got %q", tt.filename, filename)
+			}
+		})
+	}
+}
+
+func TestConfigureDHCPNoBoot(t *testing.T) {
+	m := NewMock(t, "c3.small.x86", "ewr1")
+	m.hardware.(*standalone.HardwareStandalone).Network.Interfaces[0].Netboot.AllowPXE = true
+	j := m.Job()
+	j.ProxyDHCP = true
+	j.NoBoot = true
+
+	req := dhcp4.NewPacket(dhcp4.BootRequest)
+	req.SetString(dhcp4.OptionClassID, "PXEClient:Arch:00000:UNDI:002001")
+	req.SetString(dhcp4.OptionUserClass, "Tinkerbell")
+	rep := dhcp4.NewPacket(dhcp4.BootReply)
+	if !j.configureDHCP(context.Background(), &rep, &req) {
+		t.Fatal("want DHCP configured")
+	}
+	if filename := string(bytes.TrimRight(rep.File(), "\x00")); filename != "" {
+		t.Fatalf("want no boot filename, got %q", filename)
+	}
+	if _, ok := rep.GetOption(dhcp4.OptionVendorSpecific); ok {
+		t.Fatal("want no PXE vendor options")
+	}
+}
+
+func TestRequestsOtherAddress(t *testing.T) {
+	tests := map[string]struct {
+		msgType     dhcp4.MessageType
+		assigned    string
+		requested   string
+		provisioner string
+		want        bool
+	}{
+		"assigned address":    {msgType: dhcp4.MessageTypeRequest, assigned: "192.168.1.5", requested: "192.168.1.5"},
+		"other address":       {msgType: dhcp4.MessageTypeRequest, assigned: "192.168.1.5", requested: "192.168.1.6", want: true},
+		"no requested":        {msgType: dhcp4.MessageTypeRequest, assigned: "192.168.1.5"},
+		"no assigned":         {msgType: dhcp4.MessageTypeRequest, requested: "192.168.1.6"},
+		"discover":            {msgType: dhcp4.MessageTypeDiscover, assigned: "192.168.1.5", requested: "192.168.1.6"},
+		"another provisioner": {msgType: dhcp4.MessageTypeRequest, assigned: "192.168.1.5", requested: "192.168.1.6", provisioner: "other"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := NewMock(t, "c3.small.x86", "ewr1")
+			if tt.assigned != "" {
+				m.SetDHCP(net.ParseIP(tt.assigned), "")
+			}
+			m.hardware.(*standalone.HardwareStandalone).Metadata.ProvisionerEngine = tt.provisioner
+			req := dhcp4.NewPacket(dhcp4.BootRequest)
+			req.SetMessageType(tt.msgType)
+			if tt.requested != "" {
+				req.SetIP(dhcp4.OptionAddressRequest, net.ParseIP(tt.requested))
+			}
+			if got := m.Job().RequestsOtherAddress(&req); got != tt.want {
+				t.Fatalf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
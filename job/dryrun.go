@@ -0,0 +1,18 @@
+package job
+
+import "context"
+
+type dryRunKey struct{}
+
+// WithDryRun returns ctx marked as a dry run: the jobs created with it have DryRun set and
+// their lookups are not recorded, see LastLookup.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun reports whether ctx is marked as a dry run, see WithDryRun.
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+
+	return dryRun
+}
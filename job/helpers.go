@@ -24,12 +24,30 @@ func (j Job) IsUEFI() bool {
 	return false
 }
 
+// HasHardwareArch reports whether the hardware record has an arch for the interface of j,
+// otherwise Arch is the default arch.
+func (j Job) HasHardwareArch() bool {
+	return j.hardware != nil && j.hardware.HardwareArch(j.mac) != ""
+}
+
 func (j Job) Arch() string {
 	if h := j.hardware; h != nil {
-		return h.HardwareArch(j.mac)
+		if arch := h.HardwareArch(j.mac); arch != "" {
+			return arch
+		}
 	}
 
-	return ""
+	return j.defaults.Arch
+}
+
+// BootArch returns the arch installers are selected for, the arch the machine reported
+// over DHCP if it is known, otherwise the hardware record's arch.
+func (j Job) BootArch() string {
+	if j.ClientArch != "" {
+		return j.ClientArch
+	}
+
+	return j.Arch()
 }
 
 func (j Job) BootDriveHint() string {
@@ -155,10 +173,12 @@ func (j Job) HardwareID() client.HardwareID {
 
 func (j Job) FacilityCode() string {
 	if h := j.hardware; h != nil {
-		return h.HardwareFacilityCode()
+		if code := h.HardwareFacilityCode(); code != "" {
+			return code
+		}
 	}
 
-	return ""
+	return j.defaults.Facility
 }
 
 func (j Job) PlanSlug() string {
@@ -185,6 +205,16 @@ func (j Job) Manufacturer() string {
 	return ""
 }
 
+// Hostname returns the hostname the machine is given by DHCP.
+func (j Job) Hostname() string {
+	return j.dhcp.Hostname()
+}
+
+// IP returns the IPv4 address the machine is given by DHCP.
+func (j Job) IP() net.IP {
+	return j.dhcp.Address()
+}
+
 // PrimaryNIC returns the mac address of the NIC we expect to be dhcp/pxe'ing.
 func (j Job) PrimaryNIC() net.HardwareAddr {
 	return j.mac
@@ -220,6 +250,16 @@ func (j Job) CanWorkflow() bool {
 	return j.hardware.HardwareAllowWorkflow(j.mac)
 }
 
+// RedirectTo returns the URL of the iPXE script on another boot server the hardware
+// record redirects the machine to, empty if it boots locally.
+func (j Job) RedirectTo() string {
+	if h := j.hardware; h != nil {
+		return h.RedirectTo(j.mac)
+	}
+
+	return ""
+}
+
 func (j Job) OSIEBaseURL() string {
 	if h := j.hardware; h != nil {
 		return j.hardware.OSIEBaseURL(j.mac)
@@ -243,3 +283,13 @@ func (j Job) InitrdPath() string {
 
 	return ""
 }
+
+// KernelArgs returns the hardware record's kernel args, the most specific of the
+// installer kernel args, see installers.MergeKernelArgs.
+func (j Job) KernelArgs() string {
+	if h := j.hardware; h != nil {
+		return h.KernelArgs(j.mac)
+	}
+
+	return ""
+}
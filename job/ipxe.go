@@ -3,10 +3,16 @@ package job
 import (
 	"context"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
 	"github.com/tinkerbell/boots/conf"
 	"github.com/tinkerbell/boots/ipxe"
+	"github.com/tinkerbell/boots/metrics"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
@@ -50,28 +56,67 @@ func (i *Installers) RegisterSlug(name string, builder BootScript) {
 	i.BySlug[name] = builder
 }
 
+// RegisterFacility makes builder the boot script of the hardware in the facility with code name.
+func (i *Installers) RegisterFacility(name string, builder BootScript) {
+	if _, ok := i.ByFacility[name]; ok {
+		err := errors.Errorf("facility %q already registered", name)
+		joblog.Fatal(err, "facility", name)
+	}
+	i.ByFacility[name] = builder
+}
+
 func (j Job) serveBootScript(ctx context.Context, w http.ResponseWriter, name string, i Installers) {
 	span := trace.SpanFromContext(ctx)
 	span.SetAttributes(attribute.String("boots.script_name", name))
 
-	scripts := map[string]BootScript{
-		"auto":  i.auto,
-		"shell": shell,
-	}
-	fn, ok := scripts[name]
-	if !ok {
+	installer, script, err := j.bootScript(ctx, name, i)
+	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
-		err := errors.Errorf("boot script %q not found", name)
-		j.With("script", name).Error(err)
+		j.With("script", name, "installer", installer).Error(err)
 		span.SetStatus(codes.Error, err.Error())
 
 		return
 	}
+	span.SetAttributes(attribute.String("ipxe-script", string(script)))
+	if installer != "" {
+		span.SetAttributes(attribute.String("boots.installer", installer))
+		metrics.BootScripts.WithLabelValues(installer).Inc()
+		j.With("script", name, "installer", installer).Info("serving boot script")
+	}
+
+	// scripts are rendered per request, a Range request gets the whole script
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("Content-Length", strconv.Itoa(len(script)))
+	if _, err := w.Write(script); err != nil {
+		j.With("script", name).Error(errors.Wrap(err, "unable to write boot script"))
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+}
+
+// bootScript renders the boot script called name, and returns what selected its installer
+// for the auto script, see selectInstaller. An error is returned if there is no such script.
+func (j Job) bootScript(ctx context.Context, name string, i Installers) (string, []byte, error) {
+	var installer string
+	var fn BootScript
+	switch name {
+	case "auto":
+		installer, fn = i.selectInstaller(j)
+		if arch := j.BootArch(); !i.supportsArch(installer, arch) {
+			return installer, nil, errors.Errorf("installer %s does not support arch %q", installer, arch)
+		}
+	case "shell":
+		fn = shell
+	default:
+		return "", nil, errors.Errorf("boot script %q not found", name)
+	}
 
+	span := trace.SpanFromContext(ctx)
 	s := ipxe.NewScript()
 	s.Set("iface", j.InterfaceName(0))
 	s.Or("shell")
-	s.Set("tinkerbell", "http://"+conf.PublicFQDN)
+	s.Set("tinkerbell", j.BootsURL())
 	s.Set("syslog_host", conf.PublicSyslogFQDN)
 	s.Set("ipxe_cloud_config", "packet")
 
@@ -81,46 +126,179 @@ func (j Job) serveBootScript(ctx context.Context, w http.ResponseWriter, name st
 	}
 
 	fn(ctx, j, s)
-	script := s.Bytes()
-	span.SetAttributes(attribute.String("ipxe-script", string(script)))
+	if err := s.Err(); err != nil {
+		return installer, nil, err
+	}
 
-	if _, err := w.Write(script); err != nil {
-		j.With("script", name).Error(errors.Wrap(err, "unable to write boot script"))
-		span.SetStatus(codes.Error, err.Error())
+	return installer, s.Bytes(), nil
+}
 
-		return
+// RestrictArch makes the installer selected as installer, one of installer:<name>,
+// slug:<slug>, distro:<distro> or default, only serve machines of arches.
+func (i *Installers) RestrictArch(installer string, arches []string) {
+	if i.Arches == nil {
+		i.Arches = map[string][]string{}
+	}
+	i.Arches[installer] = arches
+}
+
+// supportsArch reports whether installer serves machines of arch. Installers
+// without an arch restriction serve all arches.
+func (i Installers) supportsArch(installer, arch string) bool {
+	arches, ok := i.Arches[installer]
+	if !ok {
+		return true
+	}
+	for _, a := range arches {
+		if a == arch {
+			return true
+		}
 	}
+
+	return false
+}
+
+// archSpecific reports whether installer is restricted to some arches, see RestrictArch.
+func (i Installers) archSpecific(installer string) bool {
+	_, ok := i.Arches[installer]
+
+	return ok
 }
 
-func (i Installers) auto(ctx context.Context, j Job, s *ipxe.Script) {
+// installerKinds are the kinds of installers selected by the hardware record, in their
+// default priority order, see Installers.Priority.
+var installerKinds = []string{"facility", "installer", "slug", "distro"}
+
+// installerCandidate is an installer matching a machine, one of each kind at most.
+type installerCandidate struct {
+	kind string
+	name string
+	fn   BootScript
+}
+
+// rank returns the priority of installers of kind, lower first. Kinds share a rank when
+// they tie, see Installers.Priority.
+func (i Installers) rank(kind string) int {
+	if i.Priority == nil {
+		for n, k := range installerKinds {
+			if k == kind {
+				return n
+			}
+		}
+	}
+
+	return i.Priority[kind]
+}
+
+// selectInstaller returns the boot script the auto script uses for j, and what
+// selected it: redirect, facility:<code>, installer:<name>, slug:<slug>,
+// distro:<distro>, default or shell.
+//
+// The installers matching j are tried in priority order, and the first one serving
+// the arch of j wins. Of installers with the same priority, one restricted to the arch
+// of j beats one serving all arches, and the remaining ties go to the kind that comes
+// first in installerKinds. If no installer serves the arch of j, the default installer
+// does, or else the first match is returned so the boot is refused for its arch.
+func (i Installers) selectInstaller(j Job) (string, BootScript) {
+	if u := j.RedirectTo(); u != "" {
+		err := validateRedirect(u)
+		if err == nil && i.AllowedHosts != nil {
+			if err := i.AllowedHosts.Check(u); err != nil {
+				return "redirect", disallowedRedirect(err)
+			}
+		}
+		if err == nil {
+			return "redirect", redirect(u)
+		}
+		j.With("redirect_to", u).Error(err, "ignoring invalid redirect, booting locally")
+	}
 	if j.instance == nil {
 		j.Info(errors.New("no device to boot, providing an iPXE shell"))
-		shell(ctx, j, s)
 
-		return
+		return "shell", shell
+	}
+	os := j.hardware.OperatingSystem()
+	if os == nil {
+		// records without an instance, e.g. booted by the -allow-pxe-default policy, get the default installer
+		os = &client.OperatingSystem{}
+	}
+	keys := map[string]string{"facility": j.FacilityCode(), "installer": os.Installer, "slug": os.Slug, "distro": os.Distro}
+	scripts := map[string]map[string]BootScript{"facility": i.ByFacility, "installer": i.ByInstaller, "slug": i.BySlug, "distro": i.ByDistro}
+	var candidates []installerCandidate
+	for _, kind := range installerKinds {
+		if f, ok := scripts[kind][keys[kind]]; ok {
+			candidates = append(candidates, installerCandidate{kind: kind, name: kind + ":" + keys[kind], fn: f})
+		}
 	}
-	if f, ok := i.ByInstaller[j.hardware.OperatingSystem().Installer]; ok {
-		f(ctx, j, s)
+	sort.SliceStable(candidates, func(a, b int) bool { return i.rank(candidates[a].kind) < i.rank(candidates[b].kind) })
 
-		return
+	arch := j.BootArch()
+	best := -1
+	for n, c := range candidates {
+		if !i.supportsArch(c.name, arch) {
+			continue
+		}
+		if best == -1 {
+			best = n
+
+			continue
+		}
+		if b := candidates[best]; i.rank(c.kind) == i.rank(b.kind) && i.archSpecific(c.name) && !i.archSpecific(b.name) {
+			best = n
+		}
 	}
-	if f, ok := i.BySlug[j.hardware.OperatingSystem().Slug]; ok {
-		f(ctx, j, s)
+	switch {
+	case best != -1:
+		return candidates[best].name, candidates[best].fn
+	case i.Default != nil && (len(candidates) == 0 || i.supportsArch("default", arch)):
+		return "default", i.Default
+	case len(candidates) > 0:
+		return candidates[0].name, candidates[0].fn
+	}
+	j.With("slug", os.Slug, "distro", os.Distro).Error(errors.New("unsupported slug/distro"))
 
-		return
+	return "shell", shell
+}
+
+// validateRedirect checks that u is an absolute http or https URL that can be chained to.
+func validateRedirect(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return errors.Wrap(err, "invalid redirect_to")
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return errors.Errorf("invalid redirect_to %q, must be an http or https URL", u)
+	}
+	if parsed.Host == "" {
+		return errors.Errorf("invalid redirect_to %q, has no host", u)
+	}
+	if strings.ContainsAny(u, " \t\r\n") {
+		return errors.Errorf("invalid redirect_to %q, must not contain whitespace", u)
 	}
-	if f, ok := i.ByDistro[j.hardware.OperatingSystem().Distro]; ok {
-		f(ctx, j, s)
 
-		return
+	return nil
+}
+
+// redirect returns a boot script chaining to the script at u on another boot server.
+func redirect(u string) BootScript {
+	return func(_ context.Context, j Job, s *ipxe.Script) {
+		j.With("redirect_to", u).Info("redirecting to another boot server")
+		s.Chain(u)
 	}
-	if i.Default != nil {
-		i.Default(ctx, j, s)
+}
 
-		return
+// disallowedRedirect returns a boot script refusing a redirect to a host that is not
+// allowed, like installers.DisallowedURL refuses the artifacts of an installer.
+func disallowedRedirect(err error) BootScript {
+	return func(_ context.Context, j Job, s *ipxe.Script) {
+		if !j.DryRun {
+			metrics.DisallowedURLHost.WithLabelValues("redirect").Inc()
+		}
+		j.With("installer", "redirect").Error(errors.WithMessage(err, "refusing to serve boot script"))
+		s.Echo("Boot server redirect for hardware " + j.HardwareID().String() + " is not to an allowed host")
+		s.Sleep(10)
+		s.AppendString("exit")
 	}
-	j.With("slug", j.hardware.OperatingSystem().Slug, "distro", j.hardware.OperatingSystem().Distro).Error(errors.New("unsupported slug/distro"))
-	shell(ctx, j, s)
 }
 
 func shell(_ context.Context, _ Job, s *ipxe.Script) {
@@ -3,14 +3,17 @@ package job
 import (
 	"context"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/equinix-labs/otel-init-go/otelhelpers"
 	"github.com/packethost/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/client/standalone"
 	"github.com/tinkerbell/boots/conf"
 	"github.com/tinkerbell/boots/dhcp"
+	"github.com/tinkerbell/boots/ipxe"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -25,6 +28,11 @@ type Creator struct {
 	finder                client.HardwareFinder
 	provisionerEngineName string
 	logger                log.Logger
+	validation            *RecordValidation
+	allowPXEDefault       bool
+	leases                *dhcp.LeaseTimes
+	// lookups is the outcome of the hardware lookups, see LastLookup
+	lookups lookupStatus
 }
 
 // NewCreator returns a manager that can create jobs.
@@ -36,6 +44,24 @@ func NewCreator(logger log.Logger, provisionerEngineName string, finder client.H
 	}
 }
 
+// SetRecordValidation makes the creator check the hardware record of every job with
+// v, nil disables validation.
+func (c *Creator) SetRecordValidation(v *RecordValidation) {
+	c.validation = v
+}
+
+// SetLeaseTimes makes the jobs offer leases with the lease times l instead of the lease
+// time of their hardware record, nil keeps the hardware record's.
+func (c *Creator) SetLeaseTimes(l *dhcp.LeaseTimes) {
+	c.leases = l
+}
+
+// SetAllowPXEDefault makes the jobs of hardware whose record has no netboot settings
+// for the job's mac allowed to PXE, see Job.AllowPXEByDefault.
+func (c *Creator) SetAllowPXEDefault(allow bool) {
+	c.allowPXEDefault = allow
+}
+
 var joblog log.Logger
 
 func Init(l log.Logger) {
@@ -56,6 +82,31 @@ type Job struct {
 	NextServer            net.IP
 	IpxeBaseURL           string
 	BootsBaseURL          string
+	// BootsScheme is the scheme of BootsBaseURL and of the Boots URL in boot scripts, empty is http
+	BootsScheme string
+	// IpxeScheme is the scheme of IpxeBaseURL, empty uses BootsScheme
+	IpxeScheme string
+	// ArchPolicy chooses the iPXE binary sent to PXE clients, nil chooses by arch name
+	ArchPolicy *dhcp.ArchPolicy
+	// IPXEUserClasses are the user-classes of the iPXE builds besides Tinkerbell's that are sent the boot script URL
+	IPXEUserClasses ipxe.UserClasses
+	// defaults are used for fields missing from the hardware record, see RecordValidation
+	defaults RecordDefaults
+	// allowPXEDefault allows PXE when the hardware record has no netboot settings, see AllowPXEByDefault
+	allowPXEDefault bool
+	// leases override the lease time of the hardware record, nil keeps it
+	leases *dhcp.LeaseTimes
+	// Quiet logs the job's per-DISCOVER logging at debug level, see QuietPolicy
+	Quiet bool
+	// ClientArch is the arch the machine reported in DHCP option 93, empty if it is unknown
+	ClientArch string
+	// ProxyDHCP replies with only boot server information, leaving addressing to another DHCP server
+	ProxyDHCP bool
+	// NoBoot leaves the boot options out of DHCP replies, so the machine does not PXE boot
+	NoBoot bool
+	// DryRun renders the job's boot script without its side effects: no metrics are counted,
+	// no mirror is advanced and no image verification is started, see WithDryRun
+	DryRun bool
 }
 
 type Installers struct {
@@ -63,6 +114,21 @@ type Installers struct {
 	ByInstaller map[string]BootScript
 	ByDistro    map[string]BootScript
 	BySlug      map[string]BootScript
+	// ByFacility are the boot scripts of the hardware in a facility, they take precedence over the other installers
+	ByFacility map[string]BootScript
+	// Arches are the arches each installer serves, by what selects it, see RestrictArch
+	Arches map[string][]string
+	// Priority ranks the kinds of installers, facility, installer, slug and distro, lower
+	// first, kinds of the same rank tie, see selectInstaller. nil is the order of installerKinds.
+	Priority map[string]int
+	// AllowedHosts restricts the hosts machines are redirected to, nil allows every host
+	AllowedHosts HostChecker
+}
+
+// HostChecker checks that the host of a URL machines are directed to is allowed, see
+// installers.HostAllowList.
+type HostChecker interface {
+	Check(rawURL string) error
 }
 
 func NewInstallers() Installers {
@@ -71,20 +137,69 @@ func NewInstallers() Installers {
 		ByInstaller: make(map[string]BootScript),
 		ByDistro:    make(map[string]BootScript),
 		BySlug:      make(map[string]BootScript),
+		ByFacility:  make(map[string]BootScript),
 	}
 }
 
 // AllowPxe returns the value from the hardware data
-// in tink server defined at network.interfaces[].netboot.allow_pxe.
+// in tink server defined at network.interfaces[].netboot.allow_pxe, or true if it
+// is allowed by default, see AllowPXEByDefault.
 func (j Job) AllowPXE() bool {
 	if j.hardware.HardwareAllowPXE(j.mac) {
 		return true
 	}
-	if j.InstanceID() == "" {
-		return false
+	if j.InstanceID() != "" && j.instance.AllowPXE {
+		return true
+	}
+
+	return j.AllowPXEByDefault()
+}
+
+// BootsURL returns the URL of Boots that boot scripts fetch from and report to.
+func (j Job) BootsURL() string {
+	return j.bootsScheme() + "://" + conf.PublicFQDN
+}
+
+func (j Job) bootsScheme() string {
+	if j.BootsScheme == "" {
+		return "http"
+	}
+
+	return j.BootsScheme
+}
+
+// AllowPXEByDefault reports whether the machine is only allowed to PXE by the
+// -allow-pxe-default policy, because its hardware record has no netboot settings. An
+// explicit allow_pxe: false in the record is never overridden.
+func (j Job) AllowPXEByDefault() bool {
+	return j.allowPXEDefault && !j.hardware.HardwareAllowPXE(j.mac) && !j.NetbootConfigured()
+}
+
+// NewDefaultJob returns the job of the machine at the remote address addr when it has
+// no hardware record, allowed to PXE by the -allow-pxe-default policy so it boots the
+// default installer, see AllowPXEByDefault.
+func NewDefaultJob(logger log.Logger, addr string) (*Job, error) {
+	ip, err := remoteAddrIP(addr)
+	if err != nil {
+		return nil, err
 	}
 
-	return j.instance.AllowPXE
+	instance := &client.Instance{OS: &client.OperatingSystem{}}
+
+	return &Job{
+		Logger:          logger.With("ip", ip),
+		ip:              ip,
+		start:           time.Now(),
+		hardware:        &standalone.HardwareStandalone{Metadata: client.Metadata{Instance: instance}},
+		instance:        instance,
+		allowPXEDefault: true,
+	}, nil
+}
+
+// NetbootConfigured reports whether the hardware record has netboot settings for the job's mac.
+// A hardware record without them is known but not configured to boot from the network.
+func (j Job) NetbootConfigured() bool {
+	return j.hardware.HardwareNetbootConfigured(j.mac)
 }
 
 // ProvisionerEngineName returns the current provisioning engine name
@@ -103,13 +218,19 @@ func (c *Creator) CreateFromDHCP(ctx context.Context, mac net.HardwareAddr, giad
 		start:                 time.Now(),
 		provisionerEngineName: c.provisionerEngineName,
 		Logger:                c.logger,
+		allowPXEDefault:       c.allowPXEDefault,
+		leases:                c.leases,
+		DryRun:                IsDryRun(ctx),
 	}
 	d, err := c.finder.ByMAC(ctx, mac, giaddr, circuitID)
+	if !IsDryRun(ctx) {
+		c.lookups.record(time.Now(), err)
+	}
 	if err != nil {
-		return ctx, nil, errors.WithMessage(err, "discover from dhcp message")
+		return ctx, nil, errors.WithMessage(lookupError(err), "discover from dhcp message")
 	}
 
-	newCtx, err := j.setup(ctx, d)
+	newCtx, err := j.setup(ctx, d, c.validation)
 	if err != nil {
 		return ctx, nil, err
 	}
@@ -122,12 +243,34 @@ func (c *Creator) CreateFromDHCP(ctx context.Context, mac net.HardwareAddr, giad
 // specified, the returned context will have that trace set as its parent and the
 // spans will be linked.
 func (c *Creator) CreateFromRemoteAddr(ctx context.Context, ip string) (context.Context, *Job, error) {
-	host, _, err := net.SplitHostPort(ip)
+	addr, err := remoteAddrIP(ip)
 	if err != nil {
-		return ctx, nil, errors.Wrap(err, "splitting host:ip")
+		return ctx, nil, err
 	}
 
-	return c.createFromIP(ctx, net.ParseIP(host))
+	return c.createFromIP(ctx, addr)
+}
+
+// remoteAddrIP returns the IP of a host:port remote address, which may be a bracketed
+// IPv6 address with a zone. IPv4-mapped IPv6 addresses, as seen by dual-stack
+// listeners, are returned as IPv4 so they match the IPv4 address of the hardware.
+func remoteAddrIP(addr string) (net.IP, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "splitting host:ip")
+	}
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, errors.Errorf("remote address %q is not an IP", addr)
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4, nil
+	}
+
+	return ip, nil
 }
 
 // createFromIP looks up hardware using the IP from cacher to create a job.
@@ -140,20 +283,27 @@ func (c *Creator) createFromIP(ctx context.Context, ip net.IP) (context.Context,
 		start:                 time.Now(),
 		provisionerEngineName: c.provisionerEngineName,
 		Logger:                c.logger,
+		allowPXEDefault:       c.allowPXEDefault,
+		leases:                c.leases,
+		DryRun:                IsDryRun(ctx),
 	}
 
 	c.logger.With("ip", ip).Info("discovering from ip")
 	d, err := c.finder.ByIP(ctx, ip)
+	if !IsDryRun(ctx) {
+		c.lookups.record(time.Now(), err)
+	}
 	if err != nil {
-		return ctx, nil, errors.WithMessage(err, "discovering from ip address")
+		return ctx, nil, errors.WithMessage(lookupError(err), "discovering from ip address")
 	}
 	mac := d.GetMAC(ip)
-	if mac.String() == client.MinMAC.String() {
+	// with validation a zero mac fails the job instead of Boots
+	if mac.String() == client.MinMAC.String() && c.validation == nil {
 		c.logger.With("ip", ip).Fatal(errors.New("somehow got a zero mac"))
 	}
 	j.mac = mac
 
-	ctx, err = j.setup(ctx, d)
+	ctx, err = j.setup(ctx, d, c.validation)
 	if err != nil {
 		return ctx, nil, err
 	}
@@ -166,8 +316,8 @@ func (c *Creator) createFromIP(ctx context.Context, ip net.IP) (context.Context,
 // has an in-band traceparent populated, the context has its trace modified
 // so that it points at the incoming traceparent from the hardware. A span
 // link is applied in the process. The returned context's parent trace will
-// be set to the traceparent value.
-func (j *Job) setup(ctx context.Context, d client.Discoverer) (context.Context, error) {
+// be set to the traceparent value. The record is checked by v, which may be nil.
+func (j *Job) setup(ctx context.Context, d client.Discoverer, v *RecordValidation) (context.Context, error) {
 	dh := d.Hardware()
 
 	j.Logger = j.Logger.With("mac", j.mac, "hardware.id", dh.HardwareID())
@@ -195,11 +345,15 @@ func (j *Job) setup(ctx context.Context, d client.Discoverer) (context.Context,
 	}
 
 	ip := d.GetIP(j.mac)
+	if err := v.validate(j, ip.Address); err != nil {
+		return ctx, err
+	}
 	if ip.Address == nil {
 		return ctx, errors.New("could not find IP address")
 	}
 	j.dhcp.Setup(ip.Address, ip.Netmask, ip.Gateway)
 	j.dhcp.SetLeaseTime(d.LeaseTime(j.mac))
+	j.leases.ApplyTo(&j.dhcp)
 	j.dhcp.SetDHCPServer(conf.PublicIPv4) // used for the unicast DHCPREQUEST
 	j.dhcp.SetDNSServers(d.DNSServers(j.mac))
 
@@ -0,0 +1,138 @@
+package job
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+)
+
+// ipRecorder is a HardwareFinder that records the IP it is asked for and finds nothing.
+type ipRecorder struct {
+	ip net.IP
+}
+
+func (f *ipRecorder) ByIP(_ context.Context, ip net.IP) (client.Discoverer, error) {
+	f.ip = ip
+
+	return nil, client.ErrNotFound
+}
+
+func (f *ipRecorder) ByMAC(context.Context, net.HardwareAddr, net.IP, string) (client.Discoverer, error) {
+	return nil, client.ErrNotFound
+}
+
+func TestCreateFromRemoteAddr(t *testing.T) {
+	tests := map[string]struct {
+		addr    string
+		want    string
+		wantErr bool
+	}{
+		"ipv4":             {addr: "192.168.1.10:43210", want: "192.168.1.10"},
+		"ipv6":             {addr: "[2001:db8::10]:43210", want: "2001:db8::10"},
+		"ipv6 expanded":    {addr: "[2001:DB8:0:0::10]:43210", want: "2001:db8::10"},
+		"ipv6 link local":  {addr: "[fe80::1%eth0]:43210", want: "fe80::1"},
+		"ipv4-mapped ipv6": {addr: "[::ffff:192.168.1.10]:43210", want: "192.168.1.10"},
+		"no port":          {addr: "2001:db8::10", wantErr: true},
+		"not an ip":        {addr: "boots.example.com:80", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := &ipRecorder{}
+			c := NewCreator(joblog, "", f)
+			_, _, err := c.CreateFromRemoteAddr(context.Background(), tt.addr)
+			if tt.wantErr {
+				if err == nil || f.ip != nil {
+					t.Fatalf("want an error without a lookup, got %v looking up %v", err, f.ip)
+				}
+
+				return
+			}
+			if !errors.Is(err, client.ErrNotFound) {
+				t.Fatalf("want the lookup's error, got: %v", err)
+			}
+			if f.ip.String() != tt.want {
+				t.Fatalf("want lookup of %s, got %s", tt.want, f.ip)
+			}
+			if f.ip.To4() != nil && len(f.ip) != net.IPv4len {
+				t.Fatalf("want IPv4 lookups with a 4 byte IP, got %d bytes", len(f.ip))
+			}
+		})
+	}
+}
+
+// failingFinder is a HardwareFinder whose backend fails every lookup with err.
+type failingFinder struct {
+	err error
+}
+
+func (f failingFinder) ByIP(context.Context, net.IP) (client.Discoverer, error) {
+	return nil, f.err
+}
+
+func (f failingFinder) ByMAC(context.Context, net.HardwareAddr, net.IP, string) (client.Discoverer, error) {
+	return nil, f.err
+}
+
+func TestLookupError(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"backend failing": {err: errors.New("connection refused"), want: true},
+		"not found":       {err: client.ErrNotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := NewCreator(joblog, "", failingFinder{err: tt.err})
+			_, _, ipErr := c.CreateFromRemoteAddr(context.Background(), "192.168.1.10:43210")
+			_, _, macErr := c.CreateFromDHCP(context.Background(), net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, 0xef}, nil, "")
+			for _, err := range []error{ipErr, macErr} {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("want the finder's error, got: %v", err)
+				}
+				if IsLookupError(err) != tt.want {
+					t.Fatalf("want lookup error %t, got: %v", tt.want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLastLookup(t *testing.T) {
+	backendErr := errors.New("connection refused")
+	f := &failingFinder{err: backendErr}
+	c := NewCreator(joblog, "", f)
+	if last, err := c.LastLookup(); !last.IsZero() || err != "" {
+		t.Fatalf("want no lookups, got %v, %q", last, err)
+	}
+	_, _, _ = c.CreateFromRemoteAddr(context.Background(), "192.168.1.10:43210")
+	if last, err := c.LastLookup(); !last.IsZero() || err != backendErr.Error() {
+		t.Fatalf("want the failed lookup's error, got %v, %q", last, err)
+	}
+
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	c.lookups.record(now, nil)
+	if last, err := c.LastLookup(); !last.Equal(now) || err != backendErr.Error() {
+		t.Fatalf("want the successful lookup and the last error, got %v, %q", last, err)
+	}
+
+	// dry runs are not recorded
+	c.lookups.lastError = ""
+	_, _, _ = c.CreateFromRemoteAddr(WithDryRun(context.Background()), "192.168.1.10:43210")
+	_, _, _ = c.CreateFromDHCP(WithDryRun(context.Background()), net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, 0xef}, nil, "")
+	if last, err := c.LastLookup(); !last.Equal(now) || err != "" {
+		t.Fatalf("want no dry run lookups recorded, got %v, %q", last, err)
+	}
+
+	// machines without a hardware record are not backend failures
+	f.err = client.ErrNotFound
+	_, _, _ = c.CreateFromRemoteAddr(context.Background(), "192.168.1.10:43210")
+	_, _, _ = c.CreateFromDHCP(context.Background(), net.HardwareAddr{0x00, 0x00, 0xba, 0xdd, 0xbe, 0xef}, nil, "")
+	if last, err := c.LastLookup(); !last.Equal(now) || err != "" {
+		t.Fatalf("want lookups that found no record not recorded, got %v, %q", last, err)
+	}
+}
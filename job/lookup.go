@@ -0,0 +1,71 @@
+package job
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+)
+
+// lookupStatus records the outcome of the hardware lookups of a Creator.
+type lookupStatus struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastError   string
+}
+
+// record records the outcome of a lookup that finished at now. A lookup that found no
+// record is not recorded, the backend answered it and machines without a hardware
+// record are expected.
+func (l *lookupStatus) record(now time.Time, err error) {
+	if errors.Is(err, client.ErrNotFound) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		l.lastError = err.Error()
+
+		return
+	}
+	l.lastSuccess = now
+}
+
+// LastLookup returns when the most recent successful hardware lookup finished, zero if
+// none has, and the error of the most recent lookup the backend failed, empty if none has
+// failed. Lookups that found no record are not failures.
+func (c *Creator) LastLookup() (time.Time, string) {
+	c.lookups.mu.Lock()
+	defer c.lookups.mu.Unlock()
+
+	return c.lookups.lastSuccess, c.lookups.lastError
+}
+
+// LookupError is the error of a hardware lookup the backend could not complete, e.g.
+// because it is unreachable, as opposed to a lookup that found no record or a record
+// that failed validation.
+type LookupError struct {
+	Err error
+}
+
+func (e *LookupError) Error() string { return e.Err.Error() }
+
+func (e *LookupError) Unwrap() error { return e.Err }
+
+// lookupError returns err, the error of a finder, as a LookupError unless no record
+// was found.
+func lookupError(err error) error {
+	if errors.Is(err, client.ErrNotFound) {
+		return err
+	}
+
+	return &LookupError{Err: err}
+}
+
+// IsLookupError reports whether err is, or wraps, a LookupError.
+func IsLookupError(err error) bool {
+	var le *LookupError
+
+	return errors.As(err, &le)
+}
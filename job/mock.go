@@ -76,7 +76,7 @@ func NewMock(t zaptest.TestingT, slug, facility string) Mock {
 func NewMockFromDiscovery(d client.Discoverer, mac net.HardwareAddr) Mock {
 	mockLog, _ := log.Init("job.Mock")
 	j := Job{Logger: mockLog, mac: mac}
-	_, _ = j.setup(context.Background(), d)
+	_, _ = j.setup(context.Background(), d, nil)
 
 	return Mock(j)
 }
@@ -109,6 +109,12 @@ func (m *Mock) SetMAC(mac string) {
 	m.mac = _m
 }
 
+// SetDHCP sets the address and hostname the machine is given by DHCP.
+func (m *Mock) SetDHCP(address net.IP, hostname string) {
+	m.dhcp.Setup(address, nil, nil)
+	m.dhcp.SetHostname(hostname)
+}
+
 func (m *Mock) SetManufacturer(slug string) {
 	hp := m.hardware
 	h, ok := hp.(*standalone.HardwareStandalone)
@@ -117,6 +123,31 @@ func (m *Mock) SetManufacturer(slug string) {
 	}
 }
 
+// SetArch sets the arch of the hardware record, an empty arch leaves the record without one.
+func (m *Mock) SetArch(arch string) {
+	if h, ok := m.hardware.(*standalone.HardwareStandalone); ok {
+		h.Network.Interfaces[0].DHCP.Arch = arch
+	}
+}
+
+func (m *Mock) SetKernelArgs(args string) {
+	if h, ok := m.hardware.(*standalone.HardwareStandalone); ok {
+		h.Network.Interfaces[0].Netboot.OSIE.KernelArgs = args
+	}
+}
+
+func (m *Mock) SetRedirectTo(url string) {
+	if h, ok := m.hardware.(*standalone.HardwareStandalone); ok {
+		h.Network.Interfaces[0].Netboot.RedirectTo = url
+	}
+}
+
+func (m *Mock) SetDHCPOptions(opts map[string]string) {
+	if h, ok := m.hardware.(*standalone.HardwareStandalone); ok {
+		h.Network.Interfaces[0].DHCP.Options = opts
+	}
+}
+
 func (m *Mock) SetOSDistro(distro string) {
 	m.hardware.OperatingSystem().Distro = distro
 }
@@ -1,7 +1,65 @@
 package job
 
-import "github.com/tinkerbell/boots/client"
+import (
+	"net"
+	"strconv"
+
+	"github.com/tinkerbell/boots/client"
+)
 
 func (j Job) BondingMode() client.BondingMode {
 	return j.hardware.HardwareBondingMode()
 }
+
+// NetworkKernelArgs returns dracut style ip= (and vlan=) kernel args describing
+// the static network configuration of the interfaces in the hardware record.
+// Returns nil if no interface has an IPv4 address.
+func (j Job) NetworkKernelArgs() []string {
+	if h := j.hardware; h != nil {
+		return dracutNetworkArgs(h.NetworkInterfaces())
+	}
+
+	return nil
+}
+
+// dracutNetworkArgs formats an ip=<client-IP>::<gateway-IP>:<netmask>:<hostname>:<interface>:none
+// kernel arg for every interface with an IPv4 address. Interfaces with a VLAN ID
+// also get a vlan=<interface>.<vlan>:<interface> arg and their ip= arg is bound to
+// the VLAN interface instead. The interface name may only be left out when there is
+// a single interface, so unnamed interfaces are skipped in multi-interface records.
+func dracutNetworkArgs(ifaces []client.NetworkInterface) []string {
+	var args []string
+	for _, iface := range ifaces {
+		dh := iface.DHCP
+		if dh.IP.Address.To4() == nil {
+			continue
+		}
+		name := dh.IfaceName
+		if name == "" && len(ifaces) > 1 {
+			continue
+		}
+		if name != "" && isVLANID(dh.VLANID) {
+			parent := name
+			name = parent + "." + dh.VLANID
+			args = append(args, "vlan="+name+":"+parent)
+		}
+		args = append(args, "ip="+dh.IP.Address.String()+"::"+ipString(dh.IP.Gateway)+":"+ipString(dh.IP.Netmask)+":"+dh.Hostname+":"+name+":none")
+	}
+
+	return args
+}
+
+// isVLANID reports whether s is a single valid 802.1Q VLAN ID.
+func isVLANID(s string) bool {
+	id, err := strconv.Atoi(s)
+
+	return err == nil && id > 0 && id < 4095
+}
+
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+
+	return ip.String()
+}
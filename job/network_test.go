@@ -0,0 +1,74 @@
+package job
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/boots/client"
+)
+
+func TestDracutNetworkArgs(t *testing.T) {
+	staticIP := client.IP{
+		Address: net.ParseIP("192.168.1.5"),
+		Netmask: net.ParseIP("255.255.255.0"),
+		Gateway: net.ParseIP("192.168.1.1"),
+	}
+	tests := map[string]struct {
+		ifaces []client.NetworkInterface
+		want   []string
+	}{
+		"no interfaces": {},
+		"no ip": {
+			ifaces: []client.NetworkInterface{{DHCP: client.DHCP{IfaceName: "eth0"}}},
+		},
+		"single unnamed interface": {
+			ifaces: []client.NetworkInterface{{DHCP: client.DHCP{IP: staticIP, Hostname: "node1"}}},
+			want:   []string{"ip=192.168.1.5::192.168.1.1:255.255.255.0:node1::none"},
+		},
+		"single named interface": {
+			ifaces: []client.NetworkInterface{{DHCP: client.DHCP{IP: staticIP, Hostname: "node1", IfaceName: "eth0"}}},
+			want:   []string{"ip=192.168.1.5::192.168.1.1:255.255.255.0:node1:eth0:none"},
+		},
+		"no gateway": {
+			ifaces: []client.NetworkInterface{{DHCP: client.DHCP{
+				IP:        client.IP{Address: net.ParseIP("10.0.0.2"), Netmask: net.ParseIP("255.0.0.0")},
+				IfaceName: "eth0",
+			}}},
+			want: []string{"ip=10.0.0.2:::255.0.0.0::eth0:none"},
+		},
+		"vlan": {
+			ifaces: []client.NetworkInterface{{DHCP: client.DHCP{IP: staticIP, IfaceName: "eth0", VLANID: "100"}}},
+			want: []string{
+				"vlan=eth0.100:eth0",
+				"ip=192.168.1.5::192.168.1.1:255.255.255.0::eth0.100:none",
+			},
+		},
+		"invalid vlan is ignored": {
+			ifaces: []client.NetworkInterface{{DHCP: client.DHCP{IP: staticIP, IfaceName: "eth0", VLANID: "100,200"}}},
+			want:   []string{"ip=192.168.1.5::192.168.1.1:255.255.255.0::eth0:none"},
+		},
+		"ipv6 is skipped": {
+			ifaces: []client.NetworkInterface{{DHCP: client.DHCP{IP: client.IP{Address: net.ParseIP("fd00::5")}, IfaceName: "eth0"}}},
+		},
+		"multiple interfaces": {
+			ifaces: []client.NetworkInterface{
+				{DHCP: client.DHCP{IP: staticIP, Hostname: "node1", IfaceName: "eth0"}},
+				{DHCP: client.DHCP{IP: client.IP{Address: net.ParseIP("10.0.0.2"), Netmask: net.ParseIP("255.0.0.0")}, IfaceName: "eth1"}},
+				{DHCP: client.DHCP{IP: client.IP{Address: net.ParseIP("10.1.0.2")}}},
+			},
+			want: []string{
+				"ip=192.168.1.5::192.168.1.1:255.255.255.0:node1:eth0:none",
+				"ip=10.0.0.2:::255.0.0.0::eth1:none",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := dracutNetworkArgs(tt.ifaces)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
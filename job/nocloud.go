@@ -0,0 +1,189 @@
+package job
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+	"sigs.k8s.io/yaml"
+)
+
+// bondName is the name of the bond all interfaces are joined into when the record has a bonding mode.
+const bondName = "bond0"
+
+// bondModes are the netplan names of the linux bonding modes. Mode 0 is the
+// hardware record's default so it is treated as not bonded.
+var bondModes = map[client.BondingMode]string{
+	1: "active-backup",
+	2: "balance-xor",
+	3: "broadcast",
+	4: "802.3ad",
+	5: "balance-tlb",
+	6: "balance-alb",
+}
+
+// networkConfig is a cloud-init network config version 2 document, see
+// https://cloudinit.readthedocs.io/en/latest/reference/network-config-format-v2.html.
+type networkConfig struct {
+	Network networkConfigNetwork `json:"network"`
+}
+
+type networkConfigNetwork struct {
+	Version   int                                `json:"version"`
+	Ethernets map[string]*networkConfigInterface `json:"ethernets,omitempty"`
+	Bonds     map[string]*networkConfigInterface `json:"bonds,omitempty"`
+	VLANs     map[string]*networkConfigInterface `json:"vlans,omitempty"`
+}
+
+type networkConfigInterface struct {
+	Match       *networkConfigMatch       `json:"match,omitempty"`
+	SetName     string                    `json:"set-name,omitempty"`
+	DHCP4       bool                      `json:"dhcp4"`
+	Addresses   []string                  `json:"addresses,omitempty"`
+	Routes      []networkConfigRoute      `json:"routes,omitempty"`
+	Nameservers *networkConfigNameservers `json:"nameservers,omitempty"`
+	// Interfaces and Parameters are only set on bonds
+	Interfaces []string                 `json:"interfaces,omitempty"`
+	Parameters *networkConfigBondParams `json:"parameters,omitempty"`
+	// ID and Link are only set on VLANs
+	ID   int    `json:"id,omitempty"`
+	Link string `json:"link,omitempty"`
+}
+
+type networkConfigMatch struct {
+	MACAddress string `json:"macaddress"`
+}
+
+type networkConfigRoute struct {
+	To  string `json:"to"`
+	Via string `json:"via"`
+}
+
+type networkConfigNameservers struct {
+	Addresses []string `json:"addresses"`
+}
+
+type networkConfigBondParams struct {
+	Mode string `json:"mode"`
+}
+
+// NetworkConfig returns a cloud-init network config version 2 document describing the
+// static network configuration of the interfaces in the hardware record. Returns nil
+// if no interface has an IPv4 address, so cloud-init can fall back to DHCP.
+func (j Job) NetworkConfig() ([]byte, error) {
+	if j.hardware == nil {
+		return nil, nil
+	}
+	nc := renderNetworkConfig(j.hardware.NetworkInterfaces(), j.hardware.HardwareBondingMode())
+	if nc == nil {
+		return nil, nil
+	}
+	b, err := yaml.Marshal(nc)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal network config")
+	}
+
+	return b, nil
+}
+
+// renderNetworkConfig builds the network config for ifaces. Every interface with a MAC
+// is matched by it and renamed to its interface name, if it has one. When mode is a
+// bonding mode and there is more than one interface they are all joined in a bond that
+// gets the addressing of the first interface with an IPv4 address. Interfaces with a
+// VLAN ID have their addressing on a VLAN interface instead. Only the first address
+// with a gateway gets the default route.
+func renderNetworkConfig(ifaces []client.NetworkInterface, mode client.BondingMode) *networkConfig {
+	n := networkConfigNetwork{Version: 2, Ethernets: map[string]*networkConfigInterface{}}
+	bondMode, bonded := bondModes[mode]
+	var bond *networkConfigInterface
+	if bonded && len(ifaces) > 1 {
+		bond = &networkConfigInterface{Parameters: &networkConfigBondParams{Mode: bondMode}}
+		n.Bonds = map[string]*networkConfigInterface{bondName: bond}
+	}
+
+	addressed, defaultRoute := false, false
+	for i, iface := range ifaces {
+		dh := iface.DHCP
+		if dh.MAC == nil {
+			continue
+		}
+		name := dh.IfaceName
+		eth := &networkConfigInterface{Match: &networkConfigMatch{MACAddress: dh.MAC.String()}, SetName: name}
+		if name == "" {
+			name = "eth" + strconv.Itoa(i)
+		}
+		n.Ethernets[name] = eth
+
+		target, link := eth, name
+		if bond != nil {
+			bond.Interfaces = append(bond.Interfaces, name)
+			if addressed {
+				continue
+			}
+			target, link = bond, bondName
+		}
+		prefix, ok := ipv4Prefix(dh.IP)
+		if !ok {
+			continue
+		}
+		if isVLANID(dh.VLANID) {
+			id, _ := strconv.Atoi(dh.VLANID)
+			if n.VLANs == nil {
+				n.VLANs = map[string]*networkConfigInterface{}
+			}
+			target = &networkConfigInterface{ID: id, Link: link}
+			n.VLANs[link+"."+dh.VLANID] = target
+		}
+
+		addressed = true
+		target.Addresses = []string{prefix}
+		if dh.IP.Gateway.To4() != nil && !defaultRoute {
+			target.Routes = []networkConfigRoute{{To: "0.0.0.0/0", Via: dh.IP.Gateway.String()}}
+			defaultRoute = true
+		}
+		if len(dh.NameServers) > 0 {
+			target.Nameservers = &networkConfigNameservers{Addresses: dh.NameServers}
+		}
+	}
+	if !addressed {
+		return nil
+	}
+
+	return &networkConfig{Network: n}
+}
+
+// ipv4Prefix formats ip as an address/prefix-length, false if it is not a valid IPv4 address and mask.
+func ipv4Prefix(ip client.IP) (string, bool) {
+	addr, mask := ip.Address.To4(), ip.Netmask.To4()
+	if addr == nil || mask == nil {
+		return "", false
+	}
+	ones, bits := net.IPMask(mask).Size()
+	if bits == 0 {
+		return "", false
+	}
+
+	return addr.String() + "/" + strconv.Itoa(ones), true
+}
+
+// noCloudMetaData is the NoCloud meta-data document.
+type noCloudMetaData struct {
+	InstanceID    string `json:"instance-id"`
+	LocalHostname string `json:"local-hostname,omitempty"`
+}
+
+// NoCloudMetaData returns the cloud-init NoCloud meta-data document. The instance-id is
+// the instance's ID, or the hardware ID if there is no instance.
+func (j Job) NoCloudMetaData() ([]byte, error) {
+	md := noCloudMetaData{InstanceID: j.InstanceID(), LocalHostname: j.dhcp.Hostname()}
+	if md.InstanceID == "" {
+		md.InstanceID = j.HardwareID().String()
+	}
+	b, err := yaml.Marshal(md)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal nocloud meta-data")
+	}
+
+	return b, nil
+}
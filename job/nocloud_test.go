@@ -0,0 +1,167 @@
+package job
+
+import (
+	"net"
+	"testing"
+
+	"github.com/andreyvit/diff"
+	"github.com/tinkerbell/boots/client"
+	"sigs.k8s.io/yaml"
+)
+
+func TestRenderNetworkConfig(t *testing.T) {
+	mac := func(s string) *client.MACAddr {
+		m := &client.MACAddr{}
+		if err := m.UnmarshalText([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+
+		return m
+	}
+	staticIP := client.IP{
+		Address: net.ParseIP("192.168.1.5"),
+		Netmask: net.ParseIP("255.255.255.0"),
+		Gateway: net.ParseIP("192.168.1.1"),
+	}
+	tests := map[string]struct {
+		ifaces []client.NetworkInterface
+		mode   client.BondingMode
+		want   string
+	}{
+		"no interfaces": {},
+		"no ip": {
+			ifaces: []client.NetworkInterface{{DHCP: client.DHCP{MAC: mac("00:00:ba:dd:be:ef"), IfaceName: "eth0"}}},
+		},
+		"single interface": {
+			ifaces: []client.NetworkInterface{{DHCP: client.DHCP{
+				MAC: mac("00:00:ba:dd:be:ef"), IP: staticIP, IfaceName: "eno1", NameServers: []string{"1.1.1.1", "8.8.8.8"},
+			}}},
+			want: `network:
+  ethernets:
+    eno1:
+      addresses:
+      - 192.168.1.5/24
+      dhcp4: false
+      match:
+        macaddress: 00:00:ba:dd:be:ef
+      nameservers:
+        addresses:
+        - 1.1.1.1
+        - 8.8.8.8
+      routes:
+      - to: 0.0.0.0/0
+        via: 192.168.1.1
+      set-name: eno1
+  version: 2
+`,
+		},
+		"unnamed interface with vlan": {
+			ifaces: []client.NetworkInterface{{DHCP: client.DHCP{MAC: mac("00:00:ba:dd:be:ef"), IP: staticIP, VLANID: "100"}}},
+			want: `network:
+  ethernets:
+    eth0:
+      dhcp4: false
+      match:
+        macaddress: 00:00:ba:dd:be:ef
+  version: 2
+  vlans:
+    eth0.100:
+      addresses:
+      - 192.168.1.5/24
+      dhcp4: false
+      id: 100
+      link: eth0
+      routes:
+      - to: 0.0.0.0/0
+        via: 192.168.1.1
+`,
+		},
+		"multiple interfaces without a bonding mode": {
+			ifaces: []client.NetworkInterface{
+				{DHCP: client.DHCP{MAC: mac("00:00:ba:dd:be:ef"), IP: staticIP, IfaceName: "eth0"}},
+				{DHCP: client.DHCP{MAC: mac("00:00:ba:dd:be:f0"), IP: client.IP{
+					Address: net.ParseIP("10.0.0.2"), Netmask: net.ParseIP("255.0.0.0"), Gateway: net.ParseIP("10.0.0.1"),
+				}, IfaceName: "eth1"}},
+			},
+			want: `network:
+  ethernets:
+    eth0:
+      addresses:
+      - 192.168.1.5/24
+      dhcp4: false
+      match:
+        macaddress: 00:00:ba:dd:be:ef
+      routes:
+      - to: 0.0.0.0/0
+        via: 192.168.1.1
+      set-name: eth0
+    eth1:
+      addresses:
+      - 10.0.0.2/8
+      dhcp4: false
+      match:
+        macaddress: 00:00:ba:dd:be:f0
+      set-name: eth1
+  version: 2
+`,
+		},
+		"bond with vlan": {
+			mode: 4,
+			ifaces: []client.NetworkInterface{
+				{DHCP: client.DHCP{MAC: mac("00:00:ba:dd:be:ef"), IP: staticIP, IfaceName: "eth0", VLANID: "200"}},
+				{DHCP: client.DHCP{MAC: mac("00:00:ba:dd:be:f0"), IP: staticIP, IfaceName: "eth1", VLANID: "200"}},
+			},
+			want: `network:
+  bonds:
+    bond0:
+      dhcp4: false
+      interfaces:
+      - eth0
+      - eth1
+      parameters:
+        mode: 802.3ad
+  ethernets:
+    eth0:
+      dhcp4: false
+      match:
+        macaddress: 00:00:ba:dd:be:ef
+      set-name: eth0
+    eth1:
+      dhcp4: false
+      match:
+        macaddress: 00:00:ba:dd:be:f0
+      set-name: eth1
+  version: 2
+  vlans:
+    bond0.200:
+      addresses:
+      - 192.168.1.5/24
+      dhcp4: false
+      id: 200
+      link: bond0
+      routes:
+      - to: 0.0.0.0/0
+        via: 192.168.1.1
+`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			nc := renderNetworkConfig(tt.ifaces, tt.mode)
+			if tt.want == "" {
+				if nc != nil {
+					t.Fatalf("want no network config, got %+v", nc)
+				}
+
+				return
+			}
+			b, err := yaml.Marshal(nc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tt.want {
+				t.Fatalf("\n%s", diff.LineDiff(tt.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package job
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/packethost/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// The sources of the state a QuietPolicy matches machines by.
+const (
+	// QuietStateHardware matches by the hardware record's state, e.g. in_use.
+	QuietStateHardware = "hardware"
+	// QuietStateInstance matches by the instance's state, e.g. active.
+	QuietStateInstance = "instance"
+)
+
+// QuietPolicy recognizes machines that are done provisioning but keep sending DHCP
+// DISCOVERs because they network boot first, and are told to boot from disk. The
+// per-DISCOVER logging of these machines is logged at debug level instead of info,
+// except for one in every sample DISCOVERs so the fleet stays visible in the logs.
+// A nil QuietPolicy matches no machines.
+type QuietPolicy struct {
+	source string
+	states map[string]bool
+	sample uint64
+	n      uint64
+}
+
+// NewQuietPolicy returns a policy matching machines whose state, read from source, is
+// one of states. sample is the one in every sample matching DISCOVERs that are still
+// logged at info level, 0 logs none. Returns nil if there are no states.
+func NewQuietPolicy(source string, states []string, sample int) (*QuietPolicy, error) {
+	if source != QuietStateHardware && source != QuietStateInstance {
+		return nil, errors.Errorf("invalid quiet state source %q, must be %s or %s", source, QuietStateHardware, QuietStateInstance)
+	}
+	if sample < 0 {
+		return nil, errors.Errorf("invalid quiet log sample %d, must not be negative", sample)
+	}
+	p := &QuietPolicy{source: source, states: map[string]bool{}, sample: uint64(sample)}
+	for _, s := range states {
+		if s = strings.TrimSpace(s); s != "" {
+			p.states[s] = true
+		}
+	}
+	if len(p.states) == 0 {
+		return nil, nil
+	}
+
+	return p, nil
+}
+
+// Quiet reports whether the DISCOVER of j should be logged at debug level.
+func (p *QuietPolicy) Quiet(j Job) bool {
+	if p == nil {
+		return false
+	}
+	state := j.HardwareState()
+	if p.source == QuietStateInstance {
+		state = ""
+		if j.instance != nil {
+			state = string(j.instance.State)
+		}
+	}
+	if !p.states[state] {
+		return false
+	}
+
+	if p.sample > 0 && atomic.AddUint64(&p.n, 1)%p.sample == 1%p.sample {
+		metrics.DHCPQuiet.WithLabelValues(state, "sampled").Inc()
+
+		return false
+	}
+	metrics.DHCPQuiet.WithLabelValues(state, "suppressed").Inc()
+
+	return true
+}
+
+// info logs args with l at info level, or at debug level if the job is quiet.
+func (j Job) info(l log.Logger, args ...interface{}) {
+	l = l.AddCallerSkip(1)
+	if j.Quiet {
+		l.Debug(args...)
+
+		return
+	}
+	l.Info(args...)
+}
@@ -0,0 +1,49 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/client/standalone"
+)
+
+func TestQuietPolicy(t *testing.T) {
+	j := Job{
+		hardware: &standalone.HardwareStandalone{ID: "$hardware_id", Metadata: client.Metadata{State: "in_use"}},
+		instance: &client.Instance{State: "active"},
+	}
+	for _, tt := range []struct {
+		name   string
+		source string
+		states []string
+		sample int
+		want   []bool
+	}{
+		{name: "hardware state", source: QuietStateHardware, states: []string{"in_use"}, want: []bool{true, true, true}},
+		{name: "instance state", source: QuietStateInstance, states: []string{" active", ""}, want: []bool{true, true}},
+		{name: "other state", source: QuietStateHardware, states: []string{"provisionable"}, want: []bool{false}},
+		{name: "instance source ignores hardware state", source: QuietStateInstance, states: []string{"in_use"}, want: []bool{false}},
+		{name: "sampled", source: QuietStateHardware, states: []string{"in_use"}, sample: 3, want: []bool{false, true, true, false, true}},
+		{name: "every one sampled", source: QuietStateHardware, states: []string{"in_use"}, sample: 1, want: []bool{false, false}},
+		{name: "no states", source: QuietStateHardware, states: []string{""}, want: []bool{false}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewQuietPolicy(tt.source, tt.states, tt.sample)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range tt.want {
+				if got := p.Quiet(j); got != want {
+					t.Fatalf("discover %d: want quiet: %t, got: %t", i, want, got)
+				}
+			}
+		})
+	}
+
+	if _, err := NewQuietPolicy("tink", []string{"in_use"}, 0); err == nil {
+		t.Fatal("expected an error for an invalid state source")
+	}
+	if _, err := NewQuietPolicy(QuietStateHardware, []string{"in_use"}, -1); err == nil {
+		t.Fatal("expected an error for a negative sample")
+	}
+}
@@ -0,0 +1,114 @@
+package job
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// The actions taken for hardware records missing a field Boots needs to boot them.
+const (
+	// MissingFieldDeny fails the job with an error naming the missing field.
+	MissingFieldDeny = "deny"
+	// MissingFieldDefault uses the default profile for missing fields that have a
+	// default, records missing any other field are denied.
+	MissingFieldDefault = "default"
+)
+
+// The required fields of a hardware record. Only facility and arch have defaults.
+const (
+	fieldID       = "id"
+	fieldMAC      = "mac"
+	fieldIP       = "ip"
+	fieldFacility = "facility"
+	fieldArch     = "arch"
+)
+
+// RecordDefaults is the default profile used for the missing fields of hardware
+// records by the MissingFieldDefault action.
+type RecordDefaults struct {
+	Facility string
+	Arch     string
+}
+
+// RecordValidation checks that a resolved hardware record has the fields Boots needs
+// to boot it, instead of serving a broken boot.
+type RecordValidation struct {
+	action   string
+	defaults RecordDefaults
+}
+
+// NewRecordValidation returns a validation taking action for records missing a
+// required field. defaults is required, and only allowed, for MissingFieldDefault.
+func NewRecordValidation(action string, defaults RecordDefaults) (*RecordValidation, error) {
+	switch action {
+	case MissingFieldDeny:
+		if defaults != (RecordDefaults{}) {
+			return nil, errors.New("a default profile is only used with the default action")
+		}
+	case MissingFieldDefault:
+		if defaults.Facility == "" || defaults.Arch == "" {
+			return nil, errors.New("the default action requires a default facility and arch")
+		}
+	default:
+		return nil, errors.Errorf("invalid missing field action %q, must be %s or %s", action, MissingFieldDeny, MissingFieldDefault)
+	}
+
+	return &RecordValidation{action: action, defaults: defaults}, nil
+}
+
+// validate checks the record of j, whose IP address is ip, setting the defaults of
+// missing fields on j if the action allows it. An error names the first missing
+// field that is denied. A nil RecordValidation accepts every record.
+func (v *RecordValidation) validate(j *Job, ip net.IP) error {
+	if v == nil {
+		return nil
+	}
+	id := j.hardware.HardwareID()
+	required := []struct {
+		field   string
+		missing bool
+		dflt    *string
+		value   string
+	}{
+		{field: fieldID, missing: id == ""},
+		{field: fieldMAC, missing: !recordHasMAC(j.hardware)},
+		{field: fieldIP, missing: ip == nil || ip.IsUnspecified()},
+		{field: fieldFacility, missing: j.hardware.HardwareFacilityCode() == "", dflt: &j.defaults.Facility, value: v.defaults.Facility},
+		{field: fieldArch, missing: j.hardware.HardwareArch(j.mac) == "", dflt: &j.defaults.Arch, value: v.defaults.Arch},
+	}
+	for _, r := range required {
+		if !r.missing {
+			continue
+		}
+		if v.action == MissingFieldDefault && r.dflt != nil {
+			if !j.DryRun {
+				metrics.HardwareMissingField.WithLabelValues(r.field, MissingFieldDefault).Inc()
+			}
+			j.With("field", r.field, "default", r.value).Info("hardware record is missing a required field, using the default")
+			*r.dflt = r.value
+
+			continue
+		}
+		if !j.DryRun {
+			metrics.HardwareMissingField.WithLabelValues(r.field, MissingFieldDeny).Inc()
+		}
+
+		return errors.Errorf("hardware record %q is missing required field %s", id, r.field)
+	}
+
+	return nil
+}
+
+// recordHasMAC reports whether an interface of the hardware record h has a MAC.
+func recordHasMAC(h client.Hardware) bool {
+	for _, iface := range h.NetworkInterfaces() {
+		if iface.DHCP.MAC != nil && !iface.DHCP.MAC.IsMin() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,93 @@
+package job
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/tinkerbell/boots/client"
+	"github.com/tinkerbell/boots/client/standalone"
+)
+
+func TestRecordValidation(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0xba, 0xdd, 0xbe, 0xef, 0x00}
+	record := func() *standalone.DiscoverStandalone {
+		m := client.MACAddr{}
+		_ = m.UnmarshalText([]byte(mac.String()))
+
+		return &standalone.DiscoverStandalone{HardwareStandalone: standalone.HardwareStandalone{
+			ID:       "$hardware_id",
+			Metadata: client.Metadata{Facility: client.Facility{FacilityCode: "ewr1"}},
+			Network: client.Network{Interfaces: []client.NetworkInterface{{
+				DHCP: client.DHCP{
+					MAC:  &m,
+					IP:   client.IP{Address: net.ParseIP("192.168.1.5"), Netmask: net.ParseIP("255.255.255.0")},
+					Arch: "x86_64",
+				},
+			}}},
+		}}
+	}
+	defaults := RecordDefaults{Facility: "dflt1", Arch: "aarch64"}
+
+	for _, tt := range []struct {
+		name     string
+		mutate   func(*standalone.DiscoverStandalone)
+		action   string
+		err      string
+		facility string
+		arch     string
+	}{
+		{name: "complete", action: MissingFieldDeny, facility: "ewr1", arch: "x86_64"},
+		{name: "missing id", action: MissingFieldDefault, mutate: func(d *standalone.DiscoverStandalone) { d.ID = "" }, err: "missing required field id"},
+		{name: "missing mac", action: MissingFieldDefault, mutate: func(d *standalone.DiscoverStandalone) { d.Network.Interfaces[0].DHCP.MAC = nil }, err: "missing required field mac"},
+		{name: "zero mac", action: MissingFieldDefault, mutate: func(d *standalone.DiscoverStandalone) { d.Network.Interfaces[0].DHCP.MAC = &client.MACAddr{} }, err: "missing required field mac"},
+		{name: "missing ip", action: MissingFieldDefault, mutate: func(d *standalone.DiscoverStandalone) { d.Network.Interfaces[0].DHCP.IP.Address = nil }, err: "missing required field ip"},
+		{name: "missing facility denied", action: MissingFieldDeny, mutate: func(d *standalone.DiscoverStandalone) { d.Metadata.Facility.FacilityCode = "" }, err: "missing required field facility"},
+		{name: "missing facility default", action: MissingFieldDefault, mutate: func(d *standalone.DiscoverStandalone) { d.Metadata.Facility.FacilityCode = "" }, facility: "dflt1", arch: "x86_64"},
+		{name: "missing arch denied", action: MissingFieldDeny, mutate: func(d *standalone.DiscoverStandalone) { d.Network.Interfaces[0].DHCP.Arch = "" }, err: "missing required field arch"},
+		{name: "missing arch default", action: MissingFieldDefault, mutate: func(d *standalone.DiscoverStandalone) { d.Network.Interfaces[0].DHCP.Arch = "" }, facility: "ewr1", arch: "aarch64"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := record()
+			if tt.mutate != nil {
+				tt.mutate(d)
+			}
+			var dflts RecordDefaults
+			if tt.action == MissingFieldDefault {
+				dflts = defaults
+			}
+			v, err := NewRecordValidation(tt.action, dflts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the request has the MAC, the record may not
+			j := &Job{Logger: joblog, mac: mac}
+
+			_, err = j.setup(context.Background(), d, v)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("want error containing %q, got: %v", tt.err, err)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if j.FacilityCode() != tt.facility || j.Arch() != tt.arch {
+				t.Fatalf("want facility: %q, arch: %q, got facility: %q, arch: %q", tt.facility, tt.arch, j.FacilityCode(), j.Arch())
+			}
+		})
+	}
+
+	if _, err := NewRecordValidation("ignore", RecordDefaults{}); err == nil {
+		t.Fatal("expected an error for an invalid action")
+	}
+	if _, err := NewRecordValidation(MissingFieldDefault, RecordDefaults{Facility: "ewr1"}); err == nil {
+		t.Fatal("expected an error for a default action without a default arch")
+	}
+	if _, err := NewRecordValidation(MissingFieldDeny, defaults); err == nil {
+		t.Fatal("expected an error for a deny action with defaults")
+	}
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/packethost/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registerer registers metrics with reg like prometheus.Registerer but logs the metrics
+// that can not be registered, e.g. a metric registered twice, instead of panicking.
+// Serving machines matters more than the metrics, a metric that is not registered
+// still counts but is not exported.
+type registerer struct {
+	reg prometheus.Registerer
+	log log.Logger
+}
+
+func (r registerer) Register(c prometheus.Collector) error {
+	return r.reg.Register(c)
+}
+
+// MustRegister registers cs, logging the ones that fail rather than panicking.
+func (r registerer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		r.register(c)
+	}
+}
+
+func (r registerer) register(c prometheus.Collector) {
+	defer func() {
+		if v := recover(); v != nil {
+			r.log.Error(errors.Errorf("registering metric panicked: %v", v), "metric is not exported")
+		}
+	}()
+	if err := r.reg.Register(c); err != nil {
+		r.log.Error(errors.Wrap(err, "registering metric"), "metric is not exported")
+	}
+}
+
+func (r registerer) Unregister(c prometheus.Collector) bool {
+	return r.reg.Unregister(c)
+}
+
+// MustRegister registers cs with the default registry, like prometheus.MustRegister,
+// but only logs the ones that fail, see registerer.
+func MustRegister(l log.Logger, cs ...prometheus.Collector) {
+	registerer{reg: prometheus.DefaultRegisterer, log: l}.MustRegister(cs...)
+}
+
+// Handler serves the metrics of the default registry. Metrics that fail to be
+// gathered are logged and left out, the others are still served. Unlike
+// promhttp.Handler it does not instrument itself, so there are no
+// promhttp_metric_handler_* series.
+func Handler(l log.Logger) http.Handler {
+	return promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		ErrorLog:      errorLog{l},
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// errorLog logs the errors of promhttp, see promhttp.Logger.
+type errorLog struct {
+	log log.Logger
+}
+
+func (l errorLog) Println(v ...interface{}) {
+	l.log.Error(errors.New(fmt.Sprint(v...)), "serving metrics")
+}
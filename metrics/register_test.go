@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/packethost/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegistererCollision(t *testing.T) {
+	l, err := log.Init("github.com/tinkerbell/boots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	reg := prometheus.NewRegistry()
+	r := registerer{reg: reg, log: l}
+	first := prometheus.NewCounter(prometheus.CounterOpts{Name: "boots_test_total", Help: "first"})
+	r.MustRegister(first)
+	r.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "boots_test_total", Help: "second"}))
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 1 || families[0].GetHelp() != "first" {
+		t.Fatalf("want only the first metric registered, got %v", families)
+	}
+}
+
+func TestInitTwice(t *testing.T) {
+	l, err := log.Init("github.com/tinkerbell/boots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	Init(l)
+	registered := DHCPTotal
+	Init(l)
+	if DHCPTotal != registered {
+		t.Fatal("want the second Init to keep the registered metrics")
+	}
+	DHCPTotal.WithLabelValues("DHCPDISCOVER", "recv", "", "").Inc()
+}
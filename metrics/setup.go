@@ -1,13 +1,31 @@
 package metrics
 
 import (
+	"sync"
+
 	"github.com/packethost/pkg/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 var (
-	DHCPTotal *prometheus.CounterVec
+	DHCPTotal          *prometheus.CounterVec
+	DHCPInvalidMAC     *prometheus.CounterVec
+	DHCPTraceDropped   prometheus.Counter
+	PacketLogsDropped  *prometheus.CounterVec
+	DHCPUnknownArch    *prometheus.CounterVec
+	DHCPQuiet          *prometheus.CounterVec
+	DHCPUnmatchedRelay *prometheus.CounterVec
+	DHCPDuration       prometheus.ObserverVec
+	DHCPLookupDuration prometheus.ObserverVec
+	DHCPNoHardware     *prometheus.CounterVec
+	DHCPNAKs           *prometheus.CounterVec
+	ActiveBootsEvicted *prometheus.CounterVec
+	HardwareCacheTotal *prometheus.CounterVec
+	BackendRetries     *prometheus.CounterVec
+	BackendEndpoints   *prometheus.CounterVec
+	BackendBreaker     prometheus.Gauge
+	BackendRejected    *prometheus.CounterVec
 
 	CacherDuration           prometheus.ObserverVec
 	CacherCacheHits          *prometheus.CounterVec
@@ -21,41 +39,196 @@ var (
 	JobDuration    prometheus.ObserverVec
 	JobsTotal      *prometheus.CounterVec
 	JobsInProgress *prometheus.GaugeVec
+	DataModelInfo  *prometheus.GaugeVec
+
+	// BackendLookupDuration times only the hardware lookup of HTTP jobs, JobDuration includes serving the response
+	BackendLookupDuration prometheus.ObserverVec
+
+	BootFailures      *prometheus.CounterVec
+	NoNetbootConfig   *prometheus.CounterVec
+	DisallowedURLHost *prometheus.CounterVec
+	HTTPRateLimited   *prometheus.CounterVec
+
+	HardwareMissingField *prometheus.CounterVec
+	InventoryReports     *prometheus.CounterVec
+
+	SyslogStreamDropped  prometheus.Counter
+	SyslogForwardDropped prometheus.Counter
+
+	PhoneHomeBatchSize     prometheus.Histogram
+	PhoneHomeFlushDuration prometheus.Histogram
+	PhoneHomeDuplicates    prometheus.Counter
+	PhoneHomeUnknown       prometheus.Counter
+	PhoneHomeWebhookErrors prometheus.Counter
+	JobLookupTimeouts      prometheus.Counter
+	TFTPRejected           prometheus.Counter
+	FileTransfersAborted   *prometheus.CounterVec
+	OSIEChecksumFailures   prometheus.Counter
+	HTTPUntrustedXFF       prometheus.Counter
+	TFTPInFlight           prometheus.Gauge
+	TFTPBytesTotal         prometheus.Counter
+	TFTPTransferDuration   *prometheus.HistogramVec
+	TFTPErrorsTotal        *prometheus.CounterVec
+	HTTPActiveConnections  prometheus.Gauge
+	HTTPConnectionsTotal   prometheus.Counter
+	Maintenance            prometheus.Gauge
+	Draining               prometheus.Gauge
+	BootLoops              prometheus.Counter
+	FallbackScripts        *prometheus.CounterVec
+	BootScripts            *prometheus.CounterVec
+	OSIEBoots              *prometheus.CounterVec
 )
 
-func Init(log.Logger) {
-	DHCPTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+// initOnce makes Init register the metrics only once.
+var initOnce sync.Once
+
+// Init creates and registers the metrics. Only the first call does, later calls keep
+// the registered metrics rather than replacing them with ones that are not exported.
+func Init(l log.Logger) {
+	initOnce.Do(func() { initMetrics(l) })
+}
+
+func initMetrics(l log.Logger) {
+	factory := promauto.With(registerer{reg: prometheus.DefaultRegisterer, log: l})
+
+	DHCPTotal = factory.NewCounterVec(prometheus.CounterOpts{
 		Name: "dhcp_total",
-		Help: "Number of DHCP Requests handled.",
-	}, []string{"op", "type", "giaddr"})
+		Help: "Number of DHCP Requests handled, by the address of the listener they were received on.",
+	}, []string{"op", "type", "giaddr", "listener"})
+
+	DHCPInvalidMAC = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "dhcp_invalid_mac_total",
+		Help: "Number of DHCP Requests with an all zero or broadcast chaddr, by how they were handled.",
+	}, []string{"action"})
 
 	labelValues := []prometheus.Labels{
-		{"op": "recv", "type": "DHCPACK", "giaddr": "0.0.0.0"},
-		{"op": "recv", "type": "DHCPDECLINE", "giaddr": "0.0.0.0"},
-		{"op": "recv", "type": "DHCPDISCOVER", "giaddr": "0.0.0.0"},
-		{"op": "recv", "type": "DHCPINFORM", "giaddr": "0.0.0.0"},
-		{"op": "recv", "type": "DHCPNAK", "giaddr": "0.0.0.0"},
-		{"op": "recv", "type": "DHCPOFFER", "giaddr": "0.0.0.0"},
-		{"op": "recv", "type": "DHCPRELEASE", "giaddr": "0.0.0.0"},
-		{"op": "recv", "type": "DHCPREQUEST", "giaddr": "0.0.0.0"},
-		{"op": "send", "type": "DHCPOFFER", "giaddr": "0.0.0.0"},
-	}
-	initCounterLabels(DHCPTotal, labelValues)
-
-	CacherDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		{"action": "ignore"},
+		{"action": "client-id"},
+	}
+	initCounterLabels(DHCPInvalidMAC, labelValues)
+
+	DHCPTraceDropped = factory.NewCounter(prometheus.CounterOpts{
+		Name: "dhcp_trace_dropped_total",
+		Help: "Number of DHCP reply traces not logged because of the trace rate limit.",
+	})
+
+	PacketLogsDropped = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "packet_logs_dropped_total",
+		Help: "Number of DHCP packet and iPXE script dumps not logged because of the per-MAC rate limit, by kind.",
+	}, []string{"kind"})
+	initCounterLabels(PacketLogsDropped, []prometheus.Labels{{"kind": "dhcp"}, {"kind": "ipxe"}})
+
+	DHCPUnknownArch = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "dhcp_unknown_arch_total",
+		Help: "Number of DHCP Requests from PXE clients reporting an option 93 arch Boots does not know, by arch.",
+	}, []string{"arch"})
+
+	DHCPQuiet = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "dhcp_quiet_total",
+		Help: "Number of DHCP Discovers from machines done provisioning, by state and whether they were logged as a sample or suppressed to debug level.",
+	}, []string{"state", "logged"})
+
+	DHCPUnmatchedRelay = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "dhcp_unmatched_relay_total",
+		Help: "Number of DHCP Requests relayed from a giaddr outside of the configured relay subnets, by how they were handled. The giaddr is logged, not a label, as any relay can send them.",
+	}, []string{"policy"})
+
+	DHCPDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dhcp_request_duration_seconds",
+		Help:    "Duration taken to handle a DHCP Request, from receiving it to sending the reply, by message type and the address of the listener it was received on.",
+		Buckets: prometheus.LinearBuckets(.01, .05, 10),
+	}, []string{"type", "listener"})
+	DHCPLookupDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dhcp_hardware_lookup_duration_seconds",
+		Help:    "Duration of the hardware lookups of DHCP Requests, by message type.",
+		Buckets: prometheus.LinearBuckets(.01, .05, 10),
+	}, []string{"type"})
+	DHCPNoHardware = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "dhcp_no_hardware_total",
+		Help: "Number of DHCP Requests dropped because no hardware record could be found for them, by message type.",
+	}, []string{"type"})
+
+	labelValues = []prometheus.Labels{
+		{"type": "DHCPDISCOVER"},
+		{"type": "DHCPREQUEST"},
+	}
+	initObserverLabels(DHCPLookupDuration, labelValues)
+	initCounterLabels(DHCPNoHardware, labelValues)
+
+	DHCPNAKs = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "dhcp_naks_total",
+		Help: "Number of DHCPNAKs sent to DHCP Requests for addresses or leases Boots does not own, by reason.",
+	}, []string{"reason"})
+	initCounterLabels(DHCPNAKs, []prometheus.Labels{
+		{"reason": "unknown-client"},
+		{"reason": "wrong-address"},
+	})
+
+	ActiveBootsEvicted = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "active_boots_evicted_total",
+		Help: "Number of machines dropped from the active boots tracker, by whether they were idle or evicted to make room.",
+	}, []string{"reason"})
+
+	labelValues = []prometheus.Labels{
+		{"reason": "idle"},
+		{"reason": "capacity"},
+	}
+	initCounterLabels(ActiveBootsEvicted, labelValues)
+
+	HardwareCacheTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "hardware_cache_total",
+		Help: "Number of hardware lookups through the hardware cache, by lookup and whether they were a hit or a miss.",
+	}, []string{"lookup", "result"})
+
+	labelValues = []prometheus.Labels{
+		{"lookup": "ip", "result": "hit"},
+		{"lookup": "ip", "result": "miss"},
+		{"lookup": "mac", "result": "hit"},
+		{"lookup": "mac", "result": "miss"},
+	}
+	initCounterLabels(HardwareCacheTotal, labelValues)
+
+	BackendRetries = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "backend_retries_total",
+		Help: "Number of hardware and workflow lookups retried after a transient backend failure, by lookup.",
+	}, []string{"lookup"})
+
+	labelValues = []prometheus.Labels{
+		{"lookup": "ip"},
+		{"lookup": "mac"},
+		{"lookup": "workflow"},
+	}
+	initCounterLabels(BackendRetries, labelValues)
+
+	BackendEndpoints = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "backend_endpoint_requests_total",
+		Help: "Number of workflow lookups sent to each of multiple tink servers, by endpoint and result, success or failure.",
+	}, []string{"endpoint", "result"})
+
+	BackendBreaker = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "backend_breaker_state",
+		Help: "State of the backend circuit breaker, 0 closed, 1 open or 2 half-open, only tracked when -breaker-failures is set.",
+	})
+	BackendRejected = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "backend_breaker_rejected_total",
+		Help: "Number of hardware and workflow lookups failed fast without reaching the backend because the circuit breaker was open, by lookup.",
+	}, []string{"lookup"})
+	initCounterLabels(BackendRejected, labelValues)
+
+	CacherDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "cacher_request_duration_seconds",
 		Help:    "Duration of cacher requests.",
 		Buckets: prometheus.LinearBuckets(.01, .05, 10),
 	}, []string{"from"})
-	CacherCacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+	CacherCacheHits = factory.NewCounterVec(prometheus.CounterOpts{
 		Name: "cacher_cache_hits",
 		Help: "Number of requests which returned data from cacher.",
 	}, []string{"from"})
-	CacherTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	CacherTotal = factory.NewCounterVec(prometheus.CounterOpts{
 		Name: "cacher_total",
 		Help: "Total number of requests to the cacher service.",
 	}, []string{"from"})
-	CacherRequestsInProgress = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	CacherRequestsInProgress = factory.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "cacher_requests_in_progress",
 		Help: "Number of cacher requests that have yet to receive a response.",
 	}, []string{"from"})
@@ -69,16 +242,16 @@ func Init(log.Logger) {
 	initCounterLabels(CacherTotal, labelValues)
 	initGaugeLabels(CacherRequestsInProgress, labelValues)
 
-	DiscoverDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	DiscoverDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "discover_duration_seconds",
 		Help:    "Duration taken to get a response for a newly discovered request.",
 		Buckets: prometheus.LinearBuckets(.01, .05, 10),
 	}, []string{"from"})
-	HardwareDiscovers = promauto.NewCounterVec(prometheus.CounterOpts{
+	HardwareDiscovers = factory.NewCounterVec(prometheus.CounterOpts{
 		Name: "discover_total",
 		Help: "Number of discover requests requested.",
 	}, []string{"from"})
-	DiscoversInProgress = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	DiscoversInProgress = factory.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "discover_in_progress",
 		Help: "Number of discover requests that have yet to receive a response.",
 	}, []string{"from"})
@@ -87,16 +260,16 @@ func Init(log.Logger) {
 	initCounterLabels(HardwareDiscovers, labelValues)
 	initGaugeLabels(DiscoversInProgress, labelValues)
 
-	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	JobDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "jobs_duration_seconds",
 		Help:    "Duration taken for a job to complete.",
 		Buckets: prometheus.LinearBuckets(.01, .05, 10),
 	}, []string{"from", "op"})
-	JobsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	JobsTotal = factory.NewCounterVec(prometheus.CounterOpts{
 		Name: "jobs_total",
 		Help: "Number of jobs.",
 	}, []string{"from", "op"})
-	JobsInProgress = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	JobsInProgress = factory.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "jobs_in_progress",
 		Help: "Number of jobs waiting to complete.",
 	}, []string{"from", "op"})
@@ -113,14 +286,250 @@ func Init(log.Logger) {
 		{"from": "http", "op": "file"},
 		{"from": "http", "op": "hardware-components"},
 		{"from": "http", "op": "phone-home"},
+		{"from": "http", "op": "boot-failure"},
+		{"from": "http", "op": "nocloud"},
+		{"from": "http", "op": "inventory"},
 		{"from": "http", "op": "problem"},
 		{"from": "http", "op": "event"},
+		{"from": "http", "op": "simulate"},
 		{"from": "tftp", "op": "read"},
 	}
 
 	initObserverLabels(JobDuration, labelValues)
 	initCounterLabels(JobsTotal, labelValues)
 	initGaugeLabels(JobsInProgress, labelValues)
+
+	// the buckets, 5ms to 10.24s, reach past the default -job-lookup-timeout of 5s, so
+	// lookups that time out are told apart from slow ones
+	BackendLookupDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "backend_lookup_duration_seconds",
+		Help:    "Duration of the hardware lookups of HTTP jobs, by op and whether the hardware was found, not found or the lookup failed.",
+		Buckets: prometheus.ExponentialBuckets(.005, 2, 12),
+	}, []string{"op", "outcome"})
+	labelValues = nil
+	for _, op := range []string{"file", "phone-home"} {
+		for _, outcome := range []string{"found", "not-found", "error"} {
+			labelValues = append(labelValues, prometheus.Labels{"op": op, "outcome": outcome})
+		}
+	}
+	initObserverLabels(BackendLookupDuration, labelValues)
+
+	HTTPActiveConnections = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "http_active_connections",
+		Help: "Number of open HTTP connections, including idle ones and ones still sending their request.",
+	})
+	HTTPConnectionsTotal = factory.NewCounter(prometheus.CounterOpts{
+		Name: "http_connections_total",
+		Help: "Number of HTTP connections accepted.",
+	})
+	Maintenance = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "maintenance_mode",
+		Help: "1 while Boots is in maintenance mode, refusing all boots, 0 otherwise.",
+	})
+	Draining = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "draining",
+		Help: "1 while Boots is draining for a rolling restart, reporting not ready and refusing new job files, 0 otherwise.",
+	})
+	BootLoops = factory.NewCounter(prometheus.CounterOpts{
+		Name: "boot_loops_detected_total",
+		Help: "Number of times a machine was detected requesting boot scripts in a loop, more than -boot-loop-threshold times within -boot-loop-window.",
+	})
+	FallbackScripts = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_fallback_scripts_total",
+		Help: "Number of boot script requests served -fallback-ipxe-script because their hardware lookup timed out or failed, by reason.",
+	}, []string{"reason"})
+	initCounterLabels(FallbackScripts, []prometheus.Labels{{"reason": "timeout"}, {"reason": "error"}})
+	BootScripts = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "boot_scripts_total",
+		Help: "Number of auto boot scripts served, by what selected their installer, e.g. distro:ubuntu or default.",
+	}, []string{"installer"})
+	initCounterLabels(BootScripts, []prometheus.Labels{{"installer": "default"}})
+	OSIEBoots = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "osie_boots_total",
+		Help: "Number of OSIE/Hook boot scripts rendered, by the mirror their kernel and initrd are fetched from: the scheme and host of a configured base URL, hardware for the hardware record's or default.",
+	}, []string{"mirror"})
+	initCounterLabels(OSIEBoots, []prometheus.Labels{{"mirror": "hardware"}, {"mirror": "default"}})
+
+	DataModelInfo = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "data_model_info",
+		Help: "Always 1, labelled with the DATA_MODEL_VERSION of the hardware backend so the job metrics can be joined on it.",
+	}, []string{"data_model"})
+
+	BootFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "boot_failures_total",
+		Help: "Number of boot failures reported by iPXE clients.",
+	}, []string{"stage"})
+
+	labelValues = []prometheus.Labels{
+		{"stage": "kernel"},
+		{"stage": "initrd"},
+		{"stage": "boot"},
+		{"stage": "unknown"},
+	}
+	initCounterLabels(BootFailures, labelValues)
+
+	NoNetbootConfig = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "no_netboot_config_total",
+		Help: "Number of HTTP requests from hardware that has no netboot config, by response.",
+	}, []string{"response"})
+
+	labelValues = []prometheus.Labels{
+		{"response": "status"},
+		{"response": "script"},
+		{"response": "discovery"},
+	}
+	initCounterLabels(NoNetbootConfig, labelValues)
+
+	DisallowedURLHost = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "disallowed_url_host_total",
+		Help: "Number of boot scripts refused because an artifact URL was not on an allowed host, by installer.",
+	}, []string{"installer"})
+
+	labelValues = []prometheus.Labels{
+		{"installer": "osie"},
+		{"installer": "custom_ipxe"},
+		{"installer": "redirect"},
+	}
+	initCounterLabels(DisallowedURLHost, labelValues)
+
+	HTTPRateLimited = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_rate_limited_total",
+		Help: "Number of HTTP requests rejected because their client exceeded its rate limit, by route.",
+	}, []string{"route"})
+
+	labelValues = []prometheus.Labels{
+		{"route": "/"},
+		{"route": "/phone-home"},
+	}
+	initCounterLabels(HTTPRateLimited, labelValues)
+
+	HardwareMissingField = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "hardware_missing_field_total",
+		Help: "Number of hardware records missing a field required to boot them, by field and whether the job was denied or used the default.",
+	}, []string{"field", "action"})
+
+	InventoryReports = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "inventory_reports_total",
+		Help: "Number of inventory reports from machines without a hardware record, by whether a record was created, the report was stored or neither.",
+	}, []string{"outcome"})
+
+	labelValues = []prometheus.Labels{
+		{"outcome": "created"},
+		{"outcome": "stored"},
+		{"outcome": "failed"},
+	}
+	initCounterLabels(InventoryReports, labelValues)
+
+	SyslogStreamDropped = factory.NewCounter(prometheus.CounterOpts{
+		Name: "syslog_stream_dropped_total",
+		Help: "Number of syslog messages dropped because a stream client was not keeping up.",
+	})
+
+	SyslogForwardDropped = factory.NewCounter(prometheus.CounterOpts{
+		Name: "syslog_forward_dropped_total",
+		Help: "Number of syslog messages not forwarded to the upstream collector because the forward buffer was full or sending failed.",
+	})
+
+	PhoneHomeBatchSize = factory.NewHistogram(prometheus.HistogramOpts{
+		Name:    "phone_home_batch_size",
+		Help:    "Number of phone-home events persisted per batch.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	})
+	PhoneHomeFlushDuration = factory.NewHistogram(prometheus.HistogramOpts{
+		Name:    "phone_home_flush_duration_seconds",
+		Help:    "Duration taken to persist a batch of phone-home events.",
+		Buckets: prometheus.LinearBuckets(.01, .05, 10),
+	})
+
+	PhoneHomeDuplicates = factory.NewCounter(prometheus.CounterOpts{
+		Name: "phone_home_duplicates_total",
+		Help: "Number of phone-homes not processed because the same type was processed from the same MAC within the dedup window.",
+	})
+	PhoneHomeUnknown = factory.NewCounter(prometheus.CounterOpts{
+		Name: "phone_home_unknown_total",
+		Help: "Number of phone-homes from an address no hardware record was found for.",
+	})
+
+	PhoneHomeWebhookErrors = factory.NewCounter(prometheus.CounterOpts{
+		Name: "phone_home_webhook_errors_total",
+		Help: "Number of phone-home webhook notifications that could not be delivered, after retries.",
+	})
+
+	HTTPUntrustedXFF = factory.NewCounter(prometheus.CounterOpts{
+		Name: "http_untrusted_xff_total",
+		Help: "Number of HTTP requests with an X-Forwarded-For header from a peer that is not a trusted proxy, the header is ignored or the request rejected with -xff-strict.",
+	})
+	OSIEChecksumFailures = factory.NewCounter(prometheus.CounterOpts{
+		Name: "osie_checksum_failures_total",
+		Help: "Number of OSIE/Hook image verifications against -osie-checksums that failed.",
+	})
+	FileTransfersAborted = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_file_transfers_aborted_total",
+		Help: "Number of iPXE script and binary transfers aborted for taking longer than -file-serve-timeout or making no progress for -file-serve-idle-timeout, by reason.",
+	}, []string{"reason"})
+	initCounterLabels(FileTransfersAborted, []prometheus.Labels{{"reason": "timeout"}, {"reason": "idle"}})
+	TFTPRejected = factory.NewCounter(prometheus.CounterOpts{
+		Name: "tftp_rejected_total",
+		Help: "Number of TFTP read requests rejected because -tftp-max-concurrent transfers were in progress for longer than the queue timeout.",
+	})
+	TFTPInFlight = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "tftp_transfers_in_flight",
+		Help: "Number of TFTP transfers in progress, only tracked when -tftp-max-concurrent is set.",
+	})
+	TFTPBytesTotal = factory.NewCounter(prometheus.CounterOpts{
+		Name: "tftp_bytes_total",
+		Help: "Number of bytes of iPXE binaries sent via TFTP, including those of failed transfers.",
+	})
+	TFTPTransferDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tftp_transfer_duration_seconds",
+		Help:    "Duration of TFTP transfers, from the read request to the last block's acknowledgment or the failure, by result.",
+		Buckets: prometheus.ExponentialBuckets(.1, 2, 10),
+	}, []string{"result"})
+	initObserverLabels(TFTPTransferDuration, []prometheus.Labels{{"result": "success"}, {"result": "error"}})
+	TFTPErrorsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "tftp_errors_total",
+		Help: "Number of failed TFTP read requests, by type: timeout, file-not-found, rejected, client-error or other.",
+	}, []string{"type"})
+	initCounterLabels(TFTPErrorsTotal, []prometheus.Labels{
+		{"type": "timeout"},
+		{"type": "file-not-found"},
+		{"type": "rejected"},
+		{"type": "client-error"},
+		{"type": "other"},
+	})
+
+	JobLookupTimeouts = factory.NewCounter(prometheus.CounterOpts{
+		Name: "job_lookup_timeouts_total",
+		Help: "Number of job file requests that got a 504 because the hardware lookup did not finish within the job lookup timeout.",
+	})
+}
+
+// SetDataModel records the data model version of the hardware backend Boots was started with.
+func SetDataModel(version string) {
+	DataModelInfo.WithLabelValues(version).Set(1)
+}
+
+// InitDHCPListener initializes the DHCP metrics of the listener on addr.
+func InitDHCPListener(addr string) {
+	for _, l := range []prometheus.Labels{
+		{"op": "recv", "type": "DHCPACK"},
+		{"op": "recv", "type": "DHCPDECLINE"},
+		{"op": "recv", "type": "DHCPDISCOVER"},
+		{"op": "recv", "type": "DHCPINFORM"},
+		{"op": "recv", "type": "DHCPNAK"},
+		{"op": "recv", "type": "DHCPOFFER"},
+		{"op": "recv", "type": "DHCPRELEASE"},
+		{"op": "recv", "type": "DHCPREQUEST"},
+		{"op": "send", "type": "DHCPOFFER"},
+		{"op": "send", "type": "DHCPACK"},
+		{"op": "send", "type": "DHCPNAK"},
+	} {
+		l["giaddr"], l["listener"] = "0.0.0.0", addr
+		DHCPTotal.With(l)
+	}
+	for _, typ := range []string{"DHCPDISCOVER", "DHCPREQUEST"} {
+		DHCPDuration.WithLabelValues(typ, addr)
+	}
 }
 
 func initCounterLabels(m *prometheus.CounterVec, l []prometheus.Labels) {
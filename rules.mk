@@ -17,6 +17,8 @@ CGO_ENABLED := 0
 export CGO_ENABLED
 
 GitRev := $(shell git rev-parse --short HEAD)
+# the commit date, so builds of a commit are reproducible like with SOURCE_DATE_EPOCH
+BuildDate := $(shell TZ=UTC git log -1 --date=iso-strict-local --pretty=%cd)
 SOURCE_DATE_EPOCH := $(shell git log -1 --pretty=%ct)
 export SOURCE_DATE_EPOCH
 
@@ -24,7 +26,7 @@ crossbinaries := cmd/boots/boots-linux-amd64 cmd/boots/boots-linux-arm64
 cmd/boots/boots-linux-amd64: FLAGS=GOARCH=amd64
 cmd/boots/boots-linux-arm64: FLAGS=GOARCH=arm64
 cmd/boots/boots-linux-amd64 cmd/boots/boots-linux-arm64: boots
-	${FLAGS} GOOS=linux go build -v -ldflags="-X main.GitRev=${GitRev}" -o $@ ./cmd/boots/
+	${FLAGS} GOOS=linux go build -v -ldflags="-X main.GitRev=${GitRev} -X main.BuildDate=${BuildDate}" -o $@ ./cmd/boots/
 
 ifeq ($(origin GOBIN), undefined)
 GOBIN := ${PWD}/bin
@@ -59,4 +61,4 @@ $(generated_go_files): bin/goimports
 # this is quick and its really only for rebuilding when dev'ing, I wish go would
 # output deps in make syntax like gcc does... oh well this is good enough
 cmd/boots/boots: $(shell git ls-files | grep -v -e vendor -e '_test.go' -e 'mock' | grep '.go$$' ) syslog/facility_string.go syslog/severity_string.go
-	go build -v -ldflags="-X main.GitRev=${GitRev}" -o $@ ./cmd/boots/
+	go build -v -ldflags="-X main.GitRev=${GitRev} -X main.BuildDate=${BuildDate}" -o $@ ./cmd/boots/
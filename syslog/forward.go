@@ -0,0 +1,170 @@
+package syslog
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+const (
+	// forwardRedial is how long the forwarder waits before redialing a collector it failed to reach.
+	forwardRedial = time.Second
+	// forwardWriteTimeout bounds sending a message, so a stalled TCP collector can not block forwarding.
+	forwardWriteTimeout = 5 * time.Second
+	// forwardDrainTimeout bounds sending the queued messages once forwarding is stopped.
+	forwardDrainTimeout = 5 * time.Second
+)
+
+// Forwarder relays received syslog messages, as they were received so their priority
+// is preserved, to an upstream collector over UDP or TCP. Messages are queued up to
+// a buffer and dropped when it is full, the receiver never waits on the collector.
+type Forwarder struct {
+	network string
+	addr    string
+	queue   chan []byte
+	dial    func(ctx context.Context, network, addr string) (net.Conn, error)
+	// drainTimeout bounds sending the queued messages once Run's context is done
+	drainTimeout time.Duration
+}
+
+// NewForwarder returns a forwarder to addr, a host:port optionally prefixed with udp://
+// or tcp://, defaulting to UDP. Returns nil if addr is empty.
+func NewForwarder(addr string, buffer int) (*Forwarder, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	network := "udp"
+	if i := strings.Index(addr, "://"); i >= 0 {
+		network, addr = addr[:i], addr[i+3:]
+	}
+	if network != "udp" && network != "tcp" {
+		return nil, errors.Errorf("invalid syslog forward network %q, must be udp or tcp", network)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, errors.Wrap(err, "invalid syslog forward address")
+	}
+	if buffer < 1 {
+		return nil, errors.New("syslog forward buffer must be greater than 0")
+	}
+	d := &net.Dialer{Timeout: 5 * time.Second}
+
+	return &Forwarder{network: network, addr: addr, queue: make(chan []byte, buffer), dial: d.DialContext, drainTimeout: forwardDrainTimeout}, nil
+}
+
+// forward queues a copy of the raw message for relaying, or drops it if the queue is full.
+func (f *Forwarder) forward(raw []byte) {
+	if f == nil {
+		return
+	}
+	select {
+	case f.queue <- append([]byte(nil), raw...):
+	default:
+		metrics.SyslogForwardDropped.Inc()
+	}
+}
+
+// Run relays the queued messages until ctx is done, then sends the messages still
+// queued for up to drainTimeout. Messages that cannot be sent are dropped and the
+// collector is redialed.
+func (f *Forwarder) Run(ctx context.Context) error {
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			conn = f.drain(conn, nil)
+
+			return nil
+		case raw := <-f.queue:
+			if ctx.Err() != nil {
+				// select picked the message over ctx being done, it is sent with the rest
+				conn = f.drain(conn, raw)
+
+				return nil
+			}
+			conn = f.send(ctx, conn, raw)
+		}
+	}
+}
+
+// drain sends raw, unless nil, and the queued messages over conn until the queue is
+// empty or drainTimeout passed, dropping the rest. Returns the connection to close.
+func (f *Forwarder) drain(conn net.Conn, raw []byte) net.Conn {
+	ctx, cancel := context.WithTimeout(context.Background(), f.drainTimeout)
+	defer cancel()
+	if raw != nil {
+		conn = f.send(ctx, conn, raw)
+	}
+	for ctx.Err() == nil {
+		select {
+		case raw := <-f.queue:
+			conn = f.send(ctx, conn, raw)
+		default:
+			return conn
+		}
+	}
+	if n := len(f.queue); n > 0 {
+		metrics.SyslogForwardDropped.Add(float64(n))
+		sysloglog.With("messages", n).Info("syslog forwarding stopped with messages unsent")
+	}
+
+	return conn
+}
+
+// send sends raw over conn, dialing the collector if conn is nil. Returns the
+// connection to send the next message over, nil if it failed.
+func (f *Forwarder) send(ctx context.Context, conn net.Conn, raw []byte) net.Conn {
+	if conn == nil {
+		var err error
+		if conn, err = f.dial(ctx, f.network, f.addr); err != nil {
+			metrics.SyslogForwardDropped.Inc()
+			sysloglog.With("addr", f.addr).Error(errors.Wrap(err, "dial syslog collector"))
+			f.wait(ctx)
+
+			return nil
+		}
+	}
+	deadline := time.Now().Add(forwardWriteTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetWriteDeadline(deadline)
+	if _, err := conn.Write(f.frame(raw)); err != nil {
+		metrics.SyslogForwardDropped.Inc()
+		sysloglog.With("addr", f.addr).Error(errors.Wrap(err, "forward syslog message"))
+		conn.Close()
+
+		return nil
+	}
+
+	return conn
+}
+
+// wait waits forwardRedial or until ctx is done.
+func (f *Forwarder) wait(ctx context.Context) {
+	t := time.NewTimer(forwardRedial)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// frame returns raw as it is sent, a datagram over UDP and octet counted over TCP, see
+// RFC 6587 section 3.4.1.
+func (f *Forwarder) frame(raw []byte) []byte {
+	if f.network == "udp" {
+		return raw
+	}
+
+	return append([]byte(strconv.Itoa(len(raw))+" "), raw...)
+}
@@ -0,0 +1,198 @@
+package syslog
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/packethost/pkg/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+const testMessage = "<134>Oct 14 12:00:00 osie-installer[42]: hello"
+
+func TestMain(m *testing.M) {
+	l, err := log.Init("github.com/tinkerbell/boots")
+	if err != nil {
+		panic(err)
+	}
+	defer l.Close()
+	metrics.Init(l)
+	os.Exit(m.Run()) //nolint:gocritic // this seems to be the correct pattern
+}
+
+func TestNewForwarder(t *testing.T) {
+	tests := map[string]struct {
+		addr    string
+		network string
+		err     bool
+	}{
+		"disabled":    {addr: ""},
+		"udp default": {addr: "192.168.1.1:514", network: "udp"},
+		"udp":         {addr: "udp://192.168.1.1:514", network: "udp"},
+		"tcp":         {addr: "tcp://collector.example.com:601", network: "tcp"},
+		"no port":     {addr: "192.168.1.1", err: true},
+		"bad network": {addr: "http://192.168.1.1:514", err: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := NewForwarder(tt.addr, 10)
+			if (err != nil) != tt.err {
+				t.Fatalf("want error %t, got: %v", tt.err, err)
+			}
+			if tt.network == "" {
+				if f != nil {
+					t.Fatalf("want no forwarder, got %v", f)
+				}
+
+				return
+			}
+			if f.network != tt.network {
+				t.Fatalf("want network %s, got %s", tt.network, f.network)
+			}
+		})
+	}
+}
+
+func TestForwarderUDP(t *testing.T) {
+	Init(log.Test(t, "syslog"))
+	collector, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+
+	f, err := NewForwarder(collector.LocalAddr().String(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = f.Run(ctx) }()
+
+	f.forward([]byte(testMessage))
+	buf := make([]byte, 1024)
+	_ = collector.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := collector.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != testMessage {
+		t.Fatalf("want %q, got %q", testMessage, got)
+	}
+}
+
+func TestForwarderTCP(t *testing.T) {
+	Init(log.Test(t, "syslog"))
+	collector, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+
+	f, err := NewForwarder("tcp://"+collector.Addr().String(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = f.Run(ctx) }()
+
+	f.forward([]byte(testMessage))
+	f.forward([]byte(testMessage))
+	conn, err := collector.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	want := "46 " + testMessage + "46 " + testMessage
+	buf := make([]byte, len(want))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestForwarderFull(t *testing.T) {
+	f, err := NewForwarder("127.0.0.1:514", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped := testutil.ToFloat64(metrics.SyslogForwardDropped)
+
+	// nothing is sending, so only the first message fits
+	f.forward([]byte(testMessage))
+	f.forward([]byte(testMessage))
+	if n := testutil.ToFloat64(metrics.SyslogForwardDropped) - dropped; n != 1 {
+		t.Fatalf("want 1 dropped message, got %v", n)
+	}
+}
+
+func TestForwarderDrain(t *testing.T) {
+	Init(log.Test(t, "syslog"))
+	collector, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+
+	f, err := NewForwarder(collector.LocalAddr().String(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.forward([]byte(testMessage))
+	f.forward([]byte(testMessage))
+	// the messages queued when forwarding stops are still sent
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := f.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	for i := 0; i < 2; i++ {
+		_ = collector.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := collector.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); got != testMessage {
+			t.Fatalf("want %q, got %q", testMessage, got)
+		}
+	}
+}
+
+func TestForwarderDrainTimeout(t *testing.T) {
+	Init(log.Test(t, "syslog"))
+	f, err := NewForwarder("127.0.0.1:514", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.drainTimeout = 50 * time.Millisecond
+	f.dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	}
+	dropped := testutil.ToFloat64(metrics.SyslogForwardDropped)
+	f.forward([]byte(testMessage))
+	f.forward([]byte(testMessage))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if err := f.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("want the drain bounded by its timeout, took %v", d)
+	}
+	if n := testutil.ToFloat64(metrics.SyslogForwardDropped) - dropped; n != 2 {
+		t.Fatalf("want 2 dropped messages, got %v", n)
+	}
+}
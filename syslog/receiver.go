@@ -1,6 +1,7 @@
 package syslog
 
 import (
+	"context"
 	"net"
 	"sync"
 	"time"
@@ -15,13 +16,20 @@ var syslogMessagePool = sync.Pool{
 type Receiver struct {
 	c *net.UDPConn
 
-	parse chan *message
+	parse   chan *message
+	hub     *Hub
+	forward *Forwarder
 
 	done chan struct{}
 	err  error
+	// parsed is closed once the parsers have handled every received message
+	parsed  chan struct{}
+	parsers sync.WaitGroup
 }
 
-func StartReceiver(laddr string, parsers int) (*Receiver, error) {
+// StartReceiver listens for syslog messages on laddr and logs them. If hub is not nil
+// the messages are also published to it, and if forward is not nil they are relayed by it.
+func StartReceiver(laddr string, parsers int, hub *Hub, forward *Forwarder) (*Receiver, error) {
 	if parsers < 1 {
 		parsers = 1
 	}
@@ -37,14 +45,22 @@ func StartReceiver(laddr string, parsers int) (*Receiver, error) {
 	}
 
 	s := &Receiver{
-		c:     c,
-		parse: make(chan *message, parsers),
-		done:  make(chan struct{}),
+		c:       c,
+		parse:   make(chan *message, parsers),
+		hub:     hub,
+		forward: forward,
+		done:    make(chan struct{}),
+		parsed:  make(chan struct{}),
 	}
 
+	s.parsers.Add(parsers)
 	for i := 0; i < parsers; i++ {
 		go s.runParser()
 	}
+	go func() {
+		s.parsers.Wait()
+		close(s.parsed)
+	}()
 	go s.run()
 
 	return s, nil
@@ -58,6 +74,17 @@ func (r *Receiver) Err() error {
 	return r.err
 }
 
+// Shutdown stops receiving messages and waits until ctx is done for the messages
+// already received to be logged and published.
+func (r *Receiver) Shutdown(ctx context.Context) {
+	r.c.Close()
+	select {
+	case <-r.parsed:
+	case <-ctx.Done():
+		sysloglog.Error(errors.Wrap(ctx.Err(), "syslog shutdown timed out, dropping unparsed messages"))
+	}
+}
+
 func (r *Receiver) cleanup() {
 	r.c.Close()
 
@@ -85,6 +112,9 @@ func (r *Receiver) run() {
 			}
 		}
 		n, from, err := r.c.ReadFromUDP(msg.buf[:])
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
 		if err != nil {
 			err = errors.Wrap(err, "error reading udp message")
 			if _, ok := err.(net.Error); ok {
@@ -105,8 +135,11 @@ func (r *Receiver) run() {
 }
 
 func (r *Receiver) runParser() {
+	defer r.parsers.Done()
 	for m := range r.parse {
-		if m.parse() {
+		r.forward.forward(m.buf[:m.size])
+		parsed := m.parse()
+		if parsed {
 			if m.Severity() == DEBUG {
 				sysloglog.Debug(m)
 			} else {
@@ -115,6 +148,9 @@ func (r *Receiver) runParser() {
 		} else {
 			sysloglog.Debug(m)
 		}
+		if r.hub != nil {
+			r.hub.publish(m, parsed)
+		}
 		m.reset()
 		syslogMessagePool.Put(m)
 	}
@@ -0,0 +1,53 @@
+package syslog
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/packethost/pkg/log"
+)
+
+func TestReceiverShutdown(t *testing.T) {
+	Init(log.Test(t, "syslog"))
+	h := NewHub()
+	sub := h.Subscribe(1, nil)
+	defer sub.Close()
+	r, err := StartReceiver("127.0.0.1:0", 1, h, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := net.DialUDP("udp4", nil, r.c.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("<14>Oct 14 12:00:00 osie-installer[42]: hello")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-sub.C():
+		if e.Msg != "hello" {
+			t.Fatalf("want message hello, got %q", e.Msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r.Shutdown(ctx)
+	select {
+	case <-r.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("receiver not done after shutdown")
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("want no error after shutdown, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("shutdown timed out")
+	}
+}
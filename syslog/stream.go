@@ -0,0 +1,117 @@
+package syslog
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tinkerbell/boots/metrics"
+)
+
+// Event is a syslog message as published to Hub subscribers.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Host     string    `json:"host"`
+	Hostname string    `json:"hostname,omitempty"`
+	Facility string    `json:"facility,omitempty"`
+	Severity string    `json:"severity,omitempty"`
+	App      string    `json:"app,omitempty"`
+	ProcID   string    `json:"procid,omitempty"`
+	MsgID    string    `json:"msgid,omitempty"`
+	Msg      string    `json:"msg"`
+}
+
+func newEvent(m *message, parsed bool) Event {
+	e := Event{
+		Time: m.time,
+		Host: m.Host(),
+	}
+	if !parsed {
+		e.Msg = string(m.buf[:m.size])
+
+		return e
+	}
+	e.Hostname = string(m.hostname)
+	e.Facility = m.Facility().String()
+	e.Severity = m.Severity().String()
+	e.App = string(m.app)
+	e.ProcID = string(m.procid)
+	e.MsgID = string(m.msgid)
+	e.Msg = msgCleanup.Replace(string(m.msg))
+
+	return e
+}
+
+// Hub fans received syslog messages out to subscribers. Subscribers that do not
+// keep up have messages dropped instead of buffered.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[*Subscription]struct{}{}}
+}
+
+// Subscription receives the events accepted by its filter.
+type Subscription struct {
+	hub     *Hub
+	c       chan Event
+	filter  func(Event) bool
+	dropped uint64
+}
+
+// Subscribe returns a subscription buffering up to buffer events. A nil filter accepts all events.
+func (h *Hub) Subscribe(buffer int, filter func(Event) bool) *Subscription {
+	s := &Subscription{
+		hub:    h,
+		c:      make(chan Event, buffer),
+		filter: filter,
+	}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+
+	return s
+}
+
+// C returns the channel events are delivered on. It is closed by Close.
+func (s *Subscription) C() <-chan Event {
+	return s.c
+}
+
+// Dropped returns the number of events dropped because the subscriber was not keeping up.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close unsubscribes and closes the event channel.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if _, ok := s.hub.subs[s]; ok {
+		delete(s.hub.subs, s)
+		close(s.c)
+	}
+}
+
+func (h *Hub) publish(m *message, parsed bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.subs) == 0 {
+		return
+	}
+
+	e := newEvent(m, parsed)
+	for s := range h.subs {
+		if s.filter != nil && !s.filter(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+			metrics.SyslogStreamDropped.Inc()
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package syslog
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/boots/metrics"
+)
+
+func newTestMessage(host, msg string) *message {
+	m := &message{
+		time: time.Now().UTC(),
+		host: net.ParseIP(host),
+	}
+	m.size = copy(m.buf[:], msg)
+
+	return m
+}
+
+func TestHubPublish(t *testing.T) {
+	h := NewHub()
+	dropped := testutil.ToFloat64(metrics.SyslogStreamDropped)
+	all := h.Subscribe(1, nil)
+	defer all.Close()
+	filtered := h.Subscribe(10, func(e Event) bool { return e.Host == "192.168.1.2" })
+	defer filtered.Close()
+
+	for _, host := range []string{"192.168.1.1", "192.168.1.2", "192.168.1.3"} {
+		m := newTestMessage(host, "<14>Oct 14 12:00:00 osie-installer[42]: hello from "+host)
+		h.publish(m, m.parse())
+	}
+
+	if got := all.Dropped(); got != 2 {
+		t.Fatalf("want 2 dropped messages, got %d", got)
+	}
+	if n := testutil.ToFloat64(metrics.SyslogStreamDropped) - dropped; n != 2 {
+		t.Fatalf("want 2 dropped messages counted, got %v", n)
+	}
+	if e := <-all.C(); e.Host != "192.168.1.1" {
+		t.Fatalf("want first message from 192.168.1.1, got %q", e.Host)
+	}
+
+	if got := len(filtered.C()); got != 1 {
+		t.Fatalf("want 1 filtered message, got %d", got)
+	}
+	e := <-filtered.C()
+	want := Event{
+		Time:     e.Time,
+		Host:     "192.168.1.2",
+		Facility: "user",
+		Severity: "INFO",
+		App:      "osie-installer",
+		ProcID:   "42",
+		Msg:      "hello from 192.168.1.2",
+	}
+	if e != want {
+		t.Fatalf("want %+v, got %+v", want, e)
+	}
+	if got := filtered.Dropped(); got != 0 {
+		t.Fatalf("want 0 dropped messages, got %d", got)
+	}
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	h := NewHub()
+	s := h.Subscribe(1, nil)
+	s.Close()
+	s.Close()
+
+	if _, ok := <-s.C(); ok {
+		t.Fatal("want closed channel")
+	}
+	h.publish(newTestMessage("192.168.1.1", "<14>hello"), true)
+}